@@ -0,0 +1,412 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+)
+
+// DefaultApprovalTTL is the default time within which an Approval must be
+// submitted. Solana rejects transactions whose recent blockhash is more than
+// 150 blocks (roughly a minute) old, so longer TTLs are of little use.
+const DefaultApprovalTTL = time.Minute
+
+// WithApprovalTTL specifies the time within which an Approval built by
+// BuildApproval must be submitted. Defaults to DefaultApprovalTTL.
+func WithApprovalTTL(ttl time.Duration) SolanaOption {
+	return func(o *solanaOpts) {
+		o.approvalTTL = ttl
+	}
+}
+
+// Approval is a payment transaction that requires the signature of its sender,
+// allowing the sender's key to be held by a separate approver (such as a second
+// service, or a hardware security module), rather than by the service building
+// payments.
+//
+// Payments are approved in three steps:
+//
+//  1. A service without the sender's key builds an Approval with
+//     Client.BuildApproval, and exports it (for example, as JSON).
+//  2. The approver reviews the Payment, and signs it with Approve, which
+//     checks that the transaction makes exactly that payment.
+//  3. The service submits the signed Approval with Client.SubmitApproval.
+//
+// The transaction uses a recent blockhash, which Solana only accepts for 60 to
+// 90 seconds, so it must be approved and submitted before it Expires. Approvals
+// are therefore suited to automated approvers, not to review by a person; a
+// payment awaiting review should be stored, and an Approval built for it once
+// it is reviewed.
+type Approval struct {
+	// Payment is the payment made by the transaction. Its Sender is the owner
+	// of the sending account, who must approve it, and its Destination is the
+	// account that is credited, which may be a token account of the requested
+	// destination.
+	Payment ReadOnlyPayment `json:"payment"`
+
+	// Transaction is the marshaled transaction, without signatures.
+	Transaction []byte `json:"transaction"`
+
+	// Expires is the time after which the approval is no longer accepted.
+	Expires time.Time `json:"expires"`
+
+	// Signature is the sender's signature of the transaction, set by Approve.
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// Message returns the transaction message that the sender signs to approve the
+// payment.
+func (a Approval) Message() ([]byte, error) {
+	tx, err := a.transaction()
+	if err != nil {
+		return nil, err
+	}
+	return tx.Message.Marshal(), nil
+}
+
+// Check returns an error if the approval has expired at now, or if its
+// transaction does anything other than make Payment.
+func (a Approval) Check(now time.Time) error {
+	if !now.Before(a.Expires) {
+		return ErrApprovalExpired
+	}
+
+	tx, err := a.transaction()
+	if err != nil {
+		return err
+	}
+
+	il := a.invoiceList()
+	var ilHash []byte
+	if il != nil {
+		h, err := invoiceListHash(il)
+		if err != nil {
+			return err
+		}
+		ilHash = h[:]
+	}
+
+	parsed, err := kin.ParseTransaction(withoutComputeBudget(tx), il)
+	if err != nil {
+		return errors.Wrap(ErrInvalidApproval, err.Error())
+	}
+
+	var found bool
+	for _, r := range parsed.Regions {
+		if len(r.Creations) > 0 || len(r.Closures) > 0 {
+			return errors.Wrap(ErrInvalidApproval, "transaction contains instructions other than the payment")
+		}
+
+		for _, t := range r.Transfers {
+			if found {
+				return errors.Wrap(ErrInvalidApproval, "transaction contains more than one transfer")
+			}
+			found = true
+
+			switch {
+			case !bytes.Equal(t.Owner, a.Payment.Sender):
+				return errors.Wrap(ErrInvalidApproval, "transfer owner does not match payment sender")
+			case !bytes.Equal(t.Destination, a.Payment.Destination):
+				return errors.Wrap(ErrInvalidApproval, "transfer destination does not match payment destination")
+			case a.Payment.Quarks < 0 || t.Amount != uint64(a.Payment.Quarks):
+				return errors.Wrap(ErrInvalidApproval, "transfer amount does not match payment quarks")
+			}
+
+			if r.Memo != nil {
+				fk := r.Memo.ForeignKey()
+				switch {
+				case a.Payment.Memo != "":
+					return errors.Wrap(ErrInvalidApproval, "transaction memo does not match payment memo")
+				case r.Memo.TransactionType() != a.Payment.Type || r.Memo.AppIndex() != a.Payment.AppIndex:
+					return errors.Wrap(ErrInvalidApproval, "transaction memo does not match payment type")
+				case ilHash != nil && !bytes.Equal(fk[:len(ilHash)], ilHash):
+					return errors.Wrap(ErrInvalidApproval, "transaction memo does not match payment invoice")
+				}
+			} else if string(r.MemoData) != a.Payment.Memo || a.Payment.Invoice != nil {
+				return errors.Wrap(ErrInvalidApproval, "transaction memo does not match payment memo")
+			}
+		}
+	}
+	if !found {
+		return errors.Wrap(ErrInvalidApproval, "transaction contains no transfer")
+	}
+
+	return nil
+}
+
+// Approve checks the approval at now (see Check), and signs it with key, which
+// must be the key of the payment's sender.
+func (a *Approval) Approve(key kin.PrivateKey, now time.Time) error {
+	if len(key) != ed25519.PrivateKeySize || !bytes.Equal(key.Public(), a.Payment.Sender) {
+		return errors.New("key is not the key of the payment sender")
+	}
+	if err := a.Check(now); err != nil {
+		return err
+	}
+
+	message, err := a.Message()
+	if err != nil {
+		return err
+	}
+
+	a.Signature = ed25519.Sign(ed25519.PrivateKey(key), message)
+	return nil
+}
+
+// Verify checks the approval at now (see Check), and verifies that it has been
+// signed by the payment's sender.
+func (a Approval) Verify(now time.Time) error {
+	if err := a.Check(now); err != nil {
+		return err
+	}
+
+	message, err := a.Message()
+	if err != nil {
+		return err
+	}
+	if len(a.Payment.Sender) != ed25519.PublicKeySize || !ed25519.Verify(ed25519.PublicKey(a.Payment.Sender), message, a.Signature) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func (a Approval) transaction() (tx solana.Transaction, err error) {
+	if err := tx.Unmarshal(a.Transaction); err != nil {
+		return tx, errors.Wrap(ErrInvalidApproval, "failed to unmarshal transaction")
+	}
+	return tx, nil
+}
+
+func (a Approval) invoiceList() *commonpb.InvoiceList {
+	if a.Payment.Invoice == nil {
+		return nil
+	}
+	return &commonpb.InvoiceList{Invoices: []*commonpb.Invoice{a.Payment.Invoice}}
+}
+
+// BuildApproval builds a transaction for payment that must be approved by its
+// sender before it is submitted with SubmitApproval (see Approval).
+//
+// The sender and destination are resolved to their token accounts before the
+// transaction is built, since it cannot be resubmitted after it is approved.
+func (c *client) BuildApproval(ctx context.Context, payment ReadOnlyPayment, opts ...SolanaOption) (Approval, error) {
+	if len(payment.Sender) != ed25519.PublicKeySize {
+		return Approval{}, errors.New("payment must have a valid sender")
+	}
+	if len(payment.Destination) != ed25519.PublicKeySize {
+		return Approval{}, errors.New("payment must have a valid destination")
+	}
+	if err := validateQuarks(payment.Quarks); err != nil {
+		return Approval{}, err
+	}
+	if payment.Invoice != nil && c.opts.appIndex == 0 {
+		return Approval{}, errors.New("cannot submit payment with invoices without an app index")
+	}
+
+	solanaOpts := solanaOpts{
+		commitment:        c.opts.defaultCommitment,
+		accountResolution: AccountResolutionPreferred,
+		destResolution:    AccountResolutionPreferred,
+		approvalTTL:       DefaultApprovalTTL,
+	}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	config, err := c.internal.GetServiceConfig(ctx)
+	if err != nil {
+		return Approval{}, errors.Wrap(err, "failed to get service config")
+	}
+	subsidizer := kin.PublicKey(config.GetSubsidizerAccount().GetValue())
+	if solanaOpts.subsidizer != nil {
+		subsidizer = solanaOpts.subsidizer.Public()
+	}
+	if subsidizer == nil {
+		return Approval{}, ErrNoSubsidizer
+	}
+
+	source := payment.Sender
+	if solanaOpts.accountResolution == AccountResolutionPreferred {
+		if source, err = c.resolveApprovalAccount(ctx, payment.Sender); err != nil {
+			return Approval{}, err
+		}
+	}
+	if solanaOpts.destResolution == AccountResolutionPreferred {
+		if payment.Destination, err = c.resolveApprovalAccount(ctx, payment.Destination); err != nil {
+			return Approval{}, err
+		}
+	}
+
+	memoInstruction, _, err := c.paymentMemo(Payment{Type: payment.Type, Invoice: payment.Invoice, Memo: payment.Memo})
+	if err != nil {
+		return Approval{}, err
+	}
+	payment.AppIndex = 0
+	if payment.Memo == "" {
+		payment.AppIndex = c.opts.appIndex
+	}
+
+	instructions := solanaOpts.computeBudgetInstructions()
+	if memoInstruction != nil {
+		instructions = append(instructions, *memoInstruction)
+	}
+	instructions = append(instructions, token.Transfer(
+		ed25519.PublicKey(source),
+		ed25519.PublicKey(payment.Destination),
+		ed25519.PublicKey(payment.Sender),
+		uint64(payment.Quarks),
+	))
+
+	tx := solana.NewTransaction(ed25519.PublicKey(subsidizer), instructions...)
+	if err := checkTransactionSize(tx); err != nil {
+		return Approval{}, err
+	}
+
+	blockhash, err := c.internal.GetRecentBlockhash(ctx)
+	if err != nil {
+		return Approval{}, errors.Wrap(err, "failed to get recent blockhash")
+	}
+	tx.SetBlockhash(blockhash)
+
+	return Approval{
+		Payment:     payment,
+		Transaction: tx.Marshal(),
+		Expires:     c.opts.clock.Now().Add(solanaOpts.approvalTTL).UTC(),
+	}, nil
+}
+
+// resolveApprovalAccount returns the first token account owned by account, or
+// account itself if it owns none.
+func (c *client) resolveApprovalAccount(ctx context.Context, account kin.PublicKey) (kin.PublicKey, error) {
+	tokenAccounts, err := c.internal.ResolveTokenAccounts(ctx, account, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve token accounts")
+	}
+	if len(tokenAccounts) == 0 {
+		return account, nil
+	}
+	return tokenAccounts[0].AccountId.Value, nil
+}
+
+// SubmitApproval verifies and submits an approved payment (see Approval),
+// returning the ID of its transaction.
+//
+// As with SubmitPayment, the payment is checked by the screener specified with
+// WithPaymentScreener, and against the limits specified with WithLimits. Since
+// the client does not hold the sender's key, the Sender of the screened Payment
+// only provides the sender's public key, with Public.
+//
+// Unlike SubmitPayment, the transaction cannot be rebuilt if its blockhash has
+// expired, in which case ErrBlockhashNotFound is returned, and a new approval
+// is required.
+func (c *client) SubmitApproval(ctx context.Context, approval Approval, opts ...SolanaOption) ([]byte, error) {
+	solanaOpts := solanaOpts{commitment: c.opts.defaultCommitment}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+	if solanaOpts.appUser != nil {
+		ctx = ContextWithAppUserCredentials(ctx, solanaOpts.appUser.userID, solanaOpts.appUser.passkey)
+	}
+
+	if err := approval.Verify(c.opts.clock.Now()); err != nil {
+		return nil, err
+	}
+
+	err := c.screen(Payment{
+		Sender:      publicOnlyKey(approval.Payment.Sender),
+		Destination: approval.Payment.Destination,
+		Type:        approval.Payment.Type,
+		Quarks:      approval.Payment.Quarks,
+		Invoice:     approval.Payment.Invoice,
+		Memo:        approval.Payment.Memo,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if limits := c.limits(); limits != nil {
+		err := limits.check(ctx, c.opts.appIndex, []limitEntry{{destination: approval.Payment.Destination, quarks: approval.Payment.Quarks}}, c.opts.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := approval.transaction()
+	if err != nil {
+		return nil, err
+	}
+	if err := setSignature(&tx, approval.Payment.Sender, approval.Signature); err != nil {
+		return nil, err
+	}
+
+	il := approval.invoiceList()
+	if solanaOpts.subsidizer != nil && bytes.Equal(solanaOpts.subsidizer.Public(), tx.Message.Accounts[0]) {
+		if err := tx.Sign(ed25519.PrivateKey(solanaOpts.subsidizer)); err != nil {
+			return nil, errors.Wrap(err, "failed to sign transaction")
+		}
+	} else {
+		signResult, err := c.internal.SignTransaction(ctx, tx, il)
+		if err != nil {
+			return nil, err
+		}
+		if len(signResult.InvoiceErrors) > 0 {
+			return tx.Signature(), invoiceErrorFromProto(signResult.InvoiceErrors[0])
+		}
+		if bytes.Equal(signResult.ID, make([]byte, ed25519.SignatureSize)) {
+			return nil, ErrPayerRequired
+		}
+		copy(tx.Signatures[0][:], signResult.ID)
+	}
+
+	done, err := c.lifecycle.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	result, err := c.internal.SubmitSolanaTransaction(ctx, tx, il, solanaOpts.commitment, nil)
+	if err != nil {
+		return tx.Signature(), err
+	}
+
+	if len(result.Errors.PaymentErrors) > 0 {
+		return result.ID, result.Errors.PaymentErrors[0]
+	}
+	if result.Errors.TxError != nil {
+		return result.ID, result.Errors.TxError
+	}
+	if len(result.InvoiceErrors) > 0 {
+		return result.ID, invoiceErrorFromProto(result.InvoiceErrors[0])
+	}
+
+	return result.ID, nil
+}
+
+// publicOnlyKey returns a PrivateKey whose Public key is key, and whose seed is
+// zero, for passing a public key where a PrivateKey is expected. It cannot be
+// used to sign.
+func publicOnlyKey(key kin.PublicKey) kin.PrivateKey {
+	k := make(kin.PrivateKey, ed25519.PrivateKeySize)
+	copy(k[ed25519.SeedSize:], key)
+	return k
+}
+
+// setSignature sets the signature of signer in tx.
+func setSignature(tx *solana.Transaction, signer kin.PublicKey, signature []byte) error {
+	for i := 0; i < len(tx.Signatures) && i < len(tx.Message.Accounts); i++ {
+		if bytes.Equal(tx.Message.Accounts[i], signer) {
+			copy(tx.Signatures[i][:], signature)
+			return nil
+		}
+	}
+	return errors.Errorf("%s is not a signer of the transaction", base58.Encode(signer))
+}
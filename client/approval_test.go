@@ -0,0 +1,196 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+)
+
+func TestClient_Approval(t *testing.T) {
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	env, cleanup := setup(t, WithClock(clock))
+	defer cleanup()
+
+	_, _, subsidizer := setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	approval, err := env.client.BuildApproval(context.Background(), ReadOnlyPayment{
+		Sender:      sender.Public(),
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      100,
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, approval.Payment.AppIndex)
+	assert.Equal(t, clock.Now().Add(DefaultApprovalTTL).UTC(), approval.Expires)
+
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(approval.Transaction))
+	assert.EqualValues(t, subsidizer, tx.Message.Accounts[0])
+	assert.EqualValues(t, RecentBlockhash, tx.Message.RecentBlockhash[:])
+
+	// The approval is exported to the approver, who holds the sender's key.
+	b, err := json.Marshal(approval)
+	require.NoError(t, err)
+	var exported Approval
+	require.NoError(t, json.Unmarshal(b, &exported))
+	assert.Equal(t, approval, exported)
+
+	// Unapproved payments are not submitted.
+	_, err = env.client.SubmitApproval(context.Background(), exported)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	other, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	assert.Error(t, exported.Approve(other, clock.Now()))
+	require.NoError(t, exported.Approve(sender, clock.Now()))
+
+	txID, err := env.client.SubmitApproval(context.Background(), exported)
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 1)
+	submitted := env.v4Server.Submits[0].Transaction.Value
+	env.v4Server.Mux.Unlock()
+
+	require.NoError(t, tx.Unmarshal(submitted))
+	assert.EqualValues(t, txID, tx.Signature())
+	message := tx.Message.Marshal()
+	assert.True(t, ed25519.Verify(ed25519.PublicKey(subsidizer), message, tx.Signatures[0][:]))
+	assert.True(t, ed25519.Verify(ed25519.PublicKey(sender.Public()), message, tx.Signatures[1][:]))
+
+	// Approvals expire.
+	clock.advance(DefaultApprovalTTL)
+	_, err = env.client.SubmitApproval(context.Background(), exported)
+	assert.Equal(t, ErrApprovalExpired, err)
+	assert.Equal(t, ErrApprovalExpired, exported.Approve(sender, clock.Now()))
+}
+
+func TestClient_ApprovalScreenedAndLimited(t *testing.T) {
+	var screened []Payment
+	screenErr := errors.New("denied")
+	env, cleanup := setup(t,
+		WithPaymentScreener(func(p Payment) error {
+			screened = append(screened, p)
+			return screenErr
+		}),
+		WithLimits(Limits{MaxPaymentQuarks: 50}),
+	)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	build := func(quarks int64) Approval {
+		approval, err := env.client.BuildApproval(context.Background(), ReadOnlyPayment{
+			Sender:      sender.Public(),
+			Destination: dest.Public(),
+			Type:        kin.TransactionTypeSpend,
+			Quarks:      quarks,
+		})
+		require.NoError(t, err)
+		require.NoError(t, approval.Approve(sender, time.Now()))
+		return approval
+	}
+
+	// Approved payments are still screened.
+	_, err = env.client.SubmitApproval(context.Background(), build(10))
+	assert.Equal(t, ErrPaymentScreened, errors.Cause(err))
+	require.Len(t, screened, 1)
+	assert.Equal(t, sender.Public(), screened[0].Sender.Public())
+	assert.Equal(t, dest.Public(), screened[0].Destination)
+	assert.EqualValues(t, 10, screened[0].Quarks)
+
+	// And limited.
+	screenErr = nil
+	_, err = env.client.SubmitApproval(context.Background(), build(100))
+	assert.Equal(t, ErrLimitExceeded, errors.Cause(err))
+
+	env.v4Server.Mux.Lock()
+	assert.Empty(t, env.v4Server.Submits)
+	env.v4Server.Mux.Unlock()
+
+	_, err = env.client.SubmitApproval(context.Background(), build(10))
+	require.NoError(t, err)
+}
+
+func TestApproval_Check(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	attacker, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	invoice := &commonpb.Invoice{
+		Items: []*commonpb.Invoice_LineItem{{Title: "treasury transfer", Amount: 100}},
+	}
+	approval, err := env.client.BuildApproval(context.Background(), ReadOnlyPayment{
+		Sender:      sender.Public(),
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      100,
+		Invoice:     invoice,
+	})
+	require.NoError(t, err)
+	require.NoError(t, approval.Check(time.Now()))
+
+	// The payment shown to the approver must match the transaction.
+	for name, tamper := range map[string]func(a *Approval){
+		"quarks":      func(a *Approval) { a.Payment.Quarks = 10 },
+		"destination": func(a *Approval) { a.Payment.Destination = attacker.Public() },
+		"sender":      func(a *Approval) { a.Payment.Sender = attacker.Public() },
+		"type":        func(a *Approval) { a.Payment.Type = kin.TransactionTypeEarn },
+		"memo":        func(a *Approval) { a.Payment.Memo = "memo" },
+		"invoice": func(a *Approval) {
+			a.Payment.Invoice = &commonpb.Invoice{
+				Items: []*commonpb.Invoice_LineItem{{Title: "other", Amount: 100}},
+			}
+		},
+		"transaction": func(a *Approval) { a.Transaction = a.Transaction[:10] },
+	} {
+		tampered := approval
+		tamper(&tampered)
+		assert.Equal(t, ErrInvalidApproval, errors.Cause(tampered.Check(time.Now())), name)
+	}
+
+	// Transactions with additional transfers are rejected.
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(approval.Transaction))
+	transfer := token.Transfer(ed25519.PublicKey(sender.Public()), ed25519.PublicKey(dest.Public()), ed25519.PublicKey(sender.Public()), 100)
+	injected := approval
+	injected.Transaction = solana.NewTransaction(
+		tx.Message.Accounts[0],
+		memo.Instruction(string(tx.Message.Instructions[0].Data)),
+		transfer,
+		token.Transfer(ed25519.PublicKey(sender.Public()), ed25519.PublicKey(attacker.Public()), ed25519.PublicKey(sender.Public()), 100),
+	).Marshal()
+	assert.Equal(t, ErrInvalidApproval, errors.Cause(injected.Check(time.Now())))
+
+	assert.Equal(t, ErrApprovalExpired, approval.Check(approval.Expires))
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+)
+
+// AuditStage indicates which stage of a submission an AuditRecord pertains to.
+type AuditStage string
+
+const (
+	AuditStageAttempt AuditStage = "attempt"
+	AuditStageResult  AuditStage = "result"
+)
+
+// AuditOutcome is the outcome of a submission.
+type AuditOutcome string
+
+const (
+	AuditOutcomeSuccess      AuditOutcome = "success"
+	AuditOutcomeFailed       AuditOutcome = "failed"
+	AuditOutcomeInvoiceError AuditOutcome = "invoice_error"
+	AuditOutcomeError        AuditOutcome = "error"
+)
+
+// AuditRecord describes a single submission attempt, or the result of one.
+//
+// Keys and transaction IDs are base58 encoded.
+type AuditRecord struct {
+	Time      time.Time       `json:"time"`
+	Stage     AuditStage      `json:"stage"`
+	TxID      string          `json:"tx_id"`
+	DedupeID  []byte          `json:"dedupe_id,omitempty"`
+	Signers   []string        `json:"signers"`
+	Transfers []AuditTransfer `json:"transfers"`
+	Memo      string          `json:"memo,omitempty"`
+
+	// RequestID is the request ID the transaction was submitted with (see
+	// ContextWithRequestID).
+	RequestID string `json:"request_id,omitempty"`
+
+	// Outcome and Error are only set for AuditStageResult records.
+	Outcome AuditOutcome `json:"outcome,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// AuditTransfer is a transfer contained in an audited transaction.
+type AuditTransfer struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Owner       string `json:"owner"`
+	Quarks      uint64 `json:"quarks"`
+}
+
+// AuditSink receives an AuditRecord for every submission attempt made by the client,
+// as well as every result.
+//
+// If recording an attempt fails, the transaction is not submitted. Failures to record
+// results are ignored, as the transaction has already been submitted.
+type AuditSink interface {
+	Record(ctx context.Context, record AuditRecord) error
+}
+
+// WithAuditSink specifies an AuditSink that the client records all submissions to.
+func WithAuditSink(sink AuditSink) ClientOption {
+	return func(o *clientOpts) {
+		o.auditSink = sink
+	}
+}
+
+type noopAuditSink struct{}
+
+// NewNoopAuditSink returns an AuditSink that discards all records.
+func NewNoopAuditSink() AuditSink {
+	return noopAuditSink{}
+}
+
+// Record implements AuditSink.Record.
+func (noopAuditSink) Record(context.Context, AuditRecord) error {
+	return nil
+}
+
+type jsonLinesAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesAuditSink returns an AuditSink that writes each record to w
+// as a single line of JSON.
+func NewJSONLinesAuditSink(w io.Writer) AuditSink {
+	return &jsonLinesAuditSink{w: w}
+}
+
+// Record implements AuditSink.Record.
+func (s *jsonLinesAuditSink) Record(_ context.Context, record AuditRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit record")
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(b)
+	return err
+}
+
+func newAuditRecord(ctx context.Context, stage AuditStage, tx solana.Transaction, dedupeID []byte, now time.Time) AuditRecord {
+	record := AuditRecord{
+		Time:     now,
+		Stage:    stage,
+		TxID:     base58.Encode(tx.Signature()),
+		DedupeID: dedupeID,
+	}
+	record.RequestID, _ = RequestIDFromContext(ctx)
+
+	for i := 0; i < int(tx.Message.Header.NumSignatures) && i < len(tx.Message.Accounts); i++ {
+		record.Signers = append(record.Signers, base58.Encode(tx.Message.Accounts[i]))
+	}
+
+	for i := range tx.Message.Instructions {
+		if transfer, err := token.DecompileTransfer(tx.Message, i); err == nil {
+			record.Transfers = append(record.Transfers, AuditTransfer{
+				Source:      base58.Encode(transfer.Source),
+				Destination: base58.Encode(transfer.Destination),
+				Owner:       base58.Encode(transfer.Owner),
+				Quarks:      transfer.Amount,
+			})
+		} else if m, err := memo.DecompileMemo(tx.Message, i); err == nil {
+			record.Memo = string(m.Data)
+		}
+	}
+
+	return record
+}
+
+func auditOutcome(result SubmitTransactionResult, err error) (AuditOutcome, string) {
+	switch {
+	case err != nil:
+		return AuditOutcomeError, err.Error()
+	case result.Errors.TxError != nil:
+		return AuditOutcomeFailed, result.Errors.TxError.Error()
+	case len(result.InvoiceErrors) > 0:
+		return AuditOutcomeInvoiceError, ""
+	default:
+		return AuditOutcomeSuccess, ""
+	}
+}
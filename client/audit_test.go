@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+type failingAuditSink struct{}
+
+func (failingAuditSink) Record(context.Context, AuditRecord) error {
+	return errors.New("unavailable")
+}
+
+func TestClient_AuditSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	env, cleanup := setup(t, WithAuditSink(NewJSONLinesAuditSink(buf)))
+	defer cleanup()
+
+	_, _, subsidizer := setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	for _, k := range []kin.PrivateKey{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), k))
+	}
+
+	txID, err := env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      10,
+		Memo:        "1-test",
+		DedupeID:    []byte("dedupe"),
+	})
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		{
+			Result: transactionpbv4.SubmitTransactionResponse_FAILED,
+			TransactionError: &commonpbv4.TransactionError{
+				Reason: commonpbv4.TransactionError_INSUFFICIENT_FUNDS,
+				Raw:    []byte("rawerror"),
+			},
+		},
+	}
+	env.v4Server.Mux.Unlock()
+
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      10,
+	}, WithDestResolution(AccountResolutionExact), WithAccountResolution(AccountResolutionExact))
+	assert.Equal(t, ErrInsufficientBalance, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+
+	records := make([]AuditRecord, len(lines))
+	for i, l := range lines {
+		require.NoError(t, json.Unmarshal([]byte(l), &records[i]))
+	}
+
+	assert.Equal(t, AuditStageAttempt, records[0].Stage)
+	assert.Empty(t, records[0].Outcome)
+	assert.Equal(t, AuditStageResult, records[1].Stage)
+	assert.Equal(t, AuditOutcomeSuccess, records[1].Outcome)
+	assert.Equal(t, base58.Encode(txID), records[1].TxID)
+	assert.Equal(t, []byte("dedupe"), records[1].DedupeID)
+	assert.Equal(t, "1-test", records[1].Memo)
+	assert.Equal(t, []string{base58.Encode(subsidizer), sender.Public().Base58()}, records[1].Signers)
+	require.Len(t, records[1].Transfers, 1)
+	assert.Equal(t, dest.Public().Base58(), records[1].Transfers[0].Destination)
+	assert.Equal(t, sender.Public().Base58(), records[1].Transfers[0].Owner)
+	assert.EqualValues(t, 10, records[1].Transfers[0].Quarks)
+
+	assert.Equal(t, AuditOutcomeFailed, records[3].Outcome)
+	assert.Equal(t, ErrInsufficientBalance.Error(), records[3].Error)
+}
+
+func TestClient_AuditSinkFailure(t *testing.T) {
+	env, cleanup := setup(t, WithAuditSink(failingAuditSink{}))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	require.NoError(t, env.client.CreateAccount(context.Background(), sender))
+
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: sender.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      10,
+	})
+	assert.Error(t, err)
+
+	env.v4Server.Mux.Lock()
+	assert.Empty(t, env.v4Server.Submits)
+	env.v4Server.Mux.Unlock()
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+
+	"github.com/kinecosystem/agora-common/kin"
+
+	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
+)
+
+// TokenAccountBalance is the balance of a single token account.
+type TokenAccountBalance struct {
+	Account kin.PublicKey
+	Quarks  int64
+
+	// Owner is the owner of the token account, which may be nil if it was not
+	// provided by Agora.
+	Owner kin.PublicKey
+
+	// CloseAuthority is the close authority of the token account, which is nil
+	// if it does not have one.
+	CloseAuthority kin.PublicKey
+}
+
+// BalanceDetail breaks the balance of an account down by token account.
+type BalanceDetail struct {
+	// Quarks is the total balance of all of the token accounts.
+	Quarks int64
+
+	// TokenAccounts contains the token accounts of the account, in the order
+	// they are returned by ResolveTokenAccounts.
+	TokenAccounts []TokenAccountBalance
+}
+
+// GetBalanceDetail returns the balance of each of the token accounts owned by
+// account, along with their owner and close authority.
+//
+// If account does not own any token accounts, but is itself a token account,
+// its balance is returned instead. ErrAccountDoesNotExist is returned if it is
+// neither.
+func (c *client) GetBalanceDetail(ctx context.Context, account kin.PublicKey, opts ...SolanaOption) (BalanceDetail, error) {
+	solanaOpts := solanaOpts{commitment: c.opts.defaultCommitment}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	accountInfos, err := c.internal.ResolveTokenAccounts(ctx, account, true)
+	if err != nil {
+		return BalanceDetail{}, err
+	}
+	if len(accountInfos) == 0 {
+		accountInfo, err := c.internal.GetSolanaAccountInfo(ctx, account, solanaOpts.commitment)
+		if err != nil {
+			return BalanceDetail{}, err
+		}
+		accountInfos = []*accountpbv4.AccountInfo{accountInfo}
+	}
+
+	detail := BalanceDetail{
+		TokenAccounts: make([]TokenAccountBalance, len(accountInfos)),
+	}
+	for i, info := range accountInfos {
+		detail.TokenAccounts[i] = TokenAccountBalance{
+			Account:        info.GetAccountId().GetValue(),
+			Quarks:         info.Balance,
+			Owner:          info.GetOwner().GetValue(),
+			CloseAuthority: info.GetCloseAuthority().GetValue(),
+		}
+		detail.Quarks += info.Balance
+	}
+
+	return detail, nil
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/mr-tron/base58"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+
+	"github.com/kinecosystem/kin-go/client/testutil"
+)
+
+func TestClient_GetBalanceDetail(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	mint, _, subsidizer := setServiceConfigResp(t, env.v4Server, true)
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	_, err = env.client.GetBalanceDetail(context.Background(), priv.Public())
+	assert.Equal(t, ErrAccountDoesNotExist, err)
+
+	// Without any resolved token accounts, a token account is its own detail.
+	require.NoError(t, env.client.CreateAccount(context.Background(), priv))
+	tokenAcc, err := token.GetAssociatedAccount(ed25519.PublicKey(priv.Public()), mint)
+	require.NoError(t, err)
+
+	detail, err := env.client.GetBalanceDetail(context.Background(), kin.PublicKey(tokenAcc))
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, detail.Quarks)
+	require.Len(t, detail.TokenAccounts, 1)
+	assert.EqualValues(t, tokenAcc, detail.TokenAccounts[0].Account)
+	assert.EqualValues(t, priv.Public(), detail.TokenAccounts[0].Owner)
+	assert.Nil(t, detail.TokenAccounts[0].CloseAuthority)
+
+	// Register additional token accounts owned by priv.
+	other := testutil.GenerateSolanaKeys(t, 2)
+	env.v4Server.Mux.Lock()
+	for i, a := range other {
+		env.v4Server.Accounts[base58.Encode(a)] = &accountpbv4.AccountInfo{
+			AccountId:      &commonpbv4.SolanaAccountId{Value: a},
+			Balance:        int64(i+1) * 100,
+			Owner:          &commonpbv4.SolanaAccountId{Value: priv.Public()},
+			CloseAuthority: &commonpbv4.SolanaAccountId{Value: subsidizer},
+		}
+		env.v4Server.TokenAccounts[priv.Public().Base58()] = append(
+			env.v4Server.TokenAccounts[priv.Public().Base58()],
+			&commonpbv4.SolanaAccountId{Value: a},
+		)
+	}
+	env.v4Server.Mux.Unlock()
+
+	detail, err = env.client.GetBalanceDetail(context.Background(), priv.Public())
+	require.NoError(t, err)
+	assert.EqualValues(t, 310, detail.Quarks)
+	require.Len(t, detail.TokenAccounts, 3)
+	assert.EqualValues(t, tokenAcc, detail.TokenAccounts[0].Account)
+	for i, a := range other {
+		balance := detail.TokenAccounts[i+1]
+		assert.EqualValues(t, a, balance.Account)
+		assert.EqualValues(t, (i+1)*100, balance.Quarks)
+		assert.EqualValues(t, priv.Public(), balance.Owner)
+		assert.EqualValues(t, subsidizer, balance.CloseAuthority)
+	}
+}
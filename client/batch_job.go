@@ -0,0 +1,282 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+)
+
+// BatchJobChunkStatus is the status of a chunk of a BatchJob.
+type BatchJobChunkStatus int
+
+const (
+	// BatchJobChunkPending indicates the chunk has not been submitted.
+	BatchJobChunkPending BatchJobChunkStatus = iota
+
+	// BatchJobChunkSubmitting indicates the chunk was submitted, but its
+	// outcome is not known, such as if the process died during submission.
+	// It is resubmitted with the same DedupeID, so that it is not paid twice.
+	BatchJobChunkSubmitting
+
+	// BatchJobChunkSucceeded indicates the chunk's transaction succeeded.
+	BatchJobChunkSucceeded
+
+	// BatchJobChunkFailed indicates the chunk's transaction failed. It is
+	// resubmitted with a new DedupeID.
+	BatchJobChunkFailed
+)
+
+// BatchJobChunk is a chunk of a BatchJob, which is submitted in its own
+// transaction.
+type BatchJobChunk struct {
+	// Start and End are the range of the earns of the job in the chunk.
+	Start int
+	End   int
+
+	Status BatchJobChunkStatus
+
+	// Attempt is the number of times the chunk has failed, and DedupeID is
+	// the DedupeID of its current attempt.
+	Attempt  int
+	DedupeID []byte
+
+	// Result is the result of the most recent submission, if it completed.
+	Result *EarnBatchResult
+
+	// Error is the error of the most recent submission, if it did not
+	// complete.
+	Error string
+}
+
+// BatchJob is an earn batch whose progress is recorded in a JobStore, so that
+// if the process submitting it dies, another can resume it without paying any
+// earn twice. Use a BatchJobRunner to start and resume jobs.
+//
+// A job is split into chunks of at most MaxBatchSize earns, each with a
+// DedupeID derived from the DedupeID of the job.
+type BatchJob struct {
+	ID string
+
+	// Sender is the sender of the earns. Its private key is not stored, and
+	// must be provided to resume the job.
+	Sender kin.PublicKey
+
+	Memo     string
+	Mint     kin.PublicKey
+	DedupeID []byte
+	Earns    []Earn
+
+	Chunks []BatchJobChunk
+
+	Created time.Time
+	Updated time.Time
+}
+
+// Done returns whether every chunk of the job succeeded.
+func (j BatchJob) Done() bool {
+	return len(j.Outstanding()) == 0
+}
+
+// Outstanding returns the indices of the chunks that have not succeeded.
+func (j BatchJob) Outstanding() []int {
+	var outstanding []int
+	for i, chunk := range j.Chunks {
+		if chunk.Status != BatchJobChunkSucceeded {
+			outstanding = append(outstanding, i)
+		}
+	}
+	return outstanding
+}
+
+// JobStore stores BatchJobs. Implementations must be safe for concurrent use.
+type JobStore interface {
+	// Create stores a new job, returning ErrJobExists if a job with the same
+	// ID exists.
+	Create(ctx context.Context, job BatchJob) error
+
+	// Update replaces an existing job, returning ErrJobNotFound if it does
+	// not exist.
+	Update(ctx context.Context, job BatchJob) error
+
+	// Load returns the job with the specified ID, or ErrJobNotFound.
+	Load(ctx context.Context, id string) (BatchJob, error)
+}
+
+// BatchJobRunner starts and resumes BatchJobs.
+type BatchJobRunner struct {
+	client Client
+	store  JobStore
+	clock  Clock
+}
+
+// NewBatchJobRunner returns a BatchJobRunner that submits jobs with c, recording
+// their progress in store.
+func NewBatchJobRunner(c Client, store JobStore) *BatchJobRunner {
+	return &BatchJobRunner{
+		client: c,
+		store:  store,
+		clock:  SystemClock(),
+	}
+}
+
+// Start creates a job for batch with the specified ID, and submits it as with
+// Resume.
+//
+// If batch has no DedupeID, the DedupeID of the job is derived from its ID.
+func (r *BatchJobRunner) Start(ctx context.Context, id string, batch EarnBatch, opts ...SolanaOption) (BatchJob, error) {
+	job, err := r.newJob(id, batch)
+	if err != nil {
+		return job, err
+	}
+	if err := r.store.Create(ctx, job); err != nil {
+		return job, err
+	}
+
+	return r.run(ctx, job, batch.Sender, opts)
+}
+
+// Load returns the job with the specified ID.
+func (r *BatchJobRunner) Load(ctx context.Context, id string) (BatchJob, error) {
+	return r.store.Load(ctx, id)
+}
+
+// Resume submits the outstanding chunks of the job with the specified ID, in
+// order, stopping at the first chunk that fails. It returns the updated job,
+// and an error if any chunk failed.
+func (r *BatchJobRunner) Resume(ctx context.Context, id string, sender kin.PrivateKey, opts ...SolanaOption) (BatchJob, error) {
+	job, err := r.store.Load(ctx, id)
+	if err != nil {
+		return job, err
+	}
+	if len(sender) != ed25519.PrivateKeySize || !bytes.Equal(sender.Public(), job.Sender) {
+		return job, errors.Errorf("sender does not match the sender of job %s", id)
+	}
+
+	return r.run(ctx, job, sender, opts)
+}
+
+func (r *BatchJobRunner) newJob(id string, batch EarnBatch) (BatchJob, error) {
+	if id == "" {
+		return BatchJob{}, errors.New("job must have an ID")
+	}
+	if len(batch.Sender) != ed25519.PrivateKeySize {
+		return BatchJob{}, errors.New("job must have a valid sender")
+	}
+	if len(batch.Earns) == 0 {
+		return BatchJob{}, errors.New("earn batch must contain at least 1 earn")
+	}
+	for i, e := range batch.Earns {
+		if len(e.Destination) != ed25519.PublicKeySize {
+			return BatchJob{}, errors.Errorf("earn %d: invalid destination", i)
+		}
+		if e.Source != nil || e.Owner != nil {
+			// Jobs are persisted without private keys, so the owners of
+			// overridden sources would not be available to resume them.
+			return BatchJob{}, errors.Errorf("earn %d: source overrides are not supported by jobs", i)
+		}
+		if err := validateQuarks(e.Quarks); err != nil {
+			return BatchJob{}, errors.Wrapf(err, "earn %d", i)
+		}
+	}
+
+	dedupeID := batch.DedupeID
+	if len(dedupeID) == 0 {
+		h := sha256.Sum256([]byte(id))
+		dedupeID = h[:]
+	}
+
+	now := r.clock.Now().UTC()
+	job := BatchJob{
+		ID:       id,
+		Sender:   batch.Sender.Public(),
+		Memo:     batch.Memo,
+		Mint:     batch.Mint,
+		DedupeID: dedupeID,
+		Earns:    append([]Earn(nil), batch.Earns...),
+		Created:  now,
+		Updated:  now,
+	}
+	for start := 0; start < len(batch.Earns); start += MaxBatchSize {
+		end := start + MaxBatchSize
+		if end > len(batch.Earns) {
+			end = len(batch.Earns)
+		}
+
+		job.Chunks = append(job.Chunks, BatchJobChunk{
+			Start:    start,
+			End:      end,
+			DedupeID: chunkDedupeID(dedupeID, len(job.Chunks)),
+		})
+	}
+
+	return job, nil
+}
+
+func (r *BatchJobRunner) run(ctx context.Context, job BatchJob, sender kin.PrivateKey, opts []SolanaOption) (BatchJob, error) {
+	for i := range job.Chunks {
+		chunk := &job.Chunks[i]
+		switch chunk.Status {
+		case BatchJobChunkSucceeded:
+			continue
+		case BatchJobChunkFailed:
+			// Agora returns the result of the previous transaction for a
+			// DedupeID, so a new one is required to retry.
+			chunk.Attempt++
+			chunk.DedupeID = chunkDedupeID(chunkDedupeID(job.DedupeID, i), chunk.Attempt)
+		}
+
+		// The chunk is recorded as submitting before it is submitted, so that
+		// if the process dies, it is resubmitted with the same DedupeID.
+		chunk.Status = BatchJobChunkSubmitting
+		chunk.Result = nil
+		chunk.Error = ""
+		if err := r.update(ctx, &job); err != nil {
+			return job, err
+		}
+
+		result, err := r.client.SubmitEarnBatch(ctx, EarnBatch{
+			Sender:   sender,
+			Memo:     job.Memo,
+			Mint:     job.Mint,
+			Earns:    job.Earns[chunk.Start:chunk.End:chunk.End],
+			DedupeID: chunk.DedupeID,
+		}, opts...)
+		for j := range result.EarnErrors {
+			result.EarnErrors[j].EarnIndex += chunk.Start
+		}
+
+		switch {
+		case err != nil:
+			// The outcome is unknown, so the chunk remains submitting.
+			chunk.Error = err.Error()
+		case result.TxError != nil:
+			chunk.Status = BatchJobChunkFailed
+			chunk.Result = &result
+		default:
+			chunk.Status = BatchJobChunkSucceeded
+			chunk.Result = &result
+		}
+		if updateErr := r.update(ctx, &job); updateErr != nil {
+			return job, updateErr
+		}
+
+		if err != nil {
+			return job, errors.Wrapf(err, "failed to submit chunk %d", i)
+		}
+		if result.TxError != nil {
+			return job, errors.Wrapf(result.TxError, "chunk %d failed", i)
+		}
+	}
+
+	return job, nil
+}
+
+func (r *BatchJobRunner) update(ctx context.Context, job *BatchJob) error {
+	job.Updated = r.clock.Now().UTC()
+	return errors.Wrap(r.store.Update(ctx, *job), "failed to update job")
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// earnBatchClient is a Client that records submitted earn batches, and fails
+// them as specified by submit.
+type earnBatchClient struct {
+	Client
+
+	batches []EarnBatch
+	submit  func(batch EarnBatch) (EarnBatchResult, error)
+}
+
+func (c *earnBatchClient) SubmitEarnBatch(_ context.Context, batch EarnBatch, _ ...SolanaOption) (EarnBatchResult, error) {
+	c.batches = append(c.batches, batch)
+	if c.submit != nil {
+		return c.submit(batch)
+	}
+	return EarnBatchResult{TxID: batch.DedupeID}, nil
+}
+
+func TestBatchJobRunner(t *testing.T) {
+	sender := goldenKey("sender")
+	batch := EarnBatch{Sender: sender, Memo: "payout"}
+	for i := 0; i < 2*MaxBatchSize+1; i++ {
+		batch.Earns = append(batch.Earns, Earn{Destination: goldenKey(string(rune('a' + i))).Public(), Quarks: int64(i + 1)})
+	}
+
+	c := &earnBatchClient{}
+	store := NewMemoryJobStore()
+	runner := NewBatchJobRunner(c, store)
+
+	// The second chunk fails.
+	c.submit = func(b EarnBatch) (EarnBatchResult, error) {
+		if len(c.batches) == 2 {
+			return EarnBatchResult{
+				TxID:       b.DedupeID,
+				TxError:    ErrInsufficientBalance,
+				EarnErrors: []EarnError{{EarnIndex: 1, Error: ErrInsufficientBalance}},
+			}, nil
+		}
+		return EarnBatchResult{TxID: b.DedupeID}, nil
+	}
+	job, err := runner.Start(context.Background(), "job", batch)
+	assert.Equal(t, ErrInsufficientBalance, errors.Cause(err))
+	require.Len(t, c.batches, 2)
+	require.Len(t, job.Chunks, 3)
+	assert.Equal(t, []int{1, 2}, job.Outstanding())
+	assert.False(t, job.Done())
+
+	assert.Equal(t, BatchJobChunkSucceeded, job.Chunks[0].Status)
+	assert.Equal(t, BatchJobChunkFailed, job.Chunks[1].Status)
+	assert.Equal(t, []EarnError{{EarnIndex: MaxBatchSize + 1, Error: ErrInsufficientBalance}}, job.Chunks[1].Result.EarnErrors)
+	assert.Equal(t, BatchJobChunkPending, job.Chunks[2].Status)
+	for i, chunk := range job.Chunks {
+		assert.Equal(t, chunkDedupeID(job.DedupeID, i), chunk.DedupeID)
+		assert.Equal(t, i*MaxBatchSize, chunk.Start)
+	}
+	assert.Equal(t, batch.Earns[:MaxBatchSize], c.batches[0].Earns)
+	assert.Equal(t, batch.Earns[MaxBatchSize:2*MaxBatchSize], c.batches[1].Earns)
+	assert.Equal(t, "payout", c.batches[1].Memo)
+
+	// The progress of the job is stored.
+	loaded, err := runner.Load(context.Background(), "job")
+	require.NoError(t, err)
+	assert.Equal(t, job, loaded)
+
+	// The process dies while the failed chunk is being retried, so the
+	// outcome of the retry is unknown.
+	failedID := job.Chunks[1].DedupeID
+	c.batches = nil
+	c.submit = func(EarnBatch) (EarnBatchResult, error) {
+		return EarnBatchResult{}, errors.New("unavailable")
+	}
+	job, err = runner.Resume(context.Background(), "job", sender)
+	assert.Error(t, err)
+	require.Len(t, c.batches, 1)
+	assert.Equal(t, BatchJobChunkSubmitting, job.Chunks[1].Status)
+	assert.Equal(t, 1, job.Chunks[1].Attempt)
+	assert.Equal(t, "unavailable", job.Chunks[1].Error)
+	retryID := job.Chunks[1].DedupeID
+	assert.NotEqual(t, failedID, retryID)
+
+	// Only the outstanding chunks are submitted on resumption, and the chunk
+	// whose outcome is unknown is resubmitted with the same DedupeID.
+	c.batches = nil
+	c.submit = nil
+	job, err = runner.Resume(context.Background(), "job", sender)
+	require.NoError(t, err)
+	assert.True(t, job.Done())
+	require.Len(t, c.batches, 2)
+	assert.Equal(t, retryID, c.batches[0].DedupeID)
+	assert.Equal(t, job.Chunks[2].DedupeID, c.batches[1].DedupeID)
+	assert.Equal(t, batch.Earns[2*MaxBatchSize:], c.batches[1].Earns)
+
+	c.batches = nil
+	_, err = runner.Resume(context.Background(), "job", sender)
+	require.NoError(t, err)
+	assert.Empty(t, c.batches)
+
+	_, err = runner.Resume(context.Background(), "job", goldenKey("other"))
+	assert.Error(t, err)
+	_, err = runner.Resume(context.Background(), "missing", sender)
+	assert.Equal(t, ErrJobNotFound, err)
+	_, err = runner.Start(context.Background(), "job", batch)
+	assert.Equal(t, ErrJobExists, err)
+}
+
+func TestBatchJobRunner_Invalid(t *testing.T) {
+	runner := NewBatchJobRunner(&earnBatchClient{}, NewMemoryJobStore())
+	sender := goldenKey("sender")
+	dest := goldenKey("destination").Public()
+
+	for _, tc := range []struct {
+		id    string
+		batch EarnBatch
+	}{
+		{"", EarnBatch{Sender: sender, Earns: []Earn{{Destination: dest, Quarks: 1}}}},
+		{"job", EarnBatch{Earns: []Earn{{Destination: dest, Quarks: 1}}}},
+		{"job", EarnBatch{Sender: sender}},
+		{"job", EarnBatch{Sender: sender, Earns: []Earn{{Quarks: 1}}}},
+		{"job", EarnBatch{Sender: sender, Earns: []Earn{{Destination: dest}}}},
+		{"job", EarnBatch{Sender: sender, Earns: []Earn{{Destination: dest, Quarks: 1, Source: dest}}}},
+	} {
+		_, err := runner.Start(context.Background(), tc.id, tc.batch)
+		assert.Error(t, err)
+	}
+
+	// Jobs without a DedupeID derive one from their ID.
+	job, err := runner.Start(context.Background(), "job", EarnBatch{Sender: sender, Earns: []Earn{{Destination: dest, Quarks: 1}}})
+	require.NoError(t, err)
+	assert.Len(t, job.DedupeID, 32)
+
+	job, err = runner.Start(context.Background(), "job2", EarnBatch{Sender: sender, Earns: []Earn{{Destination: dest, Quarks: 1}}, DedupeID: []byte("dedupe")})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("dedupe"), job.DedupeID)
+}
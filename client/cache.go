@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+const (
+	serviceConfigCacheTTL = 24 * time.Hour
+	blockhashCacheTTL     = 10 * time.Second
+	resolutionCacheTTL    = 15 * time.Minute
+)
+
+// Cache is a key value cache that can be shared by clients, such as one backed
+// by Redis or memcached.
+//
+// When configured with WithCache, the client stores the service config, recent
+// blockhashes, and token account resolutions in the cache, so that clients
+// across a fleet do not each need to fetch them from Agora.
+//
+// Errors returned by the cache are not fatal. Failed reads are treated as
+// misses, and failed writes are ignored.
+type Cache interface {
+	// Get returns the value of key, and whether or not it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set sets the value of key, which expires after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete deletes key, if it exists.
+	Delete(ctx context.Context, key string) error
+}
+
+// WithCache specifies a Cache that is shared with other clients.
+//
+// Keys are namespaced by environment, so a cache can be shared by clients of
+// different environments.
+//
+// If a submission fails with ErrAccountDoesNotExist after using cached token
+// account resolutions, the resolutions are purged, and the submission is
+// resolved and resubmitted once more, since the cached token accounts may have
+// been closed.
+func WithCache(cache Cache) ClientOption {
+	return func(o *clientOpts) {
+		o.cache = cache
+	}
+}
+
+type memoryCache struct {
+	clock Clock
+
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache returns an in-memory Cache, intended for tests and for sharing
+// a cache between clients in a single process.
+func NewMemoryCache() Cache {
+	return NewMemoryCacheWithClock(SystemClock())
+}
+
+// NewMemoryCacheWithClock returns an in-memory Cache whose entries expire
+// according to clock.
+func NewMemoryCacheWithClock(clock Clock) Cache {
+	return &memoryCache{
+		clock:   clock,
+		entries: make(map[string]memoryCacheEntry),
+	}
+}
+
+// Get implements Cache.Get.
+func (c *memoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !c.clock.Now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+
+	return entry.value, true, nil
+}
+
+// Set implements Cache.Set.
+func (c *memoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = memoryCacheEntry{
+		value:   append([]byte(nil), value...),
+		expires: now.Add(ttl),
+	}
+	return nil
+}
+
+// Delete implements Cache.Delete.
+func (c *memoryCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+	return nil
+}
+
+// sharedCache stores the values cached by an InternalClient in a Cache. The
+// zero value performs no caching.
+type sharedCache struct {
+	cache  Cache
+	prefix string
+}
+
+func newSharedCache(cache Cache, env Environment) sharedCache {
+	return sharedCache{
+		cache:  cache,
+		prefix: fmt.Sprintf("kin-go:%s:", env),
+	}
+}
+
+func (s sharedCache) get(ctx context.Context, key string) ([]byte, bool) {
+	if s.cache == nil {
+		return nil, false
+	}
+
+	value, ok, err := s.cache.Get(ctx, s.prefix+key)
+	if err != nil {
+		return nil, false
+	}
+	return value, ok
+}
+
+func (s sharedCache) set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	if s.cache == nil {
+		return
+	}
+
+	_ = s.cache.Set(ctx, s.prefix+key, value, ttl)
+}
+
+func (s sharedCache) delete(ctx context.Context, key string) {
+	if s.cache == nil {
+		return
+	}
+
+	_ = s.cache.Delete(ctx, s.prefix+key)
+}
+
+func serviceConfigKey(appIndex uint16) string {
+	return fmt.Sprintf("service-config:%d", appIndex)
+}
+
+func (s sharedCache) getServiceConfig(ctx context.Context, appIndex uint16) (*transactionpbv4.GetServiceConfigResponse, bool) {
+	value, ok := s.get(ctx, serviceConfigKey(appIndex))
+	if !ok {
+		return nil, false
+	}
+
+	config := &transactionpbv4.GetServiceConfigResponse{}
+	if err := proto.Unmarshal(value, config); err != nil {
+		return nil, false
+	}
+	return config, true
+}
+
+func (s sharedCache) setServiceConfig(ctx context.Context, appIndex uint16, config *transactionpbv4.GetServiceConfigResponse) {
+	if s.cache == nil {
+		return
+	}
+
+	value, err := proto.Marshal(config)
+	if err != nil {
+		return
+	}
+	s.set(ctx, serviceConfigKey(appIndex), value, serviceConfigCacheTTL)
+}
+
+const blockhashKey = "blockhash"
+
+func (s sharedCache) getBlockhash(ctx context.Context) (blockhash solana.Blockhash, ok bool) {
+	value, ok := s.get(ctx, blockhashKey)
+	if !ok || len(value) != len(blockhash) {
+		return blockhash, false
+	}
+
+	copy(blockhash[:], value)
+	return blockhash, true
+}
+
+func (s sharedCache) setBlockhash(ctx context.Context, blockhash solana.Blockhash) {
+	s.set(ctx, blockhashKey, blockhash[:], blockhashCacheTTL)
+}
+
+// expireBlockhash removes blockhash from the cache, if it is the cached
+// blockhash.
+func (s sharedCache) expireBlockhash(ctx context.Context, blockhash solana.Blockhash) {
+	if cached, ok := s.getBlockhash(ctx); ok && cached == blockhash {
+		s.delete(ctx, blockhashKey)
+	}
+}
+
+func resolutionKey(owner kin.PublicKey) string {
+	return "resolution:" + owner.Base58()
+}
+
+// mintResolutionKey is the key of the token accounts of owner for a mint other
+// than Kin, which are cached separately from the Kin token accounts of owner.
+func mintResolutionKey(owner, mint kin.PublicKey) string {
+	return "resolution:" + mint.Base58() + ":" + owner.Base58()
+}
+
+func (s sharedCache) getResolution(ctx context.Context, owner kin.PublicKey) ([]kin.PublicKey, bool) {
+	return s.getAccounts(ctx, resolutionKey(owner))
+}
+
+// setResolution caches the token accounts of owner. Empty resolutions are not
+// cached, since the owner may create an account at any time.
+func (s sharedCache) setResolution(ctx context.Context, owner kin.PublicKey, accounts []kin.PublicKey) {
+	s.setAccounts(ctx, resolutionKey(owner), accounts)
+}
+
+// invalidateResolution removes the cached token accounts of owner.
+func (s sharedCache) invalidateResolution(ctx context.Context, owner kin.PublicKey) {
+	s.delete(ctx, resolutionKey(owner))
+}
+
+func (s sharedCache) getMintResolution(ctx context.Context, owner, mint kin.PublicKey) ([]kin.PublicKey, bool) {
+	return s.getAccounts(ctx, mintResolutionKey(owner, mint))
+}
+
+func (s sharedCache) setMintResolution(ctx context.Context, owner, mint kin.PublicKey, accounts []kin.PublicKey) {
+	s.setAccounts(ctx, mintResolutionKey(owner, mint), accounts)
+}
+
+func (s sharedCache) getAccounts(ctx context.Context, key string) ([]kin.PublicKey, bool) {
+	value, ok := s.get(ctx, key)
+	if !ok || len(value) == 0 || len(value)%ed25519.PublicKeySize != 0 {
+		return nil, false
+	}
+
+	accounts := make([]kin.PublicKey, len(value)/ed25519.PublicKeySize)
+	for i := range accounts {
+		accounts[i] = kin.PublicKey(value[i*ed25519.PublicKeySize : (i+1)*ed25519.PublicKeySize])
+	}
+	return accounts, true
+}
+
+func (s sharedCache) setAccounts(ctx context.Context, key string, accounts []kin.PublicKey) {
+	if len(accounts) == 0 {
+		return
+	}
+
+	value := make([]byte, 0, len(accounts)*ed25519.PublicKeySize)
+	for _, a := range accounts {
+		if len(a) != ed25519.PublicKeySize {
+			return
+		}
+		value = append(value, a...)
+	}
+	s.set(ctx, key, value, resolutionCacheTTL)
+}
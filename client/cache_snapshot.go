@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/pkg/errors"
+)
+
+// WarmCaches fetches the values that submissions depend on, so that they are
+// cached before the first submission: the service config, the rent exemption of
+// token accounts, and the token accounts of each of the specified accounts.
+//
+// Resolutions are only cached if a Cache is specified with WithCache.
+func (c *client) WarmCaches(ctx context.Context, accounts []kin.PublicKey) error {
+	if _, err := c.internal.GetServiceConfig(ctx); err != nil {
+		return errors.Wrap(err, "failed to get service config")
+	}
+	if _, err := c.internal.GetMinimumBalanceForRentException(ctx, token.AccountSize); err != nil {
+		return errors.Wrap(err, "failed to get rent exemption")
+	}
+	if _, _, err := c.resolveConcurrently(ctx, accounts); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CacheSnapshotter is implemented by Caches whose entries can be exported and
+// imported, such as the Cache returned by NewMemoryCache.
+type CacheSnapshotter interface {
+	// Snapshot returns the entries of the cache that have not expired.
+	Snapshot(ctx context.Context) ([]CacheEntry, error)
+
+	// Restore sets each of the entries that have not expired.
+	Restore(ctx context.Context, entries []CacheEntry) error
+}
+
+// CacheEntry is an entry of a Cache.
+type CacheEntry struct {
+	Key     string    `json:"key"`
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+type cacheSnapshot struct {
+	Entries []CacheEntry `json:"entries"`
+}
+
+// SaveCacheSnapshot writes the entries of cache to w as JSON, so that they can be
+// loaded into the cache of another process with LoadCacheSnapshot. For example,
+// a snapshot of the cache of a running instance can be loaded by autoscaled
+// instances, so that they do not each need to resolve the same accounts.
+//
+// The cache must implement CacheSnapshotter.
+func SaveCacheSnapshot(ctx context.Context, cache Cache, w io.Writer) error {
+	snapshotter, ok := cache.(CacheSnapshotter)
+	if !ok {
+		return errors.New("cache does not support snapshots")
+	}
+
+	entries, err := snapshotter.Snapshot(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to snapshot cache")
+	}
+	if err := json.NewEncoder(w).Encode(&cacheSnapshot{Entries: entries}); err != nil {
+		return errors.Wrap(err, "failed to write snapshot")
+	}
+	return nil
+}
+
+// LoadCacheSnapshot loads a snapshot written by SaveCacheSnapshot from r into
+// cache. Entries that have expired since the snapshot was taken are skipped.
+//
+// The cache must implement CacheSnapshotter.
+func LoadCacheSnapshot(ctx context.Context, cache Cache, r io.Reader) error {
+	snapshotter, ok := cache.(CacheSnapshotter)
+	if !ok {
+		return errors.New("cache does not support snapshots")
+	}
+
+	var snapshot cacheSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return errors.Wrap(err, "failed to read snapshot")
+	}
+	if err := snapshotter.Restore(ctx, snapshot.Entries); err != nil {
+		return errors.Wrap(err, "failed to restore cache")
+	}
+	return nil
+}
+
+// Snapshot implements CacheSnapshotter.Snapshot.
+func (c *memoryCache) Snapshot(_ context.Context) ([]CacheEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	entries := make([]CacheEntry, 0, len(c.entries))
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Key:     key,
+			Value:   append([]byte(nil), entry.value...),
+			Expires: entry.expires,
+		})
+	}
+	return entries, nil
+}
+
+// Restore implements CacheSnapshotter.Restore.
+func (c *memoryCache) Restore(_ context.Context, entries []CacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	for _, entry := range entries {
+		if !now.Before(entry.Expires) {
+			continue
+		}
+		c.entries[entry.Key] = memoryCacheEntry{
+			value:   append([]byte(nil), entry.Value...),
+			expires: entry.Expires,
+		}
+	}
+	return nil
+}
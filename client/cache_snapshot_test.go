@@ -0,0 +1,94 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+)
+
+func TestClient_WarmCaches(t *testing.T) {
+	cache := NewMemoryCache()
+	env, cleanup := setup(t, WithCache(cache))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	owner, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	tokenAccount, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.TokenAccounts[owner.Public().Base58()] = []*commonpbv4.SolanaAccountId{{Value: tokenAccount.Public()}}
+	env.v4Server.Mux.Unlock()
+
+	require.NoError(t, env.client.WarmCaches(context.Background(), []kin.PublicKey{owner.Public()}))
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.ServiceConfigReqs, 1)
+	assert.Len(t, env.v4Server.RentExemptionReqs, 1)
+	env.v4Server.Mux.Unlock()
+
+	cached, ok := env.client.internal.cache.getResolution(context.Background(), owner.Public())
+	require.True(t, ok)
+	assert.Equal(t, []kin.PublicKey{tokenAccount.Public()}, cached)
+
+	// A snapshot of the cache can be loaded into the cache of another client,
+	// which then does not need to resolve the account.
+	var snapshot bytes.Buffer
+	require.NoError(t, SaveCacheSnapshot(context.Background(), cache, &snapshot))
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.TokenAccounts[owner.Public().Base58()] = nil
+	env.v4Server.Mux.Unlock()
+
+	restored := NewMemoryCache()
+	require.NoError(t, LoadCacheSnapshot(context.Background(), restored, &snapshot))
+
+	c, err := New(EnvironmentTest, WithGRPC(env.conn), WithAppIndex(1), WithCache(restored))
+	require.NoError(t, err)
+	accounts, err := c.ResolveTokenAccounts(context.Background(), owner.Public())
+	require.NoError(t, err)
+	assert.Equal(t, []kin.PublicKey{tokenAccount.Public()}, accounts)
+}
+
+func TestCacheSnapshot(t *testing.T) {
+	ctx := context.Background()
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	cache := NewMemoryCacheWithClock(clock)
+
+	require.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Hour))
+	require.NoError(t, cache.Set(ctx, "b", []byte("2"), time.Minute))
+	require.NoError(t, cache.Set(ctx, "c", []byte("3"), time.Second))
+	clock.advance(time.Second)
+
+	var snapshot bytes.Buffer
+	require.NoError(t, SaveCacheSnapshot(ctx, cache, &snapshot))
+
+	// Entries that expire before the snapshot is loaded are skipped.
+	clock.advance(time.Minute)
+	restored := NewMemoryCacheWithClock(clock)
+	require.NoError(t, LoadCacheSnapshot(ctx, restored, bytes.NewReader(snapshot.Bytes())))
+
+	entries, err := restored.(CacheSnapshotter).Snapshot(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "a", entries[0].Key)
+	assert.Equal(t, []byte("1"), entries[0].Value)
+	assert.True(t, time.Unix(1000, 0).Add(time.Hour).Equal(entries[0].Expires))
+
+	value, ok, err := restored.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	assert.Error(t, SaveCacheSnapshot(ctx, nil, &snapshot))
+	assert.Error(t, LoadCacheSnapshot(ctx, restored, bytes.NewBufferString("{")))
+}
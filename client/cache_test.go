@@ -0,0 +1,247 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+
+	"github.com/kinecosystem/kin-go/client/testutil"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache()
+
+	_, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Hour))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Millisecond))
+
+	v, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), v)
+
+	time.Sleep(2 * time.Millisecond)
+	_, ok, err = c.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Delete(ctx, "a"))
+	_, ok, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestClient_Cache(t *testing.T) {
+	cache := NewMemoryCache()
+
+	env, cleanup := setup(t, WithCache(cache))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	owner, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	tokenAccount, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.TokenAccounts[owner.Public().Base58()] = []*commonpbv4.SolanaAccountId{{Value: tokenAccount.Public()}}
+	env.v4Server.Mux.Unlock()
+
+	config, err := env.internal.GetServiceConfig(context.Background())
+	require.NoError(t, err)
+	accounts, err := env.client.ResolveTokenAccounts(context.Background(), owner.Public())
+	require.NoError(t, err)
+	assert.Equal(t, []kin.PublicKey{tokenAccount.Public()}, accounts)
+	blockhash, err := env.internal.GetRecentBlockhash(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, RecentBlockhash, blockhash[:])
+
+	// A second client sharing the cache does not need to fetch the values
+	// from Agora, even if they change.
+	env.v4Server.Mux.Lock()
+	env.v4Server.TokenAccounts[owner.Public().Base58()] = nil
+	env.v4Server.Mux.Unlock()
+
+	c, err := New(EnvironmentTest, WithGRPC(env.conn), WithAppIndex(1), WithCache(cache))
+	require.NoError(t, err)
+	other := c.(*client)
+
+	cached, err := other.internal.GetServiceConfig(context.Background())
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(config, cached))
+
+	accounts, err = other.ResolveTokenAccounts(context.Background(), owner.Public())
+	require.NoError(t, err)
+	assert.Equal(t, []kin.PublicKey{tokenAccount.Public()}, accounts)
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.ServiceConfigReqs, 1)
+	env.v4Server.Mux.Unlock()
+
+	// The service config is cached per app index.
+	c, err = New(EnvironmentTest, WithGRPC(env.conn), WithAppIndex(2), WithCache(cache))
+	require.NoError(t, err)
+	_, err = c.(*client).internal.GetServiceConfig(context.Background())
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.ServiceConfigReqs, 2)
+	env.v4Server.Mux.Unlock()
+
+	// Expired blockhashes are removed from the cache, but only if they are
+	// still the cached blockhash.
+	var newer solana.Blockhash
+	copy(newer[:], bytes.Repeat([]byte{2}, 32))
+	other.internal.cache.setBlockhash(context.Background(), newer)
+
+	other.internal.cache.expireBlockhash(context.Background(), blockhash)
+	cachedBlockhash, err := other.internal.GetRecentBlockhash(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, newer, cachedBlockhash)
+
+	other.internal.cache.expireBlockhash(context.Background(), newer)
+	cachedBlockhash, err = other.internal.GetRecentBlockhash(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, RecentBlockhash, cachedBlockhash[:])
+}
+
+func TestClient_StaleResolution(t *testing.T) {
+	env, cleanup := setup(t, WithCache(NewMemoryCache()))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	accounts := testutil.GenerateSolanaKeys(t, 3)
+	stale, fresh, destAccount := accounts[0], accounts[1], accounts[2]
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.TokenAccounts[sender.Public().Base58()] = []*commonpbv4.SolanaAccountId{{Value: stale}}
+	env.v4Server.TokenAccounts[dest.Public().Base58()] = []*commonpbv4.SolanaAccountId{{Value: destAccount}}
+	env.v4Server.Mux.Unlock()
+
+	resolved, err := env.client.ResolveTokenAccounts(context.Background(), sender.Public())
+	require.NoError(t, err)
+	assert.Equal(t, []kin.PublicKey{kin.PublicKey(stale)}, resolved)
+
+	// The stale token account is closed, and replaced with a new one.
+	invalidAccount := func() *transactionpbv4.SubmitTransactionResponse {
+		return &transactionpbv4.SubmitTransactionResponse{
+			Result: transactionpbv4.SubmitTransactionResponse_FAILED,
+			TransactionError: &commonpbv4.TransactionError{
+				Reason: commonpbv4.TransactionError_INVALID_ACCOUNT,
+				Raw:    []byte{0},
+			},
+		}
+	}
+	reset := func() {
+		env.v4Server.Mux.Lock()
+		env.v4Server.TokenAccounts[sender.Public().Base58()] = []*commonpbv4.SolanaAccountId{{Value: fresh}}
+		env.v4Server.Submits = nil
+		env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{invalidAccount(), invalidAccount()}
+		env.v4Server.Mux.Unlock()
+
+		env.client.internal.cache.setResolution(context.Background(), sender.Public(), []kin.PublicKey{kin.PublicKey(stale)})
+	}
+	transferSource := func(i int) kin.PublicKey {
+		env.v4Server.Mux.Lock()
+		defer env.v4Server.Mux.Unlock()
+
+		var tx solana.Transaction
+		require.NoError(t, tx.Unmarshal(env.v4Server.Submits[i].Transaction.Value))
+		transfer, err := token.DecompileTransfer(tx.Message, 1)
+		require.NoError(t, err)
+		return kin.PublicKey(transfer.Source)
+	}
+
+	reset()
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      10,
+	})
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 3)
+	env.v4Server.Mux.Unlock()
+	assert.EqualValues(t, stale, transferSource(1))
+	assert.EqualValues(t, fresh, transferSource(2))
+
+	cached, ok := env.client.internal.cache.getResolution(context.Background(), sender.Public())
+	require.True(t, ok)
+	assert.Equal(t, []kin.PublicKey{kin.PublicKey(fresh)}, cached)
+
+	reset()
+	result, err := env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns:  []Earn{{Destination: dest.Public(), Quarks: 10}},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result.TxError)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 3)
+	env.v4Server.Mux.Unlock()
+	assert.EqualValues(t, stale, transferSource(1))
+	assert.EqualValues(t, fresh, transferSource(2))
+
+	// Stale pre-resolutions are purged in the same way, without the initial
+	// unresolved submission.
+	reset()
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = env.v4Server.SubmitResponses[1:]
+	env.v4Server.Mux.Unlock()
+
+	result, err = env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns:  []Earn{{Destination: dest.Public(), Quarks: 10}},
+	}, WithPreResolution())
+	require.NoError(t, err)
+	assert.Nil(t, result.TxError)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 2)
+	env.v4Server.Mux.Unlock()
+	assert.EqualValues(t, stale, transferSource(0))
+	assert.EqualValues(t, fresh, transferSource(1))
+
+	// Resolutions that were not cached are not retried.
+	reset()
+	env.client.internal.invalidateResolutions(context.Background(), sender.Public(), dest.Public())
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = append(env.v4Server.SubmitResponses, invalidAccount())
+	env.v4Server.Mux.Unlock()
+
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      10,
+	})
+	assert.Equal(t, ErrAccountDoesNotExist, err)
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.Submits, 2)
+	env.v4Server.Mux.Unlock()
+}
@@ -0,0 +1,270 @@
+// Package claim generates claim codes, such as for marketing airdrops, which
+// can be redeemed for Kin by anyone who holds them.
+//
+// Each claim is backed by an ephemeral account, funded when the claim is
+// generated, whose private key is encoded into the claim code. Redeeming a
+// code sweeps the funds of its account to the redeemer's destination, and
+// closes the account. Since a code is the key of its account, codes must be
+// distributed as securely as the funds they hold.
+package claim
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+var (
+	// ErrInvalidCode indicates that a claim code is malformed.
+	ErrInvalidCode = errors.New("invalid claim code")
+
+	// ErrClaimNotFound indicates that the account of a claim code does not
+	// exist, or holds no funds, typically because the code was redeemed.
+	ErrClaimNotFound = errors.New("claim not found")
+)
+
+// Claim is a generated claim.
+type Claim struct {
+	// Code is the redeemable claim code.
+	Code string
+
+	// Address is the address of the account backing the claim.
+	Address kin.PublicKey
+
+	// Quarks is the amount the claim was funded with.
+	Quarks int64
+
+	// Unconfirmed indicates that the funding of the claim failed with an
+	// error that does not determine whether the funds were paid, such as a
+	// timeout. The claim's account may or may not hold Quarks, so its
+	// balance should be checked before the code is distributed. Its code is
+	// returned so that any funds it holds can still be recovered by Redeem.
+	Unconfirmed bool
+}
+
+// EncodeCode returns the claim code of key, which is the base58 encoding of
+// its seed.
+func EncodeCode(key kin.PrivateKey) string {
+	return base58.Encode(ed25519.PrivateKey(key).Seed())
+}
+
+// DecodeCode returns the key encoded by a claim code.
+func DecodeCode(code string) (kin.PrivateKey, error) {
+	seed, err := base58.Decode(code)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, ErrInvalidCode
+	}
+	return kin.PrivateKey(ed25519.NewKeyFromSeed(seed)), nil
+}
+
+type opts struct {
+	solanaOpts []client.SolanaOption
+}
+
+// Option configures a Manager.
+type Option func(*opts)
+
+// WithSolanaOptions specifies options used for every call the manager makes
+// to the client, such as client.WithCommitment.
+func WithSolanaOptions(solanaOpts ...client.SolanaOption) Option {
+	return func(o *opts) {
+		o.solanaOpts = append(o.solanaOpts, solanaOpts...)
+	}
+}
+
+// Manager generates and redeems claims. It is safe for concurrent use.
+type Manager struct {
+	client client.Client
+	opts   opts
+}
+
+// New returns a new Manager.
+func New(c client.Client, options ...Option) *Manager {
+	m := &Manager{client: c}
+	for _, o := range options {
+		o(&m.opts)
+	}
+	return m
+}
+
+// GenerateClaims generates n claims of quarks each, funded by funder.
+//
+// The accounts of the claims are created in as few transactions as possible,
+// and funded by earn batches, each with a DedupeID derived from its claims. If
+// any account could not be created or funded, the claims that were funded are
+// returned alongside the error, so that they can still be distributed. Claims
+// whose funding failed ambiguously are returned as Unconfirmed.
+func (m *Manager) GenerateClaims(ctx context.Context, funder kin.PrivateKey, n int, quarks int64) ([]Claim, error) {
+	if n <= 0 {
+		return nil, errors.New("number of claims must be positive")
+	}
+	if quarks <= 0 || quarks > client.MaxSupplyQuarks {
+		return nil, errors.Wrapf(client.ErrInvalidAmount, "invalid claim amount %d", quarks)
+	}
+
+	keys := make([]kin.PrivateKey, n)
+	for i := range keys {
+		key, err := kin.NewPrivateKey()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to generate key")
+		}
+		keys[i] = key
+	}
+
+	results, createErr := m.client.CreateAccounts(ctx, keys, m.opts.solanaOpts...)
+	created := make(map[string]struct{}, n)
+	for _, r := range results {
+		if r.TxError != nil {
+			if createErr == nil {
+				createErr = r.TxError
+			}
+			continue
+		}
+		for _, owner := range r.Owners {
+			created[string(owner)] = struct{}{}
+		}
+	}
+
+	var pending []kin.PrivateKey
+	for _, key := range keys {
+		if _, ok := created[string(key.Public())]; ok {
+			pending = append(pending, key)
+		}
+	}
+
+	var claims []Claim
+	var fundErr error
+	for start := 0; start < len(pending); start += client.MaxBatchSize {
+		end := start + client.MaxBatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+
+		batch := client.EarnBatch{Sender: funder}
+		dedupe := sha256.New()
+		for _, key := range pending[start:end] {
+			batch.Earns = append(batch.Earns, client.Earn{Destination: key.Public(), Quarks: quarks})
+			dedupe.Write(key.Public())
+		}
+		batch.DedupeID = dedupe.Sum([]byte("kin-go/claim:"))
+
+		result, err := m.client.SubmitEarnBatch(ctx, batch, m.opts.solanaOpts...)
+		if errors.Is(err, client.ErrAlreadySubmitted) {
+			err = nil
+		}
+		if err == nil {
+			err = result.TxError
+		}
+
+		unconfirmed := false
+		if err != nil {
+			fundErr = err
+			if !unpaid(err, result) {
+				unconfirmed = true
+			}
+		}
+		if err == nil || unconfirmed {
+			for _, key := range pending[start:end] {
+				claims = append(claims, Claim{
+					Code:        EncodeCode(key),
+					Address:     key.Public(),
+					Quarks:      quarks,
+					Unconfirmed: unconfirmed,
+				})
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	switch {
+	case fundErr != nil:
+		return claims, errors.Wrap(fundErr, "failed to fund claims")
+	case createErr != nil:
+		return claims, errors.Wrap(createErr, "failed to create claim accounts")
+	}
+	return claims, nil
+}
+
+// unpaid returns whether the failure of an earn batch with err and result
+// guarantees that nothing was paid.
+func unpaid(err error, result client.EarnBatchResult) bool {
+	if err == result.TxError {
+		// The transaction was submitted, and failed.
+		return true
+	}
+
+	var validationErr *client.BatchValidationError
+	if errors.As(err, &validationErr) {
+		return true
+	}
+	for _, target := range []error{
+		client.ErrInvalidAmount,
+		client.ErrTransactionTooLarge,
+		client.ErrTransactionRejected,
+		client.ErrNoSubsidizer,
+		client.ErrLimitExceeded,
+		client.ErrPaymentScreened,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redeem sweeps the funds of the claim of code to destination, and closes the
+// claim's account, returning the IDs of the transactions (see
+// client.Client.SweepAccount).
+//
+// ErrInvalidCode is returned if the code is malformed, and ErrClaimNotFound if
+// the claim does not exist or has already been redeemed.
+func (m *Manager) Redeem(ctx context.Context, code string, destination kin.PublicKey) ([][]byte, error) {
+	key, err := DecodeCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := m.client.GetBalance(ctx, key.Public(), m.opts.solanaOpts...)
+	if err == client.ErrAccountDoesNotExist {
+		return nil, ErrClaimNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get claim balance")
+	}
+	if balance == 0 {
+		return nil, ErrClaimNotFound
+	}
+
+	txIDs, err := m.client.SweepAccount(ctx, key, destination, m.opts.solanaOpts...)
+	if err == client.ErrAccountDoesNotExist {
+		return nil, ErrClaimNotFound
+	}
+	return txIDs, err
+}
+
+// WriteCSV writes claims to w as CSV, with a header row, for export to the
+// systems that distribute the codes. Unconfirmed claims are marked as such, and
+// should not be distributed until their balance is checked.
+func WriteCSV(w io.Writer, claims []Claim) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"code", "address", "quarks", "unconfirmed"}); err != nil {
+		return err
+	}
+	for _, c := range claims {
+		if err := cw.Write([]string{c.Code, c.Address.Base58(), strconv.FormatInt(c.Quarks, 10), strconv.FormatBool(c.Unconfirmed)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
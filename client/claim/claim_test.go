@@ -0,0 +1,132 @@
+package claim
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+	"github.com/kinecosystem/kin-go/client/clientfake"
+)
+
+func TestCode(t *testing.T) {
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	decoded, err := DecodeCode(EncodeCode(key))
+	require.NoError(t, err)
+	assert.Equal(t, key, decoded)
+
+	for _, code := range []string{"", "0OIl", "abc"} {
+		_, err = DecodeCode(code)
+		assert.Equal(t, ErrInvalidCode, err)
+	}
+}
+
+func TestManager(t *testing.T) {
+	fake := clientfake.New()
+	m := New(fake)
+
+	funder, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	fake.SetBalance(funder.Public(), 10000)
+
+	_, err = m.GenerateClaims(context.Background(), funder, 0, 100)
+	assert.Error(t, err)
+	_, err = m.GenerateClaims(context.Background(), funder, 1, 0)
+	assert.Error(t, err)
+
+	n := client.MaxBatchSize + 2
+	claims, err := m.GenerateClaims(context.Background(), funder, n, 100)
+	require.NoError(t, err)
+	require.Len(t, claims, n)
+
+	balance, err := fake.GetBalance(context.Background(), funder.Public())
+	require.NoError(t, err)
+	assert.EqualValues(t, 10000-100*n, balance)
+
+	seen := make(map[string]struct{})
+	for _, c := range claims {
+		seen[c.Code] = struct{}{}
+		balance, err := fake.GetBalance(context.Background(), c.Address)
+		require.NoError(t, err)
+		assert.EqualValues(t, 100, balance)
+	}
+	assert.Len(t, seen, n)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, claims[:2]))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"code", "address", "quarks", "unconfirmed"},
+		{claims[0].Code, claims[0].Address.Base58(), "100", "false"},
+		{claims[1].Code, claims[1].Address.Base58(), "100", "false"},
+	}, rows)
+
+	// Redeeming sweeps the claim into the destination, and closes it.
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	fake.SetBalance(dest.Public(), 0)
+
+	txIDs, err := m.Redeem(context.Background(), claims[0].Code, dest.Public())
+	require.NoError(t, err)
+	assert.Len(t, txIDs, 1)
+
+	balance, err = fake.GetBalance(context.Background(), dest.Public())
+	require.NoError(t, err)
+	assert.EqualValues(t, 100, balance)
+	_, err = fake.GetBalance(context.Background(), claims[0].Address)
+	assert.Equal(t, client.ErrAccountDoesNotExist, err)
+
+	_, err = m.Redeem(context.Background(), claims[0].Code, dest.Public())
+	assert.Equal(t, ErrClaimNotFound, err)
+	_, err = m.Redeem(context.Background(), "invalid", dest.Public())
+	assert.Equal(t, ErrInvalidCode, err)
+}
+
+func TestManager_PartialFunding(t *testing.T) {
+	fake := clientfake.New()
+	m := New(fake)
+
+	funder, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	// The funder can only afford the first batch.
+	fake.SetBalance(funder.Public(), int64(100*client.MaxBatchSize))
+
+	claims, err := m.GenerateClaims(context.Background(), funder, client.MaxBatchSize+1, 100)
+	assert.Error(t, err)
+	assert.Len(t, claims, client.MaxBatchSize)
+}
+
+func TestManager_AmbiguousFunding(t *testing.T) {
+	fake := clientfake.New()
+	m := New(fake)
+
+	funder, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	fake.SetBalance(funder.Public(), 10000)
+
+	// The first batch is funded, but the second times out, so it may or may
+	// not have been paid.
+	fake.QueueError("SubmitEarnBatch", nil, context.DeadlineExceeded)
+
+	claims, err := m.GenerateClaims(context.Background(), funder, client.MaxBatchSize+2, 10)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Len(t, claims, client.MaxBatchSize+2)
+	for i, c := range claims {
+		assert.Equal(t, i >= client.MaxBatchSize, c.Unconfirmed)
+	}
+
+	// The keys of unconfirmed claims can still be used to recover any funds.
+	key, err := DecodeCode(claims[len(claims)-1].Code)
+	require.NoError(t, err)
+	assert.Equal(t, claims[len(claims)-1].Address, key.Public())
+}
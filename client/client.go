@@ -6,24 +6,28 @@ import (
 	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/base64"
+	"fmt"
+	"sync"
 	"time"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/kin/version"
 	"github.com/kinecosystem/agora-common/retry"
-	"github.com/kinecosystem/agora-common/retry/backoff"
 	"github.com/kinecosystem/agora-common/solana"
 	"github.com/kinecosystem/agora-common/solana/memo"
 	"github.com/kinecosystem/agora-common/solana/system"
 	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/mr-tron/base58"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 
 	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
 	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
 	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+
+	"github.com/kinecosystem/kin-go/client/lookuptable"
+	"github.com/kinecosystem/kin-go/client/versioned"
 )
 
 // Environment specifies the desired Kin environment to use.
@@ -34,20 +38,64 @@ const (
 	EnvironmentProd Environment = "prod"
 
 	MaxBatchSize = 15
+
+	// MaxLookupTableBatchSize is the maximum number of earns in a batch whose
+	// destinations are all contained in the address lookup tables specified
+	// with WithAddressLookupTables.
+	MaxLookupTableBatchSize = 40
+
+	// MaxSupplyQuarks is the total supply of Kin (10 trillion), in quarks. No
+	// payment, or batch of earns, may exceed it.
+	MaxSupplyQuarks int64 = 10000000000000 * 100000
 )
 
 type Client interface {
 	// CreateAccount creates a kin account.
 	CreateAccount(ctx context.Context, key kin.PrivateKey, opts ...SolanaOption) (err error)
 
+	// CreateAccounts creates a kin account for each of the keys, packing as many
+	// account creations into each transaction as the transaction size limit allows.
+	//
+	// A result is returned for each submitted transaction. If a transaction could
+	// not be submitted, the results of the previously submitted transactions are
+	// returned alongside the error.
+	CreateAccounts(ctx context.Context, keys []kin.PrivateKey, opts ...SolanaOption) (results []CreateAccountsResult, err error)
+
 	// GetBalance returns the balance of a kin account in quarks.
 	//
 	// ErrAccountDoesNotExist is returned if no account exists.
 	GetBalance(ctx context.Context, account kin.PublicKey, opts ...SolanaOption) (quarks int64, err error)
 
+	// GetBalanceDetail returns the balance of each of the token accounts owned by an
+	// account on Kin 4, along with their owner and close authority.
+	//
+	// ErrAccountDoesNotExist is returned if no account exists.
+	GetBalanceDetail(ctx context.Context, account kin.PublicKey, opts ...SolanaOption) (detail BalanceDetail, err error)
+
 	// ResolveTokenAccounts resolves the token accounts owned by an account on Kin 4.
 	ResolveTokenAccounts(ctx context.Context, account kin.PublicKey) ([]kin.PublicKey, error)
 
+	// ResolveTokenAccountsBatch concurrently resolves the token accounts owned by each of
+	// the specified accounts on Kin 4, returning a map keyed by the base58 encoded account.
+	// Accounts without token accounts map to an empty list.
+	//
+	// Resolutions are stored in the cache specified by WithCache, so it can be used to
+	// warm the cache before submitting many payments. The number of concurrent requests
+	// is bounded by WithMaxConcurrency.
+	ResolveTokenAccountsBatch(ctx context.Context, accounts []kin.PublicKey) (map[string][]kin.PublicKey, error)
+
+	// WarmCaches fetches the service config, the rent exemption of token accounts, and
+	// the token accounts of each of the specified accounts, so that they are cached
+	// before the first submission. Resolutions are only cached if a Cache is specified
+	// with WithCache (see also SaveCacheSnapshot).
+	WarmCaches(ctx context.Context, accounts []kin.PublicKey) error
+
+	// ResolveOwner resolves the owner and close authority of a token account on Kin 4.
+	//
+	// ErrAccountDoesNotExist is returned if the token account does not exist. The close
+	// authority may be nil if the account does not have one.
+	ResolveOwner(ctx context.Context, tokenAccount kin.PublicKey, opts ...SolanaOption) (owner, closeAuthority kin.PublicKey, err error)
+
 	// MergeTokenAccounts merges the balances of all the token accounts owned by the
 	// specified account into a single token account (the first one returned by ResolveTokenAccounts).
 	//
@@ -55,12 +103,55 @@ type Client interface {
 	// (if it does not exist), and the funds will be sent to the associated account.
 	MergeTokenAccounts(ctx context.Context, account kin.PrivateKey, createAssociatedAccount bool, opts ...SolanaOption) (txID []byte, err error)
 
+	// SweepAccount transfers the balances of all the token accounts owned by the
+	// specified account to destination, closing the token accounts where possible,
+	// and returns the IDs of the transactions. The transfers are screened and
+	// subject to limits, as payments are.
+	//
+	// ErrAccountDoesNotExist is returned if the account has no token accounts.
+	SweepAccount(ctx context.Context, account kin.PrivateKey, destination kin.PublicKey, opts ...SolanaOption) (txIDs [][]byte, err error)
+
 	// GetTransaction returns the TransactionData for a given transaction hash.
 	//
 	// ErrTransactionNotFound is returned if no transaction exists for the hash.
 	GetTransaction(ctx context.Context, txHash []byte, opts ...SolanaOption) (data TransactionData, err error)
 
+	// GetTransactionStatus returns the status of a transaction, without fetching
+	// and parsing its payments. It is intended for confirmation polling.
+	GetTransactionStatus(ctx context.Context, txHash []byte, opts ...SolanaOption) (status TransactionStatus, err error)
+
+	// GetReceipt returns a Receipt for a successful transaction, which can be signed
+	// and provided to customers or auditors.
+	GetReceipt(ctx context.Context, txHash []byte, opts ...SolanaOption) (receipt Receipt, err error)
+
+	// GetTransactions concurrently fetches the TransactionData for each of the specified
+	// transaction hashes, returning a map keyed by the base58 encoded hash.
+	//
+	// The number of concurrent requests is bounded by WithMaxConcurrency.
+	GetTransactions(ctx context.Context, txHashes [][]byte, opts ...SolanaOption) (data map[string]TransactionData, err error)
+
+	// GetHistory returns a page of the transaction history of an account, starting after
+	// the specified cursor, or from the start of the history if cursor is nil. Items are
+	// ordered oldest first, unless WithDescending is specified.
+	//
+	// If WithPaymentFilters is specified, pages are read until one contains a matching
+	// transaction, so an empty page still indicates the end of the history.
+	//
+	// ErrAccountDoesNotExist is returned if the account does not exist.
+	GetHistory(ctx context.Context, account kin.PublicKey, cursor Cursor, opts ...SolanaOption) (items []HistoryItem, err error)
+
+	// Subscribe returns a stream of the transactions of an account, oldest first. The
+	// history after sinceCursor (or the entire history if sinceCursor is nil) is replayed,
+	// after which transactions are streamed as they occur, without gaps or duplicates.
+	//
+	// The stream is closed when ctx is cancelled, or after a result with Err set. If
+	// WithPaymentFilters is specified, transactions without matching payments are omitted.
+	Subscribe(ctx context.Context, account kin.PublicKey, sinceCursor Cursor, opts ...SolanaOption) (<-chan SubscriptionResult, error)
+
 	// SubmitPayment submits a single payment to a specified kin account.
+	//
+	// If the payment has a DestinationHandle, it is resolved with the Resolver
+	// specified by WithResolver before the payment is submitted.
 	SubmitPayment(ctx context.Context, payment Payment, opts ...SolanaOption) (txHash []byte, err error)
 
 	// SubmitEarnBatch submits a batch of earn payments.
@@ -68,15 +159,136 @@ type Client interface {
 	// The batch may be done in on or more transactions.
 	SubmitEarnBatch(ctx context.Context, batch EarnBatch, opts ...SolanaOption) (result EarnBatchResult, err error)
 
+	// SubmitEarnBatches submits a batch of earn payments of any size, splitting it into
+	// chunks of at most MaxBatchSize earns that are each submitted in their own transaction.
+	//
+	// Chunks are submitted concurrently (see WithChunkParallelism). The results are ordered
+	// by chunk, and the EarnIndex of each EarnError refers to the earn's position in the
+	// original batch. If a chunk fails, no further chunks are started, and their results
+	// have TxError set to ErrEarnBatchAborted.
+	SubmitEarnBatches(ctx context.Context, batch EarnBatch, opts ...SolanaOption) (results []EarnBatchResult, err error)
+
+	// SubmitEarnBatchAsync is like SubmitEarnBatches, but returns a channel that receives
+	// the result of each chunk as it completes, allowing processing to begin before the
+	// whole batch has finished. The channel is closed once every chunk has a result.
+	//
+	// An error is returned if the batch is invalid, in which case nothing is submitted.
+	SubmitEarnBatchAsync(ctx context.Context, batch EarnBatch, opts ...SolanaOption) (results <-chan EarnChunkResult, err error)
+
+	// SubmitSplitPayment submits a payment of total quarks from sender, divided
+	// across the destinations of splits by fixed amounts or basis points, in a
+	// single transaction. See SplitAmounts for how the total is divided.
+	SubmitSplitPayment(ctx context.Context, sender kin.PrivateKey, total int64, splits []Split, opts ...SolanaOption) (result EarnBatchResult, err error)
+
+	// FulfillPaymentRequest pays a payment request from sender, once the payer
+	// has accepted it. ErrPaymentRequestExpired or ErrInvalidSignature is
+	// returned if the request has expired, or is not signed by its requester.
+	FulfillPaymentRequest(ctx context.Context, sender kin.PrivateKey, r PaymentRequest, opts ...SolanaOption) (txID []byte, err error)
+
+	// Refund refunds the payment at index of the transaction originalTxID from
+	// refunder, linking the refund to the original payment by its memo. A second
+	// refund of the same payment is rejected by Agora within its dedupe retention
+	// window, using a DedupeID derived from the original payment (see RefundDedupeID).
+	Refund(ctx context.Context, refunder kin.PrivateKey, originalTxID []byte, index int, opts ...SolanaOption) (txID []byte, err error)
+
+	// BuildApproval builds a payment transaction that must be approved by the
+	// payment's sender before it is submitted with SubmitApproval, so that the
+	// sender's key need not be held by the caller (see Approval).
+	BuildApproval(ctx context.Context, payment ReadOnlyPayment, opts ...SolanaOption) (approval Approval, err error)
+
+	// SubmitApproval verifies and submits a payment approved by its sender.
+	SubmitApproval(ctx context.Context, approval Approval, opts ...SolanaOption) (txID []byte, err error)
+
+	// GetMinimumBalanceForRentExemption returns the minimum balance, in lamports, an
+	// account of the specified size requires to be rent exempt. Results are cached.
+	GetMinimumBalanceForRentExemption(ctx context.Context, size uint64) (lamports uint64, err error)
+
+	// EstimateAccountCreationCost estimates the SOL cost of creating count accounts
+	// with a custom subsidizer.
+	//
+	// Fees are estimated assuming each account is created with CreateAccount, which
+	// requires a subsidizer and owner signature per account. The fees for accounts
+	// created with CreateAccounts will be lower.
+	EstimateAccountCreationCost(ctx context.Context, count int) (cost AccountCreationCost, err error)
+
+	// GetSubsidizerStatus returns the status of the subsidizer used for transactions,
+	// which is the subsidizer specified by WithSubsidizer, or the service subsidizer.
+	// The subsidizers of a pool configured with WithSubsidizerPool can be queried by
+	// specifying them with WithSubsidizer.
+	//
+	// Querying the SOL balance requires WithSolanaRPC, otherwise ErrNoSolanaRPC is returned.
+	GetSubsidizerStatus(ctx context.Context, opts ...SolanaOption) (status SubsidizerStatus, err error)
+
+	// WatchSubsidizer polls the status of the subsidizer every interval, sending the status
+	// to the returned channel whenever the balance falls below threshold lamports. An alert
+	// is only sent again once the balance has recovered and subsequently fallen below the
+	// threshold. Errors while polling are ignored.
+	//
+	// The channel is closed once ctx is done, or the client is closed.
+	WatchSubsidizer(ctx context.Context, threshold uint64, interval time.Duration, opts ...SolanaOption) (alerts <-chan SubsidizerStatus, err error)
+
+	// GetSolBalance returns the SOL balance of an account, in lamports.
+	//
+	// Querying the SOL balance requires WithSolanaRPC, otherwise ErrNoSolanaRPC is returned.
+	GetSolBalance(ctx context.Context, account kin.PublicKey, opts ...SolanaOption) (lamports uint64, err error)
+
+	// TransferSol transfers lamports from sender to destination, which is useful for
+	// funding subsidizers. The transaction fee is paid by sender.
+	//
+	// If WithSolanaRPC was specified, the transaction is submitted to the Solana RPC
+	// endpoint, and TransferSol waits for it to reach the commitment of the call.
+	// Otherwise, it is submitted to Agora, which may not accept transactions without
+	// Kin transfers. If the transaction was submitted but failed, its ID is returned
+	// alongside the error.
+	TransferSol(ctx context.Context, sender kin.PrivateKey, destination kin.PublicKey, lamports uint64, opts ...SolanaOption) (txID []byte, err error)
+
+	// HealthCheck verifies connectivity to Agora, that its blockchain version is
+	// supported, and that the service config and a recent blockhash can be retrieved.
+	//
+	// HealthHandler exposes the report over HTTP, for use as a readiness probe.
+	HealthCheck(ctx context.Context) (report HealthReport)
+
+	// GetBlockchainVersion returns the blockchain version Agora is using for the
+	// app's traffic.
+	GetBlockchainVersion(ctx context.Context) (v version.KinVersion, err error)
+
+	// VersionChanged polls the blockchain version every interval, sending a
+	// VersionChange to the returned channel whenever it differs from the previously
+	// observed version. Errors while polling are ignored.
+	//
+	// The channel is closed once ctx is done, or the client is closed.
+	VersionChanged(ctx context.Context, interval time.Duration) (changes <-chan VersionChange, err error)
+
+	// Close closes the client. New submissions are rejected with ErrClientClosed, and
+	// Close waits for in-flight submissions (including their retries) to complete before
+	// stopping background goroutines, such as those started by WatchSubsidizer and
+	// WatchConfigFile, and closing the connection to Agora if it was created by the client.
+	//
+	// Close is safe to call multiple times.
+	Close() error
+
 	// Requests an airdrop of Kin to a Kin token account. Only available on the Kin 4 test environment.
 	RequestAirdrop(ctx context.Context, publicKey kin.PublicKey, quarks uint64, opts ...SolanaOption) (txID []byte, err error)
 }
 
 type client struct {
-	internal *InternalClient
-	opts     clientOpts
+	internal     *InternalClient
+	retrier      *reloadableRetrier
+	writeRetrier *reloadableRetrier
+
+	// mu guards the options that may be changed by a configuration reload.
+	mu   sync.RWMutex
+	opts clientOpts
 
 	env Environment
+
+	rpc                *solanaRPC
+	subsidizers        *subsidizerPool
+	subsidizerFailures failureTracker
+	versionedSupport   versionedSupport
+	mintAccounts       mintAccounts
+
+	lifecycle *lifecycle
 }
 
 type clientOpts struct {
@@ -85,11 +297,47 @@ type clientOpts struct {
 	minDelay           time.Duration
 	maxDelay           time.Duration
 
+	nonRetriableErrors []error
+	retriableErrors    []error
+	readRetryPolicy    RetryPolicy
+	writeRetryPolicy   RetryPolicy
+
 	cc       *grpc.ClientConn
 	endpoint string
 	appIndex uint16
 
 	defaultCommitment commonpbv4.Commitment
+	maxConcurrency    int
+
+	limits    *Limits
+	screener  PaymentScreener
+	resolver  Resolver
+	auditSink AuditSink
+
+	requestSigner RequestSigner
+
+	solanaRPCEndpoint string
+
+	subsidizerPool     []kin.PrivateKey
+	subsidizerStrategy SubsidizerStrategy
+
+	whitelister kin.PrivateKey
+
+	cache Cache
+	clock Clock
+
+	minKinVersion version.KinVersion
+
+	ctx context.Context
+
+	hooks Hooks
+
+	faultInjection *FaultInjectionConfig
+
+	mergeDuplicateEarns         bool
+	rejectDuplicateDestinations bool
+
+	lookupTables []lookuptable.Table
 }
 
 // ClientOption configures a Client.
@@ -162,12 +410,66 @@ func WithDefaultCommitment(defaultCommitment commonpbv4.Commitment) ClientOption
 	}
 }
 
+// WithMaxConcurrency specifies the maximum number of concurrent requests
+// made by bulk operations, such as GetTransactions.
+func WithMaxConcurrency(maxConcurrency int) ClientOption {
+	return func(o *clientOpts) {
+		o.maxConcurrency = maxConcurrency
+	}
+}
+
+// WithLimits specifies spending limits that are enforced before any
+// payment or earn batch is submitted.
+func WithLimits(limits Limits) ClientOption {
+	return func(o *clientOpts) {
+		if limits.Store == nil {
+			limits.Store = NewMemoryLimitStore()
+		}
+		o.limits = &limits
+	}
+}
+
+// PaymentScreener is a function that inspects a payment prior to submission.
+//
+// If an error is returned, the payment is not submitted.
+type PaymentScreener func(Payment) error
+
+// WithPaymentScreener specifies a PaymentScreener that is invoked before every
+// payment is submitted. For earn batches, the screener is invoked for each earn,
+// and the entire batch is rejected if any earn is rejected.
+//
+// Rejected payments result in a *ScreenedError, whose cause is
+// ErrPaymentScreened.
+//
+// Approved payments are screened when they are submitted with SubmitApproval.
+// Since the sender's key is held by the approver, their Sender can only be used
+// for its public key.
+func WithPaymentScreener(screener PaymentScreener) ClientOption {
+	return func(o *clientOpts) {
+		o.screener = screener
+	}
+}
+
 type solanaOpts struct {
 	commitment        commonpbv4.Commitment
 	accountResolution AccountResolution
 	destResolution    AccountResolution
 	subsidizer        kin.PrivateKey
 	senderCreate      bool
+	progress          func(EarnBatchProgress)
+	appUser           *appUserCredentials
+	chunkParallelism  int
+	chunkFunc         func(*EarnChunk)
+	computeUnitLimit  uint32
+	computeUnitPrice  uint64
+	descending        bool
+	rawTransaction    bool
+	versioned         bool
+	verifyEffects     bool
+	approvalTTL       time.Duration
+	preResolve        bool
+	prescreened       bool
+	filters           []FilterOption
 }
 
 // ClientOption configures a solana-related function call.
@@ -210,6 +512,29 @@ func WithSenderCreate() SolanaOption {
 	}
 }
 
+// WithDescending specifies that history should be returned newest first.
+func WithDescending() SolanaOption {
+	return func(o *solanaOpts) {
+		o.descending = true
+	}
+}
+
+// WithRawTransaction specifies that the RawTransaction of each TransactionData
+// returned by GetTransaction, GetTransactions, or GetHistory should be set.
+func WithRawTransaction() SolanaOption {
+	return func(o *solanaOpts) {
+		o.rawTransaction = true
+	}
+}
+
+// WithAppUserCredentials specifies app user credentials that Agora forwards to the
+// app's sign transaction webhook when submitting a payment or earn batch.
+func WithAppUserCredentials(userID, passkey string) SolanaOption {
+	return func(o *solanaOpts) {
+		o.appUser = &appUserCredentials{userID: userID, passkey: passkey}
+	}
+}
+
 // New creates a new client.
 //
 // todo: appIndex optional, can use string memo instead
@@ -221,6 +546,8 @@ func New(env Environment, opts ...ClientOption) (Client, error) {
 			minDelay:           500 * time.Millisecond,
 			maxDelay:           10 * time.Second,
 			defaultCommitment:  commonpbv4.Commitment_SINGLE,
+			maxConcurrency:     10,
+			auditSink:          NewNoopAuditSink(),
 		},
 	}
 
@@ -228,13 +555,15 @@ func New(env Environment, opts ...ClientOption) (Client, error) {
 		o(&c.opts)
 	}
 
-	var endpoint string
-	switch env {
-	case EnvironmentTest:
-		endpoint = "api.agorainfra.dev:443"
-	case EnvironmentProd:
-		endpoint = "api.agorainfra.net:443"
-	default:
+	if c.opts.auditSink == nil {
+		c.opts.auditSink = NewNoopAuditSink()
+	}
+	if c.opts.clock == nil {
+		c.opts.clock = SystemClock()
+	}
+
+	endpoint := env.Endpoint()
+	if endpoint == "" {
 		return nil, errors.Errorf("unknown environment: %s", env)
 	}
 	c.env = env
@@ -242,41 +571,104 @@ func New(env Environment, opts ...ClientOption) (Client, error) {
 	if c.opts.cc != nil && c.opts.endpoint != "" {
 		return nil, errors.New("WithGRPC and WithEndpoint cannot both be set")
 	}
+	if c.opts.cc != nil && c.opts.requestSigner != nil {
+		return nil, errors.New("WithGRPC and WithRequestSigner cannot both be set")
+	}
 	if c.opts.endpoint != "" {
 		endpoint = c.opts.endpoint
 	}
 
+	var faults *faultInjector
+	if c.opts.faultInjection != nil {
+		if env == EnvironmentProd {
+			return nil, errors.New("WithFaultInjection cannot be used with EnvironmentProd")
+		}
+
+		var err error
+		if faults, err = newFaultInjector(*c.opts.faultInjection); err != nil {
+			return nil, err
+		}
+	}
+
+	var ownedConn *grpc.ClientConn
 	if c.opts.cc == nil {
+		dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(nil))}
+		if c.opts.requestSigner != nil {
+			dialOpts = append(
+				dialOpts,
+				grpc.WithUnaryInterceptor(RequestSigningUnaryInterceptor(c.opts.requestSigner)),
+				grpc.WithStreamInterceptor(RequestSigningStreamInterceptor(c.opts.requestSigner)),
+			)
+		}
+
 		var err error
-		c.opts.cc, err = grpc.Dial(endpoint, grpc.WithTransportCredentials(credentials.NewTLS(nil)))
+		c.opts.cc, err = grpc.Dial(endpoint, dialOpts...)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to initialize grpc client")
 		}
+		ownedConn = c.opts.cc
 	}
 
-	retrier := retry.NewRetrier(
-		retry.Limit(c.opts.maxRetries),
-		retry.BackoffWithJitter(backoff.BinaryExponential(c.opts.minDelay), c.opts.maxDelay, 0.1),
-		retry.NonRetriableErrors(nonRetriableErrors...),
-		retry.NonRetriableGRPCCodes(codes.Canceled),
-	)
+	c.retrier = &reloadableRetrier{retrier: newRetrier(c.opts, c.opts.readRetryPolicy), onRetry: c.opts.hooks.OnRetry, faults: faults}
+	c.writeRetrier = &reloadableRetrier{retrier: newRetrier(c.opts, c.opts.writeRetryPolicy), onRetry: c.opts.hooks.OnRetry, faults: faults}
+	c.internal = NewInternalClient(c.opts.cc, c.retrier, c.opts.appIndex)
+	c.internal.writeRetrier = c.writeRetrier
+	c.internal.faults = faults
+	c.internal.clock = c.opts.clock
+	c.internal.minVersion = c.opts.minKinVersion
+	c.subsidizerFailures.clock = c.opts.clock
+	if c.opts.cache != nil {
+		c.internal.cache = newSharedCache(c.opts.cache, env)
+	}
+	if c.opts.solanaRPCEndpoint != "" {
+		c.rpc = newSolanaRPC(c.opts.solanaRPCEndpoint)
+	}
+	if c.opts.subsidizerPool != nil {
+		var err error
+		c.subsidizers, err = newSubsidizerPool(c.opts.subsidizerPool, c.opts.subsidizerStrategy, c.rpc, c.opts.clock)
+		if err != nil {
+			if ownedConn != nil {
+				_ = ownedConn.Close()
+			}
+			return nil, err
+		}
+	}
 
-	c.internal = NewInternalClient(c.opts.cc, retrier, c.opts.appIndex)
+	c.lifecycle = newLifecycle(c.opts.ctx, ownedConn)
+	go func() {
+		<-c.lifecycle.ctx.Done()
+		_ = c.lifecycle.close()
+	}()
 
 	return c, nil
 }
 
 // CreateAccount creates a kin account.
 func (c *client) CreateAccount(ctx context.Context, key kin.PrivateKey, opts ...SolanaOption) error {
+	ctx = ensureRequestID(ctx)
+
 	solanaOpts := solanaOpts{commitment: c.opts.defaultCommitment}
 	for _, o := range opts {
 		o(&solanaOpts)
 	}
-	_, err := retry.Retry(
-		func() error {
+	if err := c.selectSubsidizer(ctx, &solanaOpts); err != nil {
+		return err
+	}
+	if err := c.selectWhitelister(ctx, &solanaOpts); err != nil {
+		return err
+	}
+
+	done, err := c.lifecycle.begin()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	_, err = retry.Retry(
+		withRetryHook(c.opts.hooks.OnRetry, func() error {
 			return c.internal.CreateSolanaAccount(ctx, key, solanaOpts.commitment, solanaOpts.subsidizer, c.opts.appIndex)
-		},
-		retry.Limit(c.opts.maxSequenceRetries),
+		}),
+		retry.Limit(c.nonceRetries()),
 		retry.RetriableErrors(ErrBadNonce),
 	)
 	return err
@@ -325,6 +717,40 @@ func (c *client) ResolveTokenAccounts(ctx context.Context, account kin.PublicKey
 	return accounts, nil
 }
 
+func (c *client) ResolveTokenAccountsBatch(ctx context.Context, accounts []kin.PublicKey) (map[string][]kin.PublicKey, error) {
+	resolved, _, err := c.resolveConcurrently(ctx, accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string][]kin.PublicKey, len(resolved))
+	for owner, tokenAccounts := range resolved {
+		results[kin.PublicKey(owner).Base58()] = tokenAccounts
+	}
+	return results, nil
+}
+
+func (c *client) ResolveOwner(ctx context.Context, tokenAccount kin.PublicKey, opts ...SolanaOption) (owner, closeAuthority kin.PublicKey, err error) {
+	solanaOpts := solanaOpts{commitment: c.opts.defaultCommitment}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	accountInfo, err := c.internal.GetSolanaAccountInfo(ctx, tokenAccount, solanaOpts.commitment)
+	if err != nil {
+		return nil, nil, err
+	}
+	if accountInfo.GetOwner() == nil {
+		return nil, nil, errors.New("owner not provided by agora")
+	}
+
+	if accountInfo.GetCloseAuthority() != nil {
+		closeAuthority = accountInfo.GetCloseAuthority().Value
+	}
+
+	return accountInfo.GetOwner().Value, closeAuthority, nil
+}
+
 func (c *client) MergeTokenAccounts(ctx context.Context, account kin.PrivateKey, createAssociatedAccount bool, opts ...SolanaOption) ([]byte, error) {
 	conf := solanaOpts{
 		commitment: commonpbv4.Commitment_SINGLE,
@@ -333,6 +759,9 @@ func (c *client) MergeTokenAccounts(ctx context.Context, account kin.PrivateKey,
 	for _, o := range opts {
 		o(&conf)
 	}
+	if err := c.selectSubsidizer(ctx, &conf); err != nil {
+		return nil, err
+	}
 
 	existingAccounts, err := c.internal.ResolveTokenAccounts(ctx, account.Public(), true)
 	if err != nil {
@@ -430,7 +859,7 @@ func (c *client) MergeTokenAccounts(ctx context.Context, account kin.PrivateKey,
 		instructions...,
 	)
 
-	result, err := c.signAndSubmitTx(ctx, signers, tx, conf.commitment, nil, nil)
+	result, err := c.signAndSubmitTx(ctx, signers, tx, conf.commitment, nil, nil, nil)
 	if err != nil {
 		return result.ID, err
 	}
@@ -447,11 +876,133 @@ func (c *client) GetTransaction(ctx context.Context, txID []byte, opts ...Solana
 		o(&solanaOpts)
 	}
 
-	return c.internal.GetTransaction(ctx, txID, solanaOpts.commitment)
+	data, err := c.internal.GetTransaction(ctx, txID, solanaOpts.commitment)
+	if !solanaOpts.rawTransaction {
+		data.RawTransaction = nil
+	}
+	return data, err
+}
+
+// GetTransactionStatus returns the status of a transaction.
+func (c *client) GetTransactionStatus(ctx context.Context, txID []byte, opts ...SolanaOption) (TransactionStatus, error) {
+	solanaOpts := solanaOpts{commitment: c.opts.defaultCommitment}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	return c.internal.GetTransactionStatus(ctx, txID, solanaOpts.commitment)
+}
+
+// GetTransactions concurrently fetches the TransactionData for each of the specified
+// transaction hashes, returning a map keyed by the base58 encoded hash.
+//
+// If any lookup fails, the remaining lookups are cancelled and the error is returned.
+func (c *client) GetTransactions(ctx context.Context, txIDs [][]byte, opts ...SolanaOption) (map[string]TransactionData, error) {
+	solanaOpts := solanaOpts{commitment: c.opts.defaultCommitment}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := c.concurrency()
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	results := make(map[string]TransactionData, len(txIDs))
+	sem := make(chan struct{}, concurrency)
+
+	for _, txID := range txIDs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(txID []byte) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			data, err := c.internal.GetTransaction(ctx, txID, solanaOpts.commitment)
+			if !solanaOpts.rawTransaction {
+				data.RawTransaction = nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = errors.Wrapf(err, "failed to get transaction %s", base58.Encode(txID))
+					cancel()
+				}
+				return
+			}
+			results[base58.Encode(txID)] = data
+		}(txID)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (c *client) GetHistory(ctx context.Context, account kin.PublicKey, cursor Cursor, opts ...SolanaOption) ([]HistoryItem, error) {
+	solanaOpts := solanaOpts{}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	direction := transactionpbv4.GetHistoryRequest_ASC
+	if solanaOpts.descending {
+		direction = transactionpbv4.GetHistoryRequest_DESC
+	}
+
+	items, err := c.internal.GetHistory(ctx, account, cursor, direction)
+	if len(solanaOpts.filters) > 0 {
+		// Pages without any matching transactions are skipped, so that an
+		// empty page still indicates the end of the history.
+		for err == nil && len(items) > 0 {
+			next := items[len(items)-1].Cursor
+			if items = filterHistory(items, solanaOpts.filters); len(items) > 0 {
+				break
+			}
+			items, err = c.internal.GetHistory(ctx, account, next, direction)
+		}
+	}
+	if !solanaOpts.rawTransaction {
+		for i := range items {
+			items[i].RawTransaction = nil
+		}
+	}
+	return items, err
 }
 
 // SubmitPayment sends a single payment to a specified kin account.
 func (c *client) SubmitPayment(ctx context.Context, payment Payment, opts ...SolanaOption) ([]byte, error) {
+	if err := validateQuarks(payment.Quarks); err != nil {
+		return nil, err
+	}
+	payment, err := c.resolveDestination(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
 	if payment.Invoice != nil && c.opts.appIndex == 0 {
 		return nil, errors.New("cannot submit payment with invoices without an app index")
 	}
@@ -464,11 +1015,31 @@ func (c *client) SubmitPayment(ctx context.Context, payment Payment, opts ...Sol
 	for _, o := range opts {
 		o(&solanaOpts)
 	}
+	if solanaOpts.appUser != nil {
+		ctx = ContextWithAppUserCredentials(ctx, solanaOpts.appUser.userID, solanaOpts.appUser.passkey)
+	}
+	if err := c.selectSubsidizer(ctx, &solanaOpts); err != nil {
+		return nil, err
+	}
 
-	var result SubmitTransactionResult
-	var err error
+	if err := c.screen(payment); err != nil {
+		return nil, err
+	}
+	if limits := c.limits(); limits != nil {
+		err := limits.check(ctx, c.opts.appIndex, []limitEntry{{destination: payment.Destination, quarks: payment.Quarks}}, c.opts.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	result, err = c.submitPaymentWithResolution(ctx, payment, solanaOpts)
+	var snapshot *effectsSnapshot
+	if solanaOpts.verifyEffects {
+		if snapshot, err = c.snapshotEffects(ctx, payment.Sender.Public(), solanaOpts.commitment); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := c.submitPaymentWithResolution(ctx, payment, solanaOpts)
 	if err != nil {
 		return result.ID, err
 	}
@@ -491,14 +1062,28 @@ func (c *client) SubmitPayment(ctx context.Context, payment Payment, opts ...Sol
 		return result.ID, invoiceErrorFromProto(result.InvoiceErrors[0])
 	}
 
+	if snapshot != nil {
+		return result.ID, snapshot.verify(ctx, c, result.ID, -payment.Quarks)
+	}
+
 	return result.ID, nil
 }
 
 // SubmitEarnBatch submits a batch of earn payments in a single transaction.
 //
 // A batch is limited to 15 earns, which is roughly the max number of transfers
-// that can fit inside a Solana transaction
+// that can fit inside a Solana transaction, or to MaxLookupTableBatchSize earns
+// if its destinations are contained in the tables specified with
+// WithAddressLookupTables.
 func (c *client) SubmitEarnBatch(ctx context.Context, batch EarnBatch, opts ...SolanaOption) (result EarnBatchResult, err error) {
+	if c.opts.mergeDuplicateEarns {
+		if merged, indices := mergeDuplicateEarns(batch); indices != nil {
+			result, err = c.SubmitEarnBatch(ctx, merged, opts...)
+			remapEarnErrors(result.EarnErrors, indices)
+			return result, remapValidationError(err, indices)
+		}
+	}
+
 	solanaOpts := solanaOpts{
 		commitment:        c.opts.defaultCommitment,
 		accountResolution: AccountResolutionPreferred,
@@ -507,39 +1092,30 @@ func (c *client) SubmitEarnBatch(ctx context.Context, batch EarnBatch, opts ...S
 	for _, o := range opts {
 		o(&solanaOpts)
 	}
+	if solanaOpts.appUser != nil {
+		ctx = ContextWithAppUserCredentials(ctx, solanaOpts.appUser.userID, solanaOpts.appUser.passkey)
+	}
+	if err := c.selectSubsidizer(ctx, &solanaOpts); err != nil {
+		return result, err
+	}
 
 	if len(batch.Earns) == 0 {
 		return result, errors.New("earn batch must contain at least 1 earn")
 	}
-	if len(batch.Earns) > MaxBatchSize {
-		return result, errors.Errorf("earn batch must not contain more than %d earns", MaxBatchSize)
+	if max := c.maxEarnBatchSize(batch); len(batch.Earns) > max {
+		return result, errors.Errorf("earn batch must not contain more than %d earns", max)
 	}
 
-	// Verify that there isn't a mixed usage of Invoices and text Memos, so we can
-	// fail early to reduce the chance of partial failures.
-	if batch.Memo != "" {
-		for _, r := range batch.Earns {
-			if r.Invoice != nil {
-				err = errors.New("cannot have invoice set when memo is set")
-				break
-			}
-		}
-	} else {
-		if batch.Earns[0].Invoice != nil && c.opts.appIndex == 0 {
-			err = errors.New("cannot submit earn batch with invoices without an app index")
-		} else {
-			for i := 0; i < len(batch.Earns)-1; i++ {
-				if (batch.Earns[i].Invoice == nil) != (batch.Earns[i+1].Invoice == nil) {
-					err = errors.New("either all or none of the earns should have an invoice set")
-					break
-				}
-			}
-		}
-	}
-	if err != nil {
+	if err := c.validateEarnBatch(batch); err != nil {
 		return result, err
 	}
 
+	if !solanaOpts.prescreened {
+		if err := c.screenEarns(ctx, batch); err != nil {
+			return result, err
+		}
+	}
+
 	config, err := c.internal.GetServiceConfig(ctx)
 	if err != nil {
 		return result, err
@@ -549,12 +1125,23 @@ func (c *client) SubmitEarnBatch(ctx context.Context, batch EarnBatch, opts ...S
 		return result, ErrNoSubsidizer
 	}
 
+	var snapshot *effectsSnapshot
+	if solanaOpts.verifyEffects {
+		if snapshot, err = c.snapshotEffects(ctx, batch.Sender.Public(), solanaOpts.commitment); err != nil {
+			return result, err
+		}
+	}
+
 	submitResult, err := c.submitEarnBatchWithResolution(ctx, batch, config, solanaOpts)
 	if err != nil {
+		if errors.Is(err, ErrAlreadySubmitted) {
+			result.TxID = submitResult.ID
+		}
 		return result, err
 	}
 
 	result.TxID = submitResult.ID
+	result.Commitment = submitResult.Commitment
 	if submitResult.Errors.TxError != nil {
 		result.TxError = submitResult.Errors.TxError
 
@@ -578,11 +1165,108 @@ func (c *client) SubmitEarnBatch(ctx context.Context, batch EarnBatch, opts ...S
 		}
 	}
 
+	if snapshot != nil && result.TxError == nil {
+		var expected int64
+		for _, e := range batch.Earns {
+			// Earns from accounts owned by another key do not affect the
+			// balance of the sender.
+			if e.Owner == nil {
+				expected -= e.Quarks
+			}
+		}
+		err = snapshot.verify(ctx, c, result.TxID, expected)
+	}
+
 	return result, err
 }
 
+// validateEarnBatch verifies the contents of the batch, including that there
+// isn't a mixed usage of Invoices and text Memos, so we can fail early to reduce
+// the chance of partial failures.
+//
+// All problems are reported in a *BatchValidationError.
+func (c *client) validateEarnBatch(batch EarnBatch) error {
+	var problems []BatchValidationProblem
+	add := func(index int, field, reason string) {
+		problems = append(problems, BatchValidationProblem{Index: index, Field: field, Reason: reason})
+	}
+
+	if len(batch.Sender) != ed25519.PrivateKeySize {
+		add(-1, "Sender", "must be a valid private key")
+	}
+
+	hasInvoices := len(batch.Earns) > 0 && batch.Earns[0].Invoice != nil
+	if hasInvoices && batch.Memo == "" && c.opts.appIndex == 0 {
+		add(-1, "Earns", "cannot submit earn batch with invoices without an app index")
+	}
+
+	var total int64
+	destinations := make(map[string]int, len(batch.Earns))
+	for i, e := range batch.Earns {
+		if len(e.Destination) == 0 {
+			add(i, "Destination", "must be set")
+		} else if len(e.Destination) != ed25519.PublicKeySize {
+			add(i, "Destination", "must be a valid public key")
+		} else if first, ok := destinations[string(e.Destination)]; ok && c.opts.rejectDuplicateDestinations {
+			add(i, "Destination", fmt.Sprintf("duplicates the destination of earn %d", first))
+		} else if !ok {
+			destinations[string(e.Destination)] = i
+		}
+
+		if e.Source != nil && len(e.Source) != ed25519.PublicKeySize {
+			add(i, "Source", "must be a valid public key")
+		}
+		if e.Owner != nil {
+			if e.Source == nil {
+				add(i, "Owner", "requires Source to be set")
+			} else if len(e.Owner) != ed25519.PrivateKeySize {
+				add(i, "Owner", "must be a valid private key")
+			}
+		}
+
+		if e.Quarks <= 0 {
+			add(i, "Quarks", "must be positive")
+		} else if e.Quarks > MaxSupplyQuarks {
+			add(i, "Quarks", "must not exceed the maximum supply")
+		} else if total <= MaxSupplyQuarks {
+			// Each amount is at most MaxSupplyQuarks, so the total cannot
+			// overflow before it is found to exceed it.
+			total += e.Quarks
+		}
+
+		if batch.Memo != "" {
+			if e.Invoice != nil {
+				add(i, "Invoice", "cannot have invoice set when memo is set")
+			}
+		} else if (e.Invoice != nil) != hasInvoices {
+			add(i, "Invoice", "either all or none of the earns should have an invoice set")
+		}
+	}
+
+	if total > MaxSupplyQuarks {
+		add(-1, "Earns", "total quarks must not exceed the maximum supply")
+	}
+
+	if len(problems) > 0 {
+		return &BatchValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateQuarks verifies that the quarks of a payment are positive, and do not
+// exceed the supply of Kin.
+func validateQuarks(quarks int64) error {
+	if quarks <= 0 {
+		return errors.Wrapf(ErrInvalidAmount, "quarks must be positive, got %d", quarks)
+	}
+	if quarks > MaxSupplyQuarks {
+		return errors.Wrapf(ErrInvalidAmount, "quarks must not exceed the maximum supply, got %d", quarks)
+	}
+	return nil
+}
+
 func (c *client) RequestAirdrop(ctx context.Context, publicKey kin.PublicKey, quarks uint64, opts ...SolanaOption) ([]byte, error) {
-	if c.env != EnvironmentTest {
+	if !c.env.IsTest() {
 		return nil, errors.New("only available on the test environment")
 	}
 
@@ -591,9 +1275,71 @@ func (c *client) RequestAirdrop(ctx context.Context, publicKey kin.PublicKey, qu
 		o(&solanaOpts)
 	}
 
+	done, err := c.lifecycle.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	return c.internal.RequestAirdrop(ctx, publicKey, quarks, solanaOpts.commitment)
 }
 
+func (c *client) nonceRetries() uint {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.opts.maxSequenceRetries
+}
+
+func (c *client) concurrency() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.opts.maxConcurrency
+}
+
+func (c *client) limits() *Limits {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.opts.limits
+}
+
+func (c *client) screen(p Payment) error {
+	// The screener is called without holding the lock, since it may be slow.
+	c.mu.RLock()
+	screener := c.opts.screener
+	c.mu.RUnlock()
+	if screener == nil {
+		return nil
+	}
+
+	if err := screener(p); err != nil {
+		return &ScreenedError{Err: err}
+	}
+
+	return nil
+}
+
+// earnPayments returns the Payment representation of each earn in the batch.
+func earnPayments(batch EarnBatch) []Payment {
+	payments := make([]Payment, len(batch.Earns))
+	for i, e := range batch.Earns {
+		sender := batch.Sender
+		if e.Owner != nil {
+			sender = e.Owner
+		}
+		payments[i] = Payment{
+			Sender:      sender,
+			Destination: e.Destination,
+			Type:        batch.transactionType(),
+			Quarks:      e.Quarks,
+			Invoice:     e.Invoice,
+			Memo:        batch.Memo,
+			Mint:        batch.Mint,
+			DedupeID:    batch.DedupeID,
+		}
+	}
+	return payments
+}
+
 func (c *client) submitPaymentWithResolution(ctx context.Context, p Payment, solanaOpts solanaOpts) (result SubmitTransactionResult, err error) {
 	config, err := c.internal.GetServiceConfig(ctx)
 	if err != nil {
@@ -613,7 +1359,13 @@ func (c *client) submitPaymentWithResolution(ctx context.Context, p Payment, sol
 
 	var transferSender kin.PublicKey
 	internalPayment := payment{
-		Payment: p,
+		Payment:                   p,
+		computeBudgetInstructions: solanaOpts.computeBudgetInstructions(),
+		versioned:                 solanaOpts.versioned,
+	}
+
+	if isSecondaryMint(config, p.Mint) {
+		return c.submitMintPayment(ctx, internalPayment, config, solanaOpts)
 	}
 
 	// Optimistically send the payment (without resolution)
@@ -625,118 +1377,132 @@ func (c *client) submitPaymentWithResolution(ctx context.Context, p Payment, sol
 		return result, err
 	}
 
-	var resubmit bool
-	if solanaOpts.accountResolution == AccountResolutionPreferred {
-		tokenAccounts, err := c.internal.ResolveTokenAccounts(ctx, internalPayment.Sender.Public(), false)
-		if err != nil {
-			return result, err
-		}
-
-		if len(tokenAccounts) > 0 {
-			transferSender = tokenAccounts[0].AccountId.Value
-			resubmit = true
-		}
-	}
-	if solanaOpts.destResolution == AccountResolutionPreferred {
-		tokenAccounts, err := c.internal.ResolveTokenAccounts(ctx, internalPayment.Destination, false)
-		if err != nil {
-			return result, err
-		}
+	// The resolutions may have been served from the cache, in which case the
+	// resolved accounts may have since been closed. If so, the stale resolutions
+	// are purged, and the payment is resolved and resubmitted once more.
+	unresolved := internalPayment
+	for attempt := 0; attempt < 2; attempt++ {
+		internalPayment = unresolved
+		transferSender = nil
 
-		if len(tokenAccounts) > 0 {
-			internalPayment.Destination = tokenAccounts[0].AccountId.Value
-			resubmit = true
-		} else if solanaOpts.senderCreate {
-			lamports, err := c.internal.GetMinimumBalanceForRentException(ctx, token.AccountSize)
+		var resubmit, fromCache bool
+		if solanaOpts.accountResolution == AccountResolutionPreferred {
+			tokenAccounts, cached, err := c.internal.resolveTokenAccounts(ctx, internalPayment.Sender.Public(), false)
 			if err != nil {
-				return result, errors.Wrap(err, "failed to get minimum lamports")
+				return result, err
 			}
+			fromCache = fromCache || cached
 
-			pub, priv, err := ed25519.GenerateKey(nil)
+			if len(tokenAccounts) > 0 {
+				transferSender = tokenAccounts[0].AccountId.Value
+				resubmit = true
+			}
+		}
+		if solanaOpts.destResolution == AccountResolutionPreferred {
+			tokenAccounts, cached, err := c.internal.resolveTokenAccounts(ctx, internalPayment.Destination, false)
 			if err != nil {
-				return result, errors.Wrap(err, "failed to generate temporary key")
-			}
-
-			internalPayment.Destination = kin.PublicKey(pub)
-			internalPayment.createAccountInstructions = []solana.Instruction{
-				system.CreateAccount(
-					subsidizer,
-					pub,
-					token.ProgramKey,
-					lamports,
-					token.AccountSize,
-				),
-				token.InitializeAccount(
-					pub,
-					config.Token.Value,
-					pub,
-				),
-				token.SetAuthority(
-					pub,
-					pub,
-					subsidizer,
-					token.AuthorityTypeCloseAccount,
-				),
-				token.SetAuthority(
-					pub,
-					pub,
-					ed25519.PublicKey(p.Destination),
-					token.AuthorityTypeAccountHolder,
-				),
-			}
-			internalPayment.createAccountSigner = priv
-			resubmit = true
-		}
-	}
-
-	if resubmit {
+				return result, err
+			}
+			fromCache = fromCache || cached
+
+			if len(tokenAccounts) > 0 {
+				internalPayment.Destination = tokenAccounts[0].AccountId.Value
+				resubmit = true
+			} else if solanaOpts.senderCreate {
+				lamports, err := c.internal.GetMinimumBalanceForRentException(ctx, token.AccountSize)
+				if err != nil {
+					return result, errors.Wrap(err, "failed to get minimum lamports")
+				}
+
+				pub, priv, err := ed25519.GenerateKey(nil)
+				if err != nil {
+					return result, errors.Wrap(err, "failed to generate temporary key")
+				}
+
+				internalPayment.Destination = kin.PublicKey(pub)
+				internalPayment.createAccountInstructions = []solana.Instruction{
+					system.CreateAccount(
+						subsidizer,
+						pub,
+						token.ProgramKey,
+						lamports,
+						token.AccountSize,
+					),
+					token.InitializeAccount(
+						pub,
+						config.Token.Value,
+						pub,
+					),
+					token.SetAuthority(
+						pub,
+						pub,
+						subsidizer,
+						token.AuthorityTypeCloseAccount,
+					),
+					token.SetAuthority(
+						pub,
+						pub,
+						ed25519.PublicKey(p.Destination),
+						token.AuthorityTypeAccountHolder,
+					),
+				}
+				internalPayment.createAccountSigner = priv
+				resubmit = true
+			}
+		}
+
+		if !resubmit {
+			break
+		}
+
 		result, err = c.submitSolanaPayment(ctx, internalPayment, config, solanaOpts.commitment, transferSender, solanaOpts.subsidizer)
+		if err != nil || result.Errors.TxError != ErrAccountDoesNotExist || !fromCache {
+			break
+		}
+		c.internal.invalidateResolutions(ctx, p.Sender.Public(), p.Destination)
 	}
 
 	return result, err
 }
 
 func (c *client) submitSolanaPayment(ctx context.Context, p payment, config *transactionpbv4.GetServiceConfigResponse, commitment commonpbv4.Commitment, transferSource kin.PublicKey, subsidizer kin.PrivateKey) (SubmitTransactionResult, error) {
+	tx, signers, il, err := c.buildPaymentTx(p, config, transferSource, subsidizer)
+	if err != nil {
+		return SubmitTransactionResult{}, err
+	}
+
+	if p.versioned {
+		if result, ok, err := c.submitVersioned(ctx, signers, versioned.FromLegacy(tx), commitment, il, p.DedupeID, nil); ok {
+			return result, err
+		}
+	}
+
+	return c.signAndSubmitTx(ctx, signers, tx, commitment, il, p.DedupeID, nil)
+}
+
+func (c *client) buildPaymentTx(p payment, config *transactionpbv4.GetServiceConfigResponse, transferSource kin.PublicKey, subsidizer kin.PrivateKey) (tx solana.Transaction, signers []kin.PrivateKey, il *commonpb.InvoiceList, err error) {
 	var subsidizerID kin.PublicKey
-	var signers []kin.PrivateKey
+	signers = make([]kin.PrivateKey, 0, 3)
 	if subsidizer != nil {
 		subsidizerID = subsidizer.Public()
-		signers = []kin.PrivateKey{subsidizer, p.Sender}
+		signers = append(signers, subsidizer, p.Sender)
 	} else {
 		subsidizerID = config.GetSubsidizerAccount().GetValue()
-		signers = []kin.PrivateKey{p.Sender}
+		signers = append(signers, p.Sender)
 	}
 	if len(p.createAccountSigner) == ed25519.PrivateKeySize {
 		signers = append(signers, kin.PrivateKey(p.createAccountSigner))
 	}
 
-	var instructions []solana.Instruction
-	var il *commonpb.InvoiceList
+	instructions := make([]solana.Instruction, 0, 2+len(p.createAccountInstructions)+len(p.computeBudgetInstructions))
+	instructions = append(instructions, p.computeBudgetInstructions...)
 
-	if p.Memo != "" {
-		instructions = append(instructions, memo.Instruction(p.Memo))
-	} else if c.opts.appIndex > 0 {
-		var fk [sha256.Size224]byte
-
-		if p.Invoice != nil {
-			il = &commonpb.InvoiceList{
-				Invoices: []*commonpb.Invoice{
-					p.Invoice,
-				},
-			}
-			invoiceBytes, err := proto.Marshal(il)
-			if err != nil {
-				return SubmitTransactionResult{}, errors.Wrap(err, "failed to serialize invoice list")
-			}
-			fk = sha256.Sum224(invoiceBytes)
-		}
-
-		m, err := kin.NewMemo(1, p.Type, c.opts.appIndex, fk[:])
-		if err != nil {
-			return SubmitTransactionResult{}, errors.Wrap(err, "failed to create memo")
-		}
-
-		instructions = append(instructions, memo.Instruction(base64.StdEncoding.EncodeToString(m[:])))
+	memoInstruction, il, err := c.paymentMemo(p.Payment)
+	if err != nil {
+		return tx, nil, nil, err
+	}
+	if memoInstruction != nil {
+		instructions = append(instructions, *memoInstruction)
 	}
 
 	if transferSource == nil {
@@ -754,24 +1520,93 @@ func (c *client) submitSolanaPayment(ctx context.Context, p payment, config *tra
 		),
 	)
 
-	tx := solana.NewTransaction(ed25519.PublicKey(subsidizerID), instructions...)
-	return c.signAndSubmitTx(ctx, signers, tx, commitment, il, p.DedupeID)
+	tx = solana.NewTransaction(ed25519.PublicKey(subsidizerID), instructions...)
+	if err := checkTransactionSize(tx); err != nil {
+		return tx, nil, nil, err
+	}
+
+	return tx, signers, il, nil
+}
+
+// paymentMemo returns the memo instruction of p, which is nil if p has no text
+// memo and the client has no app index, and the invoice list of p, if any.
+func (c *client) paymentMemo(p Payment) (instruction *solana.Instruction, il *commonpb.InvoiceList, err error) {
+	if p.Memo != "" {
+		i := memo.Instruction(p.Memo)
+		return &i, nil, nil
+	}
+	if c.opts.appIndex == 0 {
+		return nil, nil, nil
+	}
+
+	var fk [sha256.Size224]byte
+	if p.Invoice != nil {
+		il = &commonpb.InvoiceList{
+			Invoices: []*commonpb.Invoice{
+				p.Invoice,
+			},
+		}
+		if err := il.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid invoice list")
+		}
+		if fk, err = invoiceListHash(il); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	m, err := kin.NewMemo(1, p.Type, c.opts.appIndex, fk[:])
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create memo")
+	}
+
+	i := memo.Instruction(base64.StdEncoding.EncodeToString(m[:]))
+	return &i, il, nil
 }
 
 func (c *client) submitEarnBatchWithResolution(ctx context.Context, batch EarnBatch, config *transactionpbv4.GetServiceConfigResponse, solanaOpts solanaOpts) (SubmitTransactionResult, error) {
+	if isSecondaryMint(config, batch.Mint) {
+		return c.submitMintEarnBatch(ctx, batch, config, solanaOpts)
+	}
+
+	unresolved := batch
 	var transferSender kin.PublicKey
-	result, err := c.submitSolanaEarnBatch(ctx, batch, config, solanaOpts.commitment, transferSender, solanaOpts.subsidizer)
+	var preResolvedFromCache bool
+	if solanaOpts.preResolve {
+		var err error
+		if batch, transferSender, preResolvedFromCache, err = c.preResolveEarnBatch(ctx, unresolved, solanaOpts); err != nil {
+			return SubmitTransactionResult{}, err
+		}
+	}
+
+	result, err := c.submitSolanaEarnBatch(ctx, batch, config, solanaOpts, transferSender, earnBatchReporter(solanaOpts.progress, batch, 0))
+	reportEarnBatchResult(solanaOpts.progress, batch, 0, result, err)
 	if err != nil {
 		return result, err
 	}
 
-	if result.Errors.TxError == ErrAccountDoesNotExist {
-		var resubmit bool
+	// Resolving the batch again would only repeat the pre-resolution, unless it
+	// was served from the cache.
+	if solanaOpts.preResolve && result.Errors.TxError == ErrAccountDoesNotExist {
+		if !preResolvedFromCache {
+			return result, err
+		}
+		c.internal.invalidateResolutions(ctx, earnBatchOwners(unresolved)...)
+	}
+
+	// As with payments, if the resolutions were served from the cache, they are
+	// purged, and the batch is resolved and resubmitted once more.
+	for attempt := 1; attempt <= 2 && result.Errors.TxError == ErrAccountDoesNotExist; attempt++ {
+		batch = unresolved
+		batch.Earns = append([]Earn(nil), unresolved.Earns...)
+		transferSender = nil
+
+		var resubmit, fromCache bool
 		if solanaOpts.accountResolution == AccountResolutionPreferred {
-			tokenAccounts, err := c.internal.ResolveTokenAccounts(ctx, batch.Sender.Public(), false)
+			tokenAccounts, cached, err := c.internal.resolveTokenAccounts(ctx, batch.Sender.Public(), false)
 			if err != nil {
 				return result, err
 			}
+			fromCache = fromCache || cached
 			if len(tokenAccounts) > 0 {
 				transferSender = tokenAccounts[0].AccountId.Value
 				resubmit = true
@@ -779,10 +1614,11 @@ func (c *client) submitEarnBatchWithResolution(ctx context.Context, batch EarnBa
 		}
 		if solanaOpts.destResolution == AccountResolutionPreferred {
 			for i, earn := range batch.Earns {
-				tokenAccounts, err := c.internal.ResolveTokenAccounts(ctx, earn.Destination, false)
+				tokenAccounts, cached, err := c.internal.resolveTokenAccounts(ctx, earn.Destination, false)
 				if err != nil {
 					return result, err
 				}
+				fromCache = fromCache || cached
 				if len(tokenAccounts) > 0 {
 					batch.Earns[i].Destination = tokenAccounts[0].AccountId.Value
 					resubmit = true
@@ -790,27 +1626,109 @@ func (c *client) submitEarnBatchWithResolution(ctx context.Context, batch EarnBa
 			}
 		}
 
-		if resubmit {
-			result, err = c.submitSolanaEarnBatch(ctx, batch, config, solanaOpts.commitment, transferSender, solanaOpts.subsidizer)
+		if !resubmit {
+			break
 		}
+
+		result, err = c.submitSolanaEarnBatch(ctx, batch, config, solanaOpts, transferSender, earnBatchReporter(solanaOpts.progress, batch, attempt))
+		reportEarnBatchResult(solanaOpts.progress, batch, attempt, result, err)
+		if err != nil || result.Errors.TxError != ErrAccountDoesNotExist || !fromCache {
+			break
+		}
+
+		c.internal.invalidateResolutions(ctx, earnBatchOwners(unresolved)...)
 	}
 
 	return result, err
 }
 
-func (c *client) submitSolanaEarnBatch(ctx context.Context, batch EarnBatch, config *transactionpbv4.GetServiceConfigResponse, commitment commonpbv4.Commitment, transferSender kin.PublicKey, subsidizer kin.PrivateKey) (SubmitTransactionResult, error) {
-	var subsidizerID kin.PublicKey
+// earnBatchOwners returns the sender and destinations of batch.
+func earnBatchOwners(batch EarnBatch) []kin.PublicKey {
+	owners := []kin.PublicKey{batch.Sender.Public()}
+	for _, earn := range batch.Earns {
+		owners = append(owners, earn.Destination)
+	}
+	return owners
+}
+
+func (c *client) submitSolanaEarnBatch(ctx context.Context, batch EarnBatch, config *transactionpbv4.GetServiceConfigResponse, solanaOpts solanaOpts, transferSender kin.PublicKey, progress stageFunc) (SubmitTransactionResult, error) {
+	progress.report(EarnBatchStageBuild, nil)
+
+	if tables := c.lookupTablesFor(batch); tables != nil || solanaOpts.versioned {
+		tx, signers, il, err := c.buildVersionedEarnBatchTx(batch, config, transferSender, solanaOpts.subsidizer, tables, solanaOpts.computeBudgetInstructions()...)
+		if err != nil {
+			return SubmitTransactionResult{}, err
+		}
+
+		if result, ok, err := c.submitVersioned(ctx, signers, tx, solanaOpts.commitment, il, batch.DedupeID, progress); ok {
+			return result, err
+		}
+		if len(batch.Earns) > MaxBatchSize {
+			return SubmitTransactionResult{}, ErrVersionedTransactionsUnsupported
+		}
+	}
+
+	tx, signers, il, err := c.buildEarnBatchTx(batch, config, transferSender, solanaOpts.subsidizer, solanaOpts.computeBudgetInstructions()...)
+	if err != nil {
+		return SubmitTransactionResult{}, err
+	}
+
+	return c.signAndSubmitTx(ctx, signers, tx, solanaOpts.commitment, il, batch.DedupeID, progress)
+}
+
+func (c *client) buildEarnBatchTx(batch EarnBatch, config *transactionpbv4.GetServiceConfigResponse, transferSender kin.PublicKey, subsidizer kin.PrivateKey, prefix ...solana.Instruction) (tx solana.Transaction, signers []kin.PrivateKey, il *commonpb.InvoiceList, err error) {
+	subsidizerID, signers := earnBatchSigners(batch, config, subsidizer)
+
+	instructions, il, err := c.earnBatchInstructions(batch, transferSender, prefix)
+	if err != nil {
+		return tx, nil, nil, err
+	}
+
+	tx = solana.NewTransaction(ed25519.PublicKey(subsidizerID), instructions...)
+	if err := checkTransactionSize(tx); err != nil {
+		return tx, nil, nil, err
+	}
+
+	return tx, signers, il, nil
+}
+
+// earnBatchSigners returns the fee payer of an earn batch transaction, and the
+// keys that sign it locally: the subsidizer (if any), the sender, and the
+// distinct owners of any overridden sources.
+func earnBatchSigners(batch EarnBatch, config *transactionpbv4.GetServiceConfigResponse, subsidizer kin.PrivateKey) (kin.PublicKey, []kin.PrivateKey) {
+	var feePayer kin.PublicKey
 	var signers []kin.PrivateKey
 	if subsidizer != nil {
-		subsidizerID = subsidizer.Public()
+		feePayer = subsidizer.Public()
 		signers = []kin.PrivateKey{subsidizer, batch.Sender}
 	} else {
-		subsidizerID = config.GetSubsidizerAccount().GetValue()
+		feePayer = config.GetSubsidizerAccount().GetValue()
 		signers = []kin.PrivateKey{batch.Sender}
 	}
 
-	var instructions []solana.Instruction
-	var il *commonpb.InvoiceList
+	seen := make(map[string]struct{}, len(signers))
+	for _, s := range signers {
+		seen[string(s)] = struct{}{}
+	}
+	for _, e := range batch.Earns {
+		if e.Owner == nil {
+			continue
+		}
+		if _, ok := seen[string(e.Owner)]; ok {
+			continue
+		}
+		seen[string(e.Owner)] = struct{}{}
+		signers = append(signers, e.Owner)
+	}
+
+	return feePayer, signers
+}
+
+// earnBatchInstructions returns the instructions of an earn batch transaction,
+// following prefix, and the invoice list of the batch, if any.
+func (c *client) earnBatchInstructions(batch EarnBatch, transferSender kin.PublicKey, prefix []solana.Instruction) (instructions []solana.Instruction, il *commonpb.InvoiceList, err error) {
+	instructions = make([]solana.Instruction, 0, 1+len(batch.Earns)+len(prefix))
+	instructions = append(instructions, prefix...)
 
 	if batch.Memo != "" {
 		instructions = append(instructions, memo.Instruction(batch.Memo))
@@ -826,16 +1744,17 @@ func (c *client) submitSolanaEarnBatch(ctx context.Context, batch EarnBatch, con
 				il.Invoices[i] = e.Invoice
 			}
 
-			invoiceBytes, err := proto.Marshal(il)
-			if err != nil {
-				return SubmitTransactionResult{}, errors.Wrap(err, "failed to serialize invoice list")
+			if err := il.Validate(); err != nil {
+				return nil, nil, errors.Wrap(err, "invalid invoice list")
+			}
+			if fk, err = invoiceListHash(il); err != nil {
+				return nil, nil, err
 			}
-			fk = sha256.Sum224(invoiceBytes)
 		}
 
-		m, err := kin.NewMemo(1, kin.TransactionTypeEarn, c.opts.appIndex, fk[:])
+		m, err := kin.NewMemo(1, batch.transactionType(), c.opts.appIndex, fk[:])
 		if err != nil {
-			return SubmitTransactionResult{}, errors.Wrap(err, "failed to create memo")
+			return nil, nil, errors.Wrap(err, "failed to create memo")
 		}
 
 		instructions = append(instructions, memo.Instruction(base64.StdEncoding.EncodeToString(m[:])))
@@ -846,48 +1765,129 @@ func (c *client) submitSolanaEarnBatch(ctx context.Context, batch EarnBatch, con
 	}
 
 	for _, earn := range batch.Earns {
+		source, owner := transferSender, batch.Sender.Public()
+		if earn.Source != nil {
+			source = earn.Source
+		}
+		if earn.Owner != nil {
+			owner = earn.Owner.Public()
+		}
+
 		instructions = append(
 			instructions,
 			token.Transfer(
-				ed25519.PublicKey(transferSender),
+				ed25519.PublicKey(source),
 				ed25519.PublicKey(earn.Destination),
-				ed25519.PublicKey(batch.Sender.Public()),
+				ed25519.PublicKey(owner),
 				uint64(earn.Quarks),
 			),
 		)
 	}
 
-	tx := solana.NewTransaction(ed25519.PublicKey(subsidizerID), instructions...)
-	return c.signAndSubmitTx(ctx, signers, tx, commitment, il, batch.DedupeID)
+	return instructions, il, nil
 }
 
-func (c *client) signAndSubmitTx(ctx context.Context, signers []kin.PrivateKey, tx solana.Transaction, commitment commonpbv4.Commitment, il *commonpb.InvoiceList, dedupeId []byte) (SubmitTransactionResult, error) {
+// submittable is a legacy or versioned transaction that can be signed and
+// submitted by signAndSubmit.
+type submittable interface {
+	// payer returns the fee payer of the transaction.
+	payer() ed25519.PublicKey
+
+	setBlockhash(blockhash solana.Blockhash)
+	sign(keys ...ed25519.PrivateKey) error
+
+	// payerSignature returns the signature of the fee payer, which may be set
+	// after a remote signature is requested.
+	payerSignature() *solana.Signature
+
+	remoteSign(ctx context.Context, c *InternalClient, il *commonpb.InvoiceList) (SignTransactionResult, error)
+	submit(ctx context.Context, c *InternalClient, il *commonpb.InvoiceList, commitment commonpbv4.Commitment, dedupeID []byte) (SubmitTransactionResult, error)
+
+	// view returns the transaction as it is passed to hooks and audit records.
+	view() solana.Transaction
+}
+
+// legacyTx is a submittable legacy transaction.
+type legacyTx struct {
+	tx *solana.Transaction
+}
+
+func (t legacyTx) payer() ed25519.PublicKey                { return t.tx.Message.Accounts[0] }
+func (t legacyTx) setBlockhash(blockhash solana.Blockhash) { t.tx.SetBlockhash(blockhash) }
+func (t legacyTx) sign(keys ...ed25519.PrivateKey) error   { return t.tx.Sign(keys...) }
+func (t legacyTx) payerSignature() *solana.Signature       { return &t.tx.Signatures[0] }
+func (t legacyTx) view() solana.Transaction                { return *t.tx }
+
+func (t legacyTx) remoteSign(ctx context.Context, c *InternalClient, il *commonpb.InvoiceList) (SignTransactionResult, error) {
+	return c.SignTransaction(ctx, *t.tx, il)
+}
+
+func (t legacyTx) submit(ctx context.Context, c *InternalClient, il *commonpb.InvoiceList, commitment commonpbv4.Commitment, dedupeID []byte) (SubmitTransactionResult, error) {
+	return c.SubmitSolanaTransaction(ctx, *t.tx, il, commitment, dedupeID)
+}
+
+func (c *client) signAndSubmitTx(ctx context.Context, signers []kin.PrivateKey, tx solana.Transaction, commitment commonpbv4.Commitment, il *commonpb.InvoiceList, dedupeId []byte, progress stageFunc) (SubmitTransactionResult, error) {
+	return c.signAndSubmit(ctx, signers, legacyTx{tx: &tx}, commitment, il, dedupeId, progress)
+}
+
+// signAndSubmit signs and submits tx, retrying with a new blockhash if it is
+// rejected for having a bad nonce. Every attempt is recorded to the audit sink,
+// and passed to the submission hooks.
+func (c *client) signAndSubmit(ctx context.Context, signers []kin.PrivateKey, tx submittable, commitment commonpbv4.Commitment, il *commonpb.InvoiceList, dedupeId []byte, progress stageFunc) (SubmitTransactionResult, error) {
 	var result SubmitTransactionResult
+	ctx = ensureRequestID(ctx)
+
+	done, err := c.lifecycle.begin()
+	if err != nil {
+		return result, err
+	}
+	defer done()
 	keys := make([]ed25519.PrivateKey, len(signers))
 	for i, signer := range signers {
 		keys[i] = ed25519.PrivateKey(signer)
 	}
+	if w := c.whitelisterFor(tx.payer(), signers); w != nil {
+		keys = append(keys, ed25519.PrivateKey(w))
+	}
 
 	var emptySig [ed25519.SignatureSize]byte
 
-	_, err := retry.Retry(
-		func() error {
-			blockhash, err := c.internal.GetRecentBlockhash(ctx)
-			if err != nil {
-				return err
+	// Building audit records decompiles the transaction, which is wasted
+	// work if the records are discarded.
+	_, noopAudit := c.opts.auditSink.(noopAuditSink)
+	audit := !noopAudit
+
+	// A blockhash shared by chunks of a larger batch is only used for the
+	// first attempt, since a retry may be due to it having expired.
+	shared, useShared := ctx.Value(sharedBlockhashKey{}).(*sharedBlockhash)
+
+	attempts, err := retry.Retry(
+		withRetryHook(c.opts.hooks.OnRetry, func() error {
+			var blockhash solana.Blockhash
+			var ok bool
+			var err error
+			if useShared {
+				blockhash, ok = shared.get()
+				useShared = false
+			}
+			if !ok {
+				if blockhash, err = c.internal.GetRecentBlockhash(ctx); err != nil {
+					return err
+				}
 			}
 
-			tx.SetBlockhash(blockhash)
+			tx.setBlockhash(blockhash)
 
-			err = tx.Sign(keys...)
+			progress.report(EarnBatchStageSign, nil)
+			err = tx.sign(keys...)
 			if err != nil {
 				return err
 			}
 
 			// If the transaction isn't subsidized, request a signature.
 			var remoteSigned bool
-			if tx.Signatures[0] == (solana.Signature{}) {
-				signResult, err := c.internal.SignTransaction(ctx, tx, il)
+			if *tx.payerSignature() == (solana.Signature{}) {
+				signResult, err := tx.remoteSign(ctx, c.internal, il)
 				if err != nil {
 					return err
 				}
@@ -902,32 +1902,83 @@ func (c *client) signAndSubmitTx(ctx context.Context, signers []kin.PrivateKey,
 				}
 
 				remoteSigned = true
-				copy(tx.Signatures[0][:], signResult.ID)
+				copy(tx.payerSignature()[:], signResult.ID)
+			}
+
+			view := tx.view()
+			if audit {
+				if err := c.opts.auditSink.Record(ctx, newAuditRecord(ctx, AuditStageAttempt, view, dedupeId, c.opts.clock.Now())); err != nil {
+					return errors.Wrap(err, "failed to record submission attempt")
+				}
+			}
+
+			if c.opts.hooks.BeforeSubmit != nil {
+				if err := c.opts.hooks.BeforeSubmit(ctx, view); err != nil {
+					return err
+				}
+			}
+
+			signature := *tx.payerSignature()
+			progress.report(EarnBatchStageSubmit, signature[:])
+			result, err = tx.submit(ctx, c.internal, il, commitment, dedupeId)
+			result.ID = signature[:]
+
+			if c.opts.hooks.AfterSubmit != nil {
+				c.opts.hooks.AfterSubmit(ctx, view, result, err)
+			}
+
+			if audit {
+				record := newAuditRecord(ctx, AuditStageResult, view, dedupeId, c.opts.clock.Now())
+				record.Outcome, record.Error = auditOutcome(result, err)
+				_ = c.opts.auditSink.Record(ctx, record)
+			}
+
+			if err != nil || result.Errors.TxError != nil {
+				c.subsidizerFailures.record(tx.payer())
 			}
 
-			result, err = c.internal.SubmitSolanaTransaction(ctx, tx, il, commitment, dedupeId)
-			result.ID = tx.Signature()
 			if err != nil {
 				return err
 			}
 
-			if result.Errors.TxError == ErrBadNonce {
+			if result.Errors.TxError == ErrBadNonce || result.Errors.TxError == ErrBlockhashNotFound {
 				// If we encounter a bad nonce, _and_ we've remote signed the transaction,
 				// then we need to clear the state so the next iteration will properly
 				// request a new signature (with the updated block hash)
 				if remoteSigned {
 					result.ID = nil
-					tx.Signatures[0] = solana.Signature{}
+					*tx.payerSignature() = solana.Signature{}
+				}
+
+				// Other chunks sharing an expired blockhash should not use it either.
+				if result.Errors.TxError == ErrBlockhashNotFound && shared != nil {
+					shared.expire(blockhash)
 				}
 
-				return ErrBadNonce
+				// Nor should other clients sharing a cache.
+				c.internal.cache.expireBlockhash(ctx, blockhash)
+
+				return result.Errors.TxError
 			}
 
 			return nil
-		},
-		retry.Limit(c.opts.maxSequenceRetries),
-		retry.RetriableErrors(ErrBadNonce),
+		}),
+		retry.Limit(c.nonceRetries()),
+		retry.RetriableErrors(ErrBadNonce, ErrBlockhashNotFound),
 	)
+	if err == ErrBadNonce || err == ErrBlockhashNotFound {
+		err = &RetryError{Reason: err, Attempts: attempts}
+	}
+
+	// The slot of an existing transaction is not returned on submission, so it
+	// is looked up on a best effort basis.
+	var submitted *AlreadySubmittedError
+	if errors.As(err, &submitted) && len(submitted.TxID) > 0 {
+		result.ID = submitted.TxID
+		if status, statusErr := c.internal.GetTransactionStatus(ctx, submitted.TxID, commitment); statusErr == nil {
+			submitted.Slot = status.Slot
+		}
+	}
 
 	return result, err
 }
@@ -5,18 +5,23 @@ import (
 	"context"
 	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/json"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/google/uuid"
 	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/kin/version"
 	"github.com/kinecosystem/agora-common/solana"
 	"github.com/kinecosystem/agora-common/solana/memo"
 	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/kinecosystem/kin-go/client/computebudget"
 	"github.com/kinecosystem/kin-go/client/testutil"
 	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -31,6 +36,76 @@ func TestClient_GetTransaction(t *testing.T) {
 	// if this changes, we should add more tests here.
 }
 
+func TestClient_GetTransactionRawTransaction(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	_, solanaData, solanaResp := generateV4SolanaPayments(t, false)
+	_, stellarData, stellarResp := generateV4StellarPayments(t, false, version.KinVersion3)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.Gets[string(solanaData.TxID)] = solanaResp
+	env.v4Server.Gets[string(stellarData.TxID)] = stellarResp
+	env.v4Server.Mux.Unlock()
+
+	// The raw transaction is only set if requested.
+	data, err := env.client.GetTransaction(context.Background(), solanaData.TxID)
+	require.NoError(t, err)
+	assert.Nil(t, data.RawTransaction)
+
+	data, err = env.client.GetTransaction(context.Background(), solanaData.TxID, WithRawTransaction())
+	require.NoError(t, err)
+	require.NotNil(t, data.RawTransaction)
+	assert.Equal(t, solanaResp.Item.GetSolanaTransaction().Value, data.RawTransaction.Solana)
+	assert.Nil(t, data.RawTransaction.StellarEnvelope)
+
+	results, err := env.client.GetTransactions(context.Background(), [][]byte{stellarData.TxID}, WithRawTransaction())
+	require.NoError(t, err)
+	data = results[base58.Encode(stellarData.TxID)]
+	require.NotNil(t, data.RawTransaction)
+	assert.Nil(t, data.RawTransaction.Solana)
+	assert.Equal(t, stellarResp.Item.GetStellarTransaction().EnvelopeXdr, data.RawTransaction.StellarEnvelope)
+	assert.Equal(t, stellarResp.Item.GetStellarTransaction().ResultXdr, data.RawTransaction.StellarResult)
+}
+
+func TestClient_GetTransactions(t *testing.T) {
+	env, cleanup := setup(t, WithMaxConcurrency(2))
+	defer cleanup()
+
+	var txIDs [][]byte
+	expected := make(map[string]TransactionData)
+	for i := 0; i < 5; i++ {
+		_, txData, resp := generateV4SolanaPayments(t, i%2 == 0)
+
+		env.v4Server.Mux.Lock()
+		env.v4Server.Gets[string(txData.TxID)] = resp
+		env.v4Server.Mux.Unlock()
+
+		txIDs = append(txIDs, txData.TxID)
+		expected[base58.Encode(txData.TxID)] = txData
+	}
+	unknown := make([]byte, 64)
+	txIDs = append(txIDs, unknown)
+
+	results, err := env.client.GetTransactions(context.Background(), txIDs)
+	require.NoError(t, err)
+	require.Len(t, results, len(txIDs))
+
+	for id, txData := range expected {
+		actual, ok := results[id]
+		require.True(t, ok)
+		assert.Equal(t, txData.TxID, actual.TxID)
+		assert.Equal(t, TransactionStateSuccess, actual.TxState)
+		assert.Len(t, actual.Payments, len(txData.Payments))
+	}
+	assert.Equal(t, TransactionStateUnknown, results[base58.Encode(unknown)].TxState)
+
+	env.v4Server.SetError(errors.New("unexpected"), 100)
+	results, err = env.client.GetTransactions(context.Background(), txIDs)
+	assert.Error(t, err)
+	assert.Nil(t, results)
+}
+
 func TestClient_AppIndexNotSet(t *testing.T) {
 	env, cleanup := setup(t)
 	defer cleanup()
@@ -142,6 +217,38 @@ func TestClient_AccountManagement(t *testing.T) {
 	assert.Equal(t, kin.TransactionTypeNone, m.TransactionType())
 }
 
+func TestClient_ResolveOwner(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	mint, _, subsidizer := setServiceConfigResp(t, env.v4Server, true)
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	tokenAcc, err := token.GetAssociatedAccount(ed25519.PublicKey(priv.Public()), mint)
+	require.NoError(t, err)
+
+	_, _, err = env.client.ResolveOwner(context.Background(), kin.PublicKey(tokenAcc))
+	assert.Equal(t, ErrAccountDoesNotExist, err)
+
+	require.NoError(t, env.client.CreateAccount(context.Background(), priv))
+
+	owner, closeAuthority, err := env.client.ResolveOwner(context.Background(), kin.PublicKey(tokenAcc))
+	require.NoError(t, err)
+	assert.EqualValues(t, priv.Public(), owner)
+	assert.Nil(t, closeAuthority)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.Accounts[base58.Encode(tokenAcc)].CloseAuthority = &commonpbv4.SolanaAccountId{Value: subsidizer}
+	env.v4Server.Mux.Unlock()
+
+	owner, closeAuthority, err = env.client.ResolveOwner(context.Background(), kin.PublicKey(tokenAcc))
+	require.NoError(t, err)
+	assert.EqualValues(t, priv.Public(), owner)
+	assert.EqualValues(t, subsidizer, closeAuthority)
+}
+
 func TestClient_CreateWithoutAttribution(t *testing.T) {
 	env, cleanup := setup(t, WithAppIndex(0))
 	defer cleanup()
@@ -660,6 +767,91 @@ func TestClient_SubmitPayment_SenderCreate(t *testing.T) {
 	}
 }
 
+func TestClient_SubmitPaymentBlockhashNotFound(t *testing.T) {
+	env, cleanup := setup(t, WithMaxNonceRetries(3))
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	setServiceConfigResp(t, env.v4Server, true)
+	for _, acc := range [][]byte{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	p := Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+	}
+
+	blockhashNotFound := func() *transactionpbv4.SubmitTransactionResponse {
+		return &transactionpbv4.SubmitTransactionResponse{
+			Result: transactionpbv4.SubmitTransactionResponse_FAILED,
+			TransactionError: &commonpbv4.TransactionError{
+				Reason: commonpbv4.TransactionError_BAD_NONCE,
+				Raw:    []byte(`"BlockhashNotFound"`),
+			},
+		}
+	}
+
+	// An expired blockhash is retried with a fresh one.
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{blockhashNotFound()}
+	env.v4Server.Mux.Unlock()
+
+	txID, err := env.client.SubmitPayment(context.Background(), p)
+	require.NoError(t, err)
+	assert.NotNil(t, txID)
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.Submits, 2)
+	env.v4Server.Submits = nil
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		blockhashNotFound(),
+		blockhashNotFound(),
+		blockhashNotFound(),
+	}
+	env.v4Server.Mux.Unlock()
+
+	// Once the retries are exhausted, the reason is available in the error chain.
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	require.Error(t, err)
+	assert.Equal(t, ErrBlockhashNotFound, errors.Cause(err))
+
+	retryErr, ok := err.(*RetryError)
+	require.True(t, ok)
+	assert.EqualValues(t, 3, retryErr.Attempts)
+	assert.Len(t, env.v4Server.Submits, 3)
+}
+
+func TestClient_SubmitPaymentInvalidAmount(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	for _, quarks := range []int64{0, -1, MaxSupplyQuarks + 1} {
+		_, err := env.client.SubmitPayment(context.Background(), Payment{
+			Sender:      sender,
+			Destination: dest.Public(),
+			Type:        kin.TransactionTypeSpend,
+			Quarks:      quarks,
+		})
+		assert.Equal(t, ErrInvalidAmount, errors.Cause(err))
+	}
+
+	env.v4Server.Mux.Lock()
+	assert.Empty(t, env.v4Server.Submits)
+	env.v4Server.Mux.Unlock()
+}
+
 func TestClient_SubmitPaymentNoServiceSubsidizer(t *testing.T) {
 	env, cleanup := setup(t)
 	defer cleanup()
@@ -726,6 +918,349 @@ func TestClient_SubmitPaymentNoServiceSubsidizer(t *testing.T) {
 	assert.EqualValues(t, p.Quarks, transferInstr.Amount)
 }
 
+func TestClient_GetHistory(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	account, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	_, err = env.client.GetHistory(context.Background(), account.Public(), nil)
+	assert.Equal(t, ErrAccountDoesNotExist, err)
+
+	start := time.Now().Truncate(time.Second)
+
+	var expected []TransactionData
+	var history []*transactionpbv4.HistoryItem
+	for i := 0; i < 3; i++ {
+		_, data, resp := generateV4SolanaPayments(t, i%2 == 0)
+		resp.Item.Cursor = &transactionpbv4.Cursor{Value: []byte{byte(i)}}
+		resp.Item.TransactionTime, err = ptypes.TimestampProto(start.Add(time.Duration(i) * time.Second))
+		require.NoError(t, err)
+
+		expected = append(expected, data)
+		history = append(history, resp.Item)
+	}
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.History[string(account.Public())] = history
+	env.v4Server.HistoryPageSize = 2
+	env.v4Server.Mux.Unlock()
+
+	for _, descending := range []bool{false, true} {
+		var opts []SolanaOption
+		if descending {
+			opts = append(opts, WithDescending())
+		}
+
+		var items []HistoryItem
+		var cursor []byte
+		for {
+			page, err := env.client.GetHistory(context.Background(), account.Public(), cursor, opts...)
+			require.NoError(t, err)
+			if len(page) == 0 {
+				break
+			}
+
+			items = append(items, page...)
+			cursor = page[len(page)-1].Cursor
+		}
+
+		require.Len(t, items, 3)
+		for i, item := range items {
+			idx := i
+			if descending {
+				idx = 2 - i
+			}
+
+			assert.Equal(t, expected[idx].TxID, item.TxID)
+			assert.Equal(t, TransactionStateSuccess, item.TxState)
+			require.Equal(t, len(expected[idx].Payments), len(item.Payments))
+			for j, p := range expected[idx].Payments {
+				assert.EqualValues(t, p.Destination, item.Payments[j].Destination)
+				assert.EqualValues(t, p.Quarks, item.Payments[j].Quarks)
+				assert.EqualValues(t, p.Memo, item.Payments[j].Memo)
+				assert.True(t, proto.Equal(p.Invoice, item.Payments[j].Invoice))
+			}
+			assert.Equal(t, Cursor{byte(idx)}, item.Cursor)
+			assert.True(t, start.Add(time.Duration(idx)*time.Second).Equal(item.Time))
+		}
+	}
+}
+
+func TestClient_SubmitPaymentAppUserCredentials(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	setServiceConfigResp(t, env.v4Server, true)
+	for _, acc := range [][]byte{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	p := Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+	}
+
+	_, err = env.client.SubmitPayment(context.Background(), p, WithAppUserCredentials("user", "passkey"))
+	require.NoError(t, err)
+
+	ctx := ContextWithAppUserCredentials(context.Background(), "ctx-user", "ctx-passkey")
+	_, err = env.client.SubmitPayment(ctx, p)
+	require.NoError(t, err)
+
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	defer env.v4Server.Mux.Unlock()
+
+	require.Len(t, env.v4Server.SignMetadata, 3)
+	assert.Equal(t, []string{"user"}, env.v4Server.SignMetadata[0].Get(appUserIDHeader))
+	assert.Equal(t, []string{"passkey"}, env.v4Server.SignMetadata[0].Get(appUserPasskeyHeader))
+	assert.Equal(t, []string{"ctx-user"}, env.v4Server.SignMetadata[1].Get(appUserIDHeader))
+	assert.Equal(t, []string{"ctx-passkey"}, env.v4Server.SignMetadata[1].Get(appUserPasskeyHeader))
+	assert.Empty(t, env.v4Server.SignMetadata[2].Get(appUserIDHeader))
+	assert.Empty(t, env.v4Server.SignMetadata[2].Get(appUserPasskeyHeader))
+}
+
+func TestClient_SubmitPaymentRequestID(t *testing.T) {
+	var hookIDs []string
+	var buf bytes.Buffer
+	env, cleanup := setup(t, WithAuditSink(NewJSONLinesAuditSink(&buf)), WithHooks(Hooks{
+		BeforeSubmit: func(ctx context.Context, _ solana.Transaction) error {
+			id, _ := RequestIDFromContext(ctx)
+			hookIDs = append(hookIDs, id)
+			return nil
+		},
+	}))
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	setServiceConfigResp(t, env.v4Server, true)
+	for _, acc := range [][]byte{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	p := Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+	}
+
+	_, err = env.client.SubmitPayment(ContextWithRequestID(context.Background(), "req-1"), p)
+	require.NoError(t, err)
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	require.NoError(t, err)
+
+	// Submissions without a request ID are assigned one.
+	require.Len(t, hookIDs, 2)
+	assert.Equal(t, "req-1", hookIDs[0])
+	assert.NotEmpty(t, hookIDs[1])
+	assert.NotEqual(t, hookIDs[0], hookIDs[1])
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.SubmitMetadata, 2)
+	for i, md := range env.v4Server.SubmitMetadata {
+		assert.Equal(t, []string{hookIDs[i]}, md.Get(requestIDHeader))
+	}
+	env.v4Server.Mux.Unlock()
+
+	var records []AuditRecord
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var record AuditRecord
+		require.NoError(t, decoder.Decode(&record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 4)
+	for i, record := range records {
+		assert.Equal(t, hookIDs[i/2], record.RequestID)
+	}
+}
+
+func TestClient_SubmitPaymentAlreadySubmitted(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	setServiceConfigResp(t, env.v4Server, true)
+	for _, acc := range [][]byte{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	existing := make([]byte, ed25519.SignatureSize)
+	existing[0] = 1
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		{
+			Result:    transactionpbv4.SubmitTransactionResponse_ALREADY_SUBMITTED,
+			Signature: &commonpbv4.TransactionSignature{Value: existing},
+		},
+		{
+			Result:    transactionpbv4.SubmitTransactionResponse_ALREADY_SUBMITTED,
+			Signature: &commonpbv4.TransactionSignature{Value: existing},
+		},
+	}
+	env.v4Server.Gets[string(existing)] = transactionpbv4.GetTransactionResponse{
+		State: transactionpbv4.GetTransactionResponse_SUCCESS,
+		Slot:  10,
+	}
+	env.v4Server.Mux.Unlock()
+
+	txID, err := env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+		DedupeID:    []byte("dedupe"),
+	})
+	assert.Equal(t, ErrAlreadySubmitted, errors.Cause(err))
+	assert.Equal(t, existing, txID)
+
+	var submitted *AlreadySubmittedError
+	require.True(t, errors.As(err, &submitted))
+	assert.Equal(t, existing, submitted.TxID)
+	assert.EqualValues(t, 10, submitted.Slot)
+
+	// Earn batches also identify the existing transaction.
+	result, err := env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns:  []Earn{{Destination: dest.Public(), Quarks: 11}},
+	})
+	assert.True(t, errors.Is(err, ErrAlreadySubmitted))
+	assert.Equal(t, existing, result.TxID)
+}
+
+func TestClient_SubmitPaymentTooLarge(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	setServiceConfigResp(t, env.v4Server, true)
+	for _, acc := range [][]byte{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+		Memo:        strings.Repeat("a", solana.MaxTransactionSize),
+	})
+	assert.Equal(t, ErrTransactionTooLarge, errors.Cause(err))
+
+	var tooLarge *TransactionTooLargeError
+	require.True(t, errors.As(err, &tooLarge))
+	assert.True(t, tooLarge.Overflow > 0)
+	assert.Equal(t, solana.MaxTransactionSize, tooLarge.Size-tooLarge.Overflow)
+
+	// A full batch leaves little room for a memo.
+	batch := EarnBatch{
+		Sender: sender,
+		Memo:   strings.Repeat("a", 600),
+	}
+	for i := 0; i < MaxBatchSize; i++ {
+		dest, err := kin.NewPrivateKey()
+		require.NoError(t, err)
+		batch.Earns = append(batch.Earns, Earn{Destination: dest.Public(), Quarks: 1})
+	}
+	_, err = env.client.SubmitEarnBatch(context.Background(), batch)
+	assert.True(t, errors.Is(err, ErrTransactionTooLarge))
+
+	// Invoices are validated before submission.
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+		Invoice: &commonpb.Invoice{
+			Items: []*commonpb.Invoice_LineItem{{Title: strings.Repeat("a", 129), Amount: 11}},
+		},
+	})
+	assert.Error(t, err)
+
+	env.v4Server.Mux.Lock()
+	assert.Empty(t, env.v4Server.Submits)
+	env.v4Server.Mux.Unlock()
+}
+
+func TestClient_SubmitPaymentComputeBudget(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	setServiceConfigResp(t, env.v4Server, true)
+	for _, acc := range [][]byte{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	opts := []SolanaOption{WithComputeUnitLimit(200000), WithPriorityFee(1000)}
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+	}, opts...)
+	require.NoError(t, err)
+
+	_, err = env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns:  []Earn{{Destination: dest.Public(), Quarks: 11}},
+	}, opts...)
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	defer env.v4Server.Mux.Unlock()
+	require.Len(t, env.v4Server.Submits, 2)
+
+	for _, submit := range env.v4Server.Submits {
+		var tx solana.Transaction
+		require.NoError(t, tx.Unmarshal(submit.Transaction.Value))
+		require.Len(t, tx.Message.Instructions, 4)
+
+		limit, err := computebudget.DecompileSetComputeUnitLimit(tx.Message, 0)
+		require.NoError(t, err)
+		assert.EqualValues(t, 200000, limit.Units)
+		price, err := computebudget.DecompileSetComputeUnitPrice(tx.Message, 1)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1000, price.MicroLamports)
+
+		// The instructions do not affect the parsed payments.
+		_, payments, err := parseTransaction(tx, nil)
+		require.NoError(t, err)
+		require.Len(t, payments, 1)
+		assert.EqualValues(t, 11, payments[0].Quarks)
+		assert.Equal(t, dest.Public(), payments[0].Destination)
+	}
+}
+
 func TestClient_SubmitPaymentKin4AccountResolution(t *testing.T) {
 	env, cleanup := setup(t)
 	defer cleanup()
@@ -994,6 +1529,176 @@ func TestClient_SubmitEarnBatch(t *testing.T) {
 	assert.Nil(t, result.EarnErrors)
 }
 
+func TestClient_SubmitEarnBatchValidation(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	invoice := &commonpb.Invoice{
+		Items: []*commonpb.Invoice_LineItem{
+			{
+				Title:  "test",
+				Amount: 1,
+			},
+		},
+	}
+
+	_, err = env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Memo:   "1-test",
+		Earns: []Earn{
+			{Destination: dest.Public(), Quarks: 1},
+			{Destination: nil, Quarks: 1},
+			{Destination: dest.Public(), Quarks: 0, Invoice: invoice},
+		},
+	})
+	require.Error(t, err)
+
+	validationErr, ok := err.(*BatchValidationError)
+	require.True(t, ok)
+	assert.Equal(t, []BatchValidationProblem{
+		{Index: 1, Field: "Destination", Reason: "must be set"},
+		{Index: 2, Field: "Quarks", Reason: "must be positive"},
+		{Index: 2, Field: "Invoice", Reason: "cannot have invoice set when memo is set"},
+	}, validationErr.Problems)
+	assert.Contains(t, err.Error(), "earns[2].Quarks: must be positive")
+
+	// Duplicate destinations are only rejected if requested.
+	dupEnv, dupCleanup := setup(t, WithRejectDuplicateDestinations())
+	defer dupCleanup()
+	_, err = dupEnv.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns: []Earn{
+			{Destination: dest.Public(), Quarks: 1},
+			{Destination: dest.Public(), Quarks: 2},
+		},
+	})
+	validationErr, ok = err.(*BatchValidationError)
+	require.True(t, ok)
+	assert.Equal(t, []BatchValidationProblem{
+		{Index: 1, Field: "Destination", Reason: "duplicates the destination of earn 0"},
+	}, validationErr.Problems)
+
+	// Earns with and without invoices can't be mixed.
+	other, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	_, err = env.client.SubmitEarnBatches(context.Background(), EarnBatch{
+		Earns: []Earn{
+			{Destination: dest.Public(), Quarks: 1, Invoice: invoice},
+			{Destination: other.Public(), Quarks: 1},
+		},
+	})
+	require.Error(t, err)
+
+	validationErr, ok = err.(*BatchValidationError)
+	require.True(t, ok)
+	assert.Equal(t, []BatchValidationProblem{
+		{Index: -1, Field: "Sender", Reason: "must be a valid private key"},
+		{Index: 1, Field: "Invoice", Reason: "either all or none of the earns should have an invoice set"},
+	}, validationErr.Problems)
+
+	// Amounts must not exceed the supply, individually or in total.
+	_, err = env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns: []Earn{
+			{Destination: dest.Public(), Quarks: MaxSupplyQuarks + 1},
+			{Destination: other.Public(), Quarks: MaxSupplyQuarks},
+			{Destination: sender.Public(), Quarks: MaxSupplyQuarks},
+		},
+	})
+	require.Error(t, err)
+
+	validationErr, ok = err.(*BatchValidationError)
+	require.True(t, ok)
+	assert.Equal(t, []BatchValidationProblem{
+		{Index: 0, Field: "Quarks", Reason: "must not exceed the maximum supply"},
+		{Index: -1, Field: "Earns", Reason: "total quarks must not exceed the maximum supply"},
+	}, validationErr.Problems)
+
+	env.v4Server.Mux.Lock()
+	assert.Empty(t, env.v4Server.Submits)
+	env.v4Server.Mux.Unlock()
+}
+
+func TestClient_SubmitEarnBatchSourceOverride(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	escrowOwner, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	keys := testutil.GenerateSolanaKeys(t, 5)
+	escrow, senderAccount := kin.PublicKey(keys[0]), kin.PublicKey(keys[1])
+	dests := keys[2:]
+
+	batch := EarnBatch{
+		Sender: sender,
+		Earns: []Earn{
+			{Destination: kin.PublicKey(dests[0]), Quarks: 1},
+			{Destination: kin.PublicKey(dests[1]), Quarks: 2, Source: escrow, Owner: escrowOwner},
+			{Destination: kin.PublicKey(dests[2]), Quarks: 3, Source: senderAccount},
+		},
+	}
+	result, err := env.client.SubmitEarnBatch(context.Background(), batch, WithAccountResolution(AccountResolutionExact))
+	require.NoError(t, err)
+	assert.Nil(t, result.TxError)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 1)
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(env.v4Server.Submits[0].Transaction.Value))
+	env.v4Server.Mux.Unlock()
+
+	expected := []struct {
+		source, owner kin.PublicKey
+	}{
+		{sender.Public(), sender.Public()},
+		{escrow, escrowOwner.Public()},
+		{senderAccount, sender.Public()},
+	}
+	for i, e := range expected {
+		transfer, err := token.DecompileTransfer(tx.Message, i+1)
+		require.NoError(t, err)
+		assert.EqualValues(t, e.source, transfer.Source)
+		assert.EqualValues(t, dests[i], transfer.Destination)
+		assert.EqualValues(t, e.owner, transfer.Owner)
+	}
+
+	// The owner of the overridden source signs the transaction.
+	require.Len(t, tx.Signatures, 3)
+	var signed bool
+	for i, key := range tx.Message.Accounts[:3] {
+		if bytes.Equal(key, escrowOwner.Public()) {
+			signed = ed25519.Verify(key, tx.Message.Marshal(), tx.Signatures[i][:])
+		}
+	}
+	assert.True(t, signed)
+
+	_, err = env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns: []Earn{
+			{Destination: kin.PublicKey(dests[0]), Quarks: 1, Owner: escrowOwner},
+			{Destination: kin.PublicKey(dests[1]), Quarks: 1, Source: kin.PublicKey{1}},
+			{Destination: kin.PublicKey(dests[2]), Quarks: 1, Source: escrow, Owner: kin.PrivateKey{1}},
+		},
+	})
+	validationErr, ok := err.(*BatchValidationError)
+	require.True(t, ok)
+	assert.Equal(t, []BatchValidationProblem{
+		{Index: 0, Field: "Owner", Reason: "requires Source to be set"},
+		{Index: 1, Field: "Source", Reason: "must be a valid public key"},
+		{Index: 2, Field: "Owner", Reason: "must be a valid private key"},
+	}, validationErr.Problems)
+}
+
 func TestClient_SubmitEarnBatchNoServiceSubsidizer(t *testing.T) {
 	env, cleanup := setup(t)
 	defer cleanup()
@@ -1236,7 +1941,260 @@ func TestClient_RequestAirdrop(t *testing.T) {
 	tokenAcc, err := token.GetAssociatedAccount(ed25519.PublicKey(priv.Public()), mint)
 	require.NoError(t, err)
 
+	before, err := env.client.GetBalance(context.Background(), kin.PublicKey(tokenAcc), WithAccountResolution(AccountResolutionExact))
+	require.NoError(t, err)
+
 	txID, err = env.client.RequestAirdrop(context.Background(), kin.PublicKey(tokenAcc), 2)
 	require.NoError(t, err)
 	assert.NotNil(t, txID)
+
+	// The test server credits airdrops immediately.
+	balance, err := env.client.GetBalance(context.Background(), kin.PublicKey(tokenAcc), WithAccountResolution(AccountResolutionExact))
+	require.NoError(t, err)
+	assert.EqualValues(t, before+2, balance)
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.Airdrops, 2)
+	env.v4Server.Mux.Unlock()
+}
+
+type sanctionedError struct {
+	list string
+}
+
+func (e *sanctionedError) Error() string {
+	return "sanctioned by " + e.list
+}
+
+func TestClient_PaymentScreener(t *testing.T) {
+	denied, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	var screened []Payment
+	env, cleanup := setup(t, WithPaymentScreener(func(p Payment) error {
+		screened = append(screened, p)
+		if bytes.Equal(p.Destination, denied.Public()) {
+			return &sanctionedError{list: "sdn"}
+		}
+		return nil
+	}))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	for _, k := range []kin.PrivateKey{sender, dest, denied} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), k))
+	}
+
+	p := Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      10,
+	}
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	assert.NoError(t, err)
+
+	p.Destination = denied.Public()
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	assert.Equal(t, ErrPaymentScreened, errors.Cause(err))
+	assert.True(t, errors.Is(err, ErrPaymentScreened))
+	assert.True(t, strings.Contains(err.Error(), "sanctioned"))
+
+	// The screener's error is preserved.
+	var screenedErr *ScreenedError
+	require.True(t, errors.As(err, &screenedErr))
+	var sanctioned *sanctionedError
+	require.True(t, errors.As(err, &sanctioned))
+	assert.Equal(t, "sdn", sanctioned.list)
+	assert.Equal(t, sanctioned, screenedErr.Err)
+
+	_, err = env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns: []Earn{
+			{Destination: dest.Public(), Quarks: 1},
+			{Destination: denied.Public(), Quarks: 2},
+		},
+	})
+	assert.Equal(t, ErrPaymentScreened, errors.Cause(err))
+
+	require.Len(t, screened, 4)
+	assert.Equal(t, kin.TransactionTypeEarn, screened[3].Type)
+	assert.EqualValues(t, 2, screened[3].Quarks)
+	assert.EqualValues(t, sender, screened[3].Sender)
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.Submits, 1)
+	env.v4Server.Mux.Unlock()
+}
+
+func TestInvoiceListHash(t *testing.T) {
+	for i := 1; i <= 3; i++ {
+		_, il := generateInvoiceList(t, i)
+
+		raw, err := proto.Marshal(il)
+		require.NoError(t, err)
+
+		// Hash twice to ensure pooled buffers are reset between uses.
+		for j := 0; j < 2; j++ {
+			h, err := invoiceListHash(il)
+			require.NoError(t, err)
+			assert.Equal(t, sha256.Sum224(raw), h)
+		}
+	}
+}
+
+func benchmarkBuildSetup(b *testing.B) (*client, *transactionpbv4.GetServiceConfigResponse) {
+	subsidizer, err := kin.NewPrivateKey()
+	require.NoError(b, err)
+
+	c := &client{opts: clientOpts{appIndex: 1}}
+	config := &transactionpbv4.GetServiceConfigResponse{
+		SubsidizerAccount: &commonpbv4.SolanaAccountId{Value: subsidizer.Public()},
+	}
+	return c, config
+}
+
+func benchmarkInvoice() *commonpb.Invoice {
+	return &commonpb.Invoice{
+		Items: []*commonpb.Invoice_LineItem{
+			{
+				Title:       "Item",
+				Description: "Description",
+				Amount:      10,
+				Sku:         []byte("sku"),
+			},
+		},
+	}
+}
+
+func BenchmarkClient_BuildPayment(b *testing.B) {
+	c, config := benchmarkBuildSetup(b)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(b, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(b, err)
+
+	p := payment{
+		Payment: Payment{
+			Sender:      sender,
+			Destination: dest.Public(),
+			Type:        kin.TransactionTypeSpend,
+			Quarks:      10,
+			Invoice:     benchmarkInvoice(),
+		},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := c.buildPaymentTx(p, config, nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClient_BuildEarnBatch(b *testing.B) {
+	c, config := benchmarkBuildSetup(b)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(b, err)
+
+	batch := EarnBatch{
+		Sender: sender,
+		Earns:  make([]Earn, MaxBatchSize),
+	}
+	for i := range batch.Earns {
+		dest, err := kin.NewPrivateKey()
+		require.NoError(b, err)
+
+		batch.Earns[i] = Earn{
+			Destination: dest.Public(),
+			Quarks:      10,
+			Invoice:     benchmarkInvoice(),
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := c.buildEarnBatchTx(batch, config, nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClient_SubmitPayment(b *testing.B) {
+	env, cleanup := setup(b)
+	defer cleanup()
+
+	setServiceConfigResp(b, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(b, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(b, err)
+	for _, acc := range [][]byte{sender, dest} {
+		require.NoError(b, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	p := Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      1,
+		Invoice:     benchmarkInvoice(),
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := env.client.SubmitPayment(context.Background(), p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClient_SubmitEarnBatch(b *testing.B) {
+	env, cleanup := setup(b)
+	defer cleanup()
+
+	setServiceConfigResp(b, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(b, err)
+	require.NoError(b, env.client.CreateAccount(context.Background(), sender))
+
+	batch := EarnBatch{
+		Sender: sender,
+		Earns:  make([]Earn, MaxBatchSize),
+	}
+	for i := range batch.Earns {
+		dest, err := kin.NewPrivateKey()
+		require.NoError(b, err)
+		require.NoError(b, env.client.CreateAccount(context.Background(), dest))
+
+		batch.Earns[i] = Earn{
+			Destination: dest.Public(),
+			Quarks:      1,
+			Invoice:     benchmarkInvoice(),
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result, err := env.client.SubmitEarnBatch(context.Background(), batch)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if result.TxError != nil {
+			b.Fatal(result.TxError)
+		}
+	}
 }
@@ -0,0 +1,1219 @@
+// Package clientfake provides an in-memory implementation of client.Client,
+// allowing code that depends on a client to be unit tested without an Agora
+// server.
+//
+// The fake models a simplified Kin 4 ledger: each account has a single token
+// account, whose address is the account's public key. Payments move quarks
+// between accounts atomically, and every submission is recorded as a
+// transaction that can be queried with GetTransaction and GetHistory.
+//
+// SolanaOptions are opaque outside of the client package, so they are ignored
+// by the fake. In particular, history is always returned oldest first.
+package clientfake
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/kin/version"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// DefaultRentExemption is the default minimum balance, in lamports, returned by
+// GetMinimumBalanceForRentExemption. It is the rent exemption of a token
+// account on mainnet.
+const DefaultRentExemption = 2039280
+
+type opts struct {
+	historyPageSize      int
+	rentExemption        uint64
+	subsidizer           kin.PublicKey
+	subsidizerLamports   uint64
+	now                  func() time.Time
+	allowNegativeBalance bool
+}
+
+// Option configures a Fake.
+type Option func(*opts)
+
+// WithHistoryPageSize specifies the maximum number of items returned by a
+// single call to GetHistory. Defaults to 100.
+func WithHistoryPageSize(n int) Option {
+	return func(o *opts) {
+		o.historyPageSize = n
+	}
+}
+
+// WithRentExemption specifies the minimum balance, in lamports, returned by
+// GetMinimumBalanceForRentExemption. Defaults to DefaultRentExemption.
+func WithRentExemption(lamports uint64) Option {
+	return func(o *opts) {
+		o.rentExemption = lamports
+	}
+}
+
+// WithSubsidizer specifies the subsidizer, and its SOL balance, reported by
+// GetSubsidizerStatus and WatchSubsidizer.
+func WithSubsidizer(subsidizer kin.PublicKey, lamports uint64) Option {
+	return func(o *opts) {
+		o.subsidizer = subsidizer
+		o.subsidizerLamports = lamports
+	}
+}
+
+// WithNow specifies the function used to timestamp transactions. Defaults to
+// time.Now.
+func WithNow(now func() time.Time) Option {
+	return func(o *opts) {
+		o.now = now
+	}
+}
+
+// Fake is an in-memory client.Client. It is safe for concurrent use.
+type Fake struct {
+	opts opts
+
+	mu       sync.Mutex
+	balances map[string]int64
+	lamports map[string]uint64
+	txs      map[string]client.TransactionData
+	times    map[string]time.Time
+	history  map[string][][]byte
+	dedupe   map[string][]byte
+	errors   map[string][]error
+	txCount  uint64
+	closed   bool
+
+	// updated is closed, and replaced, whenever a transaction is recorded.
+	updated chan struct{}
+
+	// kinVersion is the blockchain version, and versionUpdated is closed, and
+	// replaced, whenever it is set.
+	kinVersion     version.KinVersion
+	versionUpdated chan struct{}
+}
+
+var _ client.Client = (*Fake)(nil)
+
+// New returns a new Fake with no accounts.
+func New(options ...Option) *Fake {
+	f := &Fake{
+		opts: opts{
+			historyPageSize: 100,
+			rentExemption:   DefaultRentExemption,
+			now:             time.Now,
+		},
+		balances: make(map[string]int64),
+		lamports: make(map[string]uint64),
+		txs:      make(map[string]client.TransactionData),
+		times:    make(map[string]time.Time),
+		history:  make(map[string][][]byte),
+		dedupe:   make(map[string][]byte),
+		errors:   make(map[string][]error),
+		updated:  make(chan struct{}),
+
+		kinVersion:     version.KinVersion4,
+		versionUpdated: make(chan struct{}),
+	}
+	for _, o := range options {
+		o(&f.opts)
+	}
+	if f.opts.subsidizer != nil {
+		f.lamports[string(f.opts.subsidizer)] = f.opts.subsidizerLamports
+	}
+
+	return f
+}
+
+// TxID returns the ID of the nth (1-based) transaction submitted to a Fake.
+// IDs are deterministic, so tests can predict them.
+func TxID(n uint64) []byte {
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], n)
+
+	// Transaction IDs are the size of a signature.
+	first := sha256.Sum256(append([]byte("clientfake:0:"), seed[:]...))
+	second := sha256.Sum256(append([]byte("clientfake:1:"), seed[:]...))
+	return append(first[:], second[:]...)
+}
+
+// SetBalance sets the balance of an account, creating it if it does not exist.
+func (f *Fake) SetBalance(account kin.PublicKey, quarks int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.balances[string(account)] = quarks
+}
+
+// SetSolBalance sets the SOL balance of an account, in lamports.
+func (f *Fake) SetSolBalance(account kin.PublicKey, lamports uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lamports[string(account)] = lamports
+}
+
+// QueueError queues an error to be returned by the next call to the specified
+// Client method, such as "SubmitPayment". Errors are returned in the order they
+// were queued, one per call.
+func (f *Fake) QueueError(method string, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.errors[method] = append(f.errors[method], errs...)
+}
+
+// Transactions returns every transaction submitted to the Fake, in the order
+// they were submitted.
+func (f *Fake) Transactions() []client.TransactionData {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	txs := make([]client.TransactionData, f.txCount)
+	for i := range txs {
+		txs[i] = f.txs[string(TxID(uint64(i+1)))]
+	}
+	return txs
+}
+
+// takeError returns the next queued error for method, if any. It must be called
+// with the lock held.
+func (f *Fake) takeError(method string) error {
+	errs := f.errors[method]
+	if len(errs) == 0 {
+		return nil
+	}
+
+	f.errors[method] = errs[1:]
+	return errs[0]
+}
+
+// begin returns the next queued error for method, or client.ErrClientClosed if
+// the fake is closed and the method submits transactions. It must be called
+// with the lock held.
+func (f *Fake) begin(method string, submits bool) error {
+	if err := f.takeError(method); err != nil {
+		return err
+	}
+	if submits && f.closed {
+		return client.ErrClientClosed
+	}
+	return nil
+}
+
+// record records a transaction with the specified payments, returning its ID.
+// It must be called with the lock held.
+func (f *Fake) record(payments []client.ReadOnlyPayment, txErr error, accounts ...kin.PublicKey) []byte {
+	f.txCount++
+	txID := TxID(f.txCount)
+
+	data := client.TransactionData{
+		TxID:      txID,
+		TxState:   client.TransactionStateSuccess,
+		Payments:  payments,
+		BlockTime: f.opts.now(),
+	}
+	if txErr != nil {
+		data.TxState = client.TransactionStateFailed
+		data.Errors.TxError = txErr
+	}
+
+	f.txs[string(txID)] = data
+	f.times[string(txID)] = data.BlockTime
+
+	seen := make(map[string]struct{})
+	for _, p := range payments {
+		accounts = append(accounts, p.Sender, p.Destination)
+	}
+	for _, a := range accounts {
+		if _, ok := seen[string(a)]; ok {
+			continue
+		}
+		seen[string(a)] = struct{}{}
+		f.history[string(a)] = append(f.history[string(a)], txID)
+	}
+
+	close(f.updated)
+	f.updated = make(chan struct{})
+
+	return txID
+}
+
+func (f *Fake) CreateAccount(_ context.Context, key kin.PrivateKey, _ ...client.SolanaOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("CreateAccount", true); err != nil {
+		return err
+	}
+	if _, ok := f.balances[string(key.Public())]; ok {
+		return client.ErrAccountExists
+	}
+
+	f.balances[string(key.Public())] = 0
+	f.record(nil, nil, key.Public())
+	return nil
+}
+
+func (f *Fake) CreateAccounts(_ context.Context, keys []kin.PrivateKey, _ ...client.SolanaOption) ([]client.CreateAccountsResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("CreateAccounts", true); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	owners := make([]kin.PublicKey, len(keys))
+	seen := make(map[string]struct{}, len(keys))
+	for i, k := range keys {
+		if _, ok := seen[string(k.Public())]; ok {
+			return nil, errors.Errorf("duplicate key: %s", k.Public().Base58())
+		}
+		seen[string(k.Public())] = struct{}{}
+		owners[i] = k.Public()
+	}
+
+	var txErr error
+	for _, o := range owners {
+		if _, ok := f.balances[string(o)]; ok {
+			txErr = client.ErrAccountExists
+		}
+	}
+	if txErr == nil {
+		for _, o := range owners {
+			f.balances[string(o)] = 0
+		}
+	}
+
+	txID := f.record(nil, txErr, owners...)
+	return []client.CreateAccountsResult{{TxID: txID, Owners: owners, TxError: txErr}}, nil
+}
+
+func (f *Fake) GetBalance(_ context.Context, account kin.PublicKey, _ ...client.SolanaOption) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("GetBalance", false); err != nil {
+		return 0, err
+	}
+
+	balance, ok := f.balances[string(account)]
+	if !ok {
+		return 0, client.ErrAccountDoesNotExist
+	}
+	return balance, nil
+}
+
+// GetBalanceDetail returns the balance of account as that of a single token
+// account, which is owned by account.
+func (f *Fake) GetBalanceDetail(_ context.Context, account kin.PublicKey, _ ...client.SolanaOption) (client.BalanceDetail, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("GetBalanceDetail", false); err != nil {
+		return client.BalanceDetail{}, err
+	}
+
+	balance, ok := f.balances[string(account)]
+	if !ok {
+		return client.BalanceDetail{}, client.ErrAccountDoesNotExist
+	}
+	return client.BalanceDetail{
+		Quarks: balance,
+		TokenAccounts: []client.TokenAccountBalance{
+			{Account: account, Quarks: balance, Owner: account},
+		},
+	}, nil
+}
+
+func (f *Fake) ResolveTokenAccounts(_ context.Context, account kin.PublicKey) ([]kin.PublicKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("ResolveTokenAccounts", false); err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.balances[string(account)]; !ok {
+		return nil, nil
+	}
+	return []kin.PublicKey{account}, nil
+}
+
+func (f *Fake) ResolveTokenAccountsBatch(_ context.Context, accounts []kin.PublicKey) (map[string][]kin.PublicKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("ResolveTokenAccountsBatch", false); err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[string][]kin.PublicKey, len(accounts))
+	for _, a := range accounts {
+		resolved[a.Base58()] = nil
+		if _, ok := f.balances[string(a)]; ok {
+			resolved[a.Base58()] = []kin.PublicKey{a}
+		}
+	}
+	return resolved, nil
+}
+
+// WarmCaches does nothing, since the fake does not cache.
+func (f *Fake) WarmCaches(_ context.Context, _ []kin.PublicKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.begin("WarmCaches", false)
+}
+
+func (f *Fake) ResolveOwner(_ context.Context, tokenAccount kin.PublicKey, _ ...client.SolanaOption) (owner, closeAuthority kin.PublicKey, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("ResolveOwner", false); err != nil {
+		return nil, nil, err
+	}
+
+	if _, ok := f.balances[string(tokenAccount)]; !ok {
+		return nil, nil, client.ErrAccountDoesNotExist
+	}
+	return tokenAccount, nil, nil
+}
+
+// MergeTokenAccounts implements client.Client.MergeTokenAccounts. Since each
+// account in the fake has a single token account, there is never anything to
+// merge, and a nil transaction ID is returned.
+func (f *Fake) MergeTokenAccounts(_ context.Context, account kin.PrivateKey, _ bool, _ ...client.SolanaOption) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("MergeTokenAccounts", true); err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.balances[string(account.Public())]; !ok {
+		return nil, client.ErrAccountDoesNotExist
+	}
+	return nil, nil
+}
+
+// SweepAccount implements client.Client.SweepAccount. The balance of the
+// account is transferred to destination in a single transaction, and the
+// account is closed.
+func (f *Fake) SweepAccount(_ context.Context, account kin.PrivateKey, destination kin.PublicKey, _ ...client.SolanaOption) ([][]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("SweepAccount", true); err != nil {
+		return nil, err
+	}
+
+	balance, ok := f.balances[string(account.Public())]
+	if !ok {
+		return nil, client.ErrAccountDoesNotExist
+	}
+
+	var payments []client.ReadOnlyPayment
+	if balance > 0 {
+		payments = append(payments, client.ReadOnlyPayment{
+			Sender:      account.Public(),
+			Destination: destination,
+			Type:        kin.TransactionTypeNone,
+			Quarks:      balance,
+		})
+	}
+
+	if err := f.transfer(payments); err != nil {
+		return [][]byte{f.record(payments, err, account.Public())}, err
+	}
+	delete(f.balances, string(account.Public()))
+	return [][]byte{f.record(payments, nil, account.Public())}, nil
+}
+
+func (f *Fake) GetTransaction(_ context.Context, txID []byte, _ ...client.SolanaOption) (client.TransactionData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("GetTransaction", false); err != nil {
+		return client.TransactionData{}, err
+	}
+
+	data, ok := f.txs[string(txID)]
+	if !ok {
+		return client.TransactionData{}, client.ErrTransactionNotFound
+	}
+	return data, nil
+}
+
+func (f *Fake) GetTransactionStatus(_ context.Context, txID []byte, _ ...client.SolanaOption) (client.TransactionStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("GetTransactionStatus", false); err != nil {
+		return client.TransactionStatus{}, err
+	}
+
+	data, ok := f.txs[string(txID)]
+	if !ok {
+		return client.TransactionStatus{}, client.ErrTransactionNotFound
+	}
+	return client.TransactionStatus{
+		TxID:    data.TxID,
+		TxState: data.TxState,
+		TxError: data.Errors.TxError,
+	}, nil
+}
+
+func (f *Fake) GetReceipt(_ context.Context, txID []byte, _ ...client.SolanaOption) (client.Receipt, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("GetReceipt", false); err != nil {
+		return client.Receipt{}, err
+	}
+
+	data, ok := f.txs[string(txID)]
+	if !ok {
+		return client.Receipt{}, client.ErrTransactionNotFound
+	}
+	if data.TxState == client.TransactionStateFailed {
+		return client.Receipt{}, errors.Wrap(data.Errors.TxError, "transaction failed")
+	}
+
+	blockTime := f.times[string(txID)].UTC()
+	receipt := client.Receipt{
+		TxID:       base58.Encode(txID),
+		BlockTime:  &blockTime,
+		Commitment: "max",
+		Payments:   make([]client.ReceiptPayment, len(data.Payments)),
+	}
+	for i, p := range data.Payments {
+		receipt.Payments[i] = client.ReceiptPayment{
+			Sender:      p.Sender.Base58(),
+			Destination: p.Destination.Base58(),
+			Type:        transactionTypeName(p.Type),
+			AppIndex:    p.AppIndex,
+			Quarks:      p.Quarks,
+			Memo:        p.Memo,
+		}
+		if p.Invoice != nil {
+			invoice := &client.ReceiptInvoice{}
+			for _, item := range p.Invoice.Items {
+				invoice.Items = append(invoice.Items, client.ReceiptLineItem{
+					Title:       item.Title,
+					Description: item.Description,
+					Amount:      item.Amount,
+					SKU:         item.Sku,
+				})
+			}
+			receipt.Payments[i].Invoice = invoice
+		}
+	}
+
+	return receipt, nil
+}
+
+func (f *Fake) GetTransactions(ctx context.Context, txIDs [][]byte, opts ...client.SolanaOption) (map[string]client.TransactionData, error) {
+	f.mu.Lock()
+	err := f.begin("GetTransactions", false)
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]client.TransactionData, len(txIDs))
+	for _, txID := range txIDs {
+		data, err := f.GetTransaction(ctx, txID, opts...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get transaction %s", base58.Encode(txID))
+		}
+		result[base58.Encode(txID)] = data
+	}
+	return result, nil
+}
+
+func (f *Fake) GetHistory(_ context.Context, account kin.PublicKey, cursor client.Cursor, _ ...client.SolanaOption) ([]client.HistoryItem, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("GetHistory", false); err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.balances[string(account)]; !ok {
+		return nil, client.ErrAccountDoesNotExist
+	}
+
+	return f.historyAfter(account, cursor, f.opts.historyPageSize), nil
+}
+
+// historyAfter returns up to limit items of the history of account after
+// cursor. It must be called with the lock held.
+func (f *Fake) historyAfter(account kin.PublicKey, cursor client.Cursor, limit int) []client.HistoryItem {
+	txIDs := f.history[string(account)]
+	if cursor != nil {
+		for i, txID := range txIDs {
+			if string(txID) == string(cursor) {
+				txIDs = txIDs[i+1:]
+				break
+			}
+		}
+	}
+	if len(txIDs) > limit {
+		txIDs = txIDs[:limit]
+	}
+
+	items := make([]client.HistoryItem, len(txIDs))
+	for i, txID := range txIDs {
+		items[i] = client.HistoryItem{
+			TransactionData: f.txs[string(txID)],
+			Cursor:          txID,
+			Time:            f.times[string(txID)],
+		}
+	}
+	return items
+}
+
+// Subscribe replays the history of account after sinceCursor, and then streams
+// the transactions recorded by the Fake. As with the real client, the Cursor of
+// live items is not set.
+func (f *Fake) Subscribe(ctx context.Context, account kin.PublicKey, sinceCursor client.Cursor, _ ...client.SolanaOption) (<-chan client.SubscriptionResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("Subscribe", false); err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.balances[string(account)]; !ok {
+		return nil, client.ErrAccountDoesNotExist
+	}
+
+	ch := make(chan client.SubscriptionResult)
+	go func() {
+		defer close(ch)
+
+		cursor := sinceCursor
+		live := false
+		for {
+			f.mu.Lock()
+			items := f.historyAfter(account, cursor, len(f.history[string(account)]))
+			updated := f.updated
+			f.mu.Unlock()
+
+			for _, item := range items {
+				cursor = item.Cursor
+				if live {
+					item.Cursor = nil
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case ch <- client.SubscriptionResult{Item: item, Live: live}:
+				}
+			}
+
+			live = true
+			select {
+			case <-ctx.Done():
+				return
+			case <-updated:
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (f *Fake) SubmitPayment(_ context.Context, payment client.Payment, _ ...client.SolanaOption) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("SubmitPayment", true); err != nil {
+		return nil, err
+	}
+	return f.submitPayment(payment)
+}
+
+// FulfillPaymentRequest verifies r as of the fake's current time, and pays it
+// from sender.
+func (f *Fake) FulfillPaymentRequest(_ context.Context, sender kin.PrivateKey, r client.PaymentRequest, _ ...client.SolanaOption) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("FulfillPaymentRequest", true); err != nil {
+		return nil, err
+	}
+	if err := r.Verify(f.opts.now()); err != nil {
+		return nil, err
+	}
+
+	payment, err := r.ToPayment(sender)
+	if err != nil {
+		return nil, err
+	}
+	return f.submitPayment(payment)
+}
+
+// Refund refunds the payment at index of the fake transaction originalTxID
+// from refunder, which must be the destination of the payment.
+func (f *Fake) Refund(_ context.Context, refunder kin.PrivateKey, originalTxID []byte, index int, _ ...client.SolanaOption) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("Refund", true); err != nil {
+		return nil, err
+	}
+
+	original, ok := f.txs[string(originalTxID)]
+	if !ok {
+		return nil, client.ErrTransactionNotFound
+	}
+	payment, err := client.RefundPayment(refunder, original, index)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(original.Payments[index].Destination, refunder.Public()) {
+		return nil, errors.New("refunder does not own the destination of the payment")
+	}
+
+	// Unlike SubmitPayment, a refund that was already submitted is reported,
+	// as it is by Agora.
+	if txID, ok := f.dedupe[string(payment.DedupeID)]; ok {
+		return txID, &client.AlreadySubmittedError{TxID: txID}
+	}
+	return f.submitPayment(payment)
+}
+
+// submitPayment submits payment. It must be called with the lock held.
+func (f *Fake) submitPayment(payment client.Payment) ([]byte, error) {
+	if payment.Quarks <= 0 || payment.Quarks > client.MaxSupplyQuarks {
+		return nil, errors.Wrapf(client.ErrInvalidAmount, "invalid quarks: %d", payment.Quarks)
+	}
+	if txID, ok := f.dedupe[string(payment.DedupeID)]; ok && payment.DedupeID != nil {
+		return txID, nil
+	}
+
+	p := client.ReadOnlyPayment{
+		Sender:      payment.Sender.Public(),
+		Destination: payment.Destination,
+		Type:        payment.Type,
+		Quarks:      payment.Quarks,
+		Invoice:     payment.Invoice,
+		Memo:        payment.Memo,
+	}
+
+	txErr := f.transfer([]client.ReadOnlyPayment{p})
+	txID := f.record([]client.ReadOnlyPayment{p}, txErr)
+	if payment.DedupeID != nil {
+		f.dedupe[string(payment.DedupeID)] = txID
+	}
+
+	return txID, txErr
+}
+
+// BuildApproval builds an approval for payment, which is paid for by the
+// subsidizer specified with WithSubsidizer, if any. Payments without a text memo
+// use a memo with an app index of 0.
+func (f *Fake) BuildApproval(_ context.Context, payment client.ReadOnlyPayment, _ ...client.SolanaOption) (client.Approval, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("BuildApproval", false); err != nil {
+		return client.Approval{}, err
+	}
+	if payment.Quarks <= 0 || payment.Quarks > client.MaxSupplyQuarks {
+		return client.Approval{}, errors.Wrapf(client.ErrInvalidAmount, "invalid quarks: %d", payment.Quarks)
+	}
+
+	var memoInstruction solana.Instruction
+	if payment.Memo != "" {
+		memoInstruction = memo.Instruction(payment.Memo)
+	} else {
+		var fk [sha256.Size224]byte
+		if payment.Invoice != nil {
+			b, err := proto.Marshal(&commonpb.InvoiceList{Invoices: []*commonpb.Invoice{payment.Invoice}})
+			if err != nil {
+				return client.Approval{}, errors.Wrap(err, "failed to marshal invoice list")
+			}
+			fk = sha256.Sum224(b)
+		}
+
+		m, err := kin.NewMemo(1, payment.Type, 0, fk[:])
+		if err != nil {
+			return client.Approval{}, errors.Wrap(err, "failed to create memo")
+		}
+		memoInstruction = memo.Instruction(base64.StdEncoding.EncodeToString(m[:]))
+	}
+	payment.AppIndex = 0
+
+	subsidizer := f.opts.subsidizer
+	if subsidizer == nil {
+		h := sha256.Sum256([]byte("clientfake:subsidizer"))
+		subsidizer = h[:]
+	}
+
+	tx := solana.NewTransaction(
+		ed25519.PublicKey(subsidizer),
+		memoInstruction,
+		token.Transfer(
+			ed25519.PublicKey(payment.Sender),
+			ed25519.PublicKey(payment.Destination),
+			ed25519.PublicKey(payment.Sender),
+			uint64(payment.Quarks),
+		),
+	)
+
+	return client.Approval{
+		Payment:     payment,
+		Transaction: tx.Marshal(),
+		Expires:     f.opts.now().Add(client.DefaultApprovalTTL),
+	}, nil
+}
+
+// SubmitApproval verifies approval, and submits its payment as with
+// SubmitPayment.
+func (f *Fake) SubmitApproval(_ context.Context, approval client.Approval, _ ...client.SolanaOption) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("SubmitApproval", true); err != nil {
+		return nil, err
+	}
+	if err := approval.Verify(f.opts.now()); err != nil {
+		return nil, err
+	}
+
+	payments := []client.ReadOnlyPayment{approval.Payment}
+	txErr := f.transfer(payments)
+	return f.record(payments, txErr), txErr
+}
+
+// transfer applies the payments, if the accounts exist and have sufficient
+// balances. Otherwise, none are applied. It must be called with the lock held.
+func (f *Fake) transfer(payments []client.ReadOnlyPayment) error {
+	balances := make(map[string]int64)
+	for _, p := range payments {
+		for _, a := range []kin.PublicKey{p.Sender, p.Destination} {
+			if _, ok := balances[string(a)]; ok {
+				continue
+			}
+
+			balance, ok := f.balances[string(a)]
+			if !ok {
+				return client.ErrAccountDoesNotExist
+			}
+			balances[string(a)] = balance
+		}
+
+		balances[string(p.Sender)] -= p.Quarks
+		balances[string(p.Destination)] += p.Quarks
+		if balances[string(p.Sender)] < 0 {
+			return client.ErrInsufficientBalance
+		}
+	}
+
+	for a, balance := range balances {
+		f.balances[a] = balance
+	}
+	return nil
+}
+
+func (f *Fake) SubmitEarnBatch(_ context.Context, batch client.EarnBatch, _ ...client.SolanaOption) (client.EarnBatchResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("SubmitEarnBatch", true); err != nil {
+		return client.EarnBatchResult{}, err
+	}
+	if len(batch.Earns) == 0 {
+		return client.EarnBatchResult{}, errors.New("earn batch must contain at least 1 earn")
+	}
+	if len(batch.Earns) > client.MaxBatchSize {
+		return client.EarnBatchResult{}, errors.Errorf("earn batch must not contain more than %d earns", client.MaxBatchSize)
+	}
+
+	return f.submitEarnBatch(batch), nil
+}
+
+// SubmitSplitPayment records a spend transaction containing a payment to each
+// split, divided as by client.SplitAmounts.
+func (f *Fake) SubmitSplitPayment(_ context.Context, sender kin.PrivateKey, total int64, splits []client.Split, _ ...client.SolanaOption) (client.EarnBatchResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("SubmitSplitPayment", true); err != nil {
+		return client.EarnBatchResult{}, err
+	}
+
+	amounts, err := client.SplitAmounts(total, splits)
+	if err != nil {
+		return client.EarnBatchResult{}, err
+	}
+
+	payments := make([]client.ReadOnlyPayment, len(splits))
+	for i, s := range splits {
+		payments[i] = client.ReadOnlyPayment{
+			Sender:      sender.Public(),
+			Destination: s.Destination,
+			Type:        kin.TransactionTypeSpend,
+			Quarks:      amounts[i],
+			Invoice:     s.Invoice,
+		}
+	}
+
+	txErr := f.transfer(payments)
+	return client.EarnBatchResult{TxID: f.record(payments, txErr), TxError: txErr}, nil
+}
+
+// submitEarnBatch submits a batch of at most client.MaxBatchSize earns. It must
+// be called with the lock held.
+func (f *Fake) submitEarnBatch(batch client.EarnBatch) client.EarnBatchResult {
+	if txID, ok := f.dedupe[string(batch.DedupeID)]; ok && batch.DedupeID != nil {
+		data := f.txs[string(txID)]
+		return client.EarnBatchResult{TxID: txID, TxError: data.Errors.TxError}
+	}
+
+	payments := make([]client.ReadOnlyPayment, len(batch.Earns))
+	for i, earn := range batch.Earns {
+		sender := batch.Sender.Public()
+		if earn.Source != nil {
+			sender = earn.Source
+		}
+		payments[i] = client.ReadOnlyPayment{
+			Sender:      sender,
+			Destination: earn.Destination,
+			Type:        kin.TransactionTypeEarn,
+			Quarks:      earn.Quarks,
+			Invoice:     earn.Invoice,
+			Memo:        batch.Memo,
+		}
+	}
+
+	txErr := f.transfer(payments)
+	txID := f.record(payments, txErr)
+	if batch.DedupeID != nil {
+		f.dedupe[string(batch.DedupeID)] = txID
+	}
+
+	return client.EarnBatchResult{TxID: txID, TxError: txErr}
+}
+
+func (f *Fake) SubmitEarnBatches(_ context.Context, batch client.EarnBatch, _ ...client.SolanaOption) ([]client.EarnBatchResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("SubmitEarnBatches", true); err != nil {
+		return nil, err
+	}
+	if len(batch.Earns) == 0 {
+		return nil, errors.New("earn batch must contain at least 1 earn")
+	}
+
+	return f.submitEarnBatches(batch), nil
+}
+
+// submitEarnBatches splits batch into chunks, submitting each until one fails.
+// It must be called with the lock held.
+func (f *Fake) submitEarnBatches(batch client.EarnBatch) []client.EarnBatchResult {
+	var results []client.EarnBatchResult
+	var failed bool
+	for start := 0; start < len(batch.Earns); start += client.MaxBatchSize {
+		end := start + client.MaxBatchSize
+		if end > len(batch.Earns) {
+			end = len(batch.Earns)
+		}
+
+		if failed {
+			results = append(results, client.EarnBatchResult{TxError: client.ErrEarnBatchAborted})
+			continue
+		}
+
+		chunk := batch
+		chunk.Earns = batch.Earns[start:end]
+		if batch.DedupeID != nil {
+			chunk.DedupeID = chunkDedupeID(batch.DedupeID, start/client.MaxBatchSize)
+		}
+
+		result := f.submitEarnBatch(chunk)
+		results = append(results, result)
+		failed = result.TxError != nil
+	}
+
+	return results
+}
+
+func (f *Fake) SubmitEarnBatchAsync(_ context.Context, batch client.EarnBatch, _ ...client.SolanaOption) (<-chan client.EarnChunkResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("SubmitEarnBatchAsync", true); err != nil {
+		return nil, err
+	}
+	if len(batch.Earns) == 0 {
+		return nil, errors.New("earn batch must contain at least 1 earn")
+	}
+
+	results := f.submitEarnBatches(batch)
+	ch := make(chan client.EarnChunkResult, len(results))
+	for i, r := range results {
+		ch <- client.EarnChunkResult{Chunk: i, EarnBatchResult: r}
+	}
+	close(ch)
+
+	return ch, nil
+}
+
+func (f *Fake) GetMinimumBalanceForRentExemption(_ context.Context, _ uint64) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("GetMinimumBalanceForRentExemption", false); err != nil {
+		return 0, err
+	}
+	return f.opts.rentExemption, nil
+}
+
+func (f *Fake) EstimateAccountCreationCost(_ context.Context, count int) (client.AccountCreationCost, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("EstimateAccountCreationCost", false); err != nil {
+		return client.AccountCreationCost{}, err
+	}
+	if count < 0 {
+		return client.AccountCreationCost{}, errors.Errorf("invalid count: %d", count)
+	}
+
+	return client.AccountCreationCost{
+		RentLamports: uint64(count) * f.opts.rentExemption,
+		FeeLamports:  uint64(count) * 2 * client.LamportsPerSignature,
+	}, nil
+}
+
+func (f *Fake) GetSubsidizerStatus(_ context.Context, _ ...client.SolanaOption) (client.SubsidizerStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("GetSubsidizerStatus", false); err != nil {
+		return client.SubsidizerStatus{}, err
+	}
+	return f.subsidizerStatus(), nil
+}
+
+// subsidizerStatus must be called with the lock held.
+func (f *Fake) subsidizerStatus() client.SubsidizerStatus {
+	lamports := f.lamports[string(f.opts.subsidizer)]
+	return client.SubsidizerStatus{
+		Subsidizer:            f.opts.subsidizer,
+		Lamports:              lamports,
+		RemainingTransactions: lamports / (2 * client.LamportsPerSignature),
+	}
+}
+
+// WatchSubsidizer implements client.Client.WatchSubsidizer. The balance of the
+// fake subsidizer is only checked once, so at most one alert is sent.
+func (f *Fake) WatchSubsidizer(ctx context.Context, threshold uint64, interval time.Duration, _ ...client.SolanaOption) (<-chan client.SubsidizerStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("WatchSubsidizer", false); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, errors.Errorf("invalid interval: %v", interval)
+	}
+
+	status := f.subsidizerStatus()
+	alerts := make(chan client.SubsidizerStatus, 1)
+	if status.Lamports < threshold {
+		alerts <- status
+	}
+
+	go func() {
+		<-ctx.Done()
+		close(alerts)
+	}()
+
+	return alerts, nil
+}
+
+// GetSolBalance implements client.Client.GetSolBalance. Accounts without a SOL
+// balance have a balance of zero.
+func (f *Fake) GetSolBalance(_ context.Context, account kin.PublicKey, _ ...client.SolanaOption) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("GetSolBalance", false); err != nil {
+		return 0, err
+	}
+	return f.lamports[string(account)], nil
+}
+
+// TransferSol implements client.Client.TransferSol. The transaction fee is not
+// charged.
+func (f *Fake) TransferSol(_ context.Context, sender kin.PrivateKey, destination kin.PublicKey, lamports uint64, _ ...client.SolanaOption) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("TransferSol", true); err != nil {
+		return nil, err
+	}
+	if lamports == 0 {
+		return nil, errors.New("lamports must be positive")
+	}
+	if f.lamports[string(sender.Public())] < lamports {
+		return nil, client.ErrInsufficientBalance
+	}
+
+	f.lamports[string(sender.Public())] -= lamports
+	f.lamports[string(destination)] += lamports
+	return f.record(nil, nil, sender.Public(), destination), nil
+}
+
+// HealthCheck implements client.Client.HealthCheck. The fake is always healthy,
+// and performs no checks.
+func (f *Fake) HealthCheck(_ context.Context) client.HealthReport {
+	return client.HealthReport{Healthy: true}
+}
+
+// SetBlockchainVersion sets the blockchain version returned by
+// GetBlockchainVersion, notifying the channels returned by VersionChanged if it
+// changed. The version defaults to version.KinVersion4.
+func (f *Fake) SetBlockchainVersion(v version.KinVersion) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.kinVersion = v
+	close(f.versionUpdated)
+	f.versionUpdated = make(chan struct{})
+}
+
+// GetBlockchainVersion implements client.Client.GetBlockchainVersion.
+func (f *Fake) GetBlockchainVersion(_ context.Context) (version.KinVersion, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("GetBlockchainVersion", false); err != nil {
+		return version.KinVersionUnknown, err
+	}
+	return f.kinVersion, nil
+}
+
+// VersionChanged implements client.Client.VersionChanged. Rather than polling,
+// changes are sent when the version is set with SetBlockchainVersion.
+func (f *Fake) VersionChanged(ctx context.Context, interval time.Duration) (<-chan client.VersionChange, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("VersionChanged", false); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, errors.Errorf("invalid interval: %v", interval)
+	}
+
+	current := f.kinVersion
+	updated := f.versionUpdated
+	changes := make(chan client.VersionChange, 1)
+	go func() {
+		defer close(changes)
+
+		for {
+			select {
+			case <-updated:
+			case <-ctx.Done():
+				return
+			}
+
+			f.mu.Lock()
+			v := f.kinVersion
+			updated = f.versionUpdated
+			f.mu.Unlock()
+			if v == current {
+				continue
+			}
+
+			select {
+			case changes <- client.VersionChange{Previous: current, Current: v}:
+			case <-ctx.Done():
+				return
+			}
+			current = v
+		}
+	}()
+
+	return changes, nil
+}
+
+// Close implements client.Client.Close. Subsequent submissions fail with
+// client.ErrClientClosed.
+func (f *Fake) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	return nil
+}
+
+func (f *Fake) RequestAirdrop(_ context.Context, publicKey kin.PublicKey, quarks uint64, _ ...client.SolanaOption) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.begin("RequestAirdrop", true); err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.balances[string(publicKey)]; !ok {
+		return nil, client.ErrAccountDoesNotExist
+	}
+
+	f.balances[string(publicKey)] += int64(quarks)
+	return f.record(nil, nil, publicKey), nil
+}
+
+// chunkDedupeID derives the DedupeID of a chunk, matching the client.
+func chunkDedupeID(dedupeID []byte, chunk int) []byte {
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], uint32(chunk))
+
+	h := sha256.New()
+	_, _ = h.Write(dedupeID)
+	_, _ = h.Write(index[:])
+	return h.Sum(nil)
+}
+
+func transactionTypeName(t kin.TransactionType) string {
+	switch t {
+	case kin.TransactionTypeNone:
+		return "none"
+	case kin.TransactionTypeEarn:
+		return "earn"
+	case kin.TransactionTypeSpend:
+		return "spend"
+	case kin.TransactionTypeP2P:
+		return "p2p"
+	default:
+		return "unknown"
+	}
+}
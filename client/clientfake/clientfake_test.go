@@ -0,0 +1,453 @@
+package clientfake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/kin/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+func TestFake_CreateAccount(t *testing.T) {
+	f := New()
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	_, err = f.GetBalance(context.Background(), key.Public())
+	assert.Equal(t, client.ErrAccountDoesNotExist, err)
+
+	require.NoError(t, f.CreateAccount(context.Background(), key))
+	assert.Equal(t, client.ErrAccountExists, f.CreateAccount(context.Background(), key))
+
+	balance, err := f.GetBalance(context.Background(), key.Public())
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, balance)
+
+	accounts, err := f.ResolveTokenAccounts(context.Background(), key.Public())
+	require.NoError(t, err)
+	assert.Equal(t, []kin.PublicKey{key.Public()}, accounts)
+
+	other, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	batch, err := f.ResolveTokenAccountsBatch(context.Background(), []kin.PublicKey{key.Public(), other.Public()})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]kin.PublicKey{
+		key.Public().Base58():   {key.Public()},
+		other.Public().Base58(): nil,
+	}, batch)
+
+	owner, _, err := f.ResolveOwner(context.Background(), key.Public())
+	require.NoError(t, err)
+	assert.Equal(t, key.Public(), owner)
+
+	detail, err := f.GetBalanceDetail(context.Background(), key.Public())
+	require.NoError(t, err)
+	require.Len(t, detail.TokenAccounts, 1)
+	assert.Equal(t, key.Public(), detail.TokenAccounts[0].Account)
+}
+
+func TestFake_SubmitPayment(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	f := New(WithNow(func() time.Time { return now }))
+
+	sender, dest := newAccount(t, f, 100), newAccount(t, f, 0)
+	payment := client.Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      60,
+		Memo:        "1-test",
+		DedupeID:    []byte("dedupe"),
+	}
+
+	txID, err := f.SubmitPayment(context.Background(), payment)
+	require.NoError(t, err)
+	assert.Equal(t, TxID(1), txID)
+
+	// Deduped payments are not applied twice.
+	deduped, err := f.SubmitPayment(context.Background(), payment)
+	require.NoError(t, err)
+	assert.Equal(t, txID, deduped)
+
+	assertBalance(t, f, sender.Public(), 40)
+	assertBalance(t, f, dest.Public(), 60)
+
+	payment.DedupeID = nil
+	failedID, err := f.SubmitPayment(context.Background(), payment)
+	assert.Equal(t, client.ErrInsufficientBalance, err)
+	assert.Equal(t, TxID(2), failedID)
+	assertBalance(t, f, sender.Public(), 40)
+
+	data, err := f.GetTransaction(context.Background(), txID)
+	require.NoError(t, err)
+	assert.Equal(t, client.TransactionStateSuccess, data.TxState)
+	require.Len(t, data.Payments, 1)
+	assert.Equal(t, sender.Public(), data.Payments[0].Sender)
+	assert.EqualValues(t, 60, data.Payments[0].Quarks)
+
+	status, err := f.GetTransactionStatus(context.Background(), failedID)
+	require.NoError(t, err)
+	assert.Equal(t, client.TransactionStateFailed, status.TxState)
+	assert.Equal(t, client.ErrInsufficientBalance, status.TxError)
+
+	receipt, err := f.GetReceipt(context.Background(), txID)
+	require.NoError(t, err)
+	assert.Equal(t, now.UTC(), *receipt.BlockTime)
+	require.Len(t, receipt.Payments, 1)
+	assert.Equal(t, "spend", receipt.Payments[0].Type)
+	assert.Equal(t, dest.Public().Base58(), receipt.Payments[0].Destination)
+
+	_, err = f.GetTransaction(context.Background(), TxID(100))
+	assert.Equal(t, client.ErrTransactionNotFound, err)
+
+	assert.Len(t, f.Transactions(), 2)
+}
+
+func TestFake_Approval(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	f := New(WithNow(func() time.Time { return now }))
+
+	sender, dest := newAccount(t, f, 100), newAccount(t, f, 0)
+	approval, err := f.BuildApproval(context.Background(), client.ReadOnlyPayment{
+		Sender:      sender.Public(),
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      60,
+	})
+	require.NoError(t, err)
+
+	_, err = f.SubmitApproval(context.Background(), approval)
+	assert.Equal(t, client.ErrInvalidSignature, err)
+	assertBalance(t, f, sender.Public(), 100)
+
+	require.NoError(t, approval.Approve(sender, now))
+	txID, err := f.SubmitApproval(context.Background(), approval)
+	require.NoError(t, err)
+	assert.Equal(t, TxID(1), txID)
+	assertBalance(t, f, sender.Public(), 40)
+	assertBalance(t, f, dest.Public(), 60)
+
+	now = approval.Expires
+	_, err = f.SubmitApproval(context.Background(), approval)
+	assert.Equal(t, client.ErrApprovalExpired, err)
+}
+
+func TestFake_SubmitEarnBatches(t *testing.T) {
+	f := New()
+
+	sender := newAccount(t, f, int64(client.MaxBatchSize)+5)
+	batch := client.EarnBatch{Sender: sender}
+	for i := 0; i < 2*client.MaxBatchSize+1; i++ {
+		batch.Earns = append(batch.Earns, client.Earn{
+			Destination: newAccount(t, f, 0).Public(),
+			Quarks:      1,
+		})
+	}
+
+	_, err := f.SubmitEarnBatch(context.Background(), batch)
+	assert.Error(t, err)
+
+	// The second chunk exceeds the sender's balance, aborting the third.
+	results, err := f.SubmitEarnBatches(context.Background(), batch)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.NoError(t, results[0].TxError)
+	assert.Equal(t, client.ErrInsufficientBalance, results[1].TxError)
+	assert.Equal(t, client.ErrEarnBatchAborted, results[2].TxError)
+	assertBalance(t, f, sender.Public(), 5)
+
+	f.SetBalance(sender.Public(), 1)
+	batch.Earns = batch.Earns[:2]
+	ch, err := f.SubmitEarnBatchAsync(context.Background(), batch)
+	require.NoError(t, err)
+
+	var chunks []client.EarnChunkResult
+	for r := range ch {
+		chunks = append(chunks, r)
+	}
+	require.Len(t, chunks, 1)
+	assert.Equal(t, client.ErrInsufficientBalance, chunks[0].TxError)
+	assertBalance(t, f, sender.Public(), 1)
+}
+
+func TestFake_GetHistory(t *testing.T) {
+	f := New(WithHistoryPageSize(2))
+
+	sender, dest := newAccount(t, f, 10), newAccount(t, f, 0)
+	var txIDs [][]byte
+	for i := 0; i < 3; i++ {
+		txID, err := f.SubmitPayment(context.Background(), client.Payment{
+			Sender:      sender,
+			Destination: dest.Public(),
+			Quarks:      1,
+		})
+		require.NoError(t, err)
+		txIDs = append(txIDs, txID)
+	}
+
+	items, err := f.GetHistory(context.Background(), dest.Public(), nil)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, txIDs[:2], [][]byte{items[0].TxID, items[1].TxID})
+
+	items, err = f.GetHistory(context.Background(), dest.Public(), items[1].Cursor)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, txIDs[2], items[0].TxID)
+
+	items, err = f.GetHistory(context.Background(), dest.Public(), items[0].Cursor)
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestFake_Subscribe(t *testing.T) {
+	f := New()
+
+	sender, dest := newAccount(t, f, 10), newAccount(t, f, 0)
+	pay := func() []byte {
+		txID, err := f.SubmitPayment(context.Background(), client.Payment{
+			Sender:      sender,
+			Destination: dest.Public(),
+			Quarks:      1,
+		})
+		require.NoError(t, err)
+		return txID
+	}
+
+	first, second := pay(), pay()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := f.Subscribe(ctx, dest.Public(), first)
+	require.NoError(t, err)
+
+	result := <-results
+	assert.Equal(t, second, result.Item.TxID)
+	assert.EqualValues(t, second, result.Item.Cursor)
+	assert.False(t, result.Live)
+
+	third := pay()
+	result = <-results
+	assert.Equal(t, third, result.Item.TxID)
+	assert.Nil(t, result.Item.Cursor)
+	assert.True(t, result.Live)
+
+	cancel()
+	_, ok := <-results
+	assert.False(t, ok)
+
+	unknown, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	_, err = f.Subscribe(context.Background(), unknown.Public(), nil)
+	assert.Equal(t, client.ErrAccountDoesNotExist, err)
+}
+
+func TestFake_QueueError(t *testing.T) {
+	f := New()
+	key := newAccount(t, f, 10)
+
+	errA, errB := errors.New("a"), errors.New("b")
+	f.QueueError("GetBalance", errA, errB)
+
+	_, err := f.GetBalance(context.Background(), key.Public())
+	assert.Equal(t, errA, err)
+	_, err = f.GetBalance(context.Background(), key.Public())
+	assert.Equal(t, errB, err)
+	_, err = f.GetBalance(context.Background(), key.Public())
+	assert.NoError(t, err)
+}
+
+func TestFake_Close(t *testing.T) {
+	f := New()
+	key := newAccount(t, f, 10)
+
+	require.NoError(t, f.Close())
+
+	_, err := f.RequestAirdrop(context.Background(), key.Public(), 10)
+	assert.Equal(t, client.ErrClientClosed, err)
+
+	// Reads still succeed.
+	assertBalance(t, f, key.Public(), 10)
+}
+
+func TestFake_Costs(t *testing.T) {
+	subsidizer, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	f := New(WithRentExemption(1000), WithSubsidizer(subsidizer.Public(), 20*client.LamportsPerSignature))
+
+	cost, err := f.EstimateAccountCreationCost(context.Background(), 3)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3000, cost.RentLamports)
+	assert.EqualValues(t, 6*client.LamportsPerSignature, cost.FeeLamports)
+
+	status, err := f.GetSubsidizerStatus(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, subsidizer.Public(), status.Subsidizer)
+	assert.EqualValues(t, 10, status.RemainingTransactions)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	alerts, err := f.WatchSubsidizer(ctx, 21*client.LamportsPerSignature, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, status, <-alerts)
+
+	cancel()
+	_, ok := <-alerts
+	assert.False(t, ok)
+}
+
+func TestFake_Sol(t *testing.T) {
+	subsidizer, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	funder, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	f := New(WithSubsidizer(subsidizer.Public(), 10))
+	f.SetSolBalance(funder.Public(), 100)
+
+	_, err = f.TransferSol(context.Background(), funder, subsidizer.Public(), 101)
+	assert.Equal(t, client.ErrInsufficientBalance, err)
+
+	txID, err := f.TransferSol(context.Background(), funder, subsidizer.Public(), 60)
+	require.NoError(t, err)
+	assert.Equal(t, TxID(1), txID)
+
+	balance, err := f.GetSolBalance(context.Background(), funder.Public())
+	require.NoError(t, err)
+	assert.EqualValues(t, 40, balance)
+
+	status, err := f.GetSubsidizerStatus(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, 70, status.Lamports)
+}
+
+func newAccount(t *testing.T, f *Fake, quarks int64) kin.PrivateKey {
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	f.SetBalance(key.Public(), quarks)
+	return key
+}
+
+func assertBalance(t *testing.T, f *Fake, account kin.PublicKey, expected int64) {
+	balance, err := f.GetBalance(context.Background(), account)
+	require.NoError(t, err)
+	assert.Equal(t, expected, balance)
+}
+
+func TestFake_VersionChanged(t *testing.T) {
+	f := New()
+
+	v, err := f.GetBlockchainVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, version.KinVersion4, v)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := f.VersionChanged(ctx, time.Second)
+	require.NoError(t, err)
+
+	f.SetBlockchainVersion(version.KinVersion4)
+	f.SetBlockchainVersion(version.KinVersion3)
+	assert.Equal(t, client.VersionChange{Previous: version.KinVersion4, Current: version.KinVersion3}, <-changes)
+
+	v, err = f.GetBlockchainVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, version.KinVersion3, v)
+
+	cancel()
+	for range changes {
+	}
+}
+
+func TestFake_SubmitSplitPayment(t *testing.T) {
+	f := New()
+
+	sender := newAccount(t, f, 100)
+	seller := newAccount(t, f, 0)
+	platform := newAccount(t, f, 0)
+
+	splits := []client.Split{
+		{Destination: seller.Public(), BasisPoints: 9000},
+		{Destination: platform.Public(), BasisPoints: 1000},
+	}
+	result, err := f.SubmitSplitPayment(context.Background(), sender, 50, splits)
+	require.NoError(t, err)
+	assert.NoError(t, result.TxError)
+	assertBalance(t, f, sender.Public(), 50)
+	assertBalance(t, f, seller.Public(), 45)
+	assertBalance(t, f, platform.Public(), 5)
+
+	result, err = f.SubmitSplitPayment(context.Background(), sender, 60, splits)
+	require.NoError(t, err)
+	assert.Equal(t, client.ErrInsufficientBalance, result.TxError)
+
+	_, err = f.SubmitSplitPayment(context.Background(), sender, 50, splits[:1])
+	assert.Error(t, err)
+	assertBalance(t, f, sender.Public(), 50)
+}
+
+func TestFake_FulfillPaymentRequest(t *testing.T) {
+	now := time.Now()
+	f := New(WithNow(func() time.Time { return now }))
+
+	sender := newAccount(t, f, 100)
+	requester := newAccount(t, f, 0)
+
+	r, err := client.PaymentRequest{
+		Destination: requester.Public(),
+		Quarks:      10,
+		Expires:     now.Add(time.Minute),
+	}.Sign(requester)
+	require.NoError(t, err)
+
+	_, err = f.FulfillPaymentRequest(context.Background(), sender, r)
+	require.NoError(t, err)
+	assertBalance(t, f, sender.Public(), 90)
+	assertBalance(t, f, requester.Public(), 10)
+
+	now = now.Add(time.Minute)
+	_, err = f.FulfillPaymentRequest(context.Background(), sender, r)
+	assert.Equal(t, client.ErrPaymentRequestExpired, err)
+	assertBalance(t, f, sender.Public(), 90)
+}
+
+func TestFake_Refund(t *testing.T) {
+	f := New()
+
+	sender := newAccount(t, f, 100)
+	merchant := newAccount(t, f, 0)
+
+	txID, err := f.SubmitPayment(context.Background(), client.Payment{
+		Sender:      sender,
+		Destination: merchant.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      30,
+	})
+	require.NoError(t, err)
+
+	_, err = f.Refund(context.Background(), sender, txID, 0)
+	assert.Error(t, err)
+
+	refundID, err := f.Refund(context.Background(), merchant, txID, 0)
+	require.NoError(t, err)
+	assertBalance(t, f, sender.Public(), 100)
+	assertBalance(t, f, merchant.Public(), 0)
+
+	data, err := f.GetTransaction(context.Background(), refundID)
+	require.NoError(t, err)
+	require.Len(t, data.Payments, 1)
+	assert.Equal(t, kin.TransactionTypeEarn, data.Payments[0].Type)
+	assert.Equal(t, client.RefundMemo(txID, 0), data.Payments[0].Memo)
+
+	// A payment can only be refunded once.
+	_, err = f.Refund(context.Background(), merchant, txID, 0)
+	assert.True(t, errors.Is(err, client.ErrAlreadySubmitted))
+	assertBalance(t, f, sender.Public(), 100)
+}
@@ -0,0 +1,101 @@
+package clientfake
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// Clock is a fake client.Clock, whose time only moves when it is advanced. It
+// is safe for concurrent use.
+//
+// Passing a Clock to client.WithClock (and client.NewMemoryCacheWithClock)
+// allows tests to expire cached values, and to skip retry backoff, without
+// sleeping. Since a retrying client waits on the clock from the goroutine that
+// made the request, tests typically advance the clock from another goroutine
+// once BlockUntil reports that the client is waiting.
+type Clock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []clockWaiter
+}
+
+type clockWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+var _ client.Clock = (*Clock)(nil)
+
+// NewClock returns a Clock whose current time is start.
+func NewClock(start time.Time) *Clock {
+	c := &Clock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now implements client.Clock.Now.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After implements client.Clock.After. The channel receives once the clock has
+// been advanced by at least d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.waiters = append(c.waiters, clockWaiter{deadline: c.now.Add(d), ch: ch})
+	c.cond.Broadcast()
+	return ch
+}
+
+// Advance moves the clock forward by d, firing the channels returned by After
+// whose duration has elapsed.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	remaining := c.waiters[:0]
+	for _, w := range c.waiters {
+		if c.now.Before(w.deadline) {
+			remaining = append(remaining, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = remaining
+}
+
+// Waiters returns the number of channels returned by After that have not yet
+// fired.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+// BlockUntil blocks until at least n channels returned by After are waiting to
+// fire.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
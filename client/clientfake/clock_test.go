@@ -0,0 +1,55 @@
+package clientfake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewClock(start)
+	assert.Equal(t, start, c.Now())
+
+	select {
+	case now := <-c.After(0):
+		assert.Equal(t, start, now)
+	default:
+		t.Fatal("expected immediate fire")
+	}
+
+	fired := make(chan time.Time)
+	go func() {
+		fired <- <-c.After(time.Minute)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(30 * time.Second)
+	assert.Equal(t, 1, c.Waiters())
+
+	c.Advance(30 * time.Second)
+	assert.Equal(t, start.Add(time.Minute), <-fired)
+	assert.Equal(t, 0, c.Waiters())
+}
+
+func TestClock_Cache(t *testing.T) {
+	c := NewClock(time.Unix(1000, 0))
+	cache := client.NewMemoryCacheWithClock(c)
+
+	require.NoError(t, cache.Set(context.Background(), "key", []byte("value"), time.Hour))
+
+	c.Advance(time.Hour - time.Second)
+	_, ok, err := cache.Get(context.Background(), "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	c.Advance(time.Second)
+	_, ok, err = cache.Get(context.Background(), "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
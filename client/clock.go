@@ -0,0 +1,67 @@
+package client
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/kinecosystem/agora-common/retry"
+	"github.com/kinecosystem/agora-common/retry/backoff"
+)
+
+// Clock is a source of time.
+//
+// The client uses its Clock for retry backoff, the expiry of cached values
+// (such as the service config and rent exemptions), subsidizer bookkeeping and
+// daily limits. Tests can replace it with a fake, such as clientfake.Clock, to
+// fast-forward through delays and expiry without sleeping.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel that receives the current time once d has
+	// elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock returns a Clock backed by the time package.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+// Now implements Clock.Now.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After implements Clock.After.
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock specifies the Clock used by the client. Defaults to SystemClock.
+func WithClock(clock Clock) ClientOption {
+	return func(o *clientOpts) {
+		o.clock = clock
+	}
+}
+
+// clockOrSystem returns clock, or the system clock if clock is nil.
+func clockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return systemClock{}
+	}
+	return clock
+}
+
+// backoffWithJitter is retry.BackoffWithJitter, sleeping with clock rather than
+// the time package.
+func backoffWithJitter(clock Clock, strategy backoff.Strategy, maxBackoff time.Duration, jitter float64) retry.Strategy {
+	return func(attempts uint, err error) bool {
+		delay := time.Duration(math.Min(float64(maxBackoff), float64(strategy(attempts))))
+		<-clock.After(time.Duration(float64(delay) * (1 + (rand.Float64()*jitter*2 - jitter))))
+		return true
+	}
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+)
+
+// stepClock is a Clock that advances by d whenever After(d) is called, so that
+// waits complete immediately.
+type stepClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *stepClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	c.waits = append(c.waits, d)
+
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func (c *stepClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestClient_Clock(t *testing.T) {
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	env, cleanup := setup(t,
+		WithClock(clock),
+		WithMinDelay(time.Hour),
+		WithMaxDelay(2*time.Hour),
+	)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+	env.v4Server.Mux.Lock()
+	env.v4Server.ServiceConfigReqs = nil
+	env.v4Server.Mux.Unlock()
+
+	// Backoff waits on the clock, rather than sleeping.
+	env.v4Server.SetError(errors.New("unexpected"), 2)
+	start := time.Now()
+	_, err := env.internal.GetTransaction(context.Background(), make([]byte, 64), commonpbv4.Commitment_SINGLE)
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Minute)
+
+	clock.mu.Lock()
+	require.Len(t, clock.waits, 2)
+	for i, wait := range clock.waits {
+		expected := time.Hour << uint(i)
+		assert.InEpsilon(t, float64(expected), float64(wait), 0.11)
+	}
+	clock.mu.Unlock()
+
+	requests := func() (config, rent int) {
+		env.v4Server.Mux.Lock()
+		defer env.v4Server.Mux.Unlock()
+		return len(env.v4Server.ServiceConfigReqs), len(env.v4Server.RentExemptionReqs)
+	}
+
+	// Cached values expire according to the clock.
+	for i := 0; i < 2; i++ {
+		_, err = env.internal.GetServiceConfig(context.Background())
+		require.NoError(t, err)
+		_, err = env.internal.GetMinimumBalanceForRentException(context.Background(), 10)
+		require.NoError(t, err)
+	}
+	config, rent := requests()
+	assert.Equal(t, 1, config)
+	assert.Equal(t, 1, rent)
+
+	clock.advance(time.Hour)
+	_, err = env.internal.GetServiceConfig(context.Background())
+	require.NoError(t, err)
+	_, err = env.internal.GetMinimumBalanceForRentException(context.Background(), 10)
+	require.NoError(t, err)
+	config, rent = requests()
+	assert.Equal(t, 1, config)
+	assert.Equal(t, 2, rent)
+
+	clock.advance(23 * time.Hour)
+	_, err = env.internal.GetServiceConfig(context.Background())
+	require.NoError(t, err)
+	config, _ = requests()
+	assert.Equal(t, 2, config)
+
+	// Subsidizer failures are counted within a window of the clock.
+	env.client.subsidizerFailures.record([]byte("subsidizer"))
+	assert.Equal(t, 1, env.client.subsidizerFailures.count([]byte("subsidizer")))
+	clock.advance(subsidizerFailureWindow)
+	assert.Equal(t, 0, env.client.subsidizerFailures.count([]byte("subsidizer")))
+
+	// The default clock is the system clock.
+	assert.IsType(t, systemClock{}, clockOrSystem(nil))
+	assert.WithinDuration(t, time.Now(), SystemClock().Now(), time.Second)
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"github.com/kinecosystem/agora-common/solana"
+
+	"github.com/kinecosystem/kin-go/client/computebudget"
+)
+
+// WithComputeUnitLimit specifies the maximum number of compute units that a
+// payment or earn batch transaction may consume, by prepending a ComputeBudget
+// SetComputeUnitLimit instruction to it.
+//
+// Transactions with ComputeBudget instructions can only be submitted if Agora
+// accepts them.
+func WithComputeUnitLimit(units uint32) SolanaOption {
+	return func(o *solanaOpts) {
+		o.computeUnitLimit = units
+	}
+}
+
+// WithPriorityFee specifies the price of each compute unit consumed by a
+// payment or earn batch transaction, in micro-lamports, by prepending a
+// ComputeBudget SetComputeUnitPrice instruction to it. Transactions paying a
+// priority fee are more likely to be processed during network congestion.
+//
+// The fee is paid by the fee payer of the transaction, which is usually the
+// subsidizer. Transactions with ComputeBudget instructions can only be
+// submitted if Agora accepts them.
+func WithPriorityFee(microLamports uint64) SolanaOption {
+	return func(o *solanaOpts) {
+		o.computeUnitPrice = microLamports
+	}
+}
+
+// computeBudgetInstructions returns the ComputeBudget instructions specified
+// by the options, if any.
+func (o solanaOpts) computeBudgetInstructions() []solana.Instruction {
+	var instructions []solana.Instruction
+	if o.computeUnitLimit > 0 {
+		instructions = append(instructions, computebudget.SetComputeUnitLimit(o.computeUnitLimit))
+	}
+	if o.computeUnitPrice > 0 {
+		instructions = append(instructions, computebudget.SetComputeUnitPrice(o.computeUnitPrice))
+	}
+	return instructions
+}
+
+// withoutComputeBudget returns tx without its ComputeBudget instructions, which
+// do not affect the payments of a transaction, but are not supported by
+// kin.ParseTransaction.
+func withoutComputeBudget(tx solana.Transaction) solana.Transaction {
+	var found bool
+	for i := range tx.Message.Instructions {
+		if computebudget.IsComputeBudget(tx.Message, i) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return tx
+	}
+
+	instructions := make([]solana.CompiledInstruction, 0, len(tx.Message.Instructions))
+	for i, instruction := range tx.Message.Instructions {
+		if !computebudget.IsComputeBudget(tx.Message, i) {
+			instructions = append(instructions, instruction)
+		}
+	}
+	tx.Message.Instructions = instructions
+	return tx
+}
@@ -0,0 +1,113 @@
+// Package computebudget provides instructions for the Solana ComputeBudget
+// program, which sets the compute unit limit and priority fee of a transaction.
+package computebudget
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/pkg/errors"
+)
+
+// ProgramKey is the address of the ComputeBudget program.
+//
+// Current key: ComputeBudget111111111111111111111111111111
+var ProgramKey = ed25519.PublicKey{3, 6, 70, 111, 229, 33, 23, 50, 255, 236, 173, 186, 114, 195, 155, 231, 188, 140, 229, 187, 197, 247, 18, 107, 44, 67, 155, 58, 64, 0, 0, 0}
+
+// Command is the type of a ComputeBudget instruction.
+type Command byte
+
+const (
+	CommandRequestUnits Command = iota
+	CommandRequestHeapFrame
+	CommandSetComputeUnitLimit
+	CommandSetComputeUnitPrice
+)
+
+// SetComputeUnitLimit returns an instruction that sets the maximum number of
+// compute units the transaction may consume.
+//
+// Reference: https://github.com/solana-labs/solana/blob/master/sdk/src/compute_budget.rs
+func SetComputeUnitLimit(units uint32) solana.Instruction {
+	data := make([]byte, 5)
+	data[0] = byte(CommandSetComputeUnitLimit)
+	binary.LittleEndian.PutUint32(data[1:], units)
+
+	return solana.NewInstruction(ProgramKey, data)
+}
+
+// SetComputeUnitPrice returns an instruction that sets the price of each
+// compute unit, in micro-lamports. The resulting priority fee is the price
+// multiplied by the compute unit limit.
+//
+// Reference: https://github.com/solana-labs/solana/blob/master/sdk/src/compute_budget.rs
+func SetComputeUnitPrice(microLamports uint64) solana.Instruction {
+	data := make([]byte, 9)
+	data[0] = byte(CommandSetComputeUnitPrice)
+	binary.LittleEndian.PutUint64(data[1:], microLamports)
+
+	return solana.NewInstruction(ProgramKey, data)
+}
+
+// IsComputeBudget returns whether the instruction at index in m is a
+// ComputeBudget instruction.
+func IsComputeBudget(m solana.Message, index int) bool {
+	if index >= len(m.Instructions) {
+		return false
+	}
+	programIndex := int(m.Instructions[index].ProgramIndex)
+	return programIndex < len(m.Accounts) && bytes.Equal(m.Accounts[programIndex], ProgramKey)
+}
+
+// DecompiledSetComputeUnitLimit is a decompiled SetComputeUnitLimit instruction.
+type DecompiledSetComputeUnitLimit struct {
+	Units uint32
+}
+
+// DecompileSetComputeUnitLimit decompiles the SetComputeUnitLimit instruction
+// at index in m.
+func DecompileSetComputeUnitLimit(m solana.Message, index int) (*DecompiledSetComputeUnitLimit, error) {
+	data, err := decompile(m, index, CommandSetComputeUnitLimit, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecompiledSetComputeUnitLimit{Units: binary.LittleEndian.Uint32(data[1:])}, nil
+}
+
+// DecompiledSetComputeUnitPrice is a decompiled SetComputeUnitPrice instruction.
+type DecompiledSetComputeUnitPrice struct {
+	MicroLamports uint64
+}
+
+// DecompileSetComputeUnitPrice decompiles the SetComputeUnitPrice instruction
+// at index in m.
+func DecompileSetComputeUnitPrice(m solana.Message, index int) (*DecompiledSetComputeUnitPrice, error) {
+	data, err := decompile(m, index, CommandSetComputeUnitPrice, 9)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecompiledSetComputeUnitPrice{MicroLamports: binary.LittleEndian.Uint64(data[1:])}, nil
+}
+
+func decompile(m solana.Message, index int, cmd Command, size int) ([]byte, error) {
+	if index >= len(m.Instructions) {
+		return nil, errors.Errorf("instruction doesn't exist at %d", index)
+	}
+	if !IsComputeBudget(m, index) {
+		return nil, solana.ErrIncorrectProgram
+	}
+
+	data := m.Instructions[index].Data
+	if len(data) == 0 || Command(data[0]) != cmd {
+		return nil, solana.ErrIncorrectInstruction
+	}
+	if len(data) != size {
+		return nil, errors.Errorf("invalid instruction data size: %d", len(data))
+	}
+
+	return data, nil
+}
@@ -0,0 +1,48 @@
+package computebudget
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/mr-tron/base58"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgramKey(t *testing.T) {
+	assert.Equal(t, "ComputeBudget111111111111111111111111111111", base58.Encode(ProgramKey))
+}
+
+func TestInstructions(t *testing.T) {
+	payer, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	tx := solana.NewTransaction(
+		payer,
+		SetComputeUnitLimit(200000),
+		SetComputeUnitPrice(1000),
+		memo.Instruction("test"),
+	)
+
+	assert.True(t, IsComputeBudget(tx.Message, 0))
+	assert.True(t, IsComputeBudget(tx.Message, 1))
+	assert.False(t, IsComputeBudget(tx.Message, 2))
+	assert.False(t, IsComputeBudget(tx.Message, 3))
+
+	limit, err := DecompileSetComputeUnitLimit(tx.Message, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 200000, limit.Units)
+
+	price, err := DecompileSetComputeUnitPrice(tx.Message, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1000, price.MicroLamports)
+
+	_, err = DecompileSetComputeUnitLimit(tx.Message, 1)
+	assert.Equal(t, solana.ErrIncorrectInstruction, err)
+	_, err = DecompileSetComputeUnitPrice(tx.Message, 2)
+	assert.Equal(t, solana.ErrIncorrectProgram, err)
+	_, err = DecompileSetComputeUnitPrice(tx.Message, 3)
+	assert.Error(t, err)
+}
@@ -0,0 +1,279 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/retry"
+	"github.com/kinecosystem/agora-common/retry/backoff"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+)
+
+// Config is a client configuration that can be loaded from YAML or JSON.
+//
+// Unset fields use the same defaults as New. Durations are specified as
+// strings, such as "500ms".
+type Config struct {
+	Environment       Environment `yaml:"environment"`
+	Endpoint          string      `yaml:"endpoint"`
+	AppIndex          uint16      `yaml:"app_index"`
+	DefaultCommitment string      `yaml:"default_commitment"`
+
+	// The following fields can be changed by WatchConfigFile. Removing one
+	// of them from a watched file leaves its current value in effect, rather
+	// than restoring the default.
+	MaxRetries      *uint         `yaml:"max_retries"`
+	MaxNonceRetries *uint         `yaml:"max_nonce_retries"`
+	MinDelay        time.Duration `yaml:"min_delay"`
+	MaxDelay        time.Duration `yaml:"max_delay"`
+	MaxConcurrency  int           `yaml:"max_concurrency"`
+	Limits          *LimitsConfig `yaml:"limits"`
+}
+
+// LimitsConfig is the configuration of Limits.
+type LimitsConfig struct {
+	MaxPaymentQuarks          int64 `yaml:"max_payment_quarks"`
+	MaxDestinationDailyQuarks int64 `yaml:"max_destination_daily_quarks"`
+	MaxAppDailyQuarks         int64 `yaml:"max_app_daily_quarks"`
+}
+
+// LoadConfig loads a Config from YAML or JSON.
+func LoadConfig(r io.Reader) (Config, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Config{}, errors.Wrap(err, "failed to read config")
+	}
+
+	// YAML is a superset of JSON, so both are handled by the YAML decoder.
+	var cfg Config
+	if err := yaml.UnmarshalStrict(b, &cfg); err != nil {
+		return Config{}, errors.Wrap(err, "invalid config")
+	}
+	if cfg.Environment == "" {
+		return Config{}, errors.New("config is missing environment")
+	}
+	if cfg.Environment, err = ParseEnvironment(string(cfg.Environment)); err != nil {
+		return Config{}, errors.Wrap(err, "invalid config")
+	}
+
+	return cfg, nil
+}
+
+// LoadConfigFile loads a Config from a YAML or JSON file.
+func LoadConfigFile(path string) (Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, errors.Wrap(err, "failed to read config")
+	}
+
+	return LoadConfig(bytes.NewReader(b))
+}
+
+// Options returns the ClientOptions specified by the config.
+func (c Config) Options() ([]ClientOption, error) {
+	var opts []ClientOption
+	if c.Endpoint != "" {
+		opts = append(opts, WithEndpoint(c.Endpoint))
+	}
+	if c.AppIndex > 0 {
+		opts = append(opts, WithAppIndex(c.AppIndex))
+	}
+	if c.DefaultCommitment != "" {
+		commitment, ok := commonpbv4.Commitment_value[strings.ToUpper(c.DefaultCommitment)]
+		if !ok {
+			return nil, errors.Errorf("invalid commitment: %s", c.DefaultCommitment)
+		}
+		opts = append(opts, WithDefaultCommitment(commonpbv4.Commitment(commitment)))
+	}
+	if c.MaxRetries != nil {
+		opts = append(opts, WithMaxRetries(*c.MaxRetries))
+	}
+	if c.MaxNonceRetries != nil {
+		opts = append(opts, WithMaxNonceRetries(*c.MaxNonceRetries))
+	}
+	if c.MinDelay > 0 {
+		opts = append(opts, WithMinDelay(c.MinDelay))
+	}
+	if c.MaxDelay > 0 {
+		opts = append(opts, WithMaxDelay(c.MaxDelay))
+	}
+	if c.MaxConcurrency > 0 {
+		opts = append(opts, WithMaxConcurrency(c.MaxConcurrency))
+	}
+	if c.Limits != nil {
+		opts = append(opts, WithLimits(Limits{
+			MaxPaymentQuarks:          c.Limits.MaxPaymentQuarks,
+			MaxDestinationDailyQuarks: c.Limits.MaxDestinationDailyQuarks,
+			MaxAppDailyQuarks:         c.Limits.MaxAppDailyQuarks,
+		}))
+	}
+
+	return opts, nil
+}
+
+// NewFromConfig creates a new client from a YAML or JSON config.
+//
+// Options specified in opts take precedence over those in the config.
+func NewFromConfig(r io.Reader, opts ...ClientOption) (Client, error) {
+	cfg, err := LoadConfig(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFromConfig(cfg, opts...)
+}
+
+// NewFromConfigFile creates a new client from a YAML or JSON config file.
+//
+// Options specified in opts take precedence over those in the config.
+func NewFromConfigFile(path string, opts ...ClientOption) (Client, error) {
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFromConfig(cfg, opts...)
+}
+
+func newFromConfig(cfg Config, opts ...ClientOption) (Client, error) {
+	cfgOpts, err := cfg.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	return New(cfg.Environment, append(cfgOpts, opts...)...)
+}
+
+// WatchConfigFile polls the config file at path every interval, and applies any
+// changes to the tunable fields of the config (retries, delays, concurrency and
+// limits) to the client. Changes to other fields require a new client.
+//
+// Only the fields set in the file are applied: removing a field from the file
+// leaves its current value in effect, rather than restoring its default. In
+// particular, limits set with WithLimits are only replaced, never removed, by
+// a reload, so that a reload cannot disable them.
+//
+// Watching stops when ctx is cancelled, or the client is closed. Errors
+// encountered while reloading the config are passed to onError, if set, and the
+// previous configuration remains in effect.
+func WatchConfigFile(ctx context.Context, c Client, path string, interval time.Duration, onError func(error)) error {
+	impl, ok := c.(*client)
+	if !ok {
+		return errors.New("client does not support config reloading")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to stat config")
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		modTime, size := info.ModTime(), info.Size()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-impl.lifecycle.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err == nil && info.ModTime().Equal(modTime) && info.Size() == size {
+				continue
+			}
+			if err == nil {
+				modTime, size = info.ModTime(), info.Size()
+
+				var cfg Config
+				if cfg, err = LoadConfigFile(path); err == nil {
+					err = impl.applyConfig(cfg)
+				}
+			}
+
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// applyConfig applies the tunable fields of cfg to the client.
+func (c *client) applyConfig(cfg Config) error {
+	cfgOpts, err := cfg.Options()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	updated := c.opts
+	for _, o := range cfgOpts {
+		o(&updated)
+	}
+
+	// Preserve the limit counters across reloads.
+	if updated.limits != nil && c.opts.limits != nil && cfg.Limits != nil {
+		updated.limits.Store = c.opts.limits.Store
+	}
+
+	c.opts.maxRetries = updated.maxRetries
+	c.opts.maxSequenceRetries = updated.maxSequenceRetries
+	c.opts.minDelay = updated.minDelay
+	c.opts.maxDelay = updated.maxDelay
+	c.opts.maxConcurrency = updated.maxConcurrency
+	c.opts.limits = updated.limits
+	c.retrier.set(newRetrier(c.opts, c.opts.readRetryPolicy))
+	c.writeRetrier.set(newRetrier(c.opts, c.opts.writeRetryPolicy))
+
+	return nil
+}
+
+func newRetrier(opts clientOpts, policy RetryPolicy) retry.Retrier {
+	policy = policy.resolve(opts)
+	return retry.NewRetrier(
+		retry.Limit(policy.MaxRetries),
+		backoffWithJitter(clockOrSystem(opts.clock), backoff.BinaryExponential(policy.MinDelay), policy.MaxDelay, 0.1),
+		nonRetriable(append(defaultNonRetriableErrors(), opts.nonRetriableErrors...), opts.retriableErrors),
+	)
+}
+
+// reloadableRetrier is a retry.Retrier whose underlying retrier can be replaced.
+type reloadableRetrier struct {
+	mu      sync.RWMutex
+	retrier retry.Retrier
+
+	// onRetry and faults are preserved across reloads.
+	onRetry func(uint, error)
+	faults  *faultInjector
+}
+
+// Retry implements retry.Retrier.Retry.
+func (r *reloadableRetrier) Retry(action retry.Action) (uint, error) {
+	r.mu.RLock()
+	retrier := r.retrier
+	r.mu.RUnlock()
+
+	return retrier.Retry(withRetryHook(r.onRetry, r.faults.wrap(action)))
+}
+
+func (r *reloadableRetrier) set(retrier retry.Retrier) {
+	r.mu.Lock()
+	r.retrier = retrier
+	r.mu.Unlock()
+}
@@ -0,0 +1,183 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+)
+
+func TestLoadConfig(t *testing.T) {
+	yamlConfig := `
+environment: test
+endpoint: localhost:8085
+app_index: 2
+default_commitment: max
+max_retries: 0
+min_delay: 100ms
+max_delay: 2s
+limits:
+  max_payment_quarks: 10
+`
+	jsonConfig := `{
+	"environment": "test",
+	"endpoint": "localhost:8085",
+	"app_index": 2,
+	"default_commitment": "MAX",
+	"max_retries": 0,
+	"min_delay": "100ms",
+	"max_delay": "2s",
+	"limits": {"max_payment_quarks": 10}
+}`
+
+	for _, raw := range []string{yamlConfig, jsonConfig} {
+		cfg, err := LoadConfig(strings.NewReader(raw))
+		require.NoError(t, err)
+		assert.Equal(t, EnvironmentTest, cfg.Environment)
+		assert.Equal(t, "localhost:8085", cfg.Endpoint)
+		assert.EqualValues(t, 2, cfg.AppIndex)
+		require.NotNil(t, cfg.MaxRetries)
+		assert.Zero(t, *cfg.MaxRetries)
+		assert.Nil(t, cfg.MaxNonceRetries)
+		assert.Equal(t, 100*time.Millisecond, cfg.MinDelay)
+		assert.Equal(t, 2*time.Second, cfg.MaxDelay)
+		assert.EqualValues(t, 10, cfg.Limits.MaxPaymentQuarks)
+
+		opts, err := cfg.Options()
+		require.NoError(t, err)
+
+		var o clientOpts
+		for _, opt := range opts {
+			opt(&o)
+		}
+		assert.Equal(t, "localhost:8085", o.endpoint)
+		assert.EqualValues(t, 2, o.appIndex)
+		assert.Equal(t, commonpbv4.Commitment_MAX, o.defaultCommitment)
+		assert.Zero(t, o.maxRetries)
+		assert.Equal(t, 100*time.Millisecond, o.minDelay)
+		assert.Equal(t, 2*time.Second, o.maxDelay)
+		assert.EqualValues(t, 10, o.limits.MaxPaymentQuarks)
+	}
+
+	for _, invalid := range []string{
+		"app_index: 1",
+		"environment: test\nunknown_field: 1",
+		"environment: test\nmin_delay: abc",
+	} {
+		_, err := LoadConfig(strings.NewReader(invalid))
+		assert.Error(t, err)
+	}
+
+	cfg, err := LoadConfig(strings.NewReader("environment: test\ndefault_commitment: abc"))
+	require.NoError(t, err)
+	_, err = cfg.Options()
+	assert.Error(t, err)
+}
+
+func TestNewFromConfigFile(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	dir, err := ioutil.TempDir("", "kin-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("environment: test\napp_index: 1\nmax_nonce_retries: 1\nlimits:\n  max_payment_quarks: 5\n"), 0600))
+
+	c, err := NewFromConfigFile(path, WithGRPC(env.conn))
+	require.NoError(t, err)
+	impl := c.(*client)
+	assert.EqualValues(t, 1, impl.nonceRetries())
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	for _, k := range []kin.PrivateKey{sender, dest} {
+		require.NoError(t, c.CreateAccount(context.Background(), k))
+	}
+
+	p := Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      6,
+	}
+	_, err = c.SubmitPayment(context.Background(), p)
+	assert.Equal(t, ErrLimitExceeded, errors.Cause(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloadErrs := make(chan error, 10)
+	require.NoError(t, WatchConfigFile(ctx, c, path, 5*time.Millisecond, func(err error) {
+		reloadErrs <- err
+	}))
+
+	// Invalid configs are reported, and leave the previous config in place.
+	require.NoError(t, ioutil.WriteFile(path, []byte("environment: test\nmax_retries: abc\n"), 0600))
+	select {
+	case err := <-reloadErrs:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for reload error")
+	}
+	assert.EqualValues(t, 1, impl.nonceRetries())
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("environment: test\nmax_nonce_retries: 5\nmax_concurrency: 3\n"), 0600))
+	require.Eventually(t, func() bool {
+		return impl.nonceRetries() == 5
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 3, impl.concurrency())
+
+	// Removing limits from the file does not disable them.
+	require.NotNil(t, impl.limits())
+	_, err = c.SubmitPayment(context.Background(), p)
+	assert.Equal(t, ErrLimitExceeded, errors.Cause(err))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("environment: test\nlimits:\n  max_payment_quarks: 10\n"), 0600))
+	require.Eventually(t, func() bool {
+		return impl.limits().MaxPaymentQuarks == 10
+	}, time.Second, 5*time.Millisecond)
+	assert.EqualValues(t, 5, impl.nonceRetries())
+
+	_, err = c.SubmitPayment(context.Background(), p)
+	assert.NoError(t, err)
+}
+
+func TestWatchConfigFile_KeepsCodeLimits(t *testing.T) {
+	env, cleanup := setup(t, WithLimits(Limits{MaxPaymentQuarks: 5}))
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "kin-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("environment: test\n"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, WatchConfigFile(ctx, env.client, path, 5*time.Millisecond, nil))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("environment: test\nmax_concurrency: 3\n"), 0600))
+	require.Eventually(t, func() bool {
+		return env.client.concurrency() == 3
+	}, time.Second, 5*time.Millisecond)
+
+	require.NotNil(t, env.client.limits())
+	assert.EqualValues(t, 5, env.client.limits().MaxPaymentQuarks)
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+)
+
+const defaultConfirmationPollInterval = 500 * time.Millisecond
+
+// ConfirmationHandle tracks the commitment of a submitted transaction, so that
+// callers can choose between speed and finality per use case. For example, a
+// transaction may be submitted with Commitment_RECENT so that it can be shown
+// to a user quickly, and then awaited at Commitment_MAX before it is settled.
+type ConfirmationHandle struct {
+	client       Client
+	txID         []byte
+	pollInterval time.Duration
+	clock        Clock
+
+	mu      sync.Mutex
+	reached commonpbv4.Commitment
+}
+
+// ConfirmationOption configures a ConfirmationHandle.
+type ConfirmationOption func(h *ConfirmationHandle)
+
+// WithConfirmationPollInterval specifies the interval at which the status of
+// the transaction is polled while awaiting a commitment. It defaults to 500ms.
+func WithConfirmationPollInterval(interval time.Duration) ConfirmationOption {
+	return func(h *ConfirmationHandle) {
+		h.pollInterval = interval
+	}
+}
+
+// WithConfirmationClock specifies the Clock used to wait between polls.
+func WithConfirmationClock(clock Clock) ConfirmationOption {
+	return func(h *ConfirmationHandle) {
+		h.clock = clock
+	}
+}
+
+// NewConfirmationHandle returns a ConfirmationHandle for the transaction txID,
+// which was accepted at the specified commitment, such as the Commitment of an
+// EarnBatchResult, or the commitment specified to SubmitPayment.
+func NewConfirmationHandle(c Client, txID []byte, accepted commonpbv4.Commitment, opts ...ConfirmationOption) *ConfirmationHandle {
+	h := &ConfirmationHandle{
+		client:       c,
+		txID:         txID,
+		pollInterval: defaultConfirmationPollInterval,
+		clock:        SystemClock(),
+		reached:      accepted,
+	}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
+}
+
+// TxID returns the ID of the transaction.
+func (h *ConfirmationHandle) TxID() []byte {
+	return h.txID
+}
+
+// Commitment returns the highest commitment the transaction is known to have
+// reached.
+func (h *ConfirmationHandle) Commitment() commonpbv4.Commitment {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.reached
+}
+
+// Await blocks until the transaction has reached the specified commitment, or
+// ctx is done, returning the status of the transaction at that commitment.
+//
+// A transaction that failed is still considered to have reached the
+// commitment; the failure is reported by the TxError of the status.
+func (h *ConfirmationHandle) Await(ctx context.Context, commitment commonpbv4.Commitment) (TransactionStatus, error) {
+	for {
+		status, err := h.client.GetTransactionStatus(ctx, h.txID, WithCommitment(commitment))
+		if err != nil {
+			return status, errors.Wrap(err, "failed to get transaction status")
+		}
+
+		switch status.TxState {
+		case TransactionStateSuccess, TransactionStateFailed:
+			h.mu.Lock()
+			if commitment > h.reached {
+				h.reached = commitment
+			}
+			h.mu.Unlock()
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-h.clock.After(h.pollInterval):
+		}
+	}
+}
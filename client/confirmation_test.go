@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+
+	"github.com/kinecosystem/kin-go/client/testutil"
+)
+
+func TestClient_SubmitEarnBatchCommitment(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest := testutil.GenerateSolanaKeys(t, 1)[0]
+
+	batch := EarnBatch{
+		Sender: sender,
+		Earns:  []Earn{{Destination: kin.PublicKey(dest), Quarks: 10}},
+	}
+
+	for _, commitment := range []commonpbv4.Commitment{commonpbv4.Commitment_RECENT, commonpbv4.Commitment_MAX} {
+		result, err := env.client.SubmitEarnBatch(context.Background(), batch, WithCommitment(commitment))
+		require.NoError(t, err)
+		assert.Equal(t, commitment, result.Commitment)
+	}
+}
+
+func TestConfirmationHandle_Await(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	txID := make([]byte, 64)
+	txID[0] = 1
+
+	clock := &stepClock{now: time.Now()}
+	h := NewConfirmationHandle(env.client, txID, commonpbv4.Commitment_RECENT, WithConfirmationClock(clock), WithConfirmationPollInterval(time.Second))
+	assert.Equal(t, txID, h.TxID())
+	assert.Equal(t, commonpbv4.Commitment_RECENT, h.Commitment())
+
+	type awaitResult struct {
+		status TransactionStatus
+		err    error
+	}
+	done := make(chan awaitResult, 1)
+	go func() {
+		status, err := h.Await(context.Background(), commonpbv4.Commitment_MAX)
+		done <- awaitResult{status, err}
+	}()
+
+	// The transaction is polled until it reaches the commitment.
+	require.Eventually(t, func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return len(clock.waits) >= 2
+	}, time.Second, time.Millisecond)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.Gets[string(txID)] = transactionpbv4.GetTransactionResponse{
+		State: transactionpbv4.GetTransactionResponse_SUCCESS,
+		Slot:  10,
+	}
+	env.v4Server.Mux.Unlock()
+
+	select {
+	case r := <-done:
+		require.NoError(t, r.err)
+		assert.Equal(t, TransactionStateSuccess, r.status.TxState)
+		assert.EqualValues(t, 10, r.status.Slot)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out awaiting confirmation")
+	}
+
+	assert.Equal(t, commonpbv4.Commitment_MAX, h.Commitment())
+	assert.Equal(t, time.Second, clock.waits[0])
+
+	// Awaiting a lower commitment does not lower the reached commitment.
+	status, err := h.Await(context.Background(), commonpbv4.Commitment_SINGLE)
+	require.NoError(t, err)
+	assert.Equal(t, TransactionStateSuccess, status.TxState)
+	assert.Equal(t, commonpbv4.Commitment_MAX, h.Commitment())
+}
+
+func TestConfirmationHandle_AwaitCancelled(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	txID := make([]byte, 64)
+	h := NewConfirmationHandle(env.client, txID, commonpbv4.Commitment_SINGLE, WithConfirmationPollInterval(time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := h.Await(ctx, commonpbv4.Commitment_MAX)
+	assert.Error(t, err)
+	assert.Equal(t, commonpbv4.Commitment_SINGLE, h.Commitment())
+}
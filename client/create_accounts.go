@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/pkg/errors"
+)
+
+// CreateAccountsResult is the result of a single transaction submitted by
+// CreateAccounts.
+type CreateAccountsResult struct {
+	TxID []byte
+
+	// Owners are the owners of the accounts created by the transaction.
+	Owners []kin.PublicKey
+
+	// If TxError is defined, the transaction failed, and none of its
+	// accounts were created.
+	TxError error
+}
+
+// accountCreationBatch is a set of account creations that fit into a
+// single transaction.
+type accountCreationBatch struct {
+	tx     solana.Transaction
+	keys   []kin.PrivateKey
+	owners []kin.PublicKey
+}
+
+func (c *client) CreateAccounts(ctx context.Context, keys []kin.PrivateKey, opts ...SolanaOption) ([]CreateAccountsResult, error) {
+	solanaOpts := solanaOpts{commitment: c.opts.defaultCommitment}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if err := c.selectSubsidizer(ctx, &solanaOpts); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		if _, ok := seen[string(k.Public())]; ok {
+			return nil, errors.Errorf("duplicate key: %s", k.Public().Base58())
+		}
+		seen[string(k.Public())] = struct{}{}
+	}
+
+	config, err := c.internal.GetServiceConfig(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get service config")
+	}
+
+	var subsidizer ed25519.PublicKey
+	if solanaOpts.subsidizer != nil {
+		subsidizer = ed25519.PublicKey(solanaOpts.subsidizer.Public())
+	} else if len(config.GetSubsidizerAccount().GetValue()) == ed25519.PublicKeySize {
+		subsidizer = config.SubsidizerAccount.Value
+	} else {
+		return nil, ErrNoSubsidizer
+	}
+
+	batches, err := c.buildAccountCreationBatches(keys, subsidizer, config.Token.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]CreateAccountsResult, 0, len(batches))
+	for i, b := range batches {
+		signers := b.keys
+		if solanaOpts.subsidizer != nil {
+			signers = append([]kin.PrivateKey{solanaOpts.subsidizer}, b.keys...)
+		}
+
+		result, err := c.signAndSubmitTx(ctx, signers, b.tx, solanaOpts.commitment, nil, nil, nil)
+		if err != nil {
+			return results, errors.Wrapf(err, "failed to submit transaction %d", i)
+		}
+
+		results = append(results, CreateAccountsResult{
+			TxID:    result.ID,
+			Owners:  b.owners,
+			TxError: result.Errors.TxError,
+		})
+	}
+
+	return results, nil
+}
+
+// buildAccountCreationBatches packs the account creations for keys into as few
+// transactions as possible.
+func (c *client) buildAccountCreationBatches(keys []kin.PrivateKey, subsidizer, mint ed25519.PublicKey) ([]accountCreationBatch, error) {
+	var prefix []solana.Instruction
+	if c.opts.appIndex > 0 {
+		m, err := kin.NewMemo(1, kin.TransactionTypeNone, c.opts.appIndex, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create memo")
+		}
+
+		prefix = append(prefix, memo.Instruction(base64.StdEncoding.EncodeToString(m[:])))
+	}
+
+	var batches []accountCreationBatch
+	current := accountCreationBatch{}
+	instructions := prefix
+
+	for _, key := range keys {
+		owner := ed25519.PublicKey(key.Public())
+
+		create, addr, err := token.CreateAssociatedTokenAccount(subsidizer, owner, mint)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to generate associated token account instruction")
+		}
+		group := []solana.Instruction{
+			create,
+			token.SetAuthority(addr, owner, subsidizer, token.AuthorityTypeCloseAccount),
+		}
+
+		candidate := append(instructions[:len(instructions):len(instructions)], group...)
+		tx := solana.NewTransaction(subsidizer, candidate...)
+		if TransactionSize(tx) > solana.MaxTransactionSize {
+			if len(current.keys) == 0 {
+				return nil, errors.New("account creation exceeds the maximum transaction size")
+			}
+
+			batches = append(batches, current)
+			current = accountCreationBatch{}
+			candidate = append(prefix[:len(prefix):len(prefix)], group...)
+			tx = solana.NewTransaction(subsidizer, candidate...)
+		}
+
+		instructions = candidate
+		current.tx = tx
+		current.keys = append(current.keys, key)
+		current.owners = append(current.owners, key.Public())
+	}
+
+	return append(batches, current), nil
+}
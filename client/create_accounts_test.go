@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client/testutil"
+)
+
+func TestClient_CreateAccounts(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	_, err := env.client.CreateAccounts(context.Background(), nil)
+	assert.NoError(t, err)
+
+	keys := make([]kin.PrivateKey, 20)
+	for i := range keys {
+		keys[i], err = kin.NewPrivateKey()
+		require.NoError(t, err)
+	}
+
+	_, err = env.client.CreateAccounts(context.Background(), append(keys, keys[0]))
+	assert.Error(t, err)
+
+	mint, _, subsidizer := setServiceConfigResp(t, env.v4Server, true)
+
+	results, err := env.client.CreateAccounts(context.Background(), keys)
+	require.NoError(t, err)
+	require.True(t, len(results) > 1)
+	require.True(t, len(results) < len(keys))
+	require.Len(t, env.v4Server.Submits, len(results))
+
+	var owners []kin.PublicKey
+	for i, r := range results {
+		assert.NoError(t, r.TxError)
+
+		var tx solana.Transaction
+		require.NoError(t, tx.Unmarshal(env.v4Server.Submits[i].Transaction.Value))
+		assert.Equal(t, r.TxID, tx.Signature())
+		assert.True(t, TransactionSize(tx) <= solana.MaxTransactionSize)
+		assert.EqualValues(t, subsidizer, tx.Message.Accounts[0])
+
+		// Each transaction contains the app memo, followed by the creation
+		// instructions for each of its accounts.
+		assert.Len(t, tx.Message.Instructions, 1+2*len(r.Owners))
+		parsed, err := kin.ParseTransaction(tx, nil)
+		require.NoError(t, err)
+		require.Len(t, parsed.Regions, 2)
+		require.Len(t, parsed.Regions[1].Creations, len(r.Owners))
+		for j, c := range parsed.Regions[1].Creations {
+			assert.EqualValues(t, r.Owners[j], c.CreateAssoc.Owner)
+			addr, err := token.GetAssociatedAccount(ed25519.PublicKey(r.Owners[j]), mint)
+			require.NoError(t, err)
+			assert.EqualValues(t, addr, c.CreateAssoc.Address)
+		}
+
+		owners = append(owners, r.Owners...)
+	}
+
+	expected := make([]kin.PublicKey, len(keys))
+	for i, k := range keys {
+		expected[i] = k.Public()
+	}
+	assert.Equal(t, expected, owners)
+
+	// With a custom subsidizer, transactions are signed locally.
+	customSubsidizer := testutil.GenerateSolanaKeypair(t)
+	env.v4Server.Submits = nil
+	results, err = env.client.CreateAccounts(context.Background(), keys[:2], WithSubsidizer(kin.PrivateKey(customSubsidizer)))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Len(t, env.v4Server.Submits, 1)
+
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(env.v4Server.Submits[0].Transaction.Value))
+	assert.EqualValues(t, customSubsidizer.Public(), tx.Message.Accounts[0])
+	assert.True(t, ed25519.Verify(customSubsidizer.Public().(ed25519.PublicKey), tx.Message.Marshal(), tx.Signatures[0][:]))
+}
+
+func TestClient_CreateAccountsNoSubsidizer(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, false)
+
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	_, err = env.client.CreateAccounts(context.Background(), []kin.PrivateKey{key})
+	assert.Equal(t, ErrNoSubsidizer, err)
+	assert.Empty(t, env.v4Server.Submits)
+}
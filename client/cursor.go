@@ -0,0 +1,69 @@
+package client
+
+import (
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+)
+
+// Cursor is an opaque position in the transaction history of an account.
+//
+// Agora issues a single kind of cursor regardless of the blockchain the
+// history was recorded on: the cursor of a Kin 2 or Kin 3 transaction encodes
+// its Stellar paging token, and the cursor of a Kin 4 transaction encodes its
+// Solana slot and signature. Apps can therefore persist a Cursor as a resume
+// token for GetHistory, Subscribe, or a reconciliation, without tracking which
+// version of Kin the position refers to.
+//
+// Cursors are ordered by their position in the history, but their encoding is
+// not, so they should not be compared other than for equality.
+type Cursor []byte
+
+// ParseCursor parses a cursor encoded by Cursor.String. An empty string is
+// parsed as the nil cursor, which refers to the start of the history.
+func ParseCursor(s string) (Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cursor")
+	}
+	return b, nil
+}
+
+// String returns the base64 encoding of the cursor, or an empty string if the
+// cursor is nil.
+func (c Cursor) String() string {
+	if len(c) == 0 {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(c)
+}
+
+// IsZero returns whether or not the cursor refers to the start of the history.
+func (c Cursor) IsZero() bool {
+	return len(c) == 0
+}
+
+// Equal returns whether or not c and other refer to the same position.
+func (c Cursor) Equal(other Cursor) bool {
+	return string(c) == string(other)
+}
+
+// MarshalText implements encoding.TextMarshaler, so that cursors are encoded
+// by encoding/json in the same way as byte slices.
+func (c Cursor) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *Cursor) UnmarshalText(text []byte) error {
+	parsed, err := ParseCursor(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
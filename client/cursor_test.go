@@ -0,0 +1,43 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	var zero Cursor
+	assert.True(t, zero.IsZero())
+	assert.Empty(t, zero.String())
+
+	parsed, err := ParseCursor("")
+	require.NoError(t, err)
+	assert.Nil(t, parsed)
+
+	c := Cursor{1, 2, 3, 255}
+	assert.False(t, c.IsZero())
+	assert.True(t, c.Equal(Cursor{1, 2, 3, 255}))
+	assert.False(t, c.Equal(zero))
+
+	parsed, err = ParseCursor(c.String())
+	require.NoError(t, err)
+	assert.Equal(t, c, parsed)
+
+	_, err = ParseCursor("not base64!")
+	assert.Error(t, err)
+
+	// Cursors are encoded by encoding/json in the same way as byte slices, so
+	// that previously persisted cursors remain valid.
+	b, err := json.Marshal(c)
+	require.NoError(t, err)
+	raw, err := json.Marshal([]byte(c))
+	require.NoError(t, err)
+	assert.JSONEq(t, string(raw), string(b))
+
+	var decoded Cursor
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	assert.Equal(t, c, decoded)
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+)
+
+const (
+	// HardenedOffset is added to an index of a derivation path to indicate
+	// hardened derivation. Ed25519 keys only support hardened derivation.
+	HardenedOffset uint32 = 0x80000000
+
+	// KinCoinType is the registered BIP-44 coin type of Kin.
+	KinCoinType uint32 = 2017
+
+	// MinSeedSize and MaxSeedSize are the bounds on the size of a master seed,
+	// in bytes.
+	MinSeedSize = 16
+	MaxSeedSize = 64
+)
+
+// DeriveKey derives a private key from masterSeed along path, using SLIP-0010
+// hardened ed25519 derivation. Every index of path must be hardened (see
+// HardenedOffset).
+func DeriveKey(masterSeed []byte, path ...uint32) (kin.PrivateKey, error) {
+	if len(masterSeed) < MinSeedSize || len(masterSeed) > MaxSeedSize {
+		return nil, errors.Errorf("master seed must be between %d and %d bytes", MinSeedSize, MaxSeedSize)
+	}
+
+	h := hmac.New(sha512.New, []byte("ed25519 seed"))
+	h.Write(masterSeed)
+	sum := h.Sum(nil)
+	key, chainCode := sum[:32], sum[32:]
+
+	data := make([]byte, 1+32+4)
+	for i, index := range path {
+		if index < HardenedOffset {
+			return nil, errors.Errorf("index %d of path is not hardened", i)
+		}
+
+		copy(data[1:], key)
+		binary.BigEndian.PutUint32(data[33:], index)
+
+		h := hmac.New(sha512.New, chainCode)
+		h.Write(data)
+		sum := h.Sum(nil)
+		key, chainCode = sum[:32], sum[32:]
+	}
+
+	return kin.PrivateKey(ed25519.NewKeyFromSeed(key)), nil
+}
+
+// UserKeyPath returns the derivation path of the key of userID, which is
+// m/44'/2017'/ followed by eight hardened indices taken from the SHA-256 hash
+// of userID.
+func UserKeyPath(userID string) []uint32 {
+	h := sha256.Sum256([]byte(userID))
+
+	path := []uint32{44 + HardenedOffset, KinCoinType + HardenedOffset}
+	for i := 0; i < len(h); i += 4 {
+		path = append(path, binary.BigEndian.Uint32(h[i:])|HardenedOffset)
+	}
+	return path
+}
+
+// DeriveUserKey returns the key of userID derived from masterSeed (see DeriveKey
+// and UserKeyPath), allowing custodial apps to recreate the keys of their
+// users from a single master seed, rather than storing each key.
+//
+// The same seed and user ID always produce the same key, so masterSeed must be
+// kept secret, and user IDs must never be reused.
+func DeriveUserKey(masterSeed []byte, userID string) (kin.PrivateKey, error) {
+	if userID == "" {
+		return nil, errors.New("user ID must not be empty")
+	}
+
+	return DeriveKey(masterSeed, UserKeyPath(userID)...)
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeriveKey(t *testing.T) {
+	// Test vector 1 of SLIP-0010 for ed25519.
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		path []uint32
+		key  string
+	}{
+		{
+			path: nil,
+			key:  "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7",
+		},
+		{
+			path: []uint32{0 + HardenedOffset},
+			key:  "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3",
+		},
+		{
+			path: []uint32{0 + HardenedOffset, 1 + HardenedOffset},
+			key:  "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2",
+		},
+	} {
+		key, err := DeriveKey(seed, tc.path...)
+		require.NoError(t, err)
+		assert.Equal(t, tc.key, hex.EncodeToString(ed25519.PrivateKey(key).Seed()))
+	}
+
+	_, err = DeriveKey(seed, 0)
+	assert.Error(t, err)
+	_, err = DeriveKey(seed[:MinSeedSize-1])
+	assert.Error(t, err)
+	_, err = DeriveKey(make([]byte, MaxSeedSize+1))
+	assert.Error(t, err)
+}
+
+func TestDeriveUserKey(t *testing.T) {
+	seed := bytes.Repeat([]byte{1}, 32)
+
+	alice, err := DeriveUserKey(seed, "alice")
+	require.NoError(t, err)
+	again, err := DeriveUserKey(seed, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, alice, again)
+
+	bob, err := DeriveUserKey(seed, "bob")
+	require.NoError(t, err)
+	assert.NotEqual(t, alice, bob)
+
+	other, err := DeriveUserKey(bytes.Repeat([]byte{2}, 32), "alice")
+	require.NoError(t, err)
+	assert.NotEqual(t, alice, other)
+
+	expected, err := DeriveKey(seed, UserKeyPath("alice")...)
+	require.NoError(t, err)
+	assert.Equal(t, expected, alice)
+
+	path := UserKeyPath("alice")
+	require.Len(t, path, 10)
+	assert.Equal(t, 44+HardenedOffset, path[0])
+	assert.Equal(t, KinCoinType+HardenedOffset, path[1])
+	for _, index := range path {
+		assert.True(t, index >= HardenedOffset)
+	}
+
+	_, err = DeriveUserKey(seed, "")
+	assert.Error(t, err)
+}
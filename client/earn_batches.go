@@ -0,0 +1,506 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/pkg/errors"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+)
+
+type sharedBlockhashKey struct{}
+
+// sharedBlockhash is a blockhash shared by the chunks of a larger batch. If a
+// chunk finds that it has expired, it is invalidated so that the remaining
+// chunks fetch a fresh blockhash.
+type sharedBlockhash struct {
+	mu      sync.Mutex
+	hash    solana.Blockhash
+	expired bool
+}
+
+func (s *sharedBlockhash) get() (solana.Blockhash, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hash, !s.expired
+}
+
+func (s *sharedBlockhash) expire(hash solana.Blockhash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hash == hash {
+		s.expired = true
+	}
+}
+
+// WithChunkParallelism specifies the maximum number of chunks SubmitEarnBatches
+// submits concurrently. It defaults to the value of WithMaxConcurrency.
+func WithChunkParallelism(parallelism int) SolanaOption {
+	return func(o *solanaOpts) {
+		o.chunkParallelism = parallelism
+	}
+}
+
+// EarnChunk is a chunk of an EarnBatch that is submitted in its own transaction
+// by SubmitEarnBatches or SubmitEarnBatchAsync.
+type EarnChunk struct {
+	// Index is the index of the chunk, and Count is the number of chunks in
+	// the batch.
+	Index int
+	Count int
+
+	// Earns contains the earns of the chunk. It must not be modified.
+	Earns []Earn
+
+	// Memo is the memo of the chunk. It defaults to the memo of the batch.
+	Memo string
+
+	// Invoices contains the invoice of each earn in the chunk, in order. It
+	// defaults to the invoices of the earns.
+	Invoices []*commonpb.Invoice
+
+	batchID string
+}
+
+// WithChunkFunc specifies a function that customizes the memo or invoices of
+// each chunk submitted by SubmitEarnBatches or SubmitEarnBatchAsync, allowing
+// each transaction to be traced back to its part of the batch.
+//
+// The function is called for every chunk before any are submitted, and the
+// customized chunks are validated as if they were submitted with
+// SubmitEarnBatch. If any are invalid, no chunks are submitted.
+func WithChunkFunc(f func(chunk *EarnChunk)) SolanaOption {
+	return func(o *solanaOpts) {
+		o.chunkFunc = f
+	}
+}
+
+// WithChunkMemoTemplate specifies a template for the memo of each chunk
+// submitted by SubmitEarnBatches or SubmitEarnBatchAsync, such as
+// "payout-{batchID}-{chunk}". The following placeholders are replaced:
+//
+//	{chunk}   the index of the chunk
+//	{chunks}  the number of chunks
+//	{memo}    the memo of the batch
+//	{batchID} the hex-encoded DedupeID of the batch
+//
+// Since a memo cannot be combined with invoices, the earns of the batch must
+// not have invoices. It replaces any function specified with WithChunkFunc.
+func WithChunkMemoTemplate(template string) SolanaOption {
+	return func(o *solanaOpts) {
+		o.chunkFunc = nil
+		if template == "" {
+			return
+		}
+
+		o.chunkFunc = func(chunk *EarnChunk) {
+			chunk.Memo = strings.NewReplacer(
+				"{chunk}", strconv.Itoa(chunk.Index),
+				"{chunks}", strconv.Itoa(chunk.Count),
+				"{memo}", chunk.Memo,
+				"{batchID}", chunk.batchID,
+			).Replace(template)
+		}
+	}
+}
+
+// WithMergeDuplicateEarns specifies that earns in an EarnBatch with the same
+// destination are merged into a single earn.
+//
+// The merged earn takes the place of the first earn with the destination, and
+// EarnErrors and the problems of a *BatchValidationError refer to the position
+// of that earn in the original batch. Earns with invoices, or with different
+// sources, cannot be merged.
+func WithMergeDuplicateEarns() ClientOption {
+	return func(o *clientOpts) {
+		o.mergeDuplicateEarns = true
+	}
+}
+
+// WithRejectDuplicateDestinations specifies that an EarnBatch containing more
+// than one earn with the same destination is rejected with a
+// *BatchValidationError. By default, such batches are submitted as is.
+//
+// If WithMergeDuplicateEarns is also specified, only the earns that cannot be
+// merged are rejected.
+func WithRejectDuplicateDestinations() ClientOption {
+	return func(o *clientOpts) {
+		o.rejectDuplicateDestinations = true
+	}
+}
+
+// mergeDuplicateEarns merges earns without invoices that have the same
+// destination, as long as the merged amount does not exceed MaxSupplyQuarks.
+// If any earns were merged, the merged batch is returned along with the index
+// in batch of each merged earn. Otherwise, batch is returned unchanged with nil
+// indices.
+func mergeDuplicateEarns(batch EarnBatch) (EarnBatch, []int) {
+	positions := make(map[string]int, len(batch.Earns))
+	earns := make([]Earn, 0, len(batch.Earns))
+	indices := make([]int, 0, len(batch.Earns))
+	for i, e := range batch.Earns {
+		if pos, ok := positions[string(e.Destination)]; ok && canMergeEarns(earns[pos], e) {
+			earns[pos].Quarks += e.Quarks
+			continue
+		}
+
+		positions[string(e.Destination)] = len(earns)
+		earns = append(earns, e)
+		indices = append(indices, i)
+	}
+
+	if len(earns) == len(batch.Earns) {
+		return batch, nil
+	}
+
+	batch.Earns = earns
+	return batch, indices
+}
+
+func canMergeEarns(a, b Earn) bool {
+	if a.Invoice != nil || b.Invoice != nil {
+		return false
+	}
+	if string(a.Source) != string(b.Source) || string(a.Owner) != string(b.Owner) {
+		return false
+	}
+	if a.Quarks <= 0 || b.Quarks <= 0 {
+		return false
+	}
+	return a.Quarks <= MaxSupplyQuarks-b.Quarks
+}
+
+// remapEarnErrors replaces the EarnIndex of each error, which refers to an earn
+// in a merged batch, with the index of the earn in the original batch.
+func remapEarnErrors(earnErrors []EarnError, indices []int) {
+	if indices == nil {
+		return
+	}
+
+	for i := range earnErrors {
+		if earnErrors[i].EarnIndex < len(indices) {
+			earnErrors[i].EarnIndex = indices[earnErrors[i].EarnIndex]
+		}
+	}
+}
+
+// remapValidationError replaces the Index of each problem in err, if it is a
+// *BatchValidationError for a merged batch, with the index of the earn in the
+// original batch.
+func remapValidationError(err error, indices []int) error {
+	var validationErr *BatchValidationError
+	if indices == nil || !errors.As(err, &validationErr) {
+		return err
+	}
+
+	problems := make([]BatchValidationProblem, len(validationErr.Problems))
+	for i, p := range validationErr.Problems {
+		if p.Index >= 0 && p.Index < len(indices) {
+			p.Index = indices[p.Index]
+		}
+		problems[i] = p
+	}
+	return &BatchValidationError{Problems: problems}
+}
+
+// EarnChunkResult is the result of a single chunk of a batch submitted with
+// SubmitEarnBatchAsync.
+type EarnChunkResult struct {
+	// Chunk is the index of the chunk. The chunk contains the earns starting
+	// at Chunk*MaxBatchSize in the original batch (Chunk*MaxLookupTableBatchSize
+	// if the batch is submitted using address lookup tables), or in the merged
+	// batch if duplicate earns were merged (see WithMergeDuplicateEarns).
+	Chunk int
+
+	EarnBatchResult
+
+	// Err is set if the chunk could not be submitted.
+	Err error
+}
+
+func (c *client) SubmitEarnBatches(ctx context.Context, batch EarnBatch, opts ...SolanaOption) ([]EarnBatchResult, error) {
+	chunks, indices, err := c.prepareEarnBatchChunks(ctx, batch, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]EarnBatchResult, len(chunks))
+	errs := make([]error, len(chunks))
+	err = c.submitEarnBatchChunks(ctx, chunks, indices, opts, func(r EarnChunkResult) {
+		results[r.Chunk], errs[r.Chunk] = r.EarnBatchResult, r.Err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Errors are returned in chunk order so that the result is deterministic.
+	for i, err := range errs {
+		if err != nil {
+			return results, errors.Wrapf(err, "failed to submit chunk %d", i)
+		}
+	}
+
+	return results, nil
+}
+
+func (c *client) SubmitEarnBatchAsync(ctx context.Context, batch EarnBatch, opts ...SolanaOption) (<-chan EarnChunkResult, error) {
+	chunks, indices, err := c.prepareEarnBatchChunks(ctx, batch, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The channel is large enough to hold every result, so that submission
+	// isn't blocked by a slow consumer.
+	results := make(chan EarnChunkResult, len(chunks))
+	go func() {
+		defer close(results)
+
+		err := c.submitEarnBatchChunks(ctx, chunks, indices, opts, func(r EarnChunkResult) {
+			results <- r
+		})
+		if err != nil {
+			for i := range chunks {
+				results <- EarnChunkResult{Chunk: i, Err: err}
+			}
+		}
+	}()
+
+	return results, nil
+}
+
+// prepareEarnBatchChunks validates and splits batch into chunks, customizing them
+// if a chunk function was specified. If duplicate earns were merged, the index of
+// each merged earn in batch is also returned.
+//
+// Every earn is screened, and the batch as a whole checked against the configured
+// limits, before any chunk is submitted, so that a rejected earn in a later chunk
+// doesn't leave the earlier chunks paid.
+func (c *client) prepareEarnBatchChunks(ctx context.Context, batch EarnBatch, opts []SolanaOption) ([]EarnBatch, []int, error) {
+	if len(batch.Earns) == 0 {
+		return nil, nil, errors.New("earn batch must contain at least 1 earn")
+	}
+
+	var indices []int
+	if c.opts.mergeDuplicateEarns {
+		batch, indices = mergeDuplicateEarns(batch)
+	}
+
+	// Validate the entire batch up front, rather than failing part way through.
+	if err := c.validateEarnBatch(batch); err != nil {
+		return nil, nil, remapValidationError(err, indices)
+	}
+
+	chunks := earnBatchChunks(batch, c.maxEarnBatchSize(batch))
+
+	var solanaOpts solanaOpts
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+	if solanaOpts.chunkFunc != nil {
+		var err error
+		if chunks, err = c.customizeEarnChunks(chunks, batch.DedupeID, solanaOpts.chunkFunc); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := c.screenEarns(ctx, chunks...); err != nil {
+		return nil, nil, err
+	}
+
+	return chunks, indices, nil
+}
+
+// withPrescreened indicates that the earns being submitted were already screened
+// and checked against the limits.
+func withPrescreened() SolanaOption {
+	return func(o *solanaOpts) {
+		o.prescreened = true
+	}
+}
+
+// screenEarns screens each earn in batches, and checks their total against the
+// configured limits, recording the earns towards them if it is allowed.
+func (c *client) screenEarns(ctx context.Context, batches ...EarnBatch) error {
+	var entries []limitEntry
+	for _, batch := range batches {
+		for _, p := range earnPayments(batch) {
+			if err := c.screen(p); err != nil {
+				return err
+			}
+		}
+		for _, e := range batch.Earns {
+			entries = append(entries, limitEntry{destination: e.Destination, quarks: e.Quarks})
+		}
+	}
+	if limits := c.limits(); limits != nil {
+		if err := limits.check(ctx, c.opts.appIndex, entries, c.opts.clock.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// customizeEarnChunks applies f to each chunk, returning the customized chunks.
+// batchID is the DedupeID of the batch the chunks were split from.
+func (c *client) customizeEarnChunks(chunks []EarnBatch, batchID []byte, f func(*EarnChunk)) ([]EarnBatch, error) {
+	customized := make([]EarnBatch, len(chunks))
+	for i, chunk := range chunks {
+		ec := &EarnChunk{
+			Index:    i,
+			Count:    len(chunks),
+			Earns:    chunk.Earns,
+			Memo:     chunk.Memo,
+			Invoices: make([]*commonpb.Invoice, len(chunk.Earns)),
+			batchID:  hex.EncodeToString(batchID),
+		}
+		for j, e := range chunk.Earns {
+			ec.Invoices[j] = e.Invoice
+		}
+
+		f(ec)
+
+		if len(ec.Invoices) != len(chunk.Earns) {
+			return nil, errors.Errorf("chunk %d: expected %d invoices, got %d", i, len(chunk.Earns), len(ec.Invoices))
+		}
+
+		chunk.Memo = ec.Memo
+		chunk.Earns = make([]Earn, len(ec.Earns))
+		for j, e := range ec.Earns {
+			e.Invoice = ec.Invoices[j]
+			chunk.Earns[j] = e
+		}
+
+		if err := c.validateEarnBatch(chunk); err != nil {
+			return nil, errors.Wrapf(err, "chunk %d", i)
+		}
+		customized[i] = chunk
+	}
+
+	return customized, nil
+}
+
+// submitEarnBatchChunks submits the chunks concurrently, calling f with the result
+// of each chunk as it completes. Calls to f are serialized.
+//
+// An error is only returned if no chunks were submitted. If indices is set, the
+// chunks are of a merged batch, and EarnErrors are remapped to the original batch.
+func (c *client) submitEarnBatchChunks(ctx context.Context, chunks []EarnBatch, indices []int, opts []SolanaOption, f func(EarnChunkResult)) error {
+	solanaOpts := solanaOpts{chunkParallelism: c.concurrency()}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+	if solanaOpts.chunkParallelism <= 0 {
+		solanaOpts.chunkParallelism = 1
+	}
+
+	// The chunks were screened and checked against the limits as a whole when
+	// they were prepared, so SubmitEarnBatch must not do so again.
+	chunkOpts := append(append([]SolanaOption(nil), opts...), withPrescreened())
+
+	// All chunks are signed with the same blockhash, rather than each fetching their own.
+	if len(chunks) > 1 {
+		blockhash, err := c.internal.GetRecentBlockhash(ctx)
+		if err != nil {
+			return err
+		}
+		ctx = context.WithValue(ctx, sharedBlockhashKey{}, &sharedBlockhash{hash: blockhash})
+	}
+
+	// The index of the first earn of each chunk in the batch.
+	offsets := make([]int, len(chunks))
+	for i := 1; i < len(chunks); i++ {
+		offsets[i] = offsets[i-1] + len(chunks[i-1].Earns)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var failed bool
+	sem := make(chan struct{}, solanaOpts.chunkParallelism)
+
+	for i := range chunks {
+		sem <- struct{}{}
+
+		mu.Lock()
+		if failed {
+			f(EarnChunkResult{Chunk: i, EarnBatchResult: EarnBatchResult{TxError: ErrEarnBatchAborted}})
+			mu.Unlock()
+			<-sem
+			continue
+		}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			opts := chunkOpts
+			if progress := solanaOpts.progress; progress != nil {
+				opts = append(opts[:len(opts):len(opts)], WithProgress(func(p EarnBatchProgress) {
+					p.Chunk = i
+					progress(p)
+				}))
+			}
+
+			result, err := c.SubmitEarnBatch(ctx, chunks[i], opts...)
+
+			for j := range result.EarnErrors {
+				result.EarnErrors[j].EarnIndex += offsets[i]
+			}
+			remapEarnErrors(result.EarnErrors, indices)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil || result.TxError != nil {
+				failed = true
+			}
+			f(EarnChunkResult{Chunk: i, EarnBatchResult: result, Err: err})
+		}(i)
+	}
+
+	wg.Wait()
+	return nil
+}
+
+// earnBatchChunks splits a batch into chunks of at most size earns.
+//
+// If the batch has a DedupeID, each chunk is given a distinct DedupeID derived
+// from it, so that the batch as a whole may be safely resubmitted.
+func earnBatchChunks(batch EarnBatch, size int) []EarnBatch {
+	chunks := make([]EarnBatch, 0, (len(batch.Earns)+size-1)/size)
+	for start := 0; start < len(batch.Earns); start += size {
+		end := start + size
+		if end > len(batch.Earns) {
+			end = len(batch.Earns)
+		}
+
+		chunk := batch
+		chunk.Earns = batch.Earns[start:end:end]
+		if len(batch.DedupeID) > 0 && len(batch.Earns) > size {
+			chunk.DedupeID = chunkDedupeID(batch.DedupeID, len(chunks))
+		}
+
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+func chunkDedupeID(dedupeID []byte, chunk int) []byte {
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], uint32(chunk))
+
+	h := sha256.New()
+	_, _ = h.Write(dedupeID)
+	_, _ = h.Write(index[:])
+	return h.Sum(nil)
+}
@@ -0,0 +1,506 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+func generateLargeEarnBatch(t *testing.T, env *testEnv, n int) EarnBatch {
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	require.NoError(t, env.client.CreateAccount(context.Background(), sender))
+
+	batch := EarnBatch{
+		Sender: sender,
+		Earns:  make([]Earn, n),
+	}
+	for i := range batch.Earns {
+		dest, err := kin.NewPrivateKey()
+		require.NoError(t, err)
+
+		batch.Earns[i] = Earn{
+			Destination: dest.Public(),
+			Quarks:      int64(i) + 1,
+		}
+	}
+
+	return batch
+}
+
+func TestClient_SubmitEarnBatches(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	batch := generateLargeEarnBatch(t, env, 2*MaxBatchSize+5)
+	batch.DedupeID = []byte("dedupe")
+
+	results, err := env.client.SubmitEarnBatches(context.Background(), batch, WithChunkParallelism(3))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for _, r := range results {
+		assert.NotNil(t, r.TxID)
+		assert.Nil(t, r.TxError)
+	}
+
+	env.v4Server.Mux.Lock()
+	defer env.v4Server.Mux.Unlock()
+	require.Len(t, env.v4Server.Submits, 3)
+
+	dedupeIDs := make(map[string]struct{})
+	transfers := make(map[string]uint64)
+	for _, submit := range env.v4Server.Submits {
+		dedupeIDs[string(submit.DedupeId)] = struct{}{}
+
+		var tx solana.Transaction
+		require.NoError(t, tx.Unmarshal(submit.Transaction.Value))
+		payments, err := kin.ParseTransaction(tx, nil)
+		require.NoError(t, err)
+		for _, r := range payments.Regions {
+			for _, transfer := range r.Transfers {
+				transfers[string(transfer.Destination)] = transfer.Amount
+			}
+		}
+	}
+
+	// Each chunk has a distinct DedupeID derived from the batch's.
+	assert.Len(t, dedupeIDs, 3)
+	assert.NotContains(t, dedupeIDs, "dedupe")
+
+	require.Len(t, transfers, len(batch.Earns))
+	for _, e := range batch.Earns {
+		assert.EqualValues(t, e.Quarks, transfers[string(e.Destination)])
+	}
+}
+
+func TestClient_SubmitEarnBatchesFailure(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	batch := generateLargeEarnBatch(t, env, 3*MaxBatchSize)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		nil,
+		{
+			Result: transactionpbv4.SubmitTransactionResponse_FAILED,
+			TransactionError: &commonpbv4.TransactionError{
+				Reason: commonpbv4.TransactionError_INSUFFICIENT_FUNDS,
+				// The memo is the first instruction.
+				InstructionIndex: 3,
+				Raw:              []byte("rawerror"),
+			},
+		},
+	}
+	env.v4Server.Mux.Unlock()
+
+	results, err := env.client.SubmitEarnBatches(context.Background(), batch, WithChunkParallelism(1))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.NotNil(t, results[0].TxID)
+	assert.Nil(t, results[0].TxError)
+
+	assert.NotNil(t, results[1].TxID)
+	assert.Equal(t, ErrInsufficientBalance, results[1].TxError)
+	require.Len(t, results[1].EarnErrors, MaxBatchSize)
+	for i, e := range results[1].EarnErrors {
+		assert.Equal(t, MaxBatchSize+i, e.EarnIndex)
+		if i == 2 {
+			assert.Equal(t, ErrInsufficientBalance, e.Error)
+		} else {
+			assert.Nil(t, e.Error)
+		}
+	}
+
+	assert.Nil(t, results[2].TxID)
+	assert.Equal(t, ErrEarnBatchAborted, results[2].TxError)
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.Submits, 2)
+	env.v4Server.Mux.Unlock()
+
+	// The entire batch is validated before anything is submitted.
+	batch.Earns[len(batch.Earns)-1].Invoice = &commonpb.Invoice{}
+	_, err = env.client.SubmitEarnBatches(context.Background(), batch)
+	assert.Error(t, err)
+
+	_, err = env.client.SubmitEarnBatches(context.Background(), EarnBatch{})
+	assert.Error(t, err)
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.Submits, 2)
+	env.v4Server.Mux.Unlock()
+}
+
+func TestClient_SubmitEarnBatchesScreenedUpFront(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	batch := generateLargeEarnBatch(t, env, 3*MaxBatchSize)
+	denied := batch.Earns[len(batch.Earns)-1].Destination
+
+	// An earn in the last chunk is screened, so no chunk is submitted.
+	WithPaymentScreener(func(p Payment) error {
+		if bytes.Equal(p.Destination, denied) {
+			return errors.New("denied")
+		}
+		return nil
+	})(&env.client.opts)
+
+	_, err := env.client.SubmitEarnBatches(context.Background(), batch, WithChunkParallelism(1))
+	assert.Equal(t, ErrPaymentScreened, errors.Cause(err))
+
+	_, err = env.client.SubmitEarnBatchAsync(context.Background(), batch)
+	assert.Equal(t, ErrPaymentScreened, errors.Cause(err))
+
+	// An earn in the last chunk exceeds the limits, so no chunk is submitted.
+	WithPaymentScreener(nil)(&env.client.opts)
+	WithLimits(Limits{MaxPaymentQuarks: int64(len(batch.Earns)) - 1})(&env.client.opts)
+
+	_, err = env.client.SubmitEarnBatches(context.Background(), batch, WithChunkParallelism(1))
+	assert.Equal(t, ErrLimitExceeded, errors.Cause(err))
+
+	env.v4Server.Mux.Lock()
+	assert.Empty(t, env.v4Server.Submits)
+	env.v4Server.Mux.Unlock()
+
+	// Once allowed, the chunks aren't checked against the limits a second time.
+	WithLimits(Limits{MaxDestinationDailyQuarks: int64(len(batch.Earns))})(&env.client.opts)
+	results, err := env.client.SubmitEarnBatches(context.Background(), batch)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.Submits, 3)
+	env.v4Server.Mux.Unlock()
+}
+
+func TestEarnBatchChunks(t *testing.T) {
+	batch := EarnBatch{
+		Earns:    make([]Earn, MaxBatchSize),
+		DedupeID: []byte("dedupe"),
+	}
+
+	// A batch that fits in a single transaction is unchanged.
+	chunks := earnBatchChunks(batch, MaxBatchSize)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, batch, chunks[0])
+
+	batch.Earns = make([]Earn, MaxBatchSize+1)
+	chunks = earnBatchChunks(batch, MaxBatchSize)
+	require.Len(t, chunks, 2)
+	assert.Len(t, chunks[0].Earns, MaxBatchSize)
+	assert.Len(t, chunks[1].Earns, 1)
+	assert.Equal(t, chunkDedupeID(batch.DedupeID, 0), chunks[0].DedupeID)
+	assert.Equal(t, chunkDedupeID(batch.DedupeID, 1), chunks[1].DedupeID)
+	assert.NotEqual(t, chunks[0].DedupeID, chunks[1].DedupeID)
+
+	// Appending to a chunk must not modify the next chunk.
+	chunks[0].Earns = append(chunks[0].Earns, Earn{Quarks: 10})
+	assert.Zero(t, chunks[1].Earns[0].Quarks)
+}
+
+func TestMergeDuplicateEarns(t *testing.T) {
+	a, b, c := make(kin.PublicKey, 32), make(kin.PublicKey, 32), make(kin.PublicKey, 32)
+	a[0], b[0], c[0] = 1, 2, 3
+
+	batch := EarnBatch{
+		Earns: []Earn{
+			{Destination: a, Quarks: 1},
+			{Destination: b, Quarks: 2},
+			{Destination: c, Quarks: 3},
+		},
+	}
+
+	// A batch without duplicates is unchanged.
+	merged, indices := mergeDuplicateEarns(batch)
+	assert.Equal(t, batch, merged)
+	assert.Nil(t, indices)
+
+	batch.Earns = append(batch.Earns, Earn{Destination: a, Quarks: 4}, Earn{Destination: c, Quarks: 5})
+	merged, indices = mergeDuplicateEarns(batch)
+	assert.Equal(t, []Earn{
+		{Destination: a, Quarks: 5},
+		{Destination: b, Quarks: 2},
+		{Destination: c, Quarks: 8},
+	}, merged.Earns)
+	assert.Equal(t, []int{0, 1, 2}, indices)
+
+	// The original batch is not modified.
+	assert.EqualValues(t, 1, batch.Earns[0].Quarks)
+
+	// Earns with invoices are not merged.
+	invoice := &commonpb.Invoice{}
+	batch.Earns = []Earn{
+		{Destination: a, Quarks: 1, Invoice: invoice},
+		{Destination: a, Quarks: 2, Invoice: invoice},
+	}
+	merged, indices = mergeDuplicateEarns(batch)
+	assert.Equal(t, batch, merged)
+	assert.Nil(t, indices)
+
+	// Nor are earns whose total would exceed the supply.
+	batch.Earns = []Earn{
+		{Destination: a, Quarks: MaxSupplyQuarks},
+		{Destination: a, Quarks: 1},
+	}
+	merged, indices = mergeDuplicateEarns(batch)
+	assert.Equal(t, batch, merged)
+	assert.Nil(t, indices)
+}
+
+func TestClient_SubmitEarnBatchMergeDuplicates(t *testing.T) {
+	env, cleanup := setup(t, WithMergeDuplicateEarns())
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	batch := generateLargeEarnBatch(t, env, 3)
+	batch.Earns = []Earn{
+		batch.Earns[0],
+		batch.Earns[1],
+		{Destination: batch.Earns[0].Destination, Quarks: 10},
+		batch.Earns[2],
+	}
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		{
+			Result: transactionpbv4.SubmitTransactionResponse_FAILED,
+			TransactionError: &commonpbv4.TransactionError{
+				Reason:           commonpbv4.TransactionError_INSUFFICIENT_FUNDS,
+				InstructionIndex: 3,
+				Raw:              []byte("rawerror"),
+			},
+		},
+	}
+	env.v4Server.Mux.Unlock()
+
+	result, err := env.client.SubmitEarnBatch(context.Background(), batch)
+	require.NoError(t, err)
+	assert.Equal(t, ErrInsufficientBalance, result.TxError)
+
+	// EarnErrors refer to the earns of the original batch.
+	require.Len(t, result.EarnErrors, 3)
+	assert.Equal(t, []int{0, 1, 3}, []int{result.EarnErrors[0].EarnIndex, result.EarnErrors[1].EarnIndex, result.EarnErrors[2].EarnIndex})
+	assert.Equal(t, ErrInsufficientBalance, result.EarnErrors[2].Error)
+
+	results, err := env.client.SubmitEarnBatches(context.Background(), batch)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Nil(t, results[0].TxError)
+
+	env.v4Server.Mux.Lock()
+	defer env.v4Server.Mux.Unlock()
+	require.Len(t, env.v4Server.Submits, 2)
+
+	for _, submit := range env.v4Server.Submits {
+		var tx solana.Transaction
+		require.NoError(t, tx.Unmarshal(submit.Transaction.Value))
+		payments, err := kin.ParseTransaction(tx, nil)
+		require.NoError(t, err)
+
+		transfers := make(map[string]uint64)
+		for _, r := range payments.Regions {
+			for _, transfer := range r.Transfers {
+				transfers[string(transfer.Destination)] += transfer.Amount
+			}
+		}
+		assert.Len(t, transfers, 3)
+		assert.EqualValues(t, batch.Earns[0].Quarks+10, transfers[string(batch.Earns[0].Destination)])
+	}
+}
+
+func TestClient_SubmitEarnBatchMergeDuplicatesInvalid(t *testing.T) {
+	env, cleanup := setup(t, WithMergeDuplicateEarns())
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	batch := generateLargeEarnBatch(t, env, 3)
+	batch.Earns = []Earn{
+		batch.Earns[0],
+		{Destination: batch.Earns[0].Destination, Quarks: 10},
+		batch.Earns[1],
+		{Destination: batch.Earns[2].Destination, Quarks: -1},
+	}
+
+	// Problems refer to the earns of the original batch.
+	_, err := env.client.SubmitEarnBatch(context.Background(), batch)
+	validationErr, ok := err.(*BatchValidationError)
+	require.True(t, ok)
+	require.Len(t, validationErr.Problems, 1)
+	assert.Equal(t, 3, validationErr.Problems[0].Index)
+	assert.Equal(t, "Quarks", validationErr.Problems[0].Field)
+
+	_, err = env.client.SubmitEarnBatches(context.Background(), batch)
+	validationErr, ok = err.(*BatchValidationError)
+	require.True(t, ok)
+	require.Len(t, validationErr.Problems, 1)
+	assert.Equal(t, 3, validationErr.Problems[0].Index)
+}
+
+func TestClient_SubmitEarnBatchesChunkMemo(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	batch := generateLargeEarnBatch(t, env, 2*MaxBatchSize+5)
+	batch.DedupeID = []byte{0xab, 0xcd}
+
+	_, err := env.client.SubmitEarnBatches(context.Background(), batch, WithChunkParallelism(1), WithChunkMemoTemplate("payout-{batchID}-{chunk}/{chunks}"))
+	require.NoError(t, err)
+
+	memos := func() []string {
+		env.v4Server.Mux.Lock()
+		defer env.v4Server.Mux.Unlock()
+
+		var memos []string
+		for _, submit := range env.v4Server.Submits {
+			var tx solana.Transaction
+			require.NoError(t, tx.Unmarshal(submit.Transaction.Value))
+			m, err := memo.DecompileMemo(tx.Message, 0)
+			require.NoError(t, err)
+			memos = append(memos, string(m.Data))
+		}
+		env.v4Server.Submits = nil
+		return memos
+	}
+	assert.Equal(t, []string{"payout-abcd-0/3", "payout-abcd-1/3", "payout-abcd-2/3"}, memos())
+
+	// Chunks may instead be given their own invoices.
+	env.client.opts.appIndex = 1
+	var chunks []int
+	_, err = env.client.SubmitEarnBatches(context.Background(), batch, WithChunkParallelism(1), WithChunkFunc(func(chunk *EarnChunk) {
+		chunks = append(chunks, len(chunk.Earns))
+		for i := range chunk.Invoices {
+			chunk.Invoices[i] = &commonpb.Invoice{
+				Items: []*commonpb.Invoice_LineItem{{Title: fmt.Sprintf("chunk %d", chunk.Index), Amount: 1}},
+			}
+		}
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, []int{MaxBatchSize, MaxBatchSize, 5}, chunks)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 3)
+	for i, submit := range env.v4Server.Submits {
+		require.NotNil(t, submit.InvoiceList)
+		assert.Equal(t, fmt.Sprintf("chunk %d", i), submit.InvoiceList.Invoices[0].Items[0].Title)
+	}
+	env.v4Server.Submits = nil
+	env.v4Server.Mux.Unlock()
+
+	// Invalid chunks are rejected before any are submitted.
+	batch.Earns[0].Invoice = &commonpb.Invoice{Items: []*commonpb.Invoice_LineItem{{Title: "test", Amount: 1}}}
+	_, err = env.client.SubmitEarnBatches(context.Background(), batch, WithChunkMemoTemplate("payout-{chunk}"))
+	assert.Error(t, err)
+
+	_, err = env.client.SubmitEarnBatchAsync(context.Background(), batch, WithChunkFunc(func(chunk *EarnChunk) {
+		chunk.Invoices = nil
+	}))
+	assert.Error(t, err)
+	assert.Empty(t, memos())
+}
+
+func TestClient_SubmitEarnBatchAsync(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	batch := generateLargeEarnBatch(t, env, 3*MaxBatchSize)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		nil,
+		{
+			Result: transactionpbv4.SubmitTransactionResponse_FAILED,
+			TransactionError: &commonpbv4.TransactionError{
+				Reason:           commonpbv4.TransactionError_INSUFFICIENT_FUNDS,
+				InstructionIndex: 1,
+				Raw:              []byte("rawerror"),
+			},
+		},
+	}
+	env.v4Server.Mux.Unlock()
+
+	results, err := env.client.SubmitEarnBatchAsync(context.Background(), batch, WithChunkParallelism(1))
+	require.NoError(t, err)
+
+	var received []EarnChunkResult
+	for r := range results {
+		received = append(received, r)
+	}
+
+	// With a parallelism of 1, results are received in chunk order.
+	require.Len(t, received, 3)
+	for i, r := range received {
+		assert.Equal(t, i, r.Chunk)
+		assert.NoError(t, r.Err)
+	}
+	assert.Nil(t, received[0].TxError)
+	assert.Equal(t, ErrInsufficientBalance, received[1].TxError)
+	assert.Equal(t, MaxBatchSize, received[1].EarnErrors[0].EarnIndex)
+	assert.Equal(t, ErrEarnBatchAborted, received[2].TxError)
+
+	// Invalid batches are rejected synchronously.
+	results, err = env.client.SubmitEarnBatchAsync(context.Background(), EarnBatch{})
+	assert.Error(t, err)
+	assert.Nil(t, results)
+
+	// Failures that occur before any chunk is submitted are reported on every chunk.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = env.client.SubmitEarnBatchAsync(ctx, batch)
+	require.NoError(t, err)
+
+	received = received[:0]
+	for r := range results {
+		received = append(received, r)
+	}
+	require.Len(t, received, 3)
+	for i, r := range received {
+		assert.Equal(t, i, r.Chunk)
+		assert.Error(t, r.Err)
+	}
+}
+
+func TestSharedBlockhash(t *testing.T) {
+	var hash, other solana.Blockhash
+	hash[0], other[0] = 1, 2
+
+	s := &sharedBlockhash{hash: hash}
+	actual, ok := s.get()
+	assert.True(t, ok)
+	assert.Equal(t, hash, actual)
+
+	// Only the shared blockhash can expire it.
+	s.expire(other)
+	_, ok = s.get()
+	assert.True(t, ok)
+
+	s.expire(hash)
+	_, ok = s.get()
+	assert.False(t, ok)
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/pkg/errors"
+)
+
+// EventEnricher converts webhook events into TransactionData, so that
+// event consumers receive the same data as consumers using GetTransaction.
+//
+// Events are parsed locally where possible. GetTransaction is only used
+// when the event is missing data, such as when the transaction references
+// an invoice list that was not included, or when the transaction failed.
+type EventEnricher struct {
+	client  Client
+	filters []FilterOption
+}
+
+// NewEventEnricher returns a new EventEnricher.
+//
+// If filters are specified, EnrichAll (and EventsFunc) only return
+// the payments matching the filters, and omit transactions without
+// any matching payments.
+func NewEventEnricher(c Client, filters ...FilterOption) *EventEnricher {
+	return &EventEnricher{
+		client:  c,
+		filters: filters,
+	}
+}
+
+// Enrich returns the TransactionData for the specified event.
+func (e *EventEnricher) Enrich(ctx context.Context, event events.TransactionEvent) (TransactionData, error) {
+	if event.SolanaEvent == nil || len(event.SolanaEvent.Transaction) == 0 || event.SolanaEvent.TransactionError != "" {
+		return e.fetch(ctx, event.TxID)
+	}
+
+	var tx solana.Transaction
+	if err := tx.Unmarshal(event.SolanaEvent.Transaction); err != nil {
+		return TransactionData{}, errors.Wrap(err, "invalid solana transaction")
+	}
+
+	if event.InvoiceList == nil && hasInvoiceForeignKey(tx) {
+		return e.fetch(ctx, tx.Signature())
+	}
+
+	// Transactions that cannot be parsed locally are fetched, since Agora
+	// may still be able to provide the payments.
+	_, payments, err := parseTransaction(tx, event.InvoiceList)
+	if err != nil {
+		return e.fetch(ctx, tx.Signature())
+	}
+
+	return TransactionData{
+		TxID:     tx.Signature(),
+		TxState:  TransactionStateSuccess,
+		Payments: payments,
+	}, nil
+}
+
+// EnrichAll returns the TransactionData for each transaction event in events.
+// Events without a transaction event are skipped.
+func (e *EventEnricher) EnrichAll(ctx context.Context, events []events.Event) ([]TransactionData, error) {
+	data := make([]TransactionData, 0, len(events))
+	for _, event := range events {
+		if event.TransactionEvent == nil {
+			continue
+		}
+
+		d, err := e.Enrich(ctx, *event.TransactionEvent)
+		if err != nil {
+			return nil, err
+		}
+
+		if d, ok := FilterPayments(d, e.filters...); ok {
+			data = append(data, d)
+		}
+	}
+
+	return data, nil
+}
+
+// EventsFunc returns an EventsFunc that enriches events before forwarding
+// them to f. It can be used with EventsHandler.
+func (e *EventEnricher) EventsFunc(f func([]TransactionData) error) EventsFunc {
+	return func(events []events.Event) error {
+		data, err := e.EnrichAll(context.Background(), events)
+		if err != nil {
+			return err
+		}
+
+		return f(data)
+	}
+}
+
+func (e *EventEnricher) fetch(ctx context.Context, txID []byte) (TransactionData, error) {
+	if len(txID) == 0 {
+		return TransactionData{}, errors.New("event has no transaction id")
+	}
+
+	data, err := e.client.GetTransaction(ctx, txID)
+	if err != nil {
+		return TransactionData{}, err
+	}
+	if data.TxState == TransactionStateUnknown {
+		return TransactionData{}, ErrTransactionNotFound
+	}
+
+	return data, nil
+}
+
+// hasInvoiceForeignKey returns whether or not the transaction contains a
+// kin memo referencing an invoice list.
+func hasInvoiceForeignKey(tx solana.Transaction) bool {
+	for i := range tx.Message.Instructions {
+		m, err := memo.DecompileMemo(tx.Message, i)
+		if err != nil {
+			continue
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(string(m.Data))
+		if err != nil || len(raw) != len(kin.Memo{}) {
+			continue
+		}
+
+		var decoded kin.Memo
+		copy(decoded[:], raw)
+		if !kin.IsValidMemoStrict(decoded) {
+			continue
+		}
+
+		for _, b := range decoded.ForeignKey()[:28] {
+			if b != 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+
+	"github.com/kinecosystem/kin-go/client/testutil"
+)
+
+func TestEventEnricher(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	enricher := NewEventEnricher(env.client)
+
+	// Text memo transactions are parsed locally, so the transaction
+	// does not need to be known by the server.
+	tx, _ := generateEventTransaction(t, false)
+	data, err := enricher.Enrich(context.Background(), events.TransactionEvent{
+		KinVersion:  4,
+		TxID:        tx.Signature(),
+		SolanaEvent: &events.SolanaEvent{Transaction: tx.Marshal()},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, tx.Signature(), data.TxID)
+	assert.Equal(t, TransactionStateSuccess, data.TxState)
+	require.Len(t, data.Payments, 3)
+	for i, p := range data.Payments {
+		assert.Equal(t, "1-test", p.Memo)
+		assert.EqualValues(t, i+1, p.Quarks)
+	}
+
+	// Transactions with invoices are parsed locally if the invoice list is present.
+	tx, il := generateEventTransaction(t, true)
+	event := events.TransactionEvent{
+		KinVersion:  4,
+		TxID:        tx.Signature(),
+		InvoiceList: il,
+		SolanaEvent: &events.SolanaEvent{Transaction: tx.Marshal()},
+	}
+	data, err = enricher.Enrich(context.Background(), event)
+	require.NoError(t, err)
+	require.Len(t, data.Payments, 3)
+	for i, p := range data.Payments {
+		assert.Equal(t, kin.TransactionTypeSpend, p.Type)
+		assert.True(t, proto.Equal(il.Invoices[i], p.Invoice))
+	}
+
+	// Otherwise, the transaction is fetched.
+	event.InvoiceList = nil
+	_, err = enricher.Enrich(context.Background(), event)
+	assert.Equal(t, ErrTransactionNotFound, err)
+
+	resp := transactionpbv4.GetTransactionResponse{
+		State: transactionpbv4.GetTransactionResponse_SUCCESS,
+		Item: &transactionpbv4.HistoryItem{
+			TransactionId: &commonpbv4.TransactionId{Value: tx.Signature()},
+			RawTransaction: &transactionpbv4.HistoryItem_SolanaTransaction{
+				SolanaTransaction: &commonpbv4.Transaction{Value: tx.Marshal()},
+			},
+			InvoiceList: il,
+		},
+	}
+	for i := range il.Invoices {
+		transfer, err := token.DecompileTransfer(tx.Message, i+1)
+		require.NoError(t, err)
+
+		resp.Item.Payments = append(resp.Item.Payments, &transactionpbv4.HistoryItem_Payment{
+			Source:      &commonpbv4.SolanaAccountId{Value: transfer.Source},
+			Destination: &commonpbv4.SolanaAccountId{Value: transfer.Destination},
+			Amount:      int64(transfer.Amount),
+			Index:       uint32(i),
+		})
+	}
+	env.v4Server.Mux.Lock()
+	env.v4Server.Gets[string(tx.Signature())] = resp
+	env.v4Server.Mux.Unlock()
+
+	all, err := enricher.EnrichAll(context.Background(), []events.Event{
+		{TransactionEvent: &event},
+		{},
+	})
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	require.Len(t, all[0].Payments, 3)
+	for i, p := range all[0].Payments {
+		assert.True(t, proto.Equal(il.Invoices[i], p.Invoice))
+	}
+
+	// Failed transactions are always fetched, to populate the errors.
+	event.InvoiceList = il
+	event.SolanaEvent.TransactionError = "failed"
+	resp.State = transactionpbv4.GetTransactionResponse_FAILED
+	resp.Item.TransactionError = &commonpbv4.TransactionError{
+		Reason: commonpbv4.TransactionError_UNAUTHORIZED,
+		Raw:    []byte("rawerror"),
+	}
+	env.v4Server.Mux.Lock()
+	env.v4Server.Gets[string(tx.Signature())] = resp
+	env.v4Server.Mux.Unlock()
+
+	var called bool
+	err = enricher.EventsFunc(func(data []TransactionData) error {
+		called = true
+		require.Len(t, data, 1)
+		assert.Equal(t, TransactionStateFailed, data[0].TxState)
+		assert.Equal(t, ErrInvalidSignature, data[0].Errors.TxError)
+		return nil
+	})([]events.Event{{TransactionEvent: &event}})
+	require.NoError(t, err)
+	assert.True(t, called)
+}
+
+func generateEventTransaction(t *testing.T, useInvoice bool) (solana.Transaction, *commonpb.InvoiceList) {
+	subsidizer := testutil.GenerateSolanaKeypair(t)
+	sender := testutil.GenerateSolanaKeypair(t)
+
+	var il *commonpb.InvoiceList
+	var instructions []solana.Instruction
+	if useInvoice {
+		var hash []byte
+		hash, il = generateInvoiceList(t, 3)
+		m, err := kin.NewMemo(1, kin.TransactionTypeSpend, 1, hash)
+		require.NoError(t, err)
+		instructions = append(instructions, memo.Instruction(base64.StdEncoding.EncodeToString(m[:])))
+	} else {
+		instructions = append(instructions, memo.Instruction("1-test"))
+	}
+
+	for i := 0; i < 3; i++ {
+		dest := testutil.GenerateSolanaKeypair(t)
+		instructions = append(instructions, token.Transfer(
+			sender.Public().(ed25519.PublicKey),
+			dest.Public().(ed25519.PublicKey),
+			sender.Public().(ed25519.PublicKey),
+			uint64(i+1),
+		))
+	}
+
+	tx := solana.NewTransaction(subsidizer.Public().(ed25519.PublicKey), instructions...)
+	require.NoError(t, tx.Sign(subsidizer, sender))
+	return tx, il
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseEnvironment parses an Environment from its name. Parsing is
+// case-insensitive, and ignores surrounding whitespace.
+func ParseEnvironment(s string) (Environment, error) {
+	env := Environment(strings.ToLower(strings.TrimSpace(s)))
+	switch env {
+	case EnvironmentTest, EnvironmentProd:
+		return env, nil
+	default:
+		return "", errors.Errorf("unknown environment: %s", s)
+	}
+}
+
+// Endpoint returns the default Agora endpoint for the environment,
+// or an empty string if the environment is unknown.
+func (e Environment) Endpoint() string {
+	switch e {
+	case EnvironmentTest:
+		return "api.agorainfra.dev:443"
+	case EnvironmentProd:
+		return "api.agorainfra.net:443"
+	default:
+		return ""
+	}
+}
+
+// IsTest returns whether or not the environment is the test environment.
+func (e Environment) IsTest() bool {
+	return e == EnvironmentTest
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvironment(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		expected Environment
+	}{
+		{"test", EnvironmentTest},
+		{" Test ", EnvironmentTest},
+		{"prod", EnvironmentProd},
+		{"PROD", EnvironmentProd},
+	} {
+		env, err := ParseEnvironment(tc.in)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, env)
+	}
+
+	for _, in := range []string{"", "production", "dev"} {
+		_, err := ParseEnvironment(in)
+		assert.Error(t, err)
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	assert.Equal(t, "api.agorainfra.dev:443", EnvironmentTest.Endpoint())
+	assert.Equal(t, "api.agorainfra.net:443", EnvironmentProd.Endpoint())
+	assert.Empty(t, Environment("dev").Endpoint())
+
+	assert.True(t, EnvironmentTest.IsTest())
+	assert.False(t, EnvironmentProd.IsTest())
+	assert.False(t, Environment("dev").IsTest())
+}
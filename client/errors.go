@@ -1,13 +1,22 @@
 package client
 
 import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kinecosystem/agora-common/kin/version"
 	"github.com/kinecosystem/agora-common/solana"
 	"github.com/kinecosystem/agora-common/solana/token"
 	"github.com/kinecosystem/go/xdr"
+	"github.com/mr-tron/base58"
 	"github.com/pkg/errors"
 
 	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
 	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+
+	"github.com/kinecosystem/kin-go/client/versioned"
 )
 
 var (
@@ -17,7 +26,13 @@ var (
 	ErrTransactionNotFound = errors.New("transaction not found")
 
 	// Transaction errors.
-	ErrBadNonce            = errors.New("bad nonce")
+	ErrBadNonce = errors.New("bad nonce")
+
+	// ErrBlockhashNotFound is returned when a transaction's blockhash has expired,
+	// or is not yet known to the cluster. It is a more specific ErrBadNonce, and
+	// errors.Is reports it as one.
+	ErrBlockhashNotFound error = &badNonceError{msg: "blockhash not found"}
+
 	ErrInsufficientBalance = errors.New("insufficient balance")
 	ErrInvalidSignature    = errors.New("invalid signature")
 
@@ -33,6 +48,90 @@ var (
 
 	ErrBlockchainVersion = errors.New("unsupported blockchain version")
 
+	// ErrLimitExceeded is returned when a payment would exceed a configured spending limit.
+	ErrLimitExceeded = errors.New("spending limit exceeded")
+
+	// ErrPaymentScreened is returned when a payment is rejected by a screener
+	// configured with WithPaymentScreener.
+	ErrPaymentScreened = errors.New("payment rejected by screener")
+
+	// ErrNoSolanaRPC is returned by queries that require a Solana RPC endpoint
+	// if WithSolanaRPC was not specified.
+	ErrNoSolanaRPC = errors.New("no solana rpc endpoint configured")
+
+	// ErrEarnBatchAborted is set on the results of earn batch chunks that were not
+	// submitted because an earlier chunk failed.
+	ErrEarnBatchAborted = errors.New("earn batch aborted")
+
+	// ErrInvalidAmount is returned when the quarks of a payment are not
+	// positive, or exceed MaxSupplyQuarks.
+	ErrInvalidAmount = errors.New("invalid amount")
+
+	// ErrClientClosed is returned by submissions made after the client was closed.
+	ErrClientClosed = errors.New("client closed")
+
+	// ErrTooManyStreams is returned by EventHub.Subscribe if subscribing to the
+	// account would exceed the maximum number of streams (see WithMaxStreams).
+	ErrTooManyStreams = errors.New("too many event streams")
+
+	// ErrEventHubClosed is returned by subscriptions made after the EventHub
+	// was closed.
+	ErrEventHubClosed = errors.New("event hub closed")
+
+	// ErrNotFeePayer is returned when signing a webhook transaction with a
+	// key that is not the fee payer of the transaction.
+	ErrNotFeePayer = errors.New("signer is not the transaction fee payer")
+
+	// ErrVersionedTransactionsUnsupported is returned when a transaction can
+	// only be submitted as a versioned transaction, which Agora does not
+	// support.
+	ErrVersionedTransactionsUnsupported = errors.New("agora does not support versioned transactions")
+
+	// ErrTransactionTooLarge is the cause of a TransactionTooLargeError.
+	ErrTransactionTooLarge = errors.New("transaction too large")
+
+	// ErrJobExists is returned by JobStore.Create when a job with the same ID
+	// already exists.
+	ErrJobExists = errors.New("job already exists")
+
+	// ErrJobNotFound is returned by JobStore.Load when a job does not exist.
+	ErrJobNotFound = errors.New("job not found")
+
+	// ErrInvalidHandle is returned when a handle is not in a valid format (see
+	// NormalizeHandle).
+	ErrInvalidHandle = errors.New("invalid handle")
+
+	// ErrHandleNotFound is returned when a handle does not refer to an address.
+	ErrHandleNotFound = errors.New("handle not found")
+
+	// ErrHandleMismatch is returned when a payment has both a Destination and a
+	// DestinationHandle, and the handle resolves to a different address.
+	ErrHandleMismatch = errors.New("handle does not match destination")
+
+	// ErrUnexpectedEffects is the cause of an EffectsMismatchError.
+	ErrUnexpectedEffects = errors.New("unexpected transaction effects")
+
+	// ErrApprovalExpired is returned when an Approval is approved or submitted
+	// after it expires.
+	ErrApprovalExpired = errors.New("approval expired")
+
+	// ErrPaymentRequestExpired is returned when a PaymentRequest is verified or
+	// fulfilled after it expires.
+	ErrPaymentRequestExpired = errors.New("payment request expired")
+
+	// ErrInvalidApproval is returned when the transaction of an Approval does
+	// not make exactly its payment.
+	ErrInvalidApproval = errors.New("invalid approval")
+
+	// ErrEventRetryQueueFull is returned by EventRetrier.Enqueue if enqueuing
+	// the events would exceed the maximum number of pending events (see
+	// WithMaxPendingEvents).
+	ErrEventRetryQueueFull = errors.New("event retry queue full")
+
+	// ErrEventRetrierClosed is returned by EventRetrier.Enqueue after the
+	// EventRetrier was closed.
+	ErrEventRetrierClosed = errors.New("event retrier closed")
+
 	// nonRetriableErrors contains the set of errors that
 	// should not be retried without modifications to the
 	// transaction.
@@ -40,6 +139,7 @@ var (
 		ErrAccountExists,
 		ErrAccountDoesNotExist,
 		ErrBadNonce,
+		ErrBlockhashNotFound,
 		ErrInsufficientBalance,
 		ErrTransactionNotFound,
 		ErrAlreadyPaid,
@@ -50,6 +150,12 @@ var (
 		ErrTransactionRejected,
 		ErrAlreadySubmitted,
 		ErrBlockchainVersion,
+		ErrInvalidHandle,
+		ErrHandleNotFound,
+		ErrHandleMismatch,
+		ErrUnexpectedEffects,
+		ErrApprovalExpired,
+		ErrInvalidApproval,
 	}
 )
 
@@ -101,6 +207,24 @@ func errorsFromSolanaTx(tx *solana.Transaction, protoError *commonpbv4.Transacti
 	return txErrors
 }
 
+// errorsFromVersionedTx is errorsFromSolanaTx for a versioned transaction,
+// whose transfers are identified without resolving looked up accounts.
+func errorsFromVersionedTx(tx *versioned.Transaction, protoError *commonpbv4.TransactionError) (txErrors TransactionErrors) {
+	// Only the program of each instruction is needed to identify transfers,
+	// and programs cannot be looked up, so looked up accounts are left empty.
+	accounts := append([]ed25519.PublicKey{}, tx.Message.Accounts...)
+	for _, l := range tx.Message.AddressTableLookups {
+		accounts = append(accounts, make([]ed25519.PublicKey, len(l.WritableIndexes)+len(l.ReadonlyIndexes))...)
+	}
+
+	return errorsFromSolanaTx(&solana.Transaction{
+		Message: solana.Message{
+			Accounts:     accounts,
+			Instructions: tx.Message.Instructions,
+		},
+	}, protoError)
+}
+
 func errorsFromStellarTx(env xdr.TransactionEnvelope, protoError *commonpbv4.TransactionError) (txErrors TransactionErrors) {
 	e := errorFromProto(protoError)
 	if e == nil {
@@ -146,6 +270,9 @@ func errorFromProto(protoError *commonpbv4.TransactionError) error {
 	case commonpbv4.TransactionError_UNAUTHORIZED:
 		return ErrInvalidSignature
 	case commonpbv4.TransactionError_BAD_NONCE:
+		if rawErrorKey(protoError.Raw) == solana.TransactionErrorBlockhashNotFound {
+			return ErrBlockhashNotFound
+		}
 		return ErrBadNonce
 	case commonpbv4.TransactionError_INSUFFICIENT_FUNDS:
 		return ErrInsufficientBalance
@@ -156,6 +283,222 @@ func errorFromProto(protoError *commonpbv4.TransactionError) error {
 	}
 }
 
+// rawErrorKey returns the key of the raw Solana transaction error, if it can be
+// parsed.
+func rawErrorKey(raw []byte) solana.TransactionErrorKey {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return ""
+	}
+
+	txErr, err := solana.ParseTransactionError(v)
+	if err != nil || txErr == nil {
+		return ""
+	}
+
+	return txErr.ErrorKey()
+}
+
+// badNonceError is a more specific ErrBadNonce.
+type badNonceError struct {
+	msg string
+}
+
+// Error implements error.Error.
+func (e *badNonceError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns ErrBadNonce.
+func (e *badNonceError) Unwrap() error {
+	return ErrBadNonce
+}
+
+// RetryError is returned when a transaction could not be submitted within the
+// configured number of attempts (see WithMaxNonceRetries).
+type RetryError struct {
+	// Reason is the error that caused the final attempt to be retried, such as
+	// ErrBadNonce or ErrBlockhashNotFound.
+	Reason error
+
+	Attempts uint
+}
+
+// Error implements error.Error.
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("transaction failed after %d attempts: %v", e.Attempts, e.Reason)
+}
+
+// Cause returns the Reason, allowing it to be retrieved with errors.Cause.
+func (e *RetryError) Cause() error {
+	return e.Reason
+}
+
+// Unwrap returns the Reason.
+func (e *RetryError) Unwrap() error {
+	return e.Reason
+}
+
+// AlreadySubmittedError is returned when a transaction was previously submitted,
+// such as with the same DedupeID. It identifies the existing transaction, so that
+// callers may treat the submission as successful and record it.
+//
+// Its cause is ErrAlreadySubmitted.
+type AlreadySubmittedError struct {
+	// TxID is the ID of the existing transaction.
+	TxID []byte
+
+	// Slot is the slot the existing transaction was included in, or zero if
+	// it is not yet known.
+	Slot uint64
+}
+
+// Error implements error.Error.
+func (e *AlreadySubmittedError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrAlreadySubmitted, base58.Encode(e.TxID))
+}
+
+// Cause returns ErrAlreadySubmitted, allowing it to be retrieved with errors.Cause.
+func (e *AlreadySubmittedError) Cause() error {
+	return ErrAlreadySubmitted
+}
+
+// Unwrap returns ErrAlreadySubmitted.
+func (e *AlreadySubmittedError) Unwrap() error {
+	return ErrAlreadySubmitted
+}
+
+// ScreenedError is returned when a payment is rejected by a screener configured
+// with WithPaymentScreener. Err is the error returned by the screener, which can
+// also be retrieved with errors.As.
+//
+// Its cause is ErrPaymentScreened.
+type ScreenedError struct {
+	Err error
+}
+
+// Error implements error.Error.
+func (e *ScreenedError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrPaymentScreened, e.Err)
+}
+
+// Cause returns ErrPaymentScreened, allowing it to be retrieved with errors.Cause.
+func (e *ScreenedError) Cause() error {
+	return ErrPaymentScreened
+}
+
+// Unwrap returns ErrPaymentScreened.
+func (e *ScreenedError) Unwrap() error {
+	return ErrPaymentScreened
+}
+
+// As finds the first error in the chain of Err that matches target.
+func (e *ScreenedError) As(target interface{}) bool {
+	return errors.As(e.Err, target)
+}
+
+// TransactionTooLargeError is returned when a payment or earn batch would
+// produce a transaction larger than solana.MaxTransactionSize, which cannot be
+// submitted. The size can be reduced by including fewer earns in a batch, or by
+// using a shorter memo.
+//
+// Its cause is ErrTransactionTooLarge.
+type TransactionTooLargeError struct {
+	// Size is the size of the serialized transaction, in bytes.
+	Size int
+
+	// Overflow is the number of bytes by which Size exceeds the maximum.
+	Overflow int
+}
+
+// Error implements error.Error.
+func (e *TransactionTooLargeError) Error() string {
+	return fmt.Sprintf("%v: %d bytes exceeds the maximum of %d by %d bytes (use fewer earns or a shorter memo)", ErrTransactionTooLarge, e.Size, e.Size-e.Overflow, e.Overflow)
+}
+
+// Cause returns ErrTransactionTooLarge, allowing it to be retrieved with errors.Cause.
+func (e *TransactionTooLargeError) Cause() error {
+	return ErrTransactionTooLarge
+}
+
+// Unwrap returns ErrTransactionTooLarge.
+func (e *TransactionTooLargeError) Unwrap() error {
+	return ErrTransactionTooLarge
+}
+
+// MinimumVersionError is returned by every operation of a client configured with
+// WithMinimumKinVersion while Agora is using a blockchain version below the
+// minimum.
+//
+// Its cause is ErrBlockchainVersion.
+type MinimumVersionError struct {
+	// Minimum is the minimum version configured with WithMinimumKinVersion.
+	Minimum version.KinVersion
+
+	// Version is the version Agora is using.
+	Version version.KinVersion
+}
+
+// Error implements error.Error.
+func (e *MinimumVersionError) Error() string {
+	return fmt.Sprintf("%v: agora is using kin %d, below the minimum of kin %d", ErrBlockchainVersion, e.Version, e.Minimum)
+}
+
+// Cause returns ErrBlockchainVersion, allowing it to be retrieved with errors.Cause.
+func (e *MinimumVersionError) Cause() error {
+	return ErrBlockchainVersion
+}
+
+// Unwrap returns ErrBlockchainVersion.
+func (e *MinimumVersionError) Unwrap() error {
+	return ErrBlockchainVersion
+}
+
+// checkTransactionSize returns a *TransactionTooLargeError if tx exceeds the
+// maximum transaction size.
+func checkTransactionSize(tx solana.Transaction) error {
+	size := TransactionSize(tx)
+	if size > solana.MaxTransactionSize {
+		return &TransactionTooLargeError{Size: size, Overflow: size - solana.MaxTransactionSize}
+	}
+	return nil
+}
+
+// BatchValidationProblem is a single problem with an EarnBatch.
+type BatchValidationProblem struct {
+	// Index is the index of the earn with the problem, or -1 if the problem
+	// is with the batch itself.
+	Index int
+
+	// Field is the name of the field with the problem, such as "Quarks".
+	Field string
+
+	Reason string
+}
+
+// String returns a description of the problem.
+func (p BatchValidationProblem) String() string {
+	if p.Index < 0 {
+		return fmt.Sprintf("%s: %s", p.Field, p.Reason)
+	}
+	return fmt.Sprintf("earns[%d].%s: %s", p.Index, p.Field, p.Reason)
+}
+
+// BatchValidationError is returned when an EarnBatch is invalid. It contains
+// every problem with the batch, so that they can all be fixed at once.
+type BatchValidationError struct {
+	Problems []BatchValidationProblem
+}
+
+// Error implements error.Error.
+func (e *BatchValidationError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		problems[i] = p.String()
+	}
+	return fmt.Sprintf("invalid earn batch: %s", strings.Join(problems, "; "))
+}
+
 func invoiceErrorFromProto(protoError *commonpb.InvoiceError) error {
 	if protoError == nil {
 		return nil
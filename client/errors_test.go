@@ -7,6 +7,7 @@ import (
 	"github.com/kinecosystem/agora-common/solana/memo"
 	"github.com/kinecosystem/agora-common/solana/token"
 	"github.com/kinecosystem/go/xdr"
+	"github.com/pkg/errors"
 	stellarxdr "github.com/stellar/go/xdr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -47,12 +48,29 @@ func TestErrorFromProto(t *testing.T) {
 		assert.Equal(t, tc.txError, err)
 	}
 
+	// Expired blockhashes are reported distinctly from other nonce errors.
+	err := errorFromProto(&commonpbv4.TransactionError{
+		Reason: commonpbv4.TransactionError_BAD_NONCE,
+		Raw:    []byte(`"BlockhashNotFound"`),
+	})
+	assert.Equal(t, ErrBlockhashNotFound, err)
+	assert.True(t, errors.Is(err, ErrBadNonce))
+	assert.True(t, errors.Is(&RetryError{Reason: err, Attempts: 3}, ErrBadNonce))
+	assert.False(t, errors.Is(ErrBadNonce, ErrBlockhashNotFound))
+
 	// Unknown error
-	err := errorFromProto(&commonpbv4.TransactionError{Reason: commonpbv4.TransactionError_UNKNOWN})
+	err = errorFromProto(&commonpbv4.TransactionError{Reason: commonpbv4.TransactionError_UNKNOWN})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "unknown")
 }
 
+func TestRetryError(t *testing.T) {
+	err := error(&RetryError{Reason: ErrBadNonce, Attempts: 3})
+	assert.Equal(t, ErrBadNonce, errors.Cause(err))
+	assert.True(t, errors.Is(err, ErrBadNonce))
+	assert.Contains(t, err.Error(), "3 attempts")
+}
+
 func TestInvoiceErrorFromProto(t *testing.T) {
 	for _, tc := range []struct {
 		reason  commonpb.InvoiceError_Reason
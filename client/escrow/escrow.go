@@ -0,0 +1,426 @@
+// Package escrow holds Kin in escrow on behalf of a depositor, until it is
+// released to a beneficiary, or refunded to the depositor.
+//
+// An escrow is a dedicated Kin account, whose key is held by the app. The token
+// program cannot enforce the conditions of an escrow, so they are enforced by
+// the Manager before it signs a release or refund with the escrow's key. Funds
+// are released or refunded once the escrow's release signer authorizes it (see
+// Authorize), or once its deadline has passed.
+//
+// Accounts owned by a program derived address would require an escrow program
+// to sign for them, and are not supported. Instead, escrow keys can be derived
+// deterministically from a master seed and the terms of the escrow with
+// DeriveKey, so that they need not be stored. Since the key commits to the
+// terms, a Manager configured WithMasterSeed rejects escrows whose terms were
+// altered after they were created.
+package escrow
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+var (
+	// ErrConditionsNotMet indicates that an escrow cannot be released or
+	// refunded, because it has not been authorized, and its deadline has not
+	// passed.
+	ErrConditionsNotMet = errors.New("escrow conditions not met")
+
+	// ErrInvalidAuthorization indicates that an Authorization was not signed
+	// by the release signer of the escrow, or was for a different action.
+	ErrInvalidAuthorization = errors.New("invalid escrow authorization")
+
+	// ErrNotFunded indicates that the escrow does not hold enough funds to be
+	// released or refunded.
+	ErrNotFunded = errors.New("escrow not funded")
+
+	// ErrTermsMismatch indicates that the key of an escrow was not derived
+	// from its terms, typically because the terms were altered.
+	ErrTermsMismatch = errors.New("escrow terms do not match its key")
+)
+
+// keyPathIndex is the hardened index, following m/44'/2017', under which escrow
+// keys are derived. Escrow key paths are one index longer than user key paths
+// (see client.UserKeyPath), so the two never coincide.
+const keyPathIndex = 1 + client.HardenedOffset
+
+// Terms are the terms of an escrow.
+type Terms struct {
+	// ID is an app defined identifier of the escrow.
+	ID string
+
+	// Depositor funds the escrow, and receives any refund.
+	Depositor kin.PublicKey
+
+	// Beneficiary receives the funds once the escrow is released.
+	Beneficiary kin.PublicKey
+
+	// Quarks is the amount the escrow must hold to be released.
+	Quarks int64
+
+	// ReleaseSigner, if set, authorizes the release or refund of the escrow.
+	// If it is not set, the escrow is released once Deadline has passed, and
+	// cannot be refunded.
+	ReleaseSigner kin.PublicKey
+
+	// Deadline, if set, is the time after which an escrow with a release
+	// signer can be refunded without its authorization, or after which an
+	// escrow without a release signer is released. It is committed to by
+	// DeriveKey and Authorize with a precision of one second.
+	Deadline time.Time
+}
+
+// encode returns the canonical encoding of the terms, which is committed to by
+// escrow keys and authorizations.
+func (t Terms) encode() []byte {
+	var b bytes.Buffer
+	b.WriteString("kin-go/escrow/terms/v1")
+
+	writeField := func(field []byte) {
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], uint32(len(field)))
+		b.Write(n[:])
+		b.Write(field)
+	}
+	writeInt := func(i int64) {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(i))
+		b.Write(n[:])
+	}
+
+	writeField([]byte(t.ID))
+	writeField(t.Depositor)
+	writeField(t.Beneficiary)
+	writeInt(t.Quarks)
+	writeField(t.ReleaseSigner)
+	if t.Deadline.IsZero() {
+		writeInt(0)
+	} else {
+		writeInt(t.Deadline.Unix())
+	}
+
+	return b.Bytes()
+}
+
+// Validate returns an error if the terms are invalid.
+func (t Terms) Validate() error {
+	if t.ID == "" {
+		return errors.New("escrow must have an ID")
+	}
+	if len(t.Depositor) != ed25519.PublicKeySize {
+		return errors.New("escrow must have a valid depositor")
+	}
+	if len(t.Beneficiary) != ed25519.PublicKeySize {
+		return errors.New("escrow must have a valid beneficiary")
+	}
+	if t.Quarks <= 0 || t.Quarks > client.MaxSupplyQuarks {
+		return errors.Wrapf(client.ErrInvalidAmount, "invalid escrow amount %d", t.Quarks)
+	}
+	if t.ReleaseSigner != nil && len(t.ReleaseSigner) != ed25519.PublicKeySize {
+		return errors.New("escrow must have a valid release signer")
+	}
+	if t.ReleaseSigner == nil && t.Deadline.IsZero() {
+		return errors.New("escrow must have a release signer or a deadline")
+	}
+	return nil
+}
+
+// Escrow is an escrow account, and the terms under which it holds funds.
+type Escrow struct {
+	Terms
+
+	// Key is the key of the escrow account, which is held by the app.
+	Key kin.PrivateKey
+}
+
+// Address returns the address of the escrow, to which funds are deposited.
+func (e Escrow) Address() kin.PublicKey {
+	return e.Key.Public()
+}
+
+// KeyPath returns the derivation path of the key of the escrow with the
+// specified terms, which is m/44'/2017'/1'/ followed by eight hardened indices
+// taken from the SHA-256 hash of the canonical encoding of the terms.
+func KeyPath(terms Terms) []uint32 {
+	h := sha256.Sum256(terms.encode())
+
+	path := []uint32{44 + client.HardenedOffset, client.KinCoinType + client.HardenedOffset, keyPathIndex}
+	for i := 0; i < len(h); i += 4 {
+		path = append(path, binary.BigEndian.Uint32(h[i:])|client.HardenedOffset)
+	}
+	return path
+}
+
+// DeriveKey derives the key of the escrow with the specified terms from
+// masterSeed (see client.DeriveKey and KeyPath). The master seed must be kept
+// secret, and escrow IDs must never be reused.
+func DeriveKey(masterSeed []byte, terms Terms) (kin.PrivateKey, error) {
+	if err := terms.Validate(); err != nil {
+		return nil, err
+	}
+	return client.DeriveKey(masterSeed, KeyPath(terms)...)
+}
+
+// Action is an action authorized by the release signer of an escrow.
+type Action int
+
+const (
+	ActionRelease Action = iota
+	ActionRefund
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionRelease:
+		return "release"
+	case ActionRefund:
+		return "refund"
+	default:
+		return "unknown"
+	}
+}
+
+// Authorization is the authorization of an action on an escrow by its release
+// signer (see Authorize).
+type Authorization struct {
+	Action    Action
+	Signature []byte
+}
+
+// Authorize returns an authorization of action on the escrow with the specified
+// terms and address, signed by signer, which must be the release signer of the
+// escrow. Authorizations can be created by the release signer without access
+// to the escrow key.
+//
+// The authorization commits to all of the terms, so it is only valid for the
+// escrow it was created for.
+func Authorize(signer kin.PrivateKey, terms Terms, address kin.PublicKey, action Action) Authorization {
+	return Authorization{
+		Action:    action,
+		Signature: ed25519.Sign(ed25519.PrivateKey(signer), authorizationMessage(terms, address, action)),
+	}
+}
+
+func (a Authorization) verify(e Escrow, action Action) error {
+	if a.Action != action || e.ReleaseSigner == nil {
+		return ErrInvalidAuthorization
+	}
+	if !ed25519.Verify(ed25519.PublicKey(e.ReleaseSigner), authorizationMessage(e.Terms, e.Address(), action), a.Signature) {
+		return ErrInvalidAuthorization
+	}
+	return nil
+}
+
+func authorizationMessage(terms Terms, address kin.PublicKey, action Action) []byte {
+	message := []byte("kin-go/escrow/authorization/v1:" + action.String() + ":")
+	message = append(message, address...)
+	return append(message, terms.encode()...)
+}
+
+// DepositPayment returns the payment that funds e from depositor.
+func DepositPayment(depositor kin.PrivateKey, e Escrow) client.Payment {
+	return client.Payment{
+		Sender:      depositor,
+		Destination: e.Address(),
+		Type:        kin.TransactionTypeP2P,
+		Quarks:      e.Quarks,
+	}
+}
+
+// ReleasePayment returns the payment that releases quarks held by e to its
+// beneficiary.
+func ReleasePayment(e Escrow, quarks int64) client.Payment {
+	return client.Payment{
+		Sender:      e.Key,
+		Destination: e.Beneficiary,
+		Type:        kin.TransactionTypeP2P,
+		Quarks:      quarks,
+	}
+}
+
+// RefundPayment returns the payment that refunds quarks held by e to its
+// depositor.
+func RefundPayment(e Escrow, quarks int64) client.Payment {
+	return client.Payment{
+		Sender:      e.Key,
+		Destination: e.Depositor,
+		Type:        kin.TransactionTypeP2P,
+		Quarks:      quarks,
+	}
+}
+
+type opts struct {
+	clock      client.Clock
+	masterSeed []byte
+	solanaOpts []client.SolanaOption
+}
+
+// Option configures a Manager.
+type Option func(*opts)
+
+// WithClock specifies the Clock used to determine whether the deadline of an
+// escrow has passed.
+func WithClock(clock client.Clock) Option {
+	return func(o *opts) {
+		o.clock = clock
+	}
+}
+
+// WithMasterSeed specifies the master seed escrow keys are derived from (see
+// DeriveKey). Escrows whose key was not derived from their terms are rejected
+// with ErrTermsMismatch, so that their terms cannot be altered once they are
+// created, such as by moving the deadline of an escrow that is released once
+// its deadline passes.
+//
+// Without a master seed, the terms of an escrow must be stored with its key,
+// and must not be accepted from untrusted callers.
+func WithMasterSeed(masterSeed []byte) Option {
+	return func(o *opts) {
+		o.masterSeed = masterSeed
+	}
+}
+
+// WithSolanaOptions specifies options used for every call the manager makes
+// to the client, such as client.WithCommitment.
+func WithSolanaOptions(solanaOpts ...client.SolanaOption) Option {
+	return func(o *opts) {
+		o.solanaOpts = append(o.solanaOpts, solanaOpts...)
+	}
+}
+
+// Manager creates, funds, releases, and refunds escrows. It is safe for
+// concurrent use.
+type Manager struct {
+	client client.Client
+	opts   opts
+}
+
+// New returns a new Manager.
+func New(c client.Client, options ...Option) *Manager {
+	m := &Manager{
+		client: c,
+		opts: opts{
+			clock: client.SystemClock(),
+		},
+	}
+	for _, o := range options {
+		o(&m.opts)
+	}
+	return m
+}
+
+// Create creates the account of e.
+func (m *Manager) Create(ctx context.Context, e Escrow) error {
+	if err := m.validate(e); err != nil {
+		return err
+	}
+	return m.client.CreateAccount(ctx, e.Key, m.opts.solanaOpts...)
+}
+
+// Deposit funds e from depositor, which must be the depositor of the escrow,
+// returning the ID of the transaction.
+func (m *Manager) Deposit(ctx context.Context, depositor kin.PrivateKey, e Escrow) ([]byte, error) {
+	if err := m.validate(e); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(depositor.Public(), e.Depositor) {
+		return nil, errors.New("depositor does not match the escrow")
+	}
+	return m.client.SubmitPayment(ctx, DepositPayment(depositor, e), m.opts.solanaOpts...)
+}
+
+// Release releases the funds held by e to its beneficiary, returning the ID of
+// the transaction. An escrow with a release signer requires its authorization,
+// and one without is released once its deadline has passed.
+func (m *Manager) Release(ctx context.Context, e Escrow, auth *Authorization) ([]byte, error) {
+	if err := m.validate(e); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case auth != nil:
+		if err := auth.verify(e, ActionRelease); err != nil {
+			return nil, err
+		}
+	case e.ReleaseSigner != nil || !m.expired(e):
+		return nil, ErrConditionsNotMet
+	}
+
+	balance, err := m.balance(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	if balance < e.Quarks {
+		return nil, ErrNotFunded
+	}
+	return m.client.SubmitPayment(ctx, ReleasePayment(e, balance), m.opts.solanaOpts...)
+}
+
+// Refund refunds the funds held by e to its depositor, returning the ID of the
+// transaction. The refund requires the authorization of the release signer of
+// the escrow, unless its deadline has passed. Escrows without a release
+// signer cannot be refunded.
+func (m *Manager) Refund(ctx context.Context, e Escrow, auth *Authorization) ([]byte, error) {
+	if err := m.validate(e); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case auth != nil:
+		if err := auth.verify(e, ActionRefund); err != nil {
+			return nil, err
+		}
+	case e.ReleaseSigner == nil || e.Deadline.IsZero() || !m.expired(e):
+		return nil, ErrConditionsNotMet
+	}
+
+	balance, err := m.balance(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	if balance <= 0 {
+		return nil, ErrNotFunded
+	}
+	return m.client.SubmitPayment(ctx, RefundPayment(e, balance), m.opts.solanaOpts...)
+}
+
+// validate validates the terms of e, and that its key was derived from them if
+// the manager has a master seed.
+func (m *Manager) validate(e Escrow) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	if m.opts.masterSeed == nil {
+		return nil
+	}
+
+	key, err := DeriveKey(m.opts.masterSeed, e.Terms)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(key, e.Key) {
+		return ErrTermsMismatch
+	}
+	return nil
+}
+
+func (m *Manager) expired(e Escrow) bool {
+	return !e.Deadline.IsZero() && !m.opts.clock.Now().Before(e.Deadline)
+}
+
+func (m *Manager) balance(ctx context.Context, e Escrow) (int64, error) {
+	balance, err := m.client.GetBalance(ctx, e.Address(), m.opts.solanaOpts...)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get escrow balance")
+	}
+	return balance, nil
+}
@@ -0,0 +1,326 @@
+package escrow
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+	"github.com/kinecosystem/kin-go/client/clientfake"
+)
+
+type fixedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fixedClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Now().Add(d)
+	return ch
+}
+
+func (c *fixedClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newKey(t *testing.T) kin.PrivateKey {
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	return key
+}
+
+type parties struct {
+	depositor, beneficiary, signer kin.PrivateKey
+}
+
+func newEscrow(t *testing.T, fake *clientfake.Fake, terms Terms) (Escrow, parties) {
+	p := parties{
+		depositor:   newKey(t),
+		beneficiary: newKey(t),
+		signer:      newKey(t),
+	}
+	fake.SetBalance(p.depositor.Public(), 1000)
+	fake.SetBalance(p.beneficiary.Public(), 0)
+
+	terms.Depositor = p.depositor.Public()
+	terms.Beneficiary = p.beneficiary.Public()
+	if terms.ReleaseSigner != nil {
+		terms.ReleaseSigner = p.signer.Public()
+	}
+	return Escrow{Terms: terms, Key: newKey(t)}, p
+}
+
+func assertBalance(t *testing.T, fake *clientfake.Fake, account kin.PublicKey, expected int64) {
+	balance, err := fake.GetBalance(context.Background(), account)
+	require.NoError(t, err)
+	assert.Equal(t, expected, balance)
+}
+
+func TestEscrow_SignatureRelease(t *testing.T) {
+	fake := clientfake.New()
+	clock := &fixedClock{now: time.Now()}
+	m := New(fake, WithClock(clock))
+
+	e, p := newEscrow(t, fake, Terms{
+		ID:            "order-1",
+		Quarks:        100,
+		ReleaseSigner: kin.PublicKey{},
+		Deadline:      clock.Now().Add(time.Hour),
+	})
+
+	status, err := m.Inspect(context.Background(), e)
+	require.NoError(t, err)
+	assert.Equal(t, StateUnfunded, status.State)
+
+	require.NoError(t, m.Create(context.Background(), e))
+
+	// The escrow cannot be released before it is funded.
+	_, err = m.Release(context.Background(), e, &Authorization{})
+	assert.Equal(t, ErrInvalidAuthorization, err)
+	release := Authorize(p.signer, e.Terms, e.Address(), ActionRelease)
+	_, err = m.Release(context.Background(), e, &release)
+	assert.Equal(t, ErrNotFunded, err)
+
+	_, err = m.Deposit(context.Background(), p.beneficiary, e)
+	assert.Error(t, err)
+	_, err = m.Deposit(context.Background(), p.depositor, e)
+	require.NoError(t, err)
+
+	status, err = m.Inspect(context.Background(), e)
+	require.NoError(t, err)
+	assert.Equal(t, StateFunded, status.State)
+	assert.EqualValues(t, 100, status.Balance)
+	assert.EqualValues(t, 100, status.Deposited)
+
+	// Without an authorization, the escrow can neither be released, nor
+	// refunded before its deadline.
+	_, err = m.Release(context.Background(), e, nil)
+	assert.Equal(t, ErrConditionsNotMet, err)
+	_, err = m.Refund(context.Background(), e, nil)
+	assert.Equal(t, ErrConditionsNotMet, err)
+
+	// Authorizations are specific to the action.
+	refund := Authorize(p.signer, e.Terms, e.Address(), ActionRefund)
+	_, err = m.Release(context.Background(), e, &refund)
+	assert.Equal(t, ErrInvalidAuthorization, err)
+	forged := Authorize(p.depositor, e.Terms, e.Address(), ActionRelease)
+	_, err = m.Release(context.Background(), e, &forged)
+	assert.Equal(t, ErrInvalidAuthorization, err)
+
+	// Authorizations are specific to the terms, so they cannot be replayed
+	// with a different beneficiary.
+	replayed := e
+	replayed.Beneficiary = p.depositor.Public()
+	_, err = m.Release(context.Background(), replayed, &release)
+	assert.Equal(t, ErrInvalidAuthorization, err)
+
+	_, err = m.Release(context.Background(), e, &release)
+	require.NoError(t, err)
+	assertBalance(t, fake, p.beneficiary.Public(), 100)
+	assertBalance(t, fake, e.Address(), 0)
+
+	status, err = m.Inspect(context.Background(), e)
+	require.NoError(t, err)
+	assert.Equal(t, StateReleased, status.State)
+	assert.EqualValues(t, 100, status.Released)
+	assert.False(t, status.Expired)
+}
+
+func TestEscrow_Refund(t *testing.T) {
+	fake := clientfake.New()
+	clock := &fixedClock{now: time.Now()}
+	m := New(fake, WithClock(clock))
+
+	e, p := newEscrow(t, fake, Terms{
+		ID:            "order-2",
+		Quarks:        100,
+		ReleaseSigner: kin.PublicKey{},
+		Deadline:      clock.Now().Add(time.Hour),
+	})
+	require.NoError(t, m.Create(context.Background(), e))
+	_, err := m.Deposit(context.Background(), p.depositor, e)
+	require.NoError(t, err)
+
+	// Once the deadline passes, the escrow can be refunded without an
+	// authorization.
+	clock.advance(time.Hour)
+	_, err = m.Refund(context.Background(), e, nil)
+	require.NoError(t, err)
+	assertBalance(t, fake, p.depositor.Public(), 1000)
+
+	status, err := m.Inspect(context.Background(), e)
+	require.NoError(t, err)
+	assert.Equal(t, StateRefunded, status.State)
+	assert.EqualValues(t, 100, status.Refunded)
+	assert.True(t, status.Expired)
+
+	_, err = m.Refund(context.Background(), e, nil)
+	assert.Equal(t, ErrNotFunded, err)
+}
+
+func TestEscrow_TimeRelease(t *testing.T) {
+	fake := clientfake.New()
+	clock := &fixedClock{now: time.Now()}
+	m := New(fake, WithClock(clock))
+
+	e, p := newEscrow(t, fake, Terms{
+		ID:       "vesting",
+		Quarks:   100,
+		Deadline: clock.Now().Add(time.Hour),
+	})
+	require.NoError(t, m.Create(context.Background(), e))
+
+	fake.SetBalance(p.depositor.Public(), 50)
+	_, err := fake.SubmitPayment(context.Background(), client.Payment{
+		Sender:      p.depositor,
+		Destination: e.Address(),
+		Quarks:      50,
+	})
+	require.NoError(t, err)
+
+	status, err := m.Inspect(context.Background(), e)
+	require.NoError(t, err)
+	assert.Equal(t, StatePartiallyFunded, status.State)
+
+	clock.advance(time.Hour)
+	_, err = m.Release(context.Background(), e, nil)
+	assert.Equal(t, ErrNotFunded, err)
+
+	fake.SetBalance(e.Address(), 100)
+	_, err = m.Release(context.Background(), e, nil)
+	require.NoError(t, err)
+	assertBalance(t, fake, p.beneficiary.Public(), 100)
+
+	// Time released escrows cannot be refunded.
+	_, err = m.Refund(context.Background(), e, nil)
+	assert.Equal(t, ErrConditionsNotMet, err)
+}
+
+func TestTerms_Validate(t *testing.T) {
+	key := newKey(t).Public()
+	valid := Terms{ID: "id", Depositor: key, Beneficiary: key, Quarks: 1, ReleaseSigner: key}
+	assert.NoError(t, valid.Validate())
+
+	for _, modify := range []func(*Terms){
+		func(t *Terms) { t.ID = "" },
+		func(t *Terms) { t.Depositor = nil },
+		func(t *Terms) { t.Beneficiary = kin.PublicKey{1} },
+		func(t *Terms) { t.Quarks = 0 },
+		func(t *Terms) { t.ReleaseSigner = kin.PublicKey{1} },
+		func(t *Terms) { t.ReleaseSigner = nil },
+	} {
+		terms := valid
+		modify(&terms)
+		assert.Error(t, terms.Validate())
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	seed := make([]byte, 32)
+	key := newKey(t).Public()
+	terms := Terms{ID: "a", Depositor: key, Beneficiary: key, Quarks: 1, Deadline: time.Unix(1600000000, 0)}
+
+	a, err := DeriveKey(seed, terms)
+	require.NoError(t, err)
+	again, err := DeriveKey(seed, terms)
+	require.NoError(t, err)
+	assert.Equal(t, a, again)
+
+	// The key commits to every term.
+	for _, modify := range []func(*Terms){
+		func(t *Terms) { t.ID = "b" },
+		func(t *Terms) { t.Quarks = 2 },
+		func(t *Terms) { t.Deadline = t.Deadline.Add(-time.Hour) },
+		func(t *Terms) { t.ReleaseSigner = t.Depositor },
+	} {
+		modified := terms
+		modify(&modified)
+		other, err := DeriveKey(seed, modified)
+		require.NoError(t, err)
+		assert.NotEqual(t, a, other)
+	}
+
+	// Escrow keys are not in the space of user keys.
+	assert.Len(t, KeyPath(terms), len(client.UserKeyPath("a"))+1)
+	user, err := client.DeriveUserKey(seed, "escrow:a")
+	require.NoError(t, err)
+	assert.NotEqual(t, a, user)
+
+	terms.ID = ""
+	_, err = DeriveKey(seed, terms)
+	assert.Error(t, err)
+}
+
+func TestAuthorize(t *testing.T) {
+	signer := newKey(t)
+	key := newKey(t).Public()
+	e := Escrow{
+		Terms: Terms{ID: "a", Depositor: key, Beneficiary: key, Quarks: 1, ReleaseSigner: signer.Public()},
+		Key:   newKey(t),
+	}
+
+	// The release signer only needs the terms and address of the escrow.
+	auth := Authorize(signer, e.Terms, e.Address(), ActionRelease)
+	assert.NoError(t, auth.verify(e, ActionRelease))
+
+	for _, modify := range []func(*Escrow){
+		func(e *Escrow) { e.ID = "b" },
+		func(e *Escrow) { e.Depositor = newKey(t).Public() },
+		func(e *Escrow) { e.Beneficiary = newKey(t).Public() },
+		func(e *Escrow) { e.Quarks = 2 },
+		func(e *Escrow) { e.Deadline = time.Unix(1600000000, 0) },
+		func(e *Escrow) { e.Key = newKey(t) },
+	} {
+		modified := e
+		modify(&modified)
+		assert.Equal(t, ErrInvalidAuthorization, auth.verify(modified, ActionRelease))
+	}
+}
+
+func TestManager_MasterSeed(t *testing.T) {
+	fake := clientfake.New()
+	clock := &fixedClock{now: time.Now()}
+	seed := make([]byte, 32)
+	m := New(fake, WithClock(clock), WithMasterSeed(seed))
+
+	e, p := newEscrow(t, fake, Terms{
+		ID:       "vesting",
+		Quarks:   100,
+		Deadline: clock.Now().Add(time.Hour),
+	})
+
+	// Keys that were not derived from the terms are rejected.
+	assert.Equal(t, ErrTermsMismatch, m.Create(context.Background(), e))
+
+	var err error
+	e.Key, err = DeriveKey(seed, e.Terms)
+	require.NoError(t, err)
+	require.NoError(t, m.Create(context.Background(), e))
+	_, err = m.Deposit(context.Background(), p.depositor, e)
+	require.NoError(t, err)
+
+	// Moving the deadline of the escrow does not release it early.
+	altered := e
+	altered.Deadline = clock.Now().Add(-time.Hour)
+	_, err = m.Release(context.Background(), altered, nil)
+	assert.Equal(t, ErrTermsMismatch, err)
+
+	clock.advance(time.Hour)
+	_, err = m.Release(context.Background(), e, nil)
+	require.NoError(t, err)
+	assertBalance(t, fake, p.beneficiary.Public(), 100)
+}
@@ -0,0 +1,156 @@
+package escrow
+
+import (
+	"context"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// State is the state of an escrow.
+type State int
+
+const (
+	// StateUnfunded indicates the escrow holds no funds.
+	StateUnfunded State = iota
+
+	// StatePartiallyFunded indicates the escrow holds less than the amount
+	// of its terms.
+	StatePartiallyFunded
+
+	// StateFunded indicates the escrow holds at least the amount of its
+	// terms, and can be released.
+	StateFunded
+
+	// StateReleased indicates funds were released to the beneficiary.
+	StateReleased
+
+	// StateRefunded indicates funds were refunded to the depositor.
+	StateRefunded
+)
+
+func (s State) String() string {
+	switch s {
+	case StateUnfunded:
+		return "unfunded"
+	case StatePartiallyFunded:
+		return "partially funded"
+	case StateFunded:
+		return "funded"
+	case StateReleased:
+		return "released"
+	case StateRefunded:
+		return "refunded"
+	default:
+		return "unknown"
+	}
+}
+
+// Status is the status of an escrow, derived from its balance and history.
+type Status struct {
+	State State
+
+	// Balance is the amount currently held by the escrow.
+	Balance int64
+
+	// Deposited, Released, and Refunded are the total amounts paid into the
+	// escrow, released to the beneficiary, and refunded to the depositor, by
+	// successful transactions.
+	Deposited int64
+	Released  int64
+	Refunded  int64
+
+	// Expired is set if the deadline of the escrow has passed.
+	Expired bool
+}
+
+// Inspect returns the status of e. The history of the escrow account is walked
+// in full, so escrows are expected to have short histories.
+func (m *Manager) Inspect(ctx context.Context, e Escrow) (Status, error) {
+	status := Status{Expired: m.expired(e)}
+
+	balance, err := m.client.GetBalance(ctx, e.Address(), m.opts.solanaOpts...)
+	if err == client.ErrAccountDoesNotExist {
+		return status, nil
+	} else if err != nil {
+		return Status{}, errors.Wrap(err, "failed to get escrow balance")
+	}
+	status.Balance = balance
+
+	escrowAccounts, err := m.accounts(ctx, e.Address())
+	if err != nil {
+		return Status{}, err
+	}
+	beneficiaryAccounts, err := m.accounts(ctx, e.Beneficiary)
+	if err != nil {
+		return Status{}, err
+	}
+	depositorAccounts, err := m.accounts(ctx, e.Depositor)
+	if err != nil {
+		return Status{}, err
+	}
+
+	var cursor client.Cursor
+	for {
+		items, err := m.client.GetHistory(ctx, e.Address(), cursor, m.opts.solanaOpts...)
+		if err != nil {
+			return Status{}, errors.Wrap(err, "failed to get escrow history")
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, item := range items {
+			if item.TxState != client.TransactionStateSuccess {
+				continue
+			}
+
+			for _, p := range item.Payments {
+				_, fromEscrow := escrowAccounts[string(p.Sender)]
+				_, toEscrow := escrowAccounts[string(p.Destination)]
+				_, toBeneficiary := beneficiaryAccounts[string(p.Destination)]
+				_, toDepositor := depositorAccounts[string(p.Destination)]
+
+				switch {
+				case toEscrow && !fromEscrow:
+					status.Deposited += p.Quarks
+				case fromEscrow && toBeneficiary:
+					status.Released += p.Quarks
+				case fromEscrow && toDepositor:
+					status.Refunded += p.Quarks
+				}
+			}
+		}
+
+		cursor = items[len(items)-1].Cursor
+	}
+
+	switch {
+	case status.Released > 0:
+		status.State = StateReleased
+	case status.Refunded > 0:
+		status.State = StateRefunded
+	case status.Balance >= e.Quarks:
+		status.State = StateFunded
+	case status.Balance > 0:
+		status.State = StatePartiallyFunded
+	}
+
+	return status, nil
+}
+
+// accounts returns account and the token accounts it owns.
+func (m *Manager) accounts(ctx context.Context, account kin.PublicKey) (map[string]struct{}, error) {
+	tokenAccounts, err := m.client.ResolveTokenAccounts(ctx, account)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve token accounts")
+	}
+
+	accounts := map[string]struct{}{string(account): {}}
+	for _, a := range tokenAccounts {
+		accounts[string(a)] = struct{}{}
+	}
+	return accounts, nil
+}
@@ -0,0 +1,235 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+
+	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
+)
+
+// EventSource opens a stream of events for an account. It is implemented by
+// InternalClient.
+type EventSource interface {
+	GetEvents(ctx context.Context, account kin.PublicKey) (<-chan EventsResult, error)
+}
+
+// EventHandler handles the events of an account subscribed to with an EventHub.
+// If result.Err is set, the stream failed, and will be reopened.
+type EventHandler func(account kin.PublicKey, result EventsResult)
+
+type eventHubOpts struct {
+	maxStreams     int
+	reconnectDelay time.Duration
+	filters        []FilterOption
+}
+
+// EventHubOption configures an EventHub.
+type EventHubOption func(*eventHubOpts)
+
+// WithMaxStreams specifies the maximum number of event streams an EventHub
+// opens concurrently. Defaults to 100.
+func WithMaxStreams(n int) EventHubOption {
+	return func(o *eventHubOpts) {
+		o.maxStreams = n
+	}
+}
+
+// WithReconnectDelay specifies how long an EventHub waits before reopening a
+// failed event stream. Defaults to 1 second.
+func WithReconnectDelay(delay time.Duration) EventHubOption {
+	return func(o *eventHubOpts) {
+		o.reconnectDelay = delay
+	}
+}
+
+// WithEventFilters specifies filters that are applied to the transaction events
+// dispatched by an EventHub, as with FilterPayments. Transaction events without
+// any matching payments are not dispatched. Other events, and transactions that
+// cannot be parsed, are always dispatched.
+//
+// Since events contain the raw transaction, matching transactions are dispatched
+// as is, including any payments that don't match.
+func WithEventFilters(filters ...FilterOption) EventHubOption {
+	return func(o *eventHubOpts) {
+		o.filters = append(o.filters, filters...)
+	}
+}
+
+// EventHub multiplexes the event subscriptions of many accounts.
+//
+// Agora streams the events of a single account per stream, so the hub opens one
+// stream for each subscribed account, which is shared by all of the account's
+// handlers. Streams are opened when an account gains its first handler, and
+// closed when it loses its last, up to a bounded number of concurrent streams.
+type EventHub struct {
+	source EventSource
+	opts   eventHubOpts
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	streams map[string]*eventStream
+	nextID  uint64
+	wg      sync.WaitGroup
+}
+
+type eventStream struct {
+	cancel   context.CancelFunc
+	handlers map[uint64]EventHandler
+}
+
+// NewEventHub returns a new EventHub that opens streams with source.
+func NewEventHub(source EventSource, opts ...EventHubOption) *EventHub {
+	h := &EventHub{
+		source: source,
+		opts: eventHubOpts{
+			maxStreams:     100,
+			reconnectDelay: time.Second,
+		},
+		streams: make(map[string]*eventStream),
+	}
+	for _, o := range opts {
+		o(&h.opts)
+	}
+
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	return h
+}
+
+// Subscribe calls handler with the events of account until the returned
+// unsubscribe function is called, or the hub is closed.
+func (h *EventHub) Subscribe(account kin.PublicKey, handler EventHandler) (unsubscribe func(), err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.ctx.Err() != nil {
+		return nil, ErrEventHubClosed
+	}
+
+	stream, ok := h.streams[string(account)]
+	if !ok {
+		if len(h.streams) >= h.opts.maxStreams {
+			return nil, ErrTooManyStreams
+		}
+
+		ctx, cancel := context.WithCancel(h.ctx)
+		stream = &eventStream{
+			cancel:   cancel,
+			handlers: make(map[uint64]EventHandler),
+		}
+		h.streams[string(account)] = stream
+
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			h.watch(ctx, account, stream)
+		}()
+	}
+
+	id := h.nextID
+	h.nextID++
+	stream.handlers[id] = handler
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			h.unsubscribe(account, stream, id)
+		})
+	}, nil
+}
+
+func (h *EventHub) unsubscribe(account kin.PublicKey, stream *eventStream, id uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(stream.handlers, id)
+	if len(stream.handlers) == 0 && h.streams[string(account)] == stream {
+		delete(h.streams, string(account))
+		stream.cancel()
+	}
+}
+
+// Streams returns the number of open streams, which is the number of accounts
+// with at least one handler.
+func (h *EventHub) Streams() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.streams)
+}
+
+// Close closes all streams, and waits for any in progress handlers to return.
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	h.cancel()
+	h.streams = make(map[string]*eventStream)
+	h.mu.Unlock()
+
+	h.wg.Wait()
+}
+
+// watch opens a stream of the events of account, reopening it if it fails,
+// until ctx is cancelled.
+func (h *EventHub) watch(ctx context.Context, account kin.PublicKey, stream *eventStream) {
+	for ctx.Err() == nil {
+		ch, err := h.source.GetEvents(ctx, account)
+		if err != nil {
+			h.dispatch(ctx, account, stream, EventsResult{Err: err})
+		} else {
+			// The channel is drained until it is closed, even after ctx is
+			// cancelled, so that the source can exit.
+			for result := range ch {
+				h.dispatch(ctx, account, stream, result)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(h.opts.reconnectDelay):
+		}
+	}
+}
+
+func (h *EventHub) dispatch(ctx context.Context, account kin.PublicKey, stream *eventStream, result EventsResult) {
+	if ctx.Err() != nil {
+		return
+	}
+	if len(h.opts.filters) > 0 && result.Err == nil {
+		if result.Events = filterEvents(result.Events, h.opts.filters); len(result.Events) == 0 {
+			return
+		}
+	}
+
+	h.mu.Lock()
+	handlers := make([]EventHandler, 0, len(stream.handlers))
+	for _, handler := range stream.handlers {
+		handlers = append(handlers, handler)
+	}
+	h.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(account, result)
+	}
+}
+
+// filterEvents returns the events that aren't transaction events, or whose
+// transactions contain a payment matching filters.
+func filterEvents(events []*accountpbv4.Event, filters []FilterOption) []*accountpbv4.Event {
+	var filtered []*accountpbv4.Event
+	for _, e := range events {
+		if event := e.GetTransactionEvent(); event != nil {
+			item, err := parseTransactionEvent(event)
+			if err == nil {
+				if _, ok := FilterPayments(item.TransactionData, filters...); !ok {
+					continue
+				}
+			}
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
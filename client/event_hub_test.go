@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
+)
+
+type testEventSource struct {
+	sync.Mutex
+	opens   map[string]int
+	streams map[string]chan EventsResult
+	err     error
+}
+
+func newTestEventSource() *testEventSource {
+	return &testEventSource{
+		opens:   make(map[string]int),
+		streams: make(map[string]chan EventsResult),
+	}
+}
+
+func (s *testEventSource) GetEvents(ctx context.Context, account kin.PublicKey) (<-chan EventsResult, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.opens[string(account)]++
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	ch := make(chan EventsResult)
+	s.streams[string(account)] = ch
+
+	go func() {
+		<-ctx.Done()
+		ch <- EventsResult{Err: ctx.Err()}
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+func (s *testEventSource) send(t *testing.T, account kin.PublicKey, result EventsResult) {
+	require.Eventually(t, func() bool {
+		s.Lock()
+		defer s.Unlock()
+		return s.streams[string(account)] != nil
+	}, time.Second, time.Millisecond)
+
+	s.Lock()
+	ch := s.streams[string(account)]
+	s.Unlock()
+
+	ch <- result
+}
+
+func (s *testEventSource) openCount(account kin.PublicKey) int {
+	s.Lock()
+	defer s.Unlock()
+	return s.opens[string(account)]
+}
+
+type eventRecorder struct {
+	sync.Mutex
+	results []EventsResult
+}
+
+func (r *eventRecorder) handle(_ kin.PublicKey, result EventsResult) {
+	r.Lock()
+	defer r.Unlock()
+	r.results = append(r.results, result)
+}
+
+func (r *eventRecorder) count() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.results)
+}
+
+func TestEventHub_FanOut(t *testing.T) {
+	source := newTestEventSource()
+	hub := NewEventHub(source, WithReconnectDelay(time.Millisecond))
+	defer hub.Close()
+
+	accounts := make([]kin.PublicKey, 2)
+	for i := range accounts {
+		priv, err := kin.NewPrivateKey()
+		require.NoError(t, err)
+		accounts[i] = priv.Public()
+	}
+
+	recorders := make([]*eventRecorder, 3)
+	unsubscribes := make([]func(), 3)
+	for i := range recorders {
+		recorders[i] = &eventRecorder{}
+
+		var err error
+		unsubscribes[i], err = hub.Subscribe(accounts[i/2], recorders[i].handle)
+		require.NoError(t, err)
+	}
+
+	// The handlers of an account share a single stream.
+	assert.Equal(t, 2, hub.Streams())
+
+	events := []*accountpbv4.Event{
+		{
+			Type: &accountpbv4.Event_AccountUpdateEvent{
+				AccountUpdateEvent: &accountpbv4.AccountUpdateEvent{},
+			},
+		},
+	}
+	source.send(t, accounts[0], EventsResult{Events: events})
+
+	for _, r := range recorders[:2] {
+		require.Eventually(t, func() bool { return r.count() == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, events, r.results[0].Events)
+	}
+	assert.Equal(t, 0, recorders[2].count())
+
+	// The stream is kept open until the account's last handler unsubscribes.
+	unsubscribes[0]()
+	assert.Equal(t, 2, hub.Streams())
+	unsubscribes[1]()
+	unsubscribes[1]()
+	assert.Equal(t, 1, hub.Streams())
+
+	unsubscribes[2]()
+	assert.Equal(t, 0, hub.Streams())
+	assert.Equal(t, 1, source.openCount(accounts[0]))
+	assert.Equal(t, 1, source.openCount(accounts[1]))
+
+	for _, r := range recorders {
+		for _, result := range r.results {
+			assert.NoError(t, result.Err)
+		}
+	}
+}
+
+func TestEventHub_MaxStreams(t *testing.T) {
+	source := newTestEventSource()
+	hub := NewEventHub(source, WithMaxStreams(1))
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	other, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	unsubscribe, err := hub.Subscribe(priv.Public(), func(kin.PublicKey, EventsResult) {})
+	require.NoError(t, err)
+
+	// Additional handlers of an account with a stream do not count against the limit.
+	_, err = hub.Subscribe(priv.Public(), func(kin.PublicKey, EventsResult) {})
+	require.NoError(t, err)
+
+	_, err = hub.Subscribe(other.Public(), func(kin.PublicKey, EventsResult) {})
+	assert.Equal(t, ErrTooManyStreams, err)
+
+	hub.Close()
+	assert.Equal(t, 0, hub.Streams())
+
+	_, err = hub.Subscribe(other.Public(), func(kin.PublicKey, EventsResult) {})
+	assert.Equal(t, ErrEventHubClosed, err)
+
+	// Unsubscribing after the hub was closed is a no-op.
+	unsubscribe()
+}
+
+func TestEventHub_Reconnect(t *testing.T) {
+	source := newTestEventSource()
+	source.err = errors.New("unavailable")
+
+	hub := NewEventHub(source, WithReconnectDelay(time.Millisecond))
+	defer hub.Close()
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	recorder := &eventRecorder{}
+	_, err = hub.Subscribe(priv.Public(), recorder.handle)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return recorder.count() >= 2 }, time.Second, time.Millisecond)
+	recorder.Lock()
+	assert.Equal(t, source.err, recorder.results[0].Err)
+	recorder.Unlock()
+
+	source.Lock()
+	source.err = nil
+	source.Unlock()
+
+	// Events are delivered once the stream is reopened.
+	events := []*accountpbv4.Event{
+		{
+			Type: &accountpbv4.Event_AccountUpdateEvent{
+				AccountUpdateEvent: &accountpbv4.AccountUpdateEvent{},
+			},
+		},
+	}
+	source.send(t, priv.Public(), EventsResult{Events: events})
+
+	require.Eventually(t, func() bool {
+		recorder.Lock()
+		defer recorder.Unlock()
+		return len(recorder.results[len(recorder.results)-1].Events) == 1
+	}, time.Second, time.Millisecond)
+	assert.True(t, source.openCount(priv.Public()) >= 3)
+}
@@ -0,0 +1,67 @@
+package eventpub
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// maxAWSBatchSize is the maximum number of entries in an SNS PublishBatch, or
+// an SQS SendMessageBatch, request.
+const maxAWSBatchSize = 10
+
+// AWSBatchSender sends a batch of at most 10 messages to an SNS topic or SQS
+// queue, identified by destination. It is typically a wrapper around the
+// PublishBatch or SendMessageBatch operation of the AWS SDK, which should use
+// Message.Key as the entry ID (and, for FIFO destinations, the deduplication
+// ID).
+//
+// An error should be returned if any entry in the batch failed.
+type AWSBatchSender interface {
+	SendBatch(ctx context.Context, destination string, msgs []Message) error
+}
+
+type awsPublisher struct {
+	sender      AWSBatchSender
+	destination string
+	service     string
+}
+
+// NewSNSPublisher returns an EventPublisher that publishes events to the
+// specified SNS topic.
+func NewSNSPublisher(sender AWSBatchSender, topicARN string) client.EventPublisher {
+	return &awsPublisher{
+		sender:      sender,
+		destination: topicARN,
+		service:     "sns",
+	}
+}
+
+// NewSQSPublisher returns an EventPublisher that sends events to the specified
+// SQS queue.
+func NewSQSPublisher(sender AWSBatchSender, queueURL string) client.EventPublisher {
+	return &awsPublisher{
+		sender:      sender,
+		destination: queueURL,
+		service:     "sqs",
+	}
+}
+
+// Publish implements client.EventPublisher.Publish.
+func (p *awsPublisher) Publish(ctx context.Context, events []client.StoredEvent) error {
+	msgs := newMessages(events)
+	for start := 0; start < len(msgs); start += maxAWSBatchSize {
+		end := start + maxAWSBatchSize
+		if end > len(msgs) {
+			end = len(msgs)
+		}
+
+		if err := p.sender.SendBatch(ctx, p.destination, msgs[start:end]); err != nil {
+			return errors.Wrapf(err, "failed to send events to %s", p.service)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+// Package eventpub provides client.EventPublisher implementations that publish
+// Events webhook events to Kafka, NATS, and AWS SNS/SQS.
+//
+// To avoid depending on any particular client library, each publisher accepts
+// a small interface, which is either satisfied directly by the library's
+// client, or by a thin wrapper around it.
+package eventpub
+
+import (
+	"encoding/hex"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// Message is an event, as published to a message queue.
+type Message struct {
+	// Key is the hex encoded ID of the event. Redeliveries of an event have
+	// the same key, so it can be used to discard duplicates.
+	Key string
+
+	// Value is the JSON encoding of the event, as sent by Agora.
+	Value []byte
+}
+
+func newMessages(events []client.StoredEvent) []Message {
+	msgs := make([]Message, len(events))
+	for i, e := range events {
+		msgs[i] = Message{
+			Key:   hex.EncodeToString(e.ID),
+			Value: e.Raw,
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,146 @@
+package eventpub
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+func storedEvents(n int) []client.StoredEvent {
+	events := make([]client.StoredEvent, n)
+	for i := range events {
+		raw := json.RawMessage(fmt.Sprintf(`{"transaction_event":{"kin_version":4,"tx_id":"%d"}}`, i))
+		id := sha256.Sum256(raw)
+		events[i] = client.StoredEvent{
+			ID:  id[:],
+			Raw: raw,
+		}
+	}
+	return events
+}
+
+type fakeKafkaWriter struct {
+	topics []string
+	msgs   []Message
+	err    error
+}
+
+func (w *fakeKafkaWriter) WriteMessages(_ context.Context, topic string, msgs ...Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.topics = append(w.topics, topic)
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	w := &fakeKafkaWriter{}
+	p := NewKafkaPublisher(w, "events")
+
+	events := storedEvents(3)
+	require.NoError(t, p.Publish(context.Background(), events))
+	require.NoError(t, p.Publish(context.Background(), nil))
+
+	assert.Equal(t, []string{"events"}, w.topics)
+	require.Len(t, w.msgs, 3)
+	for i, m := range w.msgs {
+		assert.Equal(t, hex.EncodeToString(events[i].ID), m.Key)
+		assert.Equal(t, []byte(events[i].Raw), m.Value)
+	}
+
+	w.err = errors.New("unavailable")
+	assert.Equal(t, w.err, errors.Cause(p.Publish(context.Background(), events)))
+}
+
+type fakeNATSConn struct {
+	subjects []string
+	data     [][]byte
+	flushes  int
+	err      error
+}
+
+func (c *fakeNATSConn) Publish(subject string, data []byte) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.subjects = append(c.subjects, subject)
+	c.data = append(c.data, data)
+	return nil
+}
+
+func (c *fakeNATSConn) FlushWithContext(context.Context) error {
+	c.flushes++
+	return nil
+}
+
+func TestNATSPublisher(t *testing.T) {
+	conn := &fakeNATSConn{}
+	p := NewNATSPublisher(conn, "kin.events")
+
+	events := storedEvents(2)
+	require.NoError(t, p.Publish(context.Background(), events))
+
+	assert.Equal(t, []string{"kin.events", "kin.events"}, conn.subjects)
+	assert.Equal(t, [][]byte{events[0].Raw, events[1].Raw}, conn.data)
+	assert.Equal(t, 1, conn.flushes)
+
+	conn.err = errors.New("unavailable")
+	assert.Equal(t, conn.err, errors.Cause(p.Publish(context.Background(), events)))
+	assert.Equal(t, 1, conn.flushes)
+}
+
+type fakeAWSBatchSender struct {
+	destinations []string
+	batches      [][]Message
+	err          error
+}
+
+func (s *fakeAWSBatchSender) SendBatch(_ context.Context, destination string, msgs []Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.destinations = append(s.destinations, destination)
+	s.batches = append(s.batches, msgs)
+	return nil
+}
+
+func TestAWSPublishers(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		destination string
+		new         func(AWSBatchSender, string) client.EventPublisher
+	}{
+		{name: "sns", destination: "arn:aws:sns:us-east-1:123456789012:events", new: NewSNSPublisher},
+		{name: "sqs", destination: "https://sqs.us-east-1.amazonaws.com/123456789012/events", new: NewSQSPublisher},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sender := &fakeAWSBatchSender{}
+			p := tc.new(sender, tc.destination)
+
+			events := storedEvents(23)
+			require.NoError(t, p.Publish(context.Background(), events))
+
+			require.Len(t, sender.batches, 3)
+			for i, size := range []int{10, 10, 3} {
+				assert.Equal(t, tc.destination, sender.destinations[i])
+				assert.Len(t, sender.batches[i], size)
+			}
+			assert.Equal(t, hex.EncodeToString(events[22].ID), sender.batches[2][2].Key)
+
+			sender.err = errors.New("unavailable")
+			err := p.Publish(context.Background(), events)
+			assert.Equal(t, sender.err, errors.Cause(err))
+			assert.Contains(t, err.Error(), tc.name)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package eventpub
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// KafkaWriter writes messages to a Kafka topic, returning once they have been
+// acknowledged. It is typically a wrapper around a kafka-go Writer, or a
+// sarama SyncProducer.
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, topic string, msgs ...Message) error
+}
+
+type kafkaPublisher struct {
+	w     KafkaWriter
+	topic string
+}
+
+// NewKafkaPublisher returns an EventPublisher that writes events to the
+// specified Kafka topic, keyed by event ID.
+func NewKafkaPublisher(w KafkaWriter, topic string) client.EventPublisher {
+	return &kafkaPublisher{
+		w:     w,
+		topic: topic,
+	}
+}
+
+// Publish implements client.EventPublisher.Publish.
+func (p *kafkaPublisher) Publish(ctx context.Context, events []client.StoredEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	if err := p.w.WriteMessages(ctx, p.topic, newMessages(events)...); err != nil {
+		return errors.Wrap(err, "failed to write events to kafka")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package eventpub
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// NATSConn publishes messages to NATS subjects. It is satisfied by *nats.Conn.
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+	FlushWithContext(ctx context.Context) error
+}
+
+type natsPublisher struct {
+	conn    NATSConn
+	subject string
+}
+
+// NewNATSPublisher returns an EventPublisher that publishes the JSON encoding
+// of each event to the specified subject.
+//
+// The connection is flushed after each batch, so that Publish returns once the
+// server has received the events. NATS itself does not persist messages, so
+// JetStream should be used if subscribers may be offline.
+func NewNATSPublisher(conn NATSConn, subject string) client.EventPublisher {
+	return &natsPublisher{
+		conn:    conn,
+		subject: subject,
+	}
+}
+
+// Publish implements client.EventPublisher.Publish.
+func (p *natsPublisher) Publish(ctx context.Context, events []client.StoredEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	for _, e := range events {
+		if err := p.conn.Publish(p.subject, e.Raw); err != nil {
+			return errors.Wrap(err, "failed to publish event to nats")
+		}
+	}
+
+	if err := p.conn.FlushWithContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to flush nats connection")
+	}
+	return nil
+}
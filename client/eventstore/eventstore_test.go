@@ -0,0 +1,91 @@
+package eventstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+func storedEvent(t *testing.T, txID string, received time.Time) client.StoredEvent {
+	e := events.Event{
+		TransactionEvent: &events.TransactionEvent{
+			KinVersion: 4,
+			TxID:       []byte(txID),
+			SolanaEvent: &events.SolanaEvent{
+				Transaction: []byte("tx"),
+			},
+		},
+	}
+	raw, err := json.Marshal(e)
+	require.NoError(t, err)
+
+	id := sha256.Sum256(raw)
+	return client.StoredEvent{
+		ID:       id[:],
+		Raw:      raw,
+		Event:    e,
+		Received: received,
+	}
+}
+
+func testStore(t *testing.T, s client.EventStore) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+
+	// Batches larger than the maximum batch size of any store.
+	var batch []client.StoredEvent
+	for i := 0; i < 1050; i++ {
+		batch = append(batch, storedEvent(t, fmt.Sprintf("old-%d", i), now.Add(-2*time.Hour)))
+	}
+	require.NoError(t, s.Save(ctx, batch))
+
+	recent := []client.StoredEvent{
+		storedEvent(t, "a", now),
+		storedEvent(t, "b", now),
+	}
+	require.NoError(t, s.Save(ctx, recent))
+
+	// Redeliveries are ignored.
+	require.NoError(t, s.Save(ctx, recent[:1]))
+
+	stored, err := s.Events(ctx, now.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	for i, e := range stored {
+		assert.Equal(t, recent[i].ID, e.ID)
+		assert.JSONEq(t, string(recent[i].Raw), string(e.Raw))
+		assert.Equal(t, recent[i].Event, e.Event)
+		assert.True(t, recent[i].Received.Equal(e.Received))
+	}
+
+	stored, err = s.Events(ctx, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, stored, len(batch)+len(recent))
+
+	n, err := s.Prune(ctx, now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, len(batch), n)
+
+	stored, err = s.Events(ctx, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, stored, len(recent))
+
+	// Pruned events can be saved again.
+	require.NoError(t, s.Save(ctx, batch[:1]))
+	stored, err = s.Events(ctx, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, stored, len(recent)+1)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
@@ -0,0 +1,73 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+type memoryStore struct {
+	mu     sync.Mutex
+	events []client.StoredEvent
+	ids    map[string]struct{}
+}
+
+// NewMemoryStore returns an in-memory EventStore, intended for tests and
+// development.
+func NewMemoryStore() client.EventStore {
+	return &memoryStore{
+		ids: make(map[string]struct{}),
+	}
+}
+
+// Save implements client.EventStore.Save.
+func (m *memoryStore) Save(_ context.Context, events []client.StoredEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range events {
+		if _, ok := m.ids[string(e.ID)]; ok {
+			continue
+		}
+
+		m.ids[string(e.ID)] = struct{}{}
+		m.events = append(m.events, e)
+	}
+
+	return nil
+}
+
+// Events implements client.EventStore.Events.
+func (m *memoryStore) Events(_ context.Context, since time.Time) ([]client.StoredEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []client.StoredEvent
+	for _, e := range m.events {
+		if !e.Received.Before(since) {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// Prune implements client.EventStore.Prune.
+func (m *memoryStore) Prune(_ context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var kept []client.StoredEvent
+	for _, e := range m.events {
+		if e.Received.Before(before) {
+			delete(m.ids, string(e.ID))
+			continue
+		}
+		kept = append(kept, e)
+	}
+
+	pruned := int64(len(m.events) - len(kept))
+	m.events = kept
+	return pruned, nil
+}
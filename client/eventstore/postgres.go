@@ -0,0 +1,57 @@
+package eventstore
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// PostgresSchema contains the statements that create the tables used by the
+// Postgres EventStore.
+const PostgresSchema = `
+CREATE TABLE IF NOT EXISTS webhook_events (
+	seq               BIGSERIAL PRIMARY KEY,
+	id                BYTEA NOT NULL UNIQUE,
+	tx_id             BYTEA,
+	transaction_error TEXT NOT NULL,
+	raw               TEXT NOT NULL,
+	received_at       TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_events_tx_id_idx ON webhook_events (tx_id);
+CREATE INDEX IF NOT EXISTS webhook_events_received_at_idx ON webhook_events (received_at);
+`
+
+// NewPostgresStore returns an EventStore backed by a Postgres database, which
+// must contain the tables in PostgresSchema.
+//
+// The caller is responsible for importing a Postgres driver (such as
+// github.com/lib/pq) and opening the database.
+func NewPostgresStore(db *sql.DB) client.EventStore {
+	return &sqlStore{
+		db: db,
+		d: dialect{
+			insert:   "INSERT INTO",
+			conflict: " ON CONFLICT (id) DO NOTHING",
+			placeholder: func(n int) string {
+				return fmt.Sprintf("$%d", n)
+			},
+			// Postgres supports up to 65535 parameters per statement.
+			maxBatch: 1000,
+			encodeTime: func(t time.Time) interface{} {
+				return t
+			},
+			decodeTime: func(v interface{}) (time.Time, error) {
+				t, ok := v.(time.Time)
+				if !ok {
+					return time.Time{}, errors.Errorf("unexpected received_at type: %T", v)
+				}
+				return t, nil
+			},
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package eventstore
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPostgresStore runs against the database specified by
+// EVENTSTORE_POSTGRES_URL, and is skipped if it is not set. The event table is
+// dropped and recreated.
+func TestPostgresStore(t *testing.T) {
+	url := os.Getenv("EVENTSTORE_POSTGRES_URL")
+	if url == "" {
+		t.Skip("EVENTSTORE_POSTGRES_URL not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS webhook_events`)
+	require.NoError(t, err)
+	_, err = db.Exec(PostgresSchema)
+	require.NoError(t, err)
+
+	testStore(t, NewPostgresStore(db))
+}
@@ -0,0 +1,164 @@
+// Package eventstore provides implementations of client.EventStore, which
+// persists the events received by an Events webhook.
+//
+// The SQL implementations accept a *sql.DB, and the caller is responsible for
+// importing a driver and creating the tables in the corresponding schema.
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// dialect contains the database specific parts of the SQL store.
+type dialect struct {
+	// insert is the statement prefix used to insert events, ignoring events
+	// that already exist.
+	insert string
+
+	// conflict is the statement suffix that ignores existing events, if any.
+	conflict string
+
+	// placeholder returns the placeholder for the nth (1-based) parameter.
+	placeholder func(n int) string
+
+	// maxBatch is the maximum number of events inserted by a single statement,
+	// to stay within the parameter limit of the database.
+	maxBatch int
+
+	// encodeTime and decodeTime convert between time.Time and the stored
+	// representation of the received time.
+	encodeTime func(time.Time) interface{}
+	decodeTime func(interface{}) (time.Time, error)
+}
+
+const eventColumns = 5
+
+type sqlStore struct {
+	db *sql.DB
+	d  dialect
+}
+
+// Save implements client.EventStore.Save.
+func (s *sqlStore) Save(ctx context.Context, events []client.StoredEvent) error {
+	for start := 0; start < len(events); start += s.d.maxBatch {
+		end := start + s.d.maxBatch
+		if end > len(events) {
+			end = len(events)
+		}
+
+		if err := s.insert(ctx, events[start:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqlStore) insert(ctx context.Context, events []client.StoredEvent) error {
+	var b strings.Builder
+	b.WriteString(s.d.insert)
+	b.WriteString(" webhook_events (id, tx_id, transaction_error, raw, received_at) VALUES ")
+
+	args := make([]interface{}, 0, len(events)*eventColumns)
+	for i, e := range events {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		b.WriteString("(")
+		for j := 0; j < eventColumns; j++ {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(s.d.placeholder(len(args) + j + 1))
+		}
+		b.WriteString(")")
+
+		var txID []byte
+		var txError string
+		if te := e.Event.TransactionEvent; te != nil {
+			txID = te.TxID
+			if te.SolanaEvent != nil {
+				txError = te.SolanaEvent.TransactionError
+			}
+		}
+
+		args = append(args, e.ID, txID, txError, string(e.Raw), s.d.encodeTime(e.Received))
+	}
+	b.WriteString(s.d.conflict)
+
+	if _, err := s.db.ExecContext(ctx, b.String(), args...); err != nil {
+		return errors.Wrap(err, "failed to insert events")
+	}
+
+	return nil
+}
+
+// Events implements client.EventStore.Events.
+func (s *sqlStore) Events(ctx context.Context, since time.Time) ([]client.StoredEvent, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		fmt.Sprintf(`SELECT id, raw, received_at FROM webhook_events WHERE received_at >= %s ORDER BY seq`, s.d.placeholder(1)),
+		s.d.encodeTime(since),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query events")
+	}
+	defer rows.Close()
+
+	var result []client.StoredEvent
+	for rows.Next() {
+		var e client.StoredEvent
+		var raw string
+		var received interface{}
+		if err := rows.Scan(&e.ID, &raw, &received); err != nil {
+			return nil, errors.Wrap(err, "failed to scan event")
+		}
+
+		e.Raw = json.RawMessage(raw)
+		if e.Received, err = s.d.decodeTime(received); err != nil {
+			return nil, err
+		}
+
+		var decoded events.Event
+		if err := json.Unmarshal(e.Raw, &decoded); err != nil {
+			return nil, errors.Wrap(err, "failed to decode event")
+		}
+		e.Event = decoded
+
+		result = append(result, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to query events")
+	}
+
+	return result, nil
+}
+
+// Prune implements client.EventStore.Prune.
+func (s *sqlStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(
+		ctx,
+		fmt.Sprintf(`DELETE FROM webhook_events WHERE received_at < %s`, s.d.placeholder(1)),
+		s.d.encodeTime(before),
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to prune events")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to prune events")
+	}
+	return n, nil
+}
@@ -0,0 +1,58 @@
+package eventstore
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// SQLiteSchema contains the statements that create the tables used by the
+// SQLite EventStore.
+//
+// Received times are stored as nanoseconds since the Unix epoch, since SQLite
+// has no native time type.
+const SQLiteSchema = `
+CREATE TABLE IF NOT EXISTS webhook_events (
+	seq               INTEGER PRIMARY KEY AUTOINCREMENT,
+	id                BLOB NOT NULL UNIQUE,
+	tx_id             BLOB,
+	transaction_error TEXT NOT NULL,
+	raw               TEXT NOT NULL,
+	received_at       INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_events_tx_id_idx ON webhook_events (tx_id);
+CREATE INDEX IF NOT EXISTS webhook_events_received_at_idx ON webhook_events (received_at);
+`
+
+// NewSQLiteStore returns an EventStore backed by a SQLite database, which must
+// contain the tables in SQLiteSchema.
+//
+// The caller is responsible for importing a SQLite driver (such as
+// github.com/mattn/go-sqlite3) and opening the database.
+func NewSQLiteStore(db *sql.DB) client.EventStore {
+	return &sqlStore{
+		db: db,
+		d: dialect{
+			insert: "INSERT OR IGNORE INTO",
+			placeholder: func(int) string {
+				return "?"
+			},
+			// Older versions of SQLite support up to 999 parameters per statement.
+			maxBatch: 100,
+			encodeTime: func(t time.Time) interface{} {
+				return t.UnixNano()
+			},
+			decodeTime: func(v interface{}) (time.Time, error) {
+				n, ok := v.(int64)
+				if !ok {
+					return time.Time{}, errors.Errorf("unexpected received_at type: %T", v)
+				}
+				return time.Unix(0, n), nil
+			},
+		},
+	}
+}
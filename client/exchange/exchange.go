@@ -0,0 +1,399 @@
+// Package exchange implements common integration patterns for exchanges
+// (and other custodial services) handling Kin deposits and withdrawals.
+package exchange
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// DepositStatus is the status of a detected deposit.
+type DepositStatus int
+
+const (
+	// DepositStatusPending indicates the deposit has been observed, but has
+	// not reached the finality commitment. It should not be credited yet.
+	DepositStatusPending DepositStatus = iota
+
+	// DepositStatusFinal indicates the deposit has reached the finality
+	// commitment, and can be safely credited.
+	DepositStatusFinal
+
+	// DepositStatusFailed indicates the transaction containing the deposit
+	// failed, and should not be credited.
+	DepositStatusFailed
+)
+
+// Deposit is a payment to one of the exchange's deposit addresses.
+type Deposit struct {
+	TxID         []byte
+	PaymentIndex int
+
+	// Reference is the reference associated with the deposit address, or
+	// the memo of the payment if the address is a memo deposit address.
+	Reference string
+
+	Sender      kin.PublicKey
+	Destination kin.PublicKey
+	Quarks      int64
+	Memo        string
+
+	Status DepositStatus
+}
+
+// Withdrawal is a request to send funds out of the exchange.
+type Withdrawal struct {
+	// ID uniquely identifies the withdrawal within the exchange. It is used
+	// to derive the dedupe ID of the submitted transaction, so retrying a
+	// withdrawal with the same ID will not result in a second payment.
+	ID string
+
+	Sender      kin.PrivateKey
+	Destination kin.PublicKey
+	Quarks      int64
+	Memo        string
+}
+
+// Exchange detects deposits and submits withdrawals.
+type Exchange struct {
+	client client.Client
+	opts   opts
+
+	mu      sync.Mutex
+	pending map[string][]byte
+
+	// tokenAccounts maps the token accounts of registered addresses to the
+	// addresses. It is replaced, under mu, each time the addresses are resolved.
+	tokenAccounts map[string]string
+	resolvedAt    time.Time
+
+	// resolveMu serializes resolutions, so that concurrent checks don't each
+	// resolve the registered addresses.
+	resolveMu sync.Mutex
+	now       func() time.Time
+}
+
+type opts struct {
+	depositAddresses map[string]string
+	memoAddresses    map[string]struct{}
+
+	detectCommitment   commonpbv4.Commitment
+	finalityCommitment commonpbv4.Commitment
+
+	resolveInterval time.Duration
+}
+
+// Option configures an Exchange.
+type Option func(*opts)
+
+// WithDepositAddress registers a dedicated deposit address, such as a
+// per-user address. Deposits to the address are reported with the
+// specified reference.
+//
+// The address may be an owner account, or one of its token accounts. On Kin 4,
+// payments are made to token accounts, so the token accounts of registered
+// addresses are resolved when a payment to an unknown account is first checked.
+// They are re-resolved at most once per resolve interval (see WithResolveInterval),
+// in case a token account was created since, so payments to accounts that aren't
+// deposit addresses don't each cause the addresses to be resolved.
+func WithDepositAddress(address kin.PublicKey, reference string) Option {
+	return func(o *opts) {
+		o.depositAddresses[address.Base58()] = reference
+	}
+}
+
+// WithMemoDepositAddress registers a shared deposit address, where deposits
+// are identified by the text memo of the payment. As with WithDepositAddress,
+// the address may be an owner account, or one of its token accounts.
+func WithMemoDepositAddress(address kin.PublicKey) Option {
+	return func(o *opts) {
+		o.memoAddresses[address.Base58()] = struct{}{}
+	}
+}
+
+// WithFinalityCommitment specifies the commitment a deposit must reach
+// before it is considered final. Defaults to commonpbv4.Commitment_MAX.
+func WithFinalityCommitment(commitment commonpbv4.Commitment) Option {
+	return func(o *opts) {
+		o.finalityCommitment = commitment
+	}
+}
+
+// WithResolveInterval specifies the minimum interval between resolutions of the
+// token accounts of the registered addresses. Defaults to 10 minutes.
+func WithResolveInterval(interval time.Duration) Option {
+	return func(o *opts) {
+		o.resolveInterval = interval
+	}
+}
+
+// New returns a new Exchange.
+func New(c client.Client, options ...Option) *Exchange {
+	e := &Exchange{
+		client: c,
+		opts: opts{
+			depositAddresses:   make(map[string]string),
+			memoAddresses:      make(map[string]struct{}),
+			detectCommitment:   commonpbv4.Commitment_RECENT,
+			finalityCommitment: commonpbv4.Commitment_MAX,
+			resolveInterval:    10 * time.Minute,
+		},
+		pending:       make(map[string][]byte),
+		tokenAccounts: make(map[string]string),
+		now:           time.Now,
+	}
+	for _, o := range options {
+		o(&e.opts)
+	}
+
+	return e
+}
+
+// CheckDeposits returns the deposits contained in the specified transaction.
+//
+// Deposits that have not yet reached the finality commitment are returned with
+// DepositStatusPending, and are tracked so that they can later be resolved
+// with Poll.
+func (e *Exchange) CheckDeposits(ctx context.Context, txID []byte) ([]Deposit, error) {
+	data, err := e.client.GetTransaction(ctx, txID, client.WithCommitment(e.opts.detectCommitment))
+	if err != nil {
+		return nil, err
+	}
+
+	deposits, err := e.matchDeposits(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(deposits) == 0 {
+		return nil, nil
+	}
+
+	status, err := e.status(ctx, txID, data)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	if status == DepositStatusPending {
+		e.pending[string(txID)] = txID
+	} else {
+		delete(e.pending, string(txID))
+	}
+	e.mu.Unlock()
+
+	for i := range deposits {
+		deposits[i].Status = status
+	}
+
+	return deposits, nil
+}
+
+// Poll re-checks all pending deposits, returning any deposits that have
+// since become final or failed.
+func (e *Exchange) Poll(ctx context.Context) ([]Deposit, error) {
+	e.mu.Lock()
+	txIDs := make([][]byte, 0, len(e.pending))
+	for _, id := range e.pending {
+		txIDs = append(txIDs, id)
+	}
+	e.mu.Unlock()
+
+	var resolved []Deposit
+	for _, txID := range txIDs {
+		deposits, err := e.CheckDeposits(ctx, txID)
+		if err != nil {
+			return resolved, errors.Wrapf(err, "failed to check deposits for %s", base58.Encode(txID))
+		}
+
+		for _, d := range deposits {
+			if d.Status != DepositStatusPending {
+				resolved = append(resolved, d)
+			}
+		}
+	}
+
+	return resolved, nil
+}
+
+// Pending returns the number of transactions with deposits that have
+// not yet been resolved.
+func (e *Exchange) Pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.pending)
+}
+
+// Withdraw submits a withdrawal.
+//
+// The dedupe ID of the transaction is derived from the withdrawal ID, which
+// allows withdrawals to be safely retried.
+func (e *Exchange) Withdraw(ctx context.Context, w Withdrawal, opts ...client.SolanaOption) ([]byte, error) {
+	if w.ID == "" {
+		return nil, errors.New("withdrawal id is required")
+	}
+
+	return e.client.SubmitPayment(ctx, client.Payment{
+		Sender:      w.Sender,
+		Destination: w.Destination,
+		Type:        kin.TransactionTypeNone,
+		Quarks:      w.Quarks,
+		Memo:        w.Memo,
+		DedupeID:    WithdrawalDedupeID(w.ID),
+	}, opts...)
+}
+
+// WithdrawalDedupeID returns the dedupe ID used for the withdrawal with the specified ID.
+func WithdrawalDedupeID(id string) []byte {
+	h := sha256.Sum256([]byte("withdrawal:" + id))
+	return h[:]
+}
+
+func (e *Exchange) matchDeposits(ctx context.Context, data client.TransactionData) ([]Deposit, error) {
+	var deposits []Deposit
+	refreshed := false
+	for i, p := range data.Payments {
+		if bytes.Equal(p.Sender, p.Destination) {
+			continue
+		}
+
+		address, ok := e.registeredAddress(p.Destination)
+		if !ok && !refreshed {
+			if err := e.resolveStaleTokenAccounts(ctx); err != nil {
+				return nil, err
+			}
+			refreshed = true
+			address, ok = e.registeredAddress(p.Destination)
+		}
+		if !ok {
+			continue
+		}
+
+		d := Deposit{
+			TxID:         data.TxID,
+			PaymentIndex: i,
+			Sender:       p.Sender,
+			Destination:  p.Destination,
+			Quarks:       p.Quarks,
+			Memo:         p.Memo,
+		}
+
+		if ref, ok := e.opts.depositAddresses[address]; ok {
+			d.Reference = ref
+		} else if p.Memo != "" {
+			d.Reference = p.Memo
+		} else {
+			continue
+		}
+
+		deposits = append(deposits, d)
+	}
+
+	return deposits, nil
+}
+
+// registeredAddress returns the registered deposit address that account is, or
+// is a known token account of.
+func (e *Exchange) registeredAddress(account kin.PublicKey) (string, bool) {
+	address := account.Base58()
+	if _, ok := e.opts.depositAddresses[address]; ok {
+		return address, true
+	}
+	if _, ok := e.opts.memoAddresses[address]; ok {
+		return address, true
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	address, ok := e.tokenAccounts[address]
+	return address, ok
+}
+
+// resolveStaleTokenAccounts resolves the token accounts of every registered address,
+// unless they were resolved within the resolve interval.
+func (e *Exchange) resolveStaleTokenAccounts(ctx context.Context) error {
+	e.resolveMu.Lock()
+	defer e.resolveMu.Unlock()
+
+	e.mu.Lock()
+	resolvedAt := e.resolvedAt
+	e.mu.Unlock()
+	if !resolvedAt.IsZero() && e.now().Sub(resolvedAt) < e.opts.resolveInterval {
+		return nil
+	}
+
+	var addresses []string
+	for address := range e.opts.depositAddresses {
+		addresses = append(addresses, address)
+	}
+	for address := range e.opts.memoAddresses {
+		addresses = append(addresses, address)
+	}
+
+	owners := make([]kin.PublicKey, len(addresses))
+	for i, address := range addresses {
+		owner, err := base58.Decode(address)
+		if err != nil {
+			return errors.Wrapf(err, "invalid deposit address %s", address)
+		}
+		owners[i] = owner
+	}
+
+	// The number of concurrent resolutions is bounded by client.WithMaxConcurrency.
+	accounts, err := e.client.ResolveTokenAccountsBatch(ctx, owners)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve token accounts of deposit addresses")
+	}
+
+	resolved := make(map[string]string)
+	for address, tokenAccounts := range accounts {
+		for _, account := range tokenAccounts {
+			resolved[account.Base58()] = address
+		}
+	}
+
+	e.mu.Lock()
+	e.tokenAccounts = resolved
+	e.resolvedAt = e.now()
+	e.mu.Unlock()
+
+	return nil
+}
+
+func (e *Exchange) status(ctx context.Context, txID []byte, data client.TransactionData) (DepositStatus, error) {
+	if data.TxState == client.TransactionStateFailed || data.Errors.TxError != nil {
+		return DepositStatusFailed, nil
+	}
+
+	if e.opts.finalityCommitment == e.opts.detectCommitment && data.TxState == client.TransactionStateSuccess {
+		return DepositStatusFinal, nil
+	}
+
+	final, err := e.client.GetTransaction(ctx, txID, client.WithCommitment(e.opts.finalityCommitment))
+	if err != nil {
+		return DepositStatusPending, err
+	}
+
+	switch final.TxState {
+	case client.TransactionStateSuccess:
+		if final.Errors.TxError != nil {
+			return DepositStatusFailed, nil
+		}
+		return DepositStatusFinal, nil
+	case client.TransactionStateFailed:
+		return DepositStatusFailed, nil
+	default:
+		return DepositStatusPending, nil
+	}
+}
@@ -0,0 +1,245 @@
+package exchange
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+type fakeClient struct {
+	client.Client
+
+	// responses contains the sequence of responses to return for each tx id.
+	responses map[string][]client.TransactionData
+	payments  []client.Payment
+
+	mu sync.Mutex
+	// tokenAccounts contains the token accounts of each owner.
+	tokenAccounts map[string][]kin.PublicKey
+	resolves      int
+}
+
+func (f *fakeClient) ResolveTokenAccounts(_ context.Context, account kin.PublicKey) ([]kin.PublicKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.resolves++
+	return f.tokenAccounts[account.Base58()], nil
+}
+
+func (f *fakeClient) ResolveTokenAccountsBatch(ctx context.Context, accounts []kin.PublicKey) (map[string][]kin.PublicKey, error) {
+	resolved := make(map[string][]kin.PublicKey, len(accounts))
+	for _, account := range accounts {
+		tokenAccounts, err := f.ResolveTokenAccounts(ctx, account)
+		if err != nil {
+			return nil, err
+		}
+		resolved[account.Base58()] = tokenAccounts
+	}
+	return resolved, nil
+}
+
+func (f *fakeClient) GetTransaction(_ context.Context, txID []byte, _ ...client.SolanaOption) (client.TransactionData, error) {
+	resps := f.responses[string(txID)]
+	if len(resps) == 0 {
+		return client.TransactionData{TxID: txID}, nil
+	}
+
+	f.responses[string(txID)] = resps[1:]
+	return resps[0], nil
+}
+
+func (f *fakeClient) SubmitPayment(_ context.Context, p client.Payment, _ ...client.SolanaOption) ([]byte, error) {
+	f.payments = append(f.payments, p)
+	return []byte("sig"), nil
+}
+
+func generateKey(t *testing.T) kin.PublicKey {
+	k, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	return k.Public()
+}
+
+func TestExchange_Deposits(t *testing.T) {
+	userAddr := generateKey(t)
+	sharedAddr := generateKey(t)
+	other := generateKey(t)
+	sender := generateKey(t)
+
+	data := client.TransactionData{
+		TxID:    []byte("tx1"),
+		TxState: client.TransactionStateSuccess,
+		Payments: []client.ReadOnlyPayment{
+			{Sender: sender, Destination: userAddr, Quarks: 10},
+			{Sender: sender, Destination: sharedAddr, Quarks: 20, Memo: "user-2"},
+			{Sender: sender, Destination: sharedAddr, Quarks: 30},
+			{Sender: sender, Destination: other, Quarks: 40},
+		},
+	}
+	pending := data
+	pending.TxState = client.TransactionStatePending
+
+	fc := &fakeClient{
+		responses: map[string][]client.TransactionData{
+			"tx1": {data, pending, data, data},
+		},
+	}
+	e := New(fc, WithDepositAddress(userAddr, "user-1"), WithMemoDepositAddress(sharedAddr))
+
+	deposits, err := e.CheckDeposits(context.Background(), []byte("tx1"))
+	require.NoError(t, err)
+	require.Len(t, deposits, 2)
+	assert.Equal(t, "user-1", deposits[0].Reference)
+	assert.EqualValues(t, 10, deposits[0].Quarks)
+	assert.Equal(t, 0, deposits[0].PaymentIndex)
+	assert.Equal(t, "user-2", deposits[1].Reference)
+	assert.Equal(t, 1, deposits[1].PaymentIndex)
+	for _, d := range deposits {
+		assert.Equal(t, DepositStatusPending, d.Status)
+	}
+	assert.Equal(t, 1, e.Pending())
+
+	resolved, err := e.Poll(context.Background())
+	require.NoError(t, err)
+	require.Len(t, resolved, 2)
+	for _, d := range resolved {
+		assert.Equal(t, DepositStatusFinal, d.Status)
+	}
+	assert.Equal(t, 0, e.Pending())
+}
+
+func TestExchange_OwnerDeposits(t *testing.T) {
+	owner := generateKey(t)
+	sharedOwner := generateKey(t)
+	tokenAccount := generateKey(t)
+	sharedTokenAccount := generateKey(t)
+	sender := generateKey(t)
+
+	deposit := func(id string, dest kin.PublicKey, memo string) client.TransactionData {
+		return client.TransactionData{
+			TxID:    []byte(id),
+			TxState: client.TransactionStateSuccess,
+			Payments: []client.ReadOnlyPayment{
+				{Sender: sender, Destination: dest, Quarks: 10, Memo: memo},
+			},
+		}
+	}
+
+	fc := &fakeClient{
+		responses: map[string][]client.TransactionData{
+			"tx1": {deposit("tx1", tokenAccount, "")},
+			"tx2": {deposit("tx2", sharedTokenAccount, "user-2")},
+			"tx3": {deposit("tx3", generateKey(t), "")},
+		},
+		tokenAccounts: map[string][]kin.PublicKey{
+			owner.Base58(): {tokenAccount},
+		},
+	}
+	e := New(
+		fc,
+		WithDepositAddress(owner, "user-1"),
+		WithMemoDepositAddress(sharedOwner),
+		WithFinalityCommitment(commonpbv4.Commitment_RECENT),
+	)
+
+	// Payments to the token account of a registered owner are deposits.
+	deposits, err := e.CheckDeposits(context.Background(), []byte("tx1"))
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	assert.Equal(t, "user-1", deposits[0].Reference)
+	assert.Equal(t, tokenAccount, deposits[0].Destination)
+	assert.Equal(t, DepositStatusFinal, deposits[0].Status)
+
+	assert.Equal(t, 2, fc.resolves)
+
+	// Token accounts created after they were last resolved aren't found
+	// until the resolve interval has passed.
+	fc.tokenAccounts[sharedOwner.Base58()] = []kin.PublicKey{sharedTokenAccount}
+	fc.responses["tx2"] = append(fc.responses["tx2"], deposit("tx2", sharedTokenAccount, "user-2"))
+	deposits, err = e.CheckDeposits(context.Background(), []byte("tx2"))
+	require.NoError(t, err)
+	assert.Empty(t, deposits)
+	assert.Equal(t, 2, fc.resolves)
+
+	now := time.Now().Add(10 * time.Minute)
+	e.now = func() time.Time { return now }
+	deposits, err = e.CheckDeposits(context.Background(), []byte("tx2"))
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	assert.Equal(t, "user-2", deposits[0].Reference)
+	assert.Equal(t, 4, fc.resolves)
+
+	// Payments to unknown accounts don't cause the addresses to be re-resolved.
+	fc.responses["tx4"] = []client.TransactionData{deposit("tx4", generateKey(t), "")}
+	for _, id := range []string{"tx3", "tx4"} {
+		deposits, err = e.CheckDeposits(context.Background(), []byte(id))
+		require.NoError(t, err)
+		assert.Empty(t, deposits)
+	}
+	assert.Equal(t, 4, fc.resolves)
+}
+
+func TestExchange_FailedDeposit(t *testing.T) {
+	userAddr := generateKey(t)
+
+	fc := &fakeClient{
+		responses: map[string][]client.TransactionData{
+			"tx1": {
+				{
+					TxID:    []byte("tx1"),
+					TxState: client.TransactionStateFailed,
+					Errors: client.TransactionErrors{
+						TxError: client.ErrInsufficientBalance,
+					},
+					Payments: []client.ReadOnlyPayment{
+						{Sender: generateKey(t), Destination: userAddr, Quarks: 10},
+					},
+				},
+			},
+		},
+	}
+	e := New(fc, WithDepositAddress(userAddr, "user-1"))
+
+	deposits, err := e.CheckDeposits(context.Background(), []byte("tx1"))
+	require.NoError(t, err)
+	require.Len(t, deposits, 1)
+	assert.Equal(t, DepositStatusFailed, deposits[0].Status)
+	assert.Equal(t, 0, e.Pending())
+}
+
+func TestExchange_Withdraw(t *testing.T) {
+	fc := &fakeClient{}
+	e := New(fc)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	_, err = e.Withdraw(context.Background(), Withdrawal{Sender: sender})
+	assert.Error(t, err)
+
+	w := Withdrawal{
+		ID:          "withdrawal-1",
+		Sender:      sender,
+		Destination: generateKey(t),
+		Quarks:      10,
+	}
+	for i := 0; i < 2; i++ {
+		txID, err := e.Withdraw(context.Background(), w)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("sig"), txID)
+	}
+
+	require.Len(t, fc.payments, 2)
+	assert.Equal(t, WithdrawalDedupeID("withdrawal-1"), fc.payments[0].DedupeID)
+	assert.Equal(t, fc.payments[0].DedupeID, fc.payments[1].DedupeID)
+	assert.NotEqual(t, WithdrawalDedupeID("withdrawal-2"), fc.payments[0].DedupeID)
+}
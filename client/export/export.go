@@ -0,0 +1,313 @@
+// Package export exports the transaction history of an account in formats
+// suitable for importing into spreadsheets and BI tools.
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// Format is the output format of an export.
+type Format int
+
+const (
+	// FormatCSV writes a header row, followed by a row per payment.
+	FormatCSV Format = iota
+
+	// FormatJSONL writes a JSON object per payment, one per line.
+	FormatJSONL
+)
+
+// Column is a column of an export.
+type Column string
+
+const (
+	// ColumnTxID is the base58 encoded ID of the transaction.
+	ColumnTxID Column = "tx_id"
+
+	// ColumnTime is the time of the transaction, in RFC 3339 format. It is
+	// empty if the time is unknown.
+	ColumnTime Column = "time"
+
+	// ColumnPaymentIndex is the index of the payment within the transaction.
+	ColumnPaymentIndex Column = "payment_index"
+
+	// ColumnType is the transaction type of the payment, such as "earn".
+	ColumnType Column = "type"
+
+	// ColumnAmount is the amount of the payment, in quarks. Payments sent
+	// from the exported account have a negative amount.
+	ColumnAmount Column = "amount"
+
+	// ColumnCounterparty is the base58 encoded account on the other side of
+	// the payment.
+	ColumnCounterparty Column = "counterparty"
+
+	// ColumnMemo is the text memo of the payment, if any.
+	ColumnMemo Column = "memo"
+
+	// ColumnAppIndex is the app index of the payment, if any.
+	ColumnAppIndex Column = "app_index"
+
+	// ColumnInvoiceTitles are the titles of the line items of the payment's
+	// invoice, if any. In CSV exports, they are separated by "; ".
+	ColumnInvoiceTitles Column = "invoice_titles"
+)
+
+// DefaultColumns are the columns exported if none are specified.
+var DefaultColumns = []Column{
+	ColumnTxID,
+	ColumnTime,
+	ColumnPaymentIndex,
+	ColumnType,
+	ColumnAmount,
+	ColumnCounterparty,
+	ColumnMemo,
+	ColumnAppIndex,
+	ColumnInvoiceTitles,
+}
+
+type opts struct {
+	format     Format
+	columns    []Column
+	solanaOpts []client.SolanaOption
+}
+
+// Option configures an Exporter.
+type Option func(*opts)
+
+// WithFormat specifies the output format. Defaults to FormatCSV.
+func WithFormat(format Format) Option {
+	return func(o *opts) {
+		o.format = format
+	}
+}
+
+// WithColumns specifies the columns to export, in order. Defaults to
+// DefaultColumns.
+func WithColumns(columns ...Column) Option {
+	return func(o *opts) {
+		o.columns = columns
+	}
+}
+
+// WithSolanaOptions specifies options used when fetching the history.
+func WithSolanaOptions(solanaOpts ...client.SolanaOption) Option {
+	return func(o *opts) {
+		o.solanaOpts = solanaOpts
+	}
+}
+
+// Exporter exports the transaction history of accounts.
+type Exporter struct {
+	client client.Client
+	opts   opts
+}
+
+// New returns a new Exporter.
+func New(c client.Client, options ...Option) (*Exporter, error) {
+	e := &Exporter{
+		client: c,
+		opts: opts{
+			format:  FormatCSV,
+			columns: DefaultColumns,
+		},
+	}
+	for _, o := range options {
+		o(&e.opts)
+	}
+
+	switch e.opts.format {
+	case FormatCSV, FormatJSONL:
+	default:
+		return nil, errors.Errorf("unsupported format: %d", e.opts.format)
+	}
+
+	if len(e.opts.columns) == 0 {
+		return nil, errors.New("at least one column must be specified")
+	}
+	for _, c := range e.opts.columns {
+		if !validColumn(c) {
+			return nil, errors.Errorf("unknown column: %s", c)
+		}
+	}
+
+	return e, nil
+}
+
+// Export writes a record for each payment of the successful transactions in the
+// history of account, oldest first, returning the number of records written.
+//
+// The history is streamed page by page, so exports of large histories do not
+// need to be held in memory.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, account kin.PublicKey) (n int, err error) {
+	var write func(values []interface{}) error
+	var flush func() error
+
+	switch e.opts.format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		write = func(values []interface{}) error {
+			return cw.Write(csvRow(values))
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+
+		header := make([]string, len(e.opts.columns))
+		for i, c := range e.opts.columns {
+			header[i] = string(c)
+		}
+		if err := cw.Write(header); err != nil {
+			return 0, errors.Wrap(err, "failed to write header")
+		}
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		write = func(values []interface{}) error {
+			record := make(map[string]interface{}, len(values))
+			for i, c := range e.opts.columns {
+				record[string(c)] = values[i]
+			}
+			return enc.Encode(record)
+		}
+		flush = func() error { return nil }
+	}
+
+	var cursor client.Cursor
+	for {
+		items, err := e.client.GetHistory(ctx, account, cursor, e.opts.solanaOpts...)
+		if err != nil {
+			return n, errors.Wrap(err, "failed to get history")
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, item := range items {
+			if item.TxState != client.TransactionStateSuccess {
+				continue
+			}
+
+			for i, p := range item.Payments {
+				if err := write(e.values(account, item, i, p)); err != nil {
+					return n, errors.Wrap(err, "failed to write record")
+				}
+				n++
+			}
+		}
+
+		cursor = items[len(items)-1].Cursor
+	}
+
+	if err := flush(); err != nil {
+		return n, errors.Wrap(err, "failed to flush records")
+	}
+
+	return n, nil
+}
+
+// values returns the value of each exported column for a payment. Values are
+// either a string, an int64, a []string, or nil if not applicable.
+func (e *Exporter) values(account kin.PublicKey, item client.HistoryItem, index int, p client.ReadOnlyPayment) []interface{} {
+	outgoing := bytes.Equal(p.Sender, account)
+
+	values := make([]interface{}, len(e.opts.columns))
+	for i, c := range e.opts.columns {
+		switch c {
+		case ColumnTxID:
+			values[i] = base58.Encode(item.TxID)
+		case ColumnTime:
+			if !item.Time.IsZero() {
+				values[i] = item.Time.UTC().Format(time.RFC3339)
+			}
+		case ColumnPaymentIndex:
+			values[i] = int64(index)
+		case ColumnType:
+			values[i] = transactionType(p.Type)
+		case ColumnAmount:
+			amount := p.Quarks
+			if outgoing {
+				amount = -amount
+			}
+			values[i] = amount
+		case ColumnCounterparty:
+			if outgoing {
+				values[i] = p.Destination.Base58()
+			} else {
+				values[i] = p.Sender.Base58()
+			}
+		case ColumnMemo:
+			values[i] = p.Memo
+		case ColumnAppIndex:
+			if p.AppIndex > 0 {
+				values[i] = int64(p.AppIndex)
+			}
+		case ColumnInvoiceTitles:
+			values[i] = invoiceTitles(p)
+		}
+	}
+
+	return values
+}
+
+func csvRow(values []interface{}) []string {
+	row := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			row[i] = v
+		case int64:
+			row[i] = strconv.FormatInt(v, 10)
+		case []string:
+			row[i] = strings.Join(v, "; ")
+		}
+	}
+	return row
+}
+
+func invoiceTitles(p client.ReadOnlyPayment) []string {
+	if p.Invoice == nil {
+		return []string{}
+	}
+
+	titles := make([]string, len(p.Invoice.Items))
+	for i, item := range p.Invoice.Items {
+		titles[i] = item.Title
+	}
+	return titles
+}
+
+func transactionType(t kin.TransactionType) string {
+	switch t {
+	case kin.TransactionTypeEarn:
+		return "earn"
+	case kin.TransactionTypeSpend:
+		return "spend"
+	case kin.TransactionTypeP2P:
+		return "p2p"
+	default:
+		return ""
+	}
+}
+
+func validColumn(c Column) bool {
+	for _, d := range DefaultColumns {
+		if c == d {
+			return true
+		}
+	}
+	return false
+}
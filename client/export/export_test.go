@@ -0,0 +1,159 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+type fakeClient struct {
+	client.Client
+
+	// history is ordered oldest first.
+	history  []client.HistoryItem
+	pageSize int
+	calls    int
+}
+
+func (f *fakeClient) GetHistory(_ context.Context, _ kin.PublicKey, cursor client.Cursor, _ ...client.SolanaOption) ([]client.HistoryItem, error) {
+	f.calls++
+
+	items := f.history
+	if cursor != nil {
+		for i, item := range items {
+			if string(item.Cursor) == string(cursor) {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+	if len(items) > f.pageSize {
+		items = items[:f.pageSize]
+	}
+	return items, nil
+}
+
+func generateKey(t *testing.T) kin.PublicKey {
+	k, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	return k.Public()
+}
+
+func TestExport(t *testing.T) {
+	account := generateKey(t)
+	other := generateKey(t)
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	fc := &fakeClient{
+		pageSize: 1,
+		history: []client.HistoryItem{
+			{
+				TransactionData: client.TransactionData{
+					TxID:    []byte("tx1"),
+					TxState: client.TransactionStateSuccess,
+					Payments: []client.ReadOnlyPayment{
+						{
+							Sender:      other,
+							Destination: account,
+							Type:        kin.TransactionTypeEarn,
+							Quarks:      10,
+							AppIndex:    1,
+							Invoice: &commonpb.Invoice{
+								Items: []*commonpb.Invoice_LineItem{
+									{Title: "a"},
+									{Title: "b"},
+								},
+							},
+						},
+						{
+							Sender:      account,
+							Destination: other,
+							Type:        kin.TransactionTypeSpend,
+							Quarks:      5,
+							Memo:        "hello, world",
+						},
+					},
+				},
+				Cursor: []byte("tx1"),
+				Time:   ts,
+			},
+			{
+				TransactionData: client.TransactionData{
+					TxID:     []byte("tx2"),
+					TxState:  client.TransactionStateFailed,
+					Payments: []client.ReadOnlyPayment{{Sender: account, Destination: other, Quarks: 1}},
+				},
+				Cursor: []byte("tx2"),
+			},
+		},
+	}
+
+	e, err := New(fc)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := e.Export(context.Background(), &buf, account)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 3, fc.calls)
+
+	txID := base58.Encode([]byte("tx1"))
+	expected := strings.Join([]string{
+		"tx_id,time,payment_index,type,amount,counterparty,memo,app_index,invoice_titles",
+		txID + ",2021-03-04T05:06:07Z,0,earn,10," + other.Base58() + ",,1,a; b",
+		txID + ",2021-03-04T05:06:07Z,1,spend,-5," + other.Base58() + ",\"hello, world\",,",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, buf.String())
+
+	e, err = New(fc, WithFormat(FormatJSONL), WithColumns(ColumnAmount, ColumnAppIndex, ColumnInvoiceTitles))
+	require.NoError(t, err)
+
+	buf.Reset()
+	n, err = e.Export(context.Background(), &buf, account)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var records []map[string]interface{}
+	for _, l := range lines {
+		var r map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(l), &r))
+		records = append(records, r)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"amount":         float64(10),
+		"app_index":      float64(1),
+		"invoice_titles": []interface{}{"a", "b"},
+	}, records[0])
+	assert.Equal(t, map[string]interface{}{
+		"amount":         float64(-5),
+		"app_index":      nil,
+		"invoice_titles": []interface{}{},
+	}, records[1])
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithFormat(Format(10))},
+		{WithColumns()},
+		{WithColumns(ColumnAmount, Column("balance"))},
+	} {
+		_, err := New(&fakeClient{}, opts...)
+		assert.Error(t, err)
+	}
+}
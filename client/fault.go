@@ -0,0 +1,139 @@
+package client
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+// FaultInjectionConfig configures the faults injected by a client created with
+// WithFaultInjection. Probabilities are in the range [0, 1], and each is
+// applied independently.
+type FaultInjectionConfig struct {
+	// LatencyProbability is the probability that a request to Agora is
+	// delayed by Latency.
+	LatencyProbability float64
+	Latency            time.Duration
+
+	// DropProbability is the probability that the response to a request to
+	// Agora is dropped, after the request has been processed. Dropped
+	// responses result in a codes.Unavailable error, which is retried.
+	DropProbability float64
+
+	// BadNonceProbability is the probability that a transaction submission
+	// fails with a BAD_NONCE error, without the transaction being submitted.
+	BadNonceProbability float64
+
+	// AlreadySubmittedProbability is the probability that a transaction is
+	// submitted, but is reported as ALREADY_SUBMITTED.
+	AlreadySubmittedProbability float64
+
+	// Seed seeds the source of randomness, allowing faults to be reproduced.
+	// If zero, a time based seed is used.
+	Seed int64
+}
+
+// WithFaultInjection enables the injection of faults into requests to Agora,
+// so that applications can verify their retry and dedupe handling.
+//
+// It cannot be used with EnvironmentProd.
+func WithFaultInjection(config FaultInjectionConfig) ClientOption {
+	return func(o *clientOpts) {
+		o.faultInjection = &config
+	}
+}
+
+// faultInjector injects the faults specified by a FaultInjectionConfig. A nil
+// faultInjector injects no faults.
+type faultInjector struct {
+	config FaultInjectionConfig
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newFaultInjector(config FaultInjectionConfig) (*faultInjector, error) {
+	probabilities := map[string]float64{
+		"latency":           config.LatencyProbability,
+		"drop":              config.DropProbability,
+		"bad nonce":         config.BadNonceProbability,
+		"already submitted": config.AlreadySubmittedProbability,
+	}
+	for name, p := range probabilities {
+		if p < 0 || p > 1 {
+			return nil, errors.Errorf("invalid %s probability: %v", name, p)
+		}
+	}
+	if config.Latency < 0 {
+		return nil, errors.Errorf("invalid latency: %v", config.Latency)
+	}
+
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &faultInjector{
+		config: config,
+		rand:   rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+// roll returns true with probability p.
+func (f *faultInjector) roll(p float64) bool {
+	if p == 0 {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rand.Float64() < p
+}
+
+// wrap returns an action that injects latency before, and drops the response
+// of, a request to Agora made by action.
+func (f *faultInjector) wrap(action func() error) func() error {
+	if f == nil {
+		return action
+	}
+
+	return func() error {
+		if f.roll(f.config.LatencyProbability) {
+			time.Sleep(f.config.Latency)
+		}
+
+		err := action()
+		if err == nil && f.roll(f.config.DropProbability) {
+			return status.Error(codes.Unavailable, "injected fault: response dropped")
+		}
+		return err
+	}
+}
+
+// submitResponse returns a response to use in place of submitting a
+// transaction, if a BAD_NONCE fault is injected.
+func (f *faultInjector) submitResponse() (*transactionpbv4.SubmitTransactionResponse, bool) {
+	if f == nil || !f.roll(f.config.BadNonceProbability) {
+		return nil, false
+	}
+
+	return &transactionpbv4.SubmitTransactionResponse{
+		Result: transactionpbv4.SubmitTransactionResponse_FAILED,
+		TransactionError: &commonpbv4.TransactionError{
+			Reason: commonpbv4.TransactionError_BAD_NONCE,
+		},
+	}, true
+}
+
+// alreadySubmitted returns whether the response to a successful submission
+// should be reported as ALREADY_SUBMITTED.
+func (f *faultInjector) alreadySubmitted() bool {
+	return f != nil && f.roll(f.config.AlreadySubmittedProbability)
+}
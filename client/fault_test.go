@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFaultInjection_Validation(t *testing.T) {
+	_, err := New(EnvironmentProd, WithFaultInjection(FaultInjectionConfig{DropProbability: 0.1}))
+	assert.Error(t, err)
+
+	_, err = New(EnvironmentTest, WithFaultInjection(FaultInjectionConfig{DropProbability: 1.1}))
+	assert.Error(t, err)
+
+	_, err = New(EnvironmentTest, WithFaultInjection(FaultInjectionConfig{LatencyProbability: -1}))
+	assert.Error(t, err)
+}
+
+func TestFaultInjection_Submission(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		config  FaultInjectionConfig
+		submits int
+		check   func(t *testing.T, err error)
+	}{
+		{
+			name:    "bad nonce",
+			config:  FaultInjectionConfig{BadNonceProbability: 1},
+			submits: 0,
+			check: func(t *testing.T, err error) {
+				assert.Equal(t, ErrBadNonce, errors.Cause(err))
+			},
+		},
+		{
+			name:    "already submitted",
+			config:  FaultInjectionConfig{AlreadySubmittedProbability: 1},
+			submits: 1,
+			check: func(t *testing.T, err error) {
+				assert.Equal(t, ErrAlreadySubmitted, errors.Cause(err))
+
+				var submitted *AlreadySubmittedError
+				require.True(t, errors.As(err, &submitted))
+				assert.NotEmpty(t, submitted.TxID)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env, cleanup := setup(t, WithMaxNonceRetries(3), WithFaultInjection(tc.config))
+			defer cleanup()
+
+			sender, err := kin.NewPrivateKey()
+			require.NoError(t, err)
+			dest, err := kin.NewPrivateKey()
+			require.NoError(t, err)
+
+			setServiceConfigResp(t, env.v4Server, true)
+			for _, acc := range []kin.PrivateKey{sender, dest} {
+				require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+			}
+
+			_, err = env.client.SubmitPayment(context.Background(), Payment{
+				Sender:      sender,
+				Destination: dest.Public(),
+				Type:        kin.TransactionTypeSpend,
+				Quarks:      11,
+			})
+			tc.check(t, err)
+
+			env.v4Server.Mux.Lock()
+			assert.Len(t, env.v4Server.Submits, tc.submits)
+			env.v4Server.Mux.Unlock()
+		})
+	}
+}
+
+func TestFaultInjection_Requests(t *testing.T) {
+	env, cleanup := setup(t, WithFaultInjection(FaultInjectionConfig{DropProbability: 1}))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	_, err := env.client.GetMinimumBalanceForRentExemption(context.Background(), 165)
+	assert.Equal(t, codes.Unavailable, status.Code(errors.Cause(err)))
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.RentExemptionReqs, 3)
+	env.v4Server.Mux.Unlock()
+
+	latency := 20 * time.Millisecond
+	env, cleanup = setup(t, WithFaultInjection(FaultInjectionConfig{LatencyProbability: 1, Latency: latency}))
+	defer cleanup()
+
+	start := time.Now()
+	_, err = env.client.GetMinimumBalanceForRentExemption(context.Background(), 165)
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) >= latency)
+}
+
+func TestFaultInjector_Seed(t *testing.T) {
+	config := FaultInjectionConfig{DropProbability: 0.5, Seed: 42}
+
+	var rolls [2][]bool
+	for i := range rolls {
+		f, err := newFaultInjector(config)
+		require.NoError(t, err)
+
+		for j := 0; j < 20; j++ {
+			rolls[i] = append(rolls[i], f.roll(config.DropProbability))
+		}
+	}
+
+	assert.Equal(t, rolls[0], rolls[1])
+	assert.Contains(t, rolls[0], true)
+	assert.Contains(t, rolls[0], false)
+}
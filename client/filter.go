@@ -0,0 +1,89 @@
+package client
+
+import (
+	"github.com/kinecosystem/agora-common/kin"
+)
+
+type filterOpts struct {
+	appIndex *uint16
+	types    map[kin.TransactionType]struct{}
+}
+
+// FilterOption configures which payments are retained by FilterPayments.
+type FilterOption func(*filterOpts)
+
+// WithAppIndexFilter retains only payments with the specified app index.
+//
+// Payments using text memos, or no memo at all, have no app index and are
+// therefore removed.
+func WithAppIndexFilter(appIndex uint16) FilterOption {
+	return func(o *filterOpts) {
+		o.appIndex = &appIndex
+	}
+}
+
+// WithTransactionTypeFilter retains only payments with one of the specified transaction types.
+func WithTransactionTypeFilter(types ...kin.TransactionType) FilterOption {
+	return func(o *filterOpts) {
+		if o.types == nil {
+			o.types = make(map[kin.TransactionType]struct{})
+		}
+		for _, t := range types {
+			o.types[t] = struct{}{}
+		}
+	}
+}
+
+// WithPaymentFilters specifies filters that are applied to the transactions
+// returned by GetHistory and Subscribe, as with FilterPayments. Transactions
+// without any matching payments are omitted.
+func WithPaymentFilters(filters ...FilterOption) SolanaOption {
+	return func(o *solanaOpts) {
+		o.filters = append(o.filters, filters...)
+	}
+}
+
+// FilterPayments returns a copy of data containing only the payments that match
+// all of the specified filters, and whether or not any payments matched.
+//
+// If no filters are specified, data is returned as is.
+func FilterPayments(data TransactionData, opts ...FilterOption) (TransactionData, bool) {
+	if len(opts) == 0 {
+		return data, true
+	}
+
+	var o filterOpts
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	filtered := data
+	filtered.Payments = nil
+	for _, p := range data.Payments {
+		if o.appIndex != nil && p.AppIndex != *o.appIndex {
+			continue
+		}
+		if o.types != nil {
+			if _, ok := o.types[p.Type]; !ok {
+				continue
+			}
+		}
+
+		filtered.Payments = append(filtered.Payments, p)
+	}
+
+	return filtered, len(filtered.Payments) > 0
+}
+
+// filterHistory returns the items with payments matching filters, containing
+// only the matching payments.
+func filterHistory(items []HistoryItem, filters []FilterOption) []HistoryItem {
+	var filtered []HistoryItem
+	for _, item := range items {
+		if data, ok := FilterPayments(item.TransactionData, filters...); ok {
+			item.TransactionData = data
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+func TestFilterPayments(t *testing.T) {
+	data := TransactionData{
+		TxID: []byte("tx"),
+		Payments: []ReadOnlyPayment{
+			{Type: kin.TransactionTypeEarn, AppIndex: 1, Quarks: 1},
+			{Type: kin.TransactionTypeSpend, AppIndex: 1, Quarks: 2},
+			{Type: kin.TransactionTypeEarn, AppIndex: 2, Quarks: 3},
+			{Type: kin.TransactionTypeUnknown, Memo: "1-test", Quarks: 4},
+		},
+	}
+
+	filtered, ok := FilterPayments(data)
+	assert.True(t, ok)
+	assert.Equal(t, data, filtered)
+
+	filtered, ok = FilterPayments(data, WithAppIndexFilter(1))
+	assert.True(t, ok)
+	assert.Equal(t, data.TxID, filtered.TxID)
+	assert.Equal(t, data.Payments[:2], filtered.Payments)
+
+	filtered, ok = FilterPayments(data, WithAppIndexFilter(1), WithTransactionTypeFilter(kin.TransactionTypeEarn))
+	assert.True(t, ok)
+	assert.Equal(t, data.Payments[:1], filtered.Payments)
+
+	filtered, ok = FilterPayments(data, WithTransactionTypeFilter(kin.TransactionTypeEarn, kin.TransactionTypeUnknown))
+	assert.True(t, ok)
+	assert.Equal(t, []ReadOnlyPayment{data.Payments[0], data.Payments[2], data.Payments[3]}, filtered.Payments)
+
+	_, ok = FilterPayments(data, WithAppIndexFilter(3))
+	assert.False(t, ok)
+
+	// The original data is not modified.
+	assert.Len(t, data.Payments, 4)
+}
+
+func TestEventEnricher_Filters(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	textTx, _ := generateEventTransaction(t, false)
+	invoiceTx, il := generateEventTransaction(t, true)
+	evts := []events.Event{
+		{
+			TransactionEvent: &events.TransactionEvent{
+				KinVersion:  4,
+				TxID:        textTx.Signature(),
+				SolanaEvent: &events.SolanaEvent{Transaction: textTx.Marshal()},
+			},
+		},
+		{
+			TransactionEvent: &events.TransactionEvent{
+				KinVersion:  4,
+				TxID:        invoiceTx.Signature(),
+				InvoiceList: il,
+				SolanaEvent: &events.SolanaEvent{Transaction: invoiceTx.Marshal()},
+			},
+		},
+	}
+
+	data, err := NewEventEnricher(env.client).EnrichAll(context.Background(), evts)
+	require.NoError(t, err)
+	assert.Len(t, data, 2)
+
+	data, err = NewEventEnricher(env.client, WithAppIndexFilter(1)).EnrichAll(context.Background(), evts)
+	require.NoError(t, err)
+	require.Len(t, data, 1)
+	assert.Equal(t, invoiceTx.Signature(), data[0].TxID)
+	for _, p := range data[0].Payments {
+		assert.EqualValues(t, 1, p.AppIndex)
+	}
+
+	data, err = NewEventEnricher(env.client, WithTransactionTypeFilter(kin.TransactionTypeEarn)).EnrichAll(context.Background(), evts)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
+
+func TestClient_HistoryFilters(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	tokenKey, _, _ := setServiceConfigResp(t, env.v4Server, true)
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	require.NoError(t, env.client.CreateAccount(context.Background(), priv))
+	tokenAcc, err := token.GetAssociatedAccount(ed25519.PublicKey(priv.Public()), tokenKey)
+	require.NoError(t, err)
+	account := kin.PublicKey(tokenAcc)
+
+	// Only the transactions with invoices have payments with an app index.
+	var expected [][]byte
+	var history []*transactionpbv4.HistoryItem
+	for i, useInvoice := range []bool{false, false, true, false, true, false} {
+		_, data, resp := generateV4SolanaPayments(t, useInvoice)
+		resp.Item.Cursor = &transactionpbv4.Cursor{Value: []byte{byte(i)}}
+		history = append(history, resp.Item)
+		if useInvoice {
+			expected = append(expected, data.TxID)
+		}
+	}
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.History[string(account)] = history
+	env.v4Server.HistoryPageSize = 1
+	env.v4Server.Mux.Unlock()
+
+	// Pages without matching transactions are skipped, rather than ending the iteration.
+	var txIDs [][]byte
+	var cursor Cursor
+	for {
+		page, err := env.client.GetHistory(context.Background(), account, cursor, WithPaymentFilters(WithAppIndexFilter(1)))
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+
+		for _, item := range page {
+			txIDs = append(txIDs, item.TxID)
+			for _, p := range item.Payments {
+				assert.EqualValues(t, 1, p.AppIndex)
+			}
+		}
+		cursor = page[len(page)-1].Cursor
+	}
+	assert.Equal(t, expected, txIDs)
+
+	page, err := env.client.GetHistory(context.Background(), account, nil, WithPaymentFilters(WithTransactionTypeFilter(kin.TransactionTypeEarn)))
+	require.NoError(t, err)
+	assert.Empty(t, page)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results, err := env.client.Subscribe(ctx, account, nil, WithPaymentFilters(WithAppIndexFilter(1)))
+	require.NoError(t, err)
+
+	txIDs = nil
+	for r := range results {
+		if r.Err != nil {
+			break
+		}
+		txIDs = append(txIDs, r.Item.TxID)
+	}
+	assert.Equal(t, expected, txIDs)
+}
+
+func TestEventHub_Filters(t *testing.T) {
+	source := newTestEventSource()
+	hub := NewEventHub(source, WithReconnectDelay(time.Millisecond), WithEventFilters(WithAppIndexFilter(1)))
+	defer hub.Close()
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	account := priv.Public()
+
+	r := &eventRecorder{}
+	unsubscribe, err := hub.Subscribe(account, r.handle)
+	require.NoError(t, err)
+	defer unsubscribe()
+
+	transactionEvent := func(useInvoice bool) *accountpbv4.Event {
+		_, _, resp := generateV4SolanaPayments(t, useInvoice)
+		return &accountpbv4.Event{
+			Type: &accountpbv4.Event_TransactionEvent{
+				TransactionEvent: &accountpbv4.TransactionEvent{
+					Transaction: resp.Item.GetSolanaTransaction(),
+				},
+			},
+		}
+	}
+	accountEvent := &accountpbv4.Event{
+		Type: &accountpbv4.Event_AccountUpdateEvent{
+			AccountUpdateEvent: &accountpbv4.AccountUpdateEvent{},
+		},
+	}
+
+	// Results without any matching events are not dispatched.
+	source.send(t, account, EventsResult{Events: []*accountpbv4.Event{transactionEvent(false)}})
+
+	matching := transactionEvent(true)
+	source.send(t, account, EventsResult{Events: []*accountpbv4.Event{transactionEvent(false), accountEvent, matching}})
+
+	require.Eventually(t, func() bool { return r.count() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []*accountpbv4.Event{accountEvent, matching}, r.results[0].Events)
+}
@@ -0,0 +1,120 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultCoinGeckoURL = "https://api.coingecko.com/api/v3"
+	coinGeckoKinID      = "kin"
+)
+
+type coinGeckoOpts struct {
+	baseURL     string
+	httpClient  *http.Client
+	ttl         time.Duration
+	minInterval time.Duration
+}
+
+// CoinGeckoOption configures a CoinGecko PriceProvider.
+type CoinGeckoOption func(*coinGeckoOpts)
+
+// WithCoinGeckoURL specifies the base URL of the CoinGecko API.
+func WithCoinGeckoURL(baseURL string) CoinGeckoOption {
+	return func(o *coinGeckoOpts) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithHTTPClient specifies the http.Client used to query CoinGecko.
+func WithHTTPClient(client *http.Client) CoinGeckoOption {
+	return func(o *coinGeckoOpts) {
+		o.httpClient = client
+	}
+}
+
+// WithCacheTTL specifies how long prices are cached for. Defaults to 1 minute.
+func WithCacheTTL(ttl time.Duration) CoinGeckoOption {
+	return func(o *coinGeckoOpts) {
+		o.ttl = ttl
+	}
+}
+
+// WithMinInterval specifies the minimum interval between requests to CoinGecko
+// for the same currency. Defaults to 10 seconds.
+func WithMinInterval(minInterval time.Duration) CoinGeckoOption {
+	return func(o *coinGeckoOpts) {
+		o.minInterval = minInterval
+	}
+}
+
+type coinGecko struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewCoinGeckoProvider returns a cached and rate limited PriceProvider backed by
+// the public CoinGecko API.
+//
+// It is provided as a reference implementation. Production deployments may wish
+// to use a provider with an SLA.
+func NewCoinGeckoProvider(opts ...CoinGeckoOption) PriceProvider {
+	o := coinGeckoOpts{
+		baseURL:     defaultCoinGeckoURL,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		ttl:         time.Minute,
+		minInterval: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return NewCachedProvider(&coinGecko{
+		baseURL:    o.baseURL,
+		httpClient: o.httpClient,
+	}, o.ttl, o.minInterval)
+}
+
+// Price implements PriceProvider.Price.
+func (c *coinGecko) Price(ctx context.Context, currency string) (float64, error) {
+	query := url.Values{}
+	query.Set("ids", coinGeckoKinID)
+	query.Set("vs_currencies", currency)
+
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/simple/price?"+query.Encode(), nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to query coingecko")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return 0, ErrRateLimited
+	default:
+		return 0, errors.Errorf("unexpected status from coingecko: %d", resp.StatusCode)
+	}
+
+	var prices map[string]map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&prices); err != nil {
+		return 0, errors.Wrap(err, "failed to decode coingecko response")
+	}
+
+	price, ok := prices[coinGeckoKinID][currency]
+	if !ok {
+		return 0, errors.Wrap(ErrUnsupportedCurrency, currency)
+	}
+
+	return price, nil
+}
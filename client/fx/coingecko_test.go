@@ -0,0 +1,46 @@
+package fx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoinGeckoProvider(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/simple/price", r.URL.Path)
+		assert.Equal(t, "kin", r.URL.Query().Get("ids"))
+
+		switch r.URL.Query().Get("vs_currencies") {
+		case "usd":
+			_, _ = w.Write([]byte(`{"kin":{"usd":0.00005}}`))
+		case "jpy":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			_, _ = w.Write([]byte(`{"kin":{}}`))
+		}
+	}))
+	defer server.Close()
+
+	p := NewCoinGeckoProvider(WithCoinGeckoURL(server.URL), WithHTTPClient(server.Client()), WithMinInterval(0))
+
+	for i := 0; i < 2; i++ {
+		price, err := p.Price(context.Background(), "usd")
+		require.NoError(t, err)
+		assert.EqualValues(t, 0.00005, price)
+	}
+	assert.Equal(t, 1, requests)
+
+	_, err := p.Price(context.Background(), "xyz")
+	assert.Equal(t, ErrUnsupportedCurrency, errors.Cause(err))
+
+	_, err = p.Price(context.Background(), "jpy")
+	assert.Equal(t, ErrRateLimited, err)
+}
@@ -0,0 +1,198 @@
+// Package fx provides conversions between Kin (in quarks) and fiat currencies.
+package fx
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// QuarksPerKin is the number of quarks in a single Kin.
+const QuarksPerKin = 100000
+
+// CurrencyUSD is the currency code for US dollars.
+const CurrencyUSD = "usd"
+
+var (
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrRateLimited         = errors.New("price provider rate limited")
+	ErrInvalidPrice        = errors.New("invalid price")
+)
+
+// PriceProvider provides the price of a single Kin in fiat currencies.
+type PriceProvider interface {
+	// Price returns the price of 1 Kin in the specified currency.
+	//
+	// Currencies are lowercase ISO 4217 codes, such as "usd".
+	Price(ctx context.Context, currency string) (float64, error)
+}
+
+// KinToUSD converts the specified amount of quarks to US dollars.
+func KinToUSD(ctx context.Context, p PriceProvider, quarks int64) (float64, error) {
+	return ToFiat(ctx, p, CurrencyUSD, quarks)
+}
+
+// USDToKin converts the specified amount of US dollars to quarks, rounded to
+// the nearest quark.
+func USDToKin(ctx context.Context, p PriceProvider, usd float64) (int64, error) {
+	return FromFiat(ctx, p, CurrencyUSD, usd)
+}
+
+// ToFiat converts the specified amount of quarks to the specified currency.
+func ToFiat(ctx context.Context, p PriceProvider, currency string, quarks int64) (float64, error) {
+	price, err := price(ctx, p, currency)
+	if err != nil {
+		return 0, err
+	}
+
+	amount := new(big.Rat).SetFrac64(quarks, QuarksPerKin)
+	amount.Mul(amount, price)
+
+	f, _ := amount.Float64()
+	return f, nil
+}
+
+// FromFiat converts the specified amount of the specified currency to quarks, rounded
+// to the nearest quark.
+func FromFiat(ctx context.Context, p PriceProvider, currency string, amount float64) (int64, error) {
+	price, err := price(ctx, p, currency)
+	if err != nil {
+		return 0, err
+	}
+
+	fiat := new(big.Rat)
+	if fiat.SetFloat64(amount) == nil {
+		return 0, errors.Errorf("invalid amount: %v", amount)
+	}
+
+	quarks := new(big.Rat).Quo(fiat, price)
+	quarks.Mul(quarks, big.NewRat(QuarksPerKin, 1))
+
+	// Round half away from zero.
+	half := big.NewRat(1, 2)
+	if quarks.Sign() < 0 {
+		half.Neg(half)
+	}
+	quarks.Add(quarks, half)
+
+	q := new(big.Int).Quo(quarks.Num(), quarks.Denom())
+	if !q.IsInt64() {
+		return 0, errors.New("amount out of range")
+	}
+
+	return q.Int64(), nil
+}
+
+func price(ctx context.Context, p PriceProvider, currency string) (*big.Rat, error) {
+	f, err := p.Price(ctx, strings.ToLower(currency))
+	if err != nil {
+		return nil, err
+	}
+	if f <= 0 {
+		return nil, errors.Wrapf(ErrInvalidPrice, "%v", f)
+	}
+
+	r := new(big.Rat)
+	if r.SetFloat64(f) == nil {
+		return nil, errors.Wrapf(ErrInvalidPrice, "%v", f)
+	}
+
+	return r, nil
+}
+
+type cachedPrice struct {
+	price     float64
+	fetchedAt time.Time
+}
+
+type cachedProvider struct {
+	provider    PriceProvider
+	ttl         time.Duration
+	minInterval time.Duration
+
+	mu          sync.Mutex
+	prices      map[string]cachedPrice
+	lastFetched map[string]time.Time
+	fetches     map[string]*priceFetch
+}
+
+// priceFetch is a call to the underlying provider, shared by the callers
+// requesting the same currency while it is in progress.
+type priceFetch struct {
+	done  chan struct{}
+	price float64
+	err   error
+}
+
+// NewCachedProvider returns a PriceProvider that caches the prices returned by p
+// for the duration of ttl.
+//
+// Additionally, p is called at most once per minInterval for each currency. If a
+// price has expired but p cannot yet be called, the expired price is returned. If
+// there is no price available at all, ErrRateLimited is returned.
+//
+// Concurrent requests for a currency whose price must be fetched share a single
+// call to p. Requests for other currencies are not blocked by the call.
+func NewCachedProvider(p PriceProvider, ttl, minInterval time.Duration) PriceProvider {
+	return &cachedProvider{
+		provider:    p,
+		ttl:         ttl,
+		minInterval: minInterval,
+		prices:      make(map[string]cachedPrice),
+		lastFetched: make(map[string]time.Time),
+		fetches:     make(map[string]*priceFetch),
+	}
+}
+
+// Price implements PriceProvider.Price.
+func (c *cachedProvider) Price(ctx context.Context, currency string) (float64, error) {
+	c.mu.Lock()
+
+	now := time.Now()
+	cached, ok := c.prices[currency]
+	if ok && now.Sub(cached.fetchedAt) < c.ttl {
+		c.mu.Unlock()
+		return cached.price, nil
+	}
+
+	if f, fetching := c.fetches[currency]; fetching {
+		c.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-f.done:
+			return f.price, f.err
+		}
+	}
+
+	if last, fetched := c.lastFetched[currency]; fetched && now.Sub(last) < c.minInterval {
+		c.mu.Unlock()
+		if ok {
+			return cached.price, nil
+		}
+		return 0, ErrRateLimited
+	}
+
+	f := &priceFetch{done: make(chan struct{})}
+	c.fetches[currency] = f
+	c.lastFetched[currency] = now
+	c.mu.Unlock()
+
+	f.price, f.err = c.provider.Price(ctx, currency)
+
+	c.mu.Lock()
+	if f.err == nil {
+		c.prices[currency] = cachedPrice{price: f.price, fetchedAt: now}
+	} else {
+		f.price = 0
+	}
+	delete(c.fetches, currency)
+	c.mu.Unlock()
+	close(f.done)
+
+	return f.price, f.err
+}
@@ -0,0 +1,161 @@
+package fx
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticProvider struct {
+	prices map[string]float64
+	calls  int
+	err    error
+}
+
+func (s *staticProvider) Price(_ context.Context, currency string) (float64, error) {
+	s.calls++
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	p, ok := s.prices[currency]
+	if !ok {
+		return 0, ErrUnsupportedCurrency
+	}
+	return p, nil
+}
+
+func TestConversions(t *testing.T) {
+	p := &staticProvider{prices: map[string]float64{"usd": 0.00005, "cad": 0}}
+
+	usd, err := KinToUSD(context.Background(), p, 100*QuarksPerKin)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.005, usd, 1e-12)
+
+	quarks, err := USDToKin(context.Background(), p, 0.005)
+	require.NoError(t, err)
+	assert.EqualValues(t, 100*QuarksPerKin, quarks)
+
+	// 0.00000001 USD is 0.0002 Kin, which is 20 quarks.
+	quarks, err = FromFiat(context.Background(), p, "USD", 0.00000001)
+	require.NoError(t, err)
+	assert.EqualValues(t, 20, quarks)
+
+	// Amounts are rounded to the nearest quark.
+	quarks, err = USDToKin(context.Background(), p, 0.0000000007)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, quarks)
+	quarks, err = USDToKin(context.Background(), p, 0.0000000002)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, quarks)
+
+	_, err = ToFiat(context.Background(), p, "eur", 10)
+	assert.Equal(t, ErrUnsupportedCurrency, err)
+
+	_, err = ToFiat(context.Background(), p, "cad", 10)
+	assert.Equal(t, ErrInvalidPrice, errors.Cause(err))
+}
+
+func TestCachedProvider(t *testing.T) {
+	p := &staticProvider{prices: map[string]float64{"usd": 1}}
+	cached := NewCachedProvider(p, 50*time.Millisecond, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		price, err := cached.Price(context.Background(), "usd")
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, price)
+	}
+	assert.Equal(t, 1, p.calls)
+
+	// Once expired, the stale price is returned until the min interval has passed.
+	time.Sleep(60 * time.Millisecond)
+	p.prices["usd"] = 2
+	price, err := cached.Price(context.Background(), "usd")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, price)
+	assert.Equal(t, 1, p.calls)
+
+	// Failed fetches still count against the min interval.
+	p.err = errors.New("unavailable")
+	_, err = cached.Price(context.Background(), "cad")
+	assert.Error(t, err)
+	_, err = cached.Price(context.Background(), "cad")
+	assert.Equal(t, ErrRateLimited, err)
+	assert.Equal(t, 2, p.calls)
+
+	cached = NewCachedProvider(p, time.Millisecond, 0)
+	p.err = nil
+	for i := 0; i < 2; i++ {
+		time.Sleep(2 * time.Millisecond)
+		price, err = cached.Price(context.Background(), "usd")
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, price)
+	}
+	assert.Equal(t, 4, p.calls)
+}
+
+type blockingProvider struct {
+	staticProvider
+
+	mu      sync.Mutex
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingProvider) Price(ctx context.Context, currency string) (float64, error) {
+	if currency == "usd" {
+		close(b.started)
+		<-b.release
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.staticProvider.Price(ctx, currency)
+}
+
+func TestCachedProvider_Concurrent(t *testing.T) {
+	p := &blockingProvider{
+		staticProvider: staticProvider{prices: map[string]float64{"usd": 1, "cad": 2}},
+		started:        make(chan struct{}),
+		release:        make(chan struct{}),
+	}
+	cached := NewCachedProvider(p, time.Hour, time.Hour)
+
+	var wg sync.WaitGroup
+	prices := make([]float64, 5)
+	errs := make([]error, 5)
+	for i := range prices {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			prices[i], errs[i] = cached.Price(context.Background(), "usd")
+		}(i)
+	}
+	<-p.started
+
+	// Other currencies are not blocked by the fetch.
+	price, err := cached.Price(context.Background(), "cad")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, price)
+
+	// Waiting requests can be cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = cached.Price(ctx, "usd")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	close(p.release)
+	wg.Wait()
+
+	// Concurrent requests share a single fetch, rather than being rate limited.
+	for i := range prices {
+		require.NoError(t, errs[i])
+		assert.EqualValues(t, 1, prices[i])
+	}
+	assert.Equal(t, 2, p.calls)
+}
@@ -0,0 +1,303 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/pkg/errors"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+
+	"github.com/kinecosystem/kin-go/client/lookuptable"
+)
+
+// goldenAppIndex is the app index used by the golden vectors.
+const goldenAppIndex = 1
+
+// goldenBlockhash is the recent blockhash of the golden transactions.
+var goldenBlockhash = solana.Blockhash(sha256.Sum256([]byte("kin-go golden blockhash")))
+
+// GoldenVector is a deterministic input and the output kin-go constructs from
+// it, which other Kin SDKs can use to verify that they construct byte-for-byte
+// identical memos, invoice lists, and transactions.
+//
+// Keys are derived with ed25519.NewKeyFromSeed from the hex encoded seeds in
+// Inputs. Transactions use a fixed recent blockhash, and are signed, since
+// ed25519 signatures are deterministic.
+//
+// Kin 4 is the only blockchain kin-go constructs transactions for, so there are
+// no vectors for Stellar envelopes.
+type GoldenVector struct {
+	Name   string            `json:"name"`
+	Inputs map[string]string `json:"inputs"`
+
+	// Output is the base64 encoded output.
+	Output string `json:"output"`
+}
+
+// GoldenVectors returns the golden vectors of kin-go, in a stable order.
+//
+// The vectors are also checked in at client/testdata/golden.json.
+func GoldenVectors() ([]GoldenVector, error) {
+	c := &client{opts: clientOpts{appIndex: goldenAppIndex}}
+
+	subsidizer := goldenKey("subsidizer")
+	sender := goldenKey("sender")
+	dest := goldenKey("destination")
+	earnDests := []kin.PrivateKey{goldenKey("earn-0"), goldenKey("earn-1"), goldenKey("earn-2")}
+	mint := goldenKey("mint")
+
+	invoice := &commonpb.Invoice{
+		Items: []*commonpb.Invoice_LineItem{
+			{Title: "golden item", Description: "a golden item", Amount: 10, Sku: []byte("sku-1")},
+		},
+	}
+	il := &commonpb.InvoiceList{Invoices: []*commonpb.Invoice{invoice}}
+	ilBytes, err := proto.Marshal(il)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to serialize invoice list")
+	}
+	fk, err := invoiceListHash(il)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &transactionpbv4.GetServiceConfigResponse{
+		SubsidizerAccount: &commonpbv4.SolanaAccountId{Value: subsidizer.Public()},
+		Token:             &commonpbv4.SolanaAccountId{Value: mint.Public()},
+	}
+
+	var vectors []GoldenVector
+	add := func(name string, inputs map[string]string, output []byte) {
+		vectors = append(vectors, GoldenVector{
+			Name:   name,
+			Inputs: inputs,
+			Output: base64.StdEncoding.EncodeToString(output),
+		})
+	}
+	keyInputs := func(inputs map[string]string, keys map[string]kin.PrivateKey) map[string]string {
+		for name, key := range keys {
+			inputs[name+"_seed"] = hex.EncodeToString(ed25519.PrivateKey(key).Seed())
+		}
+		return inputs
+	}
+
+	// Memos.
+	for _, m := range []struct {
+		name   string
+		txType kin.TransactionType
+		fk     []byte
+	}{
+		{"memo/earn", kin.TransactionTypeEarn, nil},
+		{"memo/spend_invoice", kin.TransactionTypeSpend, fk[:]},
+		{"memo/p2p", kin.TransactionTypeP2P, nil},
+	} {
+		memo, err := kin.NewMemo(1, m.txType, goldenAppIndex, m.fk)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create %s", m.name)
+		}
+		add(m.name, map[string]string{
+			"version":     "1",
+			"type":        strconv.Itoa(int(m.txType)),
+			"app_index":   strconv.Itoa(goldenAppIndex),
+			"foreign_key": hex.EncodeToString(m.fk),
+		}, memo[:])
+	}
+
+	// Invoice lists.
+	invoiceJSON, err := json.Marshal(il)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode invoice list")
+	}
+	add("invoice_list/single", map[string]string{"invoice_list": string(invoiceJSON)}, ilBytes)
+	add("invoice_list/hash", map[string]string{"invoice_list": string(invoiceJSON)}, fk[:])
+
+	// Transactions.
+	signed := func(tx solana.Transaction, signers ...kin.PrivateKey) ([]byte, error) {
+		tx.SetBlockhash(goldenBlockhash)
+		for _, s := range signers {
+			if err := tx.Sign(ed25519.PrivateKey(s)); err != nil {
+				return nil, err
+			}
+		}
+		return tx.Marshal(), nil
+	}
+
+	for _, p := range []struct {
+		name    string
+		memo    string
+		invoice *commonpb.Invoice
+	}{
+		{"transaction/payment", "", nil},
+		{"transaction/payment_text_memo", "1-golden", nil},
+		{"transaction/payment_invoice", "", invoice},
+	} {
+		tx, signers, _, err := c.buildPaymentTx(payment{Payment: Payment{
+			Sender:      sender,
+			Destination: dest.Public(),
+			Type:        kin.TransactionTypeSpend,
+			Quarks:      12345,
+			Memo:        p.memo,
+			Invoice:     p.invoice,
+		}}, config, nil, subsidizer)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to build %s", p.name)
+		}
+		raw, err := signed(tx, signers...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to sign %s", p.name)
+		}
+
+		inputs := map[string]string{
+			"type":      strconv.Itoa(int(kin.TransactionTypeSpend)),
+			"quarks":    "12345",
+			"app_index": strconv.Itoa(goldenAppIndex),
+			"blockhash": hex.EncodeToString(goldenBlockhash[:]),
+		}
+		if p.memo != "" {
+			inputs["memo"] = p.memo
+		}
+		if p.invoice != nil {
+			inputs["invoice_list"] = string(invoiceJSON)
+		}
+		add(p.name, keyInputs(inputs, map[string]kin.PrivateKey{
+			"subsidizer": subsidizer, "sender": sender, "destination": dest,
+		}), raw)
+	}
+
+	batch := EarnBatch{Sender: sender}
+	batchKeys := map[string]kin.PrivateKey{"subsidizer": subsidizer, "sender": sender}
+	var quarks []string
+	for i, d := range earnDests {
+		batch.Earns = append(batch.Earns, Earn{Destination: d.Public(), Quarks: int64(100 * (i + 1))})
+		batchKeys[fmt.Sprintf("earn_%d", i)] = d
+		quarks = append(quarks, strconv.Itoa(100*(i+1)))
+	}
+	batchInputs := func() map[string]string {
+		return keyInputs(map[string]string{
+			"quarks":    strings.Join(quarks, ","),
+			"app_index": strconv.Itoa(goldenAppIndex),
+			"blockhash": hex.EncodeToString(goldenBlockhash[:]),
+		}, batchKeys)
+	}
+
+	tx, signers, _, err := c.buildEarnBatchTx(batch, config, nil, subsidizer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build earn batch")
+	}
+	raw, err := signed(tx, signers...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign earn batch")
+	}
+	add("transaction/earn_batch", batchInputs(), raw)
+
+	table := lookuptable.Table{Address: ed25519.PublicKey(goldenKey("lookup-table").Public())}
+	for _, d := range earnDests {
+		table.Addresses = append(table.Addresses, ed25519.PublicKey(d.Public()))
+	}
+	vtx, signers, _, err := c.buildVersionedEarnBatchTx(batch, config, nil, subsidizer, []lookuptable.Table{table})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build versioned earn batch")
+	}
+	vtx.SetBlockhash(goldenBlockhash)
+	if err := vtx.Sign(toEd25519(signers)...); err != nil {
+		return nil, errors.Wrap(err, "failed to sign versioned earn batch")
+	}
+	inputs := batchInputs()
+	inputs["lookup_table_seed"] = hex.EncodeToString(ed25519.PrivateKey(goldenKey("lookup-table")).Seed())
+	add("transaction/earn_batch_v0", inputs, vtx.Marshal())
+
+	owner := goldenKey("owner")
+	batches, err := c.buildAccountCreationBatches([]kin.PrivateKey{owner}, ed25519.PublicKey(subsidizer.Public()), ed25519.PublicKey(mint.Public()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build account creation")
+	}
+	raw, err = signed(batches[0].tx, subsidizer, owner)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign account creation")
+	}
+	add("transaction/create_account", keyInputs(map[string]string{
+		"app_index": strconv.Itoa(goldenAppIndex),
+		"blockhash": hex.EncodeToString(goldenBlockhash[:]),
+	}, map[string]kin.PrivateKey{"subsidizer": subsidizer, "owner": owner, "mint": mint}), raw)
+
+	return vectors, nil
+}
+
+// VerifyGolden reads golden vectors encoded as a JSON array of GoldenVector
+// from r, such as a golden file produced by another Kin SDK, and verifies that
+// kin-go produces identical outputs from identical inputs.
+//
+// Every vector in r must be known to kin-go, but r need not contain all of
+// kin-go's vectors. Mismatches are reported together in the returned error.
+func VerifyGolden(r io.Reader) error {
+	var actual []GoldenVector
+	if err := json.NewDecoder(r).Decode(&actual); err != nil {
+		return errors.Wrap(err, "failed to decode golden vectors")
+	}
+
+	expected, err := GoldenVectors()
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]GoldenVector, len(expected))
+	for _, v := range expected {
+		byName[v.Name] = v
+	}
+
+	var mismatches []string
+	for _, v := range actual {
+		e, ok := byName[v.Name]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, fmt.Sprintf("%s: unknown vector", v.Name))
+		case !equalInputs(e.Inputs, v.Inputs):
+			mismatches = append(mismatches, fmt.Sprintf("%s: inputs differ", v.Name))
+		case e.Output != v.Output:
+			mismatches = append(mismatches, fmt.Sprintf("%s: output differs (expected %s, got %s)", v.Name, e.Output, v.Output))
+		}
+	}
+	if len(mismatches) > 0 {
+		return errors.Errorf("golden vectors do not match:\n%s", strings.Join(mismatches, "\n"))
+	}
+
+	return nil
+}
+
+// goldenKey derives a deterministic key for a golden vector.
+func goldenKey(name string) kin.PrivateKey {
+	seed := sha256.Sum256([]byte("kin-go golden key: " + name))
+	return kin.PrivateKey(ed25519.NewKeyFromSeed(seed[:]))
+}
+
+func toEd25519(keys []kin.PrivateKey) []ed25519.PrivateKey {
+	converted := make([]ed25519.PrivateKey, len(keys))
+	for i, k := range keys {
+		converted[i] = ed25519.PrivateKey(k)
+	}
+	return converted
+}
+
+func equalInputs(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if other, ok := b[k]; !ok || v != other {
+			return false
+		}
+	}
+	return true
+}
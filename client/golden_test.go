@@ -0,0 +1,97 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client/versioned"
+)
+
+var updateGolden = flag.Bool("update", false, "regenerate testdata/golden.json")
+
+const goldenFile = "testdata/golden.json"
+
+func TestGoldenVectors(t *testing.T) {
+	vectors, err := GoldenVectors()
+	require.NoError(t, err)
+
+	if *updateGolden {
+		b, err := json.MarshalIndent(vectors, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(goldenFile), 0755))
+		require.NoError(t, ioutil.WriteFile(goldenFile, append(b, '\n'), 0644))
+	}
+
+	// Generating the vectors is deterministic.
+	again, err := GoldenVectors()
+	require.NoError(t, err)
+	assert.Equal(t, vectors, again)
+
+	names := make(map[string]bool)
+	for _, v := range vectors {
+		assert.False(t, names[v.Name], v.Name)
+		names[v.Name] = true
+	}
+
+	// The golden transactions are valid, fully signed transactions.
+	for _, v := range vectors {
+		raw, err := base64.StdEncoding.DecodeString(v.Output)
+		require.NoError(t, err)
+
+		switch v.Name {
+		case "transaction/earn_batch_v0":
+			var tx versioned.Transaction
+			require.NoError(t, tx.Unmarshal(raw), v.Name)
+			for _, sig := range tx.Signatures {
+				assert.NotEqual(t, solana.Signature{}, sig, v.Name)
+			}
+		case "transaction/payment", "transaction/payment_text_memo", "transaction/payment_invoice",
+			"transaction/earn_batch", "transaction/create_account":
+			var tx solana.Transaction
+			require.NoError(t, tx.Unmarshal(raw), v.Name)
+			for _, sig := range tx.Signatures {
+				assert.NotEqual(t, solana.Signature{}, sig, v.Name)
+			}
+		}
+	}
+}
+
+func TestVerifyGolden(t *testing.T) {
+	b, err := ioutil.ReadFile(goldenFile)
+	require.NoError(t, err)
+	require.NoError(t, VerifyGolden(bytes.NewReader(b)), "run go test -run TestGoldenVectors -update to regenerate")
+
+	var vectors []GoldenVector
+	require.NoError(t, json.Unmarshal(b, &vectors))
+	require.NotEmpty(t, vectors)
+
+	// A subset of the vectors is accepted.
+	subset, err := json.Marshal(vectors[:1])
+	require.NoError(t, err)
+	assert.NoError(t, VerifyGolden(bytes.NewReader(subset)))
+
+	// Differing outputs, differing inputs and unknown vectors are reported.
+	vectors[0].Output = base64.StdEncoding.EncodeToString([]byte("bad"))
+	vectors[1].Inputs["app_index"] = "2"
+	vectors = append(vectors, GoldenVector{Name: "unknown"})
+	modified, err := json.Marshal(vectors)
+	require.NoError(t, err)
+
+	err = VerifyGolden(bytes.NewReader(modified))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), vectors[0].Name+": output differs")
+	assert.Contains(t, err.Error(), vectors[1].Name+": inputs differ")
+	assert.Contains(t, err.Error(), "unknown: unknown vector")
+
+	assert.Error(t, VerifyGolden(bytes.NewReader([]byte("{"))))
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin/version"
+	"github.com/pkg/errors"
+)
+
+const (
+	// HealthCheckBlockchainVersion verifies that Agora is reachable, and that
+	// its blockchain version is supported by the client.
+	HealthCheckBlockchainVersion = "blockchain_version"
+
+	// HealthCheckServiceConfig verifies that the service config can be
+	// retrieved, and that it specifies a token.
+	HealthCheckServiceConfig = "service_config"
+
+	// HealthCheckRecentBlockhash verifies that a recent blockhash can be
+	// retrieved, which is required to submit transactions.
+	HealthCheckRecentBlockhash = "recent_blockhash"
+)
+
+// HealthCheckResult is the result of a single health check.
+type HealthCheckResult struct {
+	Name    string
+	Healthy bool
+	Latency time.Duration
+
+	// Error is the reason the check failed, if it is unhealthy.
+	Error error
+}
+
+// HealthReport is the result of HealthCheck.
+type HealthReport struct {
+	// Healthy is set if every check is healthy.
+	Healthy bool
+	Checks  []HealthCheckResult
+}
+
+func (c *client) HealthCheck(ctx context.Context) HealthReport {
+	checks := []struct {
+		name string
+		f    func(context.Context) error
+	}{
+		{HealthCheckBlockchainVersion, c.checkBlockchainVersion},
+		{HealthCheckServiceConfig, c.checkServiceConfig},
+		{HealthCheckRecentBlockhash, c.checkRecentBlockhash},
+	}
+
+	report := HealthReport{Healthy: true}
+	for _, check := range checks {
+		start := time.Now()
+		err := check.f(ctx)
+
+		report.Checks = append(report.Checks, HealthCheckResult{
+			Name:    check.name,
+			Healthy: err == nil,
+			Latency: time.Since(start),
+			Error:   err,
+		})
+		report.Healthy = report.Healthy && err == nil
+	}
+
+	return report
+}
+
+func (c *client) checkBlockchainVersion(ctx context.Context) error {
+	v, err := c.internal.GetBlockchainVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if v != version.KinVersion4 {
+		return errors.Errorf("unsupported blockchain version: %d", v)
+	}
+	return nil
+}
+
+func (c *client) checkServiceConfig(ctx context.Context) error {
+	config, err := c.internal.GetServiceConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if len(config.GetToken().GetValue()) != ed25519.PublicKeySize {
+		return errors.New("service config does not specify a token")
+	}
+	return nil
+}
+
+func (c *client) checkRecentBlockhash(ctx context.Context) error {
+	_, err := c.internal.GetRecentBlockhash(ctx)
+	return err
+}
+
+type healthCheckJSON struct {
+	Name      string `json:"name"`
+	Healthy   bool   `json:"healthy"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+type healthReportJSON struct {
+	Healthy bool              `json:"healthy"`
+	Checks  []healthCheckJSON `json:"checks"`
+}
+
+// HealthHandler returns an http.HandlerFunc that runs HealthCheck, suitable for
+// use as a readiness probe. It responds with http.StatusOK if the client is
+// healthy, and http.StatusServiceUnavailable otherwise. The body is a JSON
+// encoding of the report.
+func HealthHandler(c Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.HealthCheck(r.Context())
+
+		body := healthReportJSON{
+			Healthy: report.Healthy,
+			Checks:  make([]healthCheckJSON, len(report.Checks)),
+		}
+		for i, check := range report.Checks {
+			body.Checks[i] = healthCheckJSON{
+				Name:      check.Name,
+				Healthy:   check.Healthy,
+				LatencyMS: check.Latency.Milliseconds(),
+			}
+			if check.Error != nil {
+				body.Checks[i].Error = check.Error.Error()
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if report.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
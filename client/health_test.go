@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_HealthCheck(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	// Without a service config, only the service config check fails.
+	report := env.client.HealthCheck(context.Background())
+	assert.False(t, report.Healthy)
+	require.Len(t, report.Checks, 3)
+	for _, check := range report.Checks {
+		if check.Name == HealthCheckServiceConfig {
+			assert.False(t, check.Healthy)
+			assert.Error(t, check.Error)
+		} else {
+			assert.True(t, check.Healthy, check.Name)
+			assert.NoError(t, check.Error)
+		}
+	}
+
+	setServiceConfigResp(t, env.v4Server, true)
+	report = env.client.HealthCheck(context.Background())
+	assert.True(t, report.Healthy)
+	assert.Equal(t, HealthCheckBlockchainVersion, report.Checks[0].Name)
+	assert.Equal(t, HealthCheckServiceConfig, report.Checks[1].Name)
+	assert.Equal(t, HealthCheckRecentBlockhash, report.Checks[2].Name)
+
+	rr := httptest.NewRecorder()
+	HealthHandler(env.client).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.KinVersion = 3
+	env.v4Server.Mux.Unlock()
+
+	report = env.client.HealthCheck(context.Background())
+	assert.False(t, report.Healthy)
+	assert.False(t, report.Checks[0].Healthy)
+	assert.True(t, report.Checks[1].Healthy)
+
+	rr = httptest.NewRecorder()
+	HealthHandler(env.client).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	var body healthReportJSON
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+	assert.False(t, body.Healthy)
+	require.Len(t, body.Checks, 3)
+	assert.Equal(t, HealthCheckBlockchainVersion, body.Checks[0].Name)
+	assert.Equal(t, "unsupported blockchain version: 3", body.Checks[0].Error)
+	assert.Empty(t, body.Checks[1].Error)
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+
+	"github.com/kinecosystem/agora-common/solana"
+)
+
+// Hooks are functions invoked by the client at well defined points, allowing
+// applications to inject logging, accounting, or faults without wrapping the
+// Client. Any of the hooks may be nil.
+//
+// Hooks are invoked synchronously, and may be invoked concurrently by different
+// calls to the client.
+//
+// Versioned transactions (see WithAddressLookupTables) are passed to the
+// submission hooks as a legacy transaction with the same signatures and
+// instructions, whose accounts include those referenced through lookup tables.
+type Hooks struct {
+	// BeforeSubmit is invoked before each attempt to submit a signed
+	// transaction. If it returns an error, the attempt is aborted and the error
+	// is returned to the caller.
+	BeforeSubmit func(ctx context.Context, tx solana.Transaction) error
+
+	// AfterSubmit is invoked after each attempt to submit a transaction, with
+	// the result of the attempt.
+	AfterSubmit func(ctx context.Context, tx solana.Transaction, result SubmitTransactionResult, err error)
+
+	// OnRetry is invoked before a failed request to Agora, or a failed
+	// submission attempt, is retried. attempt is the number of the upcoming
+	// attempt (starting at 2), and err is the error that caused the retry.
+	OnRetry func(attempt uint, err error)
+}
+
+// WithHooks specifies the Hooks invoked by the client.
+func WithHooks(hooks Hooks) ClientOption {
+	return func(o *clientOpts) {
+		o.hooks = hooks
+	}
+}
+
+// withRetryHook returns an action that invokes onRetry before every attempt of
+// action after the first.
+func withRetryHook(onRetry func(uint, error), action func() error) func() error {
+	if onRetry == nil {
+		return action
+	}
+
+	var attempts uint
+	var lastErr error
+	return func() error {
+		if attempts > 0 {
+			onRetry(attempts+1, lastErr)
+		}
+
+		attempts++
+		lastErr = action()
+		return lastErr
+	}
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/retry"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+type hookRecorder struct {
+	sync.Mutex
+	before  []solana.Transaction
+	after   []SubmitTransactionResult
+	retries []uint
+	abort   error
+}
+
+func (r *hookRecorder) hooks() Hooks {
+	return Hooks{
+		BeforeSubmit: func(_ context.Context, tx solana.Transaction) error {
+			r.Lock()
+			defer r.Unlock()
+			r.before = append(r.before, tx)
+			return r.abort
+		},
+		AfterSubmit: func(_ context.Context, _ solana.Transaction, result SubmitTransactionResult, _ error) {
+			r.Lock()
+			defer r.Unlock()
+			r.after = append(r.after, result)
+		},
+		OnRetry: func(attempt uint, err error) {
+			r.Lock()
+			defer r.Unlock()
+			if err == ErrBlockhashNotFound {
+				r.retries = append(r.retries, attempt)
+			}
+		},
+	}
+}
+
+func TestClient_Hooks(t *testing.T) {
+	recorder := &hookRecorder{}
+	env, cleanup := setup(t, WithMaxNonceRetries(3), WithHooks(recorder.hooks()))
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	setServiceConfigResp(t, env.v4Server, true)
+	for _, acc := range []kin.PrivateKey{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		{
+			Result: transactionpbv4.SubmitTransactionResponse_FAILED,
+			TransactionError: &commonpbv4.TransactionError{
+				Reason: commonpbv4.TransactionError_BAD_NONCE,
+				Raw:    []byte(`"BlockhashNotFound"`),
+			},
+		},
+	}
+	env.v4Server.Mux.Unlock()
+
+	p := Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+	}
+	txID, err := env.client.SubmitPayment(context.Background(), p)
+	require.NoError(t, err)
+
+	recorder.Lock()
+	require.Len(t, recorder.before, 2)
+	require.Len(t, recorder.after, 2)
+	assert.Equal(t, ErrBlockhashNotFound, recorder.after[0].Errors.TxError)
+	assert.NoError(t, recorder.after[1].Errors.TxError)
+	assert.Equal(t, txID, recorder.before[1].Signature())
+	assert.Equal(t, []uint{2}, recorder.retries)
+
+	// An error returned by BeforeSubmit aborts the submission.
+	recorder.abort = errors.New("abort")
+	recorder.Unlock()
+
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	assert.Equal(t, recorder.abort, err)
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.Submits, 2)
+	env.v4Server.Mux.Unlock()
+}
+
+func TestRetrier_OnRetry(t *testing.T) {
+	var attempts []uint
+	var errs []error
+	r := &reloadableRetrier{
+		retrier: retry.NewRetrier(retry.Limit(3)),
+		onRetry: func(attempt uint, err error) {
+			attempts = append(attempts, attempt)
+			errs = append(errs, err)
+		},
+	}
+
+	failure := errors.New("failure")
+	n, err := r.Retry(func() error {
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	assert.EqualValues(t, 3, n)
+	assert.Equal(t, []uint{2, 3}, attempts)
+	assert.Equal(t, []error{failure, failure}, errs)
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/kinecosystem/agora-common/kin"
 	"github.com/kinecosystem/agora-common/kin/version"
 	"github.com/kinecosystem/agora-common/retry"
@@ -27,12 +28,17 @@ import (
 	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
 	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
 	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+
+	"github.com/kinecosystem/kin-go/client/versioned"
 )
 
 const (
-	SDKVersion      = "0.8.0"
-	userAgentHeader = "kin-user-agent"
-	appIndexHeader  = "app-index"
+	SDKVersion           = "0.8.0"
+	userAgentHeader      = "kin-user-agent"
+	appIndexHeader       = "app-index"
+	appUserIDHeader      = "app-user-id"
+	appUserPasskeyHeader = "app-user-passkey"
+	requestIDHeader      = "x-request-id"
 )
 
 var (
@@ -49,6 +55,9 @@ type InternalClient struct {
 	retrier  retry.Retrier
 	appIndex uint16
 
+	// writeRetrier is used for submissions, if set. Otherwise, retrier is used.
+	writeRetrier retry.Retrier
+
 	accountClientV4     accountpbv4.AccountClient
 	transactionClientV4 transactionpbv4.TransactionClient
 	airdropClientV4     airdroppbv4.AirdropClient
@@ -56,6 +65,32 @@ type InternalClient struct {
 	configMux         sync.Mutex
 	serviceConfig     *transactionpbv4.GetServiceConfigResponse
 	configLastFetched time.Time
+
+	rentMux        sync.Mutex
+	rentExemptions map[uint64]rentExemption
+
+	cache sharedCache
+
+	// faults is the fault injector used for submissions, if any.
+	faults *faultInjector
+
+	clock Clock
+
+	// minVersion is the minimum blockchain version, if any. The result of the
+	// last check is cached for minVersionTTL.
+	minVersion       version.KinVersion
+	minVersionMux    sync.Mutex
+	minVersionErr    error
+	minVersionLastAt time.Time
+}
+
+// minVersionTTL is the duration for which the result of a minimum version check
+// is cached.
+const minVersionTTL = 5 * time.Minute
+
+type rentExemption struct {
+	lamports    uint64
+	lastFetched time.Time
 }
 
 func NewInternalClient(cc *grpc.ClientConn, retrier retry.Retrier, appIndex uint16) *InternalClient {
@@ -65,6 +100,7 @@ func NewInternalClient(cc *grpc.ClientConn, retrier retry.Retrier, appIndex uint
 		transactionClientV4: transactionpbv4.NewTransactionClient(cc),
 		airdropClientV4:     airdroppbv4.NewAirdropClient(cc),
 		appIndex:            appIndex,
+		clock:               SystemClock(),
 	}
 }
 
@@ -89,6 +125,33 @@ func (c *InternalClient) GetBlockchainVersion(ctx context.Context) (version.KinV
 	return kinVersion, nil
 }
 
+// checkMinimumVersion returns a *MinimumVersionError if the blockchain version
+// is below the minimum version configured with WithMinimumKinVersion.
+func (c *InternalClient) checkMinimumVersion(ctx context.Context) error {
+	if c.minVersion == version.KinVersionUnknown {
+		return nil
+	}
+
+	c.minVersionMux.Lock()
+	defer c.minVersionMux.Unlock()
+
+	if !c.minVersionLastAt.IsZero() && c.clock.Now().Sub(c.minVersionLastAt) < minVersionTTL {
+		return c.minVersionErr
+	}
+
+	v, err := c.GetBlockchainVersion(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get blockchain version")
+	}
+
+	c.minVersionErr = nil
+	if v < c.minVersion {
+		c.minVersionErr = &MinimumVersionError{Minimum: c.minVersion, Version: v}
+	}
+	c.minVersionLastAt = c.clock.Now()
+	return c.minVersionErr
+}
+
 type SignTransactionResult struct {
 	ID            []byte
 	InvoiceErrors []*commonpb.InvoiceError
@@ -98,6 +161,10 @@ type SubmitTransactionResult struct {
 	ID            []byte
 	Errors        TransactionErrors
 	InvoiceErrors []*commonpb.InvoiceError
+
+	// Commitment is the commitment the transaction was accepted at. It is only
+	// set if the transaction was submitted.
+	Commitment commonpbv4.Commitment
 }
 
 func (s SubmitTransactionResult) String() string {
@@ -123,6 +190,9 @@ func (s SubmitTransactionResult) String() string {
 
 func (c *InternalClient) CreateSolanaAccount(ctx context.Context, key kin.PrivateKey, commitment commonpbv4.Commitment, subsidizer kin.PrivateKey, appIndex uint16) (err error) {
 	ctx = c.addMetadataToCtx(ctx)
+	if err = c.checkMinimumVersion(ctx); err != nil {
+		return err
+	}
 
 	config, err := c.GetServiceConfig(ctx)
 	if err != nil {
@@ -192,7 +262,7 @@ func (c *InternalClient) CreateSolanaAccount(ctx context.Context, key kin.Privat
 	}
 
 	var resp *accountpbv4.CreateAccountResponse
-	_, err = c.retrier.Retry(func() error {
+	_, err = c.writes().Retry(func() error {
 		resp, err = c.accountClientV4.CreateAccount(ctx, &accountpbv4.CreateAccountRequest{
 			Transaction: &commonpbv4.Transaction{
 				Value: tx.Marshal(),
@@ -222,6 +292,9 @@ func (c *InternalClient) CreateSolanaAccount(ctx context.Context, key kin.Privat
 
 func (c *InternalClient) GetSolanaAccountInfo(ctx context.Context, account kin.PublicKey, commitment commonpbv4.Commitment) (accountInfo *accountpbv4.AccountInfo, err error) {
 	ctx = c.addMetadataToCtx(ctx)
+	if err = c.checkMinimumVersion(ctx); err != nil {
+		return nil, err
+	}
 
 	_, err = c.retrier.Retry(func() error {
 		resp, err := c.accountClientV4.GetAccountInfo(ctx, &accountpbv4.GetAccountInfoRequest{
@@ -251,6 +324,10 @@ func (c *InternalClient) GetSolanaAccountInfo(ctx context.Context, account kin.P
 }
 
 func (c *InternalClient) GetEvents(ctx context.Context, account kin.PublicKey) (<-chan EventsResult, error) {
+	if err := c.checkMinimumVersion(ctx); err != nil {
+		return nil, err
+	}
+
 	var ch chan EventsResult
 	_, err := c.retrier.Retry(func() error {
 		stream, err := c.accountClientV4.GetEvents(ctx, &accountpbv4.GetEventsRequest{AccountId: &commonpbv4.SolanaAccountId{Value: account}})
@@ -292,7 +369,27 @@ func (c *InternalClient) GetEvents(ctx context.Context, account kin.PublicKey) (
 }
 
 func (c *InternalClient) ResolveTokenAccounts(ctx context.Context, publicKey kin.PublicKey, includeAccountInfo bool) (accounts []*accountpbv4.AccountInfo, err error) {
+	accounts, _, err = c.resolveTokenAccounts(ctx, publicKey, includeAccountInfo)
+	return accounts, err
+}
+
+// resolveTokenAccounts is ResolveTokenAccounts, additionally returning whether
+// or not the accounts were served from the cache.
+func (c *InternalClient) resolveTokenAccounts(ctx context.Context, publicKey kin.PublicKey, includeAccountInfo bool) (accounts []*accountpbv4.AccountInfo, fromCache bool, err error) {
 	ctx = c.addMetadataToCtx(ctx)
+	if err = c.checkMinimumVersion(ctx); err != nil {
+		return nil, false, err
+	}
+
+	if !includeAccountInfo {
+		if cached, ok := c.cache.getResolution(ctx, publicKey); ok {
+			accounts = make([]*accountpbv4.AccountInfo, len(cached))
+			for i, a := range cached {
+				accounts[i] = &accountpbv4.AccountInfo{AccountId: &commonpbv4.SolanaAccountId{Value: a}}
+			}
+			return accounts, true, nil
+		}
+	}
 
 	var resp *accountpbv4.ResolveTokenAccountsResponse
 
@@ -304,7 +401,7 @@ func (c *InternalClient) ResolveTokenAccounts(ctx context.Context, publicKey kin
 		return err
 	})
 	if err != nil {
-		return accounts, errors.Wrap(err, "failed to resolve token accounts")
+		return accounts, false, errors.Wrap(err, "failed to resolve token accounts")
 	}
 
 	// This is currently in place for backward compat with the server.
@@ -318,17 +415,127 @@ func (c *InternalClient) ResolveTokenAccounts(ctx context.Context, publicKey kin
 				}
 			}
 		} else {
-			return nil, errors.New("server does not support resolving with account info")
+			return nil, false, errors.New("server does not support resolving with account info")
 		}
 	}
 
-	return resp.TokenAccountInfos, nil
+	resolved := make([]kin.PublicKey, len(resp.TokenAccountInfos))
+	for i, info := range resp.TokenAccountInfos {
+		resolved[i] = info.GetAccountId().GetValue()
+	}
+	c.cache.setResolution(ctx, publicKey, resolved)
+
+	return resp.TokenAccountInfos, false, nil
+}
+
+// invalidateResolutions removes the cached token account resolutions of owners.
+func (c *InternalClient) invalidateResolutions(ctx context.Context, owners ...kin.PublicKey) {
+	for _, owner := range owners {
+		c.cache.invalidateResolution(ctx, owner)
+	}
 }
 
 func (c *InternalClient) GetTransaction(ctx context.Context, txID []byte, commitment commonpbv4.Commitment) (data TransactionData, err error) {
+	resp, err := c.getTransaction(ctx, txID, commitment)
+	if err != nil {
+		return TransactionData{}, err
+	}
+
+	if resp.Item != nil {
+		data, err = parseHistoryItem(resp.Item)
+		if err != nil {
+			return TransactionData{}, errors.Wrap(err, "failed to parse payments")
+		}
+	}
+
+	data.TxID = txID
+	data.TxState = txStateFromProto(resp.State)
+	if data.TxState == TransactionStateSuccess {
+		data.Slot = resp.Slot
+		data.Commitment = commitment
+	}
+
+	return data, nil
+}
+
+// GetHistory returns a page of the transaction history of an account, starting after
+// the specified cursor.
+func (c *InternalClient) GetHistory(ctx context.Context, account kin.PublicKey, cursor Cursor, direction transactionpbv4.GetHistoryRequest_Direction) (items []HistoryItem, err error) {
 	ctx = c.addMetadataToCtx(ctx)
+	if err = c.checkMinimumVersion(ctx); err != nil {
+		return nil, err
+	}
 
-	var resp *transactionpbv4.GetTransactionResponse
+	req := &transactionpbv4.GetHistoryRequest{
+		AccountId: &commonpbv4.SolanaAccountId{Value: account},
+		Direction: direction,
+	}
+	if cursor != nil {
+		req.Cursor = &transactionpbv4.Cursor{Value: cursor}
+	}
+
+	var resp *transactionpbv4.GetHistoryResponse
+	_, err = c.retrier.Retry(func() error {
+		resp, err = c.transactionClientV4.GetHistory(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get history")
+	}
+
+	switch resp.Result {
+	case transactionpbv4.GetHistoryResponse_OK:
+	case transactionpbv4.GetHistoryResponse_NOT_FOUND:
+		return nil, ErrAccountDoesNotExist
+	default:
+		return nil, errors.Errorf("unexpected result from agora: %v", resp.Result)
+	}
+
+	items = make([]HistoryItem, len(resp.Items))
+	for i, item := range resp.Items {
+		data, err := parseHistoryItem(item)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse payments")
+		}
+
+		data.TxID = item.TransactionId.GetValue()
+		data.TxState = TransactionStateSuccess
+		if item.TransactionError != nil {
+			data.TxState = TransactionStateFailed
+		}
+
+		items[i] = HistoryItem{
+			TransactionData: data,
+			Cursor:          item.Cursor.GetValue(),
+			Time:            data.BlockTime,
+		}
+	}
+
+	return items, nil
+}
+
+// GetTransactionStatus returns the status of a transaction. Unlike GetTransaction,
+// the payments of the transaction are not parsed.
+func (c *InternalClient) GetTransactionStatus(ctx context.Context, txID []byte, commitment commonpbv4.Commitment) (status TransactionStatus, err error) {
+	resp, err := c.getTransaction(ctx, txID, commitment)
+	if err != nil {
+		return TransactionStatus{}, err
+	}
+
+	return TransactionStatus{
+		TxID:          txID,
+		TxState:       txStateFromProto(resp.State),
+		Slot:          resp.Slot,
+		Confirmations: resp.Confirmations,
+		TxError:       errorFromProto(resp.GetItem().GetTransactionError()),
+	}, nil
+}
+
+func (c *InternalClient) getTransaction(ctx context.Context, txID []byte, commitment commonpbv4.Commitment) (resp *transactionpbv4.GetTransactionResponse, err error) {
+	ctx = c.addMetadataToCtx(ctx)
+	if err = c.checkMinimumVersion(ctx); err != nil {
+		return nil, err
+	}
 
 	_, err = c.retrier.Retry(func() error {
 		resp, err = c.transactionClientV4.GetTransaction(ctx, &transactionpbv4.GetTransactionRequest{
@@ -340,30 +547,42 @@ func (c *InternalClient) GetTransaction(ctx context.Context, txID []byte, commit
 		return err
 	})
 	if err != nil {
-		return TransactionData{}, errors.Wrap(err, "failed to get transaction")
-	}
-
-	data.TxID = txID
-	data.TxState = txStateFromProto(resp.State)
-	if resp.Item != nil {
-		data.Payments, data.Errors, err = parseHistoryItem(resp.Item)
-		if err != nil {
-			return TransactionData{}, errors.Wrap(err, "failed to parse payments")
-		}
+		return nil, errors.Wrap(err, "failed to get transaction")
 	}
 
-	return data, nil
+	return resp, nil
 }
 
 func (c *InternalClient) SignTransaction(ctx context.Context, tx solana.Transaction, il *commonpb.InvoiceList) (result SignTransactionResult, err error) {
+	// The request is serialized by each call, so the buffer can be
+	// reused once all attempts have completed.
+	buf := txBufPool.Get().(*[]byte)
+	*buf = AppendTransaction((*buf)[:0], tx)
+	defer txBufPool.Put(buf)
+
+	return c.signTransaction(ctx, *buf, il)
+}
+
+// SignVersionedTransaction requests that Agora sign a versioned transaction as
+// the subsidizer, which Agora must support.
+func (c *InternalClient) SignVersionedTransaction(ctx context.Context, tx versioned.Transaction, il *commonpb.InvoiceList) (result SignTransactionResult, err error) {
+	return c.signTransaction(ctx, tx.Marshal(), il)
+}
+
+func (c *InternalClient) signTransaction(ctx context.Context, raw []byte, il *commonpb.InvoiceList) (result SignTransactionResult, err error) {
 	ctx = c.addMetadataToCtx(ctx)
+	if err = c.checkMinimumVersion(ctx); err != nil {
+		return result, err
+	}
+
+	req := &transactionpbv4.SignTransactionRequest{
+		Transaction: &commonpbv4.Transaction{Value: raw},
+		InvoiceList: il,
+	}
 
 	var resp *transactionpbv4.SignTransactionResponse
 	_, err = c.retrier.Retry(func() error {
-		resp, err = c.transactionClientV4.SignTransaction(ctx, &transactionpbv4.SignTransactionRequest{
-			Transaction: &commonpbv4.Transaction{Value: tx.Marshal()},
-			InvoiceList: il,
-		})
+		resp, err = c.transactionClientV4.SignTransaction(ctx, req)
 
 		return err
 	})
@@ -389,27 +608,60 @@ func (c *InternalClient) SignTransaction(ctx context.Context, tx solana.Transact
 }
 
 func (c *InternalClient) SubmitSolanaTransaction(ctx context.Context, tx solana.Transaction, il *commonpb.InvoiceList, commitment commonpbv4.Commitment, dedupeID []byte) (result SubmitTransactionResult, err error) {
+	// The request is serialized by each call, so the buffer can be
+	// reused once all attempts have completed.
+	buf := txBufPool.Get().(*[]byte)
+	*buf = AppendTransaction((*buf)[:0], tx)
+	defer txBufPool.Put(buf)
+
+	return c.submitTransaction(ctx, *buf, il, commitment, dedupeID, func(txError *commonpbv4.TransactionError) TransactionErrors {
+		return errorsFromSolanaTx(&tx, txError)
+	})
+}
+
+// SubmitVersionedTransaction submits a versioned transaction, which Agora must
+// support.
+func (c *InternalClient) SubmitVersionedTransaction(ctx context.Context, tx versioned.Transaction, il *commonpb.InvoiceList, commitment commonpbv4.Commitment, dedupeID []byte) (result SubmitTransactionResult, err error) {
+	return c.submitTransaction(ctx, tx.Marshal(), il, commitment, dedupeID, func(txError *commonpbv4.TransactionError) TransactionErrors {
+		return errorsFromVersionedTx(&tx, txError)
+	})
+}
+
+func (c *InternalClient) submitTransaction(ctx context.Context, raw []byte, il *commonpb.InvoiceList, commitment commonpbv4.Commitment, dedupeID []byte, txErrors func(*commonpbv4.TransactionError) TransactionErrors) (result SubmitTransactionResult, err error) {
 	ctx = c.addMetadataToCtx(ctx)
+	if err = c.checkMinimumVersion(ctx); err != nil {
+		return result, err
+	}
 
 	attempt := 0
 
+	req := &transactionpbv4.SubmitTransactionRequest{
+		Transaction: &commonpbv4.Transaction{Value: raw},
+		InvoiceList: il,
+		Commitment:  commitment,
+		DedupeId:    dedupeID,
+	}
+
 	var resp *transactionpbv4.SubmitTransactionResponse
 
-	_, err = c.retrier.Retry(func() error {
+	_, err = c.writes().Retry(func() error {
 		attempt += 1
 
-		resp, err = c.transactionClientV4.SubmitTransaction(ctx, &transactionpbv4.SubmitTransactionRequest{
-			Transaction: &commonpbv4.Transaction{Value: tx.Marshal()},
-			InvoiceList: il,
-			Commitment:  commitment,
-			DedupeId:    dedupeID,
-		})
+		if injected, ok := c.faults.submitResponse(); ok {
+			resp = injected
+			return nil
+		}
+
+		resp, err = c.transactionClientV4.SubmitTransaction(ctx, req)
 		if err != nil {
 			return errors.Wrap(err, "failed to submit transaction")
 		}
+		if resp.Result == transactionpbv4.SubmitTransactionResponse_OK && c.faults.alreadySubmitted() {
+			resp.Result = transactionpbv4.SubmitTransactionResponse_ALREADY_SUBMITTED
+		}
 
 		if resp.Result == transactionpbv4.SubmitTransactionResponse_ALREADY_SUBMITTED && attempt == 1 {
-			return ErrAlreadySubmitted
+			return &AlreadySubmittedError{TxID: resp.Signature.GetValue()}
 		}
 
 		return nil
@@ -422,14 +674,16 @@ func (c *InternalClient) SubmitSolanaTransaction(ctx context.Context, tx solana.
 
 	switch resp.Result {
 	case transactionpbv4.SubmitTransactionResponse_OK:
+		result.Commitment = commitment
 	case transactionpbv4.SubmitTransactionResponse_ALREADY_SUBMITTED:
+		result.Commitment = commitment
 	case transactionpbv4.SubmitTransactionResponse_REJECTED:
 		return result, ErrTransactionRejected
 	case transactionpbv4.SubmitTransactionResponse_PAYER_REQUIRED:
 		return result, ErrPayerRequired
 	case transactionpbv4.SubmitTransactionResponse_FAILED:
-		txErrors := errorsFromSolanaTx(&tx, resp.TransactionError)
-		result.Errors = txErrors
+		result.Commitment = commitment
+		result.Errors = txErrors(resp.TransactionError)
 	case transactionpbv4.SubmitTransactionResponse_INVOICE_ERROR:
 		result.InvoiceErrors = resp.InvoiceErrors
 	default:
@@ -441,16 +695,28 @@ func (c *InternalClient) SubmitSolanaTransaction(ctx context.Context, tx solana.
 
 func (c *InternalClient) GetServiceConfig(ctx context.Context) (resp *transactionpbv4.GetServiceConfigResponse, err error) {
 	ctx = c.addMetadataToCtx(ctx)
+	if err = c.checkMinimumVersion(ctx); err != nil {
+		return nil, err
+	}
 
 	c.configMux.Lock()
 	resp = c.serviceConfig
 	lastFetched := c.configLastFetched
 	c.configMux.Unlock()
 
-	if resp != nil && time.Since(lastFetched) < time.Hour*24 {
+	if resp != nil && c.clock.Now().Sub(lastFetched) < time.Hour*24 {
 		return resp, nil
 	}
 
+	if cached, ok := c.cache.getServiceConfig(ctx, c.appIndex); ok {
+		c.configMux.Lock()
+		c.serviceConfig = cached
+		c.configLastFetched = c.clock.Now()
+		c.configMux.Unlock()
+
+		return cached, nil
+	}
+
 	_, err = c.retrier.Retry(func() error {
 		resp, err = c.transactionClientV4.GetServiceConfig(ctx, &transactionpbv4.GetServiceConfigRequest{})
 		return err
@@ -461,14 +727,23 @@ func (c *InternalClient) GetServiceConfig(ctx context.Context) (resp *transactio
 
 	c.configMux.Lock()
 	c.serviceConfig = resp
-	c.configLastFetched = time.Now()
+	c.configLastFetched = c.clock.Now()
 	c.configMux.Unlock()
 
+	c.cache.setServiceConfig(ctx, c.appIndex, resp)
+
 	return resp, nil
 }
 
 func (c *InternalClient) GetRecentBlockhash(ctx context.Context) (blockhash solana.Blockhash, err error) {
 	ctx = c.addMetadataToCtx(ctx)
+	if err = c.checkMinimumVersion(ctx); err != nil {
+		return blockhash, err
+	}
+
+	if cached, ok := c.cache.getBlockhash(ctx); ok {
+		return cached, nil
+	}
 
 	var resp *transactionpbv4.GetRecentBlockhashResponse
 
@@ -481,11 +756,27 @@ func (c *InternalClient) GetRecentBlockhash(ctx context.Context) (blockhash sola
 	}
 
 	copy(blockhash[:], resp.Blockhash.Value)
+	c.cache.setBlockhash(ctx, blockhash)
+
 	return blockhash, nil
 }
 
+// GetMinimumBalanceForRentException returns the minimum balance, in lamports, an
+// account of the specified size requires to be rent exempt. Results are cached
+// for an hour.
 func (c *InternalClient) GetMinimumBalanceForRentException(ctx context.Context, size uint64) (balance uint64, err error) {
 	ctx = c.addMetadataToCtx(ctx)
+	if err = c.checkMinimumVersion(ctx); err != nil {
+		return 0, err
+	}
+
+	c.rentMux.Lock()
+	cached, ok := c.rentExemptions[size]
+	c.rentMux.Unlock()
+
+	if ok && c.clock.Now().Sub(cached.lastFetched) < time.Hour {
+		return cached.lamports, nil
+	}
 
 	var resp *transactionpbv4.GetMinimumBalanceForRentExemptionResponse
 
@@ -500,15 +791,25 @@ func (c *InternalClient) GetMinimumBalanceForRentException(ctx context.Context,
 		return balance, errors.Wrap(err, "failed to get minimum balance for rent exception")
 	}
 
+	c.rentMux.Lock()
+	if c.rentExemptions == nil {
+		c.rentExemptions = make(map[uint64]rentExemption)
+	}
+	c.rentExemptions[size] = rentExemption{lamports: resp.Lamports, lastFetched: c.clock.Now()}
+	c.rentMux.Unlock()
+
 	return resp.Lamports, nil
 }
 
 func (c *InternalClient) RequestAirdrop(ctx context.Context, publicKey kin.PublicKey, quarks uint64, commitment commonpbv4.Commitment) (txID []byte, err error) {
 	ctx = c.addMetadataToCtx(ctx)
+	if err = c.checkMinimumVersion(ctx); err != nil {
+		return nil, err
+	}
 
 	var resp *airdroppbv4.RequestAirdropResponse
 
-	_, err = c.retrier.Retry(func() error {
+	_, err = c.writes().Retry(func() error {
 		resp, err = c.airdropClientV4.RequestAirdrop(ctx, &airdroppbv4.RequestAirdropRequest{
 			AccountId:  &commonpbv4.SolanaAccountId{Value: publicKey},
 			Quarks:     quarks,
@@ -533,19 +834,73 @@ func (c *InternalClient) RequestAirdrop(ctx context.Context, publicKey kin.Publi
 	}
 }
 
-func (c *InternalClient) addMetadataToCtx(ctx context.Context) context.Context {
-	if c.appIndex > 0 {
-		return metadata.AppendToOutgoingContext(
-			ctx,
-			userAgentHeader, userAgent,
-			version.KinVersionHeader, strconv.Itoa(int(version.KinVersion4)),
-			appIndexHeader, strconv.Itoa(int(c.appIndex)),
-		)
+// writes returns the retrier used for submissions.
+func (c *InternalClient) writes() retry.Retrier {
+	if c.writeRetrier != nil {
+		return c.writeRetrier
 	}
+	return c.retrier
+}
 
-	return metadata.AppendToOutgoingContext(
-		ctx,
+func (c *InternalClient) addMetadataToCtx(ctx context.Context) context.Context {
+	kv := []string{
 		userAgentHeader, userAgent,
 		version.KinVersionHeader, strconv.Itoa(int(version.KinVersion4)),
-	)
+	}
+	if c.appIndex > 0 {
+		kv = append(kv, appIndexHeader, strconv.Itoa(int(c.appIndex)))
+	}
+	if creds, ok := ctx.Value(appUserCredentialsKey{}).(appUserCredentials); ok {
+		kv = append(kv, appUserIDHeader, creds.userID, appUserPasskeyHeader, creds.passkey)
+	}
+	if id, ok := RequestIDFromContext(ctx); ok {
+		kv = append(kv, requestIDHeader, id)
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+type appUserCredentialsKey struct{}
+
+type appUserCredentials struct {
+	userID  string
+	passkey string
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a context containing a request ID. Requests made with
+// the context include the ID, which Agora forwards to the app's webhooks, so that logs
+// from the client, Agora, and webhook handlers can be joined.
+//
+// Submissions made with a context without a request ID are assigned a generated ID,
+// which is available to Hooks and AuditSinks through RequestIDFromContext.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID of a context, if any. Webhook handlers
+// set the ID of each request in the request's context.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// ensureRequestID returns ctx if it contains a request ID, or a context
+// containing a generated request ID otherwise.
+func ensureRequestID(ctx context.Context) context.Context {
+	if _, ok := RequestIDFromContext(ctx); ok {
+		return ctx
+	}
+	return ContextWithRequestID(ctx, uuid.New().String())
+}
+
+// ContextWithAppUserCredentials returns a context containing app user credentials.
+// Requests made with the context include the credentials, which Agora forwards to
+// the app's sign transaction webhook (see SignTransactionRequest.UserID).
+func ContextWithAppUserCredentials(ctx context.Context, userID, passkey string) context.Context {
+	return context.WithValue(ctx, appUserCredentialsKey{}, appUserCredentials{
+		userID:  userID,
+		passkey: passkey,
+	})
 }
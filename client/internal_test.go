@@ -10,14 +10,13 @@ import (
 	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/google/uuid"
-	"github.com/kinecosystem/agora-common/headers"
 	"github.com/kinecosystem/agora-common/kin"
 	"github.com/kinecosystem/agora-common/kin/version"
 	"github.com/kinecosystem/agora-common/solana"
 	solanamemo "github.com/kinecosystem/agora-common/solana/memo"
 	"github.com/kinecosystem/agora-common/solana/token"
-	agoratestutil "github.com/kinecosystem/agora-common/testutil"
 	"github.com/mr-tron/base58/base58"
 	"github.com/pkg/errors"
 	"github.com/stellar/go/xdr"
@@ -28,7 +27,6 @@ import (
 	"google.golang.org/grpc/status"
 
 	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
-	airdroppbv4 "github.com/kinecosystem/agora-api/genproto/airdrop/v4"
 	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
 	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
 	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
@@ -37,29 +35,19 @@ import (
 )
 
 type testEnv struct {
-	v4Server *server
+	v4Server *TestServer
 	conn     *grpc.ClientConn
 	internal *InternalClient
 	client   *client
 }
 
-func setup(t *testing.T, opts ...ClientOption) (*testEnv, func()) {
+func setup(t testing.TB, opts ...ClientOption) (*testEnv, func()) {
 	env := &testEnv{
-		v4Server: newServer(),
+		v4Server: NewTestServer(),
 	}
 
-	conn, serv, err := agoratestutil.NewServer(
-		agoratestutil.WithUnaryServerInterceptor(headers.UnaryServerInterceptor()),
-		agoratestutil.WithStreamServerInterceptor(headers.StreamServerInterceptor()),
-	)
+	conn, stop, err := env.v4Server.Start()
 	require.NoError(t, err)
-
-	serv.RegisterService(func(s *grpc.Server) {
-		accountpbv4.RegisterAccountServer(s, env.v4Server)
-		transactionpbv4.RegisterTransactionServer(s, env.v4Server)
-		airdroppbv4.RegisterAirdropServer(s, env.v4Server)
-	})
-
 	env.conn = conn
 
 	defaultOpts := []ClientOption{
@@ -79,10 +67,7 @@ func setup(t *testing.T, opts ...ClientOption) (*testEnv, func()) {
 	env.client = c.(*client)
 	env.internal = env.client.internal
 
-	cleanup, err := serv.Serve()
-	require.NoError(t, err)
-
-	return env, cleanup
+	return env, stop
 }
 
 func TestInternal_BlockchainVersion(t *testing.T) {
@@ -461,6 +446,75 @@ func TestInternal_GetTransactionWithError(t *testing.T) {
 	assert.Equal(t, ErrBadNonce, actual.Errors.TxError)
 }
 
+func TestInternal_GetTransactionMetadata(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	_, txData, resp := generateV4SolanaPayments(t, false)
+	resp.State = transactionpbv4.GetTransactionResponse_SUCCESS
+	resp.Slot = 10
+
+	blockTime := time.Unix(1600000000, 0)
+	resp.Item.TransactionTime, _ = ptypes.TimestampProto(blockTime)
+
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(resp.Item.GetSolanaTransaction().Value))
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.Gets[string(txData.TxID)] = resp
+	env.v4Server.Mux.Unlock()
+
+	actual, err := env.internal.GetTransaction(context.Background(), txData.TxID, commonpbv4.Commitment_MAX)
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, actual.Slot)
+	assert.Equal(t, commonpbv4.Commitment_MAX, actual.Commitment)
+	assert.True(t, blockTime.Equal(actual.BlockTime))
+	assert.EqualValues(t, tx.Message.Accounts[0], actual.FeePayer)
+	assert.EqualValues(t, uint64(tx.Message.Header.NumSignatures)*LamportsPerSignature, actual.FeeLamports)
+
+	// The slot and commitment are only set for successful transactions.
+	resp.State = transactionpbv4.GetTransactionResponse_PENDING
+	env.v4Server.Mux.Lock()
+	env.v4Server.Gets[string(txData.TxID)] = resp
+	env.v4Server.Mux.Unlock()
+
+	actual, err = env.internal.GetTransaction(context.Background(), txData.TxID, commonpbv4.Commitment_MAX)
+	require.NoError(t, err)
+	assert.Zero(t, actual.Slot)
+	assert.Equal(t, commonpbv4.Commitment_RECENT, actual.Commitment)
+	assert.EqualValues(t, tx.Message.Accounts[0], actual.FeePayer)
+}
+
+func TestInternal_GetTransactionStatus(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	status, err := env.internal.GetTransactionStatus(context.Background(), make([]byte, 32), commonpbv4.Commitment_SINGLE)
+	require.NoError(t, err)
+	assert.Equal(t, make([]byte, 32), status.TxID)
+	assert.Equal(t, TransactionStateUnknown, status.TxState)
+
+	_, txData, resp := generateV4SolanaPayments(t, false)
+	resp.Slot = 10
+	resp.Confirmations = 2
+	resp.Item.TransactionError = &commonpbv4.TransactionError{
+		Reason: commonpbv4.TransactionError_BAD_NONCE,
+		Raw:    []byte("rawerror"),
+	}
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.Gets[string(txData.TxID)] = resp
+	env.v4Server.Mux.Unlock()
+
+	status, err = env.internal.GetTransactionStatus(context.Background(), txData.TxID, commonpbv4.Commitment_SINGLE)
+	require.NoError(t, err)
+	assert.Equal(t, txData.TxID, status.TxID)
+	assert.Equal(t, TransactionStateSuccess, status.TxState)
+	assert.EqualValues(t, 10, status.Slot)
+	assert.EqualValues(t, 2, status.Confirmations)
+	assert.Equal(t, ErrBadNonce, status.TxError)
+}
+
 func TestInternal_SignTransaction(t *testing.T) {
 	env, cleanup := setup(t)
 	defer cleanup()
@@ -627,7 +681,8 @@ func TestInternal_SubmitSolanaTransaction(t *testing.T) {
 	env.v4Server.Mux.Unlock()
 
 	result, err = env.internal.SubmitSolanaTransaction(context.Background(), tx, nil, commonpbv4.Commitment_SINGLE, nil)
-	assert.Equal(t, ErrAlreadySubmitted, err)
+	assert.Equal(t, ErrAlreadySubmitted, errors.Cause(err))
+	assert.Equal(t, &AlreadySubmittedError{TxID: txSig[:]}, err)
 
 	// Test already submitted received on second attempt
 	env.v4Server.SetError(errors.New("unexpected"), 1)
@@ -900,7 +955,7 @@ func TestInternal_ResolveTokenAccounts_WithInfo(t *testing.T) {
 	}
 }
 
-func setServiceConfigResp(t *testing.T, server *server, includeSubsidizer bool) (token, tokenProgram, subsidizer ed25519.PublicKey) {
+func setServiceConfigResp(t testing.TB, server *TestServer, includeSubsidizer bool) (token, tokenProgram, subsidizer ed25519.PublicKey) {
 	var err error
 	token, _, err = ed25519.GenerateKey(nil)
 	require.NoError(t, err)
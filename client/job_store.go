@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type memoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string][]byte
+}
+
+// NewMemoryJobStore returns an in-memory JobStore, intended for tests and
+// development.
+func NewMemoryJobStore() JobStore {
+	return &memoryJobStore{
+		jobs: make(map[string][]byte),
+	}
+}
+
+// Create implements JobStore.Create.
+func (m *memoryJobStore) Create(_ context.Context, job BatchJob) error {
+	// Jobs are stored encoded, so that callers cannot modify them in place.
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.jobs[job.ID]; ok {
+		return ErrJobExists
+	}
+	m.jobs[job.ID] = b
+	return nil
+}
+
+// Update implements JobStore.Update.
+func (m *memoryJobStore) Update(_ context.Context, job BatchJob) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.jobs[job.ID]; !ok {
+		return ErrJobNotFound
+	}
+	m.jobs[job.ID] = b
+	return nil
+}
+
+// Load implements JobStore.Load.
+func (m *memoryJobStore) Load(_ context.Context, id string) (job BatchJob, err error) {
+	m.mu.Lock()
+	b, ok := m.jobs[id]
+	m.mu.Unlock()
+
+	if !ok {
+		return job, ErrJobNotFound
+	}
+	return job, json.Unmarshal(b, &job)
+}
+
+type fileJobStore struct {
+	dir string
+
+	// mu serializes updates, so that concurrent updates of a job are not
+	// interleaved.
+	mu sync.Mutex
+}
+
+// NewFileJobStore returns a JobStore that stores each job as a JSON file in
+// dir, which must exist. Jobs are written atomically, so a job is never left
+// partially written if the process dies.
+func NewFileJobStore(dir string) JobStore {
+	return &fileJobStore{dir: dir}
+}
+
+// Create implements JobStore.Create.
+func (f *fileJobStore) Create(_ context.Context, job BatchJob) error {
+	tmp, err := f.writeTemp(job)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	// Linking fails if the job exists, unlike renaming.
+	if err := os.Link(tmp, f.path(job.ID)); err != nil {
+		if os.IsExist(err) {
+			return ErrJobExists
+		}
+		return errors.Wrap(err, "failed to create job")
+	}
+	return nil
+}
+
+// Update implements JobStore.Update.
+func (f *fileJobStore) Update(_ context.Context, job BatchJob) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := os.Stat(f.path(job.ID)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrJobNotFound
+		}
+		return errors.Wrap(err, "failed to stat job")
+	}
+
+	tmp, err := f.writeTemp(job)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.path(job.ID)); err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "failed to update job")
+	}
+	return nil
+}
+
+// Load implements JobStore.Load.
+func (f *fileJobStore) Load(_ context.Context, id string) (job BatchJob, err error) {
+	b, err := ioutil.ReadFile(f.path(id))
+	if os.IsNotExist(err) {
+		return job, ErrJobNotFound
+	} else if err != nil {
+		return job, errors.Wrap(err, "failed to read job")
+	}
+
+	return job, errors.Wrap(json.Unmarshal(b, &job), "failed to decode job")
+}
+
+// path returns the path of the file of a job. IDs are hex encoded, since they
+// may contain characters that are not valid in file names.
+func (f *fileJobStore) path(id string) string {
+	return filepath.Join(f.dir, "job-"+hex.EncodeToString([]byte(id))+".json")
+}
+
+func (f *fileJobStore) writeTemp(job BatchJob) (string, error) {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode job")
+	}
+
+	tmp, err := ioutil.TempFile(f.dir, ".job-*")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temporary file")
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", errors.Wrap(err, "failed to write job")
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", errors.Wrap(err, "failed to sync job")
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", errors.Wrap(err, "failed to write job")
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+)
+
+func TestJobStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, store := range map[string]JobStore{
+		"memory": NewMemoryJobStore(),
+		"file":   NewFileJobStore(dir),
+	} {
+		t.Run(name, func(t *testing.T) {
+			testJobStore(t, store)
+		})
+	}
+}
+
+func testJobStore(t *testing.T, store JobStore) {
+	now := time.Unix(100, 0).UTC()
+	job := BatchJob{
+		ID:       "payout/2021-01-01",
+		Sender:   goldenKey("sender").Public(),
+		Memo:     "payout",
+		DedupeID: []byte("dedupe"),
+		Earns: []Earn{
+			{Destination: goldenKey("a").Public(), Quarks: 10},
+			{
+				Destination: goldenKey("b").Public(),
+				Quarks:      20,
+				Invoice: &commonpb.Invoice{
+					Items: []*commonpb.Invoice_LineItem{{Title: "bonus", Amount: 20, Sku: []byte("sku")}},
+				},
+			},
+		},
+		Chunks: []BatchJobChunk{
+			{Start: 0, End: 2, DedupeID: []byte("chunk")},
+		},
+		Created: now,
+		Updated: now,
+	}
+
+	_, err := store.Load(context.Background(), job.ID)
+	assert.Equal(t, ErrJobNotFound, err)
+	assert.Equal(t, ErrJobNotFound, store.Update(context.Background(), job))
+
+	require.NoError(t, store.Create(context.Background(), job))
+	assert.Equal(t, ErrJobExists, store.Create(context.Background(), job))
+
+	loaded, err := store.Load(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, job, loaded)
+
+	job.Chunks[0] = BatchJobChunk{
+		Start:    0,
+		End:      2,
+		Status:   BatchJobChunkFailed,
+		Attempt:  1,
+		DedupeID: []byte("retry"),
+		Result: &EarnBatchResult{
+			TxID:       []byte("txid"),
+			TxError:    ErrInsufficientBalance,
+			EarnErrors: []EarnError{{EarnIndex: 1, Error: ErrInsufficientBalance}},
+		},
+	}
+	job.Updated = now.Add(time.Minute)
+	require.NoError(t, store.Update(context.Background(), job))
+
+	loaded, err = store.Load(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, job, loaded)
+}
@@ -0,0 +1,686 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+)
+
+// The JSON encodings of ReadOnlyPayment, TransactionData, HistoryItem,
+// EarnBatchResult, EarnChunkResult, BatchJob and Approval form a stable schema,
+// suitable for storing and exchanging results between services:
+//
+//   - Keys and transaction IDs are base58 encoded strings.
+//   - Quark (and lamport) amounts are decimal strings, so that they are not
+//     truncated by decoders that represent numbers as floats.
+//   - Transaction types ("none", "earn", "spend", "p2p", "unknown"), transaction
+//     states ("unknown", "success", "failed", "pending") and commitments
+//     ("recent", "single", "root", "max") are lower case strings.
+//   - Byte strings (such as SKUs, cursors and raw transactions) are base64
+//     encoded, and times are RFC 3339 encoded.
+//   - Errors are encoded as their messages. When decoding, messages of the
+//     errors exported by this package (such as ErrInsufficientBalance) are
+//     decoded to those errors, and all other messages to new errors.
+//
+// Fields may be added to the schema, but existing fields will not be renamed
+// or change type. Optional fields are omitted when unset.
+
+type jsonPayment struct {
+	Sender      string       `json:"sender"`
+	Destination string       `json:"destination"`
+	Type        string       `json:"type"`
+	Quarks      string       `json:"quarks"`
+	AppIndex    uint16       `json:"app_index,omitempty"`
+	Memo        string       `json:"memo,omitempty"`
+	Invoice     *jsonInvoice `json:"invoice,omitempty"`
+}
+
+type jsonInvoice struct {
+	Items []jsonLineItem `json:"items"`
+}
+
+type jsonLineItem struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Amount      string `json:"amount"`
+	SKU         []byte `json:"sku,omitempty"`
+}
+
+type jsonTransactionData struct {
+	TxID        string                 `json:"tx_id"`
+	State       string                 `json:"state"`
+	Payments    []ReadOnlyPayment      `json:"payments"`
+	Errors      *jsonTransactionErrors `json:"errors,omitempty"`
+	Slot        uint64                 `json:"slot,omitempty"`
+	Commitment  string                 `json:"commitment,omitempty"`
+	BlockTime   *time.Time             `json:"block_time,omitempty"`
+	FeePayer    string                 `json:"fee_payer,omitempty"`
+	FeeLamports string                 `json:"fee_lamports,omitempty"`
+	Raw         *jsonRawTransaction    `json:"raw_transaction,omitempty"`
+}
+
+type jsonTransactionErrors struct {
+	TxError       string    `json:"tx_error,omitempty"`
+	OpErrors      []*string `json:"op_errors,omitempty"`
+	PaymentErrors []*string `json:"payment_errors,omitempty"`
+}
+
+type jsonRawTransaction struct {
+	Solana          []byte `json:"solana,omitempty"`
+	StellarEnvelope []byte `json:"stellar_envelope,omitempty"`
+	StellarResult   []byte `json:"stellar_result,omitempty"`
+}
+
+type jsonHistoryItem struct {
+	jsonTransactionData
+	Cursor []byte     `json:"cursor,omitempty"`
+	Time   *time.Time `json:"time,omitempty"`
+}
+
+type jsonEarnBatchResult struct {
+	TxID       string          `json:"tx_id"`
+	TxError    string          `json:"tx_error,omitempty"`
+	EarnErrors []jsonEarnError `json:"earn_errors,omitempty"`
+}
+
+type jsonEarnError struct {
+	EarnIndex int    `json:"earn_index"`
+	Error     string `json:"error"`
+}
+
+type jsonEarnChunkResult struct {
+	jsonEarnBatchResult
+	Chunk int    `json:"chunk"`
+	Err   string `json:"error,omitempty"`
+}
+
+type jsonBatchJob struct {
+	ID       string              `json:"id"`
+	Sender   string              `json:"sender"`
+	Memo     string              `json:"memo,omitempty"`
+	Mint     string              `json:"mint,omitempty"`
+	DedupeID []byte              `json:"dedupe_id"`
+	Earns    []jsonEarn          `json:"earns"`
+	Chunks   []jsonBatchJobChunk `json:"chunks"`
+	Created  time.Time           `json:"created"`
+	Updated  time.Time           `json:"updated"`
+}
+
+type jsonEarn struct {
+	Destination string       `json:"destination"`
+	Quarks      string       `json:"quarks"`
+	Invoice     *jsonInvoice `json:"invoice,omitempty"`
+}
+
+type jsonBatchJobChunk struct {
+	Start    int              `json:"start"`
+	End      int              `json:"end"`
+	Status   string           `json:"status"`
+	Attempt  int              `json:"attempt,omitempty"`
+	DedupeID []byte           `json:"dedupe_id"`
+	Result   *EarnBatchResult `json:"result,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+var batchJobChunkStatusNames = map[BatchJobChunkStatus]string{
+	BatchJobChunkPending:    "pending",
+	BatchJobChunkSubmitting: "submitting",
+	BatchJobChunkSucceeded:  "succeeded",
+	BatchJobChunkFailed:     "failed",
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p ReadOnlyPayment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(paymentToJSON(p))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *ReadOnlyPayment) UnmarshalJSON(b []byte) error {
+	var j jsonPayment
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	payment, err := paymentFromJSON(j)
+	if err != nil {
+		return err
+	}
+	*p = payment
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t TransactionData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(transactionDataToJSON(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *TransactionData) UnmarshalJSON(b []byte) error {
+	var j jsonTransactionData
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	data, err := transactionDataFromJSON(j)
+	if err != nil {
+		return err
+	}
+	*t = data
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (h HistoryItem) MarshalJSON() ([]byte, error) {
+	j := jsonHistoryItem{
+		jsonTransactionData: transactionDataToJSON(h.TransactionData),
+		Cursor:              h.Cursor,
+	}
+	if !h.Time.IsZero() {
+		t := h.Time.UTC()
+		j.Time = &t
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (h *HistoryItem) UnmarshalJSON(b []byte) error {
+	var j jsonHistoryItem
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	data, err := transactionDataFromJSON(j.jsonTransactionData)
+	if err != nil {
+		return err
+	}
+	*h = HistoryItem{TransactionData: data, Cursor: j.Cursor}
+	if j.Time != nil {
+		h.Time = *j.Time
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r EarnBatchResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(earnBatchResultToJSON(r))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *EarnBatchResult) UnmarshalJSON(b []byte) error {
+	var j jsonEarnBatchResult
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	result, err := earnBatchResultFromJSON(j)
+	if err != nil {
+		return err
+	}
+	*r = result
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r EarnChunkResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonEarnChunkResult{
+		jsonEarnBatchResult: earnBatchResultToJSON(r.EarnBatchResult),
+		Chunk:               r.Chunk,
+		Err:                 errorToJSON(r.Err),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *EarnChunkResult) UnmarshalJSON(b []byte) error {
+	var j jsonEarnChunkResult
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	result, err := earnBatchResultFromJSON(j.jsonEarnBatchResult)
+	if err != nil {
+		return err
+	}
+	*r = EarnChunkResult{
+		Chunk:           j.Chunk,
+		EarnBatchResult: result,
+		Err:             errorFromJSON(j.Err),
+	}
+	return nil
+}
+
+func paymentToJSON(p ReadOnlyPayment) jsonPayment {
+	j := jsonPayment{
+		Sender:      keyToJSON(p.Sender),
+		Destination: keyToJSON(p.Destination),
+		Type:        transactionTypeName(p.Type),
+		Quarks:      strconv.FormatInt(p.Quarks, 10),
+		AppIndex:    p.AppIndex,
+		Memo:        p.Memo,
+	}
+	j.Invoice = invoiceToJSON(p.Invoice)
+	return j
+}
+
+func paymentFromJSON(j jsonPayment) (p ReadOnlyPayment, err error) {
+	if p.Sender, err = keyFromJSON(j.Sender); err != nil {
+		return p, errors.Wrap(err, "invalid sender")
+	}
+	if p.Destination, err = keyFromJSON(j.Destination); err != nil {
+		return p, errors.Wrap(err, "invalid destination")
+	}
+	if p.Type, err = transactionTypeFromName(j.Type); err != nil {
+		return p, err
+	}
+	if p.Quarks, err = strconv.ParseInt(j.Quarks, 10, 64); err != nil {
+		return p, errors.Wrap(err, "invalid quarks")
+	}
+	p.AppIndex = j.AppIndex
+	p.Memo = j.Memo
+
+	if p.Invoice, err = invoiceFromJSON(j.Invoice); err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+func invoiceToJSON(invoice *commonpb.Invoice) *jsonInvoice {
+	if invoice == nil {
+		return nil
+	}
+
+	j := &jsonInvoice{Items: make([]jsonLineItem, len(invoice.Items))}
+	for i, item := range invoice.Items {
+		j.Items[i] = jsonLineItem{
+			Title:       item.Title,
+			Description: item.Description,
+			Amount:      strconv.FormatInt(item.Amount, 10),
+			SKU:         item.Sku,
+		}
+	}
+	return j
+}
+
+func invoiceFromJSON(j *jsonInvoice) (*commonpb.Invoice, error) {
+	if j == nil {
+		return nil, nil
+	}
+
+	invoice := &commonpb.Invoice{Items: make([]*commonpb.Invoice_LineItem, len(j.Items))}
+	for i, item := range j.Items {
+		amount, err := strconv.ParseInt(item.Amount, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid amount for line item %d", i)
+		}
+		invoice.Items[i] = &commonpb.Invoice_LineItem{
+			Title:       item.Title,
+			Description: item.Description,
+			Amount:      amount,
+			Sku:         item.SKU,
+		}
+	}
+	return invoice, nil
+}
+
+func transactionDataToJSON(t TransactionData) jsonTransactionData {
+	j := jsonTransactionData{
+		TxID:     base58.Encode(t.TxID),
+		State:    transactionStateName(t.TxState),
+		Payments: t.Payments,
+		Errors:   transactionErrorsToJSON(t.Errors),
+		Slot:     t.Slot,
+		FeePayer: keyToJSON(t.FeePayer),
+	}
+	if j.Payments == nil {
+		j.Payments = []ReadOnlyPayment{}
+	}
+	if t.Commitment != commonpbv4.Commitment_RECENT {
+		j.Commitment = strings.ToLower(t.Commitment.String())
+	}
+	if !t.BlockTime.IsZero() {
+		bt := t.BlockTime.UTC()
+		j.BlockTime = &bt
+	}
+	if t.FeeLamports > 0 {
+		j.FeeLamports = strconv.FormatUint(t.FeeLamports, 10)
+	}
+	if t.RawTransaction != nil {
+		j.Raw = &jsonRawTransaction{
+			Solana:          t.RawTransaction.Solana,
+			StellarEnvelope: t.RawTransaction.StellarEnvelope,
+			StellarResult:   t.RawTransaction.StellarResult,
+		}
+	}
+	return j
+}
+
+func transactionDataFromJSON(j jsonTransactionData) (t TransactionData, err error) {
+	if t.TxID, err = base58.Decode(j.TxID); err != nil {
+		return t, errors.Wrap(err, "invalid tx_id")
+	}
+	if t.TxState, err = transactionStateFromName(j.State); err != nil {
+		return t, err
+	}
+	if len(j.Payments) > 0 {
+		t.Payments = j.Payments
+	}
+	t.Errors = transactionErrorsFromJSON(j.Errors)
+	t.Slot = j.Slot
+
+	if j.Commitment != "" {
+		c, ok := commonpbv4.Commitment_value[strings.ToUpper(j.Commitment)]
+		if !ok {
+			return t, errors.Errorf("unknown commitment: %s", j.Commitment)
+		}
+		t.Commitment = commonpbv4.Commitment(c)
+	}
+	if j.BlockTime != nil {
+		t.BlockTime = *j.BlockTime
+	}
+	if t.FeePayer, err = keyFromJSON(j.FeePayer); err != nil {
+		return t, errors.Wrap(err, "invalid fee_payer")
+	}
+	if j.FeeLamports != "" {
+		if t.FeeLamports, err = strconv.ParseUint(j.FeeLamports, 10, 64); err != nil {
+			return t, errors.Wrap(err, "invalid fee_lamports")
+		}
+	}
+	if j.Raw != nil {
+		t.RawTransaction = &RawTransaction{
+			Solana:          j.Raw.Solana,
+			StellarEnvelope: j.Raw.StellarEnvelope,
+			StellarResult:   j.Raw.StellarResult,
+		}
+	}
+
+	return t, nil
+}
+
+func transactionErrorsToJSON(e TransactionErrors) *jsonTransactionErrors {
+	if e.TxError == nil && len(e.OpErrors) == 0 && len(e.PaymentErrors) == 0 {
+		return nil
+	}
+
+	return &jsonTransactionErrors{
+		TxError:       errorToJSON(e.TxError),
+		OpErrors:      errorsToJSON(e.OpErrors),
+		PaymentErrors: errorsToJSON(e.PaymentErrors),
+	}
+}
+
+func transactionErrorsFromJSON(j *jsonTransactionErrors) TransactionErrors {
+	if j == nil {
+		return TransactionErrors{}
+	}
+
+	return TransactionErrors{
+		TxError:       errorFromJSON(j.TxError),
+		OpErrors:      errorsFromJSON(j.OpErrors),
+		PaymentErrors: errorsFromJSON(j.PaymentErrors),
+	}
+}
+
+func earnBatchResultToJSON(r EarnBatchResult) jsonEarnBatchResult {
+	j := jsonEarnBatchResult{
+		TxID:    base58.Encode(r.TxID),
+		TxError: errorToJSON(r.TxError),
+	}
+	for _, e := range r.EarnErrors {
+		j.EarnErrors = append(j.EarnErrors, jsonEarnError{EarnIndex: e.EarnIndex, Error: errorToJSON(e.Error)})
+	}
+	return j
+}
+
+func earnBatchResultFromJSON(j jsonEarnBatchResult) (r EarnBatchResult, err error) {
+	if r.TxID, err = base58.Decode(j.TxID); err != nil {
+		return r, errors.Wrap(err, "invalid tx_id")
+	}
+	r.TxError = errorFromJSON(j.TxError)
+	for _, e := range j.EarnErrors {
+		r.EarnErrors = append(r.EarnErrors, EarnError{EarnIndex: e.EarnIndex, Error: errorFromJSON(e.Error)})
+	}
+	return r, nil
+}
+
+func keyToJSON(k kin.PublicKey) string {
+	if len(k) == 0 {
+		return ""
+	}
+	return k.Base58()
+}
+
+func keyFromJSON(s string) (kin.PublicKey, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	b, err := base58.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, errors.Errorf("invalid key length: %d", len(b))
+	}
+	return kin.PublicKey(b), nil
+}
+
+func transactionTypeFromName(name string) (kin.TransactionType, error) {
+	switch name {
+	case "none":
+		return kin.TransactionTypeNone, nil
+	case "earn":
+		return kin.TransactionTypeEarn, nil
+	case "spend":
+		return kin.TransactionTypeSpend, nil
+	case "p2p":
+		return kin.TransactionTypeP2P, nil
+	case "unknown":
+		return kin.TransactionTypeUnknown, nil
+	default:
+		return kin.TransactionTypeUnknown, errors.Errorf("unknown transaction type: %s", name)
+	}
+}
+
+func transactionStateName(s TransactionState) string {
+	switch s {
+	case TransactionStateSuccess:
+		return "success"
+	case TransactionStateFailed:
+		return "failed"
+	case TransactionStatePending:
+		return "pending"
+	default:
+		return "unknown"
+	}
+}
+
+func transactionStateFromName(name string) (TransactionState, error) {
+	switch name {
+	case "unknown":
+		return TransactionStateUnknown, nil
+	case "success":
+		return TransactionStateSuccess, nil
+	case "failed":
+		return TransactionStateFailed, nil
+	case "pending":
+		return TransactionStatePending, nil
+	default:
+		return TransactionStateUnknown, errors.Errorf("unknown transaction state: %s", name)
+	}
+}
+
+// jsonErrors are the errors that are decoded from their messages.
+var jsonErrors = []error{
+	ErrAccountExists,
+	ErrAccountDoesNotExist,
+	ErrTransactionNotFound,
+	ErrBadNonce,
+	ErrBlockhashNotFound,
+	ErrInsufficientBalance,
+	ErrInvalidSignature,
+	ErrAlreadyPaid,
+	ErrWrongDestination,
+	ErrSKUNotFound,
+	ErrNoSubsidizer,
+	ErrPayerRequired,
+	ErrTransactionRejected,
+	ErrAlreadySubmitted,
+	ErrBlockchainVersion,
+	ErrLimitExceeded,
+	ErrPaymentScreened,
+	ErrEarnBatchAborted,
+	ErrInvalidAmount,
+	ErrTransactionTooLarge,
+}
+
+func errorToJSON(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func errorFromJSON(msg string) error {
+	if msg == "" {
+		return nil
+	}
+	for _, err := range jsonErrors {
+		if err.Error() == msg {
+			return err
+		}
+	}
+	return errors.New(msg)
+}
+
+func errorsToJSON(errs []error) []*string {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msgs := make([]*string, len(errs))
+	for i, err := range errs {
+		if err != nil {
+			msg := err.Error()
+			msgs[i] = &msg
+		}
+	}
+	return msgs
+}
+
+func errorsFromJSON(msgs []*string) []error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(msgs))
+	for i, msg := range msgs {
+		if msg != nil {
+			errs[i] = errorFromJSON(*msg)
+		}
+	}
+	return errs
+}
+
+// MarshalJSON implements json.Marshaler.
+func (j BatchJob) MarshalJSON() ([]byte, error) {
+	encoded := jsonBatchJob{
+		ID:       j.ID,
+		Sender:   keyToJSON(j.Sender),
+		Memo:     j.Memo,
+		Mint:     keyToJSON(j.Mint),
+		DedupeID: j.DedupeID,
+		Earns:    make([]jsonEarn, len(j.Earns)),
+		Chunks:   make([]jsonBatchJobChunk, len(j.Chunks)),
+		Created:  j.Created,
+		Updated:  j.Updated,
+	}
+	for i, e := range j.Earns {
+		encoded.Earns[i] = jsonEarn{
+			Destination: keyToJSON(e.Destination),
+			Quarks:      strconv.FormatInt(e.Quarks, 10),
+			Invoice:     invoiceToJSON(e.Invoice),
+		}
+	}
+	for i, c := range j.Chunks {
+		encoded.Chunks[i] = jsonBatchJobChunk{
+			Start:    c.Start,
+			End:      c.End,
+			Status:   batchJobChunkStatusNames[c.Status],
+			Attempt:  c.Attempt,
+			DedupeID: c.DedupeID,
+			Result:   c.Result,
+			Error:    c.Error,
+		}
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *BatchJob) UnmarshalJSON(b []byte) error {
+	var encoded jsonBatchJob
+	if err := json.Unmarshal(b, &encoded); err != nil {
+		return err
+	}
+
+	job := BatchJob{
+		ID:       encoded.ID,
+		Memo:     encoded.Memo,
+		DedupeID: encoded.DedupeID,
+		Earns:    make([]Earn, len(encoded.Earns)),
+		Chunks:   make([]BatchJobChunk, len(encoded.Chunks)),
+		Created:  encoded.Created,
+		Updated:  encoded.Updated,
+	}
+
+	var err error
+	if job.Sender, err = keyFromJSON(encoded.Sender); err != nil {
+		return errors.Wrap(err, "invalid sender")
+	}
+	if job.Mint, err = keyFromJSON(encoded.Mint); err != nil {
+		return errors.Wrap(err, "invalid mint")
+	}
+	for i, e := range encoded.Earns {
+		if job.Earns[i].Destination, err = keyFromJSON(e.Destination); err != nil {
+			return errors.Wrapf(err, "invalid destination for earn %d", i)
+		}
+		if job.Earns[i].Quarks, err = strconv.ParseInt(e.Quarks, 10, 64); err != nil {
+			return errors.Wrapf(err, "invalid quarks for earn %d", i)
+		}
+		if job.Earns[i].Invoice, err = invoiceFromJSON(e.Invoice); err != nil {
+			return errors.Wrapf(err, "invalid invoice for earn %d", i)
+		}
+	}
+	for i, c := range encoded.Chunks {
+		status, ok := BatchJobChunkStatus(-1), false
+		for s, name := range batchJobChunkStatusNames {
+			if name == c.Status {
+				status, ok = s, true
+			}
+		}
+		if !ok {
+			return errors.Errorf("unknown chunk status: %s", c.Status)
+		}
+
+		job.Chunks[i] = BatchJobChunk{
+			Start:    c.Start,
+			End:      c.End,
+			Status:   status,
+			Attempt:  c.Attempt,
+			DedupeID: c.DedupeID,
+			Result:   c.Result,
+			Error:    c.Error,
+		}
+	}
+
+	*j = job
+	return nil
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+)
+
+func TestReadOnlyPayment_JSON(t *testing.T) {
+	sender := goldenKey("sender").Public()
+	dest := goldenKey("destination").Public()
+
+	p := ReadOnlyPayment{
+		Sender:      sender,
+		Destination: dest,
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      MaxSupplyQuarks,
+		AppIndex:    10,
+		Invoice: &commonpb.Invoice{
+			Items: []*commonpb.Invoice_LineItem{
+				{Title: "item", Amount: 5, Sku: []byte("sku")},
+			},
+		},
+	}
+
+	b, err := json.Marshal(p)
+	require.NoError(t, err)
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &schema))
+	assert.Equal(t, map[string]interface{}{
+		"sender":      sender.Base58(),
+		"destination": dest.Base58(),
+		"type":        "spend",
+		"quarks":      "1000000000000000000",
+		"app_index":   float64(10),
+		"invoice": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"title": "item", "amount": "5", "sku": "c2t1"},
+			},
+		},
+	}, schema)
+
+	var decoded ReadOnlyPayment
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, p.Sender, decoded.Sender)
+	assert.Equal(t, p.Destination, decoded.Destination)
+	assert.Equal(t, p.Type, decoded.Type)
+	assert.Equal(t, p.Quarks, decoded.Quarks)
+	assert.Equal(t, p.AppIndex, decoded.AppIndex)
+	assert.Equal(t, p.Invoice.Items[0].Title, decoded.Invoice.Items[0].Title)
+	assert.Equal(t, p.Invoice.Items[0].Amount, decoded.Invoice.Items[0].Amount)
+	assert.Equal(t, p.Invoice.Items[0].Sku, decoded.Invoice.Items[0].Sku)
+
+	for _, invalid := range []string{
+		`{"sender": "invalid", "destination": "", "type": "spend", "quarks": "1"}`,
+		`{"sender": "1111", "type": "spend", "quarks": "1"}`,
+		`{"sender": "", "destination": "", "type": "invalid", "quarks": "1"}`,
+		`{"sender": "", "destination": "", "type": "spend", "quarks": 1}`,
+		`{"sender": "", "destination": "", "type": "spend", "quarks": "1.5"}`,
+	} {
+		assert.Error(t, json.Unmarshal([]byte(invalid), &decoded), invalid)
+	}
+}
+
+func TestTransactionData_JSON(t *testing.T) {
+	data := TransactionData{
+		TxID:    []byte("txid"),
+		TxState: TransactionStateFailed,
+		Payments: []ReadOnlyPayment{
+			{
+				Sender:      goldenKey("sender").Public(),
+				Destination: goldenKey("destination").Public(),
+				Type:        kin.TransactionTypeP2P,
+				Quarks:      10,
+				Memo:        "1-test",
+			},
+		},
+		Errors: TransactionErrors{
+			TxError:       ErrInsufficientBalance,
+			OpErrors:      []error{nil, ErrInsufficientBalance},
+			PaymentErrors: []error{errors.New("custom")},
+		},
+		Slot:        20,
+		Commitment:  commonpbv4.Commitment_MAX,
+		BlockTime:   time.Unix(100, 0).UTC(),
+		FeePayer:    goldenKey("subsidizer").Public(),
+		FeeLamports: 5000,
+		RawTransaction: &RawTransaction{
+			Solana: []byte("raw"),
+		},
+	}
+
+	b, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &schema))
+	assert.Equal(t, "3yg1Vu", schema["tx_id"])
+	assert.Equal(t, "failed", schema["state"])
+	assert.Equal(t, "max", schema["commitment"])
+	assert.Equal(t, "1970-01-01T00:01:40Z", schema["block_time"])
+	assert.Equal(t, "5000", schema["fee_lamports"])
+	assert.Equal(t, map[string]interface{}{
+		"tx_error":       "insufficient balance",
+		"op_errors":      []interface{}{nil, "insufficient balance"},
+		"payment_errors": []interface{}{"custom"},
+	}, schema["errors"])
+
+	var decoded TransactionData
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, data.TxID, decoded.TxID)
+	assert.Equal(t, data.TxState, decoded.TxState)
+	assert.Equal(t, data.Payments, decoded.Payments)
+	assert.Equal(t, ErrInsufficientBalance, decoded.Errors.TxError)
+	assert.Equal(t, []error{nil, ErrInsufficientBalance}, decoded.Errors.OpErrors)
+	assert.EqualError(t, decoded.Errors.PaymentErrors[0], "custom")
+	assert.Equal(t, data.Slot, decoded.Slot)
+	assert.Equal(t, data.Commitment, decoded.Commitment)
+	assert.True(t, data.BlockTime.Equal(decoded.BlockTime))
+	assert.Equal(t, data.FeePayer, decoded.FeePayer)
+	assert.Equal(t, data.FeeLamports, decoded.FeeLamports)
+	assert.Equal(t, data.RawTransaction, decoded.RawTransaction)
+
+	// Unset optional fields are omitted, and round trip.
+	b, err = json.Marshal(TransactionData{TxID: []byte("txid")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"tx_id": "3yg1Vu", "state": "unknown", "payments": []}`, string(b))
+
+	decoded = TransactionData{}
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, TransactionData{TxID: []byte("txid")}, decoded)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"tx_id": "3yg1Vu", "state": "invalid"}`), &decoded))
+	assert.Error(t, json.Unmarshal([]byte(`{"tx_id": "3yg1Vu", "state": "success", "commitment": "invalid"}`), &decoded))
+}
+
+func TestHistoryItem_JSON(t *testing.T) {
+	item := HistoryItem{
+		TransactionData: TransactionData{TxID: []byte("txid"), TxState: TransactionStateSuccess},
+		Cursor:          []byte("cursor"),
+		Time:            time.Unix(200, 0).UTC(),
+	}
+
+	b, err := json.Marshal(item)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"tx_id": "3yg1Vu",
+		"state": "success",
+		"payments": [],
+		"cursor": "Y3Vyc29y",
+		"time": "1970-01-01T00:03:20Z"
+	}`, string(b))
+
+	var decoded HistoryItem
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, item.TransactionData, decoded.TransactionData)
+	assert.Equal(t, item.Cursor, decoded.Cursor)
+	assert.True(t, item.Time.Equal(decoded.Time))
+}
+
+func TestEarnBatchResult_JSON(t *testing.T) {
+	result := EarnBatchResult{
+		TxID:    []byte("txid"),
+		TxError: ErrInvalidSignature,
+		EarnErrors: []EarnError{
+			{EarnIndex: 2, Error: ErrAccountDoesNotExist},
+		},
+	}
+
+	b, err := json.Marshal(result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"tx_id": "3yg1Vu",
+		"tx_error": "invalid signature",
+		"earn_errors": [{"earn_index": 2, "error": "account does not exist"}]
+	}`, string(b))
+
+	var decoded EarnBatchResult
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, result, decoded)
+
+	chunk := EarnChunkResult{
+		Chunk:           1,
+		EarnBatchResult: EarnBatchResult{TxID: []byte("txid")},
+		Err:             ErrEarnBatchAborted,
+	}
+	b, err = json.Marshal(chunk)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"tx_id": "3yg1Vu", "chunk": 1, "error": "earn batch aborted"}`, string(b))
+
+	var decodedChunk EarnChunkResult
+	require.NoError(t, json.Unmarshal(b, &decodedChunk))
+	assert.Equal(t, chunk, decodedChunk)
+}
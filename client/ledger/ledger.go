@@ -0,0 +1,304 @@
+// Package ledger implements a double-entry ledger that is kept in sync with
+// payments submitted through the client, allowing apps to maintain an
+// off-chain view of balances.
+//
+// Each ledger entry is keyed by the DedupeID of the transaction it records.
+// An entry is prepared (as pending) before the transaction is submitted, and
+// is then either committed or voided depending on the outcome. Since Agora
+// will not submit two transactions with the same DedupeID, a submission that
+// fails with an unknown outcome can be safely retried, and the entry will be
+// resolved once the outcome is known.
+package ledger
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+var (
+	// ErrEntryExists is returned when preparing an entry whose ID is already in use.
+	ErrEntryExists = errors.New("entry already exists")
+
+	// ErrEntryNotFound is returned when an entry does not exist.
+	ErrEntryNotFound = errors.New("entry not found")
+
+	// ErrEntryFinalized is returned when committing a voided entry, or voiding a
+	// committed entry.
+	ErrEntryFinalized = errors.New("entry already finalized")
+
+	// ErrUnbalanced is returned when the postings of an entry do not sum to zero.
+	ErrUnbalanced = errors.New("entry postings must sum to zero")
+)
+
+// EntryStatus is the status of a ledger entry.
+type EntryStatus int
+
+const (
+	// EntryStatusPending indicates the transaction for the entry has not yet
+	// been confirmed.
+	EntryStatusPending EntryStatus = iota
+
+	// EntryStatusCommitted indicates the transaction for the entry succeeded.
+	EntryStatusCommitted
+
+	// EntryStatusVoided indicates the transaction for the entry failed, and
+	// the entry no longer affects balances.
+	EntryStatusVoided
+)
+
+// Posting is a change to the balance of a single ledger account.
+type Posting struct {
+	Account string
+
+	// Amount is the amount, in quarks. Positive amounts are credits, and
+	// negative amounts are debits.
+	Amount int64
+}
+
+// Entry is a set of postings that are applied together.
+type Entry struct {
+	// ID uniquely identifies the entry. It is the DedupeID of the
+	// transaction that the entry records.
+	ID []byte
+
+	Postings []Posting
+	Memo     string
+
+	Status EntryStatus
+
+	// TxID is the ID of the transaction, set once the entry is committed.
+	TxID []byte
+
+	Created time.Time
+}
+
+// Validate verifies that the entry has an ID, and that its postings balance.
+func (e Entry) Validate() error {
+	if len(e.ID) == 0 {
+		return errors.New("entry must have an ID")
+	}
+	if len(e.Postings) < 2 {
+		return errors.New("entry must have at least 2 postings")
+	}
+
+	var sum int64
+	for _, p := range e.Postings {
+		if p.Account == "" {
+			return errors.New("posting must have an account")
+		}
+		sum += p.Amount
+	}
+	if sum != 0 {
+		return ErrUnbalanced
+	}
+
+	return nil
+}
+
+// Balance is the balance of a ledger account, in quarks.
+type Balance struct {
+	// Committed is the sum of all committed postings.
+	Committed int64
+
+	// Pending is the sum of all pending postings.
+	Pending int64
+}
+
+// Available returns the committed balance less any pending debits, which
+// is the amount that can be safely spent.
+func (b Balance) Available() int64 {
+	if b.Pending < 0 {
+		return b.Committed + b.Pending
+	}
+	return b.Committed
+}
+
+// Ledger is a double-entry ledger.
+//
+// Implementations must apply each operation atomically.
+type Ledger interface {
+	// Prepare records a pending entry.
+	//
+	// ErrEntryExists is returned if an entry with the same ID already exists.
+	Prepare(ctx context.Context, entry Entry) error
+
+	// Commit marks a pending entry as committed. Committing an already
+	// committed entry has no effect.
+	//
+	// ErrEntryFinalized is returned if the entry was voided.
+	Commit(ctx context.Context, id, txID []byte) error
+
+	// Void marks a pending entry as voided. Voiding an already voided entry
+	// has no effect.
+	//
+	// ErrEntryFinalized is returned if the entry was committed.
+	Void(ctx context.Context, id []byte) error
+
+	// Get returns the entry with the specified ID.
+	//
+	// ErrEntryNotFound is returned if no entry exists.
+	Get(ctx context.Context, id []byte) (Entry, error)
+
+	// Balance returns the balance of an account. Accounts without any
+	// postings have a zero balance.
+	Balance(ctx context.Context, account string) (Balance, error)
+}
+
+// SubmitPayment submits a payment, recording it in the ledger as an entry that
+// debits debitAccount and credits creditAccount.
+//
+// The payment's DedupeID is used as the entry ID, and must be set. If the entry
+// was already committed, the payment is not resubmitted, and the recorded
+// transaction ID is returned.
+func SubmitPayment(ctx context.Context, c client.Client, l Ledger, payment client.Payment, debitAccount, creditAccount string, opts ...client.SolanaOption) ([]byte, error) {
+	entry := Entry{
+		ID: payment.DedupeID,
+		Postings: []Posting{
+			{Account: debitAccount, Amount: -payment.Quarks},
+			{Account: creditAccount, Amount: payment.Quarks},
+		},
+		Memo: payment.Memo,
+	}
+
+	return submit(ctx, l, entry, func() ([]byte, bool, error) {
+		txID, err := c.SubmitPayment(ctx, payment, opts...)
+		return txID, isFailure(err), err
+	})
+}
+
+// SubmitEarnBatch submits an earn batch, recording it in the ledger as a single
+// entry that debits debitAccount, and credits the account returned by
+// creditAccount for each earn.
+//
+// The batch's DedupeID is used as the entry ID, and must be set. If the entry
+// was already committed, the batch is not resubmitted, and the recorded
+// transaction ID is returned in the result.
+func SubmitEarnBatch(ctx context.Context, c client.Client, l Ledger, batch client.EarnBatch, debitAccount string, creditAccount func(i int, earn client.Earn) string, opts ...client.SolanaOption) (client.EarnBatchResult, error) {
+	entry := Entry{
+		ID:   batch.DedupeID,
+		Memo: batch.Memo,
+	}
+
+	var total int64
+	for i, e := range batch.Earns {
+		entry.Postings = append(entry.Postings, Posting{Account: creditAccount(i, e), Amount: e.Quarks})
+		total += e.Quarks
+	}
+	entry.Postings = append(entry.Postings, Posting{Account: debitAccount, Amount: -total})
+
+	var result client.EarnBatchResult
+	txID, err := submit(ctx, l, entry, func() ([]byte, bool, error) {
+		var err error
+		result, err = c.SubmitEarnBatch(ctx, batch, opts...)
+		if err != nil {
+			return result.TxID, isFailure(err), err
+		}
+		return result.TxID, result.TxError != nil, result.TxError
+	})
+	if result.TxID == nil {
+		result.TxID = txID
+	}
+	if err != nil && err == result.TxError {
+		// As with client.SubmitEarnBatch, transaction failures are only
+		// reported in the result.
+		err = nil
+	}
+
+	return result, err
+}
+
+// submit prepares the entry, and then calls f to submit the transaction. If f
+// indicates the transaction failed, the entry is voided. If the transaction was
+// already submitted, the entry is committed with the existing transaction. If f
+// returns any other error, the outcome is unknown and the entry is left pending.
+func submit(ctx context.Context, l Ledger, entry Entry, f func() (txID []byte, failed bool, err error)) ([]byte, error) {
+	if err := entry.Validate(); err != nil {
+		return nil, err
+	}
+
+	existing, err := l.Get(ctx, entry.ID)
+	switch err {
+	case nil:
+		switch existing.Status {
+		case EntryStatusCommitted:
+			return existing.TxID, nil
+		case EntryStatusVoided:
+			return nil, ErrEntryFinalized
+		}
+		// The previous attempt has an unknown outcome, so we resubmit. If the
+		// transaction was already submitted, the DedupeID prevents it from
+		// being submitted twice.
+	case ErrEntryNotFound:
+		entry.Status = EntryStatusPending
+		entry.TxID = nil
+		entry.Created = time.Now()
+		if err := l.Prepare(ctx, entry); err != nil {
+			return nil, errors.Wrap(err, "failed to prepare entry")
+		}
+	default:
+		return nil, errors.Wrap(err, "failed to get entry")
+	}
+
+	txID, failed, err := f()
+
+	var submitted *client.AlreadySubmittedError
+	if errors.As(err, &submitted) && len(submitted.TxID) > 0 {
+		txID, failed, err = submitted.TxID, false, nil
+	}
+
+	if failed {
+		if voidErr := l.Void(ctx, entry.ID); voidErr != nil {
+			return txID, errors.Wrap(voidErr, "failed to void entry")
+		}
+		return txID, err
+	}
+	if err != nil {
+		return txID, err
+	}
+
+	if err := l.Commit(ctx, entry.ID, txID); err != nil {
+		return txID, errors.Wrap(err, "failed to commit entry")
+	}
+
+	return txID, nil
+}
+
+// failures are errors indicating a transaction definitively failed, or was not
+// submitted at all.
+var failures = []error{
+	client.ErrAccountDoesNotExist,
+	client.ErrInsufficientBalance,
+	client.ErrInvalidSignature,
+	client.ErrAlreadyPaid,
+	client.ErrWrongDestination,
+	client.ErrSKUNotFound,
+	client.ErrNoSubsidizer,
+	client.ErrPayerRequired,
+	client.ErrTransactionRejected,
+	client.ErrLimitExceeded,
+	client.ErrPaymentScreened,
+	client.ErrInvalidAmount,
+	client.ErrTransactionTooLarge,
+}
+
+func isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var validationErr *client.BatchValidationError
+	if errors.As(err, &validationErr) {
+		return true
+	}
+
+	for _, f := range failures {
+		if errors.Is(err, f) {
+			return true
+		}
+	}
+	return false
+}
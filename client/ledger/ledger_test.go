@@ -0,0 +1,265 @@
+package ledger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+type fakeClient struct {
+	client.Client
+
+	paymentErr  error
+	batchResult client.EarnBatchResult
+	batchErr    error
+
+	payments []client.Payment
+	batches  []client.EarnBatch
+}
+
+func (f *fakeClient) SubmitPayment(_ context.Context, p client.Payment, _ ...client.SolanaOption) ([]byte, error) {
+	f.payments = append(f.payments, p)
+	return []byte("sig"), f.paymentErr
+}
+
+func (f *fakeClient) SubmitEarnBatch(_ context.Context, b client.EarnBatch, _ ...client.SolanaOption) (client.EarnBatchResult, error) {
+	f.batches = append(f.batches, b)
+	return f.batchResult, f.batchErr
+}
+
+func testLedger(t *testing.T, l Ledger) {
+	ctx := context.Background()
+
+	entry := Entry{
+		ID: []byte("entry-1"),
+		Postings: []Posting{
+			{Account: "treasury", Amount: -10},
+			{Account: "user-1", Amount: 10},
+		},
+		Memo: "memo",
+	}
+
+	unbalanced := entry
+	unbalanced.Postings = []Posting{{Account: "treasury", Amount: -10}, {Account: "user-1", Amount: 5}}
+	assert.Equal(t, ErrUnbalanced, l.Prepare(ctx, unbalanced))
+
+	_, err := l.Get(ctx, entry.ID)
+	assert.Equal(t, ErrEntryNotFound, err)
+	assert.Equal(t, ErrEntryNotFound, l.Commit(ctx, entry.ID, []byte("tx")))
+
+	require.NoError(t, l.Prepare(ctx, entry))
+	assert.Equal(t, ErrEntryExists, l.Prepare(ctx, entry))
+
+	stored, err := l.Get(ctx, entry.ID)
+	require.NoError(t, err)
+	assert.Equal(t, EntryStatusPending, stored.Status)
+	assert.Equal(t, "memo", stored.Memo)
+	assert.ElementsMatch(t, entry.Postings, stored.Postings)
+
+	b, err := l.Balance(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, Balance{Pending: 10}, b)
+	b, err = l.Balance(ctx, "treasury")
+	require.NoError(t, err)
+	assert.Equal(t, Balance{Pending: -10}, b)
+
+	require.NoError(t, l.Commit(ctx, entry.ID, []byte("tx")))
+	require.NoError(t, l.Commit(ctx, entry.ID, []byte("tx")))
+	assert.Equal(t, ErrEntryFinalized, l.Void(ctx, entry.ID))
+
+	stored, err = l.Get(ctx, entry.ID)
+	require.NoError(t, err)
+	assert.Equal(t, EntryStatusCommitted, stored.Status)
+	assert.Equal(t, []byte("tx"), stored.TxID)
+
+	voided := entry
+	voided.ID = []byte("entry-2")
+	require.NoError(t, l.Prepare(ctx, voided))
+	require.NoError(t, l.Void(ctx, voided.ID))
+	require.NoError(t, l.Void(ctx, voided.ID))
+	assert.Equal(t, ErrEntryFinalized, l.Commit(ctx, voided.ID, []byte("tx")))
+
+	b, err = l.Balance(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, Balance{Committed: 10}, b)
+	b, err = l.Balance(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Equal(t, Balance{}, b)
+}
+
+func TestMemoryLedger(t *testing.T) {
+	testLedger(t, NewMemoryLedger())
+}
+
+func TestBalance_Available(t *testing.T) {
+	assert.EqualValues(t, 5, Balance{Committed: 10, Pending: -5}.Available())
+	assert.EqualValues(t, 10, Balance{Committed: 10, Pending: 5}.Available())
+}
+
+func TestSubmitPayment(t *testing.T) {
+	ctx := context.Background()
+	l := NewMemoryLedger()
+	fc := &fakeClient{}
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	p := client.Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Quarks:      10,
+	}
+
+	_, err = SubmitPayment(ctx, fc, l, p, "treasury", "user-1")
+	assert.Error(t, err)
+	assert.Empty(t, fc.payments)
+
+	// An unknown outcome leaves the entry pending.
+	p.DedupeID = []byte("payment-1")
+	fc.paymentErr = errors.New("unavailable")
+	_, err = SubmitPayment(ctx, fc, l, p, "treasury", "user-1")
+	assert.Equal(t, fc.paymentErr, err)
+
+	b, err := l.Balance(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, Balance{Pending: 10}, b)
+
+	// Retrying resubmits, and commits the entry.
+	fc.paymentErr = nil
+	txID, err := SubmitPayment(ctx, fc, l, p, "treasury", "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("sig"), txID)
+	assert.Len(t, fc.payments, 2)
+
+	b, err = l.Balance(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, Balance{Committed: 10}, b)
+
+	// Once committed, the payment is not resubmitted.
+	txID, err = SubmitPayment(ctx, fc, l, p, "treasury", "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("sig"), txID)
+	assert.Len(t, fc.payments, 2)
+
+	// Failed payments void the entry.
+	p.DedupeID = []byte("payment-2")
+	fc.paymentErr = client.ErrInsufficientBalance
+	_, err = SubmitPayment(ctx, fc, l, p, "treasury", "user-1")
+	assert.Equal(t, client.ErrInsufficientBalance, err)
+
+	entry, err := l.Get(ctx, p.DedupeID)
+	require.NoError(t, err)
+	assert.Equal(t, EntryStatusVoided, entry.Status)
+
+	_, err = SubmitPayment(ctx, fc, l, p, "treasury", "user-1")
+	assert.Equal(t, ErrEntryFinalized, err)
+	assert.Len(t, fc.payments, 3)
+
+	// Payments rejected before submission also void the entry.
+	for i, rejectErr := range []error{
+		errors.Wrap(client.ErrInvalidAmount, "quarks must be positive"),
+		client.ErrTransactionTooLarge,
+	} {
+		p.DedupeID = []byte{byte(i)}
+		fc.paymentErr = rejectErr
+		_, err = SubmitPayment(ctx, fc, l, p, "treasury", "user-1")
+		assert.Equal(t, rejectErr, err)
+
+		entry, err := l.Get(ctx, p.DedupeID)
+		require.NoError(t, err)
+		assert.Equal(t, EntryStatusVoided, entry.Status)
+	}
+
+	// Payments that were already submitted commit the entry with the
+	// existing transaction.
+	p.DedupeID = []byte("payment-3")
+	fc.paymentErr = &client.AlreadySubmittedError{TxID: []byte("existing")}
+	txID, err = SubmitPayment(ctx, fc, l, p, "treasury", "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("existing"), txID)
+
+	entry, err = l.Get(ctx, p.DedupeID)
+	require.NoError(t, err)
+	assert.Equal(t, EntryStatusCommitted, entry.Status)
+	assert.Equal(t, []byte("existing"), entry.TxID)
+}
+
+func TestSubmitEarnBatch(t *testing.T) {
+	ctx := context.Background()
+	l := NewMemoryLedger()
+	fc := &fakeClient{
+		batchResult: client.EarnBatchResult{TxID: []byte("sig")},
+	}
+
+	batch := client.EarnBatch{
+		Earns: []client.Earn{
+			{Quarks: 10},
+			{Quarks: 20},
+		},
+		DedupeID: []byte("batch-1"),
+	}
+	credit := func(i int, _ client.Earn) string {
+		return []string{"user-1", "user-2"}[i]
+	}
+
+	result, err := SubmitEarnBatch(ctx, fc, l, batch, "treasury", credit)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("sig"), result.TxID)
+
+	for account, expected := range map[string]int64{"treasury": -30, "user-1": 10, "user-2": 20} {
+		b, err := l.Balance(ctx, account)
+		require.NoError(t, err)
+		assert.Equal(t, Balance{Committed: expected}, b)
+	}
+
+	// Once committed, the batch is not resubmitted.
+	result, err = SubmitEarnBatch(ctx, fc, l, batch, "treasury", credit)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("sig"), result.TxID)
+	assert.Len(t, fc.batches, 1)
+
+	// Transaction failures are reported in the result, and void the entry.
+	batch.DedupeID = []byte("batch-2")
+	fc.batchResult = client.EarnBatchResult{TxID: []byte("sig2"), TxError: client.ErrInsufficientBalance}
+	result, err = SubmitEarnBatch(ctx, fc, l, batch, "treasury", credit)
+	require.NoError(t, err)
+	assert.Equal(t, client.ErrInsufficientBalance, result.TxError)
+
+	entry, err := l.Get(ctx, batch.DedupeID)
+	require.NoError(t, err)
+	assert.Equal(t, EntryStatusVoided, entry.Status)
+
+	b, err := l.Balance(ctx, "treasury")
+	require.NoError(t, err)
+	assert.Equal(t, Balance{Committed: -30}, b)
+
+	// Invalid batches void the entry.
+	batch.DedupeID = []byte("batch-3")
+	fc.batchResult = client.EarnBatchResult{}
+	fc.batchErr = &client.BatchValidationError{Problems: []client.BatchValidationProblem{{Index: 0, Field: "Quarks", Reason: "must be positive"}}}
+	_, err = SubmitEarnBatch(ctx, fc, l, batch, "treasury", credit)
+	assert.Equal(t, fc.batchErr, err)
+
+	entry, err = l.Get(ctx, batch.DedupeID)
+	require.NoError(t, err)
+	assert.Equal(t, EntryStatusVoided, entry.Status)
+
+	// Batches that were already submitted commit the entry.
+	batch.DedupeID = []byte("batch-4")
+	fc.batchErr = &client.AlreadySubmittedError{TxID: []byte("existing")}
+	result, err = SubmitEarnBatch(ctx, fc, l, batch, "treasury", credit)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("existing"), result.TxID)
+
+	entry, err = l.Get(ctx, batch.DedupeID)
+	require.NoError(t, err)
+	assert.Equal(t, EntryStatusCommitted, entry.Status)
+}
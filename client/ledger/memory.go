@@ -0,0 +1,109 @@
+package ledger
+
+import (
+	"context"
+	"sync"
+)
+
+type memoryLedger struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryLedger returns an in-memory Ledger, intended for tests and
+// development.
+func NewMemoryLedger() Ledger {
+	return &memoryLedger{
+		entries: make(map[string]Entry),
+	}
+}
+
+// Prepare implements Ledger.Prepare.
+func (m *memoryLedger) Prepare(_ context.Context, entry Entry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.entries[string(entry.ID)]; ok {
+		return ErrEntryExists
+	}
+
+	entry.Status = EntryStatusPending
+	entry.TxID = nil
+	entry.Postings = append([]Posting(nil), entry.Postings...)
+	m.entries[string(entry.ID)] = entry
+	return nil
+}
+
+// Commit implements Ledger.Commit.
+func (m *memoryLedger) Commit(_ context.Context, id, txID []byte) error {
+	return m.finalize(id, EntryStatusCommitted, txID)
+}
+
+// Void implements Ledger.Void.
+func (m *memoryLedger) Void(_ context.Context, id []byte) error {
+	return m.finalize(id, EntryStatusVoided, nil)
+}
+
+func (m *memoryLedger) finalize(id []byte, status EntryStatus, txID []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[string(id)]
+	if !ok {
+		return ErrEntryNotFound
+	}
+
+	switch entry.Status {
+	case EntryStatusPending:
+		entry.Status = status
+		entry.TxID = append([]byte(nil), txID...)
+		m.entries[string(id)] = entry
+		return nil
+	case status:
+		return nil
+	default:
+		return ErrEntryFinalized
+	}
+}
+
+// Get implements Ledger.Get.
+func (m *memoryLedger) Get(_ context.Context, id []byte) (Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[string(id)]
+	if !ok {
+		return Entry{}, ErrEntryNotFound
+	}
+
+	entry.Postings = append([]Posting(nil), entry.Postings...)
+	return entry, nil
+}
+
+// Balance implements Ledger.Balance.
+func (m *memoryLedger) Balance(_ context.Context, account string) (Balance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b Balance
+	for _, e := range m.entries {
+		for _, p := range e.Postings {
+			if p.Account != account {
+				continue
+			}
+
+			switch e.Status {
+			case EntryStatusPending:
+				b.Pending += p.Amount
+			case EntryStatusCommitted:
+				b.Committed += p.Amount
+			}
+		}
+	}
+
+	return b, nil
+}
@@ -0,0 +1,178 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// PostgresSchema contains the statements that create the tables used by the
+// Postgres ledger.
+const PostgresSchema = `
+CREATE TABLE IF NOT EXISTS ledger_entries (
+	id         BYTEA PRIMARY KEY,
+	status     SMALLINT NOT NULL,
+	tx_id      BYTEA,
+	memo       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ledger_postings (
+	entry_id BYTEA NOT NULL REFERENCES ledger_entries (id),
+	account  TEXT NOT NULL,
+	amount   BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ledger_postings_account_idx ON ledger_postings (account);
+`
+
+type postgresLedger struct {
+	db *sql.DB
+}
+
+// NewPostgresLedger returns a Ledger backed by a Postgres database, which must
+// contain the tables in PostgresSchema.
+//
+// The caller is responsible for importing a Postgres driver (such as
+// github.com/lib/pq) and opening the database.
+func NewPostgresLedger(db *sql.DB) Ledger {
+	return &postgresLedger{db: db}
+}
+
+// Prepare implements Ledger.Prepare.
+func (p *postgresLedger) Prepare(ctx context.Context, entry Entry) (err error) {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO ledger_entries (id, status, memo, created_at) VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO NOTHING`,
+		entry.ID, EntryStatusPending, entry.Memo, entry.Created,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert entry")
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errors.Wrap(err, "failed to insert entry")
+	} else if n == 0 {
+		return ErrEntryExists
+	}
+
+	for _, posting := range entry.Postings {
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO ledger_postings (entry_id, account, amount) VALUES ($1, $2, $3)`,
+			entry.ID, posting.Account, posting.Amount,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert posting")
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Commit implements Ledger.Commit.
+func (p *postgresLedger) Commit(ctx context.Context, id, txID []byte) error {
+	return p.finalize(ctx, id, EntryStatusCommitted, txID)
+}
+
+// Void implements Ledger.Void.
+func (p *postgresLedger) Void(ctx context.Context, id []byte) error {
+	return p.finalize(ctx, id, EntryStatusVoided, nil)
+}
+
+func (p *postgresLedger) finalize(ctx context.Context, id []byte, status EntryStatus, txID []byte) error {
+	res, err := p.db.ExecContext(
+		ctx,
+		`UPDATE ledger_entries SET status = $2, tx_id = $3 WHERE id = $1 AND status = $4`,
+		id, status, txID, EntryStatusPending,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to update entry")
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errors.Wrap(err, "failed to update entry")
+	} else if n > 0 {
+		return nil
+	}
+
+	// The entry was either already finalized, or does not exist.
+	var current EntryStatus
+	err = p.db.QueryRowContext(ctx, `SELECT status FROM ledger_entries WHERE id = $1`, id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrEntryNotFound
+	} else if err != nil {
+		return errors.Wrap(err, "failed to get entry")
+	}
+
+	if current != status {
+		return ErrEntryFinalized
+	}
+	return nil
+}
+
+// Get implements Ledger.Get.
+func (p *postgresLedger) Get(ctx context.Context, id []byte) (Entry, error) {
+	entry := Entry{ID: id}
+	err := p.db.QueryRowContext(
+		ctx,
+		`SELECT status, tx_id, memo, created_at FROM ledger_entries WHERE id = $1`,
+		id,
+	).Scan(&entry.Status, &entry.TxID, &entry.Memo, &entry.Created)
+	if err == sql.ErrNoRows {
+		return Entry{}, ErrEntryNotFound
+	} else if err != nil {
+		return Entry{}, errors.Wrap(err, "failed to get entry")
+	}
+
+	rows, err := p.db.QueryContext(ctx, `SELECT account, amount FROM ledger_postings WHERE entry_id = $1`, id)
+	if err != nil {
+		return Entry{}, errors.Wrap(err, "failed to get postings")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var posting Posting
+		if err := rows.Scan(&posting.Account, &posting.Amount); err != nil {
+			return Entry{}, errors.Wrap(err, "failed to scan posting")
+		}
+		entry.Postings = append(entry.Postings, posting)
+	}
+	if err := rows.Err(); err != nil {
+		return Entry{}, errors.Wrap(err, "failed to get postings")
+	}
+
+	return entry, nil
+}
+
+// Balance implements Ledger.Balance.
+func (p *postgresLedger) Balance(ctx context.Context, account string) (Balance, error) {
+	var b Balance
+	err := p.db.QueryRowContext(
+		ctx,
+		`SELECT
+			COALESCE(SUM(p.amount) FILTER (WHERE e.status = $2), 0),
+			COALESCE(SUM(p.amount) FILTER (WHERE e.status = $3), 0)
+		FROM ledger_postings p JOIN ledger_entries e ON e.id = p.entry_id
+		WHERE p.account = $1`,
+		account, EntryStatusCommitted, EntryStatusPending,
+	).Scan(&b.Committed, &b.Pending)
+	if err != nil {
+		return Balance{}, errors.Wrap(err, "failed to get balance")
+	}
+
+	return b, nil
+}
@@ -0,0 +1,30 @@
+package ledger
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPostgresLedger runs against the database specified by LEDGER_POSTGRES_URL,
+// and is skipped if it is not set. The ledger tables are dropped and recreated.
+func TestPostgresLedger(t *testing.T) {
+	url := os.Getenv("LEDGER_POSTGRES_URL")
+	if url == "" {
+		t.Skip("LEDGER_POSTGRES_URL not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS ledger_postings, ledger_entries`)
+	require.NoError(t, err)
+	_, err = db.Exec(PostgresSchema)
+	require.NoError(t, err)
+
+	testLedger(t, NewPostgresLedger(db))
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// WithContext specifies the lifetime of the client. Once ctx is done, the
+// client is closed, as if Close was called.
+func WithContext(ctx context.Context) ClientOption {
+	return func(o *clientOpts) {
+		o.ctx = ctx
+	}
+}
+
+// lifecycle tracks the in-flight submissions of a client, and the lifetime of
+// its background goroutines.
+type lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// conn is closed when the client is closed, if the client owns it.
+	conn *grpc.ClientConn
+
+	mu       sync.Mutex
+	closed   bool
+	inFlight sync.WaitGroup
+	once     sync.Once
+	closeErr error
+}
+
+func newLifecycle(ctx context.Context, conn *grpc.ClientConn) *lifecycle {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	l := &lifecycle{conn: conn}
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	return l
+}
+
+// begin registers an in-flight submission, returning ErrClientClosed if the
+// client is closed. done must be called once the submission completes.
+func (l *lifecycle) begin() (done func(), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil, ErrClientClosed
+	}
+
+	l.inFlight.Add(1)
+	return l.inFlight.Done, nil
+}
+
+func (l *lifecycle) close() error {
+	l.once.Do(func() {
+		l.mu.Lock()
+		l.closed = true
+		l.mu.Unlock()
+
+		l.inFlight.Wait()
+		l.cancel()
+
+		if l.conn != nil {
+			l.closeErr = l.conn.Close()
+		}
+	})
+
+	return l.closeErr
+}
+
+func (c *client) Close() error {
+	return c.lifecycle.close()
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/connectivity"
+)
+
+// blockingAuditSink blocks submission attempts until release is closed.
+type blockingAuditSink struct {
+	attempted chan struct{}
+	release   chan struct{}
+}
+
+func (s *blockingAuditSink) Record(_ context.Context, record AuditRecord) error {
+	if record.Stage == AuditStageAttempt {
+		s.attempted <- struct{}{}
+		<-s.release
+	}
+	return nil
+}
+
+func TestClient_Close(t *testing.T) {
+	sink := &blockingAuditSink{
+		attempted: make(chan struct{}, 1),
+		release:   make(chan struct{}),
+	}
+
+	env, cleanup := setup(t, WithAuditSink(sink))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	for _, acc := range []kin.PrivateKey{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	submitted := make(chan error, 1)
+	go func() {
+		_, err := env.client.SubmitPayment(context.Background(), Payment{
+			Sender:      sender,
+			Destination: dest.Public(),
+			Type:        kin.TransactionTypeSpend,
+			Quarks:      11,
+		})
+		submitted <- err
+	}()
+	<-sink.attempted
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- env.client.Close()
+	}()
+
+	// New submissions are rejected once the client is closing, but the
+	// in-flight submission is allowed to complete.
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return env.client.CreateAccount(context.Background(), key) == ErrClientClosed
+	}, time.Second, time.Millisecond)
+
+	select {
+	case <-closed:
+		t.Fatal("close returned before in-flight submission completed")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(sink.release)
+	assert.NoError(t, <-submitted)
+	assert.NoError(t, <-closed)
+
+	// The connection was provided with WithGRPC, so it is not closed.
+	assert.NotEqual(t, connectivity.Shutdown, env.conn.GetState())
+	assert.NoError(t, env.client.Close())
+}
+
+func TestClient_CloseOwnedConn(t *testing.T) {
+	c, err := New(EnvironmentTest, WithEndpoint("localhost:0"))
+	require.NoError(t, err)
+
+	impl := c.(*client)
+	require.NoError(t, c.Close())
+	assert.Equal(t, connectivity.Shutdown, impl.lifecycle.conn.GetState())
+	assert.Error(t, impl.lifecycle.ctx.Err())
+}
+
+func TestClient_WithContext(t *testing.T) {
+	rpc := newRPCServer(t)
+	defer rpc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	env, cleanup := setup(t, WithContext(ctx), WithSolanaRPC(rpc.URL))
+	defer cleanup()
+
+	_, _, subsidizer := setServiceConfigResp(t, env.v4Server, true)
+	rpc.setBalance(kin.PublicKey(subsidizer), 1000)
+
+	alerts, err := env.client.WatchSubsidizer(context.Background(), 100, time.Millisecond)
+	require.NoError(t, err)
+
+	cancel()
+
+	// Background goroutines stop once the client's lifetime ends.
+	select {
+	case _, ok := <-alerts:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("watch not stopped")
+	}
+
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return env.client.CreateAccount(context.Background(), key) == ErrClientClosed
+	}, time.Second, time.Millisecond)
+}
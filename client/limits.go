@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+)
+
+// Limits specifies spending limits that are enforced by the client before
+// any transaction is submitted.
+//
+// A zero value for any limit indicates that the limit is not enforced.
+type Limits struct {
+	// MaxPaymentQuarks is the maximum amount of a single payment (or earn).
+	MaxPaymentQuarks int64
+
+	// MaxDestinationDailyQuarks is the maximum amount that may be sent to
+	// a single destination in a (UTC) day.
+	MaxDestinationDailyQuarks int64
+
+	// MaxAppDailyQuarks is the maximum amount that may be sent in a (UTC) day
+	// using the client's app index. Clients without an app index are capped
+	// together, so a shared Store should only be used by one such app.
+	MaxAppDailyQuarks int64
+
+	// Store is used to track the daily counters. If nil, an in-memory
+	// store is used, which is not shared between processes.
+	Store LimitStore
+}
+
+// LimitStore stores the counters used to enforce daily Limits.
+//
+// Implementations must be safe for concurrent use. Shared implementations
+// (i.e. backed by a database) should be used when multiple processes
+// submit payments with the same credentials.
+type LimitStore interface {
+	// Add atomically adds delta to the counter identified by key, and returns
+	// the resulting value. Counters should expire no earlier than expiry.
+	Add(ctx context.Context, key string, delta int64, expiry time.Time) (int64, error)
+}
+
+type memoryLimitStore struct {
+	sync.Mutex
+	counters map[string]memoryLimitCounter
+}
+
+type memoryLimitCounter struct {
+	value  int64
+	expiry time.Time
+}
+
+// NewMemoryLimitStore returns an in-memory LimitStore.
+func NewMemoryLimitStore() LimitStore {
+	return &memoryLimitStore{
+		counters: make(map[string]memoryLimitCounter),
+	}
+}
+
+// Add implements LimitStore.Add.
+func (s *memoryLimitStore) Add(_ context.Context, key string, delta int64, expiry time.Time) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	for k, c := range s.counters {
+		if now.After(c.expiry) {
+			delete(s.counters, k)
+		}
+	}
+
+	c := s.counters[key]
+	c.value += delta
+	c.expiry = expiry
+	s.counters[key] = c
+
+	return c.value, nil
+}
+
+type limitEntry struct {
+	destination kin.PublicKey
+	quarks      int64
+}
+
+// check verifies that the provided entries do not exceed the limits,
+// recording them against the daily counters if they do not.
+//
+// Counters are not reverted if the subsequent submission fails, which
+// errs on the side of caution.
+func (l *Limits) check(ctx context.Context, appIndex uint16, entries []limitEntry, now time.Time) error {
+	var total int64
+	perDest := make(map[string]int64)
+	for _, e := range entries {
+		if l.MaxPaymentQuarks > 0 && e.quarks > l.MaxPaymentQuarks {
+			return errors.Wrapf(ErrLimitExceeded, "payment of %d quarks exceeds per payment limit", e.quarks)
+		}
+
+		perDest[e.destination.Base58()] += e.quarks
+		total += e.quarks
+	}
+
+	if l.MaxDestinationDailyQuarks <= 0 && l.MaxAppDailyQuarks <= 0 {
+		return nil
+	}
+
+	now = now.UTC()
+	day := now.Format("2006-01-02")
+	expiry := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+
+	var applied []limitCounter
+	revert := func() {
+		for _, c := range applied {
+			_, _ = l.Store.Add(ctx, c.key, -c.delta, expiry)
+		}
+	}
+
+	var counters []limitCounter
+	if l.MaxDestinationDailyQuarks > 0 {
+		for dest, quarks := range perDest {
+			counters = append(counters, limitCounter{
+				key:   fmt.Sprintf("dest:%s:%s", dest, day),
+				delta: quarks,
+				max:   l.MaxDestinationDailyQuarks,
+			})
+		}
+	}
+	if l.MaxAppDailyQuarks > 0 {
+		// Clients without an app index share the app:0 counter.
+		counters = append(counters, limitCounter{
+			key:   fmt.Sprintf("app:%d:%s", appIndex, day),
+			delta: total,
+			max:   l.MaxAppDailyQuarks,
+		})
+	}
+
+	for _, c := range counters {
+		value, err := l.Store.Add(ctx, c.key, c.delta, expiry)
+		if err != nil {
+			revert()
+			return errors.Wrap(err, "failed to update limit counter")
+		}
+		applied = append(applied, c)
+
+		if value > c.max {
+			revert()
+			return errors.Wrapf(ErrLimitExceeded, "daily limit for %s exceeded", c.key)
+		}
+	}
+
+	return nil
+}
+
+type limitCounter struct {
+	key   string
+	delta int64
+	max   int64
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimits_Check(t *testing.T) {
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	other, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	l := &Limits{
+		MaxPaymentQuarks:          10,
+		MaxDestinationDailyQuarks: 15,
+		MaxAppDailyQuarks:         25,
+		Store:                     NewMemoryLimitStore(),
+	}
+
+	err = l.check(context.Background(), 1, []limitEntry{{destination: dest.Public(), quarks: 11}}, time.Now())
+	assert.Equal(t, ErrLimitExceeded, errors.Cause(err))
+
+	assert.NoError(t, l.check(context.Background(), 1, []limitEntry{{destination: dest.Public(), quarks: 10}}, time.Now()))
+
+	// Exceeding the destination limit should not count against the app limit.
+	err = l.check(context.Background(), 1, []limitEntry{{destination: dest.Public(), quarks: 6}}, time.Now())
+	assert.Equal(t, ErrLimitExceeded, errors.Cause(err))
+	assert.NoError(t, l.check(context.Background(), 1, []limitEntry{{destination: dest.Public(), quarks: 5}}, time.Now()))
+
+	assert.NoError(t, l.check(context.Background(), 1, []limitEntry{{destination: other.Public(), quarks: 10}}, time.Now()))
+	err = l.check(context.Background(), 1, []limitEntry{{destination: other.Public(), quarks: 1}}, time.Now())
+	assert.Equal(t, ErrLimitExceeded, errors.Cause(err))
+
+	// The app limit only applies to the configured app index.
+	assert.NoError(t, l.check(context.Background(), 2, []limitEntry{{destination: other.Public(), quarks: 1}}, time.Now()))
+
+	// The app limit is enforced for clients without an app index.
+	l = &Limits{
+		MaxAppDailyQuarks: 15,
+		Store:             NewMemoryLimitStore(),
+	}
+	assert.NoError(t, l.check(context.Background(), 0, []limitEntry{{destination: dest.Public(), quarks: 10}}, time.Now()))
+	err = l.check(context.Background(), 0, []limitEntry{{destination: other.Public(), quarks: 6}}, time.Now())
+	assert.Equal(t, ErrLimitExceeded, errors.Cause(err))
+}
+
+func TestClient_Limits(t *testing.T) {
+	env, cleanup := setup(t, WithLimits(Limits{
+		MaxPaymentQuarks:          10,
+		MaxDestinationDailyQuarks: 15,
+	}))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	for _, k := range []kin.PrivateKey{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), k))
+	}
+
+	p := Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+	}
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	assert.Equal(t, ErrLimitExceeded, errors.Cause(err))
+
+	p.Quarks = 10
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	assert.NoError(t, err)
+
+	_, err = env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns: []Earn{
+			{Destination: dest.Public(), Quarks: 6},
+		},
+	})
+	assert.Equal(t, ErrLimitExceeded, errors.Cause(err))
+
+	env.v4Server.Mux.Lock()
+	assert.Len(t, env.v4Server.Submits, 1)
+	env.v4Server.Mux.Unlock()
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"crypto/ed25519"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+
+	"github.com/kinecosystem/kin-go/client/lookuptable"
+	"github.com/kinecosystem/kin-go/client/versioned"
+)
+
+// WithAddressLookupTables specifies address lookup tables that earn batches may
+// use. If the tables contain every destination of a batch, the batch is
+// submitted as a versioned (v0) transaction that references the destinations
+// through the tables, allowing it to contain up to MaxLookupTableBatchSize
+// earns rather than MaxBatchSize.
+//
+// Tables can be created and extended with the instructions in the lookuptable
+// package, and loaded with lookuptable.UnmarshalTable. Since earns are
+// transferred to the token accounts of destinations, the tables should contain
+// those, rather than the owners of the accounts.
+//
+// Versioned transactions can only be submitted if Agora accepts them. If it does
+// not (see WithVersionedTransactions), batches are limited to MaxBatchSize
+// earns again.
+func WithAddressLookupTables(tables ...lookuptable.Table) ClientOption {
+	return func(o *clientOpts) {
+		o.lookupTables = append(o.lookupTables, tables...)
+	}
+}
+
+// lookupTablesFor returns the configured lookup tables if they contain every
+// destination of batch.
+func (c *client) lookupTablesFor(batch EarnBatch) []lookuptable.Table {
+	if len(c.opts.lookupTables) == 0 {
+		return nil
+	}
+
+next:
+	for _, e := range batch.Earns {
+		for _, table := range c.opts.lookupTables {
+			if table.IndexOf(ed25519.PublicKey(e.Destination)) >= 0 {
+				continue next
+			}
+		}
+		return nil
+	}
+
+	return c.opts.lookupTables
+}
+
+// maxEarnBatchSize returns the maximum number of earns in a transaction of batch.
+func (c *client) maxEarnBatchSize(batch EarnBatch) int {
+	if c.lookupTablesFor(batch) != nil && !c.versionedSupport.isUnsupported() {
+		return MaxLookupTableBatchSize
+	}
+	return MaxBatchSize
+}
+
+func (c *client) buildVersionedEarnBatchTx(batch EarnBatch, config *transactionpbv4.GetServiceConfigResponse, transferSender kin.PublicKey, subsidizer kin.PrivateKey, tables []lookuptable.Table, prefix ...solana.Instruction) (tx versioned.Transaction, signers []kin.PrivateKey, il *commonpb.InvoiceList, err error) {
+	subsidizerID, signers := earnBatchSigners(batch, config, subsidizer)
+
+	instructions, il, err := c.earnBatchInstructions(batch, transferSender, prefix)
+	if err != nil {
+		return tx, nil, nil, err
+	}
+
+	tx, err = versioned.NewTransaction(ed25519.PublicKey(subsidizerID), instructions, tables...)
+	if err != nil {
+		return tx, nil, nil, err
+	}
+	if size := len(tx.Marshal()); size > solana.MaxTransactionSize {
+		return tx, nil, nil, &TransactionTooLargeError{Size: size, Overflow: size - solana.MaxTransactionSize}
+	}
+
+	return tx, signers, il, nil
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+
+	"github.com/kinecosystem/kin-go/client/lookuptable"
+	"github.com/kinecosystem/kin-go/client/versioned"
+)
+
+func TestClient_SubmitEarnBatchLookupTables(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	batch := generateLargeEarnBatch(t, env, MaxLookupTableBatchSize+5)
+
+	tables := []lookuptable.Table{
+		{Address: make([]byte, 32)},
+		{Address: make([]byte, 32)},
+	}
+	tables[1].Address[0] = 1
+	for i, e := range batch.Earns {
+		tables[i%2].Addresses = append(tables[i%2].Addresses, ed25519.PublicKey(e.Destination))
+	}
+
+	// Without tables, the batch is too large for a single transaction.
+	_, err := env.client.SubmitEarnBatch(context.Background(), EarnBatch{Sender: batch.Sender, Earns: batch.Earns[:MaxBatchSize+1]})
+	assert.Error(t, err)
+
+	WithAddressLookupTables(tables...)(&env.client.opts)
+
+	// Versioned transactions are audited, and passed to hooks.
+	buf := &bytes.Buffer{}
+	WithAuditSink(NewJSONLinesAuditSink(buf))(&env.client.opts)
+	var hooked []solana.Transaction
+	WithHooks(Hooks{
+		BeforeSubmit: func(_ context.Context, tx solana.Transaction) error {
+			hooked = append(hooked, tx)
+			return nil
+		},
+	})(&env.client.opts)
+
+	results, err := env.client.SubmitEarnBatches(context.Background(), batch, WithChunkParallelism(1))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 2)
+	var transferred int
+	for i, submit := range env.v4Server.Submits {
+		require.True(t, versioned.IsVersioned(submit.Transaction.Value))
+
+		var tx versioned.Transaction
+		require.NoError(t, tx.Unmarshal(submit.Transaction.Value))
+		assert.Equal(t, results[i].TxID, tx.Signature())
+		assert.True(t, ed25519.Verify(tx.Message.Accounts[0], tx.Message.Marshal(), tx.Signature()))
+		assert.Len(t, tx.Message.AddressTableLookups, 2)
+
+		keys, err := tx.Message.AccountKeys(tables...)
+		require.NoError(t, err)
+		for _, instruction := range tx.Message.Instructions[1:] {
+			assert.EqualValues(t, batch.Earns[transferred].Destination, keys[instruction.Accounts[1]])
+			transferred++
+		}
+	}
+	assert.Equal(t, len(batch.Earns), transferred)
+	env.v4Server.Submits = nil
+	env.v4Server.Mux.Unlock()
+
+	require.Len(t, hooked, 2)
+	assert.Equal(t, results[0].TxID, hooked[0].Signature())
+
+	var audited []string
+	records := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, records, 4)
+	for _, line := range records {
+		var record AuditRecord
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		if record.Stage == AuditStageAttempt {
+			for _, transfer := range record.Transfers {
+				audited = append(audited, transfer.Destination)
+			}
+		}
+	}
+	require.Len(t, audited, len(batch.Earns))
+	for i, e := range batch.Earns {
+		assert.Equal(t, e.Destination.Base58(), audited[i])
+	}
+
+	WithAuditSink(noopAuditSink{})(&env.client.opts)
+	WithHooks(Hooks{})(&env.client.opts)
+
+	// Chunks may not exceed the larger limit.
+	_, err = env.client.SubmitEarnBatch(context.Background(), batch)
+	assert.Error(t, err)
+
+	// Transaction errors are mapped to earns.
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		{
+			Result: transactionpbv4.SubmitTransactionResponse_FAILED,
+			TransactionError: &commonpbv4.TransactionError{
+				Reason:           commonpbv4.TransactionError_INSUFFICIENT_FUNDS,
+				InstructionIndex: 21,
+				Raw:              []byte("rawerror"),
+			},
+		},
+	}
+	env.v4Server.Mux.Unlock()
+
+	result, err := env.client.SubmitEarnBatch(context.Background(), EarnBatch{Sender: batch.Sender, Earns: batch.Earns[:MaxLookupTableBatchSize]})
+	require.NoError(t, err)
+	assert.Equal(t, ErrInsufficientBalance, result.TxError)
+	require.Len(t, result.EarnErrors, MaxLookupTableBatchSize)
+	assert.Equal(t, ErrInsufficientBalance, result.EarnErrors[20].Error)
+
+	// Batches with destinations outside of the tables use legacy transactions.
+	legacy := generateLargeEarnBatch(t, env, 2)
+	_, err = env.client.SubmitEarnBatch(context.Background(), legacy)
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	assert.False(t, versioned.IsVersioned(env.v4Server.Submits[len(env.v4Server.Submits)-1].Transaction.Value))
+	env.v4Server.Mux.Unlock()
+}
@@ -0,0 +1,171 @@
+// Package lookuptable provides instructions for the Solana AddressLookupTable
+// program, whose tables allow versioned transactions to reference accounts by
+// a one byte index rather than by their full public key.
+package lookuptable
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/system"
+	"github.com/pkg/errors"
+)
+
+// ProgramKey is the address of the AddressLookupTable program.
+//
+// Current key: AddressLookupTab1e1111111111111111111111111
+var ProgramKey = ed25519.PublicKey{2, 119, 166, 175, 151, 51, 155, 122, 200, 141, 24, 146, 201, 4, 70, 245, 0, 2, 48, 146, 102, 246, 46, 83, 193, 24, 36, 73, 130, 0, 0, 0}
+
+const (
+	// MaxAddresses is the maximum number of addresses a table may contain.
+	MaxAddresses = 256
+
+	// metaSize is the size of the metadata that precedes the addresses of a
+	// table account.
+	metaSize = 56
+)
+
+// Command is the type of an AddressLookupTable instruction.
+type Command uint32
+
+const (
+	CommandCreateLookupTable Command = iota
+	CommandFreezeLookupTable
+	CommandExtendLookupTable
+	CommandDeactivateLookupTable
+	CommandCloseLookupTable
+)
+
+// ErrInvalidTable is returned when an account does not contain a lookup table.
+var ErrInvalidTable = errors.New("invalid lookup table")
+
+// DeriveAddress returns the address of the table created by authority with the
+// specified recent slot, and the bump seed used to derive it.
+func DeriveAddress(authority ed25519.PublicKey, recentSlot uint64) (ed25519.PublicKey, byte, error) {
+	var slot [8]byte
+	binary.LittleEndian.PutUint64(slot[:], recentSlot)
+
+	for bump := 255; bump >= 0; bump-- {
+		address, err := solana.CreateProgramAddress(ProgramKey, authority, slot[:], []byte{byte(bump)})
+		if err == solana.ErrInvalidPublicKey {
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+
+		return address, byte(bump), nil
+	}
+
+	return nil, 0, errors.New("no viable bump seed")
+}
+
+// CreateLookupTable returns an instruction that creates a table owned by
+// authority, funded by payer, along with the address of the table.
+//
+// recentSlot must be a recently confirmed slot, and determines the address of
+// the table.
+//
+// Reference: https://github.com/solana-labs/solana/blob/master/programs/address-lookup-table/src/instruction.rs
+func CreateLookupTable(authority, payer ed25519.PublicKey, recentSlot uint64) (solana.Instruction, ed25519.PublicKey, error) {
+	address, bump, err := DeriveAddress(authority, recentSlot)
+	if err != nil {
+		return solana.Instruction{}, nil, errors.Wrap(err, "failed to derive table address")
+	}
+
+	data := make([]byte, 13)
+	binary.LittleEndian.PutUint32(data, uint32(CommandCreateLookupTable))
+	binary.LittleEndian.PutUint64(data[4:], recentSlot)
+	data[12] = bump
+
+	return solana.NewInstruction(
+		ProgramKey,
+		data,
+		solana.NewAccountMeta(address, false),
+		solana.NewReadonlyAccountMeta(authority, true),
+		solana.NewAccountMeta(payer, true),
+		solana.NewReadonlyAccountMeta(system.ProgramKey[:], false),
+	), address, nil
+}
+
+// ExtendLookupTable returns an instruction that appends addresses to table.
+// payer funds the additional rent required by the table.
+//
+// A table may contain at most MaxAddresses addresses, and the number that can
+// be appended by a single instruction is limited by the size of a transaction.
+//
+// Reference: https://github.com/solana-labs/solana/blob/master/programs/address-lookup-table/src/instruction.rs
+func ExtendLookupTable(table, authority, payer ed25519.PublicKey, addresses ...ed25519.PublicKey) solana.Instruction {
+	data := make([]byte, 12, 12+len(addresses)*ed25519.PublicKeySize)
+	binary.LittleEndian.PutUint32(data, uint32(CommandExtendLookupTable))
+	binary.LittleEndian.PutUint64(data[4:], uint64(len(addresses)))
+	for _, address := range addresses {
+		data = append(data, address...)
+	}
+
+	return solana.NewInstruction(
+		ProgramKey,
+		data,
+		solana.NewAccountMeta(table, false),
+		solana.NewReadonlyAccountMeta(authority, true),
+		solana.NewAccountMeta(payer, true),
+		solana.NewReadonlyAccountMeta(system.ProgramKey[:], false),
+	)
+}
+
+// Table is an address lookup table.
+type Table struct {
+	// Address is the address of the table account.
+	Address ed25519.PublicKey
+
+	// Addresses are the addresses stored in the table, in order.
+	Addresses []ed25519.PublicKey
+}
+
+// IndexOf returns the index of key in the table, or -1 if the table does not
+// contain it.
+func (t Table) IndexOf(key ed25519.PublicKey) int {
+	for i, address := range t.Addresses {
+		if bytes.Equal(address, key) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Contains returns whether the table contains all of keys.
+func (t Table) Contains(keys ...ed25519.PublicKey) bool {
+	for _, key := range keys {
+		if t.IndexOf(key) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// UnmarshalTable parses the data of the table account at address.
+//
+// Reference: https://github.com/solana-labs/solana/blob/master/programs/address-lookup-table/src/state.rs
+func UnmarshalTable(address ed25519.PublicKey, data []byte) (Table, error) {
+	if len(data) < metaSize || binary.LittleEndian.Uint32(data) != 1 {
+		return Table{}, ErrInvalidTable
+	}
+	if (len(data)-metaSize)%ed25519.PublicKeySize != 0 {
+		return Table{}, errors.Errorf("invalid lookup table size: %d", len(data))
+	}
+
+	t := Table{
+		Address:   address,
+		Addresses: make([]ed25519.PublicKey, 0, (len(data)-metaSize)/ed25519.PublicKeySize),
+	}
+	for offset := metaSize; offset < len(data); offset += ed25519.PublicKeySize {
+		key := make(ed25519.PublicKey, ed25519.PublicKeySize)
+		copy(key, data[offset:])
+		t.Addresses = append(t.Addresses, key)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,98 @@
+package lookuptable
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/mr-tron/base58"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgramKey(t *testing.T) {
+	assert.Equal(t, "AddressLookupTab1e1111111111111111111111111", base58.Encode(ProgramKey))
+}
+
+func TestCreateLookupTable(t *testing.T) {
+	authority, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	payer, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	instruction, address, err := CreateLookupTable(authority, payer, 1234)
+	require.NoError(t, err)
+
+	derived, bump, err := DeriveAddress(authority, 1234)
+	require.NoError(t, err)
+	assert.Equal(t, derived, address)
+
+	assert.Equal(t, ProgramKey, instruction.Program)
+	require.Len(t, instruction.Accounts, 4)
+	assert.Equal(t, solana.NewAccountMeta(address, false), instruction.Accounts[0])
+	assert.Equal(t, solana.NewReadonlyAccountMeta(authority, true), instruction.Accounts[1])
+	assert.Equal(t, solana.NewAccountMeta(payer, true), instruction.Accounts[2])
+
+	require.Len(t, instruction.Data, 13)
+	assert.EqualValues(t, CommandCreateLookupTable, binary.LittleEndian.Uint32(instruction.Data))
+	assert.EqualValues(t, 1234, binary.LittleEndian.Uint64(instruction.Data[4:]))
+	assert.Equal(t, bump, instruction.Data[12])
+
+	// Different slots derive different tables.
+	other, _, err := DeriveAddress(authority, 1235)
+	require.NoError(t, err)
+	assert.NotEqual(t, address, other)
+}
+
+func TestExtendLookupTable(t *testing.T) {
+	keys := make([]ed25519.PublicKey, 5)
+	for i := range keys {
+		var err error
+		keys[i], _, err = ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+	}
+
+	instruction := ExtendLookupTable(keys[0], keys[1], keys[2], keys[3], keys[4])
+	assert.Equal(t, ProgramKey, instruction.Program)
+	require.Len(t, instruction.Accounts, 4)
+	assert.Equal(t, solana.NewAccountMeta(keys[0], false), instruction.Accounts[0])
+
+	require.Len(t, instruction.Data, 12+2*ed25519.PublicKeySize)
+	assert.EqualValues(t, CommandExtendLookupTable, binary.LittleEndian.Uint32(instruction.Data))
+	assert.EqualValues(t, 2, binary.LittleEndian.Uint64(instruction.Data[4:]))
+	assert.EqualValues(t, keys[3], instruction.Data[12:44])
+	assert.EqualValues(t, keys[4], instruction.Data[44:])
+}
+
+func TestUnmarshalTable(t *testing.T) {
+	address, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	keys := make([]ed25519.PublicKey, 3)
+	data := make([]byte, metaSize)
+	binary.LittleEndian.PutUint32(data, 1)
+	for i := range keys {
+		keys[i], _, err = ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		data = append(data, keys[i]...)
+	}
+
+	table, err := UnmarshalTable(address, data)
+	require.NoError(t, err)
+	assert.Equal(t, address, table.Address)
+	assert.Equal(t, keys, table.Addresses)
+	assert.Equal(t, 1, table.IndexOf(keys[1]))
+	assert.Equal(t, -1, table.IndexOf(address))
+	assert.True(t, table.Contains(keys[2], keys[0]))
+	assert.False(t, table.Contains(keys[0], address))
+
+	_, err = UnmarshalTable(address, data[:metaSize-1])
+	assert.Equal(t, ErrInvalidTable, err)
+	_, err = UnmarshalTable(address, data[:len(data)-1])
+	assert.Error(t, err)
+
+	binary.LittleEndian.PutUint32(data, 0)
+	_, err = UnmarshalTable(address, data)
+	assert.Equal(t, ErrInvalidTable, err)
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"io"
+	"sync"
+
+	"github.com/kinecosystem/agora-common/solana"
+)
+
+// txBufPool contains buffers used to serialize transactions before
+// submission.
+var txBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, solana.MaxTransactionSize)
+		return &b
+	},
+}
+
+// TransactionSize returns the size, in bytes, of the serialized transaction.
+func TransactionSize(tx solana.Transaction) int {
+	size := shortvecSize(len(tx.Signatures)) + len(tx.Signatures)*len(solana.Signature{})
+	return size + MessageSize(tx.Message)
+}
+
+// MessageSize returns the size, in bytes, of the serialized message.
+func MessageSize(m solana.Message) int {
+	size := 3 + shortvecSize(len(m.Accounts)) + len(m.RecentBlockhash) + shortvecSize(len(m.Instructions))
+	for _, a := range m.Accounts {
+		size += len(a)
+	}
+	for _, i := range m.Instructions {
+		size += 1 + shortvecSize(len(i.Accounts)) + len(i.Accounts) + shortvecSize(len(i.Data)) + len(i.Data)
+	}
+
+	return size
+}
+
+// AppendTransaction appends the serialized transaction to b, returning the
+// extended buffer. The output is identical to tx.Marshal(), but allows
+// callers to reuse buffers across transactions.
+func AppendTransaction(b []byte, tx solana.Transaction) []byte {
+	b = appendShortvec(b, len(tx.Signatures))
+	for i := range tx.Signatures {
+		b = append(b, tx.Signatures[i][:]...)
+	}
+
+	return AppendMessage(b, tx.Message)
+}
+
+// AppendMessage appends the serialized message to b, returning the extended
+// buffer. The output is identical to m.Marshal().
+func AppendMessage(b []byte, m solana.Message) []byte {
+	b = append(b, m.Header.NumSignatures, m.Header.NumReadonlySigned, m.Header.NumReadOnly)
+
+	b = appendShortvec(b, len(m.Accounts))
+	for _, a := range m.Accounts {
+		b = append(b, a...)
+	}
+
+	b = append(b, m.RecentBlockhash[:]...)
+
+	b = appendShortvec(b, len(m.Instructions))
+	for _, i := range m.Instructions {
+		b = append(b, i.ProgramIndex)
+		b = appendShortvec(b, len(i.Accounts))
+		b = append(b, i.Accounts...)
+		b = appendShortvec(b, len(i.Data))
+		b = append(b, i.Data...)
+	}
+
+	return b
+}
+
+// MarshalTransactionTo serializes the transaction into b, returning the number
+// of bytes written. If b is too small, io.ErrShortBuffer is returned and b is
+// not modified.
+func MarshalTransactionTo(b []byte, tx solana.Transaction) (int, error) {
+	size := TransactionSize(tx)
+	if len(b) < size {
+		return 0, io.ErrShortBuffer
+	}
+
+	return len(AppendTransaction(b[:0], tx)), nil
+}
+
+func appendShortvec(b []byte, n int) []byte {
+	for {
+		v := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(b, v)
+		}
+		b = append(b, v|0x80)
+	}
+}
+
+func shortvecSize(n int) int {
+	size := 1
+	for n >>= 7; n > 0; n >>= 7 {
+		size++
+	}
+	return size
+}
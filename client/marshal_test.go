@@ -0,0 +1,78 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalTransaction(t *testing.T) {
+	var keys []ed25519.PublicKey
+	for i := 0; i < 4; i++ {
+		k, err := kin.NewPrivateKey()
+		require.NoError(t, err)
+		keys = append(keys, ed25519.PublicKey(k.Public()))
+	}
+
+	for _, tx := range []solana.Transaction{
+		{},
+		solana.NewTransaction(keys[0], memo.Instruction("1-test")),
+		solana.NewTransaction(
+			keys[0],
+			// Exceeds 127 bytes, requiring a multi-byte length.
+			memo.Instruction(strings.Repeat("a", 200)),
+			token.Transfer(keys[1], keys[2], keys[3], 10),
+			token.Transfer(keys[1], keys[3], keys[2], 20),
+		),
+	} {
+		tx.SetBlockhash(solana.Blockhash{1, 2, 3})
+		expected := tx.Marshal()
+
+		assert.Equal(t, len(expected), TransactionSize(tx))
+		assert.Equal(t, len(tx.Message.Marshal()), MessageSize(tx.Message))
+		assert.Equal(t, tx.Message.Marshal(), AppendMessage(nil, tx.Message))
+
+		prefix := []byte("prefix")
+		assert.Equal(t, append(prefix, expected...), AppendTransaction(prefix, tx))
+
+		b := make([]byte, len(expected)+10)
+		n, err := MarshalTransactionTo(b, tx)
+		require.NoError(t, err)
+		assert.Equal(t, expected, b[:n])
+
+		if len(expected) > 0 {
+			_, err = MarshalTransactionTo(b[:len(expected)-1], tx)
+			assert.Equal(t, io.ErrShortBuffer, err)
+		}
+	}
+}
+
+func BenchmarkMarshalTransaction(b *testing.B) {
+	k, err := kin.NewPrivateKey()
+	require.NoError(b, err)
+	pub := ed25519.PublicKey(k.Public())
+
+	tx := solana.NewTransaction(pub, memo.Instruction("1-test"), token.Transfer(pub, pub, pub, 10))
+
+	b.Run("Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = tx.Marshal()
+		}
+	})
+	b.Run("AppendTransaction", func(b *testing.B) {
+		buf := make([]byte, 0, solana.MaxTransactionSize)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf = AppendTransaction(buf[:0], tx)
+		}
+	})
+}
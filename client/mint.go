@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"sync"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/pkg/errors"
+
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+// isSecondaryMint returns whether mint is set, and is not the Kin mint.
+func isSecondaryMint(config *transactionpbv4.GetServiceConfigResponse, mint kin.PublicKey) bool {
+	return len(mint) > 0 && !bytes.Equal(mint, config.GetToken().GetValue())
+}
+
+// mintAccounts caches the token accounts of owners for mints other than Kin,
+// separately for each mint. The zero value is ready to use.
+type mintAccounts struct {
+	mu       sync.Mutex
+	accounts map[string]map[string]kin.PublicKey
+}
+
+func (m *mintAccounts) get(owner, mint kin.PublicKey) (kin.PublicKey, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	account, ok := m.accounts[string(mint)][string(owner)]
+	return account, ok
+}
+
+func (m *mintAccounts) set(owner, mint, account kin.PublicKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.accounts == nil {
+		m.accounts = make(map[string]map[string]kin.PublicKey)
+	}
+	if m.accounts[string(mint)] == nil {
+		m.accounts[string(mint)] = make(map[string]kin.PublicKey)
+	}
+	m.accounts[string(mint)][string(owner)] = account
+}
+
+// resolveMintAccount returns the associated token account of owner for mint.
+//
+// Agora only resolves Kin token accounts, so the associated token account is
+// assumed to be the account of owner, which must already exist.
+func (c *client) resolveMintAccount(ctx context.Context, owner, mint kin.PublicKey) (kin.PublicKey, error) {
+	if account, ok := c.mintAccounts.get(owner, mint); ok {
+		return account, nil
+	}
+	if cached, ok := c.internal.cache.getMintResolution(ctx, owner, mint); ok {
+		c.mintAccounts.set(owner, mint, cached[0])
+		return cached[0], nil
+	}
+
+	account, err := token.GetAssociatedAccount(ed25519.PublicKey(owner), ed25519.PublicKey(mint))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to derive token account of %s", owner.Base58())
+	}
+
+	c.mintAccounts.set(owner, mint, kin.PublicKey(account))
+	c.internal.cache.setMintResolution(ctx, owner, mint, []kin.PublicKey{kin.PublicKey(account)})
+	return kin.PublicKey(account), nil
+}
+
+// submitMintPayment submits a payment of a mint other than Kin, between the
+// token accounts of the sender and destination for the mint.
+func (c *client) submitMintPayment(ctx context.Context, p payment, config *transactionpbv4.GetServiceConfigResponse, solanaOpts solanaOpts) (SubmitTransactionResult, error) {
+	source, err := c.resolveMintAccount(ctx, p.Sender.Public(), p.Mint)
+	if err != nil {
+		return SubmitTransactionResult{}, err
+	}
+	if p.Destination, err = c.resolveMintAccount(ctx, p.Destination, p.Mint); err != nil {
+		return SubmitTransactionResult{}, err
+	}
+
+	return c.submitSolanaPayment(ctx, p, config, solanaOpts.commitment, source, solanaOpts.subsidizer)
+}
+
+// submitMintEarnBatch submits an earn batch of a mint other than Kin, between
+// the token accounts of the sender and destinations for the mint.
+func (c *client) submitMintEarnBatch(ctx context.Context, batch EarnBatch, config *transactionpbv4.GetServiceConfigResponse, solanaOpts solanaOpts) (SubmitTransactionResult, error) {
+	source, err := c.resolveMintAccount(ctx, batch.Sender.Public(), batch.Mint)
+	if err != nil {
+		return SubmitTransactionResult{}, err
+	}
+
+	earns := make([]Earn, len(batch.Earns))
+	for i, e := range batch.Earns {
+		if e.Destination, err = c.resolveMintAccount(ctx, e.Destination, batch.Mint); err != nil {
+			return SubmitTransactionResult{}, err
+		}
+		earns[i] = e
+	}
+	batch.Earns = earns
+
+	result, err := c.submitSolanaEarnBatch(ctx, batch, config, solanaOpts, source, earnBatchReporter(solanaOpts.progress, batch, 0))
+	reportEarnBatchResult(solanaOpts.progress, batch, 0, result, err)
+	return result, err
+}
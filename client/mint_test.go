@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SecondaryMint(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	kinMint, _, _ := setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	mint, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	tokenAccount := func(owner kin.PublicKey) ed25519.PublicKey {
+		account, err := token.GetAssociatedAccount(ed25519.PublicKey(owner), ed25519.PublicKey(mint.Public()))
+		require.NoError(t, err)
+		return account
+	}
+
+	transfers := func() (transfers []*token.DecompiledTransfer) {
+		env.v4Server.Mux.Lock()
+		defer env.v4Server.Mux.Unlock()
+
+		for _, submit := range env.v4Server.Submits {
+			var tx solana.Transaction
+			require.NoError(t, tx.Unmarshal(submit.Transaction.Value))
+			for i := range tx.Message.Instructions {
+				if transfer, err := token.DecompileTransfer(tx.Message, i); err == nil {
+					transfers = append(transfers, transfer)
+				}
+			}
+		}
+		env.v4Server.Submits = nil
+		return transfers
+	}
+
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+		Mint:        mint.Public(),
+	})
+	require.NoError(t, err)
+
+	_, err = env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns:  []Earn{{Destination: dest.Public(), Quarks: 12}},
+		Mint:   mint.Public(),
+	})
+	require.NoError(t, err)
+
+	submitted := transfers()
+	require.Len(t, submitted, 2)
+	for i, transfer := range submitted {
+		assert.Equal(t, tokenAccount(sender.Public()), transfer.Source)
+		assert.Equal(t, tokenAccount(dest.Public()), transfer.Destination)
+		assert.Equal(t, ed25519.PublicKey(sender.Public()), transfer.Owner)
+		assert.EqualValues(t, 11+i, transfer.Amount)
+	}
+
+	// Token accounts are cached per mint.
+	account, ok := env.client.mintAccounts.get(dest.Public(), mint.Public())
+	assert.True(t, ok)
+	assert.EqualValues(t, tokenAccount(dest.Public()), account)
+	_, ok = env.client.mintAccounts.get(dest.Public(), kin.PublicKey(kinMint))
+	assert.False(t, ok)
+
+	// Payments of the Kin mint are regular payments.
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+		Mint:        kin.PublicKey(kinMint),
+	})
+	require.NoError(t, err)
+
+	submitted = transfers()
+	require.Len(t, submitted, 1)
+	assert.Equal(t, ed25519.PublicKey(sender.Public()), submitted[0].Source)
+	assert.Equal(t, ed25519.PublicKey(dest.Public()), submitted[0].Destination)
+}
@@ -5,8 +5,11 @@ import (
 	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/base64"
+	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/kinecosystem/agora-common/kin"
 	"github.com/kinecosystem/agora-common/solana"
 	"github.com/kinecosystem/agora-common/solana/memo"
@@ -15,6 +18,7 @@ import (
 
 	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
 	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
 	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
 )
 
@@ -30,9 +34,23 @@ type Payment struct {
 	Type        kin.TransactionType
 	Quarks      int64
 
+	// DestinationHandle is a human-readable handle of the destination, such
+	// as "alice.sol", which SubmitPayment resolves with the client's Resolver
+	// (see WithResolver).
+	//
+	// If Destination is also set, the handle must resolve to it.
+	DestinationHandle string
+
 	Invoice *commonpb.Invoice
 	Memo    string
 
+	// Mint is the mint of the token being paid, if it is not Kin. Quarks is
+	// then in the smallest unit of the mint, and the payment is transferred
+	// between the associated token accounts of the sender and destination.
+	//
+	// Payments of other mints can only be submitted if Agora accepts them.
+	Mint kin.PublicKey
+
 	// DedupeID is a unique identifier used by the service to help prevent the
 	// accidental submission of the same intended transaction twice.
 
@@ -50,6 +68,8 @@ type payment struct {
 
 	createAccountInstructions []solana.Instruction
 	createAccountSigner       ed25519.PrivateKey
+	computeBudgetInstructions []solana.Instruction
+	versioned                 bool
 }
 
 // ReadOnlyPayment represents a kin payment, where
@@ -60,12 +80,16 @@ type ReadOnlyPayment struct {
 	Type        kin.TransactionType
 	Quarks      int64
 
+	// AppIndex is the app index of the payment's memo, if the payment
+	// used the binary memo format.
+	AppIndex uint16
+
 	Invoice *commonpb.Invoice
 	Memo    string
 }
 
 func parseTransaction(tx solana.Transaction, invoiceList *commonpb.InvoiceList) ([]Creation, []ReadOnlyPayment, error) {
-	parsed, err := kin.ParseTransaction(tx, invoiceList)
+	parsed, err := kin.ParseTransaction(withoutComputeBudget(tx), invoiceList)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -75,14 +99,11 @@ func parseTransaction(tx solana.Transaction, invoiceList *commonpb.InvoiceList)
 
 	var ilHash []byte
 	if invoiceList != nil {
-		raw, err := proto.Marshal(invoiceList)
+		h, err := invoiceListHash(invoiceList)
 		if err != nil {
-			return nil, nil, errors.Wrap(err, "failed to marshal invoice list")
+			return nil, nil, err
 		}
-
-		ilHash = make([]byte, 28)
-		h := sha256.Sum224(raw)
-		copy(ilHash, h[:])
+		ilHash = h[:]
 	}
 
 	for _, r := range parsed.Regions {
@@ -119,6 +140,7 @@ func parseTransaction(tx solana.Transaction, invoiceList *commonpb.InvoiceList)
 
 			if r.Memo != nil {
 				payment.Type = r.Memo.TransactionType()
+				payment.AppIndex = r.Memo.AppIndex()
 
 				fk := r.Memo.ForeignKey()
 				if bytes.Equal(fk[:28], ilHash[:]) && fk[28] == 0 {
@@ -139,74 +161,116 @@ func parseTransaction(tx solana.Transaction, invoiceList *commonpb.InvoiceList)
 	return creations, payments, nil
 }
 
-func parseHistoryItem(item *transactionpbv4.HistoryItem) ([]ReadOnlyPayment, TransactionErrors, error) {
+// invoiceBufPool contains *proto.Buffers used to serialize invoice lists
+// when computing their hash.
+var invoiceBufPool = sync.Pool{
+	New: func() interface{} {
+		return proto.NewBuffer(make([]byte, 0, 1024))
+	},
+}
+
+// invoiceListHash returns the SHA-224 hash of the serialized invoice list,
+// which is used as the foreign key of a memo.
+func invoiceListHash(il *commonpb.InvoiceList) (h [sha256.Size224]byte, err error) {
+	b := invoiceBufPool.Get().(*proto.Buffer)
+	defer func() {
+		b.Reset()
+		invoiceBufPool.Put(b)
+	}()
+
+	if err := b.Marshal(il); err != nil {
+		return h, errors.Wrap(err, "failed to serialize invoice list")
+	}
+
+	return sha256.Sum224(b.Bytes()), nil
+}
+
+// parseHistoryItem parses the payments, errors, and metadata of a history item.
+// The TxID and TxState of the returned data are not set.
+func parseHistoryItem(item *transactionpbv4.HistoryItem) (data TransactionData, err error) {
 	if item.InvoiceList != nil && len(item.InvoiceList.Invoices) != len(item.Payments) {
-		return nil, TransactionErrors{}, errors.Errorf(
+		return data, errors.Errorf(
 			"provided invoice count (%d) does not match payment count (%d)",
 			len(item.InvoiceList.Invoices),
 			len(item.Payments),
 		)
 	}
 
+	if item.TransactionTime != nil {
+		if data.BlockTime, err = ptypes.Timestamp(item.TransactionTime); err != nil {
+			return data, errors.Wrap(err, "invalid transaction time")
+		}
+	}
+
 	var textMemo string
 	var txType kin.TransactionType
-	var txErrors TransactionErrors
+	var appIndex uint16
 
 	switch t := item.RawTransaction.(type) {
 	case *transactionpbv4.HistoryItem_SolanaTransaction:
 		tx := &solana.Transaction{}
 		err := tx.Unmarshal(t.SolanaTransaction.Value)
 		if err != nil {
-			return nil, TransactionErrors{}, errors.Wrap(err, "failed to unmarshal test transaction")
+			return data, errors.Wrap(err, "failed to unmarshal test transaction")
 		}
 
+		data.RawTransaction = &RawTransaction{Solana: t.SolanaTransaction.Value}
+		data.FeePayer = kin.PublicKey(tx.Message.Accounts[0])
+		data.FeeLamports = uint64(tx.Message.Header.NumSignatures) * LamportsPerSignature
+
 		if bytes.Equal(tx.Message.Accounts[tx.Message.Instructions[0].ProgramIndex], memo.ProgramKey) {
 			m, err := memo.DecompileMemo(tx.Message, 0)
 			if err != nil {
-				return nil, TransactionErrors{}, errors.Wrap(err, "failed to decompile memo instruction")
+				return data, errors.Wrap(err, "failed to decompile memo instruction")
 			}
 			decoded := [32]byte{}
 			_, err = base64.StdEncoding.Decode(decoded[:], m.Data)
 			if err == nil && kin.IsValidMemoStrict(decoded) {
 				txType = kin.Memo(decoded).TransactionType()
+				appIndex = kin.Memo(decoded).AppIndex()
 			} else {
 				textMemo = string(m.Data)
 			}
 		}
-		txErrors = errorsFromSolanaTx(tx, item.TransactionError)
+		data.Errors = errorsFromSolanaTx(tx, item.TransactionError)
 	case *transactionpbv4.HistoryItem_StellarTransaction:
 		var envelope xdr.TransactionEnvelope
 		if err := envelope.UnmarshalBinary(t.StellarTransaction.EnvelopeXdr); err != nil {
-			return nil, TransactionErrors{}, errors.Wrap(err, "failed to unmarshal xdr")
+			return data, errors.Wrap(err, "failed to unmarshal xdr")
+		}
+		data.RawTransaction = &RawTransaction{
+			StellarEnvelope: t.StellarTransaction.EnvelopeXdr,
+			StellarResult:   t.StellarTransaction.ResultXdr,
 		}
 
 		kinMemo, ok := kin.MemoFromXDR(envelope.Tx.Memo, true)
 		if ok {
 			txType = kinMemo.TransactionType()
+			appIndex = kinMemo.AppIndex()
 		} else if envelope.Tx.Memo.Text != nil {
 			textMemo = *envelope.Tx.Memo.Text
 		}
-		txErrors = errorsFromStellarTx(envelope, item.TransactionError)
+		data.Errors = errorsFromStellarTx(envelope, item.TransactionError)
 	}
 
-	payments := make([]ReadOnlyPayment, len(item.Payments))
+	data.Payments = make([]ReadOnlyPayment, len(item.Payments))
 	for i, payment := range item.Payments {
 		p := ReadOnlyPayment{
 			Sender:      payment.Source.Value,
 			Destination: payment.Destination.Value,
 			Type:        txType,
 			Quarks:      payment.Amount,
+			AppIndex:    appIndex,
 		}
 		if item.InvoiceList != nil {
 			p.Invoice = item.InvoiceList.Invoices[i]
 		} else if textMemo != "" {
 			p.Memo = textMemo
 		}
-		payments[i] = p
+		data.Payments[i] = p
 	}
 
-	return payments, txErrors, nil
-
+	return data, nil
 }
 
 // TransactionData contains high level metadata and payments
@@ -216,6 +280,62 @@ type TransactionData struct {
 	TxState  TransactionState
 	Payments []ReadOnlyPayment
 	Errors   TransactionErrors
+
+	// Slot is the slot the transaction was included in, and Commitment is the
+	// commitment it was retrieved with. They are only set by GetTransaction,
+	// for successful transactions.
+	Slot       uint64
+	Commitment commonpbv4.Commitment
+
+	// BlockTime is the time of the block containing the transaction. It is the
+	// zero value if unknown.
+	BlockTime time.Time
+
+	// FeePayer is the account that paid the transaction fee, and FeeLamports
+	// is the fee, computed from the number of signatures. They are not set for
+	// Stellar transactions.
+	FeePayer    kin.PublicKey
+	FeeLamports uint64
+
+	// RawTransaction is the original encoding of the transaction. The client
+	// only sets it if WithRawTransaction is specified.
+	RawTransaction *RawTransaction
+}
+
+// RawTransaction is the original encoding of a transaction, as stored on the
+// blockchain. Only the fields for the transaction's blockchain are set.
+type RawTransaction struct {
+	// Solana is the wire encoding of a Solana transaction.
+	Solana []byte
+
+	// StellarEnvelope and StellarResult are the XDR encoded envelope and
+	// result of a Stellar transaction.
+	StellarEnvelope []byte
+	StellarResult   []byte
+}
+
+// HistoryItem is a transaction in the history of an account.
+type HistoryItem struct {
+	TransactionData
+
+	// Cursor is the position of the item in the history. Iteration can be
+	// resumed after the item by passing it to GetHistory.
+	Cursor Cursor
+
+	// Time is the time of the transaction. It is the zero value if unknown.
+	Time time.Time
+}
+
+// TransactionStatus contains the status of a transaction, without
+// its payments.
+type TransactionStatus struct {
+	TxID          []byte
+	TxState       TransactionState
+	Slot          uint64
+	Confirmations uint32
+
+	// TxError is the error the transaction failed with, if any.
+	TxError error
 }
 
 type TransactionState int
@@ -249,6 +369,10 @@ type EarnBatch struct {
 
 	Earns []Earn
 
+	// Mint is the mint of the token being paid, if it is not Kin, as with
+	// Payment.Mint.
+	Mint kin.PublicKey
+
 	// DedupeID is a unique identifier used by the service to help prevent the
 	// accidental submission of the same intended transaction twice.
 
@@ -259,6 +383,18 @@ type EarnBatch struct {
 	//
 	// Only available on Kin 4.
 	DedupeID []byte
+
+	// txType is the type of the transaction, if it is not an earn, such as
+	// for split payments.
+	txType kin.TransactionType
+}
+
+// transactionType returns the type of the transaction of the batch.
+func (b EarnBatch) transactionType() kin.TransactionType {
+	if b.txType == kin.TransactionTypeNone {
+		return kin.TransactionTypeEarn
+	}
+	return b.txType
 }
 
 // Earn represents a earn payment in an earn batch.
@@ -266,12 +402,27 @@ type Earn struct {
 	Destination kin.PublicKey
 	Quarks      int64
 	Invoice     *commonpb.Invoice
+
+	// Source optionally overrides the token account the earn is transferred
+	// from, which is otherwise the token account of the batch's Sender. It
+	// allows a single batch to aggregate transfers from several accounts, such
+	// as escrow accounts held on behalf of the app. Source is not resolved.
+	Source kin.PublicKey
+
+	// Owner is the owner of Source, which signs the transfer in addition to
+	// the batch's Sender. It defaults to the Sender, and may only be set if
+	// Source is set.
+	Owner kin.PrivateKey
 }
 
 // EarnBatchResult contains the result of an EarnBatch transaction.
 type EarnBatchResult struct {
 	TxID []byte
 
+	// Commitment is the commitment the transaction was accepted at. A
+	// ConfirmationHandle can be used to wait for a higher commitment.
+	Commitment commonpbv4.Commitment
+
 	// If TxError is defined, the transaction failed.
 	TxError error
 
@@ -308,6 +459,18 @@ const (
 	AccountResolutionPreferred
 )
 
+// SubscriptionResult contains a result received from a stream returned by
+// Client.Subscribe. Either Item or Err will be set.
+type SubscriptionResult struct {
+	// Item is a transaction of the account. Live is set if the transaction
+	// was received after the history was replayed. Live transactions are read
+	// from the history, so that they have a Cursor, unless they were not yet
+	// in the history, in which case the Cursor is not set.
+	Item HistoryItem
+	Live bool
+	Err  error
+}
+
 // EventsResult contains the result received from an account event stream. Either Events or Err will be set.
 type EventsResult struct {
 	Events []*accountpbv4.Event
@@ -0,0 +1,340 @@
+// Package monitor watches the balances of operational accounts, such as hot
+// wallets, subsidizers, and escrow accounts, and raises alerts when they fall
+// below configured thresholds.
+package monitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// eventCheckTimeout bounds the checks triggered by events, which are not
+// associated with a context.
+const eventCheckTimeout = 30 * time.Second
+
+// Currency is the currency of a watched balance.
+type Currency int
+
+const (
+	// CurrencyKin is the Kin balance of an account, in quarks.
+	CurrencyKin Currency = iota
+
+	// CurrencySOL is the SOL balance of an account, in lamports. Querying SOL
+	// balances requires the client to be configured with client.WithSolanaRPC.
+	CurrencySOL
+)
+
+// String returns the string representation of the currency.
+func (c Currency) String() string {
+	switch c {
+	case CurrencyKin:
+		return "kin"
+	case CurrencySOL:
+		return "sol"
+	default:
+		return "unknown"
+	}
+}
+
+// Watch is an account balance watched by a BalanceMonitor.
+type Watch struct {
+	Account  kin.PublicKey
+	Currency Currency
+
+	// Threshold is the balance, in quarks or lamports, below which an alert is
+	// raised.
+	Threshold uint64
+
+	// Label is an optional description of the account included in alerts, such
+	// as "hot wallet".
+	Label string
+}
+
+// Alert is raised when a watched balance crosses its threshold.
+type Alert struct {
+	Watch   Watch
+	Balance uint64
+	Time    time.Time
+
+	// Recovered is set if the balance rose back to or above the threshold,
+	// after an alert was raised for it falling below the threshold.
+	Recovered bool
+}
+
+// Alerter delivers alerts.
+type Alerter interface {
+	Alert(ctx context.Context, alert Alert) error
+}
+
+// AlerterFunc is an Alerter backed by a function.
+type AlerterFunc func(ctx context.Context, alert Alert) error
+
+// Alert implements Alerter.Alert.
+func (f AlerterFunc) Alert(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+// MultiAlerter returns an Alerter that delivers alerts to each of alerters,
+// returning the first error encountered.
+func MultiAlerter(alerters ...Alerter) Alerter {
+	return AlerterFunc(func(ctx context.Context, alert Alert) error {
+		var first error
+		for _, a := range alerters {
+			if err := a.Alert(ctx, alert); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	})
+}
+
+type opts struct {
+	interval     time.Duration
+	hub          *client.EventHub
+	errorHandler func(Watch, error)
+	now          func() time.Time
+}
+
+// Option configures a BalanceMonitor.
+type Option func(*opts)
+
+// WithInterval specifies how often Run checks every watched balance. Defaults
+// to 1 minute.
+func WithInterval(interval time.Duration) Option {
+	return func(o *opts) {
+		o.interval = interval
+	}
+}
+
+// WithEventHub specifies an EventHub whose events trigger an immediate check of
+// the Kin balance of the account they belong to, so that alerts are raised
+// without waiting for the next poll.
+func WithEventHub(hub *client.EventHub) Option {
+	return func(o *opts) {
+		o.hub = hub
+	}
+}
+
+// WithErrorHandler specifies a function that is called with the errors
+// encountered while checking balances and delivering alerts, including those
+// of checks triggered by Run and WithEventHub, which are otherwise dropped.
+func WithErrorHandler(handler func(Watch, error)) Option {
+	return func(o *opts) {
+		o.errorHandler = handler
+	}
+}
+
+type watchState struct {
+	watch       Watch
+	below       bool
+	unsubscribe func()
+}
+
+// BalanceMonitor watches account balances, raising an alert when a balance
+// falls below its threshold, and again when it recovers.
+//
+// An alert is only raised once while a balance stays below its threshold.
+type BalanceMonitor struct {
+	client  client.Client
+	alerter Alerter
+	opts    opts
+
+	// checkMu serializes checks, so that alerts for a balance are raised in
+	// order.
+	checkMu sync.Mutex
+
+	mu      sync.Mutex
+	watches map[string]*watchState
+}
+
+// NewBalanceMonitor returns a BalanceMonitor that queries balances with c and
+// delivers alerts to alerter.
+func NewBalanceMonitor(c client.Client, alerter Alerter, options ...Option) *BalanceMonitor {
+	m := &BalanceMonitor{
+		client:  c,
+		alerter: alerter,
+		opts: opts{
+			interval: time.Minute,
+			now:      time.Now,
+		},
+		watches: make(map[string]*watchState),
+	}
+	for _, o := range options {
+		o(&m.opts)
+	}
+
+	return m
+}
+
+func watchKey(account kin.PublicKey, currency Currency) string {
+	return currency.String() + ":" + string(account)
+}
+
+// Add starts watching a balance, replacing any existing watch of the same
+// account and currency.
+func (m *BalanceMonitor) Add(w Watch) error {
+	if len(w.Account) == 0 {
+		return errors.New("account is required")
+	}
+	if w.Currency != CurrencyKin && w.Currency != CurrencySOL {
+		return errors.Errorf("unsupported currency: %d", w.Currency)
+	}
+
+	state := &watchState{watch: w}
+	if m.opts.hub != nil && w.Currency == CurrencyKin {
+		unsubscribe, err := m.opts.hub.Subscribe(w.Account, func(account kin.PublicKey, result client.EventsResult) {
+			if result.Err != nil {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), eventCheckTimeout)
+			defer cancel()
+			m.checkAccount(ctx, account)
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to subscribe to events")
+		}
+		state.unsubscribe = unsubscribe
+	}
+
+	m.mu.Lock()
+	previous := m.watches[watchKey(w.Account, w.Currency)]
+	m.watches[watchKey(w.Account, w.Currency)] = state
+	m.mu.Unlock()
+
+	if previous != nil && previous.unsubscribe != nil {
+		previous.unsubscribe()
+	}
+	return nil
+}
+
+// Remove stops watching the balance of account in currency.
+func (m *BalanceMonitor) Remove(account kin.PublicKey, currency Currency) {
+	m.mu.Lock()
+	state := m.watches[watchKey(account, currency)]
+	delete(m.watches, watchKey(account, currency))
+	m.mu.Unlock()
+
+	if state != nil && state.unsubscribe != nil {
+		state.unsubscribe()
+	}
+}
+
+// Watches returns the watched balances.
+func (m *BalanceMonitor) Watches() []Watch {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	watches := make([]Watch, 0, len(m.watches))
+	for _, state := range m.watches {
+		watches = append(watches, state.watch)
+	}
+	return watches
+}
+
+// Check checks every watched balance once, raising any alerts. It returns the
+// first error encountered, after checking the remaining balances.
+func (m *BalanceMonitor) Check(ctx context.Context) error {
+	m.mu.Lock()
+	states := make([]*watchState, 0, len(m.watches))
+	for _, state := range m.watches {
+		states = append(states, state)
+	}
+	m.mu.Unlock()
+
+	var first error
+	for _, state := range states {
+		if err := m.check(ctx, state); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Run checks every watched balance each interval until ctx is done. Errors are
+// reported to the error handler, if one was specified.
+func (m *BalanceMonitor) Run(ctx context.Context) error {
+	if m.opts.interval <= 0 {
+		return errors.Errorf("invalid interval: %v", m.opts.interval)
+	}
+
+	ticker := time.NewTicker(m.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		_ = m.Check(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// checkAccount checks the Kin balance of account, if it is watched.
+func (m *BalanceMonitor) checkAccount(ctx context.Context, account kin.PublicKey) {
+	m.mu.Lock()
+	state := m.watches[watchKey(account, CurrencyKin)]
+	m.mu.Unlock()
+
+	if state != nil {
+		_ = m.check(ctx, state)
+	}
+}
+
+func (m *BalanceMonitor) check(ctx context.Context, state *watchState) (err error) {
+	m.checkMu.Lock()
+	defer m.checkMu.Unlock()
+
+	defer func() {
+		if err != nil && m.opts.errorHandler != nil {
+			m.opts.errorHandler(state.watch, err)
+		}
+	}()
+
+	balance, err := m.balance(ctx, state.watch)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get %s balance of %s", state.watch.Currency, state.watch.Account.Base58())
+	}
+
+	below := balance < state.watch.Threshold
+	if below == state.below {
+		return nil
+	}
+
+	alert := Alert{
+		Watch:     state.watch,
+		Balance:   balance,
+		Time:      m.opts.now(),
+		Recovered: !below,
+	}
+	if err := m.alerter.Alert(ctx, alert); err != nil {
+		// The state is left unchanged, so that the alert is raised again by the
+		// next check.
+		return errors.Wrap(err, "failed to deliver alert")
+	}
+
+	state.below = below
+	return nil
+}
+
+func (m *BalanceMonitor) balance(ctx context.Context, w Watch) (uint64, error) {
+	if w.Currency == CurrencySOL {
+		return m.client.GetSolBalance(ctx, w.Account)
+	}
+
+	quarks, err := m.client.GetBalance(ctx, w.Account)
+	if err != nil {
+		return 0, err
+	}
+	if quarks < 0 {
+		return 0, nil
+	}
+	return uint64(quarks), nil
+}
@@ -0,0 +1,245 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
+
+	"github.com/kinecosystem/kin-go/client"
+	"github.com/kinecosystem/kin-go/client/clientfake"
+)
+
+type alertRecorder struct {
+	mu     sync.Mutex
+	alerts []Alert
+	err    error
+}
+
+func (r *alertRecorder) Alert(_ context.Context, alert Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func (r *alertRecorder) take() []Alert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	alerts := r.alerts
+	r.alerts = nil
+	return alerts
+}
+
+func generateKey(t *testing.T) kin.PublicKey {
+	k, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	return k.Public()
+}
+
+func TestBalanceMonitor(t *testing.T) {
+	hotWallet := generateKey(t)
+	subsidizer := generateKey(t)
+
+	fake := clientfake.New()
+	fake.SetBalance(hotWallet, 100)
+	fake.SetSolBalance(subsidizer, 1000)
+
+	recorder := &alertRecorder{}
+	var handled []error
+	m := NewBalanceMonitor(fake, recorder, WithErrorHandler(func(_ Watch, err error) {
+		handled = append(handled, err)
+	}))
+
+	hotWatch := Watch{Account: hotWallet, Threshold: 50, Label: "hot wallet"}
+	subsidizerWatch := Watch{Account: subsidizer, Currency: CurrencySOL, Threshold: 500}
+	require.NoError(t, m.Add(hotWatch))
+	require.NoError(t, m.Add(subsidizerWatch))
+	assert.Error(t, m.Add(Watch{Account: hotWallet, Currency: Currency(5)}))
+	assert.Len(t, m.Watches(), 2)
+
+	// Balances above their thresholds raise no alerts.
+	require.NoError(t, m.Check(context.Background()))
+	assert.Empty(t, recorder.take())
+
+	// Falling below a threshold raises a single alert.
+	fake.SetBalance(hotWallet, 49)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, m.Check(context.Background()))
+	}
+	alerts := recorder.take()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, hotWatch, alerts[0].Watch)
+	assert.EqualValues(t, 49, alerts[0].Balance)
+	assert.False(t, alerts[0].Recovered)
+
+	// Recovering raises a recovery alert.
+	fake.SetBalance(hotWallet, 50)
+	fake.SetSolBalance(subsidizer, 499)
+	require.NoError(t, m.Check(context.Background()))
+	alerts = recorder.take()
+	require.Len(t, alerts, 2)
+	for _, alert := range alerts {
+		if alert.Watch.Currency == CurrencyKin {
+			assert.True(t, alert.Recovered)
+			assert.EqualValues(t, 50, alert.Balance)
+		} else {
+			assert.Equal(t, subsidizerWatch, alert.Watch)
+			assert.False(t, alert.Recovered)
+			assert.EqualValues(t, 499, alert.Balance)
+		}
+	}
+
+	// Alerts that fail to be delivered are raised again.
+	fake.SetBalance(hotWallet, 0)
+	recorder.err = errors.New("unavailable")
+	assert.Error(t, m.Check(context.Background()))
+	recorder.err = nil
+	require.NoError(t, m.Check(context.Background()))
+	assert.Len(t, recorder.take(), 1)
+	assert.Len(t, handled, 1)
+
+	// Errors querying balances are reported.
+	fake.QueueError("GetBalance", client.ErrAccountDoesNotExist)
+	err := m.Check(context.Background())
+	assert.Equal(t, client.ErrAccountDoesNotExist, errors.Cause(err))
+	assert.Len(t, handled, 2)
+
+	m.Remove(hotWallet, CurrencyKin)
+	assert.Equal(t, []Watch{subsidizerWatch}, m.Watches())
+}
+
+type eventSource struct {
+	events chan client.EventsResult
+}
+
+func (s *eventSource) GetEvents(ctx context.Context, _ kin.PublicKey) (<-chan client.EventsResult, error) {
+	ch := make(chan client.EventsResult)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case result := <-s.events:
+				ch <- result
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func TestBalanceMonitor_EventHub(t *testing.T) {
+	account := generateKey(t)
+
+	fake := clientfake.New()
+	fake.SetBalance(account, 100)
+
+	source := &eventSource{events: make(chan client.EventsResult)}
+	hub := client.NewEventHub(source)
+	defer hub.Close()
+
+	alerts := make(chan Alert, 1)
+	m := NewBalanceMonitor(fake, AlerterFunc(func(_ context.Context, alert Alert) error {
+		alerts <- alert
+		return nil
+	}), WithEventHub(hub))
+	require.NoError(t, m.Add(Watch{Account: account, Threshold: 50}))
+	assert.Equal(t, 1, hub.Streams())
+
+	fake.SetBalance(account, 10)
+	source.events <- client.EventsResult{Events: []*accountpbv4.Event{{}}}
+
+	select {
+	case alert := <-alerts:
+		assert.EqualValues(t, 10, alert.Balance)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for alert")
+	}
+
+	m.Remove(account, CurrencyKin)
+	assert.Equal(t, 0, hub.Streams())
+}
+
+func TestBalanceMonitor_Run(t *testing.T) {
+	account := generateKey(t)
+
+	fake := clientfake.New()
+	fake.SetBalance(account, 10)
+
+	alerts := make(chan Alert, 1)
+	m := NewBalanceMonitor(fake, AlerterFunc(func(_ context.Context, alert Alert) error {
+		alerts <- alert
+		return nil
+	}), WithInterval(time.Millisecond))
+	require.NoError(t, m.Add(Watch{Account: account, Threshold: 50}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+
+	<-alerts
+	fake.SetBalance(account, 60)
+	alert := <-alerts
+	assert.True(t, alert.Recovered)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
+
+func TestWebhookAlerter(t *testing.T) {
+	account := generateKey(t)
+
+	var received []map[string]interface{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received = append(received, body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	a := NewWebhookAlerter(server.URL, server.Client())
+	alert := Alert{
+		Watch:   Watch{Account: account, Currency: CurrencySOL, Threshold: 10, Label: "subsidizer"},
+		Balance: 5,
+		Time:    time.Unix(100, 0).UTC(),
+	}
+	require.NoError(t, a.Alert(context.Background(), alert))
+
+	require.Len(t, received, 1)
+	assert.Equal(t, map[string]interface{}{
+		"account":   account.Base58(),
+		"label":     "subsidizer",
+		"currency":  "sol",
+		"threshold": float64(10),
+		"balance":   float64(5),
+		"recovered": false,
+		"time":      "1970-01-01T00:01:40Z",
+	}, received[0])
+
+	status = http.StatusInternalServerError
+	assert.Error(t, a.Alert(context.Background(), alert))
+
+	multi := MultiAlerter(a, AlerterFunc(func(context.Context, Alert) error { return nil }))
+	assert.Error(t, multi.Alert(context.Background(), alert))
+	assert.Len(t, received, 3)
+}
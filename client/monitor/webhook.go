@@ -0,0 +1,72 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type webhookAlert struct {
+	Account   string    `json:"account"`
+	Label     string    `json:"label,omitempty"`
+	Currency  string    `json:"currency"`
+	Threshold uint64    `json:"threshold"`
+	Balance   uint64    `json:"balance"`
+	Recovered bool      `json:"recovered"`
+	Time      time.Time `json:"time"`
+}
+
+type webhookAlerter struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhookAlerter returns an Alerter that POSTs alerts as JSON to url. If
+// httpClient is nil, a client with a 10 second timeout is used.
+//
+// The body contains the base58 encoded account, along with the label, currency,
+// threshold, balance, recovered flag and time of the alert. Any non-2xx status
+// is treated as a failure to deliver the alert.
+func NewWebhookAlerter(url string, httpClient *http.Client) Alerter {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &webhookAlerter{url: url, httpClient: httpClient}
+}
+
+// Alert implements Alerter.Alert.
+func (w *webhookAlerter) Alert(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(webhookAlert{
+		Account:   alert.Watch.Account.Base58(),
+		Label:     alert.Watch.Label,
+		Currency:  alert.Watch.Currency.String(),
+		Threshold: alert.Watch.Threshold,
+		Balance:   alert.Balance,
+		Recovered: alert.Recovered,
+		Time:      alert.Time,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode alert")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "failed to send alert")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("unexpected status from alert webhook: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+)
+
+// PaymentRequestScheme is the URI scheme of payment requests.
+const PaymentRequestScheme = "kin"
+
+// PaymentRequest is a request for a payment, such as one shown to a customer
+// as a QR code at checkout, that can be encoded as a URI and parsed by any of
+// the Kin SDKs.
+//
+// Payment requests are encoded as URIs of the form:
+//
+//	kin:<destination>?amount=<kin>&type=<type>&app=<app index>&invoice=<invoice>&sku=<sku>&memo=<memo>&label=<label>&expires=<expiry>&requester=<requester>&sig=<signature>
+//
+// where the destination and requester are base58 encoded, the amount is in Kin
+// (such as "1.5"), the type is one of "earn", "spend" or "p2p", the expiry is
+// in seconds since the Unix epoch, and the invoice (a serialized
+// commonpb.Invoice), SKU and signature are unpadded base64url encoded. All
+// parameters are optional, and unknown parameters are ignored, so that new
+// parameters can be added without breaking older parsers.
+//
+// Payment requests can also be encoded as deep links into an app (see
+// DeepLink), in which case the destination is in the "to" parameter.
+type PaymentRequest struct {
+	Destination kin.PublicKey
+
+	// Quarks is the requested amount. If zero, the payer chooses the amount.
+	Quarks int64
+
+	Type     kin.TransactionType
+	AppIndex uint16
+
+	// Invoice is the invoice of the payment, whose total must equal Quarks.
+	// Invoices require an AppIndex.
+	Invoice *commonpb.Invoice
+
+	// SKU identifies the item being paid for, for merchants that do not
+	// include a full invoice.
+	SKU []byte
+
+	Memo string
+
+	// Label is the name of the recipient, such as a merchant's name, to show
+	// to the payer.
+	Label string
+
+	// Expires, if set, is the time after which the request can no longer be
+	// fulfilled. It is encoded with a precision of one second.
+	Expires time.Time
+
+	// Requester is the account that signed the request (see Sign), such as a
+	// user requesting Kin from a friend. Apps should show the requester to the
+	// payer, since the signature only proves that the request was made by the
+	// requester, not that the requester owns the destination.
+	Requester kin.PublicKey
+
+	// Signature is the requester's signature of the request.
+	Signature []byte
+}
+
+// Sign returns the request signed by requester, so that payers can verify who
+// requested the payment. Any existing signature is replaced.
+func (r PaymentRequest) Sign(requester kin.PrivateKey) (PaymentRequest, error) {
+	r.Requester = requester.Public()
+	r.Signature = nil
+
+	message, err := r.URI()
+	if err != nil {
+		return PaymentRequest{}, err
+	}
+
+	r.Signature = ed25519.Sign(ed25519.PrivateKey(requester), []byte(message))
+	return r, nil
+}
+
+// Verify returns ErrPaymentRequestExpired if the request expired before now,
+// and ErrInvalidSignature if the request has a requester whose signature is
+// missing or invalid. Unsigned requests without a requester, such as those
+// of merchants, are not otherwise verified.
+func (r PaymentRequest) Verify(now time.Time) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+	if !r.Expires.IsZero() && !now.Before(r.Expires) {
+		return ErrPaymentRequestExpired
+	}
+	if r.Requester == nil {
+		return nil
+	}
+
+	signature := r.Signature
+	r.Signature = nil
+	message, err := r.URI()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(r.Requester), []byte(message), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// URI returns the payment request as a kin: URI, which can also be used as the
+// payload of a QR code.
+func (r PaymentRequest) URI() (string, error) {
+	params, err := r.params()
+	if err != nil {
+		return "", err
+	}
+
+	u := url.URL{
+		Scheme:   PaymentRequestScheme,
+		Opaque:   r.Destination.Base58(),
+		RawQuery: params.Encode(),
+	}
+	return u.String(), nil
+}
+
+// DeepLink returns the payment request as a link to baseURL (such as
+// "https://example.com/pay" or "myapp://pay"), with the destination in the
+// "to" parameter. Any existing parameters of baseURL are preserved.
+func (r PaymentRequest) DeepLink(baseURL string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid base url")
+	}
+
+	params, err := r.params()
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Set("to", r.Destination.Base58())
+	for k, v := range params {
+		query[k] = v
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// ToPayment returns a Payment from sender that fulfills the request.
+//
+// The app index of the payment is that of the client it is submitted with, so
+// requests with an invoice should be paid with a client whose app index is the
+// request's AppIndex.
+func (r PaymentRequest) ToPayment(sender kin.PrivateKey) (Payment, error) {
+	if err := r.validate(); err != nil {
+		return Payment{}, err
+	}
+	if r.Quarks == 0 {
+		return Payment{}, errors.Wrap(ErrInvalidAmount, "payment request has no amount")
+	}
+
+	txType := r.Type
+	if txType == kin.TransactionTypeNone || txType == kin.TransactionTypeUnknown {
+		txType = kin.TransactionTypeP2P
+	}
+
+	return Payment{
+		Sender:      sender,
+		Destination: r.Destination,
+		Type:        txType,
+		Quarks:      r.Quarks,
+		Invoice:     r.Invoice,
+		Memo:        r.Memo,
+	}, nil
+}
+
+// FulfillPaymentRequest pays r from sender, once the payer has accepted it,
+// returning the ID of the transaction. The request is verified (see
+// PaymentRequest.Verify) before the payment is submitted.
+func (c *client) FulfillPaymentRequest(ctx context.Context, sender kin.PrivateKey, r PaymentRequest, opts ...SolanaOption) ([]byte, error) {
+	if err := r.Verify(c.opts.clock.Now()); err != nil {
+		return nil, err
+	}
+
+	payment, err := r.ToPayment(sender)
+	if err != nil {
+		return nil, err
+	}
+	return c.SubmitPayment(ctx, payment, opts...)
+}
+
+// ParsePaymentRequest parses a payment request URI, or a deep link generated by
+// PaymentRequest.DeepLink.
+func ParsePaymentRequest(uri string) (PaymentRequest, error) {
+	var r PaymentRequest
+
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return r, errors.Wrap(err, "invalid payment request uri")
+	}
+	query := u.Query()
+
+	destination := query.Get("to")
+	if strings.EqualFold(u.Scheme, PaymentRequestScheme) {
+		destination = u.Opaque
+		if destination == "" {
+			// kin://<destination> is accepted, for platforms that require an
+			// authority in links.
+			destination = u.Host
+		}
+	}
+	if destination == "" {
+		return r, errors.New("payment request has no destination")
+	}
+	if r.Destination, err = keyFromJSON(destination); err != nil {
+		return r, errors.Wrap(err, "invalid destination")
+	}
+
+	if amount := query.Get("amount"); amount != "" {
+		if r.Quarks, err = kin.ToQuarks(amount); err != nil {
+			return r, errors.Wrapf(ErrInvalidAmount, "invalid amount %q", amount)
+		}
+	}
+	if t := query.Get("type"); t != "" {
+		if r.Type, err = transactionTypeFromName(t); err != nil {
+			return r, err
+		}
+	}
+	if app := query.Get("app"); app != "" {
+		appIndex, err := strconv.ParseUint(app, 10, 16)
+		if err != nil {
+			return r, errors.Wrapf(err, "invalid app index %q", app)
+		}
+		r.AppIndex = uint16(appIndex)
+	}
+	if invoice := query.Get("invoice"); invoice != "" {
+		b, err := base64.RawURLEncoding.DecodeString(invoice)
+		if err != nil {
+			return r, errors.Wrap(err, "invalid invoice encoding")
+		}
+		r.Invoice = &commonpb.Invoice{}
+		if err := proto.Unmarshal(b, r.Invoice); err != nil {
+			return r, errors.Wrap(err, "invalid invoice")
+		}
+	}
+	if sku := query.Get("sku"); sku != "" {
+		if r.SKU, err = base64.RawURLEncoding.DecodeString(sku); err != nil {
+			return r, errors.Wrap(err, "invalid sku encoding")
+		}
+	}
+	r.Memo = query.Get("memo")
+	r.Label = query.Get("label")
+	if expires := query.Get("expires"); expires != "" {
+		seconds, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || seconds <= 0 {
+			return r, errors.Errorf("invalid expiry %q", expires)
+		}
+		r.Expires = time.Unix(seconds, 0)
+	}
+	if requester := query.Get("requester"); requester != "" {
+		if r.Requester, err = keyFromJSON(requester); err != nil {
+			return r, errors.Wrap(err, "invalid requester")
+		}
+	}
+	if sig := query.Get("sig"); sig != "" {
+		if r.Signature, err = base64.RawURLEncoding.DecodeString(sig); err != nil {
+			return r, errors.Wrap(err, "invalid signature encoding")
+		}
+	}
+
+	return r, r.validate()
+}
+
+func (r PaymentRequest) validate() error {
+	if len(r.Destination) != 32 {
+		return errors.New("payment request has no destination")
+	}
+	if r.Quarks != 0 {
+		if err := validateQuarks(r.Quarks); err != nil {
+			return err
+		}
+	}
+	if r.Requester != nil && len(r.Requester) != 32 {
+		return errors.New("invalid requester")
+	}
+	if r.Signature != nil && r.Requester == nil {
+		return errors.New("signed payment requests require a requester")
+	}
+	if r.Invoice != nil {
+		if r.AppIndex == 0 {
+			return errors.New("payment requests with invoices require an app index")
+		}
+		if err := r.Invoice.Validate(); err != nil {
+			return errors.Wrap(err, "invalid invoice")
+		}
+
+		var total int64
+		for _, item := range r.Invoice.Items {
+			total += item.Amount
+		}
+		if total != r.Quarks {
+			return errors.Errorf("invoice total (%d) does not match amount (%d)", total, r.Quarks)
+		}
+	}
+	return nil
+}
+
+func (r PaymentRequest) params() (url.Values, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	if r.Quarks != 0 {
+		params.Set("amount", kin.FromQuarks(r.Quarks))
+	}
+	if r.Type != kin.TransactionTypeNone && r.Type != kin.TransactionTypeUnknown {
+		params.Set("type", transactionTypeName(r.Type))
+	}
+	if r.AppIndex != 0 {
+		params.Set("app", strconv.Itoa(int(r.AppIndex)))
+	}
+	if r.Invoice != nil {
+		b, err := proto.Marshal(r.Invoice)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal invoice")
+		}
+		params.Set("invoice", base64.RawURLEncoding.EncodeToString(b))
+	}
+	if len(r.SKU) > 0 {
+		params.Set("sku", base64.RawURLEncoding.EncodeToString(r.SKU))
+	}
+	if r.Memo != "" {
+		params.Set("memo", r.Memo)
+	}
+	if r.Label != "" {
+		params.Set("label", r.Label)
+	}
+	if !r.Expires.IsZero() {
+		params.Set("expires", strconv.FormatInt(r.Expires.Unix(), 10))
+	}
+	if r.Requester != nil {
+		params.Set("requester", r.Requester.Base58())
+	}
+	if len(r.Signature) > 0 {
+		params.Set("sig", base64.RawURLEncoding.EncodeToString(r.Signature))
+	}
+	return params, nil
+}
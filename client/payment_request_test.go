@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+)
+
+func TestPaymentRequest_RoundTrip(t *testing.T) {
+	dest := goldenKey("destination").Public()
+
+	minimal := PaymentRequest{Destination: dest}
+	uri, err := minimal.URI()
+	require.NoError(t, err)
+	assert.Equal(t, "kin:"+dest.Base58(), uri)
+
+	full := PaymentRequest{
+		Destination: dest,
+		Quarks:      150000,
+		Type:        kin.TransactionTypeSpend,
+		AppIndex:    7,
+		Invoice: &commonpb.Invoice{
+			Items: []*commonpb.Invoice_LineItem{
+				{Title: "coffee", Amount: 100000, Sku: []byte("sku-1")},
+				{Title: "cake & tip", Amount: 50000},
+			},
+		},
+		SKU:   []byte{0xff, 0x00},
+		Memo:  "order 1",
+		Label: "Café",
+	}
+	uri, err = full.URI()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(uri, "kin:"+dest.Base58()+"?"), uri)
+	assert.Contains(t, uri, "amount=1.50000")
+	assert.Contains(t, uri, "type=spend")
+	assert.Contains(t, uri, "app=7")
+
+	link, err := full.DeepLink("https://example.com/pay?ref=qr")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(link, "https://example.com/pay?"), link)
+	assert.Contains(t, link, "ref=qr")
+	assert.Contains(t, link, "to="+dest.Base58())
+
+	for _, r := range []PaymentRequest{minimal, full} {
+		uri, err := r.URI()
+		require.NoError(t, err)
+		link, err := r.DeepLink("myapp://pay")
+		require.NoError(t, err)
+
+		for _, encoded := range []string{uri, link, strings.ToUpper(uri[:3]) + uri[3:]} {
+			parsed, err := ParsePaymentRequest(encoded)
+			require.NoError(t, err, encoded)
+			assert.True(t, proto.Equal(r.Invoice, parsed.Invoice))
+			parsed.Invoice = r.Invoice
+			assert.Equal(t, r, parsed)
+		}
+	}
+
+	parsed, err := ParsePaymentRequest("kin://" + dest.Base58() + "?amount=2&unknown=1")
+	require.NoError(t, err)
+	assert.Equal(t, PaymentRequest{Destination: dest, Quarks: 200000}, parsed)
+}
+
+func TestPaymentRequest_Invalid(t *testing.T) {
+	dest := goldenKey("destination").Public()
+	invoice := &commonpb.Invoice{
+		Items: []*commonpb.Invoice_LineItem{{Title: "item", Amount: 10}},
+	}
+
+	for _, r := range []PaymentRequest{
+		{},
+		{Destination: dest, Quarks: -1},
+		{Destination: dest, Quarks: MaxSupplyQuarks + 1},
+		{Destination: dest, Quarks: 10, Invoice: invoice},
+		{Destination: dest, Quarks: 11, AppIndex: 1, Invoice: invoice},
+		{Destination: dest, Quarks: 10, AppIndex: 1, Invoice: &commonpb.Invoice{}},
+	} {
+		_, err := r.URI()
+		assert.Error(t, err)
+		_, err = r.DeepLink("https://example.com/pay")
+		assert.Error(t, err)
+	}
+
+	for _, uri := range []string{
+		"",
+		"kin:",
+		"https://example.com/pay",
+		"kin:invalid",
+		"kin:" + dest.Base58() + "?amount=abc",
+		"kin:" + dest.Base58() + "?amount=-1",
+		"kin:" + dest.Base58() + "?type=gift",
+		"kin:" + dest.Base58() + "?app=65536",
+		"kin:" + dest.Base58() + "?invoice=!",
+		"kin:" + dest.Base58() + "?invoice=AAAA",
+		"kin:" + dest.Base58() + "?sku=!",
+	} {
+		_, err := ParsePaymentRequest(uri)
+		assert.Error(t, err, uri)
+	}
+}
+
+func TestPaymentRequest_ToPayment(t *testing.T) {
+	sender := goldenKey("sender")
+	dest := goldenKey("destination").Public()
+
+	p, err := PaymentRequest{Destination: dest, Quarks: 10, Memo: "memo"}.ToPayment(sender)
+	require.NoError(t, err)
+	assert.Equal(t, Payment{
+		Sender:      sender,
+		Destination: dest,
+		Type:        kin.TransactionTypeP2P,
+		Quarks:      10,
+		Memo:        "memo",
+	}, p)
+
+	invoice := &commonpb.Invoice{
+		Items: []*commonpb.Invoice_LineItem{{Title: "item", Amount: 10}},
+	}
+	p, err = PaymentRequest{
+		Destination: dest,
+		Quarks:      10,
+		Type:        kin.TransactionTypeSpend,
+		AppIndex:    1,
+		Invoice:     invoice,
+	}.ToPayment(sender)
+	require.NoError(t, err)
+	assert.Equal(t, kin.TransactionTypeSpend, p.Type)
+	assert.Equal(t, invoice, p.Invoice)
+
+	// The payer must choose an amount before paying.
+	_, err = PaymentRequest{Destination: dest}.ToPayment(sender)
+	assert.Equal(t, ErrInvalidAmount, errors.Cause(err))
+}
+
+func TestPaymentRequest_Sign(t *testing.T) {
+	requester := goldenKey("requester")
+	dest := goldenKey("destination").Public()
+	now := time.Unix(1600000000, 0)
+
+	r := PaymentRequest{
+		Destination: dest,
+		Quarks:      10,
+		Memo:        "dinner",
+		Expires:     now.Add(time.Hour),
+	}
+	signed, err := r.Sign(requester)
+	require.NoError(t, err)
+	assert.Equal(t, requester.Public(), signed.Requester)
+	require.NoError(t, signed.Verify(now))
+
+	// The signature survives encoding.
+	uri, err := signed.URI()
+	require.NoError(t, err)
+	assert.Contains(t, uri, "requester="+requester.Public().Base58())
+	parsed, err := ParsePaymentRequest(uri)
+	require.NoError(t, err)
+	assert.Equal(t, signed, parsed)
+	require.NoError(t, parsed.Verify(now))
+
+	assert.Equal(t, ErrPaymentRequestExpired, signed.Verify(now.Add(time.Hour)))
+
+	tampered := signed
+	tampered.Quarks = 20
+	assert.Equal(t, ErrInvalidSignature, tampered.Verify(now))
+
+	unsigned := signed
+	unsigned.Signature = nil
+	assert.Equal(t, ErrInvalidSignature, unsigned.Verify(now))
+
+	unsigned.Requester = nil
+	assert.NoError(t, unsigned.Verify(now))
+
+	_, err = ParsePaymentRequest("kin:" + dest.Base58() + "?sig=AAAA")
+	assert.Error(t, err)
+	_, err = ParsePaymentRequest("kin:" + dest.Base58() + "?expires=soon")
+	assert.Error(t, err)
+}
+
+func TestClient_FulfillPaymentRequest(t *testing.T) {
+	clock := &stepClock{now: time.Now()}
+	env, cleanup := setup(t, WithClock(clock))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	requester, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	for _, acc := range []kin.PrivateKey{sender, requester} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	r, err := PaymentRequest{
+		Destination: requester.Public(),
+		Quarks:      10,
+		Expires:     clock.now.Add(time.Minute),
+	}.Sign(requester)
+	require.NoError(t, err)
+
+	txID, err := env.client.FulfillPaymentRequest(context.Background(), sender, r)
+	require.NoError(t, err)
+	assert.NotNil(t, txID)
+
+	tampered := r
+	tampered.Quarks = 20
+	_, err = env.client.FulfillPaymentRequest(context.Background(), sender, tampered)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	clock.mu.Lock()
+	clock.now = clock.now.Add(time.Minute)
+	clock.mu.Unlock()
+	_, err = env.client.FulfillPaymentRequest(context.Background(), sender, r)
+	assert.Equal(t, ErrPaymentRequestExpired, err)
+
+	env.v4Server.Mux.Lock()
+	defer env.v4Server.Mux.Unlock()
+	assert.Len(t, env.v4Server.Submits, 1)
+}
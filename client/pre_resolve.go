@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+)
+
+// WithPreResolution specifies that the sender and destinations of an earn batch
+// should be resolved to their token accounts before the batch is first
+// submitted, rather than only after a submission fails with
+// ErrAccountDoesNotExist.
+//
+// The accounts are resolved concurrently (bounded by WithMaxConcurrency), and
+// resolutions are served from the cache, if one is specified with WithCache.
+// This avoids a failed first submission for batches whose destinations are
+// mostly owner accounts, at the cost of a resolution request for each
+// uncached account. Only accounts whose resolution is preferred (see
+// WithAccountResolution and WithDestResolution) are resolved.
+func WithPreResolution() SolanaOption {
+	return func(o *solanaOpts) {
+		o.preResolve = true
+	}
+}
+
+// preResolveEarnBatch resolves the sender and destinations of batch, returning
+// the batch with its destinations replaced by their token accounts, and the
+// token account of the sender. Accounts without token accounts are left as is,
+// since they may be token accounts themselves.
+func (c *client) preResolveEarnBatch(ctx context.Context, batch EarnBatch, solanaOpts solanaOpts) (resolved EarnBatch, transferSender kin.PublicKey, fromCache bool, err error) {
+	var owners []kin.PublicKey
+	if solanaOpts.accountResolution == AccountResolutionPreferred {
+		owners = append(owners, batch.Sender.Public())
+	}
+	if solanaOpts.destResolution == AccountResolutionPreferred {
+		for _, earn := range batch.Earns {
+			owners = append(owners, earn.Destination)
+		}
+	}
+
+	tokenAccounts, fromCache, err := c.resolveConcurrently(ctx, owners)
+	if err != nil {
+		return batch, nil, false, err
+	}
+
+	resolved = batch
+	if solanaOpts.accountResolution == AccountResolutionPreferred {
+		if accounts := tokenAccounts[string(batch.Sender.Public())]; len(accounts) > 0 {
+			transferSender = accounts[0]
+		}
+	}
+	if solanaOpts.destResolution == AccountResolutionPreferred {
+		resolved.Earns = make([]Earn, len(batch.Earns))
+		for i, earn := range batch.Earns {
+			if accounts := tokenAccounts[string(earn.Destination)]; len(accounts) > 0 {
+				earn.Destination = accounts[0]
+			}
+			resolved.Earns[i] = earn
+		}
+	}
+
+	return resolved, transferSender, fromCache, nil
+}
+
+// resolveConcurrently resolves the token accounts of each distinct owner,
+// returning a map of the resolved token accounts keyed by owner, and whether or
+// not any were served from the cache.
+func (c *client) resolveConcurrently(ctx context.Context, owners []kin.PublicKey) (map[string][]kin.PublicKey, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := c.concurrency()
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	var fromCache bool
+	resolved := make(map[string][]kin.PublicKey, len(owners))
+	seen := make(map[string]struct{}, len(owners))
+	sem := make(chan struct{}, concurrency)
+
+	for _, owner := range owners {
+		if _, ok := seen[string(owner)]; ok {
+			continue
+		}
+		seen[string(owner)] = struct{}{}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(owner kin.PublicKey) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			accounts, cached, err := c.internal.resolveTokenAccounts(ctx, owner, false)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = errors.Wrapf(err, "failed to resolve %s", owner.Base58())
+					cancel()
+				}
+				return
+			}
+			fromCache = fromCache || cached
+			resolved[string(owner)] = make([]kin.PublicKey, len(accounts))
+			for i, a := range accounts {
+				resolved[string(owner)][i] = a.AccountId.Value
+			}
+		}(owner)
+	}
+
+	wg.Wait()
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, false, firstErr
+	}
+	return resolved, fromCache, nil
+}
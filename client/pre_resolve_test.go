@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+
+	"github.com/kinecosystem/kin-go/client/testutil"
+)
+
+func TestClient_PreResolution(t *testing.T) {
+	env, cleanup := setup(t, WithMaxConcurrency(2))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	owners := testutil.GenerateSolanaKeys(t, 3)
+	tokenAccounts := testutil.GenerateSolanaKeys(t, 4)
+
+	// The last destination is a token account, so it has no token accounts of
+	// its own.
+	env.v4Server.Mux.Lock()
+	env.v4Server.TokenAccounts[sender.Public().Base58()] = []*commonpbv4.SolanaAccountId{{Value: tokenAccounts[0]}}
+	for i := 1; i < 3; i++ {
+		env.v4Server.TokenAccounts[kin.PublicKey(owners[i]).Base58()] = []*commonpbv4.SolanaAccountId{{Value: tokenAccounts[i]}}
+	}
+	env.v4Server.Mux.Unlock()
+
+	batch := EarnBatch{
+		Sender: sender,
+		Earns: []Earn{
+			{Destination: kin.PublicKey(owners[1]), Quarks: 1},
+			{Destination: kin.PublicKey(owners[2]), Quarks: 2},
+			{Destination: kin.PublicKey(tokenAccounts[3]), Quarks: 3},
+		},
+	}
+
+	result, err := env.client.SubmitEarnBatch(context.Background(), batch, WithPreResolution())
+	require.NoError(t, err)
+	assert.Nil(t, result.TxError)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 1)
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(env.v4Server.Submits[0].Transaction.Value))
+	env.v4Server.Mux.Unlock()
+
+	expected := [][]byte{tokenAccounts[1], tokenAccounts[2], tokenAccounts[3]}
+	for i, dest := range expected {
+		transfer, err := token.DecompileTransfer(tx.Message, i+1)
+		require.NoError(t, err)
+		assert.EqualValues(t, tokenAccounts[0], transfer.Source)
+		assert.EqualValues(t, dest, transfer.Destination)
+		assert.EqualValues(t, i+1, transfer.Amount)
+	}
+
+	// The batch itself is not modified.
+	assert.EqualValues(t, owners[1], batch.Earns[0].Destination)
+
+	// Accounts are only resolved if their resolution is preferred.
+	env.v4Server.Mux.Lock()
+	env.v4Server.Submits = nil
+	env.v4Server.Mux.Unlock()
+
+	_, err = env.client.SubmitEarnBatch(context.Background(), batch, WithPreResolution(), WithDestResolution(AccountResolutionExact))
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 1)
+	require.NoError(t, tx.Unmarshal(env.v4Server.Submits[0].Transaction.Value))
+	env.v4Server.Mux.Unlock()
+
+	transfer, err := token.DecompileTransfer(tx.Message, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, tokenAccounts[0], transfer.Source)
+	assert.EqualValues(t, owners[1], transfer.Destination)
+}
+
+func TestClient_ResolveTokenAccountsBatch(t *testing.T) {
+	cache := NewMemoryCache()
+	env, cleanup := setup(t, WithMaxConcurrency(2), WithCache(cache))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	owners := testutil.GenerateSolanaKeys(t, 4)
+	tokenAccounts := testutil.GenerateSolanaKeys(t, 4)
+
+	env.v4Server.Mux.Lock()
+	for i := 0; i < 3; i++ {
+		env.v4Server.TokenAccounts[kin.PublicKey(owners[i]).Base58()] = []*commonpbv4.SolanaAccountId{{Value: tokenAccounts[i]}}
+	}
+	env.v4Server.TokenAccounts[kin.PublicKey(owners[0]).Base58()] = append(
+		env.v4Server.TokenAccounts[kin.PublicKey(owners[0]).Base58()],
+		&commonpbv4.SolanaAccountId{Value: tokenAccounts[3]},
+	)
+	env.v4Server.Mux.Unlock()
+
+	accounts := []kin.PublicKey{
+		kin.PublicKey(owners[0]),
+		kin.PublicKey(owners[1]),
+		kin.PublicKey(owners[2]),
+		kin.PublicKey(owners[3]),
+		kin.PublicKey(owners[1]),
+	}
+	resolved, err := env.client.ResolveTokenAccountsBatch(context.Background(), accounts)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]kin.PublicKey{
+		kin.PublicKey(owners[0]).Base58(): {kin.PublicKey(tokenAccounts[0]), kin.PublicKey(tokenAccounts[3])},
+		kin.PublicKey(owners[1]).Base58(): {kin.PublicKey(tokenAccounts[1])},
+		kin.PublicKey(owners[2]).Base58(): {kin.PublicKey(tokenAccounts[2])},
+		kin.PublicKey(owners[3]).Base58(): {},
+	}, resolved)
+
+	// The resolutions are cached.
+	for i := 0; i < 3; i++ {
+		cached, ok := env.client.internal.cache.getResolution(context.Background(), kin.PublicKey(owners[i]))
+		require.True(t, ok)
+		assert.Equal(t, resolved[kin.PublicKey(owners[i]).Base58()], cached)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = env.client.ResolveTokenAccountsBatch(ctx, []kin.PublicKey{kin.PublicKey(owners[3])})
+	assert.Error(t, err)
+}
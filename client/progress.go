@@ -0,0 +1,113 @@
+package client
+
+// EarnBatchStage is a stage of an earn batch submission.
+type EarnBatchStage int
+
+const (
+	// EarnBatchStageBuild indicates the transaction is being built.
+	EarnBatchStageBuild EarnBatchStage = iota
+
+	// EarnBatchStageSign indicates the transaction is being signed. It may be
+	// reported more than once if the transaction has to be re-signed with a
+	// new blockhash.
+	EarnBatchStageSign
+
+	// EarnBatchStageSubmit indicates the transaction is being submitted.
+	EarnBatchStageSubmit
+
+	// EarnBatchStageConfirm indicates the submission has completed at the
+	// requested commitment, successfully or otherwise.
+	EarnBatchStageConfirm
+)
+
+// EarnBatchProgress describes the progress of an earn batch submission.
+type EarnBatchProgress struct {
+	Stage EarnBatchStage
+
+	// Chunk is the index of the chunk being submitted by SubmitEarnBatches or
+	// SubmitEarnBatchAsync. It is always 0 for SubmitEarnBatch.
+	Chunk int
+
+	// Attempt is the submission attempt, starting at 0. It is incremented when
+	// the batch is resubmitted after resolving token accounts.
+	Attempt int
+
+	Earns  int
+	Quarks int64
+
+	// TxID is set for EarnBatchStageSubmit and EarnBatchStageConfirm.
+	TxID []byte
+
+	// Err is set for EarnBatchStageConfirm if the submission failed.
+	Err error
+}
+
+// WithProgress specifies a function that is called as an earn batch
+// submission progresses through each stage.
+//
+// The function is called synchronously, and should not block. When used with
+// SubmitEarnBatches or SubmitEarnBatchAsync, chunks are submitted concurrently
+// (see WithChunkParallelism), so the function may be called concurrently, and
+// must be safe for concurrent use.
+func WithProgress(f func(EarnBatchProgress)) SolanaOption {
+	return func(o *solanaOpts) {
+		o.progress = f
+	}
+}
+
+// stageFunc reports the stage of a submission. It may be nil.
+type stageFunc func(stage EarnBatchStage, txID []byte)
+
+func (f stageFunc) report(stage EarnBatchStage, txID []byte) {
+	if f != nil {
+		f(stage, txID)
+	}
+}
+
+func earnBatchReporter(f func(EarnBatchProgress), batch EarnBatch, attempt int) stageFunc {
+	if f == nil {
+		return nil
+	}
+
+	quarks := earnBatchQuarks(batch)
+
+	return func(stage EarnBatchStage, txID []byte) {
+		f(EarnBatchProgress{
+			Stage:   stage,
+			Attempt: attempt,
+			Earns:   len(batch.Earns),
+			Quarks:  quarks,
+			TxID:    txID,
+		})
+	}
+}
+
+func reportEarnBatchResult(f func(EarnBatchProgress), batch EarnBatch, attempt int, result SubmitTransactionResult, err error) {
+	if f == nil {
+		return
+	}
+
+	if err == nil {
+		err = result.Errors.TxError
+	}
+	if err == nil && len(result.InvoiceErrors) > 0 {
+		err = ErrTransactionRejected
+	}
+
+	f(EarnBatchProgress{
+		Stage:   EarnBatchStageConfirm,
+		Attempt: attempt,
+		Earns:   len(batch.Earns),
+		Quarks:  earnBatchQuarks(batch),
+		TxID:    result.ID,
+		Err:     err,
+	})
+}
+
+func earnBatchQuarks(batch EarnBatch) int64 {
+	var quarks int64
+	for _, e := range batch.Earns {
+		quarks += e.Quarks
+	}
+	return quarks
+}
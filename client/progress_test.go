@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+func TestClient_SubmitEarnBatchProgress(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	other, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	for _, k := range []kin.PrivateKey{sender, dest, other} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), k))
+	}
+
+	// The first submission fails, causing a resubmission after resolution.
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		{
+			Result: transactionpbv4.SubmitTransactionResponse_FAILED,
+			TransactionError: &commonpbv4.TransactionError{
+				Reason: commonpbv4.TransactionError_INVALID_ACCOUNT,
+				Raw:    []byte("rawerror"),
+			},
+		},
+	}
+	env.v4Server.Mux.Unlock()
+
+	var progress []EarnBatchProgress
+	result, err := env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns: []Earn{
+			{Destination: dest.Public(), Quarks: 1},
+			{Destination: other.Public(), Quarks: 2},
+		},
+	}, WithProgress(func(p EarnBatchProgress) {
+		progress = append(progress, p)
+	}))
+	require.NoError(t, err)
+	assert.Nil(t, result.TxError)
+
+	expected := []EarnBatchStage{
+		EarnBatchStageBuild,
+		EarnBatchStageSign,
+		EarnBatchStageSubmit,
+		EarnBatchStageConfirm,
+	}
+	require.Len(t, progress, 2*len(expected))
+	for i, p := range progress {
+		assert.Equal(t, expected[i%len(expected)], p.Stage)
+		assert.Equal(t, i/len(expected), p.Attempt)
+		assert.Equal(t, 0, p.Chunk)
+		assert.Equal(t, 2, p.Earns)
+		assert.EqualValues(t, 3, p.Quarks)
+
+		if p.Stage == EarnBatchStageSubmit || p.Stage == EarnBatchStageConfirm {
+			assert.NotNil(t, p.TxID)
+		}
+	}
+	assert.Equal(t, ErrAccountDoesNotExist, progress[3].Err)
+	assert.NoError(t, progress[7].Err)
+	assert.Equal(t, result.TxID, progress[7].TxID)
+}
+
+func TestClient_SubmitEarnBatchesProgress(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	batch := generateLargeEarnBatch(t, env, 2*MaxBatchSize+5)
+
+	// Chunks are submitted concurrently, so progress is reported concurrently.
+	var mu sync.Mutex
+	progress := make(map[int][]EarnBatchProgress)
+	results, err := env.client.SubmitEarnBatches(context.Background(), batch, WithChunkParallelism(3), WithProgress(func(p EarnBatchProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress[p.Chunk] = append(progress[p.Chunk], p)
+	}))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	expected := []EarnBatchStage{
+		EarnBatchStageBuild,
+		EarnBatchStageSign,
+		EarnBatchStageSubmit,
+		EarnBatchStageConfirm,
+	}
+	offset := 0
+	require.Len(t, progress, len(results))
+	for chunk, r := range results {
+		earns := MaxBatchSize
+		if chunk == len(results)-1 {
+			earns = len(batch.Earns) - 2*MaxBatchSize
+		}
+		quarks := earnBatchQuarks(EarnBatch{Earns: batch.Earns[offset : offset+earns]})
+		offset += earns
+
+		require.Len(t, progress[chunk], len(expected))
+		for i, p := range progress[chunk] {
+			assert.Equal(t, expected[i], p.Stage)
+			assert.Equal(t, chunk, p.Chunk)
+			assert.Equal(t, 0, p.Attempt)
+			assert.Equal(t, earns, p.Earns)
+			assert.Equal(t, quarks, p.Quarks)
+		}
+		assert.Equal(t, r.TxID, progress[chunk][len(expected)-1].TxID)
+		assert.NoError(t, progress[chunk][len(expected)-1].Err)
+	}
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"github.com/golang/protobuf/ptypes"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/pkg/errors"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+// ToProto converts the transaction data to a transactionpbv4.HistoryItem, the
+// representation used by Agora, so that it can be passed over other gRPC
+// APIs.
+//
+// Since a HistoryItem contains the raw transaction, the transaction data must
+// have been retrieved using WithRawTransaction. Payments must either all have
+// an invoice, or none. Errors are converted to the closest
+// commonpbv4.TransactionError_Reason, and the resulting item therefore only
+// records the first of the OpErrors.
+func (t TransactionData) ToProto() (*transactionpbv4.HistoryItem, error) {
+	if t.RawTransaction == nil {
+		return nil, errors.New("raw transaction required (see WithRawTransaction)")
+	}
+
+	item := &transactionpbv4.HistoryItem{
+		TransactionId:    &commonpbv4.TransactionId{Value: t.TxID},
+		TransactionError: transactionErrorsToProto(t.Errors),
+		Payments:         make([]*transactionpbv4.HistoryItem_Payment, len(t.Payments)),
+	}
+
+	var indices []uint32
+	switch {
+	case len(t.RawTransaction.Solana) > 0:
+		var tx solana.Transaction
+		if err := tx.Unmarshal(t.RawTransaction.Solana); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal transaction")
+		}
+		for i := range tx.Message.Instructions {
+			if _, err := token.DecompileTransfer(tx.Message, i); err == nil {
+				indices = append(indices, uint32(i))
+			}
+		}
+		if len(indices) != len(t.Payments) {
+			return nil, errors.Errorf("transaction contains %d transfers, but %d payments", len(indices), len(t.Payments))
+		}
+
+		item.RawTransaction = &transactionpbv4.HistoryItem_SolanaTransaction{
+			SolanaTransaction: &commonpbv4.Transaction{Value: t.RawTransaction.Solana},
+		}
+	case len(t.RawTransaction.StellarEnvelope) > 0:
+		for i := range t.Payments {
+			indices = append(indices, uint32(i))
+		}
+
+		item.RawTransaction = &transactionpbv4.HistoryItem_StellarTransaction{
+			StellarTransaction: &commonpbv4.StellarTransaction{
+				EnvelopeXdr: t.RawTransaction.StellarEnvelope,
+				ResultXdr:   t.RawTransaction.StellarResult,
+			},
+		}
+	default:
+		return nil, errors.New("raw transaction is empty")
+	}
+
+	var invoices []*commonpb.Invoice
+	for i, p := range t.Payments {
+		item.Payments[i] = &transactionpbv4.HistoryItem_Payment{
+			Source:      &commonpbv4.SolanaAccountId{Value: p.Sender},
+			Destination: &commonpbv4.SolanaAccountId{Value: p.Destination},
+			Amount:      p.Quarks,
+			Index:       indices[i],
+		}
+		if p.Invoice != nil {
+			invoices = append(invoices, p.Invoice)
+		}
+	}
+	if len(invoices) > 0 {
+		if len(invoices) != len(t.Payments) {
+			return nil, errors.New("either all or no payments must have an invoice")
+		}
+		item.InvoiceList = &commonpb.InvoiceList{Invoices: invoices}
+	}
+
+	if !t.BlockTime.IsZero() {
+		ts, err := ptypes.TimestampProto(t.BlockTime)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid block time")
+		}
+		item.TransactionTime = ts
+	}
+
+	return item, nil
+}
+
+// TransactionDataFromProto converts a transactionpbv4.HistoryItem to
+// TransactionData. The state of the transaction is TransactionStateFailed if
+// the item has a transaction error, and TransactionStateSuccess otherwise.
+//
+// A HistoryItem contains neither the slot nor the commitment of the
+// transaction, so they are not set.
+func TransactionDataFromProto(item *transactionpbv4.HistoryItem) (TransactionData, error) {
+	data, err := parseHistoryItem(item)
+	if err != nil {
+		return TransactionData{}, err
+	}
+
+	data.TxID = item.GetTransactionId().GetValue()
+	data.TxState = TransactionStateSuccess
+	if data.Errors.TxError != nil {
+		data.TxState = TransactionStateFailed
+	}
+
+	return data, nil
+}
+
+// ToProto converts the history item to a transactionpbv4.HistoryItem,
+// including its cursor. See TransactionData.ToProto.
+func (h HistoryItem) ToProto() (*transactionpbv4.HistoryItem, error) {
+	item, err := h.TransactionData.ToProto()
+	if err != nil {
+		return nil, err
+	}
+
+	if h.Cursor != nil {
+		item.Cursor = &transactionpbv4.Cursor{Value: h.Cursor}
+	}
+	return item, nil
+}
+
+// HistoryItemFromProto converts a transactionpbv4.HistoryItem to a
+// HistoryItem. See TransactionDataFromProto.
+func HistoryItemFromProto(item *transactionpbv4.HistoryItem) (HistoryItem, error) {
+	data, err := TransactionDataFromProto(item)
+	if err != nil {
+		return HistoryItem{}, err
+	}
+
+	return HistoryItem{
+		TransactionData: data,
+		Cursor:          item.GetCursor().GetValue(),
+		Time:            data.BlockTime,
+	}, nil
+}
+
+// transactionErrorsToProto is the inverse of errorFromProto.
+func transactionErrorsToProto(txErrors TransactionErrors) *commonpbv4.TransactionError {
+	if txErrors.TxError == nil {
+		return nil
+	}
+
+	protoError := &commonpbv4.TransactionError{}
+	switch errors.Cause(txErrors.TxError) {
+	case ErrInvalidSignature:
+		protoError.Reason = commonpbv4.TransactionError_UNAUTHORIZED
+	case ErrBadNonce:
+		protoError.Reason = commonpbv4.TransactionError_BAD_NONCE
+	case ErrBlockhashNotFound:
+		protoError.Reason = commonpbv4.TransactionError_BAD_NONCE
+		if raw, err := solana.NewTransactionError(solana.TransactionErrorBlockhashNotFound).JSONString(); err == nil {
+			protoError.Raw = []byte(raw)
+		}
+	case ErrInsufficientBalance:
+		protoError.Reason = commonpbv4.TransactionError_INSUFFICIENT_FUNDS
+	case ErrAccountDoesNotExist:
+		protoError.Reason = commonpbv4.TransactionError_INVALID_ACCOUNT
+	default:
+		protoError.Reason = commonpbv4.TransactionError_UNKNOWN
+	}
+
+	for i, err := range txErrors.OpErrors {
+		if err != nil {
+			protoError.InstructionIndex = int32(i)
+			break
+		}
+	}
+
+	return protoError
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+func TestTransactionData_Proto(t *testing.T) {
+	c := &client{opts: clientOpts{appIndex: 1}}
+	subsidizer := goldenKey("subsidizer")
+	sender := goldenKey("sender")
+	dest := goldenKey("destination")
+
+	invoice := &commonpb.Invoice{
+		Items: []*commonpb.Invoice_LineItem{{Title: "item", Amount: 10}},
+	}
+	config := &transactionpbv4.GetServiceConfigResponse{
+		SubsidizerAccount: &commonpbv4.SolanaAccountId{Value: subsidizer.Public()},
+		Token:             &commonpbv4.SolanaAccountId{Value: goldenKey("mint").Public()},
+	}
+	tx, signers, _, err := c.buildPaymentTx(payment{Payment: Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      10,
+		Invoice:     invoice,
+	}}, config, nil, subsidizer)
+	require.NoError(t, err)
+	require.NoError(t, tx.Sign(toEd25519(signers)...))
+
+	data := TransactionData{
+		TxID:    tx.Signature(),
+		TxState: TransactionStateFailed,
+		Payments: []ReadOnlyPayment{
+			{
+				Sender:      sender.Public(),
+				Destination: dest.Public(),
+				Type:        kin.TransactionTypeSpend,
+				Quarks:      10,
+				AppIndex:    1,
+				Invoice:     invoice,
+			},
+		},
+		Errors: TransactionErrors{
+			TxError:  ErrBlockhashNotFound,
+			OpErrors: []error{ErrBlockhashNotFound, nil},
+		},
+		BlockTime:      time.Unix(100, 0).UTC(),
+		FeePayer:       subsidizer.Public(),
+		FeeLamports:    2 * LamportsPerSignature,
+		RawTransaction: &RawTransaction{Solana: tx.Marshal()},
+	}
+
+	item, err := data.ToProto()
+	require.NoError(t, err)
+	assert.Equal(t, data.TxID, item.TransactionId.Value)
+	assert.Equal(t, commonpbv4.TransactionError_BAD_NONCE, item.TransactionError.Reason)
+	assert.EqualValues(t, 0, item.TransactionError.InstructionIndex)
+	require.Len(t, item.Payments, 1)
+	assert.EqualValues(t, 1, item.Payments[0].Index)
+	assert.EqualValues(t, 10, item.Payments[0].Amount)
+	assert.True(t, proto.Equal(invoice, item.InvoiceList.Invoices[0]))
+	assert.NoError(t, item.Validate())
+
+	decoded, err := TransactionDataFromProto(item)
+	require.NoError(t, err)
+	assert.Equal(t, data, decoded)
+
+	historyItem := HistoryItem{TransactionData: data, Cursor: []byte("cursor"), Time: data.BlockTime}
+	item, err = historyItem.ToProto()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("cursor"), item.Cursor.Value)
+
+	decodedItem, err := HistoryItemFromProto(item)
+	require.NoError(t, err)
+	assert.Equal(t, historyItem, decodedItem)
+
+	// Successful transactions have no error.
+	data.TxState = TransactionStateSuccess
+	data.Errors = TransactionErrors{}
+	item, err = data.ToProto()
+	require.NoError(t, err)
+	assert.Nil(t, item.TransactionError)
+
+	decoded, err = TransactionDataFromProto(item)
+	require.NoError(t, err)
+	assert.Equal(t, data, decoded)
+
+	// Raw transactions are required, and must match the payments.
+	noRaw := data
+	noRaw.RawTransaction = nil
+	_, err = noRaw.ToProto()
+	assert.Error(t, err)
+
+	mismatched := data
+	mismatched.Payments = append(mismatched.Payments, data.Payments[0])
+	_, err = mismatched.ToProto()
+	assert.Error(t, err)
+
+	mismatched.RawTransaction = &RawTransaction{StellarEnvelope: []byte("envelope")}
+	mismatched.Payments[1].Invoice = nil
+	_, err = mismatched.ToProto()
+	assert.Error(t, err)
+}
+
+func TestTransactionErrorsToProto(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		reason commonpbv4.TransactionError_Reason
+	}{
+		{ErrInvalidSignature, commonpbv4.TransactionError_UNAUTHORIZED},
+		{ErrBadNonce, commonpbv4.TransactionError_BAD_NONCE},
+		{ErrBlockhashNotFound, commonpbv4.TransactionError_BAD_NONCE},
+		{errors.Wrap(ErrInsufficientBalance, "wrapped"), commonpbv4.TransactionError_INSUFFICIENT_FUNDS},
+		{ErrAccountDoesNotExist, commonpbv4.TransactionError_INVALID_ACCOUNT},
+		{errors.New("other"), commonpbv4.TransactionError_UNKNOWN},
+	} {
+		protoError := transactionErrorsToProto(TransactionErrors{
+			TxError:  tc.err,
+			OpErrors: []error{nil, tc.err},
+		})
+		assert.Equal(t, tc.reason, protoError.Reason)
+		assert.EqualValues(t, 1, protoError.InstructionIndex)
+
+		if tc.reason != commonpbv4.TransactionError_UNKNOWN {
+			assert.Equal(t, errors.Cause(tc.err), errorFromProto(protoError))
+		}
+	}
+
+	assert.Nil(t, transactionErrorsToProto(TransactionErrors{}))
+}
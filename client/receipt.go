@@ -0,0 +1,247 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+const (
+	ReceiptAlgorithmHMACSHA256 = "hmac-sha256"
+	ReceiptAlgorithmEd25519    = "ed25519"
+)
+
+// Receipt is a record of a completed transaction, suitable for customer
+// support and auditing purposes.
+//
+// Keys and transaction IDs are base58 encoded.
+type Receipt struct {
+	TxID       string           `json:"tx_id"`
+	Slot       uint64           `json:"slot"`
+	BlockTime  *time.Time       `json:"block_time,omitempty"`
+	Commitment string           `json:"commitment"`
+	Payments   []ReceiptPayment `json:"payments"`
+
+	// Signature is set by SignHMAC or SignEd25519, and covers all other fields.
+	Signature *ReceiptSignature `json:"signature,omitempty"`
+}
+
+// ReceiptPayment is a payment contained in a Receipt.
+type ReceiptPayment struct {
+	Sender      string          `json:"sender"`
+	Destination string          `json:"destination"`
+	Type        string          `json:"type"`
+	AppIndex    uint16          `json:"app_index,omitempty"`
+	Quarks      int64           `json:"quarks"`
+	Memo        string          `json:"memo,omitempty"`
+	Invoice     *ReceiptInvoice `json:"invoice,omitempty"`
+}
+
+// ReceiptInvoice is the invoice of a ReceiptPayment.
+type ReceiptInvoice struct {
+	Items []ReceiptLineItem `json:"items"`
+}
+
+// ReceiptLineItem is a line item of a ReceiptInvoice.
+type ReceiptLineItem struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Amount      int64  `json:"amount"`
+	SKU         []byte `json:"sku,omitempty"`
+}
+
+// ReceiptSignature is the signature of a Receipt.
+type ReceiptSignature struct {
+	Algorithm string `json:"algorithm"`
+
+	// PublicKey is only set for ReceiptAlgorithmEd25519 signatures.
+	PublicKey string `json:"public_key,omitempty"`
+	Value     []byte `json:"value"`
+}
+
+// SignHMAC signs the receipt using HMAC-SHA256 with the specified secret.
+func (r *Receipt) SignHMAC(secret []byte) error {
+	b, err := r.signingBytes()
+	if err != nil {
+		return err
+	}
+
+	h := hmac.New(sha256.New, secret)
+	_, _ = h.Write(b)
+	r.Signature = &ReceiptSignature{
+		Algorithm: ReceiptAlgorithmHMACSHA256,
+		Value:     h.Sum(nil),
+	}
+	return nil
+}
+
+// SignEd25519 signs the receipt with the specified private key.
+func (r *Receipt) SignEd25519(priv kin.PrivateKey) error {
+	b, err := r.signingBytes()
+	if err != nil {
+		return err
+	}
+
+	r.Signature = &ReceiptSignature{
+		Algorithm: ReceiptAlgorithmEd25519,
+		PublicKey: priv.Public().Base58(),
+		Value:     ed25519.Sign(ed25519.PrivateKey(priv), b),
+	}
+	return nil
+}
+
+// VerifyHMAC verifies that the receipt was signed with the specified secret.
+func (r *Receipt) VerifyHMAC(secret []byte) error {
+	if r.Signature == nil || r.Signature.Algorithm != ReceiptAlgorithmHMACSHA256 {
+		return errors.Wrap(ErrInvalidSignature, "receipt is not signed with hmac-sha256")
+	}
+
+	b, err := r.signingBytes()
+	if err != nil {
+		return err
+	}
+
+	h := hmac.New(sha256.New, secret)
+	_, _ = h.Write(b)
+	if !hmac.Equal(h.Sum(nil), r.Signature.Value) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyEd25519 verifies that the receipt was signed by the specified public key.
+func (r *Receipt) VerifyEd25519(pub kin.PublicKey) error {
+	if r.Signature == nil || r.Signature.Algorithm != ReceiptAlgorithmEd25519 {
+		return errors.Wrap(ErrInvalidSignature, "receipt is not signed with ed25519")
+	}
+	if r.Signature.PublicKey != pub.Base58() {
+		return errors.Wrap(ErrInvalidSignature, "receipt signed by a different key")
+	}
+
+	b, err := r.signingBytes()
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(pub), b, r.Signature.Value) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// signingBytes returns the canonical encoding of the receipt, excluding the signature.
+func (r *Receipt) signingBytes() ([]byte, error) {
+	unsigned := *r
+	unsigned.Signature = nil
+
+	b, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal receipt")
+	}
+	return b, nil
+}
+
+// GetReceipt returns a Receipt for a successful transaction.
+//
+// Unless otherwise specified with WithCommitment, the transaction is looked up
+// with commonpbv4.Commitment_MAX. ErrTransactionNotFound is returned if the
+// transaction has not reached the commitment.
+func (c *client) GetReceipt(ctx context.Context, txID []byte, opts ...SolanaOption) (Receipt, error) {
+	solanaOpts := solanaOpts{commitment: commonpbv4.Commitment_MAX}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	resp, err := c.internal.getTransaction(ctx, txID, solanaOpts.commitment)
+	if err != nil {
+		return Receipt{}, err
+	}
+
+	switch resp.State {
+	case transactionpbv4.GetTransactionResponse_SUCCESS:
+	case transactionpbv4.GetTransactionResponse_FAILED:
+		return Receipt{}, errors.Wrap(errorFromProto(resp.GetItem().GetTransactionError()), "transaction failed")
+	default:
+		return Receipt{}, ErrTransactionNotFound
+	}
+	if resp.Item == nil {
+		return Receipt{}, ErrTransactionNotFound
+	}
+
+	data, err := parseHistoryItem(resp.Item)
+	if err != nil {
+		return Receipt{}, errors.Wrap(err, "failed to parse payments")
+	}
+	payments := data.Payments
+
+	receipt := Receipt{
+		TxID:       base58.Encode(txID),
+		Slot:       resp.Slot,
+		Commitment: strings.ToLower(solanaOpts.commitment.String()),
+		Payments:   make([]ReceiptPayment, len(payments)),
+	}
+	if !data.BlockTime.IsZero() {
+		t := data.BlockTime.UTC()
+		receipt.BlockTime = &t
+	}
+
+	for i, p := range payments {
+		receipt.Payments[i] = ReceiptPayment{
+			Sender:      p.Sender.Base58(),
+			Destination: p.Destination.Base58(),
+			Type:        transactionTypeName(p.Type),
+			AppIndex:    p.AppIndex,
+			Quarks:      p.Quarks,
+			Memo:        p.Memo,
+			Invoice:     receiptInvoice(p.Invoice),
+		}
+	}
+
+	return receipt, nil
+}
+
+func receiptInvoice(invoice *commonpb.Invoice) *ReceiptInvoice {
+	if invoice == nil {
+		return nil
+	}
+
+	r := &ReceiptInvoice{
+		Items: make([]ReceiptLineItem, len(invoice.Items)),
+	}
+	for i, item := range invoice.Items {
+		r.Items[i] = ReceiptLineItem{
+			Title:       item.Title,
+			Description: item.Description,
+			Amount:      item.Amount,
+			SKU:         item.Sku,
+		}
+	}
+	return r
+}
+
+func transactionTypeName(t kin.TransactionType) string {
+	switch t {
+	case kin.TransactionTypeNone:
+		return "none"
+	case kin.TransactionTypeEarn:
+		return "earn"
+	case kin.TransactionTypeSpend:
+		return "spend"
+	case kin.TransactionTypeP2P:
+		return "p2p"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+func TestClient_GetReceipt(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	_, err := env.client.GetReceipt(context.Background(), make([]byte, 64))
+	assert.Equal(t, ErrTransactionNotFound, err)
+
+	_, txData, resp := generateV4SolanaPayments(t, true)
+	blockTime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	resp.Slot = 10
+	resp.Item.TransactionTime, err = ptypes.TimestampProto(blockTime)
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.Gets[string(txData.TxID)] = resp
+	env.v4Server.Mux.Unlock()
+
+	receipt, err := env.client.GetReceipt(context.Background(), txData.TxID)
+	require.NoError(t, err)
+	assert.Equal(t, base58.Encode(txData.TxID), receipt.TxID)
+	assert.EqualValues(t, 10, receipt.Slot)
+	assert.Equal(t, blockTime, *receipt.BlockTime)
+	assert.Equal(t, "max", receipt.Commitment)
+	assert.Nil(t, receipt.Signature)
+
+	require.Len(t, receipt.Payments, len(txData.Payments))
+	for i, p := range receipt.Payments {
+		assert.Equal(t, txData.Payments[i].Sender.Base58(), p.Sender)
+		assert.Equal(t, txData.Payments[i].Destination.Base58(), p.Destination)
+		assert.Equal(t, "spend", p.Type)
+		assert.EqualValues(t, 1, p.AppIndex)
+		assert.Equal(t, txData.Payments[i].Quarks, p.Quarks)
+		require.NotNil(t, p.Invoice)
+		require.Len(t, p.Invoice.Items, len(txData.Payments[i].Invoice.Items))
+		assert.Equal(t, txData.Payments[i].Invoice.Items[0].Title, p.Invoice.Items[0].Title)
+	}
+
+	resp.State = transactionpbv4.GetTransactionResponse_FAILED
+	resp.Item.TransactionError = &commonpbv4.TransactionError{
+		Reason: commonpbv4.TransactionError_UNAUTHORIZED,
+		Raw:    []byte("rawerror"),
+	}
+	env.v4Server.Mux.Lock()
+	env.v4Server.Gets[string(txData.TxID)] = resp
+	env.v4Server.Mux.Unlock()
+
+	_, err = env.client.GetReceipt(context.Background(), txData.TxID, WithCommitment(commonpbv4.Commitment_SINGLE))
+	assert.Equal(t, ErrInvalidSignature, errors.Cause(err))
+}
+
+func TestReceipt_Signing(t *testing.T) {
+	receipt := Receipt{
+		TxID:       "tx",
+		Slot:       10,
+		Commitment: "max",
+		Payments: []ReceiptPayment{
+			{Sender: "a", Destination: "b", Type: "earn", Quarks: 10},
+		},
+	}
+
+	secret := []byte("secret")
+	require.NoError(t, receipt.SignHMAC(secret))
+	assert.NoError(t, receipt.VerifyHMAC(secret))
+	assert.Equal(t, ErrInvalidSignature, errors.Cause(receipt.VerifyHMAC([]byte("other"))))
+
+	// Signatures survive a JSON round trip.
+	b, err := json.Marshal(receipt)
+	require.NoError(t, err)
+	var decoded Receipt
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.NoError(t, decoded.VerifyHMAC(secret))
+
+	decoded.Payments[0].Quarks = 11
+	assert.Equal(t, ErrInvalidSignature, errors.Cause(decoded.VerifyHMAC(secret)))
+
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	other, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	require.NoError(t, receipt.SignEd25519(key))
+	assert.NoError(t, receipt.VerifyEd25519(key.Public()))
+	assert.Equal(t, ErrInvalidSignature, errors.Cause(receipt.VerifyEd25519(other.Public())))
+	assert.Equal(t, ErrInvalidSignature, errors.Cause(receipt.VerifyHMAC(secret)))
+
+	receipt.Slot = 11
+	assert.Equal(t, ErrInvalidSignature, errors.Cause(receipt.VerifyEd25519(key.Public())))
+}
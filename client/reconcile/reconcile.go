@@ -0,0 +1,313 @@
+// Package reconcile reconciles the on-chain history of an account against
+// an app's own records of its payments.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// Record is an app's record of a payment.
+type Record struct {
+	// ID is an app defined identifier for the record.
+	ID string
+
+	// TxID is the ID of the transaction containing the payment.
+	TxID []byte
+
+	// PaymentIndex is the index of the payment within the transaction.
+	PaymentIndex int
+
+	Quarks int64
+}
+
+// RecordSource provides the app's records of payments.
+type RecordSource interface {
+	// Records returns the records of payments involving the account that
+	// were made within the specified time range, [start, end).
+	Records(ctx context.Context, account kin.PublicKey, start, end time.Time) ([]Record, error)
+}
+
+// DiscrepancyType is the type of a Discrepancy.
+type DiscrepancyType int
+
+const (
+	// DiscrepancyMissing indicates a record has no corresponding successful
+	// payment on-chain.
+	DiscrepancyMissing DiscrepancyType = iota
+
+	// DiscrepancyOrphaned indicates an on-chain payment has no corresponding
+	// record.
+	DiscrepancyOrphaned
+
+	// DiscrepancyAmountMismatch indicates the amount of a record differs from
+	// that of its on-chain payment.
+	DiscrepancyAmountMismatch
+)
+
+// Discrepancy is a difference between the on-chain history of an account
+// and the app's records.
+type Discrepancy struct {
+	Type DiscrepancyType
+
+	TxID         []byte
+	PaymentIndex int
+
+	// Record is the app's record. It is nil for DiscrepancyOrphaned.
+	Record *Record
+
+	// Payment is the on-chain payment. It is nil for DiscrepancyMissing.
+	Payment *client.ReadOnlyPayment
+}
+
+// Report is the result of a reconciliation.
+type Report struct {
+	Account    kin.PublicKey
+	Start, End time.Time
+
+	// Matched is the number of records that matched on-chain payments.
+	Matched int
+
+	Discrepancies []Discrepancy
+
+	// Undated is the number of transactions in the account's history without
+	// a known time. They could not be attributed to the time range, and were
+	// not reconciled.
+	Undated int
+
+	// Cursor is the cursor of the newest transaction walked within the time
+	// range. It can be passed to ReconcileSince to resume reconciliation after
+	// the report, or persisted as a resume token for GetHistory or Subscribe.
+	Cursor client.Cursor
+}
+
+// Reconciler reconciles account history against a RecordSource.
+type Reconciler struct {
+	client client.Client
+	source RecordSource
+}
+
+// New returns a new Reconciler.
+func New(c client.Client, source RecordSource) *Reconciler {
+	return &Reconciler{
+		client: c,
+		source: source,
+	}
+}
+
+type paymentKey struct {
+	txID  string
+	index int
+}
+
+// Reconcile reconciles the payments of successful transactions in the history of
+// an account made within [start, end) against the records provided by the source.
+//
+// The history is walked from newest to oldest, so reconciling recent time ranges
+// is cheaper than older ones. Records whose transaction falls just outside the
+// time range (for example, due to clock differences) are reported as missing.
+func (r *Reconciler) Reconcile(ctx context.Context, account kin.PublicKey, start, end time.Time) (Report, error) {
+	report := Report{
+		Account: account,
+		Start:   start,
+		End:     end,
+	}
+
+	var payments history
+	var cursor client.Cursor
+walk:
+	for {
+		items, err := r.client.GetHistory(ctx, account, cursor, client.WithDescending())
+		if err != nil {
+			return Report{}, errors.Wrap(err, "failed to get history")
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, item := range items {
+			if item.Time.IsZero() {
+				report.Undated++
+				continue
+			}
+			if !item.Time.Before(end) {
+				continue
+			}
+			if item.Time.Before(start) {
+				break walk
+			}
+			if report.Cursor == nil {
+				report.Cursor = item.Cursor
+			}
+
+			payments.add(item)
+		}
+
+		cursor = items[len(items)-1].Cursor
+	}
+
+	if err := r.match(ctx, &report, payments); err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// ReconcileSince reconciles the payments of successful transactions in the
+// history of an account after the since cursor, and before end, against the
+// records provided by the source. It allows reconciliations to resume from the
+// Cursor of a previous Report, rather than from a point in time.
+//
+// The history is walked from oldest to newest. The start of the report is the
+// time of the first transaction after since, and records made before it are not
+// reconciled. If there are no transactions after since, the report is empty,
+// and its Cursor is since.
+func (r *Reconciler) ReconcileSince(ctx context.Context, account kin.PublicKey, since client.Cursor, end time.Time) (Report, error) {
+	report := Report{
+		Account: account,
+		Start:   end,
+		End:     end,
+		Cursor:  since,
+	}
+
+	var payments history
+	var dated bool
+	cursor := since
+walk:
+	for {
+		items, err := r.client.GetHistory(ctx, account, cursor)
+		if err != nil {
+			return Report{}, errors.Wrap(err, "failed to get history")
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, item := range items {
+			if item.Time.IsZero() {
+				report.Undated++
+				report.Cursor = item.Cursor
+				continue
+			}
+			if !item.Time.Before(end) {
+				break walk
+			}
+			if !dated {
+				report.Start = item.Time
+				dated = true
+			}
+			report.Cursor = item.Cursor
+
+			payments.add(item)
+		}
+
+		cursor = items[len(items)-1].Cursor
+	}
+
+	if !dated {
+		return report, nil
+	}
+	if err := r.match(ctx, &report, payments); err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// history contains the payments of the successful transactions walked by a
+// reconciliation.
+type history struct {
+	payments map[paymentKey]client.ReadOnlyPayment
+	order    []paymentKey
+}
+
+func (h *history) add(item client.HistoryItem) {
+	if item.TxState != client.TransactionStateSuccess {
+		return
+	}
+	if h.payments == nil {
+		h.payments = make(map[paymentKey]client.ReadOnlyPayment)
+	}
+
+	for i, p := range item.Payments {
+		key := paymentKey{txID: string(item.TxID), index: i}
+		h.payments[key] = p
+		h.order = append(h.order, key)
+	}
+}
+
+// match matches the records of the report's time range against payments,
+// adding the results to report.
+func (r *Reconciler) match(ctx context.Context, report *Report, payments history) error {
+	records, err := r.source.Records(ctx, report.Account, report.Start, report.End)
+	if err != nil {
+		return errors.Wrap(err, "failed to get records")
+	}
+
+	matched := make(map[paymentKey]struct{})
+	for i := range records {
+		record := &records[i]
+		key := paymentKey{txID: string(record.TxID), index: record.PaymentIndex}
+
+		p, ok := payments.payments[key]
+		if !ok {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Type:         DiscrepancyMissing,
+				TxID:         record.TxID,
+				PaymentIndex: record.PaymentIndex,
+				Record:       record,
+			})
+			continue
+		}
+
+		matched[key] = struct{}{}
+		if p.Quarks != record.Quarks {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Type:         DiscrepancyAmountMismatch,
+				TxID:         record.TxID,
+				PaymentIndex: record.PaymentIndex,
+				Record:       record,
+				Payment:      &p,
+			})
+			continue
+		}
+
+		report.Matched++
+	}
+
+	for _, key := range payments.order {
+		if _, ok := matched[key]; ok {
+			continue
+		}
+
+		p := payments.payments[key]
+		report.Discrepancies = append(report.Discrepancies, Discrepancy{
+			Type:         DiscrepancyOrphaned,
+			TxID:         []byte(key.txID),
+			PaymentIndex: key.index,
+			Payment:      &p,
+		})
+	}
+
+	return nil
+}
+
+// String returns a human readable description of the discrepancy.
+func (d Discrepancy) String() string {
+	payment := fmt.Sprintf("%s[%d]", base58.Encode(d.TxID), d.PaymentIndex)
+	switch d.Type {
+	case DiscrepancyMissing:
+		return fmt.Sprintf("missing: record %s has no payment at %s", d.Record.ID, payment)
+	case DiscrepancyOrphaned:
+		return fmt.Sprintf("orphaned: payment at %s has no record", payment)
+	case DiscrepancyAmountMismatch:
+		return fmt.Sprintf("amount mismatch: record %s has %d quarks, payment at %s has %d quarks", d.Record.ID, d.Record.Quarks, payment, d.Payment.Quarks)
+	default:
+		return fmt.Sprintf("unknown discrepancy at %s", payment)
+	}
+}
@@ -0,0 +1,172 @@
+package reconcile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+type fakeClient struct {
+	client.Client
+
+	// history is ordered newest first.
+	history  []client.HistoryItem
+	pageSize int
+}
+
+func (f *fakeClient) GetHistory(_ context.Context, _ kin.PublicKey, cursor client.Cursor, _ ...client.SolanaOption) ([]client.HistoryItem, error) {
+	items := f.history
+	if cursor != nil {
+		for i, item := range items {
+			if string(item.Cursor) == string(cursor) {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+	if len(items) > f.pageSize {
+		items = items[:f.pageSize]
+	}
+	return items, nil
+}
+
+type staticSource struct {
+	records    []Record
+	start, end time.Time
+}
+
+func (s *staticSource) Records(_ context.Context, _ kin.PublicKey, start, end time.Time) ([]Record, error) {
+	s.start, s.end = start, end
+	return s.records, nil
+}
+
+func historyItem(id string, t time.Time, state client.TransactionState, quarks ...int64) client.HistoryItem {
+	item := client.HistoryItem{
+		TransactionData: client.TransactionData{
+			TxID:    []byte(id),
+			TxState: state,
+		},
+		Cursor: []byte(id),
+		Time:   t,
+	}
+	for _, q := range quarks {
+		item.Payments = append(item.Payments, client.ReadOnlyPayment{Quarks: q})
+	}
+	return item
+}
+
+func TestReconcile(t *testing.T) {
+	account, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	fc := &fakeClient{
+		pageSize: 2,
+		history: []client.HistoryItem{
+			historyItem("after", end, client.TransactionStateSuccess, 1),
+			historyItem("tx4", end.Add(-time.Second), client.TransactionStateSuccess, 40),
+			historyItem("undated", time.Time{}, client.TransactionStateSuccess, 1),
+			historyItem("failed", start.Add(2*time.Hour), client.TransactionStateFailed, 30),
+			historyItem("tx2", start.Add(time.Hour), client.TransactionStateSuccess, 20, 21),
+			historyItem("tx1", start, client.TransactionStateSuccess, 10),
+			historyItem("before", start.Add(-time.Second), client.TransactionStateSuccess, 1),
+		},
+	}
+	source := &staticSource{
+		records: []Record{
+			{ID: "r1", TxID: []byte("tx1"), Quarks: 10},
+			{ID: "r2", TxID: []byte("tx2"), PaymentIndex: 1, Quarks: 22},
+			{ID: "r3", TxID: []byte("failed"), Quarks: 30},
+			{ID: "r4", TxID: []byte("tx4"), Quarks: 40},
+		},
+	}
+
+	report, err := New(fc, source).Reconcile(context.Background(), account.Public(), start, end)
+	require.NoError(t, err)
+
+	assert.Equal(t, start, source.start)
+	assert.Equal(t, end, source.end)
+
+	assert.Equal(t, 2, report.Matched)
+	assert.Equal(t, 1, report.Undated)
+	assert.Equal(t, client.Cursor("tx4"), report.Cursor)
+	require.Len(t, report.Discrepancies, 3)
+
+	mismatch := report.Discrepancies[0]
+	assert.Equal(t, DiscrepancyAmountMismatch, mismatch.Type)
+	assert.Equal(t, "r2", mismatch.Record.ID)
+	assert.EqualValues(t, 21, mismatch.Payment.Quarks)
+
+	missing := report.Discrepancies[1]
+	assert.Equal(t, DiscrepancyMissing, missing.Type)
+	assert.Equal(t, "r3", missing.Record.ID)
+	assert.Nil(t, missing.Payment)
+
+	orphaned := report.Discrepancies[2]
+	assert.Equal(t, DiscrepancyOrphaned, orphaned.Type)
+	assert.Equal(t, []byte("tx2"), orphaned.TxID)
+	assert.Equal(t, 0, orphaned.PaymentIndex)
+	assert.Nil(t, orphaned.Record)
+	assert.EqualValues(t, 20, orphaned.Payment.Quarks)
+
+	for _, d := range report.Discrepancies {
+		assert.NotEmpty(t, d.String())
+	}
+}
+
+func TestReconcileSince(t *testing.T) {
+	account, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	fc := &fakeClient{
+		pageSize: 2,
+		history: []client.HistoryItem{
+			historyItem("before", start.Add(-time.Second), client.TransactionStateSuccess, 1),
+			historyItem("tx1", start, client.TransactionStateSuccess, 10),
+			historyItem("failed", start.Add(time.Hour), client.TransactionStateFailed, 30),
+			historyItem("undated", time.Time{}, client.TransactionStateSuccess, 1),
+			historyItem("tx2", start.Add(2*time.Hour), client.TransactionStateSuccess, 20),
+			historyItem("after", end, client.TransactionStateSuccess, 1),
+		},
+	}
+	source := &staticSource{
+		records: []Record{
+			{ID: "r1", TxID: []byte("tx1"), Quarks: 10},
+			{ID: "r2", TxID: []byte("tx2"), Quarks: 21},
+		},
+	}
+
+	r := New(fc, source)
+	report, err := r.ReconcileSince(context.Background(), account.Public(), client.Cursor("before"), end)
+	require.NoError(t, err)
+
+	assert.Equal(t, start, source.start)
+	assert.Equal(t, end, source.end)
+	assert.Equal(t, start, report.Start)
+	assert.Equal(t, client.Cursor("tx2"), report.Cursor)
+
+	assert.Equal(t, 1, report.Matched)
+	assert.Equal(t, 1, report.Undated)
+	require.Len(t, report.Discrepancies, 1)
+	assert.Equal(t, DiscrepancyAmountMismatch, report.Discrepancies[0].Type)
+
+	// Resuming from the report's cursor skips the reconciled transactions.
+	source.start, source.end = time.Time{}, time.Time{}
+	report, err = r.ReconcileSince(context.Background(), account.Public(), report.Cursor, end)
+	require.NoError(t, err)
+	assert.Equal(t, client.Cursor("tx2"), report.Cursor)
+	assert.Equal(t, end, report.Start)
+	assert.Empty(t, report.Discrepancies)
+	assert.True(t, source.start.IsZero())
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"strconv"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+)
+
+// RefundMemo returns the text memo of the refund of the payment at index of the
+// transaction originalTxID, which links the refund to the original payment.
+func RefundMemo(originalTxID []byte, index int) string {
+	return "refund:" + base58.Encode(originalTxID) + ":" + strconv.Itoa(index)
+}
+
+// RefundDedupeID returns the DedupeID of the refund of the payment at index of
+// the transaction originalTxID. Since it is derived from the original payment,
+// the service rejects a second refund of the same payment, even if it is
+// submitted by a different process.
+//
+// Agora only remembers DedupeIDs for a limited retention window, so this does
+// not prevent a payment from being refunded again after the window has passed.
+// Refunds can be found later by their memo (see RefundMemo).
+func RefundDedupeID(originalTxID []byte, index int) []byte {
+	h := sha256.Sum256([]byte("kin-go/refund:" + base58.Encode(originalTxID) + ":" + strconv.Itoa(index)))
+	return h[:]
+}
+
+// RefundPayment returns the payment from refunder that refunds the payment at
+// index of original, which must have been paid to refunder.
+//
+// The full amount is returned to the original sender. The refund of a spend is
+// an earn, and any other payment is refunded with a P2P payment.
+func RefundPayment(refunder kin.PrivateKey, original TransactionData, index int) (Payment, error) {
+	if original.TxState != TransactionStateSuccess {
+		return Payment{}, errors.New("only successful transactions can be refunded")
+	}
+	if index < 0 || index >= len(original.Payments) {
+		return Payment{}, errors.Errorf("transaction has no payment at index %d", index)
+	}
+	p := original.Payments[index]
+
+	txType := kin.TransactionTypeP2P
+	if p.Type == kin.TransactionTypeSpend {
+		txType = kin.TransactionTypeEarn
+	}
+
+	return Payment{
+		Sender:      refunder,
+		Destination: p.Sender,
+		Type:        txType,
+		Quarks:      p.Quarks,
+		Memo:        RefundMemo(original.TxID, index),
+		DedupeID:    RefundDedupeID(original.TxID, index),
+	}, nil
+}
+
+// Refund refunds the payment at index of the transaction originalTxID from
+// refunder, which must own the destination of the payment, returning the ID of
+// the refund transaction (see RefundPayment).
+//
+// The refund is submitted with the DedupeID returned by RefundDedupeID, so if
+// the payment was already refunded within Agora's dedupe retention window, the
+// returned error's cause is ErrAlreadySubmitted, and it identifies the existing
+// refund.
+func (c *client) Refund(ctx context.Context, refunder kin.PrivateKey, originalTxID []byte, index int, opts ...SolanaOption) ([]byte, error) {
+	original, err := c.GetTransaction(ctx, originalTxID, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get original transaction")
+	}
+	if original.TxID == nil {
+		original.TxID = originalTxID
+	}
+
+	payment, err := RefundPayment(refunder, original, index)
+	if err != nil {
+		return nil, err
+	}
+
+	// The destination of a Kin 4 payment is a token account, so it is only
+	// known to belong to the refunder if it is one of the refunder's accounts.
+	destination := original.Payments[index].Destination
+	if !bytes.Equal(destination, refunder.Public()) {
+		accounts, err := c.ResolveTokenAccounts(ctx, refunder.Public())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve refunder accounts")
+		}
+
+		owned := false
+		for _, account := range accounts {
+			owned = owned || bytes.Equal(account, destination)
+		}
+		if !owned {
+			return nil, errors.New("refunder does not own the destination of the payment")
+		}
+	}
+
+	return c.SubmitPayment(ctx, payment, opts...)
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	solanamemo "github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+func TestRefundPayment(t *testing.T) {
+	refunder := goldenKey("refunder")
+	sender := goldenKey("sender").Public()
+	txID := make([]byte, 64)
+
+	original := TransactionData{
+		TxID:    txID,
+		TxState: TransactionStateSuccess,
+		Payments: []ReadOnlyPayment{
+			{Sender: sender, Destination: refunder.Public(), Type: kin.TransactionTypeSpend, Quarks: 10},
+			{Sender: sender, Destination: refunder.Public(), Type: kin.TransactionTypeP2P, Quarks: 20},
+		},
+	}
+
+	p, err := RefundPayment(refunder, original, 0)
+	require.NoError(t, err)
+	assert.Equal(t, Payment{
+		Sender:      refunder,
+		Destination: sender,
+		Type:        kin.TransactionTypeEarn,
+		Quarks:      10,
+		Memo:        RefundMemo(txID, 0),
+		DedupeID:    RefundDedupeID(txID, 0),
+	}, p)
+
+	p, err = RefundPayment(refunder, original, 1)
+	require.NoError(t, err)
+	assert.Equal(t, kin.TransactionTypeP2P, p.Type)
+	assert.EqualValues(t, 20, p.Quarks)
+
+	assert.NotEqual(t, RefundDedupeID(txID, 0), RefundDedupeID(txID, 1))
+
+	_, err = RefundPayment(refunder, original, 2)
+	assert.Error(t, err)
+	_, err = RefundPayment(refunder, original, -1)
+	assert.Error(t, err)
+
+	original.TxState = TransactionStateFailed
+	_, err = RefundPayment(refunder, original, 0)
+	assert.Error(t, err)
+}
+
+func TestClient_Refund(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	refunder, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	for _, acc := range []kin.PrivateKey{sender, refunder} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	tokenAccount, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	other, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	txID := generateRefundable(t, env.v4Server, sender, refunder.Public(), tokenAccount.Public(), other.Public())
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.TokenAccounts[refunder.Public().Base58()] = append(
+		env.v4Server.TokenAccounts[refunder.Public().Base58()],
+		&commonpbv4.SolanaAccountId{Value: tokenAccount.Public()},
+	)
+	env.v4Server.Mux.Unlock()
+
+	// Payments to the refunder, or to its token accounts, can be refunded.
+	for i := 0; i < 2; i++ {
+		_, err = env.client.Refund(context.Background(), refunder, txID, i)
+		require.NoError(t, err)
+	}
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 2)
+	for i, submit := range env.v4Server.Submits {
+		assert.Equal(t, RefundDedupeID(txID, i), submit.DedupeId)
+
+		var tx solana.Transaction
+		require.NoError(t, tx.Unmarshal(submit.Transaction.Value))
+
+		var memo string
+		var transfer *token.DecompiledTransfer
+		for j := range tx.Message.Instructions {
+			if m, err := solanamemo.DecompileMemo(tx.Message, j); err == nil {
+				memo = string(m.Data)
+			}
+			if tr, err := token.DecompileTransfer(tx.Message, j); err == nil {
+				transfer = tr
+			}
+		}
+		assert.Equal(t, RefundMemo(txID, i), memo)
+		require.NotNil(t, transfer)
+		assert.EqualValues(t, i+1, transfer.Amount)
+		assert.EqualValues(t, refunder.Public(), transfer.Owner)
+	}
+	env.v4Server.Mux.Unlock()
+
+	// Payments to accounts the refunder does not own cannot be refunded.
+	_, err = env.client.Refund(context.Background(), refunder, txID, 2)
+	assert.Error(t, err)
+	_, err = env.client.Refund(context.Background(), refunder, txID, 3)
+	assert.Error(t, err)
+
+	// A second refund is reported as already submitted.
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		{Result: transactionpbv4.SubmitTransactionResponse_ALREADY_SUBMITTED},
+	}
+	env.v4Server.Mux.Unlock()
+
+	_, err = env.client.Refund(context.Background(), refunder, txID, 0)
+	assert.True(t, errors.Is(err, ErrAlreadySubmitted), err)
+
+	_, err = env.client.Refund(context.Background(), refunder, make([]byte, 64), 0)
+	assert.Error(t, err)
+}
+
+// generateRefundable adds a successful transaction to server, which pays 1, 2,
+// 3... quarks from sender to each of destinations, and returns its ID.
+func generateRefundable(t *testing.T, server *TestServer, sender kin.PrivateKey, destinations ...kin.PublicKey) []byte {
+	instructions := make([]solana.Instruction, len(destinations))
+	for i, dest := range destinations {
+		instructions[i] = token.Transfer(
+			ed25519.PublicKey(sender.Public()),
+			ed25519.PublicKey(dest),
+			ed25519.PublicKey(sender.Public()),
+			uint64(i+1),
+		)
+	}
+
+	tx := solana.NewTransaction(ed25519.PublicKey(sender.Public()), instructions...)
+	require.NoError(t, tx.Sign(ed25519.PrivateKey(sender)))
+
+	resp := transactionpbv4.GetTransactionResponse{
+		State: transactionpbv4.GetTransactionResponse_SUCCESS,
+		Item: &transactionpbv4.HistoryItem{
+			TransactionId: &commonpbv4.TransactionId{Value: tx.Signature()},
+			RawTransaction: &transactionpbv4.HistoryItem_SolanaTransaction{
+				SolanaTransaction: &commonpbv4.Transaction{Value: tx.Marshal()},
+			},
+		},
+	}
+	for i, dest := range destinations {
+		resp.Item.Payments = append(resp.Item.Payments, &transactionpbv4.HistoryItem_Payment{
+			Source:      &commonpbv4.SolanaAccountId{Value: sender.Public()},
+			Destination: &commonpbv4.SolanaAccountId{Value: dest},
+			Amount:      int64(i + 1),
+			Index:       uint32(i),
+		})
+	}
+
+	server.Mux.Lock()
+	server.Gets[string(tx.Signature())] = resp
+	server.Mux.Unlock()
+
+	return tx.Signature()
+}
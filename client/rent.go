@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/pkg/errors"
+)
+
+// LamportsPerSignature is the fee, in lamports, charged for each signature of
+// a transaction.
+const LamportsPerSignature = 5000
+
+// AccountCreationCost is an estimate of the SOL required to create a set of
+// accounts.
+type AccountCreationCost struct {
+	// RentLamports is the total balance required to make the created token
+	// accounts rent exempt.
+	RentLamports uint64
+
+	// FeeLamports is the total of the transaction fees.
+	FeeLamports uint64
+}
+
+// Total returns the total cost of the account creations, in lamports.
+func (c AccountCreationCost) Total() uint64 {
+	return c.RentLamports + c.FeeLamports
+}
+
+func (c *client) GetMinimumBalanceForRentExemption(ctx context.Context, size uint64) (uint64, error) {
+	return c.internal.GetMinimumBalanceForRentException(ctx, size)
+}
+
+func (c *client) EstimateAccountCreationCost(ctx context.Context, count int) (AccountCreationCost, error) {
+	if count < 0 {
+		return AccountCreationCost{}, errors.Errorf("invalid count: %d", count)
+	}
+	if count == 0 {
+		return AccountCreationCost{}, nil
+	}
+
+	lamports, err := c.internal.GetMinimumBalanceForRentException(ctx, token.AccountSize)
+	if err != nil {
+		return AccountCreationCost{}, err
+	}
+
+	return AccountCreationCost{
+		RentLamports: uint64(count) * lamports,
+		FeeLamports:  uint64(count) * 2 * LamportsPerSignature,
+	}, nil
+}
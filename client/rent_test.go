@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetMinimumBalanceForRentExemption(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		lamports, err := env.client.GetMinimumBalanceForRentExemption(context.Background(), token.AccountSize)
+		require.NoError(t, err)
+		assert.Equal(t, MinBalanceForRentException, lamports)
+	}
+
+	// Results are cached per size.
+	_, err := env.client.GetMinimumBalanceForRentExemption(context.Background(), 10)
+	require.NoError(t, err)
+
+	require.Len(t, env.v4Server.RentExemptionReqs, 2)
+	assert.EqualValues(t, token.AccountSize, env.v4Server.RentExemptionReqs[0].Size)
+	assert.EqualValues(t, 10, env.v4Server.RentExemptionReqs[1].Size)
+}
+
+func TestClient_EstimateAccountCreationCost(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	cost, err := env.client.EstimateAccountCreationCost(context.Background(), 0)
+	require.NoError(t, err)
+	assert.Zero(t, cost.Total())
+	assert.Empty(t, env.v4Server.RentExemptionReqs)
+
+	_, err = env.client.EstimateAccountCreationCost(context.Background(), -1)
+	assert.Error(t, err)
+
+	cost, err = env.client.EstimateAccountCreationCost(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 10*MinBalanceForRentException, cost.RentLamports)
+	assert.EqualValues(t, 10*2*LamportsPerSignature, cost.FeeLamports)
+	assert.Equal(t, cost.RentLamports+cost.FeeLamports, cost.Total())
+}
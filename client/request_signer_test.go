@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+func TestRequestSigningUnaryInterceptor(t *testing.T) {
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	req := &transactionpbv4.GetTransactionRequest{Commitment: 2}
+	body, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	for _, signer := range []RequestSigner{
+		NewEd25519RequestSigner(key),
+		NewHMACRequestSigner("k1", []byte("secret")),
+	} {
+		var md metadata.MD
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ = metadata.FromOutgoingContext(ctx)
+			return nil
+		}
+
+		interceptor := RequestSigningUnaryInterceptor(signer)
+		require.NoError(t, interceptor(context.Background(), "/test/Method", req, nil, nil, invoker))
+
+		require.Len(t, md.Get(RequestSignatureHeader), 1)
+		assert.Equal(t, []string{signer.KeyID()}, md.Get(RequestSignatureKeyHeader))
+		assert.Equal(t, []string{signer.Algorithm()}, md.Get(RequestSignatureAlgorithmHeader))
+
+		nanos, err := strconv.ParseInt(md.Get(RequestSignatureTimeHeader)[0], 10, 64)
+		require.NoError(t, err)
+		sig, err := base64.StdEncoding.DecodeString(md.Get(RequestSignatureHeader)[0])
+		require.NoError(t, err)
+
+		canonical := CanonicalRequest("/test/Method", time.Unix(0, nanos), body)
+		switch signer.Algorithm() {
+		case "ed25519":
+			assert.Equal(t, key.Public().Base58(), signer.KeyID())
+			assert.True(t, ed25519.Verify(ed25519.PublicKey(key.Public()), canonical, sig))
+		default:
+			expected, err := signer.Sign(canonical)
+			require.NoError(t, err)
+			assert.Equal(t, expected, sig)
+		}
+	}
+}
+
+func TestRequestSigningStreamInterceptor(t *testing.T) {
+	var md metadata.MD
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil, nil
+	}
+
+	signer := NewHMACRequestSigner("k1", []byte("secret"))
+	_, err := RequestSigningStreamInterceptor(signer)(context.Background(), &grpc.StreamDesc{}, nil, "/test/Stream", streamer)
+	require.NoError(t, err)
+
+	nanos, err := strconv.ParseInt(md.Get(RequestSignatureTimeHeader)[0], 10, 64)
+	require.NoError(t, err)
+	expected, err := signer.Sign(CanonicalRequest("/test/Stream", time.Unix(0, nanos), nil))
+	require.NoError(t, err)
+	assert.Equal(t, []string{base64.StdEncoding.EncodeToString(expected)}, md.Get(RequestSignatureHeader))
+}
+
+func TestClient_RequestSignerWithGRPC(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	_, err := New(EnvironmentTest, WithGRPC(env.conn), WithRequestSigner(NewHMACRequestSigner("k1", []byte("secret"))))
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package resolve
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// WithDirectoryKey specifies the key that a Directory's responses must be
+// signed with. Without it, the responses of the directory are trusted as long
+// as they are served over HTTPS.
+func WithDirectoryKey(key kin.PublicKey) Option {
+	return func(o *opts) {
+		o.directoryKey = key
+	}
+}
+
+// DirectoryEntry is the response of a directory for a handle.
+type DirectoryEntry struct {
+	Handle  string `json:"handle"`
+	Address string `json:"address"`
+
+	// Signature is the base58 encoded signature of the entry by the key of the
+	// directory (see WithDirectoryKey).
+	Signature string `json:"signature,omitempty"`
+}
+
+// SignaturePayload returns the payload that is signed by Signature, which is
+// the handle and the address, separated by a zero byte.
+func (e DirectoryEntry) SignaturePayload() []byte {
+	return []byte(e.Handle + "\x00" + e.Address)
+}
+
+// Directory is a client.Resolver backed by an app's own directory of handles,
+// such as usernames.
+//
+// Handles are resolved with a GET request to the base URL of the directory,
+// followed by the escaped handle, which responds with a JSON DirectoryEntry, or
+// 404 if the handle does not exist. Entries for a different handle than the
+// one requested are rejected.
+type Directory struct {
+	baseURL string
+	opts    opts
+}
+
+// NewDirectory returns a Directory resolver for the directory at baseURL.
+func NewDirectory(baseURL string, options ...Option) *Directory {
+	return &Directory{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		opts:    newOpts(options),
+	}
+}
+
+// Resolve implements client.Resolver.Resolve.
+func (d *Directory) Resolve(ctx context.Context, handle string) (kin.PublicKey, error) {
+	req, err := http.NewRequest(http.MethodGet, d.baseURL+"/"+url.PathEscape(handle), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create directory request")
+	}
+
+	resp, err := d.opts.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query directory")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errors.Wrapf(client.ErrHandleNotFound, "%q not in directory", handle)
+	default:
+		return nil, errors.Errorf("unexpected status from directory: %d", resp.StatusCode)
+	}
+
+	var entry DirectoryEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		return nil, errors.Wrap(err, "failed to decode directory entry")
+	}
+
+	normalized, err := client.NormalizeHandle(entry.Handle)
+	if err != nil || normalized != handle {
+		return nil, errors.Errorf("directory returned entry for %q, not %q", entry.Handle, handle)
+	}
+	address, err := kin.PublicKeyFromString(entry.Address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "directory returned invalid address for %q", handle)
+	}
+
+	if len(d.opts.directoryKey) > 0 {
+		sig, err := base58.Decode(entry.Signature)
+		if err != nil || !ed25519.Verify(ed25519.PublicKey(d.opts.directoryKey), entry.SignaturePayload(), sig) {
+			return nil, errors.Errorf("invalid directory signature for %q", handle)
+		}
+	}
+
+	return address, nil
+}
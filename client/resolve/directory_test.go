@@ -0,0 +1,83 @@
+package resolve
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+func TestDirectory(t *testing.T) {
+	alice, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	directoryKey, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	entries := map[string]DirectoryEntry{
+		"alice": {Handle: "alice", Address: alice.Public().Base58()},
+		"bob":   {Handle: "alice", Address: alice.Public().Base58()},
+		"eve":   {Handle: "eve", Address: "invalid"},
+	}
+	for handle, entry := range entries {
+		entry.Signature = base58.Encode(ed25519.Sign(ed25519.PrivateKey(directoryKey), entry.SignaturePayload()))
+		entries[handle] = entry
+	}
+	entries["unsigned"] = DirectoryEntry{Handle: "unsigned", Address: alice.Public().Base58()}
+
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+
+		entry, ok := entries[strings.TrimPrefix(r.URL.Path, "/users/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(entry))
+	}))
+	defer server.Close()
+
+	d := NewDirectory(server.URL + "/users/")
+	address, err := d.Resolve(context.Background(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, alice.Public(), address)
+	assert.Equal(t, []string{"/users/alice"}, paths)
+
+	_, err = d.Resolve(context.Background(), "missing")
+	assert.Equal(t, client.ErrHandleNotFound, errors.Cause(err))
+
+	// Entries must be for the requested handle, and have a valid address.
+	_, err = d.Resolve(context.Background(), "bob")
+	assert.Error(t, err)
+	_, err = d.Resolve(context.Background(), "eve")
+	assert.Error(t, err)
+
+	_, err = d.Resolve(context.Background(), "unsigned")
+	assert.NoError(t, err)
+
+	// With a directory key, entries must be signed by it.
+	d = NewDirectory(server.URL+"/users", WithDirectoryKey(directoryKey.Public()))
+	address, err = d.Resolve(context.Background(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, alice.Public(), address)
+
+	_, err = d.Resolve(context.Background(), "unsigned")
+	assert.Error(t, err)
+
+	other, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	d = NewDirectory(server.URL+"/users", WithDirectoryKey(other.Public()))
+	_, err = d.Resolve(context.Background(), "alice")
+	assert.Error(t, err)
+}
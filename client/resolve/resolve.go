@@ -0,0 +1,176 @@
+// Package resolve provides implementations of client.Resolver, which resolve
+// human-readable handles to Kin addresses.
+//
+// Resolvers can be combined: a Mux dispatches handles to resolvers by suffix
+// (for example, ".sol" handles to an SNS resolver, and everything else to an
+// app's Directory), and a Cache caches the resolutions of another resolver.
+package resolve
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+type opts struct {
+	httpClient   *http.Client
+	directoryKey kin.PublicKey
+	ttl          time.Duration
+	clock        client.Clock
+}
+
+// Option configures a resolver.
+type Option func(*opts)
+
+// WithHTTPClient specifies the http.Client used by SNS and Directory resolvers.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *opts) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithTTL specifies how long a Cache caches resolutions for. Defaults to 10
+// minutes.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *opts) {
+		o.ttl = ttl
+	}
+}
+
+// WithClock specifies the Clock used by a Cache to expire resolutions.
+func WithClock(clock client.Clock) Option {
+	return func(o *opts) {
+		o.clock = clock
+	}
+}
+
+func newOpts(options []Option) opts {
+	o := opts{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		ttl:        10 * time.Minute,
+		clock:      client.SystemClock(),
+	}
+	for _, opt := range options {
+		opt(&o)
+	}
+	return o
+}
+
+// Mux is a client.Resolver that dispatches handles to other resolvers by
+// suffix.
+type Mux struct {
+	fallback client.Resolver
+
+	mu       sync.RWMutex
+	suffixes []string
+	routes   map[string]client.Resolver
+}
+
+// NewMux returns a Mux that resolves handles that match none of its suffixes
+// with fallback. If fallback is nil, such handles are not found.
+func NewMux(fallback client.Resolver) *Mux {
+	return &Mux{
+		fallback: fallback,
+		routes:   make(map[string]client.Resolver),
+	}
+}
+
+// Handle resolves handles ending in suffix, such as ".sol", with r. If several
+// suffixes match a handle, the longest is used.
+func (m *Mux) Handle(suffix string, r client.Resolver) {
+	suffix = strings.ToLower(suffix)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.routes[suffix]; !ok {
+		m.suffixes = append(m.suffixes, suffix)
+		sort.Slice(m.suffixes, func(i, j int) bool {
+			return len(m.suffixes[i]) > len(m.suffixes[j])
+		})
+	}
+	m.routes[suffix] = r
+}
+
+// Resolve implements client.Resolver.Resolve.
+func (m *Mux) Resolve(ctx context.Context, handle string) (kin.PublicKey, error) {
+	m.mu.RLock()
+	r := m.fallback
+	for _, suffix := range m.suffixes {
+		if strings.HasSuffix(handle, suffix) {
+			r = m.routes[suffix]
+			break
+		}
+	}
+	m.mu.RUnlock()
+
+	if r == nil {
+		return nil, errors.Wrapf(client.ErrHandleNotFound, "no resolver for %q", handle)
+	}
+	return r.Resolve(ctx, handle)
+}
+
+// Cache is a client.Resolver that caches the resolutions of another resolver.
+//
+// Only successful resolutions are cached, so that newly registered handles can
+// be resolved immediately.
+type Cache struct {
+	resolver client.Resolver
+	opts     opts
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	address kin.PublicKey
+	expires time.Time
+}
+
+// NewCache returns a Cache of the resolutions of r.
+func NewCache(r client.Resolver, options ...Option) *Cache {
+	return &Cache{
+		resolver: r,
+		opts:     newOpts(options),
+		entries:  make(map[string]cacheEntry),
+	}
+}
+
+// Resolve implements client.Resolver.Resolve.
+func (c *Cache) Resolve(ctx context.Context, handle string) (kin.PublicKey, error) {
+	now := c.opts.clock.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[handle]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.address, nil
+	}
+
+	address, err := c.resolver.Resolve(ctx, handle)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[handle] = cacheEntry{address: address, expires: now.Add(c.opts.ttl)}
+	c.mu.Unlock()
+
+	return address, nil
+}
+
+// Invalidate removes the cached resolution of handle, if any. It should be
+// called when a handle is known to have changed hands.
+func (c *Cache) Invalidate(handle string) {
+	c.mu.Lock()
+	delete(c.entries, handle)
+	c.mu.Unlock()
+}
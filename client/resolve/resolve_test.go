@@ -0,0 +1,87 @@
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+	"github.com/kinecosystem/kin-go/client/clientfake"
+)
+
+func staticResolver(t *testing.T, calls *int) (client.Resolver, kin.PublicKey) {
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	return client.ResolverFunc(func(_ context.Context, handle string) (kin.PublicKey, error) {
+		*calls++
+		if handle == "missing" || handle == "missing.sol" {
+			return nil, client.ErrHandleNotFound
+		}
+		return key.Public(), nil
+	}), key.Public()
+}
+
+func TestMux(t *testing.T) {
+	var snsCalls, subCalls, fallbackCalls int
+	sns, snsAddress := staticResolver(t, &snsCalls)
+	sub, subAddress := staticResolver(t, &subCalls)
+	fallback, fallbackAddress := staticResolver(t, &fallbackCalls)
+
+	m := NewMux(fallback)
+	m.Handle(".sol", sns)
+	m.Handle(".app.SOL", sub)
+
+	for handle, expected := range map[string]kin.PublicKey{
+		"alice.sol":     snsAddress,
+		"alice.app.sol": subAddress,
+		"alice":         fallbackAddress,
+	} {
+		address, err := m.Resolve(context.Background(), handle)
+		require.NoError(t, err)
+		assert.Equal(t, expected, address, handle)
+	}
+	assert.Equal(t, 1, snsCalls)
+	assert.Equal(t, 1, subCalls)
+	assert.Equal(t, 1, fallbackCalls)
+
+	_, err := NewMux(nil).Resolve(context.Background(), "alice")
+	assert.Equal(t, client.ErrHandleNotFound, errors.Cause(err))
+}
+
+func TestCache(t *testing.T) {
+	var calls int
+	r, expected := staticResolver(t, &calls)
+
+	clock := clientfake.NewClock(time.Now())
+	c := NewCache(r, WithTTL(time.Minute), WithClock(clock))
+
+	for i := 0; i < 3; i++ {
+		address, err := c.Resolve(context.Background(), "alice")
+		require.NoError(t, err)
+		assert.Equal(t, expected, address)
+	}
+	assert.Equal(t, 1, calls)
+
+	clock.Advance(time.Minute)
+	_, err := c.Resolve(context.Background(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	c.Invalidate("alice")
+	_, err = c.Resolve(context.Background(), "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// Missing handles are not cached.
+	for i := 0; i < 2; i++ {
+		_, err = c.Resolve(context.Background(), "missing")
+		assert.Equal(t, client.ErrHandleNotFound, errors.Cause(err))
+	}
+	assert.Equal(t, 5, calls)
+}
@@ -0,0 +1,181 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+const (
+	// snsSuffix is the top level domain of SNS handles.
+	snsSuffix = ".sol"
+
+	// snsHashPrefix is prepended to names before they are hashed to derive
+	// the address of their name account.
+	snsHashPrefix = "SPL Name Service"
+
+	// snsHeaderSize is the size of the header of a name account, which
+	// contains the parent, owner and class of the name.
+	snsHeaderSize = 96
+)
+
+var (
+	// snsProgram is the address of the SPL Name Service program.
+	snsProgram = mustDecode("namesLPneVptA9Z5rqUDD9tMTWEJwofgaYwp8cawRkX")
+
+	// snsRoot is the name account of the .sol top level domain.
+	snsRoot = mustDecode("58PwtjSDuFHuUkYjH9BYnnQKHfwo9reZhC2zMJv9JPkx")
+)
+
+// SNS is a client.Resolver that resolves .sol handles (such as "alice.sol")
+// with the Solana Name Service, to the address of the owner of the domain.
+//
+// Only second level domains are supported. The name account of a domain is
+// verified to be owned by the name service program and to be a child of the
+// .sol domain, so that accounts with the same layout cannot be passed off as
+// domains.
+type SNS struct {
+	endpoint string
+	opts     opts
+}
+
+// NewSNS returns an SNS resolver that reads name accounts from the Solana JSON
+// RPC node at endpoint.
+func NewSNS(endpoint string, options ...Option) *SNS {
+	return &SNS{
+		endpoint: endpoint,
+		opts:     newOpts(options),
+	}
+}
+
+// Resolve implements client.Resolver.Resolve.
+func (s *SNS) Resolve(ctx context.Context, handle string) (kin.PublicKey, error) {
+	account, err := snsNameAccount(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.getAccountInfo(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, errors.Wrapf(client.ErrHandleNotFound, "no name account for %q", handle)
+	}
+
+	programOwner, err := base58.Decode(info.Owner)
+	if err != nil || !bytes.Equal(programOwner, snsProgram) {
+		return nil, errors.Errorf("name account of %q is not owned by the name service", handle)
+	}
+	if len(info.Data) != 2 || info.Data[1] != "base64" {
+		return nil, errors.Errorf("unexpected encoding of name account of %q", handle)
+	}
+	data, err := base64.StdEncoding.DecodeString(info.Data[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode name account of %q", handle)
+	}
+	if len(data) < snsHeaderSize {
+		return nil, errors.Errorf("name account of %q is too small: %d", handle, len(data))
+	}
+	if !bytes.Equal(data[:32], snsRoot) {
+		return nil, errors.Errorf("name account of %q is not a .sol domain", handle)
+	}
+
+	owner := kin.PublicKey(data[32:64])
+	if bytes.Equal(owner, make([]byte, 32)) {
+		return nil, errors.Wrapf(client.ErrHandleNotFound, "%q has no owner", handle)
+	}
+	return owner, nil
+}
+
+// snsNameAccount returns the address of the name account of handle.
+func snsNameAccount(handle string) (ed25519.PublicKey, error) {
+	if !strings.HasSuffix(handle, snsSuffix) {
+		return nil, errors.Wrapf(client.ErrInvalidHandle, "%q is not a .sol handle", handle)
+	}
+	name := strings.TrimSuffix(handle, snsSuffix)
+	if name == "" || strings.Contains(name, ".") {
+		return nil, errors.Wrapf(client.ErrInvalidHandle, "%q is not a second level .sol domain", handle)
+	}
+
+	hashed := sha256.Sum256([]byte(snsHashPrefix + name))
+	address, err := solana.FindProgramAddress(snsProgram, hashed[:], make([]byte, 32), snsRoot)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to derive name account of %q", handle)
+	}
+	return address, nil
+}
+
+type accountInfo struct {
+	Data  []string `json:"data"`
+	Owner string   `json:"owner"`
+}
+
+// getAccountInfo returns the info of account, or nil if it does not exist.
+func (s *SNS) getAccountInfo(ctx context.Context, account ed25519.PublicKey) (*accountInfo, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "getAccountInfo",
+		"params": []interface{}{
+			base58.Encode(account),
+			map[string]string{"encoding": "base64", "commitment": "finalized"},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode rpc request")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create rpc request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.opts.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to call getAccountInfo")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status from solana rpc: %d", resp.StatusCode)
+	}
+
+	var rpcResp struct {
+		Result struct {
+			Value *accountInfo `json:"value"`
+		} `json:"result"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode rpc response")
+	}
+	if rpcResp.Error != nil {
+		return nil, errors.Errorf("failed to call getAccountInfo: %s", rpcResp.Error.Message)
+	}
+
+	return rpcResp.Result.Value, nil
+}
+
+func mustDecode(s string) ed25519.PublicKey {
+	b, err := base58.Decode(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
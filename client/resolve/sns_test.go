@@ -0,0 +1,94 @@
+package resolve
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+func TestSNS(t *testing.T) {
+	owner, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	otherParent, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	nameAccount := func(data []byte, program []byte) *accountInfo {
+		return &accountInfo{
+			Data:  []string{base64.StdEncoding.EncodeToString(data), "base64"},
+			Owner: base58.Encode(program),
+		}
+	}
+	header := func(parent, owner []byte) []byte {
+		data := append(append([]byte{}, parent...), owner...)
+		return append(data, make([]byte, 64)...)
+	}
+
+	accounts := make(map[string]*accountInfo)
+	for name, info := range map[string]*accountInfo{
+		"alice.sol":     nameAccount(header(snsRoot, owner.Public()), snsProgram),
+		"unowned.sol":   nameAccount(header(snsRoot, make([]byte, 32)), snsProgram),
+		"program.sol":   nameAccount(header(snsRoot, owner.Public()), otherParent.Public()),
+		"parent.sol":    nameAccount(header(otherParent.Public(), owner.Public()), snsProgram),
+		"truncated.sol": nameAccount(header(snsRoot, owner.Public())[:64], snsProgram),
+	} {
+		address, err := snsNameAccount(name)
+		require.NoError(t, err)
+		accounts[base58.Encode(address)] = info
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string        `json:"method"`
+			Params []interface{} `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "getAccountInfo", req.Method)
+
+		var resp struct {
+			Result struct {
+				Value *accountInfo `json:"value"`
+			} `json:"result"`
+		}
+		resp.Result.Value = accounts[req.Params[0].(string)]
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+	defer server.Close()
+
+	s := NewSNS(server.URL)
+	address, err := s.Resolve(context.Background(), "alice.sol")
+	require.NoError(t, err)
+	assert.Equal(t, owner.Public(), address)
+
+	for _, handle := range []string{"missing.sol", "unowned.sol"} {
+		_, err = s.Resolve(context.Background(), handle)
+		assert.Equal(t, client.ErrHandleNotFound, errors.Cause(err), handle)
+	}
+	for _, handle := range []string{"alice", "sub.alice.sol", ".sol"} {
+		_, err = s.Resolve(context.Background(), handle)
+		assert.Equal(t, client.ErrInvalidHandle, errors.Cause(err), handle)
+	}
+	for _, handle := range []string{"program.sol", "parent.sol", "truncated.sol"} {
+		_, err = s.Resolve(context.Background(), handle)
+		assert.Error(t, err, handle)
+		assert.NotEqual(t, client.ErrHandleNotFound, errors.Cause(err), handle)
+	}
+}
+
+func TestSNS_NameAccount(t *testing.T) {
+	// The name account of bonfida.sol, as derived by the reference
+	// implementation of the name service.
+	address, err := snsNameAccount("bonfida.sol")
+	require.NoError(t, err)
+	assert.Equal(t, "Crf8hzfthWGbGbLTVCiqRqV5MVnbpHB1L9KQMd6gsinb", base58.Encode(address))
+}
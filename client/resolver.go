@@ -0,0 +1,102 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+)
+
+// maxHandleLength is the maximum length of a handle, which is the maximum
+// length of a domain name.
+const maxHandleLength = 253
+
+// Resolver resolves human-readable handles, such as "alice.sol", to the Kin
+// address they refer to. Implementations are provided by the resolve package.
+//
+// Handles passed to Resolve have been normalized by NormalizeHandle.
+// Implementations should return an error whose cause is ErrHandleNotFound if
+// the handle does not refer to an address.
+type Resolver interface {
+	Resolve(ctx context.Context, handle string) (kin.PublicKey, error)
+}
+
+// ResolverFunc is an adapter to allow the use of ordinary functions as a
+// Resolver.
+type ResolverFunc func(ctx context.Context, handle string) (kin.PublicKey, error)
+
+// Resolve implements Resolver.Resolve.
+func (f ResolverFunc) Resolve(ctx context.Context, handle string) (kin.PublicKey, error) {
+	return f(ctx, handle)
+}
+
+// WithResolver specifies the Resolver used to resolve the DestinationHandle of
+// payments submitted with SubmitPayment.
+func WithResolver(r Resolver) ClientOption {
+	return func(o *clientOpts) {
+		o.resolver = r
+	}
+}
+
+// NormalizeHandle returns the canonical form of handle, which is lowercase and
+// without surrounding whitespace.
+//
+// To protect against handles that are visually indistinguishable from others
+// (such as those containing Cyrillic or zero width characters), handles may
+// only contain ASCII letters, digits, '-', '_' and '.', and must not start or
+// end with '.', or contain consecutive '.'s. An error whose cause is
+// ErrInvalidHandle is returned for any other handle.
+func NormalizeHandle(handle string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(handle))
+	if normalized == "" || len(normalized) > maxHandleLength {
+		return "", errors.Wrapf(ErrInvalidHandle, "invalid length: %d", len(normalized))
+	}
+
+	for _, r := range normalized {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return "", errors.Wrapf(ErrInvalidHandle, "invalid character %q", r)
+		}
+	}
+	if strings.HasPrefix(normalized, ".") || strings.HasSuffix(normalized, ".") || strings.Contains(normalized, "..") {
+		return "", errors.Wrapf(ErrInvalidHandle, "invalid handle %q", handle)
+	}
+
+	return normalized, nil
+}
+
+// resolveDestination sets the destination of p to the address of its
+// DestinationHandle, if it has one.
+func (c *client) resolveDestination(ctx context.Context, p Payment) (Payment, error) {
+	if p.DestinationHandle == "" {
+		return p, nil
+	}
+	if c.opts.resolver == nil {
+		return p, errors.New("cannot resolve destination handle without a resolver")
+	}
+
+	handle, err := NormalizeHandle(p.DestinationHandle)
+	if err != nil {
+		return p, err
+	}
+
+	resolved, err := c.opts.resolver.Resolve(ctx, handle)
+	if err != nil {
+		return p, errors.Wrapf(err, "failed to resolve %q", handle)
+	}
+	if len(resolved) != 32 {
+		return p, errors.Errorf("resolver returned invalid address for %q", handle)
+	}
+
+	// If the caller also specified a destination (for example, one that was
+	// shown to and confirmed by the user), the handle must still refer to it.
+	if len(p.Destination) > 0 && !bytes.Equal(p.Destination, resolved) {
+		return p, errors.Wrapf(ErrHandleMismatch, "%q resolved to %s, not %s", handle, resolved.Base58(), p.Destination.Base58())
+	}
+
+	p.Destination = resolved
+	return p, nil
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeHandle(t *testing.T) {
+	for in, out := range map[string]string{
+		"alice.sol":    "alice.sol",
+		" Alice.SOL\n": "alice.sol",
+		"bob_1-2":      "bob_1-2",
+	} {
+		normalized, err := NormalizeHandle(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, out, normalized)
+	}
+
+	for _, in := range []string{
+		"",
+		" ",
+		"\u0430lice.sol",  // Cyrillic a
+		"al\u200bice.sol", // zero width space
+		"alice@sol",
+		".alice",
+		"alice.",
+		"alice..sol",
+		string(make([]byte, maxHandleLength+1)),
+	} {
+		_, err := NormalizeHandle(in)
+		assert.Equal(t, ErrInvalidHandle, errors.Cause(err), in)
+	}
+}
+
+func TestClient_SubmitPaymentHandle(t *testing.T) {
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	other, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	var resolved []string
+	env, cleanup := setup(t, WithResolver(ResolverFunc(func(_ context.Context, handle string) (kin.PublicKey, error) {
+		resolved = append(resolved, handle)
+		if handle == "alice.sol" {
+			return dest.Public(), nil
+		}
+		return nil, ErrHandleNotFound
+	})))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	for _, k := range []kin.PrivateKey{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), k))
+	}
+
+	p := Payment{
+		Sender:            sender,
+		DestinationHandle: "Alice.sol",
+		Type:              kin.TransactionTypeP2P,
+		Quarks:            10,
+	}
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	require.NoError(t, err)
+
+	balance, err := env.client.GetBalance(context.Background(), dest.Public())
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, balance)
+
+	// A destination that the handle does not resolve to is rejected.
+	p.Destination = other.Public()
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	assert.Equal(t, ErrHandleMismatch, errors.Cause(err))
+
+	p.Destination = dest.Public()
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	assert.NoError(t, err)
+
+	p.Destination = nil
+	p.DestinationHandle = "bob.sol"
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	assert.Equal(t, ErrHandleNotFound, errors.Cause(err))
+
+	p.DestinationHandle = "bob@sol"
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	assert.Equal(t, ErrInvalidHandle, errors.Cause(err))
+
+	assert.Equal(t, []string{"alice.sol", "alice.sol", "alice.sol", "bob.sol"}, resolved)
+}
+
+func TestClient_SubmitPaymentHandleNoResolver(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:            sender,
+		DestinationHandle: "alice.sol",
+		Type:              kin.TransactionTypeP2P,
+		Quarks:            10,
+	})
+	assert.Error(t, err)
+}
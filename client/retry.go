@@ -0,0 +1,120 @@
+package client
+
+import (
+	"time"
+
+	"github.com/kinecosystem/agora-common/retry"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy configures how requests that fail with transient errors are
+// retried. Zero values default to the values specified with WithMaxRetries,
+// WithMinDelay and WithMaxDelay.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of attempts, as with WithMaxRetries.
+	// Since zero selects the default, a value of 1 disables retries.
+	MaxRetries uint
+
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// WithReadRetryPolicy specifies the retry policy of requests that do not modify
+// the blockchain, such as GetBalance and GetTransaction. Since reads are safe to
+// repeat, they may be retried more aggressively than writes.
+func WithReadRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(o *clientOpts) {
+		o.readRetryPolicy = policy
+	}
+}
+
+// WithWriteRetryPolicy specifies the retry policy of requests that submit
+// transactions, such as SubmitPayment, CreateAccount and RequestAirdrop.
+//
+// A write that failed with a transient error may still have been processed, so
+// retrying submissions without a DedupeID risks submitting them twice.
+func WithWriteRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(o *clientOpts) {
+		o.writeRetryPolicy = policy
+	}
+}
+
+// resolve returns the policy with zero values replaced by the defaults in opts.
+func (p RetryPolicy) resolve(opts clientOpts) RetryPolicy {
+	if p.MaxRetries == 0 {
+		p.MaxRetries = opts.maxRetries
+	}
+	if p.MinDelay == 0 {
+		p.MinDelay = opts.minDelay
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = opts.maxDelay
+	}
+	return p
+}
+
+// WithNonRetriableErrors specifies errors that the client does not retry, in
+// addition to the errors it does not retry by default (such as
+// ErrInsufficientBalance).
+//
+// An error matches if errors.Is reports that it does, or if both are gRPC
+// status errors with the same code. For example,
+// status.Error(codes.DeadlineExceeded, "") prevents requests that exceeded
+// their deadline from being retried.
+func WithNonRetriableErrors(errs ...error) ClientOption {
+	return func(o *clientOpts) {
+		o.nonRetriableErrors = append(o.nonRetriableErrors, errs...)
+	}
+}
+
+// WithRetriableErrors specifies errors that the client retries, even if they
+// are not retried by default or were specified with WithNonRetriableErrors.
+// Errors are matched as with WithNonRetriableErrors.
+func WithRetriableErrors(errs ...error) ClientOption {
+	return func(o *clientOpts) {
+		o.retriableErrors = append(o.retriableErrors, errs...)
+	}
+}
+
+// defaultNonRetriableErrors returns the errors that are not retried by default.
+func defaultNonRetriableErrors() []error {
+	errs := make([]error, 0, len(nonRetriableErrors)+1)
+	errs = append(errs, nonRetriableErrors...)
+	return append(errs, status.Error(codes.Canceled, ""))
+}
+
+// nonRetriable returns a strategy that does not retry errors matching one of
+// nonRetriable, unless they also match one of retriable.
+func nonRetriable(nonRetriable, retriable []error) retry.Strategy {
+	return func(attempts uint, err error) bool {
+		if matchesError(err, retriable) {
+			return true
+		}
+		return !matchesError(err, nonRetriable)
+	}
+}
+
+// matchesError returns whether err matches any of targets.
+func matchesError(err error, targets []error) bool {
+	code, isStatus := statusCode(err)
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+		if targetCode, ok := statusCode(target); ok && isStatus && code == targetCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// statusCode returns the gRPC status code of err, if it is a status error.
+func statusCode(err error) (codes.Code, bool) {
+	if s, ok := status.FromError(errors.Cause(err)); ok && s.Code() != codes.OK {
+		return s.Code(), true
+	}
+	return codes.OK, false
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+)
+
+func TestRetrier_ErrorClassification(t *testing.T) {
+	attempts := func(opts clientOpts, err error) uint {
+		opts.maxRetries = 3
+		opts.minDelay = time.Millisecond
+		opts.maxDelay = time.Millisecond
+
+		var n uint
+		_, _ = newRetrier(opts, RetryPolicy{}).Retry(func() error {
+			n++
+			return err
+		})
+		return n
+	}
+
+	deadline := status.Error(codes.DeadlineExceeded, "deadline exceeded")
+
+	// Defaults.
+	assert.EqualValues(t, 1, attempts(clientOpts{}, ErrInsufficientBalance))
+	assert.EqualValues(t, 1, attempts(clientOpts{}, errors.Wrap(ErrBadNonce, "wrapped")))
+	assert.EqualValues(t, 1, attempts(clientOpts{}, status.Error(codes.Canceled, "canceled")))
+	assert.EqualValues(t, 3, attempts(clientOpts{}, deadline))
+	assert.EqualValues(t, 3, attempts(clientOpts{}, errors.New("unexpected")))
+
+	// Additional non-retriable errors, matched by status code.
+	opts := clientOpts{}
+	WithNonRetriableErrors(status.Error(codes.DeadlineExceeded, ""))(&opts)
+	assert.EqualValues(t, 1, attempts(opts, deadline))
+	assert.EqualValues(t, 1, attempts(opts, errors.Wrap(deadline, "failed to submit transaction")))
+	assert.EqualValues(t, 3, attempts(opts, status.Error(codes.Unavailable, "unavailable")))
+
+	// Retriable errors take precedence.
+	WithRetriableErrors(ErrBadNonce, deadline)(&opts)
+	assert.EqualValues(t, 3, attempts(opts, ErrBadNonce))
+	assert.EqualValues(t, 3, attempts(opts, deadline))
+	assert.EqualValues(t, 1, attempts(opts, ErrInsufficientBalance))
+}
+
+func TestRetryPolicies(t *testing.T) {
+	env, cleanup := setup(t,
+		WithReadRetryPolicy(RetryPolicy{MaxRetries: 5}),
+		WithWriteRetryPolicy(RetryPolicy{MaxRetries: 1}),
+	)
+	defer cleanup()
+
+	remainingErrors := func() int {
+		env.v4Server.Mux.Lock()
+		defer env.v4Server.Mux.Unlock()
+		return len(env.v4Server.Errors)
+	}
+
+	env.v4Server.SetError(errors.New("unexpected"), 10)
+
+	// Writes are not retried.
+	_, err := env.internal.RequestAirdrop(context.Background(), make([]byte, 32), 10, commonpbv4.Commitment_SINGLE)
+	assert.Error(t, err)
+	assert.Equal(t, 9, remainingErrors())
+
+	_, err = env.internal.GetTransaction(context.Background(), make([]byte, 64), commonpbv4.Commitment_SINGLE)
+	assert.Error(t, err)
+	assert.Equal(t, 4, remainingErrors())
+
+	// Unspecified values default to the client's.
+	opts := clientOpts{maxRetries: 3, minDelay: time.Second, maxDelay: time.Minute}
+	assert.Equal(t, RetryPolicy{MaxRetries: 3, MinDelay: time.Second, MaxDelay: time.Minute}, RetryPolicy{}.resolve(opts))
+	assert.Equal(t, RetryPolicy{MaxRetries: 1, MinDelay: time.Second, MaxDelay: time.Minute}, RetryPolicy{MaxRetries: 1}.resolve(opts))
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+)
+
+// SenderPool distributes earn batches across a set of funded hot wallets
+// in a round-robin fashion.
+//
+// The pool tracks the (approximate) balance of each wallet locally, and if
+// a treasury is configured, will top up a wallet from the treasury whenever
+// its balance drops below the configured threshold.
+type SenderPool struct {
+	client Client
+	opts   senderPoolOpts
+
+	mu       sync.Mutex
+	senders  []kin.PrivateKey
+	balances map[string]int64
+	topUps   map[string]struct{}
+	next     int
+}
+
+type senderPoolOpts struct {
+	treasury     kin.PrivateKey
+	threshold    int64
+	topUpQuarks  int64
+	topUpFailure func(sender kin.PublicKey, err error)
+}
+
+// SenderPoolOption configures a SenderPool.
+type SenderPoolOption func(*senderPoolOpts)
+
+// WithTreasury specifies a treasury account used to top up pool wallets.
+//
+// Whenever a wallet's tracked balance drops below threshold quarks after a
+// submission, topUpQuarks will be sent to it from the treasury.
+func WithTreasury(treasury kin.PrivateKey, threshold, topUpQuarks int64) SenderPoolOption {
+	return func(o *senderPoolOpts) {
+		o.treasury = treasury
+		o.threshold = threshold
+		o.topUpQuarks = topUpQuarks
+	}
+}
+
+// WithTopUpFailureHandler specifies a function that is called when a wallet
+// could not be topped up from the treasury. The earn batch that triggered the
+// top up has already been submitted by then, so the failure is not returned by
+// SubmitEarnBatch. The top up is retried after the next submission from the
+// wallet.
+func WithTopUpFailureHandler(f func(sender kin.PublicKey, err error)) SenderPoolOption {
+	return func(o *senderPoolOpts) {
+		o.topUpFailure = f
+	}
+}
+
+// NewSenderPool creates a new SenderPool over the provided wallets.
+func NewSenderPool(c Client, senders []kin.PrivateKey, opts ...SenderPoolOption) (*SenderPool, error) {
+	if len(senders) == 0 {
+		return nil, errors.New("sender pool must contain at least 1 sender")
+	}
+
+	p := &SenderPool{
+		client:   c,
+		senders:  append([]kin.PrivateKey(nil), senders...),
+		balances: make(map[string]int64),
+		topUps:   make(map[string]struct{}),
+	}
+	for _, o := range opts {
+		o(&p.opts)
+	}
+
+	if p.opts.treasury != nil && p.opts.topUpQuarks <= 0 {
+		return nil, errors.New("top up amount must be positive")
+	}
+
+	return p, nil
+}
+
+// SubmitEarnBatch submits the batch using the next wallet in the pool as the
+// sender. Any Sender set on the batch is ignored. Earns with a Source or Owner
+// override are not deducted from the wallet's tracked balance.
+//
+// If the wallet needs to be topped up after the batch is submitted, failing to
+// do so does not fail the batch (see WithTopUpFailureHandler), so that callers
+// do not retry a batch that was paid out.
+func (p *SenderPool) SubmitEarnBatch(ctx context.Context, batch EarnBatch, opts ...SolanaOption) (result EarnBatchResult, err error) {
+	sender, err := p.nextSender(ctx, opts...)
+	if err != nil {
+		return result, err
+	}
+
+	batch.Sender = sender
+	result, err = p.client.SubmitEarnBatch(ctx, batch, opts...)
+	if err != nil || result.TxError != nil {
+		// The balance may or may not have changed, so we refresh it on next use.
+		p.mu.Lock()
+		delete(p.balances, sender.Public().Base58())
+		p.mu.Unlock()
+		return result, err
+	}
+
+	// Earns transferred from overridden sources are not paid by the wallet,
+	// so they don't affect its balance.
+	var total int64
+	for _, e := range batch.Earns {
+		if e.Source == nil && e.Owner == nil {
+			total += e.Quarks
+		}
+	}
+
+	p.mu.Lock()
+	p.balances[sender.Public().Base58()] -= total
+	balance := p.balances[sender.Public().Base58()]
+	p.mu.Unlock()
+
+	if p.opts.treasury != nil && balance < p.opts.threshold {
+		if err := p.topUp(ctx, sender, result.TxID, opts...); err != nil {
+			// The top up may or may not have been submitted, so we refresh
+			// the balance on next use, which also retries the top up.
+			p.mu.Lock()
+			delete(p.balances, sender.Public().Base58())
+			p.mu.Unlock()
+
+			if p.opts.topUpFailure != nil {
+				p.opts.topUpFailure(sender.Public(), err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Balance returns the tracked balance of a sender in the pool, in quarks.
+//
+// The second return value indicates whether or not the balance is currently known.
+func (p *SenderPool) Balance(sender kin.PublicKey) (int64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	balance, ok := p.balances[sender.Base58()]
+	return balance, ok
+}
+
+// Refresh re-fetches the balances of all wallets in the pool.
+func (p *SenderPool) Refresh(ctx context.Context, opts ...SolanaOption) error {
+	for _, s := range p.senders {
+		if _, err := p.refreshBalance(ctx, s, opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *SenderPool) nextSender(ctx context.Context, opts ...SolanaOption) (kin.PrivateKey, error) {
+	p.mu.Lock()
+	sender := p.senders[p.next]
+	p.next = (p.next + 1) % len(p.senders)
+	_, known := p.balances[sender.Public().Base58()]
+	p.mu.Unlock()
+
+	if !known {
+		if _, err := p.refreshBalance(ctx, sender, opts...); err != nil {
+			return nil, err
+		}
+	}
+
+	return sender, nil
+}
+
+func (p *SenderPool) refreshBalance(ctx context.Context, sender kin.PrivateKey, opts ...SolanaOption) (int64, error) {
+	balance, err := p.client.GetBalance(ctx, sender.Public(), opts...)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get sender balance")
+	}
+
+	p.mu.Lock()
+	p.balances[sender.Public().Base58()] = balance
+	p.mu.Unlock()
+
+	return balance, nil
+}
+
+// topUp tops up sender from the treasury, unless a top up of sender is already
+// in flight. The top up is deduped by the transaction that triggered it, so
+// that it is not paid twice if it is resubmitted.
+func (p *SenderPool) topUp(ctx context.Context, sender kin.PrivateKey, triggerTxID []byte, opts ...SolanaOption) error {
+	key := sender.Public().Base58()
+
+	p.mu.Lock()
+	if _, ok := p.topUps[key]; ok {
+		p.mu.Unlock()
+		return nil
+	}
+	p.topUps[key] = struct{}{}
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.topUps, key)
+		p.mu.Unlock()
+	}()
+
+	dedupeID := sha256.Sum256(append([]byte("kin-go/top-up:"+key+":"), triggerTxID...))
+	_, err := p.client.SubmitPayment(ctx, Payment{
+		Sender:      p.opts.treasury,
+		Destination: sender.Public(),
+		Type:        kin.TransactionTypeNone,
+		Quarks:      p.opts.topUpQuarks,
+		DedupeID:    dedupeID[:],
+	}, opts...)
+	if err != nil && !errors.Is(err, ErrAlreadySubmitted) {
+		return err
+	}
+
+	p.mu.Lock()
+	if _, ok := p.balances[key]; ok {
+		p.balances[key] += p.opts.topUpQuarks
+	}
+	p.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/kinecosystem/kin-go/client/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+func TestSenderPool_RoundRobin(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	senders := make([]kin.PrivateKey, 3)
+	for i := range senders {
+		sender, err := kin.NewPrivateKey()
+		require.NoError(t, err)
+		require.NoError(t, env.client.CreateAccount(context.Background(), sender))
+		senders[i] = sender
+	}
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	require.NoError(t, env.client.CreateAccount(context.Background(), dest))
+
+	_, err = NewSenderPool(env.client, nil)
+	assert.Error(t, err)
+
+	pool, err := NewSenderPool(env.client, senders)
+	require.NoError(t, err)
+
+	batch := EarnBatch{
+		Earns: []Earn{
+			{
+				Destination: dest.Public(),
+				Quarks:      2,
+			},
+		},
+	}
+
+	for i := 0; i < 2*len(senders); i++ {
+		result, err := pool.SubmitEarnBatch(context.Background(), batch)
+		require.NoError(t, err)
+		assert.Nil(t, result.TxError)
+	}
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 2*len(senders))
+	for i, submit := range env.v4Server.Submits {
+		var tx solana.Transaction
+		require.NoError(t, tx.Unmarshal(submit.Transaction.Value))
+
+		transfer, err := token.DecompileTransfer(tx.Message, 1)
+		require.NoError(t, err)
+		assert.EqualValues(t, senders[i%len(senders)].Public(), transfer.Owner)
+	}
+	env.v4Server.Mux.Unlock()
+
+	for _, s := range senders {
+		balance, ok := pool.Balance(s.Public())
+		assert.True(t, ok)
+		assert.EqualValues(t, 6, balance)
+	}
+
+	require.NoError(t, pool.Refresh(context.Background()))
+	for _, s := range senders {
+		balance, ok := pool.Balance(s.Public())
+		assert.True(t, ok)
+		assert.EqualValues(t, 10, balance)
+	}
+}
+
+func TestSenderPool_SourceOverride(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	var keys []kin.PrivateKey
+	for i := 0; i < 4; i++ {
+		key, err := kin.NewPrivateKey()
+		require.NoError(t, err)
+		require.NoError(t, env.client.CreateAccount(context.Background(), key))
+		keys = append(keys, key)
+	}
+	sender, escrowOwner, dest := keys[0], keys[1], keys[2]
+	escrow := kin.PublicKey(testutil.GenerateSolanaKeys(t, 1)[0])
+
+	pool, err := NewSenderPool(env.client, []kin.PrivateKey{sender})
+	require.NoError(t, err)
+
+	// Only the earn transferred from the wallet is deducted from its balance.
+	result, err := pool.SubmitEarnBatch(context.Background(), EarnBatch{
+		Earns: []Earn{
+			{Destination: dest.Public(), Quarks: 2},
+			{Destination: keys[3].Public(), Quarks: 3, Source: escrow, Owner: escrowOwner},
+		},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result.TxError)
+
+	balance, ok := pool.Balance(sender.Public())
+	assert.True(t, ok)
+	assert.EqualValues(t, 8, balance)
+}
+
+func TestSenderPool_TopUp(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	var keys []kin.PrivateKey
+	for i := 0; i < 3; i++ {
+		key, err := kin.NewPrivateKey()
+		require.NoError(t, err)
+		require.NoError(t, env.client.CreateAccount(context.Background(), key))
+		keys = append(keys, key)
+	}
+	sender, treasury, dest := keys[0], keys[1], keys[2]
+
+	_, err := NewSenderPool(env.client, []kin.PrivateKey{sender}, WithTreasury(treasury, 9, 0))
+	assert.Error(t, err)
+
+	pool, err := NewSenderPool(env.client, []kin.PrivateKey{sender}, WithTreasury(treasury, 9, 100))
+	require.NoError(t, err)
+
+	result, err := pool.SubmitEarnBatch(context.Background(), EarnBatch{
+		Earns: []Earn{
+			{
+				Destination: dest.Public(),
+				Quarks:      2,
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result.TxError)
+
+	balance, ok := pool.Balance(sender.Public())
+	assert.True(t, ok)
+	assert.EqualValues(t, 108, balance)
+
+	env.v4Server.Mux.Lock()
+	defer env.v4Server.Mux.Unlock()
+	require.Len(t, env.v4Server.Submits, 2)
+
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(env.v4Server.Submits[1].Transaction.Value))
+	transfer, err := token.DecompileTransfer(tx.Message, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, treasury.Public(), transfer.Owner)
+	assert.EqualValues(t, sender.Public(), transfer.Destination)
+	assert.EqualValues(t, 100, transfer.Amount)
+	assert.NotEmpty(t, env.v4Server.Submits[1].DedupeId)
+}
+
+func TestSenderPool_TopUpFailure(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	var keys []kin.PrivateKey
+	for i := 0; i < 3; i++ {
+		key, err := kin.NewPrivateKey()
+		require.NoError(t, err)
+		require.NoError(t, env.client.CreateAccount(context.Background(), key))
+		keys = append(keys, key)
+	}
+	sender, treasury, dest := keys[0], keys[1], keys[2]
+
+	var failures []error
+	pool, err := NewSenderPool(env.client, []kin.PrivateKey{sender}, WithTreasury(treasury, 9, 100), WithTopUpFailureHandler(func(s kin.PublicKey, err error) {
+		assert.Equal(t, sender.Public(), s)
+		failures = append(failures, err)
+	}))
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		nil,
+		{Result: transactionpbv4.SubmitTransactionResponse_REJECTED},
+	}
+	env.v4Server.Mux.Unlock()
+
+	// The batch was paid out, so the failed top up is not returned.
+	result, err := pool.SubmitEarnBatch(context.Background(), EarnBatch{
+		Earns: []Earn{{Destination: dest.Public(), Quarks: 2}},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result.TxError)
+	require.Len(t, failures, 1)
+	assert.Equal(t, ErrTransactionRejected, failures[0])
+
+	_, ok := pool.Balance(sender.Public())
+	assert.False(t, ok)
+}
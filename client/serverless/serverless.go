@@ -0,0 +1,280 @@
+// Package serverless adapts the webhook handlers of the client package (such as
+// client.EventsHandler and client.SignTransactionHandler) to serverless
+// platforms, so that they can be deployed without running an HTTP server.
+//
+// Google Cloud Functions invoke Go HTTP functions with an http.ResponseWriter
+// and *http.Request, so the handlers can be deployed as is. Likewise, Azure
+// Functions custom handlers with enableForwardingHttpRequest set receive the
+// original HTTP request. For other deployments, this package provides:
+//
+//   - APIGatewayHandler, for AWS Lambda functions behind an API Gateway REST
+//     API, or an HTTP API using the 1.0 payload format.
+//   - AzureHandler, for Azure Functions custom handlers that receive the
+//     invocation payload of an HTTP trigger.
+//
+// The handlers are run unmodified, so request signatures are verified and
+// responses are shaped exactly as they are when serving HTTP.
+//
+// To avoid a dependency on the platform SDKs, the event types are defined by
+// this package. They encode to the same JSON as their SDK equivalents, so, for
+// example, the function returned by APIGatewayHandler can be passed directly to
+// lambda.Start.
+package serverless
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// APIGatewayProxyRequest is the event of an AWS API Gateway proxy integration.
+//
+// It corresponds to events.APIGatewayProxyRequest of
+// github.com/aws/aws-lambda-go.
+type APIGatewayProxyRequest struct {
+	Resource                        string              `json:"resource"`
+	Path                            string              `json:"path"`
+	HTTPMethod                      string              `json:"httpMethod"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	PathParameters                  map[string]string   `json:"pathParameters"`
+	StageVariables                  map[string]string   `json:"stageVariables"`
+	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded,omitempty"`
+}
+
+// APIGatewayProxyResponse is the response to an APIGatewayProxyRequest.
+//
+// It corresponds to events.APIGatewayProxyResponse of
+// github.com/aws/aws-lambda-go.
+type APIGatewayProxyResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded,omitempty"`
+}
+
+// APIGatewayFunc is a Lambda function handling API Gateway proxy requests.
+type APIGatewayFunc func(context.Context, APIGatewayProxyRequest) (APIGatewayProxyResponse, error)
+
+// APIGatewayHandler returns a Lambda function that serves API Gateway proxy
+// requests with h.
+//
+// An error is only returned if the request cannot be converted into an
+// *http.Request. Errors of h, including invalid signatures, are returned as
+// responses, as they are when serving HTTP.
+func APIGatewayHandler(h http.Handler) APIGatewayFunc {
+	return func(ctx context.Context, event APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+		body := []byte(event.Body)
+		if event.IsBase64Encoded {
+			var err error
+			if body, err = base64.StdEncoding.DecodeString(event.Body); err != nil {
+				return APIGatewayProxyResponse{}, errors.Wrap(err, "invalid base64 body")
+			}
+		}
+
+		header := make(http.Header)
+		for k, v := range event.Headers {
+			header.Set(k, v)
+		}
+		for k, values := range event.MultiValueHeaders {
+			header.Del(k)
+			for _, v := range values {
+				header.Add(k, v)
+			}
+		}
+
+		query := make(url.Values)
+		for k, v := range event.QueryStringParameters {
+			query.Set(k, v)
+		}
+		for k, values := range event.MultiValueQueryStringParameters {
+			query[k] = values
+		}
+
+		r, err := newRequest(ctx, event.HTTPMethod, event.Path, query, header, body)
+		if err != nil {
+			return APIGatewayProxyResponse{}, err
+		}
+
+		w := newResponseWriter()
+		h.ServeHTTP(w, r)
+
+		resp := APIGatewayProxyResponse{
+			StatusCode:        w.statusCode,
+			Headers:           make(map[string]string),
+			MultiValueHeaders: w.header,
+		}
+		for k := range w.header {
+			resp.Headers[k] = w.header.Get(k)
+		}
+		resp.Body, resp.IsBase64Encoded = w.encodedBody()
+		return resp, nil
+	}
+}
+
+// AzureInvocationRequest is the invocation payload sent to an Azure Functions
+// custom handler.
+type AzureInvocationRequest struct {
+	Data     map[string]AzureHTTPRequest `json:"Data"`
+	Metadata map[string]interface{}      `json:"Metadata"`
+}
+
+// AzureHTTPRequest is the input binding of an HTTP triggered Azure Function.
+type AzureHTTPRequest struct {
+	URL     string              `json:"Url"`
+	Method  string              `json:"Method"`
+	Query   map[string]string   `json:"Query"`
+	Headers map[string][]string `json:"Headers"`
+	Params  map[string]string   `json:"Params"`
+	Body    string              `json:"Body"`
+}
+
+// AzureInvocationResponse is the response of an Azure Functions custom handler
+// to an invocation.
+type AzureInvocationResponse struct {
+	Outputs     map[string]AzureHTTPResponse `json:"Outputs"`
+	Logs        []string                     `json:"Logs"`
+	ReturnValue interface{}                  `json:"ReturnValue"`
+}
+
+// AzureHTTPResponse is the output binding of an HTTP triggered Azure Function.
+type AzureHTTPResponse struct {
+	StatusCode string            `json:"statusCode"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+}
+
+// AzureHandler returns an http.Handler that serves the invocations of an HTTP
+// triggered Azure Function with h. The input and output bindings of the
+// function must be named "req" and "res", which are the defaults.
+//
+// Invocations that do not contain a valid HTTP request are rejected with
+// http.StatusBadRequest. Errors of h, including invalid signatures, are
+// returned in the output binding, as they are when serving HTTP.
+func AzureHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, invocation *http.Request) {
+		var payload AzureInvocationRequest
+		if err := json.NewDecoder(invocation.Body).Decode(&payload); err != nil {
+			http.Error(rw, "invalid invocation", http.StatusBadRequest)
+			return
+		}
+		req, ok := payload.Data["req"]
+		if !ok {
+			http.Error(rw, "missing http request", http.StatusBadRequest)
+			return
+		}
+
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			http.Error(rw, "invalid url", http.StatusBadRequest)
+			return
+		}
+		query := u.Query()
+		for k, v := range req.Query {
+			query.Set(k, v)
+		}
+
+		header := make(http.Header)
+		for k, values := range req.Headers {
+			for _, v := range values {
+				header.Add(k, v)
+			}
+		}
+
+		r, err := newRequest(invocation.Context(), req.Method, u.Path, query, header, []byte(req.Body))
+		if err != nil {
+			http.Error(rw, "invalid http request", http.StatusBadRequest)
+			return
+		}
+
+		w := newResponseWriter()
+		h.ServeHTTP(w, r)
+
+		res := AzureHTTPResponse{
+			StatusCode: strconv.Itoa(w.statusCode),
+			Headers:    make(map[string]string),
+			Body:       w.body.String(),
+		}
+		for k := range w.header {
+			res.Headers[k] = w.header.Get(k)
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(&AzureInvocationResponse{
+			Outputs: map[string]AzureHTTPResponse{"res": res},
+			Logs:    []string{},
+		})
+	})
+}
+
+func newRequest(ctx context.Context, method, path string, query url.Values, header http.Header, body []byte) (*http.Request, error) {
+	if path == "" {
+		path = "/"
+	}
+	u := &url.URL{Path: path, RawQuery: query.Encode()}
+
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid request")
+	}
+	r.Header = header
+	r.Host = header.Get("Host")
+	r.RequestURI = u.RequestURI()
+	return r, nil
+}
+
+// responseWriter is an http.ResponseWriter that buffers the response of a
+// handler.
+type responseWriter struct {
+	header      http.Header
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newResponseWriter() *responseWriter {
+	return &responseWriter{
+		header:     make(http.Header),
+		statusCode: http.StatusOK,
+	}
+}
+
+// Header implements http.ResponseWriter.Header.
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements http.ResponseWriter.WriteHeader.
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.statusCode = statusCode
+	w.wroteHeader = true
+}
+
+// Write implements http.ResponseWriter.Write.
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// encodedBody returns the body as a string, base64 encoding it if it is not
+// valid UTF-8.
+func (w *responseWriter) encodedBody() (string, bool) {
+	if utf8.Valid(w.body.Bytes()) {
+		return w.body.String(), false
+	}
+	return base64.StdEncoding.EncodeToString(w.body.Bytes()), true
+}
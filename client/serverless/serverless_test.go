@@ -0,0 +1,173 @@
+package serverless
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+const secret = "secret"
+
+func genEvents(t *testing.T) ([]events.Event, []byte, string) {
+	data := []events.Event{
+		{
+			TransactionEvent: &events.TransactionEvent{
+				KinVersion: 4,
+				TxID:       []byte("sig"),
+			},
+		},
+	}
+	body, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	h := hmac.New(sha256.New, []byte(secret))
+	_, _ = h.Write(body)
+	return data, body, base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func TestAPIGatewayHandler(t *testing.T) {
+	data, body, sig := genEvents(t)
+
+	var received []events.Event
+	f := APIGatewayHandler(client.EventsHandler(secret, func(e []events.Event) error {
+		received = e
+		return nil
+	}))
+
+	for _, event := range []APIGatewayProxyRequest{
+		{
+			Path:       "/events",
+			HTTPMethod: http.MethodPost,
+			Headers: map[string]string{
+				"x-agora-hmac-sha256": sig,
+				"content-type":        "application/json",
+			},
+			Body: string(body),
+		},
+		{
+			Path:       "/events",
+			HTTPMethod: http.MethodPost,
+			MultiValueHeaders: map[string][]string{
+				client.AgoraHMACHeader: {sig},
+			},
+			Body:            base64.StdEncoding.EncodeToString(body),
+			IsBase64Encoded: true,
+		},
+	} {
+		received = nil
+
+		resp, err := f(context.Background(), event)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEmpty(t, resp.Headers[http.CanonicalHeaderKey(client.RequestIDHeader)])
+		assert.Equal(t, data, received)
+	}
+
+	// Invalid signatures are rejected by the handler.
+	received = nil
+	resp, err := f(context.Background(), APIGatewayProxyRequest{
+		Path:       "/events",
+		HTTPMethod: http.MethodPost,
+		Headers:    map[string]string{client.AgoraHMACHeader: sig},
+		Body:       string(body) + " ",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Nil(t, received)
+
+	_, err = f(context.Background(), APIGatewayProxyRequest{
+		HTTPMethod:      http.MethodPost,
+		Body:            "invalid",
+		IsBase64Encoded: true,
+	})
+	assert.Error(t, err)
+}
+
+func TestAPIGatewayHandler_Response(t *testing.T) {
+	f := APIGatewayHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/sign_transaction", r.URL.Path)
+		assert.Equal(t, "b", r.URL.Query().Get("a"))
+
+		w.Header().Add("X-Values", "1")
+		w.Header().Add("X-Values", "2")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte{0xff, 0xfe})
+	}))
+
+	resp, err := f(context.Background(), APIGatewayProxyRequest{
+		Path:                  "/sign_transaction",
+		HTTPMethod:            http.MethodPost,
+		QueryStringParameters: map[string]string{"a": "b"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "1", resp.Headers["X-Values"])
+	assert.Equal(t, []string{"1", "2"}, resp.MultiValueHeaders["X-Values"])
+	assert.True(t, resp.IsBase64Encoded)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte{0xff, 0xfe}), resp.Body)
+}
+
+func TestAzureHandler(t *testing.T) {
+	data, body, sig := genEvents(t)
+
+	var received []events.Event
+	h := AzureHandler(client.EventsHandler(secret, func(e []events.Event) error {
+		received = e
+		return nil
+	}))
+
+	invoke := func(sig string) AzureHTTPResponse {
+		payload, err := json.Marshal(&AzureInvocationRequest{
+			Data: map[string]AzureHTTPRequest{
+				"req": {
+					URL:     "https://example.azurewebsites.net/api/events",
+					Method:  http.MethodPost,
+					Headers: map[string][]string{client.AgoraHMACHeader: {sig}},
+					Body:    string(body),
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, "/events", bytes.NewReader(payload))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var resp AzureInvocationResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		require.Contains(t, resp.Outputs, "res")
+		return resp.Outputs["res"]
+	}
+
+	res := invoke(sig)
+	assert.Equal(t, "200", res.StatusCode)
+	assert.NotEmpty(t, res.Headers[http.CanonicalHeaderKey(client.RequestIDHeader)])
+	assert.Equal(t, data, received)
+
+	received = nil
+	res = invoke("invalid")
+	assert.Equal(t, "401", res.StatusCode)
+	assert.Nil(t, received)
+
+	for _, payload := range []string{"{", `{"Data": {}}`} {
+		req, err := http.NewRequest(http.MethodPost, "/events", bytes.NewBufferString(payload))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+)
+
+// maxSKULength is the maximum length of the SKU of an invoice line item.
+const maxSKULength = 128
+
+// SKU is a product in a SKUCatalog.
+type SKU struct {
+	// ID is the SKU of the product, as it appears in invoice line items.
+	ID []byte
+
+	Title       string
+	Description string
+
+	// Quarks is the price of the product.
+	Quarks int64
+}
+
+// SKUCatalog maps SKUs to products, which it uses to generate invoices for
+// payments, and to validate the invoices of payments in sign transaction
+// webhooks. It is safe for concurrent use.
+type SKUCatalog struct {
+	mu   sync.RWMutex
+	skus map[string]SKU
+}
+
+// NewSKUCatalog returns a SKUCatalog containing skus.
+func NewSKUCatalog(skus ...SKU) (*SKUCatalog, error) {
+	c := &SKUCatalog{skus: make(map[string]SKU)}
+	for _, sku := range skus {
+		if err := c.Add(sku); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Add adds sku to the catalog, replacing any product with the same ID.
+func (c *SKUCatalog) Add(sku SKU) error {
+	if len(sku.ID) == 0 || len(sku.ID) > maxSKULength {
+		return errors.Errorf("invalid sku length: %d", len(sku.ID))
+	}
+	if sku.Title == "" {
+		return errors.Errorf("sku %x has no title", sku.ID)
+	}
+	if err := validateQuarks(sku.Quarks); err != nil {
+		return errors.Wrapf(err, "invalid price for sku %x", sku.ID)
+	}
+
+	c.mu.Lock()
+	c.skus[string(sku.ID)] = sku
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Remove removes the product with the specified ID, if it exists.
+func (c *SKUCatalog) Remove(id []byte) {
+	c.mu.Lock()
+	delete(c.skus, string(id))
+	c.mu.Unlock()
+}
+
+// Get returns the product with the specified ID, if it exists.
+func (c *SKUCatalog) Get(id []byte) (SKU, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	sku, ok := c.skus[string(id)]
+	return sku, ok
+}
+
+// Invoice returns an invoice with a line item for each of the specified
+// products, in order. An error whose cause is ErrSKUNotFound is returned if
+// any product is not in the catalog.
+func (c *SKUCatalog) Invoice(ids ...[]byte) (*commonpb.Invoice, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("no skus specified")
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	invoice := &commonpb.Invoice{
+		Items: make([]*commonpb.Invoice_LineItem, len(ids)),
+	}
+	for i, id := range ids {
+		sku, ok := c.skus[string(id)]
+		if !ok {
+			return nil, errors.Wrapf(ErrSKUNotFound, "sku %x", id)
+		}
+
+		invoice.Items[i] = &commonpb.Invoice_LineItem{
+			Title:       sku.Title,
+			Description: sku.Description,
+			Amount:      sku.Quarks,
+			Sku:         sku.ID,
+		}
+	}
+
+	if err := invoice.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid invoice")
+	}
+	return invoice, nil
+}
+
+// Payment returns a spend payment from sender to destination for the specified
+// products, with an invoice generated by Invoice.
+func (c *SKUCatalog) Payment(sender kin.PrivateKey, destination kin.PublicKey, ids ...[]byte) (Payment, error) {
+	invoice, err := c.Invoice(ids...)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	var total int64
+	for _, item := range invoice.Items {
+		total += item.Amount
+	}
+	if err := validateQuarks(total); err != nil {
+		return Payment{}, err
+	}
+
+	return Payment{
+		Sender:      sender,
+		Destination: destination,
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      total,
+		Invoice:     invoice,
+	}, nil
+}
+
+// Validate validates the invoices of the payments in req against the catalog,
+// and returns whether they are all valid. Payments without invoices are not
+// validated.
+//
+// Payments with line items whose SKU is not in the catalog are marked with
+// resp.MarkSKUNotFound. Since Agora has no invoice error for incorrect amounts,
+// the transaction is rejected without reason if the amount of a line item is
+// not the price of its product, or the amount of a payment is not the total of
+// its invoice.
+func (c *SKUCatalog) Validate(req SignTransactionRequest, resp *SignTransactionResponse) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	valid := true
+	for i, p := range req.Payments {
+		if p.Invoice == nil {
+			continue
+		}
+
+		var total int64
+		var notFound, wrongAmount bool
+		for _, item := range p.Invoice.Items {
+			total += item.Amount
+
+			sku, ok := c.skus[string(item.Sku)]
+			if !ok {
+				notFound = true
+			} else if item.Amount != sku.Quarks {
+				wrongAmount = true
+			}
+		}
+		if total != p.Quarks {
+			wrongAmount = true
+		}
+
+		switch {
+		case notFound:
+			resp.MarkSKUNotFound(i)
+		case wrongAmount:
+			resp.Reject()
+		default:
+			continue
+		}
+		valid = false
+	}
+
+	return valid
+}
+
+// WrapSignTransaction returns a SignTransactionFunc that validates requests with
+// Validate, before forwarding valid requests to f. Invalid requests are
+// rejected without calling f.
+func (c *SKUCatalog) WrapSignTransaction(f SignTransactionFunc) SignTransactionFunc {
+	return func(req SignTransactionRequest, resp *SignTransactionResponse) error {
+		if !c.Validate(req, resp) {
+			return nil
+		}
+		return f(req, resp)
+	}
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/webhook/signtransaction"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+)
+
+func TestSKUCatalog(t *testing.T) {
+	coffee := SKU{ID: []byte("coffee"), Title: "Coffee", Quarks: 100}
+	cake := SKU{ID: []byte("cake"), Title: "Cake", Description: "Chocolate", Quarks: 250}
+
+	c, err := NewSKUCatalog(coffee, cake)
+	require.NoError(t, err)
+
+	sku, ok := c.Get([]byte("cake"))
+	assert.True(t, ok)
+	assert.Equal(t, cake, sku)
+
+	for _, invalid := range []SKU{
+		{Title: "no id", Quarks: 1},
+		{ID: make([]byte, maxSKULength+1), Title: "long id", Quarks: 1},
+		{ID: []byte("untitled"), Quarks: 1},
+		{ID: []byte("free"), Title: "Free"},
+		{ID: []byte("expensive"), Title: "Expensive", Quarks: MaxSupplyQuarks + 1},
+	} {
+		assert.Error(t, c.Add(invalid))
+	}
+	_, err = NewSKUCatalog(coffee, SKU{ID: []byte("free"), Title: "Free"})
+	assert.Error(t, err)
+
+	sender := goldenKey("sender")
+	dest := goldenKey("destination").Public()
+	p, err := c.Payment(sender, dest, coffee.ID, cake.ID, coffee.ID)
+	require.NoError(t, err)
+	assert.Equal(t, kin.TransactionTypeSpend, p.Type)
+	assert.EqualValues(t, 450, p.Quarks)
+	require.Len(t, p.Invoice.Items, 3)
+	assert.Equal(t, &commonpb.Invoice_LineItem{
+		Title:       "Cake",
+		Description: "Chocolate",
+		Amount:      250,
+		Sku:         []byte("cake"),
+	}, p.Invoice.Items[1])
+
+	_, err = c.Invoice()
+	assert.Error(t, err)
+	_, err = c.Invoice(coffee.ID, []byte("tea"))
+	assert.Equal(t, ErrSKUNotFound, errors.Cause(err))
+
+	c.Remove(cake.ID)
+	_, ok = c.Get(cake.ID)
+	assert.False(t, ok)
+	_, err = c.Payment(sender, dest, cake.ID)
+	assert.Equal(t, ErrSKUNotFound, errors.Cause(err))
+}
+
+func TestSKUCatalog_Validate(t *testing.T) {
+	coffee := SKU{ID: []byte("coffee"), Title: "Coffee", Quarks: 100}
+	c, err := NewSKUCatalog(coffee)
+	require.NoError(t, err)
+
+	invoice := func(skus ...string) *commonpb.Invoice {
+		inv := &commonpb.Invoice{}
+		for _, sku := range skus {
+			inv.Items = append(inv.Items, &commonpb.Invoice_LineItem{Title: sku, Amount: 100, Sku: []byte(sku)})
+		}
+		return inv
+	}
+	wrongPrice := invoice("coffee")
+	wrongPrice.Items[0].Amount = 50
+
+	for _, tc := range []struct {
+		payments []ReadOnlyPayment
+		outcome  SignOutcome
+		errors   []signtransaction.InvoiceError
+	}{
+		{
+			payments: []ReadOnlyPayment{
+				{Quarks: 10},
+				{Quarks: 200, Invoice: invoice("coffee", "coffee")},
+			},
+			outcome: SignOutcomeApproved,
+		},
+		{
+			payments: []ReadOnlyPayment{
+				{Quarks: 100, Invoice: invoice("coffee")},
+				{Quarks: 200, Invoice: invoice("coffee", "tea")},
+			},
+			outcome: SignOutcomeInvoiceErrors,
+			errors: []signtransaction.InvoiceError{
+				{OperationIndex: 1, Reason: signtransaction.SKUNotFound},
+			},
+		},
+		{
+			payments: []ReadOnlyPayment{{Quarks: 50, Invoice: wrongPrice}},
+			outcome:  SignOutcomeRejected,
+		},
+		{
+			payments: []ReadOnlyPayment{{Quarks: 90, Invoice: invoice("coffee")}},
+			outcome:  SignOutcomeRejected,
+		},
+	} {
+		var called bool
+		f := c.WrapSignTransaction(func(SignTransactionRequest, *SignTransactionResponse) error {
+			called = true
+			return nil
+		})
+
+		resp := &SignTransactionResponse{payments: len(tc.payments)}
+		require.NoError(t, f(SignTransactionRequest{Payments: tc.payments}, resp))
+		assert.Equal(t, tc.outcome, resp.Outcome())
+		assert.Equal(t, tc.errors, resp.errors)
+		assert.Equal(t, tc.outcome == SignOutcomeApproved, called)
+	}
+}
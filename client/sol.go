@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/system"
+	"github.com/pkg/errors"
+)
+
+// solConfirmationInterval is the interval at which the status of a SOL transfer
+// submitted through the Solana RPC endpoint is polled.
+const solConfirmationInterval = 500 * time.Millisecond
+
+// systemCommandTransfer is the System program Transfer instruction.
+const systemCommandTransfer = 2
+
+// transferSolInstruction returns a System program instruction that transfers
+// lamports from one account to another.
+//
+// Reference: https://github.com/solana-labs/solana/blob/f02a78d8fff2dd7297dc6ce6eb5a68a3002f5359/sdk/src/system_instruction.rs#L111-L117
+func transferSolInstruction(from, to ed25519.PublicKey, lamports uint64) solana.Instruction {
+	// # Account references
+	//   0. [WRITE, SIGNER] Funding account
+	//   1. [WRITE] Recipient account
+	data := make([]byte, 4+8)
+	binary.LittleEndian.PutUint32(data, systemCommandTransfer)
+	binary.LittleEndian.PutUint64(data[4:], lamports)
+
+	return solana.NewInstruction(
+		system.ProgramKey[:],
+		data,
+		solana.NewAccountMeta(from, true),
+		solana.NewAccountMeta(to, false),
+	)
+}
+
+func (c *client) GetSolBalance(ctx context.Context, account kin.PublicKey, opts ...SolanaOption) (uint64, error) {
+	solanaOpts := solanaOpts{commitment: c.opts.defaultCommitment}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	if c.rpc == nil {
+		return 0, ErrNoSolanaRPC
+	}
+
+	lamports, err := c.rpc.getBalance(ctx, account, solanaOpts.commitment)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get sol balance")
+	}
+
+	return lamports, nil
+}
+
+func (c *client) TransferSol(ctx context.Context, sender kin.PrivateKey, destination kin.PublicKey, lamports uint64, opts ...SolanaOption) ([]byte, error) {
+	solanaOpts := solanaOpts{commitment: c.opts.defaultCommitment}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	if lamports == 0 {
+		return nil, errors.New("lamports must be positive")
+	}
+
+	done, err := c.lifecycle.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	blockhash, err := c.internal.GetRecentBlockhash(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get recent blockhash")
+	}
+
+	tx := solana.NewTransaction(
+		ed25519.PublicKey(sender.Public()),
+		transferSolInstruction(ed25519.PublicKey(sender.Public()), ed25519.PublicKey(destination), lamports),
+	)
+	tx.SetBlockhash(blockhash)
+	if err := tx.Sign(ed25519.PrivateKey(sender)); err != nil {
+		return nil, errors.Wrap(err, "failed to sign transaction")
+	}
+
+	if c.rpc == nil {
+		result, err := c.internal.SubmitSolanaTransaction(ctx, tx, nil, solanaOpts.commitment, nil)
+		if err != nil {
+			return tx.Signature(), err
+		}
+		return result.ID, result.Errors.TxError
+	}
+
+	if err := c.rpc.sendTransaction(ctx, tx.Marshal(), solanaOpts.commitment); err != nil {
+		return tx.Signature(), errors.Wrap(err, "failed to submit transaction")
+	}
+
+	txErrors, err := c.rpc.awaitCommitment(ctx, tx.Signature(), solanaOpts.commitment, solConfirmationInterval)
+	if err != nil {
+		return tx.Signature(), errors.Wrap(err, "failed to confirm transaction")
+	}
+
+	return tx.Signature(), txErrors.TxError
+}
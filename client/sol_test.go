@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/system"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Sol(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	_, err = env.client.GetSolBalance(context.Background(), sender.Public())
+	assert.Equal(t, ErrNoSolanaRPC, err)
+
+	assertTransfer := func(raw []byte, txID []byte) {
+		var tx solana.Transaction
+		require.NoError(t, tx.Unmarshal(raw))
+		assert.Equal(t, tx.Signature(), txID)
+		assert.True(t, ed25519.Verify(ed25519.PublicKey(sender.Public()), tx.Message.Marshal(), txID))
+
+		require.Len(t, tx.Message.Instructions, 1)
+		instruction := tx.Message.Instructions[0]
+		assert.EqualValues(t, system.ProgramKey[:], tx.Message.Accounts[instruction.ProgramIndex])
+		assert.EqualValues(t, sender.Public(), tx.Message.Accounts[instruction.Accounts[0]])
+		assert.EqualValues(t, dest.Public(), tx.Message.Accounts[instruction.Accounts[1]])
+		assert.EqualValues(t, systemCommandTransfer, binary.LittleEndian.Uint32(instruction.Data))
+		assert.EqualValues(t, 1000, binary.LittleEndian.Uint64(instruction.Data[4:]))
+	}
+
+	// Without a Solana RPC endpoint, transfers are submitted to Agora.
+	txID, err := env.client.TransferSol(context.Background(), sender, dest.Public(), 1000)
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 1)
+	assertTransfer(env.v4Server.Submits[0].Transaction.Value, txID)
+	env.v4Server.Mux.Unlock()
+
+	_, err = env.client.TransferSol(context.Background(), sender, dest.Public(), 0)
+	assert.Error(t, err)
+
+	rpc := newRPCServer(t)
+	defer rpc.Close()
+
+	env, cleanup = setup(t, WithSolanaRPC(rpc.URL))
+	defer cleanup()
+
+	rpc.setBalance(sender.Public(), 5000)
+	balance, err := env.client.GetSolBalance(context.Background(), sender.Public())
+	require.NoError(t, err)
+	assert.EqualValues(t, 5000, balance)
+
+	txID, err = env.client.TransferSol(context.Background(), sender, dest.Public(), 1000)
+	require.NoError(t, err)
+
+	rpc.mu.Lock()
+	defer rpc.mu.Unlock()
+	require.Len(t, rpc.requests, 3)
+	assert.Equal(t, "sendTransaction", rpc.requests[1].Method)
+	raw, err := base64.StdEncoding.DecodeString(rpc.requests[1].Params[0].(string))
+	require.NoError(t, err)
+	assertTransfer(raw, txID)
+	assert.Equal(t, "getSignatureStatuses", rpc.requests[2].Method)
+
+	env.v4Server.Mux.Lock()
+	assert.Empty(t, env.v4Server.Submits)
+	env.v4Server.Mux.Unlock()
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+)
+
+// WithSolanaRPC specifies the endpoint of a Solana JSON RPC node, which is used for
+// queries that Agora does not support, such as the SOL balance of an account.
+func WithSolanaRPC(endpoint string) ClientOption {
+	return func(o *clientOpts) {
+		o.solanaRPCEndpoint = endpoint
+	}
+}
+
+// solanaRPC is a minimal Solana JSON RPC client.
+type solanaRPC struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+func newSolanaRPC(endpoint string) *solanaRPC {
+	return &solanaRPC{
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+func (r *solanaRPC) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode rpc request")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create rpc request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s", method)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status from solana rpc: %d", resp.StatusCode)
+	}
+
+	var rpcResp rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return errors.Wrap(err, "failed to decode rpc response")
+	}
+	if rpcResp.Error != nil {
+		return errors.Wrapf(rpcResp.Error, "failed to call %s", method)
+	}
+
+	return errors.Wrap(json.Unmarshal(rpcResp.Result, result), "failed to decode rpc result")
+}
+
+// getBalance returns the SOL balance of an account, in lamports.
+func (r *solanaRPC) getBalance(ctx context.Context, account kin.PublicKey, commitment commonpbv4.Commitment) (uint64, error) {
+	var result struct {
+		Value uint64 `json:"value"`
+	}
+	err := r.call(ctx, "getBalance", &result, account.Base58(), map[string]string{
+		"commitment": rpcCommitment(commitment),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Value, nil
+}
+
+func rpcCommitment(commitment commonpbv4.Commitment) string {
+	switch commitment {
+	case commonpbv4.Commitment_RECENT:
+		return "processed"
+	case commonpbv4.Commitment_SINGLE:
+		return "confirmed"
+	default:
+		return "finalized"
+	}
+}
+
+// sendTransaction submits a signed transaction, without waiting for it to be
+// confirmed.
+func (r *solanaRPC) sendTransaction(ctx context.Context, raw []byte, commitment commonpbv4.Commitment) error {
+	var sig string
+	return r.call(ctx, "sendTransaction", &sig, base64.StdEncoding.EncodeToString(raw), map[string]string{
+		"encoding":            "base64",
+		"preflightCommitment": rpcCommitment(commitment),
+	})
+}
+
+// awaitCommitment polls the status of the transaction identified by txID until
+// it reaches commitment, it fails, or ctx is done. The transaction error, if
+// any, is returned as a TransactionErrors.
+func (r *solanaRPC) awaitCommitment(ctx context.Context, txID []byte, commitment commonpbv4.Commitment, interval time.Duration) (TransactionErrors, error) {
+	levels := map[string]int{"processed": 0, "confirmed": 1, "finalized": 2}
+	target := levels[rpcCommitment(commitment)]
+
+	for {
+		var result struct {
+			Value []*struct {
+				Err                json.RawMessage `json:"err"`
+				ConfirmationStatus string          `json:"confirmationStatus"`
+			} `json:"value"`
+		}
+		err := r.call(ctx, "getSignatureStatuses", &result, []string{base58.Encode(txID)}, map[string]bool{
+			"searchTransactionHistory": true,
+		})
+		if err != nil {
+			return TransactionErrors{}, err
+		}
+
+		if len(result.Value) > 0 && result.Value[0] != nil {
+			status := result.Value[0]
+			if len(status.Err) > 0 && string(status.Err) != "null" {
+				return TransactionErrors{TxError: errors.Errorf("transaction failed: %s", status.Err)}, nil
+			}
+			if level, ok := levels[status.ConfirmationStatus]; ok && level >= target {
+				return TransactionErrors{}, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return TransactionErrors{}, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
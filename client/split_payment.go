@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+)
+
+// MaxBasisPoints is the number of basis points in a whole, such that a Split
+// with BasisPoints of MaxBasisPoints receives the entire remainder of a split
+// payment.
+const MaxBasisPoints = 10000
+
+// Split is a share of a split payment. Exactly one of Quarks and BasisPoints
+// must be set.
+type Split struct {
+	Destination kin.PublicKey
+
+	// Quarks is a fixed amount paid to the destination, such as a flat fee.
+	Quarks int64
+
+	// BasisPoints is the share of the amount remaining after the fixed
+	// amounts are paid, in hundredths of a percent. The basis points of all
+	// splits must sum to MaxBasisPoints.
+	BasisPoints int64
+
+	// Invoice is the invoice of the split, whose line items describe what the
+	// destination is paid for. Either all or none of the splits must have an
+	// invoice.
+	Invoice *commonpb.Invoice
+}
+
+// SplitAmounts divides total across splits, returning the amount of each split.
+//
+// Fixed amounts are paid first, and the remainder is divided by basis points,
+// rounding down. Any quarks lost to rounding are added to the first split with
+// basis points, so that the amounts sum to exactly total. If no split has basis
+// points, the fixed amounts must sum to exactly total.
+func SplitAmounts(total int64, splits []Split) ([]int64, error) {
+	if err := validateQuarks(total); err != nil {
+		return nil, err
+	}
+	if len(splits) == 0 {
+		return nil, errors.New("split payment must contain at least 1 split")
+	}
+
+	amounts := make([]int64, len(splits))
+	remainder := total
+	var basisPoints int64
+	first := -1
+	for i, s := range splits {
+		switch {
+		case s.Quarks != 0 && s.BasisPoints != 0:
+			return nil, errors.Errorf("split %d: only one of quarks and basis points may be set", i)
+		case s.Quarks < 0:
+			return nil, errors.Errorf("split %d: quarks must be positive", i)
+		case s.Quarks > 0:
+			if s.Quarks > remainder {
+				return nil, errors.Errorf("split %d: fixed amounts exceed the total", i)
+			}
+			amounts[i] = s.Quarks
+			remainder -= s.Quarks
+		case s.BasisPoints < 0 || s.BasisPoints > MaxBasisPoints:
+			return nil, errors.Errorf("split %d: basis points must be between 1 and %d", i, MaxBasisPoints)
+		case s.BasisPoints > 0:
+			basisPoints += s.BasisPoints
+			if first < 0 {
+				first = i
+			}
+		default:
+			return nil, errors.Errorf("split %d: one of quarks and basis points must be set", i)
+		}
+	}
+
+	if first < 0 {
+		if remainder != 0 {
+			return nil, errors.Errorf("fixed amounts sum to %d, not the total of %d", total-remainder, total)
+		}
+		return amounts, nil
+	}
+	if basisPoints != MaxBasisPoints {
+		return nil, errors.Errorf("basis points sum to %d, not %d", basisPoints, MaxBasisPoints)
+	}
+
+	// The remainder is split as (q * MaxBasisPoints + r) * bp / MaxBasisPoints,
+	// which cannot overflow, unlike remainder * bp.
+	q, r := remainder/MaxBasisPoints, remainder%MaxBasisPoints
+	distributed := int64(0)
+	for i, s := range splits {
+		if s.BasisPoints == 0 {
+			continue
+		}
+		amounts[i] = q*s.BasisPoints + r*s.BasisPoints/MaxBasisPoints
+		distributed += amounts[i]
+	}
+	amounts[first] += remainder - distributed
+
+	for i, amount := range amounts {
+		if amount == 0 {
+			return nil, errors.Errorf("split %d: amount rounds to zero", i)
+		}
+	}
+
+	return amounts, nil
+}
+
+// SubmitSplitPayment submits a payment of total quarks from sender, divided
+// across the destinations of splits (see SplitAmounts), in a single spend
+// transaction.
+//
+// The split payment is submitted in the same way as an earn batch, so the
+// accounts are resolved, and EarnErrors refer to the index of the split. The
+// number of splits is limited to MaxBatchSize.
+func (c *client) SubmitSplitPayment(ctx context.Context, sender kin.PrivateKey, total int64, splits []Split, opts ...SolanaOption) (EarnBatchResult, error) {
+	amounts, err := SplitAmounts(total, splits)
+	if err != nil {
+		return EarnBatchResult{}, err
+	}
+	if len(splits) > MaxBatchSize {
+		return EarnBatchResult{}, errors.Errorf("split payment must not contain more than %d splits", MaxBatchSize)
+	}
+
+	batch := EarnBatch{
+		Sender: sender,
+		Earns:  make([]Earn, len(splits)),
+		txType: kin.TransactionTypeSpend,
+	}
+	for i, s := range splits {
+		batch.Earns[i] = Earn{
+			Destination: s.Destination,
+			Quarks:      amounts[i],
+			Invoice:     s.Invoice,
+		}
+	}
+
+	return c.SubmitEarnBatch(ctx, batch, opts...)
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+
+	"github.com/kinecosystem/kin-go/client/testutil"
+)
+
+func TestSplitAmounts(t *testing.T) {
+	for _, tc := range []struct {
+		total    int64
+		splits   []Split
+		expected []int64
+	}{
+		{100, []Split{{Quarks: 60}, {Quarks: 40}}, []int64{60, 40}},
+		{100, []Split{{BasisPoints: 7000}, {BasisPoints: 3000}}, []int64{70, 30}},
+		// The fee is paid first, and the remainder is shared.
+		{110, []Split{{BasisPoints: 5000}, {Quarks: 10}, {BasisPoints: 5000}}, []int64{50, 10, 50}},
+		// Rounding dust goes to the first share.
+		{100, []Split{{BasisPoints: 3333}, {BasisPoints: 3333}, {BasisPoints: 3334}}, []int64{34, 33, 33}},
+		// Large amounts do not overflow.
+		{MaxSupplyQuarks, []Split{{BasisPoints: 5000}, {BasisPoints: 5000}}, []int64{MaxSupplyQuarks / 2, MaxSupplyQuarks / 2}},
+	} {
+		amounts, err := SplitAmounts(tc.total, tc.splits)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, amounts)
+	}
+
+	for _, tc := range []struct {
+		total  int64
+		splits []Split
+	}{
+		{0, []Split{{Quarks: 1}}},
+		{100, nil},
+		{100, []Split{{Quarks: 60}, {Quarks: 30}}},
+		{100, []Split{{Quarks: 60}, {Quarks: 50}}},
+		{100, []Split{{Quarks: 100}, {}}},
+		{100, []Split{{Quarks: 50, BasisPoints: 10000}}},
+		{100, []Split{{Quarks: -1}, {Quarks: 101}}},
+		{100, []Split{{BasisPoints: 5000}, {BasisPoints: 4000}}},
+		{100, []Split{{BasisPoints: -1}, {BasisPoints: 10001}}},
+		{100, []Split{{Quarks: 99}, {BasisPoints: 9000}, {BasisPoints: 1000}}},
+	} {
+		_, err := SplitAmounts(tc.total, tc.splits)
+		assert.Error(t, err, "%d %v", tc.total, tc.splits)
+	}
+}
+
+func TestClient_SubmitSplitPayment(t *testing.T) {
+	env, cleanup := setup(t, WithAppIndex(1))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dests := testutil.GenerateSolanaKeys(t, 3)
+
+	invoice := func(title string) *commonpb.Invoice {
+		return &commonpb.Invoice{Items: []*commonpb.Invoice_LineItem{{Title: title, Amount: 1}}}
+	}
+	splits := []Split{
+		{Destination: kin.PublicKey(dests[0]), BasisPoints: 8000, Invoice: invoice("seller")},
+		{Destination: kin.PublicKey(dests[1]), Quarks: 50, Invoice: invoice("fee")},
+		{Destination: kin.PublicKey(dests[2]), BasisPoints: 2000, Invoice: invoice("affiliate")},
+	}
+
+	result, err := env.client.SubmitSplitPayment(context.Background(), sender, 1050, splits)
+	require.NoError(t, err)
+	assert.Nil(t, result.TxError)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 1)
+	submit := env.v4Server.Submits[0]
+	env.v4Server.Mux.Unlock()
+
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(submit.Transaction.Value))
+
+	// The transaction is a spend, whose invoices are those of the splits.
+	m, err := memo.DecompileMemo(tx.Message, 0)
+	require.NoError(t, err)
+	kinMemo, err := kin.MemoFromBase64String(string(m.Data), true)
+	require.NoError(t, err)
+	assert.Equal(t, kin.TransactionTypeSpend, kinMemo.TransactionType())
+
+	require.Len(t, submit.InvoiceList.Invoices, 3)
+	assert.Equal(t, "fee", submit.InvoiceList.Invoices[1].Items[0].Title)
+
+	for i, expected := range []uint64{800, 50, 200} {
+		transfer, err := token.DecompileTransfer(tx.Message, i+1)
+		require.NoError(t, err)
+		assert.EqualValues(t, dests[i], transfer.Destination)
+		assert.Equal(t, expected, transfer.Amount)
+	}
+
+	_, err = env.client.SubmitSplitPayment(context.Background(), sender, 1050, splits[:2])
+	assert.Error(t, err)
+}
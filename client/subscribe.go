@@ -0,0 +1,292 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/pkg/errors"
+
+	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+func (c *client) Subscribe(ctx context.Context, account kin.PublicKey, sinceCursor Cursor, opts ...SolanaOption) (<-chan SubscriptionResult, error) {
+	solanaOpts := solanaOpts{}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	// The event stream is opened before the history is replayed, so that any
+	// transaction that is not in the replayed history is received as an event.
+	events, err := c.internal.GetEvents(ctx, account)
+	if err != nil {
+		cancel()
+		return nil, errors.Wrap(err, "failed to open event stream")
+	}
+
+	s := &subscription{
+		client:      c,
+		account:     account,
+		cursor:      sinceCursor,
+		raw:         solanaOpts.rawTransaction,
+		filters:     solanaOpts.filters,
+		seen:        make(map[string]struct{}, subscriptionDedupeWindow),
+		results:     make(chan SubscriptionResult),
+		eventsReady: make(chan struct{}, 1),
+	}
+
+	go s.buffer(events)
+	go func() {
+		defer cancel()
+		defer close(s.results)
+		s.run(ctx)
+	}()
+
+	return s.results, nil
+}
+
+const (
+	// subscriptionDedupeWindow is the number of most recently emitted
+	// transactions that a subscription remembers, so that transactions
+	// received from both the history and the event stream are only emitted
+	// once.
+	subscriptionDedupeWindow = 1024
+
+	// maxSubscriptionEvents is the maximum number of event stream results that
+	// a subscription buffers. Further results are dropped, and the
+	// transactions they contain are found in the history instead.
+	maxSubscriptionEvents = 1024
+)
+
+// subscription replays the history of an account, and then the transactions
+// received from its event stream.
+//
+// Events are used as a signal to read the history from the cursor, so that the
+// transactions they contain are emitted with a cursor. Transactions that are
+// not yet in the history are emitted from the event instead.
+type subscription struct {
+	client  *client
+	account kin.PublicKey
+	cursor  Cursor
+	raw     bool
+	filters []FilterOption
+
+	// seen contains the IDs of the most recently emitted transactions, which
+	// are not emitted again if they are received from the event stream, or
+	// from the history after being emitted from the event stream. order
+	// contains the same IDs, in a ring of subscriptionDedupeWindow entries.
+	seen    map[string]struct{}
+	order   []string
+	next    int
+	results chan SubscriptionResult
+
+	// events buffers the results of the event stream while they are being
+	// processed, so that the stream is not blocked. If the buffer is full,
+	// results are dropped, and dropped is set.
+	mu          sync.Mutex
+	events      []EventsResult
+	dropped     bool
+	eventsDone  bool
+	eventsReady chan struct{}
+}
+
+func (s *subscription) buffer(events <-chan EventsResult) {
+	for result := range events {
+		s.mu.Lock()
+		if len(s.events) < maxSubscriptionEvents || result.Err != nil {
+			s.events = append(s.events, result)
+		} else {
+			s.dropped = true
+		}
+		s.mu.Unlock()
+		s.notify()
+	}
+
+	s.mu.Lock()
+	s.eventsDone = true
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *subscription) notify() {
+	select {
+	case s.eventsReady <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscription) run(ctx context.Context) {
+	if !s.replay(ctx, false) {
+		return
+	}
+
+	for {
+		s.mu.Lock()
+		events, dropped, done := s.events, s.dropped, s.eventsDone
+		s.events, s.dropped = nil, false
+		s.mu.Unlock()
+
+		var items []HistoryItem
+		var streamErr error
+		for _, result := range events {
+			if result.Err != nil {
+				streamErr = result.Err
+				break
+			}
+
+			for _, e := range result.Events {
+				event := e.GetTransactionEvent()
+				if event == nil {
+					continue
+				}
+
+				item, err := parseTransactionEvent(event)
+				if err != nil {
+					s.emit(ctx, SubscriptionResult{Err: errors.Wrap(err, "failed to parse transaction event")})
+					return
+				}
+				items = append(items, item)
+			}
+		}
+
+		if len(items) > 0 || dropped {
+			if !s.replay(ctx, true) {
+				return
+			}
+		}
+		for _, item := range items {
+			if !s.emitItem(ctx, item, true) {
+				return
+			}
+		}
+
+		if streamErr != nil {
+			s.emit(ctx, SubscriptionResult{Err: streamErr})
+			return
+		}
+		if done {
+			if ctx.Err() == nil {
+				s.emit(ctx, SubscriptionResult{Err: errors.New("event stream closed")})
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.eventsReady:
+		}
+	}
+}
+
+// replay emits the history after the cursor, advancing the cursor. It returns
+// false if the subscription was cancelled, or the history could not be read.
+func (s *subscription) replay(ctx context.Context, live bool) bool {
+	for {
+		items, err := s.client.internal.GetHistory(ctx, s.account, s.cursor, transactionpbv4.GetHistoryRequest_ASC)
+		if err != nil {
+			s.emit(ctx, SubscriptionResult{Err: err})
+			return false
+		}
+		if len(items) == 0 {
+			return true
+		}
+
+		for _, item := range items {
+			if !s.emitItem(ctx, item, live) {
+				return false
+			}
+		}
+		s.cursor = items[len(items)-1].Cursor
+	}
+}
+
+// emitItem emits item, unless it was recently emitted. It returns false if the
+// subscription was cancelled.
+func (s *subscription) emitItem(ctx context.Context, item HistoryItem, live bool) bool {
+	id := string(item.TxID)
+	if _, ok := s.seen[id]; ok {
+		return true
+	}
+
+	if len(s.order) < subscriptionDedupeWindow {
+		s.order = append(s.order, id)
+	} else {
+		delete(s.seen, s.order[s.next])
+		s.order[s.next] = id
+		s.next = (s.next + 1) % subscriptionDedupeWindow
+	}
+	s.seen[id] = struct{}{}
+
+	data, ok := FilterPayments(item.TransactionData, s.filters...)
+	if !ok {
+		return true
+	}
+	item.TransactionData = data
+
+	if !s.raw {
+		item.RawTransaction = nil
+	}
+	return s.emit(ctx, SubscriptionResult{Item: item, Live: live})
+}
+
+func (s *subscription) emit(ctx context.Context, result SubscriptionResult) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case s.results <- result:
+		return true
+	}
+}
+
+// parseTransactionEvent parses a transaction received from an event stream
+// into a HistoryItem without a cursor. Events do not contain invoices, so the
+// payments of the item have none.
+func parseTransactionEvent(event *accountpbv4.TransactionEvent) (HistoryItem, error) {
+	raw := event.GetTransaction().GetValue()
+
+	var tx solana.Transaction
+	if err := tx.Unmarshal(raw); err != nil {
+		return HistoryItem{}, errors.Wrap(err, "failed to unmarshal transaction")
+	}
+
+	historyItem := &transactionpbv4.HistoryItem{
+		TransactionId: &commonpbv4.TransactionId{Value: tx.Signature()},
+		RawTransaction: &transactionpbv4.HistoryItem_SolanaTransaction{
+			SolanaTransaction: &commonpbv4.Transaction{Value: raw},
+		},
+		TransactionError: event.GetTransactionError(),
+	}
+	for i := range tx.Message.Instructions {
+		transfer, err := token.DecompileTransfer(tx.Message, i)
+		if err != nil {
+			continue
+		}
+
+		historyItem.Payments = append(historyItem.Payments, &transactionpbv4.HistoryItem_Payment{
+			Source:      &commonpbv4.SolanaAccountId{Value: transfer.Source},
+			Destination: &commonpbv4.SolanaAccountId{Value: transfer.Destination},
+			Amount:      int64(transfer.Amount),
+			Index:       uint32(i),
+		})
+	}
+
+	data, err := parseHistoryItem(historyItem)
+	if err != nil {
+		return HistoryItem{}, err
+	}
+
+	data.TxID = tx.Signature()
+	data.TxState = TransactionStateSuccess
+	if event.GetTransactionError() != nil {
+		data.TxState = TransactionStateFailed
+	}
+
+	return HistoryItem{TransactionData: data}, nil
+}
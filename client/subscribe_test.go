@@ -0,0 +1,228 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+func TestClient_Subscribe(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	tokenKey, _, _ := setServiceConfigResp(t, env.v4Server, true)
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	require.NoError(t, env.client.CreateAccount(context.Background(), priv))
+	tokenAcc, err := token.GetAssociatedAccount(ed25519.PublicKey(priv.Public()), tokenKey)
+	require.NoError(t, err)
+	account := kin.PublicKey(tokenAcc)
+
+	var expected []TransactionData
+	var items []*transactionpbv4.HistoryItem
+	for i := 0; i < 4; i++ {
+		_, data, resp := generateV4SolanaPayments(t, false)
+		resp.Item.Cursor = &transactionpbv4.Cursor{Value: []byte{byte(i)}}
+
+		expected = append(expected, data)
+		items = append(items, resp.Item)
+	}
+
+	// The last two transactions are received as events, after the third was
+	// added to the history.
+	var events []*accountpbv4.Event
+	for _, item := range items[2:] {
+		events = append(events, &accountpbv4.Event{
+			Type: &accountpbv4.Event_TransactionEvent{
+				TransactionEvent: &accountpbv4.TransactionEvent{
+					Transaction: item.GetSolanaTransaction(),
+				},
+			},
+		})
+	}
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.History[string(account)] = items[:3]
+	env.v4Server.HistoryPageSize = 1
+	env.v4Server.EventsResponses = []*accountpbv4.Events{
+		{
+			Result: accountpbv4.Events_OK,
+			Events: []*accountpbv4.Event{
+				{
+					Type: &accountpbv4.Event_AccountUpdateEvent{
+						AccountUpdateEvent: &accountpbv4.AccountUpdateEvent{
+							AccountInfo: &accountpbv4.AccountInfo{
+								AccountId: &commonpbv4.SolanaAccountId{Value: tokenAcc},
+								Balance:   10,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Result: accountpbv4.Events_OK,
+			Events: events,
+		},
+	}
+	env.v4Server.Mux.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results, err := env.client.Subscribe(ctx, account, []byte{0})
+	require.NoError(t, err)
+
+	var received []SubscriptionResult
+	for r := range results {
+		received = append(received, r)
+	}
+
+	// The history after the cursor is replayed, followed by the events that
+	// were not in the history. The test server closes the stream after
+	// sending its events.
+	require.Len(t, received, 4)
+	for i, r := range received[:3] {
+		require.NoError(t, r.Err)
+		assert.Equal(t, expected[i+1].TxID, r.Item.TxID)
+		assert.Equal(t, i == 2, r.Live)
+		assert.Nil(t, r.Item.RawTransaction)
+	}
+	assert.Equal(t, Cursor{1}, received[0].Item.Cursor)
+	assert.Equal(t, Cursor{2}, received[1].Item.Cursor)
+	assert.Nil(t, received[2].Item.Cursor)
+	require.Len(t, received[2].Item.Payments, len(expected[3].Payments))
+	for i, p := range expected[3].Payments {
+		assert.EqualValues(t, p.Sender, received[2].Item.Payments[i].Sender)
+		assert.EqualValues(t, p.Destination, received[2].Item.Payments[i].Destination)
+		assert.Equal(t, p.Quarks, received[2].Item.Payments[i].Quarks)
+	}
+	assert.Equal(t, io.EOF, received[3].Err)
+}
+
+func TestClient_SubscribeLiveCursor(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	tokenKey, _, _ := setServiceConfigResp(t, env.v4Server, true)
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	require.NoError(t, env.client.CreateAccount(context.Background(), priv))
+	tokenAcc, err := token.GetAssociatedAccount(ed25519.PublicKey(priv.Public()), tokenKey)
+	require.NoError(t, err)
+	account := kin.PublicKey(tokenAcc)
+
+	var expected []TransactionData
+	var items []*transactionpbv4.HistoryItem
+	for i := 0; i < 3; i++ {
+		_, data, resp := generateV4SolanaPayments(t, false)
+		resp.Item.Cursor = &transactionpbv4.Cursor{Value: []byte{byte(i)}}
+
+		expected = append(expected, data)
+		items = append(items, resp.Item)
+	}
+
+	// The second transaction is received as an event after it was replayed,
+	// and the third is added to the history after the replay completes.
+	var events []*accountpbv4.Event
+	for _, item := range items[1:] {
+		events = append(events, &accountpbv4.Event{
+			Type: &accountpbv4.Event_TransactionEvent{
+				TransactionEvent: &accountpbv4.TransactionEvent{
+					Transaction: item.GetSolanaTransaction(),
+				},
+			},
+		})
+	}
+
+	var requests int
+	env.v4Server.Mux.Lock()
+	env.v4Server.History[string(account)] = items[:2]
+	env.v4Server.HistoryPageSize = 1
+	env.v4Server.HistoryHook = func(*transactionpbv4.GetHistoryRequest) {
+		// The replay reads both transactions, and then an empty page.
+		requests++
+		if requests == 4 {
+			env.v4Server.History[string(account)] = items
+		}
+	}
+	env.v4Server.EventsResponses = []*accountpbv4.Events{
+		{
+			Result: accountpbv4.Events_OK,
+			Events: events,
+		},
+	}
+	env.v4Server.Mux.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results, err := env.client.Subscribe(ctx, account, nil)
+	require.NoError(t, err)
+
+	var received []SubscriptionResult
+	for r := range results {
+		received = append(received, r)
+	}
+
+	require.Len(t, received, 4)
+	for i, r := range received[:3] {
+		require.NoError(t, r.Err)
+		assert.Equal(t, expected[i].TxID, r.Item.TxID)
+		assert.Equal(t, Cursor{byte(i)}, r.Item.Cursor)
+		assert.Equal(t, i == 2, r.Live)
+	}
+	assert.Equal(t, io.EOF, received[3].Err)
+}
+
+func TestSubscription_DedupeWindow(t *testing.T) {
+	s := &subscription{
+		seen:    make(map[string]struct{}),
+		results: make(chan SubscriptionResult, 2*subscriptionDedupeWindow),
+	}
+
+	ctx := context.Background()
+	for i := 0; i < subscriptionDedupeWindow+10; i++ {
+		require.True(t, s.emitItem(ctx, HistoryItem{TransactionData: TransactionData{TxID: []byte{byte(i), byte(i >> 8)}}}, true))
+	}
+	assert.Len(t, s.results, subscriptionDedupeWindow+10)
+	assert.Len(t, s.seen, subscriptionDedupeWindow)
+
+	// Recent transactions are not emitted again, but older ones are no
+	// longer remembered.
+	require.True(t, s.emitItem(ctx, HistoryItem{TransactionData: TransactionData{TxID: []byte{byte(20), 0}}}, true))
+	assert.Len(t, s.results, subscriptionDedupeWindow+10)
+	require.True(t, s.emitItem(ctx, HistoryItem{TransactionData: TransactionData{TxID: []byte{0, 0}}}, true))
+	assert.Len(t, s.results, subscriptionDedupeWindow+11)
+	assert.Len(t, s.seen, subscriptionDedupeWindow)
+}
+
+func TestClient_SubscribeNotFound(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	account, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	results, err := env.client.Subscribe(context.Background(), account.Public(), nil)
+	require.NoError(t, err)
+
+	r := <-results
+	assert.Equal(t, ErrAccountDoesNotExist, r.Err)
+
+	_, ok := <-results
+	assert.False(t, ok)
+}
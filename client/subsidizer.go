@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+)
+
+// subsidizerFailureWindow is the period over which subsidizer failures are counted.
+const subsidizerFailureWindow = time.Hour
+
+// SubsidizerStatus is the status of a subsidizer.
+type SubsidizerStatus struct {
+	Subsidizer kin.PublicKey
+
+	// Custom is set if the subsidizer was specified with WithSubsidizer, rather
+	// than being the service subsidizer.
+	Custom bool
+
+	// Lamports is the SOL balance of the subsidizer.
+	Lamports uint64
+
+	// RemainingTransactions is an estimate of the number of transactions the
+	// subsidizer can pay for, assuming each transaction has two signatures.
+	RemainingTransactions uint64
+
+	// RecentFailures is the number of transactions paid for by the subsidizer
+	// that failed to be submitted in the last hour.
+	RecentFailures int
+}
+
+func (c *client) GetSubsidizerStatus(ctx context.Context, opts ...SolanaOption) (SubsidizerStatus, error) {
+	solanaOpts := solanaOpts{commitment: c.opts.defaultCommitment}
+	for _, o := range opts {
+		o(&solanaOpts)
+	}
+
+	if c.rpc == nil {
+		return SubsidizerStatus{}, ErrNoSolanaRPC
+	}
+
+	var status SubsidizerStatus
+	if solanaOpts.subsidizer != nil {
+		status.Subsidizer = solanaOpts.subsidizer.Public()
+		status.Custom = true
+	} else {
+		config, err := c.internal.GetServiceConfig(ctx)
+		if err != nil {
+			return SubsidizerStatus{}, errors.Wrap(err, "failed to get service config")
+		}
+		if len(config.GetSubsidizerAccount().GetValue()) != ed25519.PublicKeySize {
+			return SubsidizerStatus{}, ErrNoSubsidizer
+		}
+		status.Subsidizer = config.SubsidizerAccount.Value
+	}
+
+	lamports, err := c.rpc.getBalance(ctx, status.Subsidizer, solanaOpts.commitment)
+	if err != nil {
+		return SubsidizerStatus{}, errors.Wrap(err, "failed to get subsidizer balance")
+	}
+
+	status.Lamports = lamports
+	status.RemainingTransactions = lamports / (2 * LamportsPerSignature)
+	status.RecentFailures = c.subsidizerFailures.count(status.Subsidizer)
+	return status, nil
+}
+
+func (c *client) WatchSubsidizer(ctx context.Context, threshold uint64, interval time.Duration, opts ...SolanaOption) (<-chan SubsidizerStatus, error) {
+	if interval <= 0 {
+		return nil, errors.Errorf("invalid interval: %v", interval)
+	}
+
+	// The initial status is fetched synchronously so that misconfiguration is
+	// reported to the caller.
+	status, err := c.GetSubsidizerStatus(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := make(chan SubsidizerStatus, 1)
+	go func() {
+		defer close(alerts)
+
+		var alerted bool
+		for {
+			if status.Lamports < threshold {
+				if !alerted {
+					select {
+					case alerts <- status:
+					case <-ctx.Done():
+						return
+					case <-c.lifecycle.ctx.Done():
+						return
+					}
+				}
+				alerted = true
+			} else {
+				alerted = false
+			}
+
+			select {
+			case <-c.opts.clock.After(interval):
+			case <-ctx.Done():
+				return
+			case <-c.lifecycle.ctx.Done():
+				return
+			}
+
+			if s, err := c.GetSubsidizerStatus(ctx, opts...); err == nil {
+				status = s
+			}
+		}
+	}()
+
+	return alerts, nil
+}
+
+// failureTracker tracks the recent failures of each subsidizer. The zero value
+// is ready to use, and uses the system clock.
+type failureTracker struct {
+	clock Clock
+
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+func (t *failureTracker) record(subsidizer []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.failures == nil {
+		t.failures = make(map[string][]time.Time)
+	}
+
+	now := clockOrSystem(t.clock).Now()
+	t.failures[string(subsidizer)] = append(t.prune(string(subsidizer), now), now)
+}
+
+func (t *failureTracker) count(subsidizer []byte) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.prune(string(subsidizer), clockOrSystem(t.clock).Now()))
+}
+
+// prune removes the failures that are outside the failure window. It must be
+// called with the lock held.
+func (t *failureTracker) prune(subsidizer string, now time.Time) []time.Time {
+	failures := t.failures[subsidizer]
+
+	var i int
+	for i < len(failures) && now.Sub(failures[i]) >= subsidizerFailureWindow {
+		i++
+	}
+
+	if i == len(failures) {
+		delete(t.failures, subsidizer)
+		return nil
+	}
+
+	failures = failures[i:]
+	t.failures[subsidizer] = failures
+	return failures
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+)
+
+// subsidizerBalanceTTL is how long the balances used by
+// SubsidizerStrategyBalanceWeighted are cached for.
+const subsidizerBalanceTTL = time.Minute
+
+// SubsidizerStrategy is the strategy used to select a subsidizer from a pool
+// configured with WithSubsidizerPool.
+type SubsidizerStrategy int
+
+const (
+	// SubsidizerStrategyRoundRobin selects each subsidizer in turn.
+	SubsidizerStrategyRoundRobin SubsidizerStrategy = iota
+
+	// SubsidizerStrategyLeastRecentlyUsed selects the subsidizer that was
+	// selected the longest time ago.
+	SubsidizerStrategyLeastRecentlyUsed
+
+	// SubsidizerStrategyBalanceWeighted selects a subsidizer at random, weighted
+	// by its SOL balance. It requires WithSolanaRPC.
+	SubsidizerStrategyBalanceWeighted
+)
+
+// WithSubsidizerPool specifies a pool of subsidizers the client rotates across,
+// selecting one for each call according to strategy. A subsidizer specified
+// with WithSubsidizer takes precedence over the pool.
+//
+// For SubmitEarnBatches and SubmitEarnBatchAsync, a subsidizer is selected for
+// each chunk.
+func WithSubsidizerPool(subsidizers []kin.PrivateKey, strategy SubsidizerStrategy) ClientOption {
+	return func(o *clientOpts) {
+		o.subsidizerPool = make([]kin.PrivateKey, len(subsidizers))
+		copy(o.subsidizerPool, subsidizers)
+		o.subsidizerStrategy = strategy
+	}
+}
+
+type subsidizerPool struct {
+	subsidizers []kin.PrivateKey
+	strategy    SubsidizerStrategy
+	rpc         *solanaRPC
+	clock       Clock
+
+	mu              sync.Mutex
+	next            int
+	lastUsed        []time.Time
+	balances        []uint64
+	balancesFetched time.Time
+	rand            *rand.Rand
+}
+
+func newSubsidizerPool(subsidizers []kin.PrivateKey, strategy SubsidizerStrategy, rpc *solanaRPC, clock Clock) (*subsidizerPool, error) {
+	if len(subsidizers) == 0 {
+		return nil, errors.New("subsidizer pool must contain at least 1 subsidizer")
+	}
+
+	switch strategy {
+	case SubsidizerStrategyRoundRobin, SubsidizerStrategyLeastRecentlyUsed:
+	case SubsidizerStrategyBalanceWeighted:
+		if rpc == nil {
+			return nil, errors.New("SubsidizerStrategyBalanceWeighted requires WithSolanaRPC")
+		}
+	default:
+		return nil, errors.Errorf("unknown subsidizer strategy: %d", strategy)
+	}
+
+	return &subsidizerPool{
+		subsidizers: subsidizers,
+		strategy:    strategy,
+		rpc:         rpc,
+		clock:       clockOrSystem(clock),
+		lastUsed:    make([]time.Time, len(subsidizers)),
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// selectSubsidizer selects a subsidizer from the pool, if one is configured and
+// no subsidizer was explicitly specified.
+func (c *client) selectSubsidizer(ctx context.Context, o *solanaOpts) error {
+	if o.subsidizer != nil || c.subsidizers == nil {
+		return nil
+	}
+
+	subsidizer, err := c.subsidizers.get(ctx, o)
+	if err != nil {
+		return errors.Wrap(err, "failed to select subsidizer")
+	}
+
+	o.subsidizer = subsidizer
+	return nil
+}
+
+func (p *subsidizerPool) get(ctx context.Context, o *solanaOpts) (kin.PrivateKey, error) {
+	if p.strategy == SubsidizerStrategyBalanceWeighted {
+		if err := p.refreshBalances(ctx, o); err != nil {
+			// Stale balances are preferable to failing the call.
+			p.mu.Lock()
+			stale := p.balances != nil
+			p.mu.Unlock()
+
+			if !stale {
+				return nil, err
+			}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.next
+	switch p.strategy {
+	case SubsidizerStrategyLeastRecentlyUsed:
+		for j := range p.lastUsed {
+			if p.lastUsed[j].Before(p.lastUsed[i]) {
+				i = j
+			}
+		}
+	case SubsidizerStrategyBalanceWeighted:
+		var total uint64
+		for _, b := range p.balances {
+			total += b
+		}
+
+		// If none of the subsidizers have a balance, fall back to round robin.
+		if total > 0 {
+			r := uint64(p.rand.Int63n(int64(total)))
+			for j, b := range p.balances {
+				if r < b {
+					i = j
+					break
+				}
+				r -= b
+			}
+		}
+	}
+
+	p.next = (i + 1) % len(p.subsidizers)
+	p.lastUsed[i] = p.clock.Now()
+	return p.subsidizers[i], nil
+}
+
+func (p *subsidizerPool) refreshBalances(ctx context.Context, o *solanaOpts) error {
+	p.mu.Lock()
+	fresh := p.balances != nil && p.clock.Now().Sub(p.balancesFetched) < subsidizerBalanceTTL
+	p.mu.Unlock()
+
+	if fresh {
+		return nil
+	}
+
+	balances := make([]uint64, len(p.subsidizers))
+	for i, s := range p.subsidizers {
+		balance, err := p.rpc.getBalance(ctx, s.Public(), o.commitment)
+		if err != nil {
+			return errors.Wrap(err, "failed to get subsidizer balance")
+		}
+		balances[i] = balance
+	}
+
+	p.mu.Lock()
+	p.balances = balances
+	p.balancesFetched = p.clock.Now()
+	p.mu.Unlock()
+
+	return nil
+}
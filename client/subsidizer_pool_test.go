@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateSubsidizers(t *testing.T, n int) []kin.PrivateKey {
+	subsidizers := make([]kin.PrivateKey, n)
+	for i := range subsidizers {
+		var err error
+		subsidizers[i], err = kin.NewPrivateKey()
+		require.NoError(t, err)
+	}
+	return subsidizers
+}
+
+func TestClient_SubsidizerPool(t *testing.T) {
+	subsidizers := generateSubsidizers(t, 3)
+
+	for _, opts := range [][]ClientOption{
+		{WithSubsidizerPool(nil, SubsidizerStrategyRoundRobin)},
+		{WithSubsidizerPool(subsidizers, SubsidizerStrategyBalanceWeighted)},
+		{WithSubsidizerPool(subsidizers, SubsidizerStrategy(10))},
+	} {
+		_, err := New(EnvironmentTest, opts...)
+		assert.Error(t, err)
+	}
+
+	env, cleanup := setup(t, WithSubsidizerPool(subsidizers, SubsidizerStrategyRoundRobin))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	override, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	expected := []kin.PublicKey{
+		subsidizers[0].Public(),
+		subsidizers[1].Public(),
+		override.Public(),
+		subsidizers[2].Public(),
+		subsidizers[0].Public(),
+	}
+	for i := range expected {
+		key, err := kin.NewPrivateKey()
+		require.NoError(t, err)
+
+		var opts []SolanaOption
+		if i == 2 {
+			opts = append(opts, WithSubsidizer(override))
+		}
+		require.NoError(t, env.client.CreateAccount(context.Background(), key, opts...))
+	}
+
+	require.Len(t, env.v4Server.Creates, len(expected))
+	for i, create := range env.v4Server.Creates {
+		var tx solana.Transaction
+		require.NoError(t, tx.Unmarshal(create.Transaction.Value))
+		assert.EqualValues(t, expected[i], tx.Message.Accounts[0], "create %d", i)
+	}
+}
+
+func TestSubsidizerPool_LeastRecentlyUsed(t *testing.T) {
+	subsidizers := generateSubsidizers(t, 3)
+
+	p, err := newSubsidizerPool(subsidizers, SubsidizerStrategyLeastRecentlyUsed, nil, nil)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		s, err := p.get(context.Background(), &solanaOpts{})
+		require.NoError(t, err)
+		assert.Equal(t, subsidizers[i], s)
+	}
+
+	p.lastUsed[1] = time.Now().Add(-time.Hour)
+	s, err := p.get(context.Background(), &solanaOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, subsidizers[1], s)
+
+	s, err = p.get(context.Background(), &solanaOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, subsidizers[0], s)
+}
+
+func TestSubsidizerPool_BalanceWeighted(t *testing.T) {
+	rpc := newRPCServer(t)
+	defer rpc.Close()
+
+	subsidizers := generateSubsidizers(t, 3)
+
+	p, err := newSubsidizerPool(subsidizers, SubsidizerStrategyBalanceWeighted, newSolanaRPC(rpc.URL), nil)
+	require.NoError(t, err)
+
+	// Balances are required for the first selection.
+	_, err = p.get(context.Background(), &solanaOpts{})
+	assert.Error(t, err)
+
+	rpc.setBalance(subsidizers[0].Public(), 0)
+	rpc.setBalance(subsidizers[1].Public(), 100)
+	rpc.setBalance(subsidizers[2].Public(), 0)
+
+	for i := 0; i < 10; i++ {
+		s, err := p.get(context.Background(), &solanaOpts{})
+		require.NoError(t, err)
+		assert.Equal(t, subsidizers[1], s)
+	}
+
+	rpc.mu.Lock()
+	assert.Len(t, rpc.requests, 1+len(subsidizers))
+	rpc.mu.Unlock()
+
+	// If none of the subsidizers have a balance, they are selected in turn.
+	rpc.setBalance(subsidizers[1].Public(), 0)
+	p.balancesFetched = time.Time{}
+	for i := 0; i < 3; i++ {
+		s, err := p.get(context.Background(), &solanaOpts{})
+		require.NoError(t, err)
+		assert.Equal(t, subsidizers[(i+2)%3], s)
+	}
+
+	// Stale balances are used if they cannot be refreshed.
+	rpc.mu.Lock()
+	delete(rpc.balances, subsidizers[0].Public().Base58())
+	rpc.mu.Unlock()
+	p.balancesFetched = time.Time{}
+	_, err = p.get(context.Background(), &solanaOpts{})
+	assert.NoError(t, err)
+}
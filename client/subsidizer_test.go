@@ -0,0 +1,210 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+type rpcServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	balances map[string]uint64
+	requests []rpcRequest
+}
+
+func newRPCServer(t *testing.T) *rpcServer {
+	s := &rpcServer{balances: make(map[string]uint64)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, req)
+
+		switch req.Method {
+		case "sendTransaction":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": "sig"})
+			return
+		case "getSignatureStatuses":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"result": map[string]interface{}{
+					"value": []interface{}{map[string]interface{}{"err": nil, "confirmationStatus": "finalized"}},
+				},
+			})
+			return
+		}
+
+		account := req.Params[0].(string)
+		balance, ok := s.balances[account]
+		if !ok {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]interface{}{"code": -32602, "message": "invalid account"},
+			})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"result": map[string]interface{}{"value": balance},
+		})
+	}))
+	return s
+}
+
+func (s *rpcServer) setBalance(account kin.PublicKey, lamports uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances[account.Base58()] = lamports
+}
+
+func TestClient_GetSubsidizerStatus(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	_, err := env.client.GetSubsidizerStatus(context.Background())
+	assert.Equal(t, ErrNoSolanaRPC, err)
+
+	rpc := newRPCServer(t)
+	defer rpc.Close()
+
+	env, cleanup = setup(t, WithSolanaRPC(rpc.URL))
+	defer cleanup()
+
+	_, _, subsidizer := setServiceConfigResp(t, env.v4Server, true)
+
+	_, err = env.client.GetSubsidizerStatus(context.Background())
+	assert.Error(t, err)
+
+	rpc.setBalance(kin.PublicKey(subsidizer), 1000*LamportsPerSignature+1)
+	status, err := env.client.GetSubsidizerStatus(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, subsidizer, status.Subsidizer)
+	assert.False(t, status.Custom)
+	assert.EqualValues(t, 1000*LamportsPerSignature+1, status.Lamports)
+	assert.EqualValues(t, 500, status.RemainingTransactions)
+	assert.Zero(t, status.RecentFailures)
+
+	rpc.mu.Lock()
+	req := rpc.requests[len(rpc.requests)-1]
+	rpc.mu.Unlock()
+	assert.Equal(t, "getBalance", req.Method)
+	assert.Equal(t, map[string]interface{}{"commitment": "confirmed"}, req.Params[1])
+
+	// Failed submissions are attributed to the subsidizer that paid for them.
+	custom, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	rpc.setBalance(custom.Public(), 10)
+
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.SubmitResponses = []*transactionpbv4.SubmitTransactionResponse{
+		{
+			Result: transactionpbv4.SubmitTransactionResponse_FAILED,
+			TransactionError: &commonpbv4.TransactionError{
+				Reason: commonpbv4.TransactionError_UNAUTHORIZED,
+				Raw:    []byte("rawerror"),
+			},
+		},
+	}
+	env.v4Server.Mux.Unlock()
+
+	results, err := env.client.CreateAccounts(context.Background(), []kin.PrivateKey{key}, WithSubsidizer(custom))
+	require.NoError(t, err)
+	assert.Error(t, results[0].TxError)
+
+	status, err = env.client.GetSubsidizerStatus(context.Background(), WithSubsidizer(custom), WithCommitment(commonpbv4.Commitment_MAX))
+	require.NoError(t, err)
+	assert.Equal(t, custom.Public(), status.Subsidizer)
+	assert.True(t, status.Custom)
+	assert.EqualValues(t, 10, status.Lamports)
+	assert.Zero(t, status.RemainingTransactions)
+	assert.Equal(t, 1, status.RecentFailures)
+
+	status, err = env.client.GetSubsidizerStatus(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, status.RecentFailures)
+}
+
+func TestClient_WatchSubsidizer(t *testing.T) {
+	rpc := newRPCServer(t)
+	defer rpc.Close()
+
+	env, cleanup := setup(t, WithSolanaRPC(rpc.URL))
+	defer cleanup()
+
+	_, _, subsidizer := setServiceConfigResp(t, env.v4Server, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := env.client.WatchSubsidizer(ctx, 100, time.Millisecond)
+	assert.Error(t, err)
+
+	rpc.setBalance(kin.PublicKey(subsidizer), 50)
+	alerts, err := env.client.WatchSubsidizer(ctx, 100, time.Millisecond)
+	require.NoError(t, err)
+
+	select {
+	case status := <-alerts:
+		assert.EqualValues(t, 50, status.Lamports)
+	case <-time.After(time.Second):
+		t.Fatal("no alert received")
+	}
+
+	// No further alerts are sent until the balance recovers.
+	select {
+	case <-alerts:
+		t.Fatal("unexpected alert")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	rpc.setBalance(kin.PublicKey(subsidizer), 1000)
+	time.Sleep(20 * time.Millisecond)
+	rpc.setBalance(kin.PublicKey(subsidizer), 20)
+
+	select {
+	case status := <-alerts:
+		assert.EqualValues(t, 20, status.Lamports)
+	case <-time.After(time.Second):
+		t.Fatal("no alert received")
+	}
+
+	cancel()
+	for range alerts {
+	}
+}
+
+func TestFailureTracker(t *testing.T) {
+	var tracker failureTracker
+	assert.Zero(t, tracker.count([]byte("a")))
+
+	tracker.record([]byte("a"))
+	tracker.record([]byte("a"))
+	tracker.record([]byte("b"))
+	assert.Equal(t, 2, tracker.count([]byte("a")))
+	assert.Equal(t, 1, tracker.count([]byte("b")))
+
+	tracker.mu.Lock()
+	tracker.failures["a"][0] = time.Now().Add(-subsidizerFailureWindow)
+	tracker.failures["b"][0] = time.Now().Add(-subsidizerFailureWindow)
+	tracker.mu.Unlock()
+
+	assert.Equal(t, 1, tracker.count([]byte("a")))
+	assert.Zero(t, tracker.count([]byte("b")))
+	assert.NotContains(t, tracker.failures, "b")
+}
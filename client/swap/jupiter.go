@@ -0,0 +1,272 @@
+package swap
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client/versioned"
+)
+
+const (
+	defaultJupiterURL = "https://quote-api.jup.ag/v6"
+	defaultSolanaRPC  = "https://api.mainnet-beta.solana.com"
+
+	jupiterNoRouteCode = "COULD_NOT_FIND_ANY_ROUTE"
+)
+
+type jupiterOpts struct {
+	baseURL             string
+	rpcEndpoint         string
+	httpClient          *http.Client
+	confirmationTimeout time.Duration
+	pollInterval        time.Duration
+}
+
+// JupiterOption configures a Jupiter Swapper.
+type JupiterOption func(*jupiterOpts)
+
+// WithJupiterURL specifies the base URL of the Jupiter swap API.
+func WithJupiterURL(baseURL string) JupiterOption {
+	return func(o *jupiterOpts) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithSolanaRPC specifies the Solana JSON RPC endpoint that swap transactions
+// are submitted to. Defaults to the public mainnet endpoint.
+func WithSolanaRPC(endpoint string) JupiterOption {
+	return func(o *jupiterOpts) {
+		o.rpcEndpoint = endpoint
+	}
+}
+
+// WithHTTPClient specifies the http.Client used to query Jupiter and Solana.
+func WithHTTPClient(client *http.Client) JupiterOption {
+	return func(o *jupiterOpts) {
+		o.httpClient = client
+	}
+}
+
+// WithConfirmationTimeout specifies how long to wait for a swap transaction to
+// be confirmed. Defaults to 1 minute.
+func WithConfirmationTimeout(timeout time.Duration) JupiterOption {
+	return func(o *jupiterOpts) {
+		o.confirmationTimeout = timeout
+	}
+}
+
+// WithPollInterval specifies how often the status of a swap transaction is
+// checked while waiting for it to be confirmed. Defaults to 1 second.
+func WithPollInterval(interval time.Duration) JupiterOption {
+	return func(o *jupiterOpts) {
+		o.pollInterval = interval
+	}
+}
+
+type jupiter struct {
+	opts jupiterOpts
+}
+
+// NewJupiterSwapper returns a Swapper backed by the Jupiter swap API.
+//
+// Swap transactions are paid for by the owner of the swapped funds, who must
+// therefore hold enough SOL for the transaction fee (and for the creation of
+// any missing token accounts). SOL is swapped as wrapped SOL, so that it can be
+// paid out like any other token.
+//
+// It is provided as a reference implementation. Production deployments may wish
+// to use a provider with an SLA.
+func NewJupiterSwapper(opts ...JupiterOption) Swapper {
+	o := jupiterOpts{
+		baseURL:             defaultJupiterURL,
+		rpcEndpoint:         defaultSolanaRPC,
+		httpClient:          &http.Client{Timeout: 10 * time.Second},
+		confirmationTimeout: time.Minute,
+		pollInterval:        time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &jupiter{opts: o}
+}
+
+type jupiterQuote struct {
+	InputMint            string `json:"inputMint"`
+	InAmount             string `json:"inAmount"`
+	OutputMint           string `json:"outputMint"`
+	OutAmount            string `json:"outAmount"`
+	OtherAmountThreshold string `json:"otherAmountThreshold"`
+	SwapMode             string `json:"swapMode"`
+	PriceImpactPct       string `json:"priceImpactPct"`
+}
+
+type jupiterError struct {
+	Error     string `json:"error"`
+	ErrorCode string `json:"errorCode"`
+}
+
+// Quote implements Swapper.Quote.
+func (j *jupiter) Quote(ctx context.Context, req QuoteRequest) (q Quote, err error) {
+	query := url.Values{}
+	query.Set("inputMint", req.InputMint.Base58())
+	query.Set("outputMint", req.OutputMint.Base58())
+	query.Set("amount", strconv.FormatUint(req.Amount, 10))
+	query.Set("slippageBps", strconv.Itoa(req.SlippageBps))
+	if req.Mode == ModeExactOut {
+		query.Set("swapMode", "ExactOut")
+	} else {
+		query.Set("swapMode", "ExactIn")
+	}
+
+	httpReq, err := http.NewRequest(http.MethodGet, j.opts.baseURL+"/quote?"+query.Encode(), nil)
+	if err != nil {
+		return q, errors.Wrap(err, "failed to create request")
+	}
+
+	var raw json.RawMessage
+	if err := j.do(ctx, httpReq, &raw); err != nil {
+		return q, err
+	}
+
+	var resp jupiterQuote
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return q, errors.Wrap(err, "failed to decode jupiter quote")
+	}
+
+	q = Quote{
+		InputMint:  req.InputMint,
+		OutputMint: req.OutputMint,
+		Mode:       req.Mode,
+		Raw:        raw,
+	}
+	if resp.InputMint != req.InputMint.Base58() || resp.OutputMint != req.OutputMint.Base58() {
+		return q, errors.Wrap(ErrInvalidQuote, "mismatched mints")
+	}
+	for _, field := range []struct {
+		value string
+		dest  *uint64
+	}{
+		{resp.InAmount, &q.InAmount},
+		{resp.OutAmount, &q.OutAmount},
+		{resp.OtherAmountThreshold, &q.Threshold},
+	} {
+		if *field.dest, err = strconv.ParseUint(field.value, 10, 64); err != nil {
+			return q, errors.Wrapf(ErrInvalidQuote, "invalid amount: %q", field.value)
+		}
+	}
+	if resp.PriceImpactPct != "" {
+		if q.PriceImpactPct, err = strconv.ParseFloat(resp.PriceImpactPct, 64); err != nil {
+			return q, errors.Wrapf(ErrInvalidQuote, "invalid price impact: %q", resp.PriceImpactPct)
+		}
+	}
+
+	return q, nil
+}
+
+// Swap implements Swapper.Swap.
+func (j *jupiter) Swap(ctx context.Context, owner kin.PrivateKey, quote Quote) ([]byte, error) {
+	if len(quote.Raw) == 0 {
+		return nil, errors.Wrap(ErrInvalidQuote, "missing raw quote")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"quoteResponse":    json.RawMessage(quote.Raw),
+		"userPublicKey":    owner.Public().Base58(),
+		"wrapAndUnwrapSol": false,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode swap request")
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, j.opts.baseURL+"/swap", bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	var resp struct {
+		SwapTransaction string `json:"swapTransaction"`
+	}
+	if err := j.do(ctx, httpReq, &resp); err != nil {
+		return nil, err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(resp.SwapTransaction)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode swap transaction")
+	}
+
+	txID, raw, err := sign(raw, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	rpc := &solanaRPC{endpoint: j.opts.rpcEndpoint, httpClient: j.opts.httpClient}
+	if err := rpc.sendTransaction(ctx, raw); err != nil {
+		return txID, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, j.opts.confirmationTimeout)
+	defer cancel()
+
+	return txID, rpc.awaitConfirmation(ctx, txID, j.opts.pollInterval)
+}
+
+// sign signs the serialized legacy or versioned transaction raw with owner,
+// returning its signature and the signed transaction.
+func sign(raw []byte, owner kin.PrivateKey) (txID, signed []byte, err error) {
+	if versioned.IsVersioned(raw) {
+		var tx versioned.Transaction
+		if err := tx.Unmarshal(raw); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to unmarshal swap transaction")
+		}
+		if err := tx.Sign(ed25519.PrivateKey(owner)); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to sign swap transaction")
+		}
+		return tx.Signature(), tx.Marshal(), nil
+	}
+
+	var tx solana.Transaction
+	if err := tx.Unmarshal(raw); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to unmarshal swap transaction")
+	}
+	if err := tx.Sign(ed25519.PrivateKey(owner)); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to sign swap transaction")
+	}
+	return tx.Signature(), tx.Marshal(), nil
+}
+
+func (j *jupiter) do(ctx context.Context, req *http.Request, result interface{}) error {
+	resp, err := j.opts.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "failed to query jupiter")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		var jErr jupiterError
+		_ = json.NewDecoder(resp.Body).Decode(&jErr)
+		if jErr.ErrorCode == jupiterNoRouteCode {
+			return ErrNoRoute
+		}
+		return errors.Errorf("unexpected status from jupiter: %d (%s)", resp.StatusCode, jErr.Error)
+	}
+
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(result), "failed to decode jupiter response")
+}
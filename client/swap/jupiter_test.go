@@ -0,0 +1,137 @@
+package swap
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client/versioned"
+)
+
+func TestJupiterSwapper(t *testing.T) {
+	owner, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	quote := `{"inputMint":"` + USDCMint.Base58() + `","inAmount":"25","outputMint":"` + KinMint.Base58() +
+		`","outAmount":"100000","otherAmountThreshold":"26","swapMode":"ExactOut","priceImpactPct":"0.01"}`
+
+	tx, err := versioned.NewTransaction(ed25519.PublicKey(owner.Public()), []solana.Instruction{memo.Instruction("swap")})
+	require.NoError(t, err)
+
+	var sent versioned.Transaction
+	var statusCalls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/quote":
+			assert.Equal(t, USDCMint.Base58(), r.URL.Query().Get("inputMint"))
+			assert.Equal(t, KinMint.Base58(), r.URL.Query().Get("outputMint"))
+			assert.Equal(t, "ExactOut", r.URL.Query().Get("swapMode"))
+			assert.Equal(t, "50", r.URL.Query().Get("slippageBps"))
+
+			switch r.URL.Query().Get("amount") {
+			case "100000":
+				_, _ = w.Write([]byte(quote))
+			case "1":
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"no route","errorCode":"COULD_NOT_FIND_ANY_ROUTE"}`))
+			default:
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+		case "/swap":
+			var req struct {
+				QuoteResponse json.RawMessage `json:"quoteResponse"`
+				UserPublicKey string          `json:"userPublicKey"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.JSONEq(t, quote, string(req.QuoteResponse))
+			assert.Equal(t, owner.Public().Base58(), req.UserPublicKey)
+
+			_, _ = w.Write([]byte(`{"swapTransaction":"` + base64.StdEncoding.EncodeToString(tx.Marshal()) + `"}`))
+		case "/rpc":
+			var req rpcRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+			switch req.Method {
+			case "sendTransaction":
+				raw, err := base64.StdEncoding.DecodeString(req.Params[0].(string))
+				require.NoError(t, err)
+				require.NoError(t, sent.Unmarshal(raw))
+				_, _ = w.Write([]byte(`{"result":"` + base58.Encode(sent.Signature()) + `"}`))
+			case "getSignatureStatuses":
+				statusCalls++
+				if statusCalls == 1 {
+					_, _ = w.Write([]byte(`{"result":{"value":[null]}}`))
+				} else {
+					_, _ = w.Write([]byte(`{"result":{"value":[{"err":null,"confirmationStatus":"confirmed"}]}}`))
+				}
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	s := NewJupiterSwapper(
+		WithJupiterURL(server.URL),
+		WithSolanaRPC(server.URL+"/rpc"),
+		WithHTTPClient(server.Client()),
+		WithPollInterval(time.Millisecond),
+	)
+
+	q, err := s.Quote(context.Background(), QuoteRequest{
+		InputMint:   USDCMint,
+		OutputMint:  KinMint,
+		Amount:      100000,
+		Mode:        ModeExactOut,
+		SlippageBps: 50,
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 25, q.InAmount)
+	assert.EqualValues(t, 100000, q.OutAmount)
+	assert.EqualValues(t, 26, q.Threshold)
+	assert.EqualValues(t, 0.01, q.PriceImpactPct)
+
+	txID, err := s.Swap(context.Background(), owner, q)
+	require.NoError(t, err)
+	assert.Equal(t, sent.Signature(), txID)
+	assert.True(t, ed25519.Verify(ed25519.PublicKey(owner.Public()), sent.Message.Marshal(), txID))
+	assert.Equal(t, 2, statusCalls)
+
+	for amount, expected := range map[uint64]error{1: ErrNoRoute, 2: ErrRateLimited} {
+		_, err = s.Quote(context.Background(), QuoteRequest{
+			InputMint:   USDCMint,
+			OutputMint:  KinMint,
+			Amount:      amount,
+			Mode:        ModeExactOut,
+			SlippageBps: 50,
+		})
+		assert.Equal(t, expected, errors.Cause(err))
+	}
+
+	_, err = s.Swap(context.Background(), owner, Quote{})
+	assert.Equal(t, ErrInvalidQuote, errors.Cause(err))
+}
+
+func TestSolanaRPC_AwaitConfirmationFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"result":{"value":[{"err":{"InstructionError":[0,"Custom"]},"confirmationStatus":"confirmed"}]}}`))
+	}))
+	defer server.Close()
+
+	rpc := &solanaRPC{endpoint: server.URL, httpClient: server.Client()}
+	err := rpc.awaitConfirmation(context.Background(), make([]byte, 64), time.Millisecond)
+	assert.Equal(t, ErrSwapFailed, errors.Cause(err))
+}
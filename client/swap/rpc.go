@@ -0,0 +1,117 @@
+package swap
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+)
+
+// solanaRPC is a minimal Solana JSON RPC client for submitting swaps.
+type solanaRPC struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+func (r *solanaRPC) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode rpc request")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create rpc request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s", method)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status from solana rpc: %d", resp.StatusCode)
+	}
+
+	var rpcResp rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return errors.Wrap(err, "failed to decode rpc response")
+	}
+	if rpcResp.Error != nil {
+		return errors.Wrapf(rpcResp.Error, "failed to call %s", method)
+	}
+
+	return errors.Wrap(json.Unmarshal(rpcResp.Result, result), "failed to decode rpc result")
+}
+
+func (r *solanaRPC) sendTransaction(ctx context.Context, raw []byte) error {
+	var sig string
+	return r.call(ctx, "sendTransaction", &sig, base64.StdEncoding.EncodeToString(raw), map[string]string{
+		"encoding": "base64",
+	})
+}
+
+// awaitConfirmation polls the status of the transaction identified by txID
+// until it is confirmed, it fails, or ctx is done.
+func (r *solanaRPC) awaitConfirmation(ctx context.Context, txID []byte, interval time.Duration) error {
+	for {
+		var result struct {
+			Value []*struct {
+				Err                json.RawMessage `json:"err"`
+				ConfirmationStatus string          `json:"confirmationStatus"`
+			} `json:"value"`
+		}
+		if err := r.call(ctx, "getSignatureStatuses", &result, []string{base58.Encode(txID)}); err != nil {
+			return err
+		}
+
+		if len(result.Value) > 0 && result.Value[0] != nil {
+			status := result.Value[0]
+			if len(status.Err) > 0 && string(status.Err) != "null" {
+				return errors.Wrap(ErrSwapFailed, string(status.Err))
+			}
+			if status.ConfirmationStatus == "confirmed" || status.ConfirmationStatus == "finalized" {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "swap transaction not confirmed")
+		case <-time.After(interval):
+		}
+	}
+}
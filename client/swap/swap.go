@@ -0,0 +1,176 @@
+// Package swap provides conversions between Kin and other tokens through
+// decentralized exchanges, such as Kin to USDC or SOL, and payouts of the
+// converted funds.
+package swap
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+var (
+	// KinMint is the mint of Kin on Solana mainnet.
+	KinMint = mustPublicKey("kinXdEcpDQeHPEuQnqmUgtYykqKGVFq6CeVX5iAHJq6")
+
+	// USDCMint is the mint of USDC on Solana mainnet.
+	USDCMint = mustPublicKey("EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v")
+
+	// SOLMint is the mint of wrapped SOL.
+	SOLMint = mustPublicKey("So11111111111111111111111111111111111111112")
+)
+
+var (
+	ErrNoRoute      = errors.New("no swap route found")
+	ErrRateLimited  = errors.New("swap provider rate limited")
+	ErrSwapFailed   = errors.New("swap transaction failed")
+	ErrInvalidQuote = errors.New("invalid quote")
+)
+
+// Mode specifies which side of a swap has a fixed amount.
+type Mode int
+
+const (
+	// ModeExactIn swaps an exact amount of the input token for at least the
+	// quoted amount of the output token, minus slippage.
+	ModeExactIn Mode = iota
+
+	// ModeExactOut swaps at most the quoted amount of the input token, plus
+	// slippage, for an exact amount of the output token.
+	ModeExactOut
+)
+
+// QuoteRequest is a request for a quote to swap between two tokens.
+type QuoteRequest struct {
+	InputMint  kin.PublicKey
+	OutputMint kin.PublicKey
+
+	// Amount is the amount of the input token if Mode is ModeExactIn, or the
+	// amount of the output token if Mode is ModeExactOut, in the smallest unit
+	// of the token (quarks for Kin).
+	Amount uint64
+	Mode   Mode
+
+	// SlippageBps is the tolerated slippage, in basis points.
+	SlippageBps int
+}
+
+// Quote is a quote to swap between two tokens.
+type Quote struct {
+	InputMint  kin.PublicKey
+	OutputMint kin.PublicKey
+	InAmount   uint64
+	OutAmount  uint64
+	Mode       Mode
+
+	// Threshold is the minimum amount of the output token received if Mode is
+	// ModeExactIn, or the maximum amount of the input token spent if Mode is
+	// ModeExactOut, once slippage is accounted for.
+	Threshold uint64
+
+	// PriceImpactPct is the estimated impact of the swap on the price, in
+	// percent.
+	PriceImpactPct float64
+
+	// Raw is the provider specific representation of the quote, which is used
+	// to execute it.
+	Raw []byte
+}
+
+// Swapper quotes and executes swaps between tokens.
+type Swapper interface {
+	// Quote returns a quote for the swap described by req.
+	Quote(ctx context.Context, req QuoteRequest) (Quote, error)
+
+	// Swap executes quote on behalf of owner, whose token accounts are the
+	// source and destination of the swap. It returns once the swap transaction
+	// has been confirmed.
+	Swap(ctx context.Context, owner kin.PrivateKey, quote Quote) (txID []byte, err error)
+}
+
+// Payout is a payment of a token other than the one held by the sender.
+type Payout struct {
+	// Payment is submitted once the swap has completed. The swap is quoted for
+	// exactly Payment.Quarks of Payment.Mint (Kin, if unset), which must be
+	// quoted in the smallest unit of the mint.
+	client.Payment
+
+	// InputMint is the mint of the token held by the sender, which is swapped
+	// for the token being paid.
+	InputMint kin.PublicKey
+
+	// SlippageBps is the tolerated slippage of the swap, in basis points.
+	SlippageBps int
+}
+
+// PayoutResult is the result of QuoteAndPay.
+type PayoutResult struct {
+	Quote       Quote
+	SwapTxID    []byte
+	PaymentTxID []byte
+}
+
+// QuoteAndPay swaps the funds of the sender of p into the token being paid,
+// and then pays the destination of p using c.
+//
+// If the swap succeeded but the payment failed, the result contains the
+// transaction ID of the swap, since the sender now holds the output token.
+// Retrying such a payout would swap the funds again; the payment should be
+// retried with c directly instead.
+func QuoteAndPay(ctx context.Context, c client.Client, s Swapper, p Payout, opts ...client.SolanaOption) (result PayoutResult, err error) {
+	if p.Quarks <= 0 {
+		return result, errors.Errorf("invalid payout amount: %d", p.Quarks)
+	}
+
+	outputMint := p.Mint
+	if len(outputMint) == 0 {
+		outputMint = KinMint
+	}
+	if bytes.Equal(p.InputMint, outputMint) {
+		return result, errors.New("input and output mints must differ")
+	}
+
+	result.Quote, err = s.Quote(ctx, QuoteRequest{
+		InputMint:   p.InputMint,
+		OutputMint:  outputMint,
+		Amount:      uint64(p.Quarks),
+		Mode:        ModeExactOut,
+		SlippageBps: p.SlippageBps,
+	})
+	if err != nil {
+		return result, errors.Wrap(err, "failed to get quote")
+	}
+	if result.Quote.OutAmount < uint64(p.Quarks) {
+		return result, errors.Wrapf(ErrInvalidQuote, "quoted %d, expected %d", result.Quote.OutAmount, p.Quarks)
+	}
+
+	result.SwapTxID, err = s.Swap(ctx, p.Sender, result.Quote)
+	if err != nil {
+		return result, errors.Wrap(err, "failed to swap")
+	}
+
+	payment := p.Payment
+	if bytes.Equal(outputMint, KinMint) {
+		payment.Mint = nil
+	}
+
+	result.PaymentTxID, err = c.SubmitPayment(ctx, payment, opts...)
+	if err != nil {
+		return result, errors.Wrapf(err, "failed to submit payment after swap %s", base58.Encode(result.SwapTxID))
+	}
+
+	return result, nil
+}
+
+func mustPublicKey(s string) kin.PublicKey {
+	b, err := base58.Decode(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,140 @@
+package swap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+type fakeClient struct {
+	client.Client
+
+	payments []client.Payment
+	err      error
+}
+
+func (f *fakeClient) SubmitPayment(_ context.Context, p client.Payment, _ ...client.SolanaOption) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.payments = append(f.payments, p)
+	return []byte("payment"), nil
+}
+
+type fakeSwapper struct {
+	requests []QuoteRequest
+	swaps    []Quote
+	// shortfall is subtracted from the quoted output amount.
+	shortfall uint64
+}
+
+func (f *fakeSwapper) Quote(_ context.Context, req QuoteRequest) (Quote, error) {
+	f.requests = append(f.requests, req)
+	return Quote{
+		InputMint:  req.InputMint,
+		OutputMint: req.OutputMint,
+		InAmount:   req.Amount * 2,
+		OutAmount:  req.Amount - f.shortfall,
+		Mode:       req.Mode,
+		Threshold:  req.Amount * 3,
+	}, nil
+}
+
+func (f *fakeSwapper) Swap(_ context.Context, _ kin.PrivateKey, q Quote) ([]byte, error) {
+	f.swaps = append(f.swaps, q)
+	return []byte("swap"), nil
+}
+
+func TestQuoteAndPay(t *testing.T) {
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	c := &fakeClient{}
+	s := &fakeSwapper{}
+
+	// Pay out Kin from USDC.
+	result, err := QuoteAndPay(context.Background(), c, s, Payout{
+		Payment: client.Payment{
+			Sender:      sender,
+			Destination: dest.Public(),
+			Type:        kin.TransactionTypeEarn,
+			Quarks:      100,
+		},
+		InputMint:   USDCMint,
+		SlippageBps: 50,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("swap"), result.SwapTxID)
+	assert.Equal(t, []byte("payment"), result.PaymentTxID)
+	assert.EqualValues(t, 200, result.Quote.InAmount)
+
+	require.Len(t, s.requests, 1)
+	assert.Equal(t, QuoteRequest{
+		InputMint:   USDCMint,
+		OutputMint:  KinMint,
+		Amount:      100,
+		Mode:        ModeExactOut,
+		SlippageBps: 50,
+	}, s.requests[0])
+	require.Len(t, s.swaps, 1)
+
+	require.Len(t, c.payments, 1)
+	assert.EqualValues(t, 100, c.payments[0].Quarks)
+	assert.Nil(t, c.payments[0].Mint)
+
+	// Pay out USDC from Kin.
+	_, err = QuoteAndPay(context.Background(), c, s, Payout{
+		Payment: client.Payment{
+			Sender:      sender,
+			Destination: dest.Public(),
+			Quarks:      10,
+			Mint:        USDCMint,
+		},
+		InputMint: KinMint,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, USDCMint, s.requests[1].OutputMint)
+	require.Len(t, c.payments, 2)
+	assert.Equal(t, USDCMint, c.payments[1].Mint)
+
+	// Invalid payouts are rejected before quoting.
+	_, err = QuoteAndPay(context.Background(), c, s, Payout{
+		Payment:   client.Payment{Sender: sender, Destination: dest.Public(), Quarks: 10},
+		InputMint: KinMint,
+	})
+	assert.Error(t, err)
+	_, err = QuoteAndPay(context.Background(), c, s, Payout{
+		Payment:   client.Payment{Sender: sender, Destination: dest.Public()},
+		InputMint: USDCMint,
+	})
+	assert.Error(t, err)
+	assert.Len(t, s.requests, 2)
+
+	// Quotes for less than the payout are not executed.
+	s.shortfall = 1
+	_, err = QuoteAndPay(context.Background(), c, s, Payout{
+		Payment:   client.Payment{Sender: sender, Destination: dest.Public(), Quarks: 10},
+		InputMint: SOLMint,
+	})
+	assert.Equal(t, ErrInvalidQuote, errors.Cause(err))
+	assert.Len(t, s.swaps, 2)
+	s.shortfall = 0
+
+	// The swap is reported if the payment fails.
+	c.err = errors.New("failed")
+	result, err = QuoteAndPay(context.Background(), c, s, Payout{
+		Payment:   client.Payment{Sender: sender, Destination: dest.Public(), Quarks: 10},
+		InputMint: SOLMint,
+	})
+	assert.Equal(t, c.err, errors.Cause(err))
+	assert.Equal(t, []byte("swap"), result.SwapTxID)
+	assert.Nil(t, result.PaymentTxID)
+}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/pkg/errors"
+)
+
+// SweepAccount transfers the balances of all of the token accounts owned by
+// account to destination, closing each token account whose close authority is
+// the account or the subsidizer, and returns the IDs of the transactions. No
+// transactions are submitted if there is nothing to sweep.
+//
+// Each transfer is screened and subject to limits in the same way as a payment
+// from the account to destination. Accounts with many token accounts are swept
+// in as many transactions as required; if one fails, the IDs of the preceding
+// transactions are returned alongside the error.
+//
+// The destination is resolved to its token account, unless
+// WithDestResolution(AccountResolutionExact) is specified.
+func (c *client) SweepAccount(ctx context.Context, account kin.PrivateKey, destination kin.PublicKey, opts ...SolanaOption) ([][]byte, error) {
+	conf := solanaOpts{
+		commitment:     c.opts.defaultCommitment,
+		destResolution: AccountResolutionPreferred,
+	}
+	for _, o := range opts {
+		o(&conf)
+	}
+	if err := c.selectSubsidizer(ctx, &conf); err != nil {
+		return nil, err
+	}
+
+	existingAccounts, err := c.internal.ResolveTokenAccounts(ctx, account.Public(), true)
+	if err != nil {
+		return nil, err
+	}
+	if len(existingAccounts) == 0 {
+		return nil, ErrAccountDoesNotExist
+	}
+
+	config, err := c.internal.GetServiceConfig(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get service config")
+	}
+
+	var subsidizer ed25519.PublicKey
+	signers := []kin.PrivateKey{account}
+	if conf.subsidizer != nil {
+		subsidizer = ed25519.PublicKey(conf.subsidizer.Public())
+		signers = append(signers, conf.subsidizer)
+	} else if len(config.SubsidizerAccount.GetValue()) == ed25519.PublicKeySize {
+		subsidizer = config.SubsidizerAccount.Value
+	} else {
+		return nil, ErrNoSubsidizer
+	}
+
+	dest := destination
+	if conf.destResolution == AccountResolutionPreferred {
+		tokenAccounts, err := c.internal.ResolveTokenAccounts(ctx, destination, false)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve destination")
+		}
+		if len(tokenAccounts) > 0 {
+			dest = tokenAccounts[0].AccountId.Value
+		}
+	}
+
+	var groups [][]solana.Instruction
+	var entries []limitEntry
+	for _, existing := range existingAccounts {
+		if bytes.Equal(existing.AccountId.Value, dest) {
+			return nil, errors.New("cannot sweep an account into itself")
+		}
+
+		var group []solana.Instruction
+		if existing.Balance > 0 {
+			err := c.screen(Payment{
+				Sender:      account,
+				Destination: destination,
+				Type:        kin.TransactionTypeNone,
+				Quarks:      existing.Balance,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, limitEntry{destination: destination, quarks: existing.Balance})
+			group = append(group, token.Transfer(
+				existing.AccountId.Value,
+				ed25519.PublicKey(dest),
+				ed25519.PublicKey(account.Public()),
+				uint64(existing.Balance),
+			))
+		}
+
+		// As with MergeTokenAccounts, accounts are only closed if the close
+		// authority is known to sign for it.
+		closeAuthority := existing.GetCloseAuthority().GetValue()
+		if bytes.Equal(closeAuthority, account.Public()) || bytes.Equal(closeAuthority, subsidizer) {
+			group = append(group, token.CloseAccount(
+				existing.AccountId.Value,
+				closeAuthority,
+				closeAuthority,
+			))
+		}
+
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	if limits := c.limits(); limits != nil && len(entries) > 0 {
+		if err := limits.check(ctx, c.opts.appIndex, entries, c.opts.clock.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	// The instructions of each token account are kept in the same
+	// transaction, and as many token accounts as fit are swept together.
+	var txs []solana.Transaction
+	var instructions []solana.Instruction
+	for _, group := range groups {
+		candidate := append(instructions[:len(instructions):len(instructions)], group...)
+		if TransactionSize(solana.NewTransaction(subsidizer, candidate...)) > solana.MaxTransactionSize && len(instructions) > 0 {
+			txs = append(txs, solana.NewTransaction(subsidizer, instructions...))
+			candidate = group
+		}
+		instructions = candidate
+	}
+	txs = append(txs, solana.NewTransaction(subsidizer, instructions...))
+
+	var txIDs [][]byte
+	for _, tx := range txs {
+		result, err := c.signAndSubmitTx(ctx, signers, tx, conf.commitment, nil, nil, nil)
+		if err == nil {
+			err = result.Errors.TxError
+		}
+		if result.ID != nil {
+			txIDs = append(txIDs, result.ID)
+		}
+		if err != nil {
+			return txIDs, err
+		}
+	}
+
+	return txIDs, nil
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+
+	"github.com/kinecosystem/kin-go/client/testutil"
+)
+
+func TestClient_SweepAccount(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	_, _, subsidizer := setServiceConfigResp(t, env.v4Server, true)
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	_, err = env.client.SweepAccount(context.Background(), priv, dest.Public())
+	assert.Equal(t, ErrAccountDoesNotExist, err)
+
+	keys := testutil.GenerateSolanaKeys(t, 4)
+	tokenAccounts, destAccount := keys[:3], keys[3]
+	closeAuthorities := []ed25519.PublicKey{subsidizer, ed25519.PublicKey(priv.Public()), testutil.GenerateSolanaKeys(t, 1)[0]}
+	balances := []int64{10, 0, 30}
+
+	env.v4Server.Mux.Lock()
+	for i, a := range tokenAccounts {
+		env.v4Server.Accounts[base58.Encode(a)] = &accountpbv4.AccountInfo{
+			AccountId:      &commonpbv4.SolanaAccountId{Value: a},
+			Balance:        balances[i],
+			Owner:          &commonpbv4.SolanaAccountId{Value: priv.Public()},
+			CloseAuthority: &commonpbv4.SolanaAccountId{Value: closeAuthorities[i]},
+		}
+		env.v4Server.TokenAccounts[priv.Public().Base58()] = append(
+			env.v4Server.TokenAccounts[priv.Public().Base58()],
+			&commonpbv4.SolanaAccountId{Value: a},
+		)
+	}
+	env.v4Server.TokenAccounts[dest.Public().Base58()] = []*commonpbv4.SolanaAccountId{{Value: destAccount}}
+	env.v4Server.Mux.Unlock()
+
+	txIDs, err := env.client.SweepAccount(context.Background(), priv, dest.Public())
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 1)
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(env.v4Server.Submits[0].Transaction.Value))
+	env.v4Server.Mux.Unlock()
+	assert.Equal(t, [][]byte{tx.Signature()}, txIDs)
+
+	parsed, err := kin.ParseTransaction(tx, nil)
+	require.NoError(t, err)
+	require.Len(t, parsed.Regions, 1)
+
+	// Empty accounts are not transferred from, and accounts are only closed if
+	// the close authority will sign.
+	transfers := parsed.Regions[0].Transfers
+	require.Len(t, transfers, 2)
+	for i, index := range []int{0, 2} {
+		assert.EqualValues(t, tokenAccounts[index], transfers[i].Source)
+		assert.EqualValues(t, destAccount, transfers[i].Destination)
+		assert.EqualValues(t, balances[index], transfers[i].Amount)
+	}
+
+	closures := parsed.Regions[0].Closures
+	require.Len(t, closures, 2)
+	for i, index := range []int{0, 1} {
+		assert.EqualValues(t, tokenAccounts[index], closures[i].Account)
+		assert.EqualValues(t, closeAuthorities[index], closures[i].Destination)
+	}
+
+	// Without destination resolution, the destination is used as is.
+	env.v4Server.Mux.Lock()
+	env.v4Server.Submits = nil
+	env.v4Server.Mux.Unlock()
+
+	_, err = env.client.SweepAccount(context.Background(), priv, dest.Public(), WithDestResolution(AccountResolutionExact))
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 1)
+	require.NoError(t, tx.Unmarshal(env.v4Server.Submits[0].Transaction.Value))
+	env.v4Server.Mux.Unlock()
+
+	parsed, err = kin.ParseTransaction(tx, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, dest.Public(), parsed.Regions[0].Transfers[0].Destination)
+}
+
+func TestClient_SweepAccountScreened(t *testing.T) {
+	var screened []Payment
+	env, cleanup := setup(t, WithPaymentScreener(func(p Payment) error {
+		screened = append(screened, p)
+		return errors.New("denied")
+	}))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	require.NoError(t, env.client.CreateAccount(context.Background(), priv))
+
+	_, err = env.client.SweepAccount(context.Background(), priv, dest.Public())
+	assert.Equal(t, ErrPaymentScreened, errors.Cause(err))
+	require.Len(t, screened, 1)
+	assert.Equal(t, dest.Public(), screened[0].Destination)
+
+	env.v4Server.Mux.Lock()
+	assert.Empty(t, env.v4Server.Submits)
+	env.v4Server.Mux.Unlock()
+}
+
+func TestClient_SweepAccountLimits(t *testing.T) {
+	env, cleanup := setup(t, WithLimits(Limits{MaxPaymentQuarks: 5}))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	require.NoError(t, env.client.CreateAccount(context.Background(), priv))
+
+	_, err = env.client.SweepAccount(context.Background(), priv, dest.Public())
+	assert.Equal(t, ErrLimitExceeded, errors.Cause(err))
+
+	env.v4Server.Mux.Lock()
+	assert.Empty(t, env.v4Server.Submits)
+	env.v4Server.Mux.Unlock()
+}
+
+func TestClient_SweepAccountSplit(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest := testutil.GenerateSolanaKeys(t, 1)[0]
+
+	tokenAccounts := testutil.GenerateSolanaKeys(t, 30)
+	env.v4Server.Mux.Lock()
+	for _, a := range tokenAccounts {
+		env.v4Server.Accounts[base58.Encode(a)] = &accountpbv4.AccountInfo{
+			AccountId:      &commonpbv4.SolanaAccountId{Value: a},
+			Balance:        1,
+			Owner:          &commonpbv4.SolanaAccountId{Value: priv.Public()},
+			CloseAuthority: &commonpbv4.SolanaAccountId{Value: priv.Public()},
+		}
+		env.v4Server.TokenAccounts[priv.Public().Base58()] = append(
+			env.v4Server.TokenAccounts[priv.Public().Base58()],
+			&commonpbv4.SolanaAccountId{Value: a},
+		)
+	}
+	env.v4Server.Mux.Unlock()
+
+	txIDs, err := env.client.SweepAccount(context.Background(), priv, kin.PublicKey(dest), WithDestResolution(AccountResolutionExact))
+	require.NoError(t, err)
+	assert.True(t, len(txIDs) > 1)
+
+	env.v4Server.Mux.Lock()
+	defer env.v4Server.Mux.Unlock()
+	require.Len(t, env.v4Server.Submits, len(txIDs))
+
+	swept := make(map[string]bool)
+	for _, submit := range env.v4Server.Submits {
+		var tx solana.Transaction
+		require.NoError(t, tx.Unmarshal(submit.Transaction.Value))
+		assert.True(t, TransactionSize(tx) <= solana.MaxTransactionSize)
+
+		parsed, err := kin.ParseTransaction(tx, nil)
+		require.NoError(t, err)
+		for _, r := range parsed.Regions {
+			require.Equal(t, len(r.Transfers), len(r.Closures))
+			for i := range r.Transfers {
+				// Each account is transferred from and closed together.
+				assert.EqualValues(t, r.Transfers[i].Source, r.Closures[i].Account)
+				swept[base58.Encode(r.Transfers[i].Source)] = true
+			}
+		}
+	}
+	assert.Len(t, swept, len(tokenAccounts))
+}
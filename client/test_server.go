@@ -4,14 +4,20 @@ import (
 	"bytes"
 	"context"
 	"crypto/ed25519"
+	"path"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/kinecosystem/agora-api/genproto/airdrop/v4"
+	"github.com/kinecosystem/agora-common/headers"
 	"github.com/kinecosystem/agora-common/kin"
 	"github.com/kinecosystem/agora-common/solana"
+	agoratestutil "github.com/kinecosystem/agora-common/testutil"
 	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
@@ -20,16 +26,26 @@ import (
 	airdroppbv4 "github.com/kinecosystem/agora-api/genproto/airdrop/v4"
 	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
 	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+
+	"github.com/kinecosystem/kin-go/client/versioned"
 )
 
 var RecentBlockhash = bytes.Repeat([]byte{1}, 32)
 var MinBalanceForRentException = uint64(1234567)
 var MaxAirdrop = uint64(100000)
 
-type server struct {
+// TestServer is an in-memory implementation of the Agora account, transaction
+// and airdrop services, for tests that exercise a client end to end (see
+// TestServer.Start). Tests configure the server and inspect the requests it
+// received through its fields, while holding Mux.
+type TestServer struct {
 	Mux    sync.Mutex
 	Errors []error
 
+	// Latency delays each call to the RPCs it contains, keyed by method name
+	// (such as "SubmitTransaction"), before the call is handled.
+	Latency map[string]time.Duration
+
 	Creates       []*accountpbv4.CreateAccountRequest
 	Accounts      map[string]*accountpbv4.AccountInfo
 	TokenAccounts map[string][]*commonpbv4.SolanaAccountId
@@ -38,24 +54,113 @@ type server struct {
 	ServiceConfig     *transactionpbv4.GetServiceConfigResponse
 	Subsidizer        ed25519.PrivateKey
 
+	RentExemptionReqs []*transactionpbv4.GetMinimumBalanceForRentExemptionRequest
+
+	// KinVersion is the minimum kin version returned by the server. It
+	// defaults to 4.
+	KinVersion uint32
+
+	// VersionedUnsupported rejects versioned transactions as having an
+	// unsupported version.
+	VersionedUnsupported bool
+
 	Gets            map[string]transactionpbv4.GetTransactionResponse
 	Signs           []*transactionpbv4.SignTransactionRequest
+	SignMetadata    []metadata.MD
 	Submits         []*transactionpbv4.SubmitTransactionRequest
+	SubmitMetadata  []metadata.MD
 	SignResponses   []*transactionpbv4.SignTransactionResponse
 	SubmitResponses []*transactionpbv4.SubmitTransactionResponse
 
+	// SubmitHook, if set, is called with each transaction that is submitted
+	// successfully and its signature, while the server is locked, allowing
+	// tests to simulate its effects.
+	SubmitHook func(tx []byte, sig []byte)
+
+	Airdrops        []*airdroppbv4.RequestAirdropRequest
 	EventsResponses []*accountpbv4.Events
+
+	// History contains the history items of each account, oldest first.
+	History         map[string][]*transactionpbv4.HistoryItem
+	HistoryPageSize int
+
+	// HistoryHook, if set, is called with each history request while the
+	// server is locked, allowing tests to modify the history between requests.
+	HistoryHook func(req *transactionpbv4.GetHistoryRequest)
 }
 
-func newServer() *server {
-	return &server{
+// NewTestServer returns a new TestServer with no accounts.
+func NewTestServer() *TestServer {
+	return &TestServer{
+		Latency:       make(map[string]time.Duration),
 		Accounts:      make(map[string]*accountpbv4.AccountInfo),
 		TokenAccounts: make(map[string][]*commonpbv4.SolanaAccountId),
 		Gets:          make(map[string]transactionpbv4.GetTransactionResponse),
+		History:       make(map[string][]*transactionpbv4.HistoryItem),
 	}
 }
 
-func (t *server) CreateAccount(ctx context.Context, req *accountpbv4.CreateAccountRequest) (*accountpbv4.CreateAccountResponse, error) {
+// Start serves the server on a local port, returning a connection to it, which
+// can be passed to WithGRPC, and a function that stops the server.
+func (t *TestServer) Start() (*grpc.ClientConn, func(), error) {
+	conn, serv, err := agoratestutil.NewServer(
+		agoratestutil.WithUnaryServerInterceptor(headers.UnaryServerInterceptor()),
+		agoratestutil.WithUnaryServerInterceptor(t.unaryLatencyInterceptor),
+		agoratestutil.WithStreamServerInterceptor(headers.StreamServerInterceptor()),
+		agoratestutil.WithStreamServerInterceptor(t.streamLatencyInterceptor),
+	)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create test server")
+	}
+
+	serv.RegisterService(func(s *grpc.Server) {
+		accountpbv4.RegisterAccountServer(s, t)
+		transactionpbv4.RegisterTransactionServer(s, t)
+		airdroppbv4.RegisterAirdropServer(s, t)
+	})
+
+	stop, err := serv.Serve()
+	if err != nil {
+		conn.Close()
+		return nil, nil, errors.Wrap(err, "failed to serve test server")
+	}
+	return conn, stop, nil
+}
+
+func (t *TestServer) unaryLatencyInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := t.delay(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (t *TestServer) streamLatencyInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := t.delay(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// delay waits for the latency of fullMethod, without holding the lock, so that
+// concurrent calls are delayed independently.
+func (t *TestServer) delay(ctx context.Context, fullMethod string) error {
+	t.Mux.Lock()
+	latency := t.Latency[path.Base(fullMethod)]
+	t.Mux.Unlock()
+
+	if latency <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(latency):
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (t *TestServer) CreateAccount(ctx context.Context, req *accountpbv4.CreateAccountRequest) (*accountpbv4.CreateAccountResponse, error) {
 	t.Mux.Lock()
 	defer t.Mux.Unlock()
 
@@ -82,7 +187,7 @@ func (t *server) CreateAccount(ctx context.Context, req *accountpbv4.CreateAccou
 	}
 
 	var tokenAccID, ownerID string
-	var tokenAddr ed25519.PublicKey
+	var tokenAddr, ownerAddr ed25519.PublicKey
 	for _, r := range parsed.Regions {
 		switch len(r.Creations) {
 		case 0:
@@ -98,11 +203,13 @@ func (t *server) CreateAccount(ctx context.Context, req *accountpbv4.CreateAccou
 		if r.Creations[0].Create != nil {
 			tokenAddr = r.Creations[0].Create.Address
 			tokenAccID = base58.Encode(tokenAddr)
-			ownerID = base58.Encode(r.Creations[0].AccountHolder.NewAuthority)
+			ownerAddr = r.Creations[0].AccountHolder.NewAuthority
+			ownerID = base58.Encode(ownerAddr)
 		} else {
 			tokenAddr = r.Creations[0].CreateAssoc.Address
 			tokenAccID = base58.Encode(tokenAddr)
-			ownerID = base58.Encode(r.Creations[0].CreateAssoc.Owner)
+			ownerAddr = r.Creations[0].CreateAssoc.Owner
+			ownerID = base58.Encode(ownerAddr)
 		}
 	}
 
@@ -116,6 +223,7 @@ func (t *server) CreateAccount(ctx context.Context, req *accountpbv4.CreateAccou
 	accountInfo := &accountpbv4.AccountInfo{
 		AccountId: &commonpbv4.SolanaAccountId{Value: tokenAddr},
 		Balance:   10,
+		Owner:     &commonpbv4.SolanaAccountId{Value: ownerAddr},
 	}
 	t.Accounts[tokenAccID] = accountInfo
 	t.TokenAccounts[ownerID] = append(t.TokenAccounts[ownerID], &commonpbv4.SolanaAccountId{Value: tokenAddr})
@@ -125,7 +233,7 @@ func (t *server) CreateAccount(ctx context.Context, req *accountpbv4.CreateAccou
 	}, nil
 }
 
-func (t *server) GetAccountInfo(ctx context.Context, req *accountpbv4.GetAccountInfoRequest) (*accountpbv4.GetAccountInfoResponse, error) {
+func (t *TestServer) GetAccountInfo(ctx context.Context, req *accountpbv4.GetAccountInfoRequest) (*accountpbv4.GetAccountInfoResponse, error) {
 	t.Mux.Lock()
 	defer t.Mux.Unlock()
 
@@ -145,7 +253,7 @@ func (t *server) GetAccountInfo(ctx context.Context, req *accountpbv4.GetAccount
 	}, nil
 }
 
-func (t *server) ResolveTokenAccounts(ctx context.Context, req *accountpbv4.ResolveTokenAccountsRequest) (*accountpbv4.ResolveTokenAccountsResponse, error) {
+func (t *TestServer) ResolveTokenAccounts(ctx context.Context, req *accountpbv4.ResolveTokenAccountsRequest) (*accountpbv4.ResolveTokenAccountsResponse, error) {
 	t.Mux.Lock()
 	defer t.Mux.Unlock()
 
@@ -186,7 +294,7 @@ func (t *server) ResolveTokenAccounts(ctx context.Context, req *accountpbv4.Reso
 	return resp, nil
 }
 
-func (t *server) GetEvents(req *accountpbv4.GetEventsRequest, stream accountpbv4.Account_GetEventsServer) error {
+func (t *TestServer) GetEvents(req *accountpbv4.GetEventsRequest, stream accountpbv4.Account_GetEventsServer) error {
 	t.Mux.Lock()
 	defer t.Mux.Unlock()
 
@@ -210,7 +318,7 @@ func (t *server) GetEvents(req *accountpbv4.GetEventsRequest, stream accountpbv4
 	return nil
 }
 
-func (t *server) GetServiceConfig(ctx context.Context, req *transactionpbv4.GetServiceConfigRequest) (*transactionpbv4.GetServiceConfigResponse, error) {
+func (t *TestServer) GetServiceConfig(ctx context.Context, req *transactionpbv4.GetServiceConfigRequest) (*transactionpbv4.GetServiceConfigResponse, error) {
 	t.Mux.Lock()
 	defer t.Mux.Unlock()
 
@@ -222,15 +330,21 @@ func (t *server) GetServiceConfig(ctx context.Context, req *transactionpbv4.GetS
 	return t.ServiceConfig, nil
 }
 
-func (t *server) GetMinimumKinVersion(ctx context.Context, req *transactionpbv4.GetMinimumKinVersionRequest) (*transactionpbv4.GetMinimumKinVersionResponse, error) {
+func (t *TestServer) GetMinimumKinVersion(ctx context.Context, req *transactionpbv4.GetMinimumKinVersionRequest) (*transactionpbv4.GetMinimumKinVersionResponse, error) {
+	t.Mux.Lock()
+	defer t.Mux.Unlock()
+
 	if err := validateV4Headers(ctx); err != nil {
 		return nil, err
 	}
 
+	if t.KinVersion != 0 {
+		return &transactionpbv4.GetMinimumKinVersionResponse{Version: t.KinVersion}, nil
+	}
 	return &transactionpbv4.GetMinimumKinVersionResponse{Version: 4}, nil
 }
 
-func (t *server) GetRecentBlockhash(ctx context.Context, req *transactionpbv4.GetRecentBlockhashRequest) (*transactionpbv4.GetRecentBlockhashResponse, error) {
+func (t *TestServer) GetRecentBlockhash(ctx context.Context, req *transactionpbv4.GetRecentBlockhashRequest) (*transactionpbv4.GetRecentBlockhashResponse, error) {
 	if err := validateV4Headers(ctx); err != nil {
 		return nil, err
 	}
@@ -238,19 +352,83 @@ func (t *server) GetRecentBlockhash(ctx context.Context, req *transactionpbv4.Ge
 	return &transactionpbv4.GetRecentBlockhashResponse{Blockhash: &commonpbv4.Blockhash{Value: RecentBlockhash}}, nil
 }
 
-func (t *server) GetMinimumBalanceForRentExemption(ctx context.Context, req *transactionpbv4.GetMinimumBalanceForRentExemptionRequest) (*transactionpbv4.GetMinimumBalanceForRentExemptionResponse, error) {
+func (t *TestServer) GetMinimumBalanceForRentExemption(ctx context.Context, req *transactionpbv4.GetMinimumBalanceForRentExemptionRequest) (*transactionpbv4.GetMinimumBalanceForRentExemptionResponse, error) {
+	t.Mux.Lock()
+	defer t.Mux.Unlock()
+
 	if err := validateV4Headers(ctx); err != nil {
 		return nil, err
 	}
 
+	t.RentExemptionReqs = append(t.RentExemptionReqs, proto.Clone(req).(*transactionpbv4.GetMinimumBalanceForRentExemptionRequest))
+
 	return &transactionpbv4.GetMinimumBalanceForRentExemptionResponse{Lamports: MinBalanceForRentException}, nil
 }
 
-func (t *server) GetHistory(context.Context, *transactionpbv4.GetHistoryRequest) (*transactionpbv4.GetHistoryResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+func (t *TestServer) GetHistory(ctx context.Context, req *transactionpbv4.GetHistoryRequest) (*transactionpbv4.GetHistoryResponse, error) {
+	t.Mux.Lock()
+	defer t.Mux.Unlock()
+
+	if err := validateV4Headers(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := t.GetError(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if t.HistoryHook != nil {
+		t.HistoryHook(req)
+	}
+
+	history, ok := t.History[string(req.AccountId.Value)]
+	if !ok {
+		return &transactionpbv4.GetHistoryResponse{Result: transactionpbv4.GetHistoryResponse_NOT_FOUND}, nil
+	}
+
+	items := make([]*transactionpbv4.HistoryItem, len(history))
+	copy(items, history)
+	if req.Direction == transactionpbv4.GetHistoryRequest_DESC {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	if req.Cursor != nil {
+		for i, item := range items {
+			if bytes.Equal(item.Cursor.GetValue(), req.Cursor.Value) {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+	if t.HistoryPageSize > 0 && len(items) > t.HistoryPageSize {
+		items = items[:t.HistoryPageSize]
+	}
+
+	return &transactionpbv4.GetHistoryResponse{
+		Result: transactionpbv4.GetHistoryResponse_OK,
+		Items:  items,
+	}, nil
+}
+
+// testTransaction is a legacy or versioned transaction.
+type testTransaction interface {
+	Signature() []byte
+	Sign(signers ...ed25519.PrivateKey) error
 }
 
-func (t *server) SignTransaction(ctx context.Context, req *transactionpbv4.SignTransactionRequest) (*transactionpbv4.SignTransactionResponse, error) {
+func unmarshalTestTransaction(b []byte) (testTransaction, error) {
+	if versioned.IsVersioned(b) {
+		tx := &versioned.Transaction{}
+		return tx, tx.Unmarshal(b)
+	}
+
+	tx := &solana.Transaction{}
+	return tx, tx.Unmarshal(b)
+}
+
+func (t *TestServer) SignTransaction(ctx context.Context, req *transactionpbv4.SignTransactionRequest) (*transactionpbv4.SignTransactionResponse, error) {
 	t.Mux.Lock()
 	defer t.Mux.Unlock()
 
@@ -262,24 +440,31 @@ func (t *server) SignTransaction(ctx context.Context, req *transactionpbv4.SignT
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	tx := solana.Transaction{}
-	if err := tx.Unmarshal(req.Transaction.Value); err != nil {
+	if t.VersionedUnsupported && versioned.IsVersioned(req.Transaction.Value) {
+		return nil, status.Error(codes.InvalidArgument, "unsupported transaction version")
+	}
+	tx, err := unmarshalTestTransaction(req.Transaction.Value)
+	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to unmarshal tx: %v", err)
 	}
 
 	t.Signs = append(t.Signs, proto.Clone(req).(*transactionpbv4.SignTransactionRequest))
+	md, _ := metadata.FromIncomingContext(ctx)
+	t.SignMetadata = append(t.SignMetadata, md)
 	if len(t.SignResponses) > 0 {
 		r := t.SignResponses[0]
 		t.SignResponses = t.SignResponses[1:]
 		if r != nil {
-			r.Signature = &commonpbv4.TransactionSignature{
-				Value: tx.Signature(),
+			if r.Signature == nil {
+				r.Signature = &commonpbv4.TransactionSignature{
+					Value: tx.Signature(),
+				}
 			}
 			return r, nil
 		}
 	}
 
-	if t.ServiceConfig != nil && t.ServiceConfig.GetSubsidizerAccount() != nil && t.Subsidizer != nil && bytes.Equal(tx.Signatures[0][:], make([]byte, ed25519.SignatureSize)) {
+	if t.ServiceConfig != nil && t.ServiceConfig.GetSubsidizerAccount() != nil && t.Subsidizer != nil && bytes.Equal(tx.Signature(), make([]byte, ed25519.SignatureSize)) {
 		err := tx.Sign(t.Subsidizer)
 		if err != nil {
 			return nil, status.Error(codes.Internal, "failed to sign transaction with subsidizer")
@@ -293,7 +478,7 @@ func (t *server) SignTransaction(ctx context.Context, req *transactionpbv4.SignT
 	}, nil
 }
 
-func (t *server) SubmitTransaction(ctx context.Context, req *transactionpbv4.SubmitTransactionRequest) (*transactionpbv4.SubmitTransactionResponse, error) {
+func (t *TestServer) SubmitTransaction(ctx context.Context, req *transactionpbv4.SubmitTransactionRequest) (*transactionpbv4.SubmitTransactionResponse, error) {
 	t.Mux.Lock()
 	defer t.Mux.Unlock()
 
@@ -301,12 +486,18 @@ func (t *server) SubmitTransaction(ctx context.Context, req *transactionpbv4.Sub
 		return nil, err
 	}
 
+	md, _ := metadata.FromIncomingContext(ctx)
+	t.SubmitMetadata = append(t.SubmitMetadata, md)
+
 	if err := t.GetError(); err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	tx := solana.Transaction{}
-	if err := tx.Unmarshal(req.Transaction.Value); err != nil {
+	if t.VersionedUnsupported && versioned.IsVersioned(req.Transaction.Value) {
+		return nil, status.Error(codes.InvalidArgument, "unsupported transaction version")
+	}
+	tx, err := unmarshalTestTransaction(req.Transaction.Value)
+	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to unmarshal tx: %v", err)
 	}
 
@@ -315,20 +506,26 @@ func (t *server) SubmitTransaction(ctx context.Context, req *transactionpbv4.Sub
 		r := t.SubmitResponses[0]
 		t.SubmitResponses = t.SubmitResponses[1:]
 		if r != nil {
-			r.Signature = &commonpbv4.TransactionSignature{
-				Value: tx.Signature(),
+			if r.Signature == nil {
+				r.Signature = &commonpbv4.TransactionSignature{
+					Value: tx.Signature(),
+				}
 			}
 			return r, nil
 		}
 	}
 
-	if t.ServiceConfig != nil && t.ServiceConfig.GetSubsidizerAccount() != nil && t.Subsidizer != nil && bytes.Equal(tx.Signatures[0][:], make([]byte, ed25519.SignatureSize)) {
+	if t.ServiceConfig != nil && t.ServiceConfig.GetSubsidizerAccount() != nil && t.Subsidizer != nil && bytes.Equal(tx.Signature(), make([]byte, ed25519.SignatureSize)) {
 		err := tx.Sign(t.Subsidizer)
 		if err != nil {
 			return nil, status.Error(codes.Internal, "failed to sign transaction with subsidizer")
 		}
 	}
 
+	if t.SubmitHook != nil {
+		t.SubmitHook(req.Transaction.Value, tx.Signature())
+	}
+
 	return &transactionpbv4.SubmitTransactionResponse{
 		Signature: &commonpbv4.TransactionSignature{
 			Value: tx.Signature(),
@@ -336,7 +533,7 @@ func (t *server) SubmitTransaction(ctx context.Context, req *transactionpbv4.Sub
 	}, nil
 }
 
-func (t *server) GetTransaction(ctx context.Context, req *transactionpbv4.GetTransactionRequest) (*transactionpbv4.GetTransactionResponse, error) {
+func (t *TestServer) GetTransaction(ctx context.Context, req *transactionpbv4.GetTransactionRequest) (*transactionpbv4.GetTransactionResponse, error) {
 	t.Mux.Lock()
 	defer t.Mux.Unlock()
 
@@ -357,7 +554,7 @@ func (t *server) GetTransaction(ctx context.Context, req *transactionpbv4.GetTra
 	}, nil
 }
 
-func (t *server) RequestAirdrop(ctx context.Context, req *airdrop.RequestAirdropRequest) (*airdrop.RequestAirdropResponse, error) {
+func (t *TestServer) RequestAirdrop(ctx context.Context, req *airdrop.RequestAirdropRequest) (*airdrop.RequestAirdropResponse, error) {
 	t.Mux.Lock()
 	defer t.Mux.Unlock()
 
@@ -369,7 +566,9 @@ func (t *server) RequestAirdrop(ctx context.Context, req *airdrop.RequestAirdrop
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	_, ok := t.Accounts[base58.Encode(req.AccountId.Value)]
+	t.Airdrops = append(t.Airdrops, proto.Clone(req).(*airdroppbv4.RequestAirdropRequest))
+
+	info, ok := t.Accounts[base58.Encode(req.AccountId.Value)]
 	if !ok {
 		return &airdroppbv4.RequestAirdropResponse{Result: airdroppbv4.RequestAirdropResponse_NOT_FOUND}, nil
 	}
@@ -378,6 +577,9 @@ func (t *server) RequestAirdrop(ctx context.Context, req *airdrop.RequestAirdrop
 		return &airdroppbv4.RequestAirdropResponse{Result: airdroppbv4.RequestAirdropResponse_INSUFFICIENT_KIN}, nil
 	}
 
+	// Airdrops are credited immediately, so that tests can observe them.
+	info.Balance += int64(req.Quarks)
+
 	return &airdroppbv4.RequestAirdropResponse{
 		Signature: &commonpbv4.TransactionSignature{
 			Value: make([]byte, 64),
@@ -385,7 +587,7 @@ func (t *server) RequestAirdrop(ctx context.Context, req *airdrop.RequestAirdrop
 	}, nil
 }
 
-func (t *server) SetError(err error, n int) {
+func (t *TestServer) SetError(err error, n int) {
 	t.Mux.Lock()
 	defer t.Mux.Unlock()
 
@@ -395,7 +597,7 @@ func (t *server) SetError(err error, n int) {
 	}
 }
 
-func (t *server) GetError() error {
+func (t *TestServer) GetError() error {
 	if len(t.Errors) == 0 {
 		return nil
 	}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestServer_Latency(t *testing.T) {
+	env, cleanup := setup(t, WithMaxRetries(0))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	priv, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	require.NoError(t, env.client.CreateAccount(context.Background(), priv))
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.Latency["ResolveTokenAccounts"] = 50 * time.Millisecond
+	env.v4Server.Mux.Unlock()
+
+	start := time.Now()
+	_, err = env.client.ResolveTokenAccounts(context.Background(), priv.Public())
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// Other RPCs are not delayed, and delayed RPCs respect the deadline of the
+	// call.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = env.client.ResolveTokenAccounts(ctx, priv.Public())
+	assert.Error(t, err)
+
+	start = time.Now()
+	_, err = env.client.GetTransaction(context.Background(), make([]byte, 64))
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+}
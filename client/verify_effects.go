@@ -0,0 +1,190 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+)
+
+// WithVerifyEffects specifies that SubmitPayment and SubmitEarnBatch should
+// verify that a successful transaction moved exactly the expected amount out of
+// the sender's account, and that no other transaction debited the account while
+// it was being submitted.
+//
+// Before submitting, the sender's balance and the position of its most recent
+// transaction are recorded. Once the transaction succeeds, the sender's new
+// transactions and balance are compared with the snapshot, and an
+// *EffectsMismatchError is returned if they differ from the submission's
+// expected effects, such as when a compromised co-signer injects an extra
+// transfer into the transaction.
+//
+// Verification requires two additional requests before submitting, and at least
+// three after.
+func WithVerifyEffects() SolanaOption {
+	return func(o *solanaOpts) {
+		o.verifyEffects = true
+	}
+}
+
+// EffectsMismatchError is returned by submissions made with WithVerifyEffects
+// when a transaction succeeded, but its effects on the sender's account differ
+// from those expected.
+//
+// Its cause is ErrUnexpectedEffects.
+type EffectsMismatchError struct {
+	// TxID is the ID of the submitted transaction.
+	TxID []byte
+
+	// ExpectedDelta is the expected change in the sender's balance, in quarks,
+	// and TxDelta is the change made by the payments of the transaction.
+	ExpectedDelta int64
+	TxDelta       int64
+
+	// BalanceDelta is the observed change in the sender's balance, and
+	// HistoryDelta is the change accounted for by the sender's transactions
+	// since the snapshot, including the submitted transaction.
+	BalanceDelta int64
+	HistoryDelta int64
+
+	// Unexpected contains the IDs of other transactions that debited the
+	// sender's account since the snapshot.
+	Unexpected [][]byte
+}
+
+// Error implements error.Error.
+func (e *EffectsMismatchError) Error() string {
+	return fmt.Sprintf(
+		"%v: expected delta %d, transaction delta %d, balance delta %d, history delta %d, %d unexpected debits",
+		ErrUnexpectedEffects, e.ExpectedDelta, e.TxDelta, e.BalanceDelta, e.HistoryDelta, len(e.Unexpected),
+	)
+}
+
+// Cause returns ErrUnexpectedEffects, allowing it to be retrieved with errors.Cause.
+func (e *EffectsMismatchError) Cause() error {
+	return ErrUnexpectedEffects
+}
+
+// Unwrap returns ErrUnexpectedEffects.
+func (e *EffectsMismatchError) Unwrap() error {
+	return ErrUnexpectedEffects
+}
+
+// effectsSnapshot is the state of a sender's account before a submission.
+type effectsSnapshot struct {
+	owner      kin.PublicKey
+	accounts   map[string]struct{}
+	commitment commonpbv4.Commitment
+
+	balance int64
+	cursor  Cursor
+}
+
+// snapshotEffects records the balance and most recent history item of owner.
+func (c *client) snapshotEffects(ctx context.Context, owner kin.PublicKey, commitment commonpbv4.Commitment) (*effectsSnapshot, error) {
+	tokenAccounts, err := c.ResolveTokenAccounts(ctx, owner)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve sender token accounts")
+	}
+
+	s := &effectsSnapshot{
+		owner:      owner,
+		accounts:   map[string]struct{}{string(owner): {}},
+		commitment: commitment,
+	}
+	for _, account := range tokenAccounts {
+		s.accounts[string(account)] = struct{}{}
+	}
+
+	s.balance, err = c.GetBalance(ctx, owner, WithCommitment(commitment))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get sender balance")
+	}
+
+	latest, err := c.GetHistory(ctx, owner, nil, WithDescending())
+	if err != nil && err != ErrAccountDoesNotExist {
+		return nil, errors.Wrap(err, "failed to get sender history")
+	}
+	if len(latest) > 0 {
+		s.cursor = latest[0].Cursor
+	}
+
+	return s, nil
+}
+
+// verify checks that the only debit of the sender since the snapshot was the
+// transaction txID, which changed the sender's balance by expected quarks.
+func (s *effectsSnapshot) verify(ctx context.Context, c *client, txID []byte, expected int64) error {
+	balance, err := c.GetBalance(ctx, s.owner, WithCommitment(s.commitment))
+	if err != nil {
+		return errors.Wrap(err, "failed to get sender balance")
+	}
+
+	mismatch := &EffectsMismatchError{
+		TxID:          txID,
+		ExpectedDelta: expected,
+		BalanceDelta:  balance - s.balance,
+	}
+
+	found := false
+	cursor := s.cursor
+	for {
+		items, err := c.GetHistory(ctx, s.owner, cursor)
+		if err != nil {
+			return errors.Wrap(err, "failed to get sender history")
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, item := range items {
+			delta := s.delta(item.TransactionData)
+			mismatch.HistoryDelta += delta
+
+			if bytes.Equal(item.TxID, txID) {
+				found = true
+				mismatch.TxDelta = delta
+			} else if delta < 0 {
+				mismatch.Unexpected = append(mismatch.Unexpected, item.TxID)
+			}
+		}
+		cursor = items[len(items)-1].Cursor
+	}
+
+	// The transaction may not be indexed in the sender's history yet.
+	if !found {
+		data, err := c.GetTransaction(ctx, txID, WithCommitment(s.commitment))
+		if err != nil {
+			return errors.Wrap(err, "failed to get submitted transaction")
+		}
+		mismatch.TxDelta = s.delta(data)
+		mismatch.HistoryDelta += mismatch.TxDelta
+	}
+
+	if mismatch.TxDelta != expected || mismatch.BalanceDelta != mismatch.HistoryDelta || len(mismatch.Unexpected) > 0 {
+		return mismatch
+	}
+	return nil
+}
+
+// delta returns the change a successful transaction made to the balance of the
+// sender's accounts.
+func (s *effectsSnapshot) delta(data TransactionData) (delta int64) {
+	if data.TxState == TransactionStateFailed {
+		return 0
+	}
+
+	for _, p := range data.Payments {
+		if _, ok := s.accounts[string(p.Sender)]; ok {
+			delta -= p.Quarks
+		}
+		if _, ok := s.accounts[string(p.Destination)]; ok {
+			delta += p.Quarks
+		}
+	}
+	return delta
+}
@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	accountpbv4 "github.com/kinecosystem/agora-api/genproto/account/v4"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+	transactionpbv4 "github.com/kinecosystem/agora-api/genproto/transaction/v4"
+)
+
+type testTransfer struct {
+	source, dest kin.PublicKey
+	quarks       int64
+}
+
+func effectsTxID(name string) []byte {
+	id := sha256.Sum256([]byte(name))
+	return id[:]
+}
+
+func effectsHistoryItem(txID []byte, cursor byte, transfers ...testTransfer) *transactionpbv4.HistoryItem {
+	item := &transactionpbv4.HistoryItem{
+		TransactionId: &commonpbv4.TransactionId{Value: txID},
+		Cursor:        &transactionpbv4.Cursor{Value: []byte{cursor}},
+	}
+	for i, t := range transfers {
+		item.Payments = append(item.Payments, &transactionpbv4.HistoryItem_Payment{
+			Source:      &commonpbv4.SolanaAccountId{Value: t.source},
+			Destination: &commonpbv4.SolanaAccountId{Value: t.dest},
+			Amount:      t.quarks,
+			Index:       uint32(i),
+		})
+	}
+	return item
+}
+
+// setupEffects creates a sender with a balance of 100 quarks and a single
+// history item, and calls effects with the ID of each submitted transaction to
+// return the history items and balance change it causes.
+func setupEffects(t *testing.T, env *testEnv, effects func(txID []byte) ([]*transactionpbv4.HistoryItem, int64)) kin.PrivateKey {
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	other, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	env.v4Server.Mux.Lock()
+	defer env.v4Server.Mux.Unlock()
+
+	info := &accountpbv4.AccountInfo{
+		AccountId: &commonpbv4.SolanaAccountId{Value: sender.Public()},
+		Balance:   100,
+	}
+	env.v4Server.Accounts[base58.Encode(sender.Public())] = info
+	env.v4Server.History[string(sender.Public())] = []*transactionpbv4.HistoryItem{
+		effectsHistoryItem(effectsTxID("previous"), 0, testTransfer{sender.Public(), other.Public(), 10}),
+	}
+	env.v4Server.SubmitHook = func(_ []byte, sig []byte) {
+		items, delta := effects(sig)
+		env.v4Server.History[string(sender.Public())] = append(env.v4Server.History[string(sender.Public())], items...)
+		info.Balance += delta
+	}
+
+	return sender
+}
+
+func TestClient_VerifyEffects(t *testing.T) {
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	attacker, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name    string
+		effects func(sender kin.PublicKey, txID []byte) ([]*transactionpbv4.HistoryItem, int64)
+		check   func(t *testing.T, mismatch *EffectsMismatchError)
+	}{
+		{
+			name: "expected",
+			effects: func(sender kin.PublicKey, txID []byte) ([]*transactionpbv4.HistoryItem, int64) {
+				return []*transactionpbv4.HistoryItem{
+					effectsHistoryItem(txID, 1, testTransfer{sender, dest.Public(), 10}),
+				}, -10
+			},
+		},
+		{
+			name: "incoming payment",
+			effects: func(sender kin.PublicKey, txID []byte) ([]*transactionpbv4.HistoryItem, int64) {
+				return []*transactionpbv4.HistoryItem{
+					effectsHistoryItem(effectsTxID("incoming"), 1, testTransfer{attacker.Public(), sender, 3}),
+					effectsHistoryItem(txID, 2, testTransfer{sender, dest.Public(), 10}),
+				}, -7
+			},
+		},
+		{
+			name: "injected transfer",
+			effects: func(sender kin.PublicKey, txID []byte) ([]*transactionpbv4.HistoryItem, int64) {
+				return []*transactionpbv4.HistoryItem{
+					effectsHistoryItem(txID, 1,
+						testTransfer{sender, dest.Public(), 10},
+						testTransfer{sender, attacker.Public(), 5},
+					),
+				}, -15
+			},
+			check: func(t *testing.T, mismatch *EffectsMismatchError) {
+				assert.EqualValues(t, -10, mismatch.ExpectedDelta)
+				assert.EqualValues(t, -15, mismatch.TxDelta)
+				assert.EqualValues(t, -15, mismatch.BalanceDelta)
+				assert.Empty(t, mismatch.Unexpected)
+			},
+		},
+		{
+			name: "concurrent debit",
+			effects: func(sender kin.PublicKey, txID []byte) ([]*transactionpbv4.HistoryItem, int64) {
+				return []*transactionpbv4.HistoryItem{
+					effectsHistoryItem(txID, 1, testTransfer{sender, dest.Public(), 10}),
+					effectsHistoryItem(effectsTxID("drain"), 2, testTransfer{sender, attacker.Public(), 5}),
+				}, -15
+			},
+			check: func(t *testing.T, mismatch *EffectsMismatchError) {
+				assert.EqualValues(t, -10, mismatch.TxDelta)
+				assert.Equal(t, [][]byte{effectsTxID("drain")}, mismatch.Unexpected)
+			},
+		},
+		{
+			name: "unaccounted balance change",
+			effects: func(sender kin.PublicKey, txID []byte) ([]*transactionpbv4.HistoryItem, int64) {
+				return []*transactionpbv4.HistoryItem{
+					effectsHistoryItem(txID, 1, testTransfer{sender, dest.Public(), 10}),
+				}, -15
+			},
+			check: func(t *testing.T, mismatch *EffectsMismatchError) {
+				assert.EqualValues(t, -10, mismatch.TxDelta)
+				assert.EqualValues(t, -15, mismatch.BalanceDelta)
+				assert.EqualValues(t, -10, mismatch.HistoryDelta)
+				assert.Empty(t, mismatch.Unexpected)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env, cleanup := setup(t)
+			defer cleanup()
+
+			var sender kin.PrivateKey
+			sender = setupEffects(t, env, func(txID []byte) ([]*transactionpbv4.HistoryItem, int64) {
+				return tc.effects(sender.Public(), txID)
+			})
+
+			p := Payment{
+				Sender:      sender,
+				Destination: dest.Public(),
+				Type:        kin.TransactionTypeP2P,
+				Quarks:      10,
+			}
+			txID, err := env.client.SubmitPayment(context.Background(), p, WithVerifyEffects())
+			if tc.check == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			assert.Equal(t, ErrUnexpectedEffects, errors.Cause(err))
+			mismatch, ok := err.(*EffectsMismatchError)
+			require.True(t, ok)
+			assert.Equal(t, txID, mismatch.TxID)
+			tc.check(t, mismatch)
+		})
+	}
+}
+
+func TestClient_VerifyEffectsNotIndexed(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	// The transaction is not yet in the sender's history, so it is retrieved
+	// directly.
+	var sender kin.PrivateKey
+	sender = setupEffects(t, env, func(txID []byte) ([]*transactionpbv4.HistoryItem, int64) {
+		env.v4Server.Gets[string(txID)] = transactionpbv4.GetTransactionResponse{
+			State: transactionpbv4.GetTransactionResponse_SUCCESS,
+			Item:  effectsHistoryItem(txID, 1, testTransfer{sender.Public(), dest.Public(), 10}),
+		}
+		return nil, -10
+	})
+
+	p := Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeP2P,
+		Quarks:      10,
+	}
+	_, err = env.client.SubmitPayment(context.Background(), p, WithVerifyEffects())
+	require.NoError(t, err)
+}
+
+func TestClient_VerifyEffectsEarnBatch(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	earns := make([]Earn, 3)
+	for i := range earns {
+		dest, err := kin.NewPrivateKey()
+		require.NoError(t, err)
+		earns[i] = Earn{Destination: dest.Public(), Quarks: int64(i + 1)}
+	}
+
+	attacker, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	var sender kin.PrivateKey
+	sender = setupEffects(t, env, func(txID []byte) ([]*transactionpbv4.HistoryItem, int64) {
+		transfers := []testTransfer{{sender.Public(), attacker.Public(), 4}}
+		for _, e := range earns {
+			transfers = append(transfers, testTransfer{sender.Public(), e.Destination, e.Quarks})
+		}
+		return []*transactionpbv4.HistoryItem{effectsHistoryItem(txID, 1, transfers...)}, -10
+	})
+
+	result, err := env.client.SubmitEarnBatch(context.Background(), EarnBatch{
+		Sender: sender,
+		Earns:  earns,
+	}, WithVerifyEffects())
+	require.NoError(t, result.TxError)
+	assert.Equal(t, ErrUnexpectedEffects, errors.Cause(err))
+
+	mismatch, ok := err.(*EffectsMismatchError)
+	require.True(t, ok)
+	assert.Equal(t, result.TxID, mismatch.TxID)
+	assert.EqualValues(t, -6, mismatch.ExpectedDelta)
+	assert.EqualValues(t, -10, mismatch.TxDelta)
+	assert.EqualValues(t, -10, mismatch.BalanceDelta)
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin/version"
+	"github.com/pkg/errors"
+)
+
+// VersionChange is sent by VersionChanged when the blockchain version
+// negotiated with Agora changes.
+type VersionChange struct {
+	Previous version.KinVersion
+	Current  version.KinVersion
+}
+
+// WithMinimumKinVersion specifies the minimum blockchain version the client may
+// operate on. While Agora is using an older version (for example, if an app's
+// traffic is migrated back to Kin 3), every operation fails with a
+// *MinimumVersionError, rather than silently using legacy paths.
+//
+// The version is checked before the first operation, and then at most every
+// five minutes.
+func WithMinimumKinVersion(v version.KinVersion) ClientOption {
+	return func(o *clientOpts) {
+		o.minKinVersion = v
+	}
+}
+
+func (c *client) GetBlockchainVersion(ctx context.Context) (version.KinVersion, error) {
+	return c.internal.GetBlockchainVersion(ctx)
+}
+
+func (c *client) VersionChanged(ctx context.Context, interval time.Duration) (<-chan VersionChange, error) {
+	if interval <= 0 {
+		return nil, errors.Errorf("invalid interval: %v", interval)
+	}
+
+	// The initial version is fetched synchronously so that connectivity
+	// issues are reported to the caller.
+	current, err := c.GetBlockchainVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan VersionChange, 1)
+	go func() {
+		defer close(changes)
+
+		for {
+			select {
+			case <-c.opts.clock.After(interval):
+			case <-ctx.Done():
+				return
+			case <-c.lifecycle.ctx.Done():
+				return
+			}
+
+			v, err := c.GetBlockchainVersion(ctx)
+			if err != nil || v == current {
+				continue
+			}
+
+			select {
+			case changes <- VersionChange{Previous: current, Current: v}:
+			case <-ctx.Done():
+				return
+			case <-c.lifecycle.ctx.Done():
+				return
+			}
+			current = v
+		}
+	}()
+
+	return changes, nil
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/kin/version"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_VersionChanged(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	v, err := env.client.GetBlockchainVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, version.KinVersion4, v)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err = env.client.VersionChanged(ctx, 0)
+	assert.Error(t, err)
+
+	changes, err := env.client.VersionChanged(ctx, time.Millisecond)
+	require.NoError(t, err)
+
+	// No changes are sent while the version is unchanged.
+	select {
+	case <-changes:
+		t.Fatal("unexpected change")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	env.v4Server.Mux.Lock()
+	env.v4Server.KinVersion = 3
+	env.v4Server.Mux.Unlock()
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, VersionChange{Previous: version.KinVersion4, Current: version.KinVersion3}, change)
+	case <-time.After(time.Second):
+		t.Fatal("no change received")
+	}
+
+	cancel()
+	for range changes {
+	}
+}
+
+func TestClient_MinimumKinVersion(t *testing.T) {
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	env, cleanup := setup(t, WithClock(clock), WithMinimumKinVersion(version.KinVersion4))
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+
+	_, err := env.client.GetMinimumBalanceForRentExemption(context.Background(), 165)
+	require.NoError(t, err)
+
+	// The result of the check is cached.
+	env.v4Server.Mux.Lock()
+	env.v4Server.KinVersion = 3
+	env.v4Server.Mux.Unlock()
+
+	_, err = env.client.GetMinimumBalanceForRentExemption(context.Background(), 166)
+	require.NoError(t, err)
+
+	clock.advance(minVersionTTL)
+	_, err = env.client.GetMinimumBalanceForRentExemption(context.Background(), 167)
+	require.Error(t, err)
+	assert.Equal(t, ErrBlockchainVersion, errors.Cause(err))
+
+	var versionErr *MinimumVersionError
+	require.True(t, errors.As(err, &versionErr))
+	assert.Equal(t, MinimumVersionError{Minimum: version.KinVersion4, Version: version.KinVersion3}, *versionErr)
+
+	// Submissions are refused too.
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: sender.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      10,
+	})
+	assert.Equal(t, ErrBlockchainVersion, errors.Cause(err))
+
+	// The client operates again once the version is restored.
+	env.v4Server.Mux.Lock()
+	env.v4Server.KinVersion = 4
+	env.v4Server.Mux.Unlock()
+
+	clock.advance(minVersionTTL)
+	_, err = env.client.GetMinimumBalanceForRentExemption(context.Background(), 168)
+	assert.NoError(t, err)
+}
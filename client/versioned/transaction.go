@@ -0,0 +1,441 @@
+// Package versioned provides v0 Solana transactions, which may reference
+// accounts through address lookup tables.
+package versioned
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"io"
+
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/shortvec"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client/lookuptable"
+)
+
+const (
+	// versionPrefix is set on the first byte of a versioned message, which
+	// distinguishes it from a legacy message whose first byte is the number of
+	// signatures.
+	versionPrefix = 0x80
+
+	// maxAccounts is the maximum number of accounts a message can reference.
+	maxAccounts = 256
+)
+
+// AddressTableLookup references accounts in an address lookup table.
+type AddressTableLookup struct {
+	Table           ed25519.PublicKey
+	WritableIndexes []byte
+	ReadonlyIndexes []byte
+}
+
+// Message is a v0 message.
+//
+// Accounts referenced by compiled instructions are indexed by the static
+// accounts, followed by the writable accounts of each lookup, followed by the
+// readonly accounts of each lookup.
+type Message struct {
+	Header              solana.Header
+	Accounts            []ed25519.PublicKey
+	RecentBlockhash     solana.Blockhash
+	Instructions        []solana.CompiledInstruction
+	AddressTableLookups []AddressTableLookup
+}
+
+// Transaction is a v0 transaction.
+type Transaction struct {
+	Signatures []solana.Signature
+	Message    Message
+}
+
+type accountMeta struct {
+	key        ed25519.PublicKey
+	isSigner   bool
+	isWritable bool
+	isInvoked  bool
+}
+
+// NewTransaction returns a v0 transaction paid for by payer. Accounts contained
+// in tables are referenced through the table, unless they sign the transaction
+// or are the program of an instruction, which must be referenced directly.
+func NewTransaction(payer ed25519.PublicKey, instructions []solana.Instruction, tables ...lookuptable.Table) (Transaction, error) {
+	metas := []*accountMeta{{key: payer, isSigner: true, isWritable: true}}
+	find := func(key ed25519.PublicKey) *accountMeta {
+		for _, m := range metas {
+			if bytes.Equal(m.key, key) {
+				return m
+			}
+		}
+		m := &accountMeta{key: key}
+		metas = append(metas, m)
+		return m
+	}
+
+	for _, i := range instructions {
+		find(i.Program).isInvoked = true
+		for _, a := range i.Accounts {
+			m := find(a.PublicKey)
+			m.isSigner = m.isSigner || a.IsSigner
+			m.isWritable = m.isWritable || a.IsWritable
+		}
+	}
+
+	// Static accounts are ordered as in a legacy message: writable signers,
+	// readonly signers, writable non-signers, then readonly non-signers.
+	var static [4][]ed25519.PublicKey
+	lookups := make([]AddressTableLookup, len(tables))
+	for _, m := range metas {
+		if !m.isSigner && !m.isInvoked && lookup(m, tables, lookups) {
+			continue
+		}
+
+		switch {
+		case m.isSigner && m.isWritable:
+			static[0] = append(static[0], m.key)
+		case m.isSigner:
+			static[1] = append(static[1], m.key)
+		case m.isWritable:
+			static[2] = append(static[2], m.key)
+		default:
+			static[3] = append(static[3], m.key)
+		}
+	}
+
+	var msg Message
+	for _, keys := range static {
+		msg.Accounts = append(msg.Accounts, keys...)
+	}
+	msg.Header = solana.Header{
+		NumSignatures:     byte(len(static[0]) + len(static[1])),
+		NumReadonlySigned: byte(len(static[1])),
+		NumReadOnly:       byte(len(static[3])),
+	}
+	var used []lookuptable.Table
+	for t, l := range lookups {
+		if len(l.WritableIndexes) > 0 || len(l.ReadonlyIndexes) > 0 {
+			msg.AddressTableLookups = append(msg.AddressTableLookups, l)
+			used = append(used, tables[t])
+		}
+	}
+
+	keys, err := msg.AccountKeys(used...)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if len(keys) > maxAccounts {
+		return Transaction{}, errors.Errorf("transaction references too many accounts: %d", len(keys))
+	}
+
+	for _, i := range instructions {
+		c := solana.CompiledInstruction{
+			ProgramIndex: byte(indexOf(keys, i.Program)),
+			Data:         i.Data,
+		}
+		for _, a := range i.Accounts {
+			c.Accounts = append(c.Accounts, byte(indexOf(keys, a.PublicKey)))
+		}
+		msg.Instructions = append(msg.Instructions, c)
+	}
+
+	return Transaction{
+		Signatures: make([]solana.Signature, msg.Header.NumSignatures),
+		Message:    msg,
+	}, nil
+}
+
+// FromLegacy returns a v0 transaction with the same accounts and instructions
+// as tx, which does not use any lookup tables. The signatures of tx are not
+// copied, since they do not sign the versioned message.
+func FromLegacy(tx solana.Transaction) Transaction {
+	return Transaction{
+		Signatures: make([]solana.Signature, len(tx.Signatures)),
+		Message: Message{
+			Header:          tx.Message.Header,
+			Accounts:        tx.Message.Accounts,
+			RecentBlockhash: tx.Message.RecentBlockhash,
+			Instructions:    tx.Message.Instructions,
+		},
+	}
+}
+
+// lookup adds m to the lookup of the first table containing it, returning
+// whether one did.
+func lookup(m *accountMeta, tables []lookuptable.Table, lookups []AddressTableLookup) bool {
+	for t, table := range tables {
+		index := table.IndexOf(m.key)
+		if index < 0 {
+			continue
+		}
+
+		lookups[t].Table = table.Address
+		if m.isWritable {
+			lookups[t].WritableIndexes = append(lookups[t].WritableIndexes, byte(index))
+		} else {
+			lookups[t].ReadonlyIndexes = append(lookups[t].ReadonlyIndexes, byte(index))
+		}
+		return true
+	}
+
+	return false
+}
+
+// Signature returns the signature of the fee payer, which identifies the
+// transaction.
+func (t *Transaction) Signature() []byte {
+	return t.Signatures[0][:]
+}
+
+// SetBlockhash sets the recent blockhash of the transaction.
+func (t *Transaction) SetBlockhash(bh solana.Blockhash) {
+	t.Message.RecentBlockhash = bh
+}
+
+// Sign signs the transaction with each of signers, which must be signers of
+// the transaction.
+func (t *Transaction) Sign(signers ...ed25519.PrivateKey) error {
+	messageBytes := t.Message.Marshal()
+
+	for _, s := range signers {
+		pub := s.Public().(ed25519.PublicKey)
+		index := indexOf(t.Message.Accounts, pub)
+		if index < 0 {
+			return errors.Errorf("signing account %s is not in the account list", base58.Encode(pub))
+		}
+		if index >= len(t.Signatures) {
+			return errors.Errorf("signing account %s is not in the list of signers", base58.Encode(pub))
+		}
+
+		copy(t.Signatures[index][:], ed25519.Sign(s, messageBytes))
+	}
+
+	return nil
+}
+
+// AccountKeys returns the keys of the accounts referenced by the message, in
+// index order, resolving looked up accounts with tables.
+func (m Message) AccountKeys(tables ...lookuptable.Table) ([]ed25519.PublicKey, error) {
+	keys := append([]ed25519.PublicKey{}, m.Accounts...)
+	var readonly []ed25519.PublicKey
+
+	for _, l := range m.AddressTableLookups {
+		var table *lookuptable.Table
+		for i := range tables {
+			if bytes.Equal(tables[i].Address, l.Table) {
+				table = &tables[i]
+				break
+			}
+		}
+		if table == nil {
+			return nil, errors.Errorf("missing lookup table %s", base58.Encode(l.Table))
+		}
+
+		resolve := func(indexes []byte) ([]ed25519.PublicKey, error) {
+			resolved := make([]ed25519.PublicKey, len(indexes))
+			for i, index := range indexes {
+				if int(index) >= len(table.Addresses) {
+					return nil, errors.Errorf("lookup index out of range: %d", index)
+				}
+				resolved[i] = table.Addresses[index]
+			}
+			return resolved, nil
+		}
+
+		writable, err := resolve(l.WritableIndexes)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, writable...)
+
+		ro, err := resolve(l.ReadonlyIndexes)
+		if err != nil {
+			return nil, err
+		}
+		readonly = append(readonly, ro...)
+	}
+
+	return append(keys, readonly...), nil
+}
+
+func (t Transaction) Marshal() []byte {
+	b := bytes.NewBuffer(nil)
+
+	_, _ = shortvec.EncodeLen(b, len(t.Signatures))
+	for _, s := range t.Signatures {
+		_, _ = b.Write(s[:])
+	}
+	_, _ = b.Write(t.Message.Marshal())
+
+	return b.Bytes()
+}
+
+func (t *Transaction) Unmarshal(b []byte) error {
+	buf := bytes.NewBuffer(b)
+
+	sigLen, err := shortvec.DecodeLen(buf)
+	if err != nil {
+		return errors.Wrap(err, "failed to read signature length")
+	}
+
+	t.Signatures = make([]solana.Signature, sigLen)
+	for i := 0; i < sigLen; i++ {
+		if _, err = io.ReadFull(buf, t.Signatures[i][:]); err != nil {
+			return errors.Wrapf(err, "failed to read signature at %d", i)
+		}
+	}
+
+	return (&t.Message).Unmarshal(buf.Bytes())
+}
+
+func (m Message) Marshal() []byte {
+	b := bytes.NewBuffer(nil)
+
+	_ = b.WriteByte(versionPrefix)
+
+	// The remainder of the message is encoded as a legacy message, followed
+	// by the lookups.
+	_, _ = b.Write(solana.Message{
+		Header:          m.Header,
+		Accounts:        m.Accounts,
+		RecentBlockhash: m.RecentBlockhash,
+		Instructions:    m.Instructions,
+	}.Marshal())
+
+	_, _ = shortvec.EncodeLen(b, len(m.AddressTableLookups))
+	for _, l := range m.AddressTableLookups {
+		_, _ = b.Write(l.Table)
+
+		_, _ = shortvec.EncodeLen(b, len(l.WritableIndexes))
+		_, _ = b.Write(l.WritableIndexes)
+
+		_, _ = shortvec.EncodeLen(b, len(l.ReadonlyIndexes))
+		_, _ = b.Write(l.ReadonlyIndexes)
+	}
+
+	return b.Bytes()
+}
+
+func (m *Message) Unmarshal(b []byte) (err error) {
+	buf := bytes.NewBuffer(b)
+
+	prefix, err := buf.ReadByte()
+	if err != nil {
+		return errors.Wrap(err, "failed to read version")
+	}
+	if prefix&versionPrefix == 0 {
+		return errors.New("message is not versioned")
+	}
+	if version := prefix &^ versionPrefix; version != 0 {
+		return errors.Errorf("unsupported message version: %d", version)
+	}
+
+	if m.Header.NumSignatures, err = buf.ReadByte(); err != nil {
+		return errors.Wrap(err, "failed to read num signatures")
+	}
+	if m.Header.NumReadonlySigned, err = buf.ReadByte(); err != nil {
+		return errors.Wrap(err, "failed to read num readonly signatures")
+	}
+	if m.Header.NumReadOnly, err = buf.ReadByte(); err != nil {
+		return errors.Wrap(err, "failed to read num readonly")
+	}
+
+	accountLen, err := shortvec.DecodeLen(buf)
+	if err != nil {
+		return errors.Wrap(err, "failed to read account len")
+	}
+	m.Accounts = make([]ed25519.PublicKey, accountLen)
+	for i := 0; i < accountLen; i++ {
+		m.Accounts[i] = make([]byte, ed25519.PublicKeySize)
+		if _, err = io.ReadFull(buf, m.Accounts[i]); err != nil {
+			return errors.Wrapf(err, "failed to read account at index %d", i)
+		}
+	}
+
+	if _, err = io.ReadFull(buf, m.RecentBlockhash[:]); err != nil {
+		return errors.Wrap(err, "failed to read recent block hash")
+	}
+
+	instructionLen, err := shortvec.DecodeLen(buf)
+	if err != nil {
+		return errors.Wrap(err, "failed to read instruction len")
+	}
+	m.Instructions = make([]solana.CompiledInstruction, instructionLen)
+	for i := 0; i < instructionLen; i++ {
+		var c solana.CompiledInstruction
+
+		if c.ProgramIndex, err = buf.ReadByte(); err != nil {
+			return errors.Wrapf(err, "failed to read instruction[%d] program index", i)
+		}
+		if int(c.ProgramIndex) >= len(m.Accounts) {
+			return errors.Errorf("program index out of range: %d:%d", i, c.ProgramIndex)
+		}
+
+		if c.Accounts, err = readBytes(buf); err != nil {
+			return errors.Wrapf(err, "failed to read instruction[%d] accounts", i)
+		}
+		if c.Data, err = readBytes(buf); err != nil {
+			return errors.Wrapf(err, "failed to read instruction[%d] data", i)
+		}
+
+		m.Instructions[i] = c
+	}
+
+	lookupLen, err := shortvec.DecodeLen(buf)
+	if err != nil {
+		return errors.Wrap(err, "failed to read lookup len")
+	}
+	m.AddressTableLookups = make([]AddressTableLookup, lookupLen)
+	for i := 0; i < lookupLen; i++ {
+		l := AddressTableLookup{Table: make([]byte, ed25519.PublicKeySize)}
+		if _, err = io.ReadFull(buf, l.Table); err != nil {
+			return errors.Wrapf(err, "failed to read lookup[%d] table", i)
+		}
+		if l.WritableIndexes, err = readBytes(buf); err != nil {
+			return errors.Wrapf(err, "failed to read lookup[%d] writable indexes", i)
+		}
+		if l.ReadonlyIndexes, err = readBytes(buf); err != nil {
+			return errors.Wrapf(err, "failed to read lookup[%d] readonly indexes", i)
+		}
+
+		m.AddressTableLookups[i] = l
+	}
+
+	return nil
+}
+
+// IsVersioned returns whether the serialized transaction b contains a
+// versioned message.
+func IsVersioned(b []byte) bool {
+	buf := bytes.NewBuffer(b)
+	sigLen, err := shortvec.DecodeLen(buf)
+	if err != nil || buf.Len() <= sigLen*ed25519.SignatureSize {
+		return false
+	}
+
+	return buf.Bytes()[sigLen*ed25519.SignatureSize]&versionPrefix != 0
+}
+
+func readBytes(buf *bytes.Buffer) ([]byte, error) {
+	n, err := shortvec.DecodeLen(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(buf, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func indexOf(keys []ed25519.PublicKey, key ed25519.PublicKey) int {
+	for i, k := range keys {
+		if bytes.Equal(k, key) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,113 @@
+package versioned
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client/lookuptable"
+)
+
+func generateKey(t *testing.T) ed25519.PublicKey {
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	return pub
+}
+
+func TestNewTransaction(t *testing.T) {
+	payer, payerKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	owner, ownerKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	source := generateKey(t)
+
+	dests := make([]ed25519.PublicKey, 4)
+	for i := range dests {
+		dests[i] = generateKey(t)
+	}
+
+	tables := []lookuptable.Table{
+		{Address: generateKey(t), Addresses: []ed25519.PublicKey{generateKey(t), dests[2], dests[0]}},
+		{Address: generateKey(t), Addresses: []ed25519.PublicKey{dests[1], owner}},
+		{Address: generateKey(t), Addresses: []ed25519.PublicKey{generateKey(t)}},
+	}
+
+	instructions := []solana.Instruction{memo.Instruction("test")}
+	for _, dest := range dests {
+		instructions = append(instructions, token.Transfer(source, dest, owner, 10))
+	}
+
+	tx, err := NewTransaction(payer, instructions, tables...)
+	require.NoError(t, err)
+
+	// Signers and programs are static, as are accounts not in any table.
+	assert.Equal(t, []ed25519.PublicKey{payer, owner, source, dests[3], memo.ProgramKey, token.ProgramKey}, tx.Message.Accounts)
+	assert.Equal(t, solana.Header{NumSignatures: 2, NumReadonlySigned: 1, NumReadOnly: 2}, tx.Message.Header)
+	assert.Equal(t, []AddressTableLookup{
+		{Table: tables[0].Address, WritableIndexes: []byte{2, 1}},
+		{Table: tables[1].Address, WritableIndexes: []byte{0}},
+	}, tx.Message.AddressTableLookups)
+
+	keys, err := tx.Message.AccountKeys(tables...)
+	require.NoError(t, err)
+	assert.Equal(t, []ed25519.PublicKey{payer, owner, source, dests[3], memo.ProgramKey, token.ProgramKey, dests[0], dests[2], dests[1]}, keys)
+
+	for i, dest := range dests {
+		compiled := tx.Message.Instructions[i+1]
+		assert.Equal(t, token.ProgramKey, keys[compiled.ProgramIndex])
+		assert.Equal(t, []ed25519.PublicKey{source, dest, owner}, []ed25519.PublicKey{
+			keys[compiled.Accounts[0]],
+			keys[compiled.Accounts[1]],
+			keys[compiled.Accounts[2]],
+		})
+	}
+
+	require.NoError(t, tx.Sign(payerKey, ownerKey))
+	assert.True(t, ed25519.Verify(payer, tx.Message.Marshal(), tx.Signature()))
+	assert.True(t, ed25519.Verify(owner, tx.Message.Marshal(), tx.Signatures[1][:]))
+	assert.Error(t, tx.Sign(func() ed25519.PrivateKey { _, k, _ := ed25519.GenerateKey(nil); return k }()))
+
+	// Round trip.
+	b := tx.Marshal()
+	assert.True(t, IsVersioned(b))
+
+	var parsed Transaction
+	require.NoError(t, parsed.Unmarshal(b))
+	assert.Equal(t, tx.Signatures, parsed.Signatures)
+	assert.Equal(t, tx.Message.Accounts, parsed.Message.Accounts)
+	assert.Equal(t, b, parsed.Marshal())
+
+	legacy := solana.NewTransaction(payer, instructions...)
+	assert.False(t, IsVersioned(legacy.Marshal()))
+	assert.Error(t, parsed.Unmarshal(legacy.Marshal()))
+
+	// Lookups save space.
+	assert.Less(t, len(b), len(legacy.Marshal()))
+
+	_, err = tx.Message.AccountKeys(tables[0])
+	assert.Error(t, err)
+}
+
+func TestNewTransaction_NoTables(t *testing.T) {
+	payer := generateKey(t)
+	owner := generateKey(t)
+	source := generateKey(t)
+	dest := generateKey(t)
+
+	instructions := []solana.Instruction{token.Transfer(source, dest, owner, 10)}
+	tx, err := NewTransaction(payer, instructions)
+	require.NoError(t, err)
+
+	legacy := solana.NewTransaction(payer, instructions...)
+	assert.Equal(t, legacy.Message.Header, tx.Message.Header)
+	assert.Equal(t, legacy.Message.Accounts, tx.Message.Accounts)
+	assert.Equal(t, legacy.Message.Instructions, tx.Message.Instructions)
+	assert.Empty(t, tx.Message.AddressTableLookups)
+	assert.Equal(t, tx, FromLegacy(legacy))
+	assert.Equal(t, append([]byte{versionPrefix}, legacy.Message.Marshal()...), tx.Message.Marshal()[:len(legacy.Message.Marshal())+1])
+}
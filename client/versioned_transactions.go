@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"strings"
+	"sync/atomic"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+
+	"github.com/kinecosystem/kin-go/client/lookuptable"
+	"github.com/kinecosystem/kin-go/client/versioned"
+)
+
+// WithVersionedTransactions specifies that payments and earn batches should be
+// submitted as versioned (v0) transactions, rather than legacy transactions.
+//
+// Agora is assumed to support versioned transactions until it rejects one as
+// unimplemented, or as having an unsupported version, after which the client
+// submits legacy transactions instead. Earn batches that only fit in a versioned transaction
+// (see WithAddressLookupTables) fail with ErrVersionedTransactionsUnsupported.
+func WithVersionedTransactions() SolanaOption {
+	return func(o *solanaOpts) {
+		o.versioned = true
+	}
+}
+
+const (
+	versionedSupportUnknown int32 = iota
+	versionedSupportYes
+	versionedSupportNo
+)
+
+// versionedSupport tracks whether Agora supports versioned transactions, which
+// is detected from the result of submitting them.
+type versionedSupport struct {
+	state int32
+}
+
+func (s *versionedSupport) isUnsupported() bool {
+	return atomic.LoadInt32(&s.state) == versionedSupportNo
+}
+
+// record records the result of a versioned transaction, returning whether it
+// was rejected because Agora does not support versioned transactions. Once a
+// versioned transaction has been accepted, Agora is assumed to support them.
+func (s *versionedSupport) record(err error) bool {
+	if err == nil {
+		atomic.CompareAndSwapInt32(&s.state, versionedSupportUnknown, versionedSupportYes)
+		return false
+	}
+
+	if !isVersionUnsupported(err) {
+		return false
+	}
+
+	return atomic.CompareAndSwapInt32(&s.state, versionedSupportUnknown, versionedSupportNo)
+}
+
+// isVersionUnsupported returns whether err indicates that Agora does not
+// support versioned transactions, either because the method is unimplemented,
+// or because the transaction was rejected for its version. Other invalid
+// argument errors may be caused by a single malformed request, so they do not.
+func isVersionUnsupported(err error) bool {
+	s, ok := status.FromError(errors.Cause(err))
+	if !ok {
+		return false
+	}
+
+	switch s.Code() {
+	case codes.Unimplemented:
+		return true
+	case codes.InvalidArgument:
+		msg := strings.ToLower(s.Message())
+		return strings.Contains(msg, "version") && (strings.Contains(msg, "unsupported") || strings.Contains(msg, "not supported"))
+	default:
+		return false
+	}
+}
+
+// submitVersioned submits tx as a versioned transaction if Agora may support
+// them. If it does not, the transaction must be submitted as a legacy
+// transaction instead, which is indicated by the returned bool.
+func (c *client) submitVersioned(ctx context.Context, signers []kin.PrivateKey, tx versioned.Transaction, commitment commonpbv4.Commitment, il *commonpb.InvoiceList, dedupeID []byte, progress stageFunc) (SubmitTransactionResult, bool, error) {
+	if c.versionedSupport.isUnsupported() {
+		return SubmitTransactionResult{}, false, nil
+	}
+
+	result, err := c.signAndSubmit(ctx, signers, versionedTx{tx: &tx, tables: c.opts.lookupTables}, commitment, il, dedupeID, progress)
+	if c.versionedSupport.record(err) {
+		return SubmitTransactionResult{}, false, nil
+	}
+
+	return result, true, err
+}
+
+// versionedTx is a submittable versioned transaction. Its looked up accounts
+// are resolved with tables for hooks and audit records.
+type versionedTx struct {
+	tx     *versioned.Transaction
+	tables []lookuptable.Table
+}
+
+func (t versionedTx) payer() ed25519.PublicKey                { return t.tx.Message.Accounts[0] }
+func (t versionedTx) setBlockhash(blockhash solana.Blockhash) { t.tx.SetBlockhash(blockhash) }
+func (t versionedTx) sign(keys ...ed25519.PrivateKey) error   { return t.tx.Sign(keys...) }
+func (t versionedTx) payerSignature() *solana.Signature       { return &t.tx.Signatures[0] }
+
+func (t versionedTx) remoteSign(ctx context.Context, c *InternalClient, il *commonpb.InvoiceList) (SignTransactionResult, error) {
+	return c.SignVersionedTransaction(ctx, *t.tx, il)
+}
+
+func (t versionedTx) submit(ctx context.Context, c *InternalClient, il *commonpb.InvoiceList, commitment commonpbv4.Commitment, dedupeID []byte) (SubmitTransactionResult, error) {
+	return c.SubmitVersionedTransaction(ctx, *t.tx, il, commitment, dedupeID)
+}
+
+// view returns the transaction as a legacy transaction whose accounts include
+// the looked up accounts, so that its instructions can be decompiled. If an
+// account cannot be resolved, only the static accounts are included.
+func (t versionedTx) view() solana.Transaction {
+	accounts, err := t.tx.Message.AccountKeys(t.tables...)
+	if err != nil {
+		accounts = t.tx.Message.Accounts
+	}
+
+	return solana.Transaction{
+		Signatures: t.tx.Signatures,
+		Message: solana.Message{
+			Header:          t.tx.Message.Header,
+			Accounts:        accounts,
+			RecentBlockhash: t.tx.Message.RecentBlockhash,
+			Instructions:    t.tx.Message.Instructions,
+		},
+	}
+}
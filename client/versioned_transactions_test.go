@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kinecosystem/kin-go/client/lookuptable"
+	"github.com/kinecosystem/kin-go/client/versioned"
+)
+
+func TestClient_VersionedTransactions(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	setServiceConfigResp(t, env.v4Server, true)
+	for _, acc := range [][]byte{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	p := Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+	}
+	batch := EarnBatch{
+		Sender: sender,
+		Earns:  []Earn{{Destination: dest.Public(), Quarks: 11}},
+	}
+
+	submitted := func() (isVersioned []bool) {
+		env.v4Server.Mux.Lock()
+		defer env.v4Server.Mux.Unlock()
+
+		for _, submit := range env.v4Server.Submits {
+			isVersioned = append(isVersioned, versioned.IsVersioned(submit.Transaction.Value))
+			if versioned.IsVersioned(submit.Transaction.Value) {
+				var tx versioned.Transaction
+				require.NoError(t, tx.Unmarshal(submit.Transaction.Value))
+				assert.True(t, ed25519.Verify(tx.Message.Accounts[0], tx.Message.Marshal(), tx.Signature()))
+			}
+		}
+		env.v4Server.Submits = nil
+		return isVersioned
+	}
+
+	// Legacy transactions are the default.
+	_, err = env.client.SubmitPayment(context.Background(), p)
+	require.NoError(t, err)
+	_, err = env.client.SubmitEarnBatch(context.Background(), batch)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false, false}, submitted())
+
+	txID, err := env.client.SubmitPayment(context.Background(), p, WithVersionedTransactions())
+	require.NoError(t, err)
+	result, err := env.client.SubmitEarnBatch(context.Background(), batch, WithVersionedTransactions())
+	require.NoError(t, err)
+	assert.NotNil(t, txID)
+	assert.NotNil(t, result.TxID)
+	assert.Equal(t, []bool{true, true}, submitted())
+
+	// Once a versioned transaction was accepted, Agora is assumed to support them.
+	assert.False(t, env.client.versionedSupport.record(status.Error(codes.InvalidArgument, "")))
+	assert.False(t, env.client.versionedSupport.isUnsupported())
+}
+
+func TestClient_VersionedTransactionsUnsupported(t *testing.T) {
+	env, cleanup := setup(t)
+	defer cleanup()
+
+	setServiceConfigResp(t, env.v4Server, true)
+	env.v4Server.Mux.Lock()
+	env.v4Server.VersionedUnsupported = true
+	env.v4Server.Mux.Unlock()
+
+	batch := generateLargeEarnBatch(t, env, MaxBatchSize+1)
+	table := lookuptable.Table{Address: make([]byte, 32)}
+	for _, e := range batch.Earns {
+		table.Addresses = append(table.Addresses, ed25519.PublicKey(e.Destination))
+	}
+	WithAddressLookupTables(table)(&env.client.opts)
+
+	// Batches that only fit in a versioned transaction cannot fall back.
+	_, err := env.client.SubmitEarnBatch(context.Background(), batch)
+	assert.True(t, errors.Is(err, ErrVersionedTransactionsUnsupported))
+	assert.True(t, env.client.versionedSupport.isUnsupported())
+
+	// Other transactions are submitted as legacy transactions instead.
+	_, err = env.client.SubmitEarnBatches(context.Background(), batch, WithVersionedTransactions())
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	require.Len(t, env.v4Server.Submits, 2)
+	for _, submit := range env.v4Server.Submits {
+		assert.False(t, versioned.IsVersioned(submit.Transaction.Value))
+	}
+	env.v4Server.Mux.Unlock()
+
+	// Unrelated errors do not affect detection.
+	var s versionedSupport
+	assert.False(t, s.record(errors.New("unexpected")))
+	assert.False(t, s.record(status.Error(codes.Internal, "")))
+	assert.False(t, s.record(status.Error(codes.InvalidArgument, "failed to unmarshal tx")))
+	assert.False(t, s.isUnsupported())
+	assert.True(t, s.record(errors.Wrap(status.Error(codes.Unimplemented, ""), "failed to submit transaction")))
+	assert.True(t, s.isUnsupported())
+
+	s = versionedSupport{}
+	assert.True(t, s.record(status.Error(codes.InvalidArgument, "transaction version not supported")))
+	assert.True(t, s.isUnsupported())
+}
@@ -0,0 +1,275 @@
+// Package wallet provides a simplified interface to a single Kin account, for
+// apps (such as bots and mobile backends) that hold keys on behalf of their
+// users, and would rather work with Kin amounts than quarks and transactions.
+//
+// Kin amounts are decimal strings. Amounts returned by a Wallet are formatted
+// by kin.FromQuarks, with five decimal places (such as "1.50000").
+package wallet
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+// Direction is the direction of a Transfer, relative to the wallet.
+type Direction int
+
+const (
+	DirectionReceived Direction = iota
+	DirectionSent
+)
+
+// Transfer is a payment to or from the wallet.
+type Transfer struct {
+	TxID      []byte
+	Time      time.Time
+	Direction Direction
+
+	// Counterparty is the sender of a received transfer, or the destination of
+	// a sent transfer.
+	Counterparty kin.PublicKey
+
+	// Amount is the amount of the transfer, in Kin, and Quarks is the same
+	// amount in quarks.
+	Amount string
+	Quarks int64
+
+	// Note is the text memo of the transfer, if any.
+	Note string
+
+	// Failed is set if the transaction containing the transfer failed, in
+	// which case no funds were moved.
+	Failed bool
+}
+
+// BalanceChange is passed to the handler of BalanceChanged when the balance of
+// the wallet changes. Balances are in Kin.
+type BalanceChange struct {
+	// TxID is the transaction that triggered the change.
+	TxID []byte
+
+	Previous string
+	Current  string
+}
+
+// Wallet is a Kin account, whose funds are controlled by the wallet's key. It is
+// safe for concurrent use.
+type Wallet struct {
+	client client.Client
+	key    kin.PrivateKey
+	opts   opts
+
+	mu       sync.Mutex
+	accounts map[string]struct{}
+}
+
+type opts struct {
+	txType     kin.TransactionType
+	solanaOpts []client.SolanaOption
+}
+
+// Option configures a Wallet.
+type Option func(*opts)
+
+// WithTransactionType specifies the type of the payments sent by the wallet.
+// Defaults to kin.TransactionTypeP2P.
+func WithTransactionType(txType kin.TransactionType) Option {
+	return func(o *opts) {
+		o.txType = txType
+	}
+}
+
+// WithSolanaOptions specifies options used for every call the wallet makes to
+// the client, such as client.WithCommitment.
+func WithSolanaOptions(solanaOpts ...client.SolanaOption) Option {
+	return func(o *opts) {
+		o.solanaOpts = append(o.solanaOpts, solanaOpts...)
+	}
+}
+
+// New returns a Wallet for the account of key. The account must already exist
+// (see client.Client.CreateAccount).
+func New(c client.Client, key kin.PrivateKey, options ...Option) *Wallet {
+	w := &Wallet{
+		client: c,
+		key:    key,
+		opts: opts{
+			txType: kin.TransactionTypeP2P,
+		},
+	}
+	for _, o := range options {
+		o(&w.opts)
+	}
+
+	return w
+}
+
+// Address returns the address of the wallet, which can be shared with others
+// to receive Kin.
+func (w *Wallet) Address() kin.PublicKey {
+	return w.key.Public()
+}
+
+// Balance returns the balance of the wallet, in Kin.
+func (w *Wallet) Balance(ctx context.Context) (string, error) {
+	quarks, err := w.client.GetBalance(ctx, w.Address(), w.opts.solanaOpts...)
+	if err != nil {
+		return "", err
+	}
+	return kin.FromQuarks(quarks), nil
+}
+
+// Send sends kinAmount (such as "1.5") Kin to the specified address, with an
+// optional note, returning the ID of the transaction.
+//
+// An error whose cause is client.ErrInvalidAmount is returned if kinAmount is
+// not a valid, positive amount.
+func (w *Wallet) Send(ctx context.Context, to kin.PublicKey, kinAmount, note string) ([]byte, error) {
+	quarks, err := kin.ToQuarks(kinAmount)
+	if err != nil {
+		return nil, errors.Wrapf(client.ErrInvalidAmount, "invalid kin amount %q", kinAmount)
+	}
+
+	return w.client.SubmitPayment(ctx, client.Payment{
+		Sender:      w.key,
+		Destination: to,
+		Type:        w.opts.txType,
+		Quarks:      quarks,
+		Memo:        note,
+	}, w.opts.solanaOpts...)
+}
+
+// History returns a page of the transfers to and from the wallet, oldest first,
+// starting after cursor, or from the start of the history if cursor is nil.
+//
+// The returned cursor can be passed to History to retrieve the next page. It is
+// nil if there are no more transfers.
+func (w *Wallet) History(ctx context.Context, cursor client.Cursor) (transfers []Transfer, next client.Cursor, err error) {
+	items, err := w.client.GetHistory(ctx, w.Address(), cursor, w.opts.solanaOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil, nil
+	}
+
+	accounts, err := w.ownAccounts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, item := range items {
+		transfers = append(transfers, transfersOf(item.TransactionData, item.Time, accounts)...)
+	}
+	return transfers, items[len(items)-1].Cursor, nil
+}
+
+// BalanceChanged calls handler whenever a transaction changes the balance of
+// the wallet, until ctx is done or the transaction stream fails. Handlers are
+// called sequentially, and only for transactions that occur after the call.
+//
+// BalanceChanged blocks until it returns, so it is typically run in its own
+// goroutine. It returns ctx.Err() once ctx is done.
+func (w *Wallet) BalanceChanged(ctx context.Context, handler func(BalanceChange)) error {
+	current, err := w.client.GetBalance(ctx, w.Address(), w.opts.solanaOpts...)
+	if err != nil {
+		return err
+	}
+
+	// Start the stream from the most recent transaction, so that as little of
+	// the history as possible is replayed.
+	latest, err := w.client.GetHistory(ctx, w.Address(), nil, append(w.opts.solanaOpts, client.WithDescending())...)
+	if err != nil {
+		return err
+	}
+	var since client.Cursor
+	if len(latest) > 0 {
+		since = latest[0].Cursor
+	}
+
+	stream, err := w.client.Subscribe(ctx, w.Address(), since, w.opts.solanaOpts...)
+	if err != nil {
+		return err
+	}
+
+	for result := range stream {
+		if result.Err != nil {
+			return result.Err
+		}
+		if !result.Live {
+			continue
+		}
+
+		balance, err := w.client.GetBalance(ctx, w.Address(), w.opts.solanaOpts...)
+		if err != nil {
+			return err
+		}
+		if balance == current {
+			continue
+		}
+
+		handler(BalanceChange{
+			TxID:     result.Item.TxID,
+			Previous: kin.FromQuarks(current),
+			Current:  kin.FromQuarks(balance),
+		})
+		current = balance
+	}
+
+	return ctx.Err()
+}
+
+// ownAccounts returns the accounts of the wallet, which are its address and any
+// token accounts it owns.
+func (w *Wallet) ownAccounts(ctx context.Context) (map[string]struct{}, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.accounts != nil {
+		return w.accounts, nil
+	}
+
+	tokenAccounts, err := w.client.ResolveTokenAccounts(ctx, w.Address())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve token accounts")
+	}
+
+	w.accounts = map[string]struct{}{string(w.Address()): {}}
+	for _, account := range tokenAccounts {
+		w.accounts[string(account)] = struct{}{}
+	}
+	return w.accounts, nil
+}
+
+func transfersOf(data client.TransactionData, t time.Time, accounts map[string]struct{}) []Transfer {
+	var transfers []Transfer
+	for _, p := range data.Payments {
+		transfer := Transfer{
+			TxID:   data.TxID,
+			Time:   t,
+			Amount: kin.FromQuarks(p.Quarks),
+			Quarks: p.Quarks,
+			Note:   p.Memo,
+			Failed: data.TxState == client.TransactionStateFailed,
+		}
+
+		if _, ok := accounts[string(p.Sender)]; ok {
+			transfer.Direction = DirectionSent
+			transfer.Counterparty = p.Destination
+		} else if _, ok := accounts[string(p.Destination)]; ok {
+			transfer.Direction = DirectionReceived
+			transfer.Counterparty = p.Sender
+		} else {
+			continue
+		}
+
+		transfers = append(transfers, transfer)
+	}
+	return transfers
+}
@@ -0,0 +1,111 @@
+package wallet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+	"github.com/kinecosystem/kin-go/client/clientfake"
+)
+
+func newWallet(t *testing.T, fake *clientfake.Fake, kinBalance string) *Wallet {
+	key, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	fake.SetBalance(key.Public(), kin.MustToQuarks(kinBalance))
+	return New(fake, key)
+}
+
+func TestWallet(t *testing.T) {
+	fake := clientfake.New(clientfake.WithHistoryPageSize(1))
+	alice := newWallet(t, fake, "10")
+	bob := newWallet(t, fake, "0")
+
+	balance, err := alice.Balance(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "10.00000", balance)
+
+	txID, err := alice.Send(context.Background(), bob.Address(), "1.5", "lunch")
+	require.NoError(t, err)
+	_, err = bob.Send(context.Background(), alice.Address(), "0.5", "")
+	require.NoError(t, err)
+
+	balance, err = alice.Balance(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "9.00000", balance)
+	balance, err = bob.Balance(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "1.00000", balance)
+
+	for _, amount := range []string{"abc", "0", "-1"} {
+		_, err = alice.Send(context.Background(), bob.Address(), amount, "")
+		assert.Equal(t, client.ErrInvalidAmount, errors.Cause(err), amount)
+	}
+
+	// History is paged.
+	transfers, cursor, err := alice.History(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+	assert.Equal(t, txID, transfers[0].TxID)
+	assert.Equal(t, DirectionSent, transfers[0].Direction)
+	assert.Equal(t, bob.Address(), transfers[0].Counterparty)
+	assert.Equal(t, "1.50000", transfers[0].Amount)
+	assert.EqualValues(t, 150000, transfers[0].Quarks)
+	assert.Equal(t, "lunch", transfers[0].Note)
+
+	transfers, cursor, err = alice.History(context.Background(), cursor)
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+	assert.Equal(t, DirectionReceived, transfers[0].Direction)
+	assert.Equal(t, bob.Address(), transfers[0].Counterparty)
+	assert.Equal(t, "0.50000", transfers[0].Amount)
+
+	transfers, cursor, err = alice.History(context.Background(), cursor)
+	require.NoError(t, err)
+	assert.Empty(t, transfers)
+	assert.Nil(t, cursor)
+}
+
+func TestWallet_BalanceChanged(t *testing.T) {
+	fake := clientfake.New()
+	alice := newWallet(t, fake, "10")
+	bob := newWallet(t, fake, "0")
+
+	// Earlier transactions are not reported.
+	_, err := alice.Send(context.Background(), bob.Address(), "1", "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan BalanceChange, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- bob.BalanceChanged(ctx, func(change BalanceChange) {
+			changes <- change
+		})
+	}()
+
+	// Wait for the subscription to be established, since transactions that
+	// occur beforehand are not reported.
+	time.Sleep(50 * time.Millisecond)
+
+	txID, err := alice.Send(context.Background(), bob.Address(), "2", "")
+	require.NoError(t, err)
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, BalanceChange{TxID: txID, Previous: "1.00000", Current: "3.00000"}, change)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for balance change")
+	}
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
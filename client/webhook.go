@@ -8,66 +8,114 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"hash"
+	"io"
 	"io/ioutil"
 	"net/http"
 
-	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
 	"github.com/kinecosystem/agora-common/kin"
 	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/memo"
+	"github.com/kinecosystem/agora-common/solana/token"
 	"github.com/kinecosystem/agora-common/webhook/createaccount"
 	"github.com/kinecosystem/agora-common/webhook/events"
 	"github.com/kinecosystem/agora-common/webhook/signtransaction"
 	"github.com/pkg/errors"
-
-	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
 )
 
 const (
 	AgoraHMACHeader      = "X-Agora-HMAC-SHA256"
+	AgoraHMACKeyIDHeader = "X-Agora-HMAC-Key-ID"
 	AppUserIDHeader      = "X-App-User-ID"
 	AppUserPasskeyHeader = "X-App-User-Passkey"
+
+	// DefaultMaxWebhookBodyBytes is the default maximum size of a webhook
+	// request body.
+	DefaultMaxWebhookBodyBytes = 10 << 20
 )
 
 // EventsFunc is a callback function for the Events webhook.
 //
 // If an error is returned, an InternalServer error is returned
 // to Agora. Agora will retry a limited amount of times when an
-// InternalServerError is returned.
+// InternalServerError is returned. To report that only some of the
+// events failed, return EventErrors (see WithEventRetryQueue).
 type EventsFunc func([]events.Event) error
 
 // EventsHandler returns an http.HandlerFunc that decodes and verifies
 // an Events webhook call, before forwarding it to the specified EventsFunc.
-func EventsHandler(secret string, f EventsFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+func EventsHandler(secret string, f EventsFunc, opts ...WebhookOption) http.HandlerFunc {
+	o := newWebhookOpts(secret, opts...)
+
+	return o.handler(WebhookEvents, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, "", http.StatusMethodNotAllowed)
+			o.writeError(w, r, http.StatusMethodNotAllowed, WebhookErrorMethodNotAllowed, "", nil)
 			return
 		}
 
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "failed to ready body", http.StatusBadRequest)
+		body := o.limitBody(w, r)
+		defer body.Close()
+
+		// Event batches can be large, so rather than buffering the body, the
+		// events are decoded as the body is hashed for verification.
+		v := o.newVerifier(r.Header)
+		tee := io.TeeReader(body, v)
+		events, raw, decodeErr := decodeEvents(tee)
+
+		// Regardless of whether or not decoding succeeded, the rest of the body
+		// is required to verify the signature.
+		if _, err := io.Copy(ioutil.Discard, tee); err != nil && decodeErr == nil {
+			decodeErr = err
+		}
+		if body.exceeded() {
+			o.writeBodyTooLarge(w, r)
 			return
 		}
-		defer r.Body.Close()
 
-		if len(secret) > 0 {
-			if err := verifySignature(r.Header, body, []byte(secret)); err != nil {
-				http.Error(w, "", http.StatusUnauthorized)
-				return
+		if err := v.verify(); err != nil {
+			o.observeVerificationFailure(WebhookEvents)
+			o.writeError(w, r, http.StatusUnauthorized, WebhookErrorUnauthorized, "invalid signature", err)
+			return
+		}
+		if decodeErr != nil {
+			o.writeError(w, r, http.StatusBadRequest, WebhookErrorInvalidRequest, "invalid body", decodeErr)
+			return
+		}
+		o.observeEventBatch(len(events))
+
+		var stored []StoredEvent
+		if o.eventStore != nil || o.eventPublisher != nil || o.eventRetryQueue != nil {
+			stored = newStoredEvents(events, raw)
+		}
+		if o.eventStore != nil || o.eventPublisher != nil {
+			if o.eventStore != nil {
+				if err := o.eventStore.Save(r.Context(), stored); err != nil {
+					o.writeError(w, r, http.StatusInternalServerError, WebhookErrorInternal, "failed to save events", err)
+					return
+				}
+			}
+			if o.eventPublisher != nil {
+				if err := o.eventPublisher.Publish(r.Context(), stored); err != nil {
+					o.writeError(w, r, http.StatusInternalServerError, WebhookErrorInternal, "failed to publish events", err)
+					return
+				}
 			}
 		}
 
-		var events []events.Event
-		if err := json.Unmarshal(body, &events); err != nil {
-			http.Error(w, "invalid body", http.StatusBadRequest)
+		err := f(events)
+		if errs, ok := err.(EventErrors); ok && o.eventRetryQueue != nil {
+			if failed := failedEvents(stored, errs); len(failed) > 0 {
+				if err := o.eventRetryQueue.Enqueue(r.Context(), failed); err != nil {
+					o.writeError(w, r, http.StatusInternalServerError, WebhookErrorInternal, "failed to enqueue failed events", err)
+				}
+			}
 			return
 		}
-
-		if err := f(events); err != nil {
-			http.Error(w, "", http.StatusInternalServerError)
+		if err != nil {
+			o.writeError(w, r, http.StatusInternalServerError, WebhookErrorInternal, "handler failed", err)
 		}
-	}
+	})
 }
 
 type CreateAccountFunc func(CreateAccountRequest, *CreateAccountResponse) error
@@ -75,6 +123,9 @@ type CreateAccountFunc func(CreateAccountRequest, *CreateAccountResponse) error
 type CreateAccountRequest struct {
 	Creation    Creation
 	Transaction *solana.Transaction
+
+	// RequestID is the ID of the webhook request (see RequestIDHeader).
+	RequestID string
 }
 
 type CreateAccountResponse struct {
@@ -83,6 +134,10 @@ type CreateAccountResponse struct {
 }
 
 // Sign signs the underlying transaction with the specified private key.
+//
+// The transaction is only signed if priv is the fee payer of the transaction,
+// since the fee payer signature is the only one returned to Agora. Otherwise,
+// Sign is a no-op. Use SignWith to sign with a key held outside of the process.
 func (c *CreateAccountResponse) Sign(priv kin.PrivateKey) (err error) {
 	if len(c.tx.Signatures) > len(c.tx.Message.Accounts) {
 		return errors.New("invalid transaction: more signers than accounts")
@@ -96,34 +151,45 @@ func (c *CreateAccountResponse) Sign(priv kin.PrivateKey) (err error) {
 	return nil
 }
 
+// SignWith signs the underlying transaction with signer, which must be the fee
+// payer of the transaction. If it is not, ErrNotFeePayer is returned.
+func (c *CreateAccountResponse) SignWith(signer TransactionSigner) error {
+	return signFeePayer(c.tx, signer)
+}
+
+// Signature returns the fee payer signature of the transaction that is
+// returned to Agora, or nil if the transaction has not been signed.
+func (c *CreateAccountResponse) Signature() []byte {
+	return feePayerSignature(c.tx)
+}
+
 func (c *CreateAccountResponse) Reject() {
 	c.rejected = true
 }
 
-func CreateAccountHandler(secret string, f CreateAccountFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+func CreateAccountHandler(secret string, f CreateAccountFunc, opts ...WebhookOption) http.HandlerFunc {
+	o := newWebhookOpts(secret, opts...)
+
+	return o.handler(WebhookCreateAccount, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, "", http.StatusMethodNotAllowed)
+			o.writeError(w, r, http.StatusMethodNotAllowed, WebhookErrorMethodNotAllowed, "", nil)
 			return
 		}
 
-		body, err := ioutil.ReadAll(r.Body)
+		body, err := o.readBody(w, r)
 		if err != nil {
-			http.Error(w, "failed to ready body", http.StatusBadRequest)
 			return
 		}
-		defer r.Body.Close()
 
-		if len(secret) > 0 {
-			if err := verifySignature(r.Header, body, []byte(secret)); err != nil {
-				http.Error(w, "", http.StatusUnauthorized)
-				return
-			}
+		if err := o.verify(r.Header, body); err != nil {
+			o.observeVerificationFailure(WebhookCreateAccount)
+			o.writeError(w, r, http.StatusUnauthorized, WebhookErrorUnauthorized, "invalid signature", err)
+			return
 		}
 
 		var createRequest createaccount.Request
 		if err = json.Unmarshal(body, &createRequest); err != nil {
-			http.Error(w, "invalid body", http.StatusBadRequest)
+			o.writeError(w, r, http.StatusBadRequest, WebhookErrorInvalidRequest, "invalid body", err)
 			return
 		}
 
@@ -133,18 +199,19 @@ func CreateAccountHandler(secret string, f CreateAccountFunc) http.HandlerFunc {
 		}
 
 		if createRequest.KinVersion != 4 {
-			http.Error(w, fmt.Sprintf("unsupported kin version %d", createRequest.KinVersion), http.StatusBadRequest)
+			o.writeError(w, r, http.StatusBadRequest, WebhookErrorInvalidRequest, fmt.Sprintf("unsupported kin version %d", createRequest.KinVersion), nil)
 			return
 		}
 
 		var tx solana.Transaction
 		if err := tx.Unmarshal(createRequest.SolanaTransaction); err != nil {
-			http.Error(w, "invalid solana tx", http.StatusBadRequest)
+			o.writeError(w, r, http.StatusBadRequest, WebhookErrorInvalidRequest, "invalid solana tx", err)
 			return
 		}
 
 		req := CreateAccountRequest{
 			Transaction: &tx,
+			RequestID:   requestID(r),
 		}
 		resp := CreateAccountResponse{
 			tx: &tx,
@@ -152,25 +219,32 @@ func CreateAccountHandler(secret string, f CreateAccountFunc) http.HandlerFunc {
 
 		creations, payments, err := parseTransaction(*req.Transaction, nil)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			o.writeError(w, r, http.StatusBadRequest, WebhookErrorInvalidRequest, err.Error(), err)
 			return
 		}
 		if len(payments) != 0 {
-			http.Error(w, "unexpected payments present", http.StatusBadRequest)
+			o.writeError(w, r, http.StatusBadRequest, WebhookErrorInvalidRequest, "unexpected payments present", nil)
 			return
 		}
 		if len(creations) != 1 {
-			http.Error(w, fmt.Sprintf("expected exactly 1 creation, got %d", len(creations)), http.StatusBadRequest)
+			o.writeError(w, r, http.StatusBadRequest, WebhookErrorInvalidRequest, fmt.Sprintf("expected exactly 1 creation, got %d", len(creations)), nil)
 			return
 		}
 
 		req.Creation = creations[0]
 
 		if err := f(req, &resp); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			o.writeError(w, r, http.StatusInternalServerError, WebhookErrorInternal, "handler failed", err)
 			return
 		}
 
+		if !resp.rejected {
+			if err := o.autoSign(resp.tx); err != nil {
+				o.writeError(w, r, http.StatusInternalServerError, WebhookErrorInternal, "failed to sign transaction", err)
+				return
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		encoder := json.NewEncoder(w)
 
@@ -179,14 +253,13 @@ func CreateAccountHandler(secret string, f CreateAccountFunc) http.HandlerFunc {
 			return
 		}
 
-		successResp := createaccount.SuccessResponse{}
-		if resp.tx.Signatures[0] != (solana.Signature{}) {
-			successResp.Signature = resp.tx.Signature()
+		successResp := createaccount.SuccessResponse{
+			Signature: resp.Signature(),
 		}
 		if err := encoder.Encode(&successResp); err != nil {
-			http.Error(w, "failed to encode response", http.StatusInternalServerError)
+			o.writeError(w, r, http.StatusInternalServerError, WebhookErrorInternal, "failed to encode response", err)
 		}
-	}
+	})
 }
 
 // SignTransactionFunc is a callback function for the SignTransaction webhook.
@@ -212,6 +285,11 @@ type SignTransactionRequest struct {
 	// The UserPassKey provided by the client (optional).
 	UserPasskey string
 
+	// RequestID is the ID of the webhook request (see RequestIDHeader). If the
+	// client specified a request ID (see ContextWithRequestID), and Agora
+	// forwarded it, it is the same ID.
+	RequestID string
+
 	// Account creations required for payments.
 	Creations []Creation
 
@@ -222,6 +300,8 @@ type SignTransactionRequest struct {
 	// which is optional.
 	//
 	// It will only be set on Solana-based transactions, and is _not_ a stable API.
+	// Validators should prefer the accessors on SignTransactionRequest, such as
+	// Transfers, Memos, FeePayer and VerifySignatures.
 	SolanaTransaction *solana.Transaction
 }
 
@@ -235,6 +315,118 @@ func (s *SignTransactionRequest) TxID() ([]byte, error) {
 	return nil, errors.New("this request has no transaction")
 }
 
+// Transfer is a token transfer contained in a SignTransactionRequest.
+type Transfer struct {
+	// Source and Destination are the token accounts of the transfer.
+	Source      kin.PublicKey
+	Destination kin.PublicKey
+
+	// Owner is the authority of the source account that authorized the transfer.
+	Owner kin.PublicKey
+
+	// DestinationOwner is the owner of the destination account, if the
+	// destination account is created within the same transaction. Otherwise,
+	// it is nil.
+	DestinationOwner kin.PublicKey
+
+	Quarks int64
+}
+
+// InstructionCount returns the number of instructions in the transaction.
+func (s *SignTransactionRequest) InstructionCount() int {
+	if s.SolanaTransaction == nil {
+		return 0
+	}
+	return len(s.SolanaTransaction.Message.Instructions)
+}
+
+// FeePayer returns the account paying the fees of the transaction.
+func (s *SignTransactionRequest) FeePayer() kin.PublicKey {
+	if s.SolanaTransaction == nil || len(s.SolanaTransaction.Message.Accounts) == 0 {
+		return nil
+	}
+	return kin.PublicKey(s.SolanaTransaction.Message.Accounts[0])
+}
+
+// Transfers returns the token transfers in the transaction, in instruction order.
+func (s *SignTransactionRequest) Transfers() []Transfer {
+	if s.SolanaTransaction == nil {
+		return nil
+	}
+
+	owners := make(map[string]kin.PublicKey)
+	for _, c := range s.Creations {
+		owners[string(c.Address)] = c.Owner
+	}
+
+	var transfers []Transfer
+	for i := range s.SolanaTransaction.Message.Instructions {
+		t, err := token.DecompileTransfer(s.SolanaTransaction.Message, i)
+		if err != nil {
+			continue
+		}
+
+		transfers = append(transfers, Transfer{
+			Source:           kin.PublicKey(t.Source),
+			Destination:      kin.PublicKey(t.Destination),
+			Owner:            kin.PublicKey(t.Owner),
+			DestinationOwner: owners[string(t.Destination)],
+			Quarks:           int64(t.Amount),
+		})
+	}
+
+	return transfers
+}
+
+// Memos returns the contents of the memo instructions in the transaction,
+// in instruction order.
+func (s *SignTransactionRequest) Memos() []string {
+	if s.SolanaTransaction == nil {
+		return nil
+	}
+
+	var memos []string
+	for i := range s.SolanaTransaction.Message.Instructions {
+		if m, err := memo.DecompileMemo(s.SolanaTransaction.Message, i); err == nil {
+			memos = append(memos, string(m.Data))
+		}
+	}
+
+	return memos
+}
+
+// VerifySignatures verifies the signatures present on the transaction.
+//
+// The fee payer signature may be absent, as it is typically provided by the
+// subsidizer after the webhook approves the transaction. All other signatures
+// must be present and valid, otherwise ErrInvalidSignature is returned.
+func (s *SignTransactionRequest) VerifySignatures() error {
+	if s.SolanaTransaction == nil {
+		return errors.New("this request has no transaction")
+	}
+
+	tx := s.SolanaTransaction
+	if len(tx.Signatures) > len(tx.Message.Accounts) {
+		return errors.Wrap(ErrInvalidSignature, "more signatures than accounts")
+	}
+
+	msg := tx.Message.Marshal()
+	for i, sig := range tx.Signatures {
+		if sig == (solana.Signature{}) {
+			if i == 0 {
+				continue
+			}
+			return errors.Wrapf(ErrInvalidSignature, "missing signature for %s", kin.PublicKey(tx.Message.Accounts[i]).Base58())
+		}
+
+		if !ed25519.Verify(ed25519.PublicKey(tx.Message.Accounts[i]), msg, sig[:]) {
+			return errors.Wrapf(ErrInvalidSignature, "invalid signature for %s", kin.PublicKey(tx.Message.Accounts[i]).Base58())
+		}
+	}
+
+	return nil
+}
+
 // SignTransactionResponse contains the response information related to a request.
 //
 // It is the primary mechanism in which a SignTransactionRequest can be signed or
@@ -243,9 +435,32 @@ type SignTransactionResponse struct {
 	rejected bool
 	errors   []signtransaction.InvoiceError
 	tx       *solana.Transaction
+
+	payments int
+	approved map[int]struct{}
 }
 
+// SignOutcome is the outcome of a SignTransactionResponse.
+type SignOutcome int
+
+const (
+	// SignOutcomeApproved indicates the transaction is approved.
+	SignOutcomeApproved SignOutcome = iota
+
+	// SignOutcomeRejected indicates the transaction is rejected
+	// without any invoice errors.
+	SignOutcomeRejected
+
+	// SignOutcomeInvoiceErrors indicates the transaction is rejected
+	// due to one or more invoice errors.
+	SignOutcomeInvoiceErrors
+)
+
 // Sign signs the underlying transaction with the specified private key.
+//
+// The transaction is only signed if priv is the fee payer of the transaction,
+// since the fee payer signature is the only one returned to Agora. Otherwise,
+// Sign is a no-op. Use SignWith to sign with a key held outside of the process.
 func (r *SignTransactionResponse) Sign(priv kin.PrivateKey) (err error) {
 	if len(r.tx.Signatures) > len(r.tx.Message.Accounts) {
 		return errors.New("invalid transaction: more signers than accounts")
@@ -259,6 +474,18 @@ func (r *SignTransactionResponse) Sign(priv kin.PrivateKey) (err error) {
 	return nil
 }
 
+// SignWith signs the underlying transaction with signer, which must be the fee
+// payer of the transaction. If it is not, ErrNotFeePayer is returned.
+func (r *SignTransactionResponse) SignWith(signer TransactionSigner) error {
+	return signFeePayer(r.tx, signer)
+}
+
+// Signature returns the fee payer signature of the transaction that is
+// returned to Agora, or nil if the transaction has not been signed.
+func (r *SignTransactionResponse) Signature() []byte {
+	return feePayerSignature(r.tx)
+}
+
 // Reject indicates the transaction should be rejected, without reason.
 func (r *SignTransactionResponse) Reject() {
 	r.rejected = true
@@ -267,18 +494,56 @@ func (r *SignTransactionResponse) Reject() {
 // IsRejected returns whether or not the transaction should be rejected,
 // with or without reason.
 func (r *SignTransactionResponse) IsRejected() bool {
-	return r.rejected
+	if r.rejected {
+		return true
+	}
+
+	// Solana transactions are atomic, so if any payment is explicitly
+	// approved, all of them must be.
+	if len(r.approved) == 0 {
+		return false
+	}
+	for i := 0; i < r.payments; i++ {
+		if _, ok := r.approved[i]; !ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MarkApproved marks the Payment at index idx as approved.
+//
+// Calling MarkApproved is optional. However, once any payment has been
+// marked as approved, the transaction is only approved if every payment
+// in the transaction has been marked as approved. Since Solana transactions
+// are executed atomically, Agora does not support approving a subset of the
+// payments in a transaction. Transactions that are rejected because of
+// unapproved payments contain no invoice errors.
+func (r *SignTransactionResponse) MarkApproved(idx int) {
+	if r.approved == nil {
+		r.approved = make(map[int]struct{})
+	}
+	r.approved[idx] = struct{}{}
+}
+
+// Outcome returns the outcome of the response.
+func (r *SignTransactionResponse) Outcome() SignOutcome {
+	switch {
+	case len(r.errors) > 0:
+		return SignOutcomeInvoiceErrors
+	case r.IsRejected():
+		return SignOutcomeRejected
+	default:
+		return SignOutcomeApproved
+	}
 }
 
 // MarkAlreadyPaid marks the Payment at index idx as paid.
 //
 // This causes the entire transaction to be rejected.
 func (r *SignTransactionResponse) MarkAlreadyPaid(idx int) {
-	r.rejected = true
-	r.errors = append(r.errors, signtransaction.InvoiceError{
-		OperationIndex: uint32(idx),
-		Reason:         signtransaction.AlreadyPaid,
-	})
+	r.markInvoiceError(idx, signtransaction.AlreadyPaid)
 }
 
 // MarkWrongDestination marks the Payment at index idx as having the
@@ -286,11 +551,7 @@ func (r *SignTransactionResponse) MarkAlreadyPaid(idx int) {
 //
 // This causes the entire transaction to be rejected.
 func (r *SignTransactionResponse) MarkWrongDestination(idx int) {
-	r.rejected = true
-	r.errors = append(r.errors, signtransaction.InvoiceError{
-		OperationIndex: uint32(idx),
-		Reason:         signtransaction.WrongDestination,
-	})
+	r.markInvoiceError(idx, signtransaction.WrongDestination)
 }
 
 // MarkSKUNotFound marks the Payment at index idx as having the
@@ -298,118 +559,269 @@ func (r *SignTransactionResponse) MarkWrongDestination(idx int) {
 //
 // This causes the entire transaction to be rejected.
 func (r *SignTransactionResponse) MarkSKUNotFound(idx int) {
+	r.markInvoiceError(idx, signtransaction.SKUNotFound)
+}
+
+// MarkWrongAmount indicates the Payment at index idx has an amount that
+// does not match its invoice.
+//
+// Agora does not define an invoice error for incorrect amounts, so this is
+// equivalent to Reject: the entire transaction is rejected without reason.
+func (r *SignTransactionResponse) MarkWrongAmount(idx int) {
+	r.Reject()
+}
+
+// MarkExpired indicates the Payment at index idx pays an invoice that has
+// expired, such as an abandoned checkout.
+//
+// Agora does not define an invoice error for expired invoices, so this is
+// equivalent to Reject: the entire transaction is rejected without reason.
+func (r *SignTransactionResponse) MarkExpired(idx int) {
+	r.Reject()
+}
+
+// InvoiceErrors returns the invoice errors the Payments have been marked with.
+func (r *SignTransactionResponse) InvoiceErrors() []signtransaction.InvoiceError {
+	return append([]signtransaction.InvoiceError(nil), r.errors...)
+}
+
+func (r *SignTransactionResponse) markInvoiceError(idx int, reason signtransaction.Reason) {
 	r.rejected = true
 	r.errors = append(r.errors, signtransaction.InvoiceError{
 		OperationIndex: uint32(idx),
-		Reason:         signtransaction.SKUNotFound,
+		Reason:         reason,
 	})
 }
 
 // SignTransactionHandler returns an http.HandlerFunc that decodes and verifies
 // a signtransaction webhook call, before forwarding it to the specified SignTransactionFunc.
-func SignTransactionHandler(secret string, f SignTransactionFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+func SignTransactionHandler(secret string, f SignTransactionFunc, opts ...WebhookOption) http.HandlerFunc {
+	o := newWebhookOpts(secret, opts...)
+
+	return o.handler(WebhookSignTransaction, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			// todo(consistency): double check error code response
-			http.Error(w, "", http.StatusMethodNotAllowed)
+			o.writeError(w, r, http.StatusMethodNotAllowed, WebhookErrorMethodNotAllowed, "", nil)
 			return
 		}
 
-		body, err := ioutil.ReadAll(r.Body)
+		body, err := o.readBody(w, r)
 		if err != nil {
-			http.Error(w, "failed to ready body", http.StatusBadRequest)
 			return
 		}
-		defer r.Body.Close()
 
-		if len(secret) > 0 {
-			if err := verifySignature(r.Header, body, []byte(secret)); err != nil {
-				http.Error(w, "", http.StatusUnauthorized)
-				return
-			}
+		if err := o.verify(r.Header, body); err != nil {
+			o.observeVerificationFailure(WebhookSignTransaction)
+			o.writeError(w, r, http.StatusUnauthorized, WebhookErrorUnauthorized, "invalid signature", err)
+			return
 		}
 
-		var signRequest signtransaction.Request
-		if err = json.Unmarshal(body, &signRequest); err != nil {
-			http.Error(w, "invalid body", http.StatusBadRequest)
+		req, err := ParseSignRequestBody(body)
+		if err != nil {
+			o.writeError(w, r, http.StatusBadRequest, WebhookErrorInvalidRequest, err.Error(), err)
 			return
 		}
+		req.UserID = r.Header.Get(AppUserIDHeader)
+		req.UserPasskey = r.Header.Get(AppUserPasskeyHeader)
+		req.RequestID = requestID(r)
 
-		// If no kin version is set, default to Kin 4
-		if signRequest.KinVersion == 0 {
-			signRequest.KinVersion = 4
+		var cacheKey, requestHash []byte
+		if o.signCache != nil {
+			cacheKey = signTransactionCacheKey(req.SolanaTransaction)
+			h := sha256.Sum256(body)
+			requestHash = h[:]
+
+			if cached, ok := o.signCache.Get(cacheKey); ok && bytes.Equal(cached.RequestHash, requestHash) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(cached.StatusCode)
+				_, _ = w.Write(cached.Body)
+				return
+			}
 		}
 
-		if signRequest.KinVersion != 4 {
-			http.Error(w, fmt.Sprintf("unsupported kin version %d", signRequest.KinVersion), http.StatusBadRequest)
+		resp := NewSignTransactionResponse(req)
+		if err := f(req, resp); err != nil {
+			o.writeError(w, r, http.StatusInternalServerError, WebhookErrorInternal, "handler failed", err)
 			return
 		}
+		o.observeSignOutcome(resp)
 
-		var invoiceList *commonpb.InvoiceList
-		if len(signRequest.InvoiceList) > 0 {
-			invoiceList = &commonpb.InvoiceList{}
-			if err = proto.Unmarshal(signRequest.InvoiceList, invoiceList); err != nil {
-				http.Error(w, "invalid invoice list", http.StatusBadRequest)
+		if !resp.IsRejected() {
+			if err := o.autoSign(resp.tx); err != nil {
+				o.writeError(w, r, http.StatusInternalServerError, WebhookErrorInternal, "failed to sign transaction", err)
 				return
 			}
 		}
 
-		req := SignTransactionRequest{
-			UserID:      r.Header.Get(AppUserIDHeader),
-			UserPasskey: r.Header.Get(AppUserPasskeyHeader),
-		}
-
-		var tx solana.Transaction
-		if err = tx.Unmarshal(signRequest.SolanaTransaction); err != nil {
-			http.Error(w, "invalid solana tx", http.StatusBadRequest)
+		statusCode, encoded, err := resp.Encode()
+		if err != nil {
+			o.writeError(w, r, http.StatusInternalServerError, WebhookErrorInternal, "failed to encode response", err)
 			return
 		}
 
-		req.SolanaTransaction = &tx
-		req.Creations, req.Payments, err = parseTransaction(tx, invoiceList)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+		if o.signCache != nil {
+			o.signCache.Put(cacheKey, CachedSignTransactionResponse{
+				RequestHash: requestHash,
+				StatusCode:  statusCode,
+				Body:        encoded,
+			})
 		}
 
-		resp := &SignTransactionResponse{
-			tx: req.SolanaTransaction,
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		_, _ = w.Write(encoded)
+	})
+}
+
+type webhookSecret struct {
+	keyID  string
+	secret []byte
+}
+
+type webhookOpts struct {
+	secrets      []webhookSecret
+	maxBodyBytes int64
+	signCache    SignTransactionCache
+	eventStore   EventStore
+
+	eventPublisher  EventPublisher
+	eventRetryQueue EventRetryQueue
+	metrics         WebhookMetrics
+	errorWriter     WebhookErrorWriter
+	subsidizer      TransactionSigner
+}
+
+// WebhookOption configures a webhook handler.
+type WebhookOption func(*webhookOpts)
+
+// WithPreviousSecrets specifies additional secrets that are accepted by a webhook
+// handler, allowing the webhook secret to be rotated without rejecting deliveries
+// signed with the previous secret.
+func WithPreviousSecrets(secrets ...string) WebhookOption {
+	return func(o *webhookOpts) {
+		for _, secret := range secrets {
+			if secret != "" {
+				o.secrets = append(o.secrets, webhookSecret{secret: []byte(secret)})
+			}
 		}
+	}
+}
 
-		if err := f(req, resp); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+// WithKeyedSecret specifies an additional secret, identified by keyID, that is
+// accepted by a webhook handler.
+//
+// If a request specifies a key ID in the AgoraHMACKeyIDHeader header, it must be
+// signed with the secret of that key ID. Agora does not send the header itself,
+// so it is only useful if requests are forwarded by a proxy that sets it, or
+// that signs requests with its own keys. Requests without the header may be
+// signed with any configured secret.
+//
+// As with WithPreviousSecrets, an empty secret is ignored.
+func WithKeyedSecret(keyID, secret string) WebhookOption {
+	return func(o *webhookOpts) {
+		if secret != "" {
+			o.secrets = append(o.secrets, webhookSecret{keyID: keyID, secret: []byte(secret)})
 		}
+	}
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		encoder := json.NewEncoder(w)
+// WithMaxBodyBytes specifies the maximum size of a webhook request body. Requests
+// with larger bodies are rejected with http.StatusRequestEntityTooLarge.
+//
+// It defaults to DefaultMaxWebhookBodyBytes. If n <= 0, the size is unlimited.
+func WithMaxBodyBytes(n int64) WebhookOption {
+	return func(o *webhookOpts) {
+		o.maxBodyBytes = n
+	}
+}
 
-		if resp.IsRejected() {
-			w.WriteHeader(http.StatusForbidden)
+func newWebhookOpts(secret string, opts ...WebhookOption) webhookOpts {
+	o := webhookOpts{maxBodyBytes: DefaultMaxWebhookBodyBytes}
+	if secret != "" {
+		o.secrets = append(o.secrets, webhookSecret{secret: []byte(secret)})
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
 
-			rejectResp := signtransaction.ForbiddenResponse{
-				Message:       "rejected",
-				InvoiceErrors: resp.errors,
-			}
-			if err := encoder.Encode(&rejectResp); err != nil {
-				http.Error(w, "failed to encode response", http.StatusInternalServerError)
-			}
+// handler returns h, wrapped with the request ID and metrics handling common
+// to all webhooks.
+//
+// The ID of the request is set in the request context, and the response.
+func (o webhookOpts) handler(webhook string, h http.HandlerFunc) http.HandlerFunc {
+	h = o.instrument(webhook, h)
 
-			return
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = uuid.New().String()
 		}
 
-		successResp := signtransaction.SuccessResponse{}
-		if resp.tx.Signatures[0] != (solana.Signature{}) {
-			successResp.Signature = resp.tx.Signature()
+		w.Header().Set(RequestIDHeader, id)
+		h(w, r.WithContext(ContextWithRequestID(r.Context(), id)))
+	}
+}
+
+// verify verifies the request signature against the configured secrets. If no
+// secrets are configured, no verification is performed.
+func (o webhookOpts) verify(header http.Header, body []byte) error {
+	v := o.newVerifier(header)
+	_, _ = v.Write(body)
+	return v.verify()
+}
+
+// bodyVerifier verifies the signature of a request body as it is written.
+type bodyVerifier struct {
+	header  http.Header
+	secrets []webhookSecret
+	hashes  []hash.Hash
+	err     error
+}
+
+func (o webhookOpts) newVerifier(header http.Header) *bodyVerifier {
+	v := &bodyVerifier{header: header, secrets: o.secrets}
+
+	if keyID := header.Get(AgoraHMACKeyIDHeader); keyID != "" && len(o.secrets) > 0 {
+		v.secrets = nil
+		for _, s := range o.secrets {
+			if s.keyID == keyID {
+				v.secrets = []webhookSecret{s}
+				break
+			}
 		}
-		if err = encoder.Encode(&successResp); err != nil {
-			http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		if len(v.secrets) == 0 {
+			v.err = errors.Errorf("unknown key id: %s", keyID)
 		}
 	}
+
+	v.hashes = make([]hash.Hash, len(v.secrets))
+	for i, s := range v.secrets {
+		v.hashes[i] = hmac.New(sha256.New, s.secret)
+	}
+
+	return v
+}
+
+// Write implements io.Writer.Write.
+func (v *bodyVerifier) Write(p []byte) (int, error) {
+	for _, h := range v.hashes {
+		_, _ = h.Write(p)
+	}
+	return len(p), nil
 }
 
-func verifySignature(header http.Header, body, secret []byte) error {
-	encodedSig := header.Get(AgoraHMACHeader)
+// verify verifies the signature over everything written to the verifier.
+func (v *bodyVerifier) verify() error {
+	if v.err != nil {
+		return v.err
+	}
+	if len(v.hashes) == 0 {
+		return nil
+	}
+
+	encodedSig := v.header.Get(AgoraHMACHeader)
 	if encodedSig == "" {
 		return errors.New("missing signature")
 	}
@@ -419,16 +831,108 @@ func verifySignature(header http.Header, body, secret []byte) error {
 		return errors.Wrap(err, "invalid signature")
 	}
 
-	h := hmac.New(sha256.New, []byte(secret))
-	if _, err := h.Write(body); err != nil {
-		return err
+	for _, h := range v.hashes {
+		if hmac.Equal(h.Sum(nil), sig) {
+			return nil
+		}
 	}
 
-	expected := h.Sum(nil)
-	if !hmac.Equal(expected, sig) {
-		// todo: well known error type?
-		return errors.New("hmac signature mismatch")
+	// todo: well known error type?
+	return errors.New("hmac signature mismatch")
+}
+
+// limitedBody is a request body limited to the maximum body size, which
+// tracks whether or not the limit was exceeded.
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+	err   error
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	n, err := l.ReadCloser.Read(p)
+	l.read += int64(n)
+	if err != nil && err != io.EOF {
+		l.err = err
 	}
+	return n, err
+}
 
-	return nil
+func (l *limitedBody) exceeded() bool {
+	return l.limit > 0 && l.err != nil && l.read >= l.limit
+}
+
+func (o webhookOpts) limitBody(w http.ResponseWriter, r *http.Request) *limitedBody {
+	body := &limitedBody{ReadCloser: r.Body, limit: o.maxBodyBytes}
+	if o.maxBodyBytes > 0 {
+		body.ReadCloser = http.MaxBytesReader(w, r.Body, o.maxBodyBytes)
+	}
+	return body
+}
+
+// readBody reads the request body. If it could not be read, an error response is
+// written, and the error is returned.
+func (o webhookOpts) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body := o.limitBody(w, r)
+	defer body.Close()
+
+	b, err := ioutil.ReadAll(body)
+	if body.exceeded() {
+		o.writeBodyTooLarge(w, r)
+		return nil, err
+	}
+	if err != nil {
+		o.writeError(w, r, http.StatusBadRequest, WebhookErrorInvalidRequest, "failed to read body", err)
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (o webhookOpts) writeBodyTooLarge(w http.ResponseWriter, r *http.Request) {
+	o.writeError(w, r, http.StatusRequestEntityTooLarge, WebhookErrorRequestTooLarge, fmt.Sprintf("request body exceeds %d bytes", o.maxBodyBytes), nil)
+}
+
+// decodeEvents decodes a JSON array of events from r, one event at a time. The
+// raw JSON of each event is returned alongside the decoded events.
+func decodeEvents(r io.Reader) ([]events.Event, []json.RawMessage, error) {
+	dec := json.NewDecoder(r)
+
+	t, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if t == nil {
+		return nil, nil, nil
+	}
+	if delim, ok := t.(json.Delim); !ok || delim != '[' {
+		return nil, nil, errors.New("expected array of events")
+	}
+
+	var result []events.Event
+	var raw []json.RawMessage
+	for dec.More() {
+		var m json.RawMessage
+		if err := dec.Decode(&m); err != nil {
+			return nil, nil, err
+		}
+
+		var e events.Event
+		if err := json.Unmarshal(m, &e); err != nil {
+			return nil, nil, err
+		}
+		result = append(result, e)
+		raw = append(raw, m)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+
+	// As with json.Unmarshal, only whitespace may follow the array.
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, nil, errors.New("unexpected data after events")
+	}
+
+	return result, raw, nil
 }
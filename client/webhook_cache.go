@@ -0,0 +1,135 @@
+package client
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/solana"
+)
+
+// SignTransactionCache caches the responses of a SignTransaction webhook handler,
+// allowing re-deliveries of the same request to be answered without invoking the
+// SignTransactionFunc again.
+//
+// Implementations must be safe for concurrent use.
+type SignTransactionCache interface {
+	// Get returns the cached response for the specified key, if any.
+	Get(key []byte) (CachedSignTransactionResponse, bool)
+
+	// Put caches the response for the specified key.
+	Put(key []byte, resp CachedSignTransactionResponse)
+}
+
+// CachedSignTransactionResponse is a response cached by a SignTransactionCache.
+type CachedSignTransactionResponse struct {
+	// RequestHash is the SHA-256 hash of the request body the response was
+	// computed for. The response is only used for identical requests.
+	RequestHash []byte
+
+	StatusCode int
+	Body       []byte
+}
+
+// WithSignTransactionCache specifies a cache used by SignTransactionHandler to
+// return the previously computed signature or rejection for a re-delivered request.
+//
+// Responses are keyed on the transaction ID or, if the transaction has not been
+// signed by its fee payer, the hash of the transaction message. Only approved and
+// rejected outcomes are cached.
+func WithSignTransactionCache(c SignTransactionCache) WebhookOption {
+	return func(o *webhookOpts) {
+		o.signCache = c
+	}
+}
+
+// signTransactionCacheKey returns the key used to cache the response for tx.
+func signTransactionCacheKey(tx *solana.Transaction) []byte {
+	if len(tx.Signatures) > 0 && tx.Signatures[0] != (solana.Signature{}) {
+		return tx.Signature()
+	}
+
+	h := sha256.Sum256(tx.Message.Marshal())
+	return h[:]
+}
+
+type memorySignTransactionCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type memorySignTransactionCacheEntry struct {
+	key      string
+	resp     CachedSignTransactionResponse
+	cachedAt time.Time
+}
+
+// NewMemorySignTransactionCache returns an in-memory SignTransactionCache that
+// holds up to size responses for the duration of ttl, evicting the least recently
+// used responses first.
+//
+// If ttl <= 0, responses do not expire.
+func NewMemorySignTransactionCache(size int, ttl time.Duration) SignTransactionCache {
+	return &memorySignTransactionCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Get implements SignTransactionCache.Get.
+func (c *memorySignTransactionCache) Get(key []byte) (CachedSignTransactionResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[string(key)]
+	if !ok {
+		return CachedSignTransactionResponse{}, false
+	}
+
+	entry := elem.Value.(*memorySignTransactionCacheEntry)
+	if c.ttl > 0 && time.Since(entry.cachedAt) >= c.ttl {
+		c.lru.Remove(elem)
+		delete(c.entries, entry.key)
+		return CachedSignTransactionResponse{}, false
+	}
+
+	c.lru.MoveToFront(elem)
+	return entry.resp, true
+}
+
+// Put implements SignTransactionCache.Put.
+func (c *memorySignTransactionCache) Put(key []byte, resp CachedSignTransactionResponse) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[string(key)]; ok {
+		entry := elem.Value.(*memorySignTransactionCacheEntry)
+		entry.resp = resp
+		entry.cachedAt = time.Now()
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	for c.lru.Len() >= c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memorySignTransactionCacheEntry).key)
+	}
+
+	c.entries[string(key)] = c.lru.PushFront(&memorySignTransactionCacheEntry{
+		key:      string(key),
+		resp:     resp,
+		cachedAt: time.Now(),
+	})
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/webhook/signtransaction"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignTransactionHandler_Cache(t *testing.T) {
+	var calls int
+	var fail, reject bool
+	f := func(req SignTransactionRequest, resp *SignTransactionResponse) error {
+		calls++
+		if fail {
+			return errors.New("unavailable")
+		}
+		if reject {
+			resp.Reject()
+		}
+		return nil
+	}
+
+	makeReq := func(data signtransaction.Request) *http.Request {
+		body, err := json.Marshal(data)
+		require.NoError(t, err)
+
+		h := hmac.New(sha256.New, []byte("secret"))
+		_, _ = h.Write(body)
+
+		req, err := http.NewRequest(http.MethodPost, "/sign_transaction", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.Header.Add(AgoraHMACHeader, base64.StdEncoding.EncodeToString(h.Sum(nil)))
+		return req
+	}
+
+	handler := SignTransactionHandler("secret", f, WithSignTransactionCache(NewMemorySignTransactionCache(10, time.Hour)))
+
+	// Failures are not cached.
+	approved := genRequest(t, false, false, 4)
+	fail = true
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq(approved))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, 1, calls)
+
+	fail = false
+	var expected []byte
+	for i := 0; i < 3; i++ {
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, makeReq(approved))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+		if expected == nil {
+			expected = rr.Body.Bytes()
+		}
+		assert.Equal(t, expected, rr.Body.Bytes())
+	}
+	assert.Equal(t, 2, calls)
+
+	// Rejections are cached.
+	rejected := genRequest(t, false, true, 4)
+	reject = true
+	for i := 0; i < 3; i++ {
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, makeReq(rejected))
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		var resp signtransaction.ForbiddenResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		assert.Equal(t, "rejected", resp.Message)
+	}
+	assert.Equal(t, 3, calls)
+
+	// A request for the same transaction with a different body is not
+	// answered from the cache.
+	modified := rejected
+	modified.KinVersion = 0
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq(modified))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, 4, calls)
+}
+
+func TestSignTransactionCacheKey(t *testing.T) {
+	req := genRequest(t, false, false, 4)
+
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(req.SolanaTransaction))
+
+	// Unsigned transactions are keyed on their message.
+	unsigned := signTransactionCacheKey(&tx)
+	assert.NotEqual(t, make([]byte, len(unsigned)), unsigned)
+
+	var other solana.Transaction
+	require.NoError(t, other.Unmarshal(genRequest(t, false, false, 4).SolanaTransaction))
+	assert.NotEqual(t, unsigned, signTransactionCacheKey(&other))
+
+	tx.Signatures[0][0] = 1
+	assert.Equal(t, tx.Signature(), signTransactionCacheKey(&tx))
+}
+
+func TestMemorySignTransactionCache(t *testing.T) {
+	c := NewMemorySignTransactionCache(2, time.Hour)
+
+	for i := byte(0); i < 3; i++ {
+		c.Put([]byte{i}, CachedSignTransactionResponse{StatusCode: int(i)})
+		if i == 1 {
+			// Mark 0 as recently used, so 1 is evicted.
+			_, ok := c.Get([]byte{0})
+			assert.True(t, ok)
+		}
+	}
+
+	resp, ok := c.Get([]byte{0})
+	assert.True(t, ok)
+	assert.Equal(t, 0, resp.StatusCode)
+	_, ok = c.Get([]byte{1})
+	assert.False(t, ok)
+	resp, ok = c.Get([]byte{2})
+	assert.True(t, ok)
+	assert.Equal(t, 2, resp.StatusCode)
+
+	c.Put([]byte{2}, CachedSignTransactionResponse{StatusCode: 20})
+	resp, ok = c.Get([]byte{2})
+	assert.True(t, ok)
+	assert.Equal(t, 20, resp.StatusCode)
+
+	c = NewMemorySignTransactionCache(2, time.Millisecond)
+	c.Put([]byte{0}, CachedSignTransactionResponse{})
+	time.Sleep(2 * time.Millisecond)
+	_, ok = c.Get([]byte{0})
+	assert.False(t, ok)
+
+	c = NewMemorySignTransactionCache(0, time.Hour)
+	c.Put([]byte{0}, CachedSignTransactionResponse{})
+	_, ok = c.Get([]byte{0})
+	assert.False(t, ok)
+}
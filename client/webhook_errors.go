@@ -0,0 +1,77 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// RequestIDHeader is the header that identifies a webhook request. If a request
+// does not specify it, an ID is generated. The ID is returned in the same header
+// of the response, and is available from the request context through
+// RequestIDFromContext.
+const RequestIDHeader = "X-Request-ID"
+
+// The codes of WebhookErrors returned by webhook handlers.
+const (
+	WebhookErrorMethodNotAllowed = "method_not_allowed"
+	WebhookErrorUnauthorized     = "unauthorized"
+	WebhookErrorInvalidRequest   = "invalid_request"
+	WebhookErrorRequestTooLarge  = "request_too_large"
+	WebhookErrorInternal         = "internal_error"
+)
+
+// WebhookError is the body of an error response from a webhook handler.
+//
+// Rejections by a SignTransactionFunc or CreateAccountFunc are not errors, and
+// are returned as Agora expects.
+type WebhookError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Err is the underlying error, if any. It is not included in the
+	// response, but is available to a WebhookErrorWriter for logging.
+	Err error `json:"-"`
+}
+
+// WebhookErrorWriter writes an error response with the specified status code.
+type WebhookErrorWriter func(w http.ResponseWriter, r *http.Request, statusCode int, e WebhookError)
+
+// WithErrorWriter specifies the WebhookErrorWriter that a webhook handler
+// writes error responses with. By default, the WebhookError is written as JSON.
+func WithErrorWriter(f WebhookErrorWriter) WebhookOption {
+	return func(o *webhookOpts) {
+		o.errorWriter = f
+	}
+}
+
+// writeWebhookError writes e as JSON.
+func writeWebhookError(w http.ResponseWriter, _ *http.Request, statusCode int, e WebhookError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(&e)
+}
+
+func (o webhookOpts) writeError(w http.ResponseWriter, r *http.Request, statusCode int, code, message string, err error) {
+	e := WebhookError{
+		Code:      code,
+		Message:   message,
+		RequestID: requestID(r),
+		Err:       err,
+	}
+
+	if o.errorWriter != nil {
+		o.errorWriter(w, r, statusCode, e)
+		return
+	}
+	writeWebhookError(w, r, statusCode, e)
+}
+
+// requestID returns the ID of the request, as set by webhookOpts.handler.
+func requestID(r *http.Request) string {
+	if id, ok := RequestIDFromContext(r.Context()); ok {
+		return id
+	}
+	return r.Header.Get(RequestIDHeader)
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookErrors(t *testing.T) {
+	handlerErr := errors.New("database unavailable")
+	handler := EventsHandler("secret", func([]events.Event) error { return handlerErr })
+
+	// Unsigned requests are rejected, and the request ID is echoed.
+	req, err := http.NewRequest(http.MethodPost, "/events", bytes.NewBufferString("[]"))
+	require.NoError(t, err)
+	req.Header.Set(RequestIDHeader, "abc")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var e WebhookError
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&e))
+	assert.Equal(t, WebhookError{
+		Code:      WebhookErrorUnauthorized,
+		Message:   "invalid signature",
+		RequestID: "abc",
+	}, e)
+
+	// Without a request ID, one is generated. The underlying error is not
+	// included in the response.
+	handler = EventsHandler("", func([]events.Event) error { return handlerErr })
+	req, err = http.NewRequest(http.MethodPost, "/events", bytes.NewBufferString("[]"))
+	require.NoError(t, err)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.NotContains(t, rr.Body.String(), handlerErr.Error())
+
+	e = WebhookError{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&e))
+	assert.Equal(t, WebhookErrorInternal, e.Code)
+	assert.NotEmpty(t, e.RequestID)
+}
+
+func TestWebhookErrors_Writer(t *testing.T) {
+	handlerErr := errors.New("database unavailable")
+
+	var written []WebhookError
+	writer := func(w http.ResponseWriter, r *http.Request, statusCode int, e WebhookError) {
+		written = append(written, e)
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(e.Code))
+	}
+
+	handler := EventsHandler("", func([]events.Event) error { return handlerErr }, WithErrorWriter(writer))
+	for _, body := range []string{"{", "[]"} {
+		req, err := http.NewRequest(http.MethodPost, "/events", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/events", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+	assert.Equal(t, WebhookErrorMethodNotAllowed, rr.Body.String())
+
+	require.Len(t, written, 3)
+	assert.Equal(t, WebhookErrorInvalidRequest, written[0].Code)
+	assert.Error(t, written[0].Err)
+	assert.Equal(t, WebhookErrorInternal, written[1].Code)
+	assert.Equal(t, handlerErr, written[1].Err)
+	assert.Equal(t, WebhookErrorMethodNotAllowed, written[2].Code)
+}
+
+func TestWebhook_RequestID(t *testing.T) {
+	var requestIDs []string
+	handler := SignTransactionHandler("", func(req SignTransactionRequest, resp *SignTransactionResponse) error {
+		requestIDs = append(requestIDs, req.RequestID)
+		return nil
+	})
+
+	body, err := json.Marshal(genRequest(t, false, false, 4))
+	require.NoError(t, err)
+
+	for _, id := range []string{"req-1", ""} {
+		req, err := http.NewRequest(http.MethodPost, "/sign_transaction", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, requestIDs[len(requestIDs)-1], rr.Header().Get(RequestIDHeader))
+	}
+
+	require.Len(t, requestIDs, 2)
+	assert.Equal(t, "req-1", requestIDs[0])
+	assert.NotEmpty(t, requestIDs[1])
+
+	// The ID is available from the context of the request.
+	store := &fakeEventStore{}
+	var ctxID string
+	eventsHandler := EventsHandler("", func([]events.Event) error { return nil }, WithEventStore(requestIDStore{store, &ctxID}))
+	req, err := http.NewRequest(http.MethodPost, "/events", bytes.NewBufferString("[]"))
+	require.NoError(t, err)
+	req.Header.Set(RequestIDHeader, "req-2")
+	eventsHandler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "req-2", ctxID)
+}
+
+type requestIDStore struct {
+	*fakeEventStore
+	id *string
+}
+
+func (s requestIDStore) Save(ctx context.Context, events []StoredEvent) error {
+	*s.id, _ = RequestIDFromContext(ctx)
+	return s.fakeEventStore.Save(ctx, events)
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"time"
+
+	"github.com/kinecosystem/agora-common/webhook/events"
+)
+
+// StoredEvent is an Events webhook event, as received from Agora.
+type StoredEvent struct {
+	// ID identifies the event. It is the SHA-256 hash of Raw, so redeliveries
+	// of an event have the same ID.
+	ID []byte
+
+	// Raw is the JSON encoding of the event, as sent by Agora.
+	Raw json.RawMessage
+
+	Event    events.Event
+	Received time.Time
+}
+
+// EventStore persists the events received by an Events webhook.
+//
+// Implementations for Postgres and SQLite are provided in the
+// client/eventstore package.
+type EventStore interface {
+	// Save saves a batch of events. Events that have already been saved are
+	// ignored.
+	Save(ctx context.Context, events []StoredEvent) error
+
+	// Events returns the events received at or after the specified time, in
+	// the order they were saved.
+	Events(ctx context.Context, since time.Time) ([]StoredEvent, error)
+
+	// Prune deletes the events received before the specified time, returning
+	// the number of events deleted.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+// WithEventStore specifies an EventStore that every event received by an
+// EventsHandler is saved to, after the request is verified and before the
+// EventsFunc is invoked.
+//
+// If the events cannot be saved, the EventsFunc is not invoked, and an
+// InternalServerError is returned to Agora so that the events are redelivered.
+func WithEventStore(store EventStore) WebhookOption {
+	return func(o *webhookOpts) {
+		o.eventStore = store
+	}
+}
+
+// EventPublisher publishes the events received by an Events webhook to a
+// message queue, so that they can be processed independently of the webhook
+// request.
+//
+// Implementations for Kafka, NATS, and SNS/SQS are provided in the
+// client/eventpub package.
+type EventPublisher interface {
+	// Publish publishes a batch of events, in order. It should only return
+	// once the events have been accepted by the queue.
+	Publish(ctx context.Context, events []StoredEvent) error
+}
+
+// WithEventPublisher specifies an EventPublisher that every event received by
+// an EventsHandler is published to, after the request is verified (and the
+// events are saved, if WithEventStore is specified), and before the EventsFunc
+// is invoked.
+//
+// If the events cannot be published, the EventsFunc is not invoked, and an
+// InternalServerError is returned to Agora so that the events are redelivered.
+// Subscribers should therefore use StoredEvent.ID to discard redeliveries.
+func WithEventPublisher(publisher EventPublisher) WebhookOption {
+	return func(o *webhookOpts) {
+		o.eventPublisher = publisher
+	}
+}
+
+func newStoredEvents(decoded []events.Event, raw []json.RawMessage) []StoredEvent {
+	received := time.Now()
+
+	stored := make([]StoredEvent, len(decoded))
+	for i := range decoded {
+		id := sha256.Sum256(raw[i])
+		stored[i] = StoredEvent{
+			ID:       id[:],
+			Raw:      raw[i],
+			Event:    decoded[i],
+			Received: received,
+		}
+	}
+	return stored
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEventStore struct {
+	saved []StoredEvent
+	err   error
+}
+
+func (s *fakeEventStore) Save(_ context.Context, events []StoredEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.saved = append(s.saved, events...)
+	return nil
+}
+
+func (s *fakeEventStore) Events(context.Context, time.Time) ([]StoredEvent, error) {
+	return s.saved, nil
+}
+
+func (s *fakeEventStore) Prune(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestEventsHandler_EventStore(t *testing.T) {
+	// The raw events are preserved exactly as sent, including fields unknown
+	// to the decoder.
+	raw := []json.RawMessage{
+		json.RawMessage(`{"transaction_event":{"kin_version":4,"tx_id":"YQ==","solana_event":{"transaction":"dHg="}},"extra":1}`),
+		json.RawMessage(`{"transaction_event":{"kin_version":4,"tx_id":"Yg==","solana_event":{"transaction":"dHg=","transaction_error":"failed"}}}`),
+	}
+	body := []byte("[" + string(raw[0]) + ",\n" + string(raw[1]) + "]")
+
+	makeReq := func() *http.Request {
+		h := hmac.New(sha256.New, []byte("secret"))
+		_, _ = h.Write(body)
+
+		req, err := http.NewRequest(http.MethodPost, "/events", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.Header.Add(AgoraHMACHeader, base64.StdEncoding.EncodeToString(h.Sum(nil)))
+		return req
+	}
+
+	var called int
+	store := &fakeEventStore{}
+	handler := EventsHandler("secret", func(received []events.Event) error {
+		called++
+		require.Len(t, store.saved, len(received))
+		return nil
+	}, WithEventStore(store))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq())
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, called)
+
+	require.Len(t, store.saved, 2)
+	for i, e := range store.saved {
+		id := sha256.Sum256(raw[i])
+		assert.Equal(t, id[:], e.ID)
+		assert.Equal(t, raw[i], e.Raw)
+		assert.False(t, e.Received.IsZero())
+	}
+	assert.Equal(t, []byte("a"), store.saved[0].Event.TransactionEvent.TxID)
+	assert.Equal(t, "failed", store.saved[1].Event.TransactionEvent.SolanaEvent.TransactionError)
+
+	// If the events cannot be saved, they are not processed.
+	store.err = errors.New("unavailable")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq())
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, 1, called)
+}
+
+type fakeEventPublisher struct {
+	published []StoredEvent
+	err       error
+}
+
+func (p *fakeEventPublisher) Publish(_ context.Context, events []StoredEvent) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, events...)
+	return nil
+}
+
+func TestEventsHandler_EventPublisher(t *testing.T) {
+	raw := json.RawMessage(`{"transaction_event":{"kin_version":4,"tx_id":"YQ==","solana_event":{"transaction":"dHg="}}}`)
+	body := []byte("[" + string(raw) + "]")
+
+	makeReq := func() *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "/events", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		return req
+	}
+
+	var called int
+	store := &fakeEventStore{}
+	publisher := &fakeEventPublisher{}
+	handler := EventsHandler("", func(received []events.Event) error {
+		called++
+		require.Len(t, publisher.published, len(received))
+		return nil
+	}, WithEventStore(store), WithEventPublisher(publisher))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq())
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, called)
+
+	require.Len(t, publisher.published, 1)
+	assert.Equal(t, store.saved, publisher.published)
+	assert.Equal(t, raw, publisher.published[0].Raw)
+
+	// If the events cannot be published, they are not processed.
+	publisher.err = errors.New("unavailable")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq())
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, 1, called)
+
+	// Events are not published if they cannot be saved.
+	publisher.err = nil
+	store.err = errors.New("unavailable")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq())
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Len(t, publisher.published, 1)
+}
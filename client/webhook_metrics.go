@@ -0,0 +1,126 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+// The names of the webhooks reported to WebhookMetrics.
+const (
+	WebhookEvents          = "events"
+	WebhookCreateAccount   = "create_account"
+	WebhookSignTransaction = "sign_transaction"
+)
+
+// WebhookMetrics records metrics about the requests handled by webhook
+// handlers. Methods may be called concurrently.
+//
+// An implementation that exports Prometheus metrics is provided in the
+// client/webhookmetrics package.
+type WebhookMetrics interface {
+	// ObserveRequest is called after each request is handled, with the status
+	// code of the response, and the time taken to handle the request.
+	ObserveRequest(webhook string, statusCode int, duration time.Duration)
+
+	// ObserveVerificationFailure is called when the HMAC signature of a request
+	// cannot be verified.
+	ObserveVerificationFailure(webhook string)
+
+	// ObserveSignOutcome is called with the outcome of each SignTransaction
+	// request handled by a SignTransactionFunc, and the distinct reasons of
+	// its invoice errors, if any.
+	ObserveSignOutcome(outcome SignOutcome, reasons []string)
+
+	// ObserveEventBatch is called with the number of events in each verified
+	// Events request.
+	ObserveEventBatch(size int)
+}
+
+// WithWebhookMetrics specifies the WebhookMetrics that a webhook handler
+// records its requests with.
+func WithWebhookMetrics(metrics WebhookMetrics) WebhookOption {
+	return func(o *webhookOpts) {
+		o.metrics = metrics
+	}
+}
+
+// String returns the name of the outcome.
+func (o SignOutcome) String() string {
+	switch o {
+	case SignOutcomeApproved:
+		return "approved"
+	case SignOutcomeRejected:
+		return "rejected"
+	case SignOutcomeInvoiceErrors:
+		return "invoice_errors"
+	default:
+		return "unknown"
+	}
+}
+
+// instrument returns a handler that records the requests handled by h, if
+// metrics are configured.
+func (o webhookOpts) instrument(webhook string, h http.HandlerFunc) http.HandlerFunc {
+	if o.metrics == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		h(rec, r)
+		o.metrics.ObserveRequest(webhook, rec.statusCode, time.Since(start))
+	}
+}
+
+func (o webhookOpts) observeVerificationFailure(webhook string) {
+	if o.metrics != nil {
+		o.metrics.ObserveVerificationFailure(webhook)
+	}
+}
+
+func (o webhookOpts) observeSignOutcome(resp *SignTransactionResponse) {
+	if o.metrics == nil {
+		return
+	}
+
+	var reasons []string
+	seen := make(map[string]struct{})
+	for _, e := range resp.errors {
+		if _, ok := seen[string(e.Reason)]; ok {
+			continue
+		}
+		seen[string(e.Reason)] = struct{}{}
+		reasons = append(reasons, string(e.Reason))
+	}
+
+	o.metrics.ObserveSignOutcome(resp.Outcome(), reasons)
+}
+
+func (o webhookOpts) observeEventBatch(size int) {
+	if o.metrics != nil {
+		o.metrics.ObserveEventBatch(size)
+	}
+}
+
+// statusRecorder records the status code written to a ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter.WriteHeader.
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	if !r.wroteHeader {
+		r.statusCode = statusCode
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write implements http.ResponseWriter.Write.
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
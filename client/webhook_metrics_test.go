@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type signOutcome struct {
+	outcome SignOutcome
+	reasons []string
+}
+
+type fakeWebhookMetrics struct {
+	sync.Mutex
+	requests             map[string][]int
+	verificationFailures map[string]int
+	signOutcomes         []signOutcome
+	batches              []int
+}
+
+func newFakeWebhookMetrics() *fakeWebhookMetrics {
+	return &fakeWebhookMetrics{
+		requests:             make(map[string][]int),
+		verificationFailures: make(map[string]int),
+	}
+}
+
+func (m *fakeWebhookMetrics) ObserveRequest(webhook string, statusCode int, duration time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.requests[webhook] = append(m.requests[webhook], statusCode)
+}
+
+func (m *fakeWebhookMetrics) ObserveVerificationFailure(webhook string) {
+	m.Lock()
+	defer m.Unlock()
+	m.verificationFailures[webhook]++
+}
+
+func (m *fakeWebhookMetrics) ObserveSignOutcome(outcome SignOutcome, reasons []string) {
+	m.Lock()
+	defer m.Unlock()
+	m.signOutcomes = append(m.signOutcomes, signOutcome{outcome: outcome, reasons: reasons})
+}
+
+func (m *fakeWebhookMetrics) ObserveEventBatch(size int) {
+	m.Lock()
+	defer m.Unlock()
+	m.batches = append(m.batches, size)
+}
+
+func TestWebhookMetrics(t *testing.T) {
+	metrics := newFakeWebhookMetrics()
+
+	eventsHandler := EventsHandler("", func([]events.Event) error { return nil }, WithWebhookMetrics(metrics))
+	body := []byte(`[{"transaction_event":{"kin_version":4,"tx_id":"YQ=="}},{"transaction_event":{"kin_version":4,"tx_id":"Yg=="}}]`)
+	req, err := http.NewRequest(http.MethodPost, "/events", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	eventsHandler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// Unsigned requests fail verification.
+	createHandler := CreateAccountHandler("secret", func(CreateAccountRequest, *CreateAccountResponse) error { return nil }, WithWebhookMetrics(metrics))
+	req, err = http.NewRequest(http.MethodPost, "/create_account", bytes.NewBufferString("{}"))
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	createHandler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	signHandler := SignTransactionHandler("", func(req SignTransactionRequest, resp *SignTransactionResponse) error {
+		if len(req.Payments) > 0 {
+			resp.MarkAlreadyPaid(0)
+			resp.MarkAlreadyPaid(1)
+			resp.MarkSKUNotFound(2)
+		}
+		return nil
+	}, WithWebhookMetrics(metrics))
+	signBody, err := json.Marshal(genRequest(t, false, false, 4))
+	require.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, "/sign_transaction", bytes.NewBuffer(signBody))
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	signHandler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	assert.Equal(t, map[string][]int{
+		WebhookEvents:          {http.StatusOK},
+		WebhookCreateAccount:   {http.StatusUnauthorized},
+		WebhookSignTransaction: {http.StatusForbidden},
+	}, metrics.requests)
+	assert.Equal(t, map[string]int{WebhookCreateAccount: 1}, metrics.verificationFailures)
+	assert.Equal(t, []int{2}, metrics.batches)
+	assert.Equal(t, []signOutcome{
+		{outcome: SignOutcomeInvoiceErrors, reasons: []string{"already_paid", "sku_not_found"}},
+	}, metrics.signOutcomes)
+}
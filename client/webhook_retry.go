@@ -0,0 +1,255 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/pkg/errors"
+)
+
+// EventErrors may be returned by an EventsFunc to report that only some events
+// of a batch failed to be processed. It maps the index of each failed event in
+// the batch to the error it failed with.
+//
+// Agora only supports acknowledging a batch as a whole, so by default, any error
+// causes the entire batch to be redelivered. If an EventRetryQueue is specified
+// with WithEventRetryQueue, the failed events are instead enqueued to be retried,
+// and the batch is acknowledged.
+type EventErrors map[int]error
+
+// Error implements error.Error.
+func (e EventErrors) Error() string {
+	indices := e.indices()
+	if len(indices) == 0 {
+		return "no events failed"
+	}
+	return fmt.Sprintf("%d event(s) failed, event %d: %v", len(indices), indices[0], e[indices[0]])
+}
+
+// indices returns the indices of the failed events, in ascending order.
+func (e EventErrors) indices() []int {
+	indices := make([]int, 0, len(e))
+	for i := range e {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// EventRetryQueue retries the events that an EventsFunc failed to process.
+//
+// EventRetrier is an in-memory implementation.
+type EventRetryQueue interface {
+	// Enqueue enqueues events to be retried. If an error is returned, the
+	// entire batch is redelivered by Agora.
+	Enqueue(ctx context.Context, events []StoredEvent) error
+}
+
+// WithEventRetryQueue specifies an EventRetryQueue that events are enqueued to
+// if an EventsFunc fails with EventErrors. The batch is then acknowledged, so that
+// only the failed events are retried, rather than the entire batch.
+//
+// Errors other than EventErrors still cause the entire batch to be redelivered.
+func WithEventRetryQueue(q EventRetryQueue) WebhookOption {
+	return func(o *webhookOpts) {
+		o.eventRetryQueue = q
+	}
+}
+
+// failedEvents returns the events of batch reported as failed by errs. Indices
+// that are outside of the batch are ignored.
+func failedEvents(batch []StoredEvent, errs EventErrors) []StoredEvent {
+	var failed []StoredEvent
+	for _, i := range errs.indices() {
+		if i >= 0 && i < len(batch) {
+			failed = append(failed, batch[i])
+		}
+	}
+	return failed
+}
+
+// DeadLetterFunc is called with the events that an EventRetrier gave up on, and
+// the last error they failed with.
+type DeadLetterFunc func(event StoredEvent, err error)
+
+type eventRetrierOpts struct {
+	maxAttempts int
+	minDelay    time.Duration
+	maxDelay    time.Duration
+	maxPending  int
+	deadLetter  DeadLetterFunc
+	clock       Clock
+}
+
+// EventRetrierOption configures an EventRetrier.
+type EventRetrierOption func(*eventRetrierOpts)
+
+// WithEventRetryAttempts specifies the maximum number of times an EventRetrier
+// retries an event. Defaults to 5.
+func WithEventRetryAttempts(n int) EventRetrierOption {
+	return func(o *eventRetrierOpts) {
+		o.maxAttempts = n
+	}
+}
+
+// WithEventRetryDelay specifies the delay before an event is first retried,
+// which doubles after every attempt, up to maxDelay. Defaults to 1 second and 1
+// minute.
+func WithEventRetryDelay(minDelay, maxDelay time.Duration) EventRetrierOption {
+	return func(o *eventRetrierOpts) {
+		o.minDelay = minDelay
+		o.maxDelay = maxDelay
+	}
+}
+
+// WithMaxPendingEvents specifies the maximum number of events an EventRetrier
+// holds. Once reached, Enqueue returns ErrEventRetryQueueFull, and the batch is
+// redelivered by Agora. Defaults to 1000.
+func WithMaxPendingEvents(n int) EventRetrierOption {
+	return func(o *eventRetrierOpts) {
+		o.maxPending = n
+	}
+}
+
+// WithDeadLetterFunc specifies a function that is called with the events an
+// EventRetrier gives up on, either because every attempt failed, or because the
+// EventRetrier was closed. By default, they are dropped.
+func WithDeadLetterFunc(f DeadLetterFunc) EventRetrierOption {
+	return func(o *eventRetrierOpts) {
+		o.deadLetter = f
+	}
+}
+
+// WithEventRetrierClock specifies the Clock an EventRetrier waits between
+// attempts with. Defaults to SystemClock.
+func WithEventRetrierClock(clock Clock) EventRetrierOption {
+	return func(o *eventRetrierOpts) {
+		o.clock = clock
+	}
+}
+
+// EventRetrier is an in-memory EventRetryQueue, which retries events in the
+// background, with exponential backoff.
+//
+// Each event is retried individually, by calling the EventsFunc with a batch
+// containing only that event, so events may be processed out of order. Events
+// are not persisted, so pending events are lost if the process exits; they are
+// passed to the DeadLetterFunc if the EventRetrier is closed.
+type EventRetrier struct {
+	f    EventsFunc
+	opts eventRetrierOpts
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	pending int
+	wg      sync.WaitGroup
+}
+
+// NewEventRetrier returns a new EventRetrier that retries events with f.
+func NewEventRetrier(f EventsFunc, opts ...EventRetrierOption) *EventRetrier {
+	r := &EventRetrier{
+		f: f,
+		opts: eventRetrierOpts{
+			maxAttempts: 5,
+			minDelay:    time.Second,
+			maxDelay:    time.Minute,
+			maxPending:  1000,
+		},
+	}
+	for _, o := range opts {
+		o(&r.opts)
+	}
+	r.opts.clock = clockOrSystem(r.opts.clock)
+
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	return r
+}
+
+// Enqueue implements EventRetryQueue.Enqueue.
+func (r *EventRetrier) Enqueue(_ context.Context, events []StoredEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ctx.Err() != nil {
+		return ErrEventRetrierClosed
+	}
+	if r.pending+len(events) > r.opts.maxPending {
+		return ErrEventRetryQueueFull
+	}
+
+	r.pending += len(events)
+	r.wg.Add(len(events))
+	for _, e := range events {
+		go func(e StoredEvent) {
+			defer r.wg.Done()
+			r.retry(e)
+
+			r.mu.Lock()
+			r.pending--
+			r.mu.Unlock()
+		}(e)
+	}
+
+	return nil
+}
+
+// Pending returns the number of events waiting to be retried.
+func (r *EventRetrier) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pending
+}
+
+// Close stops retrying events, passing those still pending to the
+// DeadLetterFunc, and waits for any attempts in progress to complete.
+func (r *EventRetrier) Close() error {
+	r.cancel()
+	r.wg.Wait()
+	return nil
+}
+
+func (r *EventRetrier) retry(e StoredEvent) {
+	delay := r.opts.minDelay
+	var err error
+	for attempt := 0; attempt < r.opts.maxAttempts; attempt++ {
+		select {
+		case <-r.ctx.Done():
+			r.deadLetter(e, ErrEventRetrierClosed)
+			return
+		case <-r.opts.clock.After(delay):
+		}
+
+		if err = r.process(e); err == nil {
+			return
+		}
+
+		if delay *= 2; delay > r.opts.maxDelay {
+			delay = r.opts.maxDelay
+		}
+	}
+
+	if err == nil {
+		err = errors.New("no retry attempts")
+	}
+	r.deadLetter(e, err)
+}
+
+func (r *EventRetrier) process(e StoredEvent) error {
+	err := r.f([]events.Event{e.Event})
+	if errs, ok := err.(EventErrors); ok && len(errs) == 0 {
+		return nil
+	}
+	return err
+}
+
+func (r *EventRetrier) deadLetter(e StoredEvent, err error) {
+	if r.opts.deadLetter != nil {
+		r.opts.deadLetter(e, err)
+	}
+}
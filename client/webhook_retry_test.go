@@ -0,0 +1,186 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEventRetryQueue struct {
+	enqueued []StoredEvent
+	err      error
+}
+
+func (q *fakeEventRetryQueue) Enqueue(_ context.Context, events []StoredEvent) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.enqueued = append(q.enqueued, events...)
+	return nil
+}
+
+func genRetryEvents(t *testing.T, n int) []StoredEvent {
+	stored := make([]StoredEvent, n)
+	for i := range stored {
+		e := events.Event{
+			TransactionEvent: &events.TransactionEvent{
+				KinVersion: 4,
+				TxID:       []byte{byte(i)},
+			},
+		}
+		raw, err := json.Marshal(e)
+		require.NoError(t, err)
+		stored[i] = newStoredEvents([]events.Event{e}, []json.RawMessage{raw})[0]
+	}
+	return stored
+}
+
+func TestEventsHandler_EventErrors(t *testing.T) {
+	stored := genRetryEvents(t, 3)
+	decoded := []events.Event{stored[0].Event, stored[1].Event, stored[2].Event}
+	body, err := json.Marshal(decoded)
+	require.NoError(t, err)
+
+	makeReq := func() *http.Request {
+		h := hmac.New(sha256.New, []byte("secret"))
+		_, _ = h.Write(body)
+
+		req, err := http.NewRequest(http.MethodPost, "/events", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.Header.Add(AgoraHMACHeader, base64.StdEncoding.EncodeToString(h.Sum(nil)))
+		return req
+	}
+
+	f := func([]events.Event) error {
+		return EventErrors{1: errors.New("failed"), 5: errors.New("out of range")}
+	}
+
+	// Without a retry queue, the whole batch is redelivered.
+	rr := httptest.NewRecorder()
+	EventsHandler("secret", f).ServeHTTP(rr, makeReq())
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	// With a retry queue, only the failed events are retried.
+	queue := &fakeEventRetryQueue{}
+	rr = httptest.NewRecorder()
+	EventsHandler("secret", f, WithEventRetryQueue(queue)).ServeHTTP(rr, makeReq())
+	assert.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, queue.enqueued, 1)
+	assert.Equal(t, stored[1].Event, queue.enqueued[0].Event)
+
+	// Other errors still cause the batch to be redelivered.
+	queue = &fakeEventRetryQueue{}
+	rr = httptest.NewRecorder()
+	EventsHandler("secret", func([]events.Event) error {
+		return errors.New("failed")
+	}, WithEventRetryQueue(queue)).ServeHTTP(rr, makeReq())
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Empty(t, queue.enqueued)
+
+	// As do events that cannot be enqueued.
+	queue = &fakeEventRetryQueue{err: ErrEventRetryQueueFull}
+	rr = httptest.NewRecorder()
+	EventsHandler("secret", f, WithEventRetryQueue(queue)).ServeHTTP(rr, makeReq())
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestEventErrors_Error(t *testing.T) {
+	assert.Equal(t, "no events failed", EventErrors{}.Error())
+	assert.Equal(t, "2 event(s) failed, event 1: b", EventErrors{3: errors.New("a"), 1: errors.New("b")}.Error())
+}
+
+func TestEventRetrier(t *testing.T) {
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	stored := genRetryEvents(t, 3)
+
+	var mu sync.Mutex
+	attempts := make(map[byte]int)
+	processed := make(map[byte]bool)
+	f := func(batch []events.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		require.Len(t, batch, 1)
+		id := batch[0].TransactionEvent.TxID[0]
+		attempts[id]++
+
+		switch id {
+		case 0:
+			processed[id] = true
+			return nil
+		case 1:
+			if attempts[id] < 3 {
+				return EventErrors{0: errors.New("transient")}
+			}
+			processed[id] = true
+			return EventErrors{}
+		default:
+			return errors.New("permanent")
+		}
+	}
+
+	var dead []StoredEvent
+	r := NewEventRetrier(
+		f,
+		WithEventRetryAttempts(4),
+		WithEventRetryDelay(time.Second, 2*time.Second),
+		WithEventRetrierClock(clock),
+		WithDeadLetterFunc(func(e StoredEvent, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.EqualError(t, err, "permanent")
+			dead = append(dead, e)
+		}),
+	)
+
+	require.NoError(t, r.Enqueue(context.Background(), stored))
+	require.Eventually(t, func() bool { return r.Pending() == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, r.Close())
+
+	assert.Equal(t, map[byte]bool{0: true, 1: true}, processed)
+	assert.Equal(t, map[byte]int{0: 1, 1: 3, 2: 4}, attempts)
+	require.Len(t, dead, 1)
+	assert.Equal(t, stored[2].ID, dead[0].ID)
+
+	assert.Equal(t, ErrEventRetrierClosed, r.Enqueue(context.Background(), stored))
+}
+
+func TestEventRetrier_Limits(t *testing.T) {
+	stored := genRetryEvents(t, 3)
+
+	var mu sync.Mutex
+	var dead []error
+	r := NewEventRetrier(
+		func([]events.Event) error { return nil },
+		WithMaxPendingEvents(2),
+		WithEventRetryDelay(time.Hour, time.Hour),
+		WithDeadLetterFunc(func(e StoredEvent, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			dead = append(dead, err)
+		}),
+	)
+
+	assert.Equal(t, ErrEventRetryQueueFull, r.Enqueue(context.Background(), stored))
+	require.NoError(t, r.Enqueue(context.Background(), stored[:2]))
+	assert.Equal(t, 2, r.Pending())
+	assert.Equal(t, ErrEventRetryQueueFull, r.Enqueue(context.Background(), stored[2:]))
+
+	// Pending events are dead lettered when the retrier is closed.
+	require.NoError(t, r.Close())
+	assert.Zero(t, r.Pending())
+	assert.Equal(t, []error{ErrEventRetrierClosed, ErrEventRetrierClosed}, dead)
+}
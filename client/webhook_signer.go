@@ -0,0 +1,98 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/pkg/errors"
+)
+
+// TransactionSigner signs Solana transaction messages on behalf of a single
+// account. It allows the subsidizer key to be held outside of the process,
+// such as in a KMS or HSM.
+type TransactionSigner interface {
+	// PublicKey returns the public key of the signing account.
+	PublicKey() kin.PublicKey
+
+	// Sign returns the ed25519 signature of message.
+	Sign(message []byte) ([]byte, error)
+}
+
+type privateKeySigner struct {
+	priv kin.PrivateKey
+}
+
+// NewPrivateKeySigner returns a TransactionSigner that signs with priv.
+func NewPrivateKeySigner(priv kin.PrivateKey) TransactionSigner {
+	return privateKeySigner{priv: priv}
+}
+
+// PublicKey implements TransactionSigner.PublicKey.
+func (s privateKeySigner) PublicKey() kin.PublicKey {
+	return s.priv.Public()
+}
+
+// Sign implements TransactionSigner.Sign.
+func (s privateKeySigner) Sign(message []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(s.priv), message), nil
+}
+
+// WithSubsidizerSigner specifies the signer that the SignTransaction and
+// CreateAccount handlers automatically sign approved transactions with.
+//
+// Transactions are only signed if the signer is their fee payer, and they have
+// not already been signed by the handler function. Transactions paid for by
+// another subsidizer (such as Agora's) are approved without a signature.
+func WithSubsidizerSigner(signer TransactionSigner) WebhookOption {
+	return func(o *webhookOpts) {
+		o.subsidizer = signer
+	}
+}
+
+// autoSign signs tx with the configured subsidizer, if any.
+func (o webhookOpts) autoSign(tx *solana.Transaction) error {
+	if o.subsidizer == nil || len(tx.Signatures) == 0 || tx.Signatures[0] != (solana.Signature{}) {
+		return nil
+	}
+
+	err := signFeePayer(tx, o.subsidizer)
+	if err == ErrNotFeePayer {
+		return nil
+	}
+	return err
+}
+
+// signFeePayer sets the fee payer signature of tx using signer.
+func signFeePayer(tx *solana.Transaction, signer TransactionSigner) error {
+	if len(tx.Signatures) == 0 || len(tx.Signatures) > len(tx.Message.Accounts) {
+		return errors.New("invalid transaction: invalid number of signatures")
+	}
+
+	pub := signer.PublicKey()
+	if !bytes.Equal(pub, tx.Message.Accounts[0]) {
+		return ErrNotFeePayer
+	}
+
+	message := tx.Message.Marshal()
+	sig, err := signer.Sign(message)
+	if err != nil {
+		return errors.Wrap(err, "failed to sign transaction")
+	}
+	if len(sig) != ed25519.SignatureSize || !ed25519.Verify(ed25519.PublicKey(pub), message, sig) {
+		return errors.Wrap(ErrInvalidSignature, "signer produced an invalid signature")
+	}
+
+	copy(tx.Signatures[0][:], sig)
+	return nil
+}
+
+// feePayerSignature returns the fee payer signature of tx, or nil if it has
+// not been signed.
+func feePayerSignature(tx *solana.Transaction) []byte {
+	if len(tx.Signatures) == 0 || tx.Signatures[0] == (solana.Signature{}) {
+		return nil
+	}
+	return tx.Signature()
+}
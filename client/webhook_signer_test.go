@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/solana/token"
+	"github.com/kinecosystem/agora-common/webhook/signtransaction"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client/testutil"
+)
+
+type failingSigner struct {
+	pub kin.PublicKey
+	sig []byte
+	err error
+}
+
+func (s failingSigner) PublicKey() kin.PublicKey    { return s.pub }
+func (s failingSigner) Sign([]byte) ([]byte, error) { return s.sig, s.err }
+
+func genSignedRequest(t *testing.T, subsidizer ed25519.PrivateKey) signtransaction.Request {
+	sender := testutil.GenerateSolanaKeypair(t)
+	dest := testutil.GenerateSolanaKeypair(t)
+
+	tx := solana.NewTransaction(
+		subsidizer.Public().(ed25519.PublicKey),
+		token.Transfer(
+			sender.Public().(ed25519.PublicKey),
+			dest.Public().(ed25519.PublicKey),
+			sender.Public().(ed25519.PublicKey),
+			10,
+		),
+	)
+	require.NoError(t, tx.Sign(sender))
+
+	return signtransaction.Request{
+		KinVersion:        4,
+		SolanaTransaction: tx.Marshal(),
+	}
+}
+
+func TestSignTransactionResponse_SignWith(t *testing.T) {
+	subsidizer := testutil.GenerateSolanaKeypair(t)
+	other := testutil.GenerateSolanaKeypair(t)
+
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(genSignedRequest(t, subsidizer).SolanaTransaction))
+	resp := &SignTransactionResponse{tx: &tx}
+	assert.Nil(t, resp.Signature())
+
+	// Only the fee payer may sign.
+	assert.Equal(t, ErrNotFeePayer, resp.SignWith(NewPrivateKeySigner(kin.PrivateKey(other))))
+	assert.Nil(t, resp.Signature())
+
+	// Signers producing invalid signatures are rejected.
+	pub := kin.PublicKey(subsidizer.Public().(ed25519.PublicKey))
+	signErr := errors.New("kms unavailable")
+	assert.Error(t, resp.SignWith(failingSigner{pub: pub, err: signErr}))
+	assert.True(t, errors.Is(resp.SignWith(failingSigner{pub: pub, sig: make([]byte, 64)}), ErrInvalidSignature))
+	assert.Nil(t, resp.Signature())
+
+	require.NoError(t, resp.SignWith(NewPrivateKeySigner(kin.PrivateKey(subsidizer))))
+	assert.True(t, ed25519.Verify(subsidizer.Public().(ed25519.PublicKey), tx.Message.Marshal(), resp.Signature()))
+
+	// The signature is the same as a signature produced by Sign.
+	expected := resp.Signature()
+	tx.Signatures[0] = solana.Signature{}
+	require.NoError(t, resp.Sign(kin.PrivateKey(subsidizer)))
+	assert.Equal(t, expected, resp.Signature())
+}
+
+func TestSignTransactionHandler_SubsidizerSigner(t *testing.T) {
+	subsidizer := testutil.GenerateSolanaKeypair(t)
+	signer := NewPrivateKeySigner(kin.PrivateKey(subsidizer))
+
+	reject := false
+	handler := SignTransactionHandler("", func(req SignTransactionRequest, resp *SignTransactionResponse) error {
+		if reject {
+			resp.Reject()
+		}
+		return nil
+	}, WithSubsidizerSigner(signer))
+
+	send := func(data signtransaction.Request) *httptest.ResponseRecorder {
+		body, err := json.Marshal(data)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "/sign_transaction", bytes.NewBuffer(body))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Approved transactions paid for by the subsidizer are signed.
+	data := genSignedRequest(t, subsidizer)
+	rr := send(data)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp signtransaction.SuccessResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(data.SolanaTransaction))
+	assert.True(t, ed25519.Verify(subsidizer.Public().(ed25519.PublicKey), tx.Message.Marshal(), resp.Signature))
+
+	// Transactions paid for by another subsidizer are approved without a
+	// signature.
+	rr = send(genSignedRequest(t, testutil.GenerateSolanaKeypair(t)))
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp = signtransaction.SuccessResponse{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Nil(t, resp.Signature)
+
+	// Rejected transactions are not signed.
+	reject = true
+	rr = send(genSignedRequest(t, subsidizer))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "signature")
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -588,6 +589,8 @@ func TestSignTransactionHandler_InvoiceErrors(t *testing.T) {
 		resp.MarkAlreadyPaid(0)
 		resp.MarkWrongDestination(3)
 		resp.MarkSKUNotFound(5)
+		resp.MarkWrongAmount(1)
+		resp.MarkExpired(2)
 
 		return nil
 	}
@@ -620,6 +623,8 @@ func TestSignTransactionHandler_InvoiceErrors(t *testing.T) {
 		var resp signtransaction.ForbiddenResponse
 		assert.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&resp))
 		assert.Equal(t, resp.Message, "rejected")
+		// Payments with wrong amounts or expired invoices reject the transaction,
+		// but aren't included as invoice errors, since Agora doesn't define them.
 		assert.Len(t, resp.InvoiceErrors, 3)
 
 		assert.EqualValues(t, resp.InvoiceErrors[0].OperationIndex, 0)
@@ -824,3 +829,266 @@ func genRequest(t *testing.T, useInvoice, useMemo bool, version int) signtransac
 	require.NoError(t, err)
 	return req
 }
+
+func TestSignTransactionRequest_Accessors(t *testing.T) {
+	subsidizer := testutil.GenerateSolanaKeypair(t)
+	sender := testutil.GenerateSolanaKeypair(t)
+	owner := testutil.GenerateSolanaKeypair(t)
+	dest := testutil.GenerateSolanaKeypair(t)
+	created := testutil.GenerateSolanaKeypair(t)
+
+	tx := solana.NewTransaction(
+		subsidizer.Public().(ed25519.PublicKey),
+		memo.Instruction("1-test"),
+		token.Transfer(sender.Public().(ed25519.PublicKey), dest.Public().(ed25519.PublicKey), owner.Public().(ed25519.PublicKey), 10),
+		token.Transfer(sender.Public().(ed25519.PublicKey), created.Public().(ed25519.PublicKey), owner.Public().(ed25519.PublicKey), 20),
+	)
+
+	createdOwner := testutil.GenerateSolanaKeypair(t)
+	req := SignTransactionRequest{
+		Creations: []Creation{
+			{Address: kin.PublicKey(created.Public().(ed25519.PublicKey)), Owner: kin.PublicKey(createdOwner.Public().(ed25519.PublicKey))},
+		},
+		SolanaTransaction: &tx,
+	}
+
+	assert.Equal(t, 3, req.InstructionCount())
+	assert.EqualValues(t, subsidizer.Public(), req.FeePayer())
+	assert.Equal(t, []string{"1-test"}, req.Memos())
+
+	transfers := req.Transfers()
+	require.Len(t, transfers, 2)
+	assert.EqualValues(t, sender.Public(), transfers[0].Source)
+	assert.EqualValues(t, dest.Public(), transfers[0].Destination)
+	assert.EqualValues(t, owner.Public(), transfers[0].Owner)
+	assert.Nil(t, transfers[0].DestinationOwner)
+	assert.EqualValues(t, 10, transfers[0].Quarks)
+	assert.EqualValues(t, createdOwner.Public(), transfers[1].DestinationOwner)
+	assert.EqualValues(t, 20, transfers[1].Quarks)
+
+	// The owner has not signed yet.
+	assert.Equal(t, ErrInvalidSignature, errors.Cause(req.VerifySignatures()))
+
+	// The fee payer signature is optional.
+	require.NoError(t, tx.Sign(owner))
+	assert.NoError(t, req.VerifySignatures())
+
+	tx.Signatures[1][0] ^= 1
+	assert.Equal(t, ErrInvalidSignature, errors.Cause(req.VerifySignatures()))
+	tx.Signatures[1][0] ^= 1
+
+	require.NoError(t, tx.Sign(subsidizer))
+	assert.NoError(t, req.VerifySignatures())
+
+	empty := SignTransactionRequest{}
+	assert.Zero(t, empty.InstructionCount())
+	assert.Nil(t, empty.FeePayer())
+	assert.Nil(t, empty.Transfers())
+	assert.Error(t, empty.VerifySignatures())
+}
+
+func TestSignTransactionResponse_Outcome(t *testing.T) {
+	resp := &SignTransactionResponse{payments: 2}
+	assert.False(t, resp.IsRejected())
+	assert.Equal(t, SignOutcomeApproved, resp.Outcome())
+
+	// Partially approved transactions are rejected.
+	resp.MarkApproved(0)
+	resp.MarkApproved(5)
+	assert.True(t, resp.IsRejected())
+	assert.Equal(t, SignOutcomeRejected, resp.Outcome())
+
+	resp.MarkApproved(1)
+	assert.False(t, resp.IsRejected())
+	assert.Equal(t, SignOutcomeApproved, resp.Outcome())
+
+	resp.Reject()
+	assert.Equal(t, SignOutcomeRejected, resp.Outcome())
+
+	resp = &SignTransactionResponse{payments: 2}
+	resp.MarkWrongDestination(1)
+	assert.True(t, resp.IsRejected())
+	assert.Equal(t, SignOutcomeInvoiceErrors, resp.Outcome())
+
+	assert.Equal(t, []signtransaction.InvoiceError{{OperationIndex: 1, Reason: signtransaction.WrongDestination}}, resp.InvoiceErrors())
+
+	// Agora has no invoice errors for wrong amounts or expired invoices, so
+	// the transaction is rejected without reason.
+	for _, mark := range []func(*SignTransactionResponse, int){
+		(*SignTransactionResponse).MarkWrongAmount,
+		(*SignTransactionResponse).MarkExpired,
+	} {
+		resp = &SignTransactionResponse{payments: 2}
+		mark(resp, 0)
+		assert.True(t, resp.IsRejected())
+		assert.Equal(t, SignOutcomeRejected, resp.Outcome())
+		assert.Empty(t, resp.InvoiceErrors())
+	}
+}
+
+func TestSignTransactionHandler_PartialApproval(t *testing.T) {
+	f := func(req SignTransactionRequest, resp *SignTransactionResponse) error {
+		for i := 0; i < len(req.Payments)-1; i++ {
+			resp.MarkApproved(i)
+		}
+		return nil
+	}
+
+	body, err := json.Marshal(genRequest(t, false, false, 4))
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, "/sign_transaction", bytes.NewBuffer(body))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	SignTransactionHandler("", f).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	var resp signtransaction.ForbiddenResponse
+	require.NoError(t, json.NewDecoder(rr.Result().Body).Decode(&resp))
+	assert.Empty(t, resp.InvoiceErrors)
+}
+
+func TestWebhook_SecretRotation(t *testing.T) {
+	var called int
+	f := func([]events.Event) error {
+		called++
+		return nil
+	}
+
+	body, err := json.Marshal([]events.Event{})
+	require.NoError(t, err)
+
+	makeReq := func(secret, keyID string) *http.Request {
+		h := hmac.New(sha256.New, []byte(secret))
+		_, _ = h.Write(body)
+
+		req, err := http.NewRequest(http.MethodPost, "/events", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.Header.Add(AgoraHMACHeader, base64.StdEncoding.EncodeToString(h.Sum(nil)))
+		if keyID != "" {
+			req.Header.Add(AgoraHMACKeyIDHeader, keyID)
+		}
+		return req
+	}
+
+	handler := EventsHandler("current", f, WithPreviousSecrets("previous"), WithKeyedSecret("k1", "keyed"))
+
+	for _, tc := range []struct {
+		secret string
+		keyID  string
+		code   int
+	}{
+		{"current", "", http.StatusOK},
+		{"previous", "", http.StatusOK},
+		{"keyed", "", http.StatusOK},
+		{"keyed", "k1", http.StatusOK},
+		{"current", "k1", http.StatusUnauthorized},
+		{"keyed", "k2", http.StatusUnauthorized},
+		{"other", "", http.StatusUnauthorized},
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, makeReq(tc.secret, tc.keyID))
+		assert.Equal(t, tc.code, rr.Code, "secret: %s, key id: %s", tc.secret, tc.keyID)
+	}
+	assert.Equal(t, 4, called)
+
+	// Previous secrets are still verified if the primary secret is empty.
+	handler = EventsHandler("", f, WithPreviousSecrets("previous"))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq("other", ""))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq("previous", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Empty keyed secrets are ignored, rather than accepting requests signed
+	// with an empty key.
+	handler = EventsHandler("current", f, WithKeyedSecret("k1", ""))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq("", "k1"))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq("", ""))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestWebhook_MaxBodyBytes(t *testing.T) {
+	var called int
+	f := func([]events.Event) error {
+		called++
+		return nil
+	}
+
+	data := make([]events.Event, 64)
+	for i := range data {
+		data[i] = events.Event{
+			TransactionEvent: &events.TransactionEvent{
+				KinVersion: 4,
+				TxID:       []byte(fmt.Sprintf("tx-%d", i)),
+				SolanaEvent: &events.SolanaEvent{
+					Transaction: []byte("transaction"),
+				},
+			},
+		}
+	}
+	body, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	makeReq := func(path, secret string, body []byte) *http.Request {
+		h := hmac.New(sha256.New, []byte(secret))
+		_, _ = h.Write(body)
+
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(body))
+		require.NoError(t, err)
+		req.Header.Add(AgoraHMACHeader, base64.StdEncoding.EncodeToString(h.Sum(nil)))
+		return req
+	}
+
+	// Event batches within the limit are decoded and verified as a stream.
+	handler := EventsHandler("secret", func(received []events.Event) error {
+		called++
+		assert.Equal(t, data, received)
+		return nil
+	}, WithMaxBodyBytes(int64(len(body))), WithPreviousSecrets("previous"))
+	for _, secret := range []string{"secret", "previous"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, makeReq("/events", secret, body))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+	assert.Equal(t, 2, called)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, makeReq("/events", "other", body))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// A valid signature over a malformed body is a bad request.
+	for _, invalid := range [][]byte{[]byte("{}"), []byte("[{}"), append(append([]byte{}, body...), []byte("[]")...)} {
+		rr = httptest.NewRecorder()
+		EventsHandler("secret", f).ServeHTTP(rr, makeReq("/events", "secret", invalid))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, string(invalid))
+	}
+
+	// Bodies over the limit are rejected before verification.
+	limit := int64(len(body) - 1)
+	handlers := map[string]http.Handler{
+		"/events":           EventsHandler("secret", f, WithMaxBodyBytes(limit)),
+		"/create_account":   CreateAccountHandler("secret", func(CreateAccountRequest, *CreateAccountResponse) error { t.Fail(); return nil }, WithMaxBodyBytes(limit)),
+		"/sign_transaction": SignTransactionHandler("secret", func(SignTransactionRequest, *SignTransactionResponse) error { t.Fail(); return nil }, WithMaxBodyBytes(limit)),
+	}
+	for path, handler := range handlers {
+		for _, secret := range []string{"secret", "other"} {
+			rr = httptest.NewRecorder()
+			handler.ServeHTTP(rr, makeReq(path, secret, body))
+			assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, path)
+			assert.Contains(t, rr.Body.String(), fmt.Sprintf("%d bytes", limit))
+		}
+	}
+	assert.Equal(t, 2, called)
+
+	// A non-positive limit disables the check.
+	rr = httptest.NewRecorder()
+	EventsHandler("secret", f, WithMaxBodyBytes(0)).ServeHTTP(rr, makeReq("/events", "secret", body))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 3, called)
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/kinecosystem/agora-common/webhook/signtransaction"
+	"github.com/pkg/errors"
+
+	commonpb "github.com/kinecosystem/agora-api/genproto/common/v3"
+)
+
+// The functions in this file expose the verification and decoding performed by
+// EventsHandler and SignTransactionHandler, for webhooks served by frameworks
+// that do not use http.HandlerFunc, such as AWS Lambda.
+
+// VerifyWebhookSignature verifies the signature of a webhook request body, where
+// signature is the value of the request's AgoraHMACHeader header. The body must
+// be signed with one of secrets.
+//
+// An error whose cause is ErrInvalidSignature is returned if the signature is
+// missing or invalid.
+func VerifyWebhookSignature(signature string, body []byte, secrets ...string) error {
+	o := newWebhookOpts("", WithPreviousSecrets(secrets...))
+	if len(o.secrets) == 0 {
+		return errors.New("no webhook secrets specified")
+	}
+
+	header := make(http.Header)
+	header.Set(AgoraHMACHeader, signature)
+	if err := o.verify(header, body); err != nil {
+		return errors.Wrap(ErrInvalidSignature, err.Error())
+	}
+	return nil
+}
+
+// ParseEventsBody decodes the body of an Events webhook request. The signature
+// of the body should be verified first, with VerifyWebhookSignature.
+func ParseEventsBody(body []byte) ([]events.Event, error) {
+	result, _, err := decodeEvents(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid body")
+	}
+	return result, nil
+}
+
+// ParseSignRequestBody decodes the body of a Sign Transaction webhook request.
+// The signature of the body should be verified first, with
+// VerifyWebhookSignature.
+//
+// The UserID, UserPasskey and RequestID of the returned request are set from
+// headers, rather than the body, so it is up to the caller to set them (see
+// AppUserIDHeader, AppUserPasskeyHeader and RequestIDHeader).
+func ParseSignRequestBody(body []byte) (SignTransactionRequest, error) {
+	var signRequest signtransaction.Request
+	if err := json.Unmarshal(body, &signRequest); err != nil {
+		return SignTransactionRequest{}, errors.Wrap(err, "invalid body")
+	}
+
+	// If no kin version is set, default to Kin 4
+	if signRequest.KinVersion == 0 {
+		signRequest.KinVersion = 4
+	}
+	if signRequest.KinVersion != 4 {
+		return SignTransactionRequest{}, errors.Errorf("unsupported kin version %d", signRequest.KinVersion)
+	}
+
+	var invoiceList *commonpb.InvoiceList
+	if len(signRequest.InvoiceList) > 0 {
+		invoiceList = &commonpb.InvoiceList{}
+		if err := proto.Unmarshal(signRequest.InvoiceList, invoiceList); err != nil {
+			return SignTransactionRequest{}, errors.Wrap(err, "invalid invoice list")
+		}
+	}
+
+	var tx solana.Transaction
+	if err := tx.Unmarshal(signRequest.SolanaTransaction); err != nil {
+		return SignTransactionRequest{}, errors.Wrap(err, "invalid solana tx")
+	}
+
+	req := SignTransactionRequest{SolanaTransaction: &tx}
+	var err error
+	if req.Creations, req.Payments, err = parseTransaction(tx, invoiceList); err != nil {
+		return SignTransactionRequest{}, err
+	}
+	return req, nil
+}
+
+// NewSignTransactionResponse returns the response to req, which approves the
+// transaction until it is rejected.
+func NewSignTransactionResponse(req SignTransactionRequest) *SignTransactionResponse {
+	return &SignTransactionResponse{
+		tx:       req.SolanaTransaction,
+		payments: len(req.Payments),
+	}
+}
+
+// Encode returns the HTTP status code and JSON body of the response to Agora.
+// Approved transactions should be signed first (see Sign).
+func (r *SignTransactionResponse) Encode() (statusCode int, body []byte, err error) {
+	var encoded bytes.Buffer
+	if r.IsRejected() {
+		statusCode = http.StatusForbidden
+		err = json.NewEncoder(&encoded).Encode(&signtransaction.ForbiddenResponse{
+			Message:       "rejected",
+			InvoiceErrors: r.errors,
+		})
+	} else {
+		statusCode = http.StatusOK
+		err = json.NewEncoder(&encoded).Encode(&signtransaction.SuccessResponse{
+			Signature: r.Signature(),
+		})
+	}
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "failed to encode response")
+	}
+
+	return statusCode, encoded.Bytes(), nil
+}
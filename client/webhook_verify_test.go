@@ -0,0 +1,133 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/kinecosystem/agora-common/webhook/signtransaction"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"hello":"world"}`)
+
+	sign := func(secret string) string {
+		h := hmac.New(sha256.New, []byte(secret))
+		_, _ = h.Write(body)
+		return base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+
+	assert.NoError(t, VerifyWebhookSignature(sign("secret"), body, "secret"))
+	assert.NoError(t, VerifyWebhookSignature(sign("old"), body, "secret", "old"))
+
+	for _, sig := range []string{"", "invalid", sign("other")} {
+		err := VerifyWebhookSignature(sig, body, "secret")
+		assert.Equal(t, ErrInvalidSignature, errors.Cause(err))
+	}
+	err := VerifyWebhookSignature(sign("secret"), []byte("tampered"), "secret")
+	assert.Equal(t, ErrInvalidSignature, errors.Cause(err))
+
+	assert.Error(t, VerifyWebhookSignature(sign("secret"), body))
+}
+
+func TestParseEventsBody(t *testing.T) {
+	data := []events.Event{
+		{
+			TransactionEvent: &events.TransactionEvent{
+				KinVersion: 4,
+				TxID:       []byte("sig"),
+			},
+		},
+	}
+	body, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	actual, err := ParseEventsBody(body)
+	require.NoError(t, err)
+	assert.Equal(t, data, actual)
+
+	_, err = ParseEventsBody([]byte("{"))
+	assert.Error(t, err)
+}
+
+func TestParseSignRequestBody(t *testing.T) {
+	data := genRequest(t, true, false, 4)
+	body, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	req, err := ParseSignRequestBody(body)
+	require.NoError(t, err)
+	require.NotNil(t, req.SolanaTransaction)
+	assert.EqualValues(t, data.SolanaTransaction, req.SolanaTransaction.Marshal())
+	require.Len(t, req.Payments, 10)
+	for _, p := range req.Payments {
+		assert.NotNil(t, p.Invoice)
+	}
+
+	// Requests without a kin version default to Kin 4.
+	data.KinVersion = 0
+	body, err = json.Marshal(data)
+	require.NoError(t, err)
+	_, err = ParseSignRequestBody(body)
+	assert.NoError(t, err)
+
+	for _, invalid := range []func(r *signtransaction.Request){
+		func(r *signtransaction.Request) { r.KinVersion = 3 },
+		func(r *signtransaction.Request) { r.SolanaTransaction = []byte("invalid") },
+		func(r *signtransaction.Request) { r.InvoiceList = []byte("invalid") },
+	} {
+		data := genRequest(t, true, false, 4)
+		invalid(&data)
+		body, err := json.Marshal(data)
+		require.NoError(t, err)
+
+		_, err = ParseSignRequestBody(body)
+		assert.Error(t, err)
+	}
+
+	_, err = ParseSignRequestBody([]byte("{"))
+	assert.Error(t, err)
+}
+
+func TestSignTransactionResponse_Encode(t *testing.T) {
+	body, err := json.Marshal(genRequest(t, true, false, 4))
+	require.NoError(t, err)
+	req, err := ParseSignRequestBody(body)
+	require.NoError(t, err)
+
+	whitelist, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	resp := NewSignTransactionResponse(req)
+	require.NoError(t, resp.Sign(whitelist))
+
+	status, encoded, err := resp.Encode()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	var success signtransaction.SuccessResponse
+	require.NoError(t, json.Unmarshal(encoded, &success))
+	assert.Equal(t, resp.Signature(), success.Signature)
+
+	resp = NewSignTransactionResponse(req)
+	resp.MarkAlreadyPaid(1)
+
+	status, encoded, err = resp.Encode()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	var forbidden signtransaction.ForbiddenResponse
+	require.NoError(t, json.Unmarshal(encoded, &forbidden))
+	assert.Equal(t, "rejected", forbidden.Message)
+	require.Len(t, forbidden.InvoiceErrors, 1)
+	assert.EqualValues(t, 1, forbidden.InvoiceErrors[0].OperationIndex)
+	assert.Equal(t, signtransaction.AlreadyPaid, forbidden.InvoiceErrors[0].Reason)
+}
@@ -0,0 +1,152 @@
+// Package webhookmetrics provides a client.WebhookMetrics implementation that
+// exports Prometheus metrics.
+package webhookmetrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+type opts struct {
+	namespace string
+	registry  *prometheus.Registry
+}
+
+// Option configures the Metrics returned by New.
+type Option func(*opts)
+
+// WithNamespace specifies the namespace of the metric names. Defaults to "kin".
+func WithNamespace(namespace string) Option {
+	return func(o *opts) {
+		o.namespace = namespace
+	}
+}
+
+// WithRegistry specifies the registry the metrics are registered with, and
+// served from by Handler. Defaults to a new registry.
+func WithRegistry(registry *prometheus.Registry) Option {
+	return func(o *opts) {
+		o.registry = registry
+	}
+}
+
+// Metrics records webhook metrics with Prometheus. The exported metrics are:
+//
+//	webhook_requests_total{webhook, code}
+//	webhook_request_duration_seconds{webhook}
+//	webhook_verification_failures_total{webhook}
+//	webhook_sign_outcomes_total{outcome, reason}
+//	webhook_event_batch_size
+//
+// Where webhook is one of client.WebhookEvents, client.WebhookCreateAccount,
+// or client.WebhookSignTransaction. Sign outcomes without invoice errors have
+// the reason "none".
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests             *prometheus.CounterVec
+	durations            *prometheus.HistogramVec
+	verificationFailures *prometheus.CounterVec
+	signOutcomes         *prometheus.CounterVec
+	eventBatchSizes      prometheus.Histogram
+}
+
+var _ client.WebhookMetrics = (*Metrics)(nil)
+
+// New returns a new Metrics, registered with the configured registry.
+func New(options ...Option) (*Metrics, error) {
+	o := opts{
+		namespace: "kin",
+	}
+	for _, opt := range options {
+		opt(&o)
+	}
+	if o.registry == nil {
+		o.registry = prometheus.NewRegistry()
+	}
+
+	m := &Metrics{
+		registry: o.registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Subsystem: "webhook",
+			Name:      "requests_total",
+			Help:      "Number of webhook requests, by response status code.",
+		}, []string{"webhook", "code"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Subsystem: "webhook",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to handle webhook requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"webhook"}),
+		verificationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Subsystem: "webhook",
+			Name:      "verification_failures_total",
+			Help:      "Number of webhook requests with an invalid HMAC signature.",
+		}, []string{"webhook"}),
+		signOutcomes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Subsystem: "webhook",
+			Name:      "sign_outcomes_total",
+			Help:      "Number of SignTransaction approvals and rejections, by invoice error reason.",
+		}, []string{"outcome", "reason"}),
+		eventBatchSizes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Subsystem: "webhook",
+			Name:      "event_batch_size",
+			Help:      "Number of events in Events webhook requests.",
+			Buckets:   []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
+	}
+
+	for _, c := range []prometheus.Collector{m.requests, m.durations, m.verificationFailures, m.signOutcomes, m.eventBatchSizes} {
+		if err := o.registry.Register(c); err != nil {
+			return nil, errors.Wrap(err, "failed to register metric")
+		}
+	}
+
+	return m, nil
+}
+
+// Handler returns an http.Handler that serves the metrics of the registry, for
+// use as a /metrics endpoint.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest implements client.WebhookMetrics.ObserveRequest.
+func (m *Metrics) ObserveRequest(webhook string, statusCode int, duration time.Duration) {
+	m.requests.WithLabelValues(webhook, strconv.Itoa(statusCode)).Inc()
+	m.durations.WithLabelValues(webhook).Observe(duration.Seconds())
+}
+
+// ObserveVerificationFailure implements client.WebhookMetrics.ObserveVerificationFailure.
+func (m *Metrics) ObserveVerificationFailure(webhook string) {
+	m.verificationFailures.WithLabelValues(webhook).Inc()
+}
+
+// ObserveSignOutcome implements client.WebhookMetrics.ObserveSignOutcome.
+func (m *Metrics) ObserveSignOutcome(outcome client.SignOutcome, reasons []string) {
+	if len(reasons) == 0 {
+		m.signOutcomes.WithLabelValues(outcome.String(), "none").Inc()
+		return
+	}
+
+	for _, reason := range reasons {
+		m.signOutcomes.WithLabelValues(outcome.String(), reason).Inc()
+	}
+}
+
+// ObserveEventBatch implements client.WebhookMetrics.ObserveEventBatch.
+func (m *Metrics) ObserveEventBatch(size int) {
+	m.eventBatchSizes.Observe(float64(size))
+}
@@ -0,0 +1,70 @@
+package webhookmetrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kinecosystem/agora-common/webhook/events"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kinecosystem/kin-go/client"
+)
+
+func TestMetrics(t *testing.T) {
+	m, err := New()
+	require.NoError(t, err)
+
+	handler := client.EventsHandler("secret", func([]events.Event) error { return nil }, client.WithWebhookMetrics(m))
+	req, err := http.NewRequest(http.MethodPost, "/events", bytes.NewBufferString("[]"))
+	require.NoError(t, err)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	m.ObserveRequest(client.WebhookSignTransaction, http.StatusOK, 10*time.Millisecond)
+	m.ObserveSignOutcome(client.SignOutcomeApproved, nil)
+	m.ObserveSignOutcome(client.SignOutcomeInvoiceErrors, []string{"already_paid", "sku_not_found"})
+	m.ObserveEventBatch(3)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues(client.WebhookEvents, "401")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues(client.WebhookSignTransaction, "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.verificationFailures.WithLabelValues(client.WebhookEvents)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.signOutcomes.WithLabelValues("approved", "none")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.signOutcomes.WithLabelValues("invoice_errors", "already_paid")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.signOutcomes.WithLabelValues("invoice_errors", "sku_not_found")))
+
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body, err := ioutil.ReadAll(rr.Body)
+	require.NoError(t, err)
+	for _, name := range []string{
+		"kin_webhook_requests_total",
+		"kin_webhook_request_duration_seconds",
+		"kin_webhook_verification_failures_total",
+		"kin_webhook_sign_outcomes_total",
+		"kin_webhook_event_batch_size",
+	} {
+		assert.Contains(t, string(body), name)
+	}
+}
+
+func TestMetrics_Registry(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	_, err := New(WithRegistry(registry), WithNamespace("app"))
+	require.NoError(t, err)
+
+	// The metrics cannot be registered twice with the same registry.
+	_, err = New(WithRegistry(registry), WithNamespace("app"))
+	assert.Error(t, err)
+
+	_, err = New(WithRegistry(registry), WithNamespace("other"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/pkg/errors"
+)
+
+// WithWhitelister specifies a key that co-signs transactions on behalf of the
+// app. Any transaction whose fee payer is the whitelister is signed locally
+// before submission, rather than being sent to Agora for signing.
+//
+// This allows apps that run their own subsidizer (for example, by configuring
+// Agora to use it as the app subsidizer) to co-sign transactions without
+// specifying WithSubsidizer on every call.
+func WithWhitelister(key kin.PrivateKey) ClientOption {
+	return func(o *clientOpts) {
+		o.whitelister = key
+	}
+}
+
+// whitelisterFor returns the whitelister if it is the fee payer of a transaction
+// and is not already one of signers.
+func (c *client) whitelisterFor(payer ed25519.PublicKey, signers []kin.PrivateKey) kin.PrivateKey {
+	w := c.opts.whitelister
+	if w == nil || !bytes.Equal(payer, w.Public()) {
+		return nil
+	}
+
+	for _, s := range signers {
+		if bytes.Equal(s.Public(), w.Public()) {
+			return nil
+		}
+	}
+
+	return w
+}
+
+// selectWhitelister uses the whitelister as the subsidizer if no subsidizer was
+// specified and the whitelister is the service subsidizer.
+//
+// This is only required for operations that do not build the transaction
+// themselves, such as CreateAccount.
+func (c *client) selectWhitelister(ctx context.Context, o *solanaOpts) error {
+	if o.subsidizer != nil || c.opts.whitelister == nil {
+		return nil
+	}
+
+	config, err := c.internal.GetServiceConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get service config")
+	}
+
+	if bytes.Equal(config.GetSubsidizerAccount().GetValue(), c.opts.whitelister.Public()) {
+		o.subsidizer = c.opts.whitelister
+	}
+	return nil
+}
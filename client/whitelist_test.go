@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/kinecosystem/agora-common/kin"
+	"github.com/kinecosystem/agora-common/solana"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commonpbv4 "github.com/kinecosystem/agora-api/genproto/common/v4"
+)
+
+func TestClient_Whitelister(t *testing.T) {
+	whitelister, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	env, cleanup := setup(t, WithWhitelister(whitelister))
+	defer cleanup()
+
+	// Configure the whitelister as the service subsidizer, without allowing the
+	// server to sign on its behalf.
+	setServiceConfigResp(t, env.v4Server, true)
+	env.v4Server.Mux.Lock()
+	env.v4Server.ServiceConfig.SubsidizerAccount = &commonpbv4.SolanaAccountId{Value: whitelister.Public()}
+	env.v4Server.Subsidizer = nil
+	env.v4Server.Mux.Unlock()
+
+	sender, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+	dest, err := kin.NewPrivateKey()
+	require.NoError(t, err)
+
+	for _, acc := range []kin.PrivateKey{sender, dest} {
+		require.NoError(t, env.client.CreateAccount(context.Background(), acc))
+	}
+
+	_, err = env.client.SubmitPayment(context.Background(), Payment{
+		Sender:      sender,
+		Destination: dest.Public(),
+		Type:        kin.TransactionTypeSpend,
+		Quarks:      11,
+	})
+	require.NoError(t, err)
+
+	env.v4Server.Mux.Lock()
+	defer env.v4Server.Mux.Unlock()
+
+	assert.Empty(t, env.v4Server.Signs)
+
+	var txs []solana.Transaction
+	for _, create := range env.v4Server.Creates {
+		var tx solana.Transaction
+		require.NoError(t, tx.Unmarshal(create.Transaction.Value))
+		txs = append(txs, tx)
+	}
+	require.Len(t, env.v4Server.Submits, 1)
+	var tx solana.Transaction
+	require.NoError(t, tx.Unmarshal(env.v4Server.Submits[0].Transaction.Value))
+	txs = append(txs, tx)
+
+	require.Len(t, txs, 3)
+	for _, tx := range txs {
+		assert.EqualValues(t, whitelister.Public(), tx.Message.Accounts[0])
+		assert.True(t, ed25519.Verify(ed25519.PublicKey(whitelister.Public()), tx.Message.Marshal(), tx.Signatures[0][:]))
+	}
+}
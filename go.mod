@@ -8,15 +8,18 @@ require (
 	github.com/kinecosystem/agora-api v0.26.1
 	github.com/kinecosystem/agora-common v0.84.0
 	github.com/kinecosystem/go v0.0.0-20191108204735-d6832148266e
+	github.com/lib/pq v1.5.2
 	github.com/mattn/go-colorable v0.1.6 // indirect
 	github.com/mr-tron/base58 v1.2.0
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.7.1
 	github.com/stellar/go v0.0.0-20191211203732-552e507ffa37
 	github.com/stretchr/objx v0.2.0 // indirect
 	github.com/stretchr/testify v1.5.1
 	golang.org/x/text v0.3.4 // indirect
 	google.golang.org/genproto v0.0.0-20201204160425-06b3db808446 // indirect
 	google.golang.org/grpc v1.37.0
+	gopkg.in/yaml.v2 v2.3.0
 )
 
 // This dependency of stellar/go no longer exists; use a forked version of the repo instead.